@@ -6,8 +6,10 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
+	"github.com/loyalty/analytics/internal/events"
 	"github.com/loyalty/analytics/internal/mock"
 	"github.com/loyalty/analytics/internal/models"
 	"github.com/loyalty/analytics/internal/rfm"
@@ -31,7 +33,16 @@ func main() {
 	log.Printf("🗄️  MongoDB: %s", mongoURL)
 
 	// Initialize storage
-	mongoStorage, err := storage.NewMongoStorage(mongoURL, "analytics")
+	mongoConfig := storage.DefaultMongoStorageConfig()
+	if spec := os.Getenv("TENANT_DATABASES"); spec != "" {
+		tenants, err := storage.ParseTenantConfig(spec)
+		if err != nil {
+			log.Fatalf("Invalid TENANT_DATABASES: %v", err)
+		}
+		mongoConfig.Tenants = tenants
+	}
+
+	mongoStorage, err := storage.NewMongoStorageWithConfig(mongoURL, "analytics", mongoConfig)
 	if err != nil {
 		log.Fatalf("Failed to create MongoDB storage: %v", err)
 	}
@@ -41,12 +52,22 @@ func main() {
 	rfmStorage := rfm.NewRFMStorage(mongoStorage)
 	rfmCalculator := rfm.NewRFMCalculator(rfmStorage)
 
-	tierStorage := tiers.NewTierStorage(mongoStorage.GetClient(), mongoStorage.GetDatabase())
+	tierStorage := tiers.NewTierStorageWithTenants(mongoStorage.GetClient(), mongoStorage.GetDatabase(), mongoStorage.GetTenants())
 	tierCalculator := tiers.NewTierCalculator(tierStorage)
 
+	var interactions events.InteractionConfig
+	if actionTypes := os.Getenv("LOYALTY_ACTION_INTERACTION_TYPES"); actionTypes != "" {
+		interactions.ActionTypes = strings.Split(actionTypes, ",")
+	}
+	interactions.CountTransactions = os.Getenv("LOYALTY_ACTION_INTERACTIONS_COUNT_AS_TRANSACTIONS") == "true"
+
 	// Connect to mock Kafka
 	client := mock.NewMockKafkaClient(mockKafkaURL)
-	if err := client.Connect("*.pos.transaction"); err != nil {
+	topic := "*.pos.transaction"
+	if len(interactions.ActionTypes) > 0 {
+		topic = "*"
+	}
+	if err := client.Connect(topic); err != nil {
 		log.Fatalf("Failed to connect to mock Kafka: %v", err)
 	}
 	defer client.Close()
@@ -87,7 +108,7 @@ func main() {
 			log.Printf("📨 Received event %d: %s from %s (Amount: %v)", 
 				eventCount, event.EventType, event.CustomerID, event.Payload["amount"])
 
-			if err := processEvent(ctx, event, rfmCalculator, tierCalculator); err != nil {
+			if err := processEvent(ctx, event, rfmCalculator, rfmStorage, tierCalculator, tierStorage, interactions); err != nil {
 				log.Printf("❌ Error processing event %d: %v", eventCount, err)
 			} else {
 				log.Printf("✅ Event %d processed successfully - stored in MongoDB", eventCount)
@@ -96,10 +117,14 @@ func main() {
 	}
 }
 
-func processEvent(ctx context.Context, event models.BaseEvent, rfmCalc *rfm.RFMCalculator, tierCalc *tiers.TierCalculator) error {
+func processEvent(ctx context.Context, event models.BaseEvent, rfmCalc *rfm.RFMCalculator, rfmStorage *rfm.RFMStorage, tierCalc *tiers.TierCalculator, tierStorage *tiers.TierStorage, interactions events.InteractionConfig) error {
 	log.Printf("🔍 Processing event type: %s for customer: %s in org: %s", 
 		event.EventType, event.CustomerID, event.OrgID)
 	
+	if event.EventType == "loyalty.action" {
+		return processInteraction(ctx, event, rfmCalc, rfmStorage, interactions)
+	}
+
 	if event.EventType != "pos.transaction" {
 		log.Printf("⚠️  Skipping non-transaction event: %s", event.EventType)
 		return nil
@@ -142,28 +167,52 @@ func processEvent(ctx context.Context, event models.BaseEvent, rfmCalc *rfm.RFMC
 		log.Printf("✅ RFM calculated and stored for %s: $%.2f", event.CustomerID, transaction.Amount)
 	}
 
-	// Create customer metrics for tier calculation
-	metrics := tiers.CustomerMetrics{
-		OrgID:             event.OrgID,
-		LocationID:        event.LocationID,
-		CustomerID:        event.CustomerID,
-		TotalSpent:        transaction.Amount,
-		TotalVisits:       1,
-		SpentThisYear:     transaction.Amount,
-		VisitsThisYear:    1,
-		SpentThisMonth:    transaction.Amount,
-		VisitsThisMonth:   1,
-		LastTransaction:   event.Timestamp,
-		TransactionAmount: transaction.Amount,
+	// Count the transaction into the customer's tier metrics
+	metrics, err := tierStorage.AccumulateCustomerMetrics(ctx, event.OrgID, event.LocationID, event.CustomerID, transaction.Amount, event.Timestamp)
+	if err != nil {
+		log.Printf("❌ Tier metrics update failed for %s: %v", event.CustomerID, err)
+		return nil
 	}
 
 	// Process tier calculation
 	log.Printf("🏆 Starting tier calculation for %s...", event.CustomerID)
-	if err := tierCalc.ProcessCustomerMetrics(ctx, metrics); err != nil {
+	if err := tierCalc.ProcessCustomerMetrics(ctx, *metrics); err != nil {
 		log.Printf("❌ Tier calculation failed for %s: %v", event.CustomerID, err)
 	} else {
 		log.Printf("✅ Tier calculated and stored for %s: $%.2f", event.CustomerID, transaction.Amount)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+// processInteraction moves the customer's recency for loyalty actions
+// configured as interactions, leaving their spend alone
+func processInteraction(ctx context.Context, event models.BaseEvent, rfmCalc *rfm.RFMCalculator, rfmStorage *rfm.RFMStorage, interactions events.InteractionConfig) error {
+	action, err := events.ParseLoyaltyAction(event.Payload)
+	if err != nil {
+		log.Printf("❌ Failed to parse loyalty action: %v", err)
+		return err
+	}
+	if !interactions.IsInteraction(action) {
+		log.Printf("⚠️  Skipping loyalty action: %s", action.ActionType)
+		return nil
+	}
+
+	activity, err := rfmStorage.RecordCustomerInteraction(ctx, models.CustomerActivity{
+		OrgID:           event.OrgID,
+		LocationID:      event.LocationID,
+		CustomerID:      event.CustomerID,
+		TransactionDate: event.Timestamp,
+	}, interactions.CountTransactions)
+	if err != nil {
+		log.Printf("❌ Failed to record interaction for %s: %v", event.CustomerID, err)
+		return err
+	}
+
+	if err := rfmCalc.ProcessCustomerTransaction(ctx, *activity); err != nil {
+		log.Printf("❌ RFM calculation failed for %s: %v", event.CustomerID, err)
+	} else {
+		log.Printf("✅ RFM recency updated for %s by %s", event.CustomerID, action.ActionType)
+	}
+
+	return nil
+}
@@ -6,13 +6,17 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/loyalty/analytics/internal/events"
 	"github.com/loyalty/analytics/internal/models"
+	"github.com/loyalty/analytics/internal/rewards"
 	"github.com/loyalty/analytics/internal/rfm"
 	"github.com/loyalty/analytics/internal/storage"
+	"github.com/loyalty/analytics/internal/throttle"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -20,6 +24,18 @@ type POSTransaction struct {
 	TransactionID string    `json:"transaction_id"`
 	Amount        float64   `json:"amount"`
 	Timestamp     time.Time `json:"timestamp"`
+	// Refund marks Amount as returned, so it comes off the customer's spend
+	Refund        bool      `json:"refund"`
+}
+
+// RewardTriggered is the payload of a reward.triggered event
+type RewardTriggered struct {
+	RewardID      string    `json:"reward_id"`
+	RewardType    string    `json:"reward_type"`
+	RewardValue   string    `json:"reward_value"`
+	Description   string    `json:"description"`
+	TriggeredAt   time.Time `json:"triggered_at"`
+	SourceEventID string    `json:"source_event_id"`
 }
 
 type BaseEvent struct {
@@ -32,6 +48,23 @@ type BaseEvent struct {
 	Payload    map[string]interface{} `json:"payload"`
 }
 
+// eventOptions holds the configured rules for turning events into
+// transactions
+type eventOptions struct {
+	spend        events.SpendConfig
+	interactions events.InteractionConfig
+	timestamps   events.TimestampPolicy
+	// refundsReverseTransactions uncounts a transaction for each refund as
+	// well as taking its amount off the customer's spend
+	refundsReverseTransactions bool
+	// rewards, when set, records reward.triggered events for the analytics
+	// dashboard's reward counts
+	rewards rewards.RewardRecorderInterface
+	// customerMetrics reads transactions from the stream processor's
+	// customer.metrics.updated events instead of raw POS events
+	customerMetrics bool
+}
+
 func main() {
 	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
 	if kafkaBrokers == "" {
@@ -48,16 +81,199 @@ func main() {
 		consumerGroupID = "rfm-processor"
 	}
 
-	mongoStorage, err := storage.NewMongoStorage(mongoURL, "analytics")
+	mongoConfig := storage.DefaultMongoStorageConfig()
+	if spec := os.Getenv("TENANT_DATABASES"); spec != "" {
+		tenants, err := storage.ParseTenantConfig(spec)
+		if err != nil {
+			log.Fatalf("Invalid TENANT_DATABASES: %v", err)
+		}
+		mongoConfig.Tenants = tenants
+	}
+
+	mongoStorage, err := storage.NewMongoStorageWithConfig(mongoURL, "analytics", mongoConfig)
 	if err != nil {
 		log.Fatalf("Failed to create MongoDB storage: %v", err)
 	}
 	defer mongoStorage.Close()
 
-	rfmStorage := rfm.NewRFMStorage(mongoStorage)
-	calculator := rfm.NewRFMCalculator(rfmStorage)
+	storageConfig := rfm.DefaultStorageConfig()
+	if saveAttempts := os.Getenv("QUINTILE_SAVE_ATTEMPTS"); saveAttempts != "" {
+		attempts, err := strconv.Atoi(saveAttempts)
+		if err != nil || attempts < 1 {
+			log.Fatalf("Invalid QUINTILE_SAVE_ATTEMPTS %q", saveAttempts)
+		}
+		storageConfig.QuintileSaveAttempts = attempts
+	}
+	if saveBackoff := os.Getenv("QUINTILE_SAVE_BACKOFF"); saveBackoff != "" {
+		backoff, err := time.ParseDuration(saveBackoff)
+		if err != nil {
+			log.Fatalf("Invalid QUINTILE_SAVE_BACKOFF %q: %v", saveBackoff, err)
+		}
+		storageConfig.QuintileSaveBackoff = backoff
+	}
+	storageConfig.QuintileHistory = os.Getenv("RFM_QUINTILE_HISTORY") == "true"
+	if value := os.Getenv("RFM_RECALC_INTERVAL"); value != "" {
+		interval, err := rfm.ParseRecalcInterval(value)
+		if err != nil {
+			log.Fatalf("Invalid RFM_RECALC_INTERVAL: %v", err)
+		}
+		storageConfig.RecalcInterval = interval
+	}
+	if spec := os.Getenv("RFM_ORG_RECALC_INTERVALS"); spec != "" {
+		intervals, err := rfm.ParseOrgRecalcIntervals(spec)
+		if err != nil {
+			log.Fatalf("Invalid RFM_ORG_RECALC_INTERVALS: %v", err)
+		}
+		storageConfig.OrgRecalcIntervals = intervals
+	}
+
+	var verticals rfm.VerticalConfig
+	if name := os.Getenv("RFM_VERTICAL"); name != "" {
+		vertical, err := rfm.ParseVertical(name)
+		if err != nil {
+			log.Fatalf("Invalid RFM_VERTICAL: %v", err)
+		}
+		verticals.Default = vertical
+	}
+	if spec := os.Getenv("RFM_ORG_VERTICALS"); spec != "" {
+		orgVerticals, err := rfm.ParseOrgVerticals(spec)
+		if err != nil {
+			log.Fatalf("Invalid RFM_ORG_VERTICALS: %v", err)
+		}
+		verticals.Orgs = orgVerticals
+	}
+	if spec := os.Getenv("RFM_ORG_WEIGHTS"); spec != "" {
+		orgWeights, err := rfm.ParseOrgWeights(spec)
+		if err != nil {
+			log.Fatalf("Invalid RFM_ORG_WEIGHTS: %v", err)
+		}
+		verticals.Weights = orgWeights
+	}
+	storageConfig.Verticals = verticals
+
+	var sampling rfm.QuintileSampling
+	if sampleSize := os.Getenv("RFM_QUINTILE_SAMPLE_SIZE"); sampleSize != "" {
+		size, err := strconv.Atoi(sampleSize)
+		if err != nil || size < 0 {
+			log.Fatalf("Invalid RFM_QUINTILE_SAMPLE_SIZE %q", sampleSize)
+		}
+		sampling.SampleSize = size
+	}
+	if batchSize := os.Getenv("RFM_ACTIVITY_BATCH_SIZE"); batchSize != "" {
+		size, err := strconv.Atoi(batchSize)
+		if err != nil || size < 0 {
+			log.Fatalf("Invalid RFM_ACTIVITY_BATCH_SIZE %q", batchSize)
+		}
+		sampling.BatchSize = size
+	}
+	storageConfig.Sampling = sampling
+
+	buckets := rfm.DefaultBuckets
+	if value := os.Getenv("RFM_BUCKETS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 2 || n > rfm.MaxBuckets {
+			log.Fatalf("Invalid RFM_BUCKETS %q: must be from 2 to %d", value, rfm.MaxBuckets)
+		}
+		buckets = n
+	}
+	storageConfig.Buckets = buckets
+
+	rfmStorage := rfm.NewRFMStorageWithConfig(mongoStorage, storageConfig)
+
+	calculatorConfig := rfm.DefaultCalculatorConfig()
+	calculatorConfig.Verticals = verticals
+	calculatorConfig.Sampling = sampling
+	calculatorConfig.Buckets = buckets
+	if minTransactions := os.Getenv("RFM_MIN_TRANSACTIONS"); minTransactions != "" {
+		min, err := strconv.Atoi(minTransactions)
+		if err != nil || min < 0 {
+			log.Fatalf("Invalid RFM_MIN_TRANSACTIONS %q", minTransactions)
+		}
+		calculatorConfig.MinTransactionsForSegment = min
+	}
+	if segment := os.Getenv("RFM_INSUFFICIENT_DATA_SEGMENT"); segment != "" {
+		calculatorConfig.InsufficientDataSegment = segment
+	}
+	calculatorConfig.LocationQuintiles = os.Getenv("RFM_LOCATION_QUINTILES") == "true"
 
 	brokerList := strings.Split(kafkaBrokers, ",")
+
+	// Segment changes are published for downstream campaign systems
+	var segmentWriter *kafka.Writer
+	if os.Getenv("PUBLISH_SEGMENT_CHANGES") == "true" {
+		segmentWriter = &kafka.Writer{
+			Addr:     kafka.TCP(brokerList...),
+			Balancer: &kafka.Hash{},
+		}
+		calculatorConfig.SegmentWriter = segmentWriter
+	}
+
+	calculator := rfm.NewRFMCalculatorWithConfig(rfmStorage, calculatorConfig)
+
+	spendConfig := events.DefaultSpendConfig()
+	if actionTypes := os.Getenv("LOYALTY_ACTION_SPEND_TYPES"); actionTypes != "" {
+		spendConfig.ActionTypes = strings.Split(actionTypes, ",")
+	}
+	if spendPerPoint := os.Getenv("LOYALTY_ACTION_SPEND_PER_POINT"); spendPerPoint != "" {
+		rate, err := strconv.ParseFloat(spendPerPoint, 64)
+		if err != nil || rate <= 0 {
+			log.Fatalf("Invalid LOYALTY_ACTION_SPEND_PER_POINT %q", spendPerPoint)
+		}
+		spendConfig.SpendPerPoint = rate
+	}
+
+	var interactionConfig events.InteractionConfig
+	if actionTypes := os.Getenv("LOYALTY_ACTION_INTERACTION_TYPES"); actionTypes != "" {
+		interactionConfig.ActionTypes = strings.Split(actionTypes, ",")
+	}
+	interactionConfig.CountTransactions = os.Getenv("LOYALTY_ACTION_INTERACTIONS_COUNT_AS_TRANSACTIONS") == "true"
+
+	timestampPolicy := events.DefaultTimestampPolicy()
+	if maxSkew := os.Getenv("EVENT_MAX_FUTURE_SKEW"); maxSkew != "" {
+		skew, err := time.ParseDuration(maxSkew)
+		if err != nil {
+			log.Fatalf("Invalid EVENT_MAX_FUTURE_SKEW %q: %v", maxSkew, err)
+		}
+		timestampPolicy.MaxFutureSkew = skew
+	}
+	if mode := os.Getenv("EVENT_FUTURE_TIMESTAMP_MODE"); mode != "" {
+		if mode != events.FutureTimestampClamp && mode != events.FutureTimestampReject {
+			log.Fatalf("Invalid EVENT_FUTURE_TIMESTAMP_MODE %q: must be clamp or reject", mode)
+		}
+		timestampPolicy.Mode = mode
+	}
+
+	options := eventOptions{
+		spend:                      spendConfig,
+		interactions:               interactionConfig,
+		timestamps:                 timestampPolicy,
+		refundsReverseTransactions: os.Getenv("RFM_REFUNDS_REVERSE_TRANSACTIONS") == "true",
+		customerMetrics:            os.Getenv("CONSUME_CUSTOMER_METRICS") == "true",
+	}
+	if os.Getenv("RECORD_TRIGGERED_REWARDS") == "true" {
+		options.rewards = rewards.NewRewardStorageWithTenants(mongoStorage.GetTenants())
+	}
+
+	var recomputeInterval time.Duration
+	if interval := os.Getenv("RECOMPUTE_INTERVAL"); interval != "" {
+		recomputeInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			log.Fatalf("Invalid RECOMPUTE_INTERVAL %q: %v", interval, err)
+		}
+	}
+
+	// Activity is saved per message but the RFM recompute is throttled per
+	// customer and location. Activity totals are cumulative, so the latest one wins. A
+	// throttled recompute outlives its message, so it has its own context.
+	recompute := throttle.NewThrottler(recomputeInterval, func(activity models.CustomerActivity) {
+		if err := calculator.ProcessCustomerTransaction(context.Background(), activity); err != nil {
+			log.Printf("Error recomputing RFM for customer %s: %v", activity.CustomerID, err)
+			return
+		}
+		log.Printf("Updated RFM for customer %s: %d transactions, $%.2f total",
+			activity.CustomerID, activity.TotalTransactions, activity.TotalSpent)
+	})
+
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:     brokerList,
 		GroupID:     consumerGroupID,
@@ -88,9 +304,15 @@ func main() {
 		select {
 		case <-ctx.Done():
 			log.Println("Context cancelled, stopping RFM processor")
+			recompute.Flush()
 			if err := reader.Close(); err != nil {
 				log.Printf("Error closing reader: %v", err)
 			}
+			if segmentWriter != nil {
+				if err := segmentWriter.Close(); err != nil {
+					log.Printf("Error closing segment writer: %v", err)
+				}
+			}
 			return
 		default:
 			message, err := reader.FetchMessage(ctx)
@@ -103,8 +325,8 @@ func main() {
 				continue
 			}
 
-			if shouldProcessMessage(string(message.Topic)) {
-				if err := processMessage(ctx, message, calculator, rfmStorage); err != nil {
+			if shouldProcessMessage(string(message.Topic), options) {
+				if err := processMessage(ctx, message, recompute, rfmStorage, options); err != nil {
 					log.Printf("Error processing message: %v", err)
 				}
 			}
@@ -116,11 +338,14 @@ func main() {
 	}
 }
 
-func shouldProcessMessage(topic string) bool {
+func shouldProcessMessage(topic string, options eventOptions) bool {
 	patterns := []string{
-		".pos.transaction",
+		events.TransactionTopic(options.customerMetrics),
 		".loyalty.action",
 	}
+	if options.rewards != nil {
+		patterns = append(patterns, ".reward.triggered")
+	}
 	
 	for _, pattern := range patterns {
 		if strings.Contains(topic, pattern) {
@@ -130,79 +355,169 @@ func shouldProcessMessage(topic string) bool {
 	return false
 }
 
-func processMessage(ctx context.Context, message kafka.Message, calculator *rfm.RFMCalculator, storage *rfm.RFMStorage) error {
+func processMessage(ctx context.Context, message kafka.Message, recompute *throttle.Throttler[models.CustomerActivity], storage *rfm.RFMStorage, options eventOptions) error {
 	var event BaseEvent
 	if err := json.Unmarshal(message.Value, &event); err != nil {
 		return err
 	}
 
-	if event.EventType != "pos.transaction" {
+	var transaction POSTransaction
+	switch event.EventType {
+	case "pos.transaction":
+		if options.customerMetrics {
+			return nil
+		}
+		transactionData, err := json.Marshal(event.Payload)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(transactionData, &transaction); err != nil {
+			return err
+		}
+	case "customer.metrics.updated":
+		if !options.customerMetrics {
+			return nil
+		}
+		metrics, err := events.ParseCustomerMetrics(event.Payload)
+		if err != nil {
+			return err
+		}
+
+		transaction = POSTransaction{
+			TransactionID: metrics.TransactionID,
+			Amount:        metrics.Amount,
+			Timestamp:     metrics.Timestamp,
+			Refund:        metrics.Refund,
+		}
+	case "loyalty.action":
+		action, err := events.ParseLoyaltyAction(event.Payload)
+		if err != nil {
+			return err
+		}
+
+		amount, ok := options.spend.SpendEquivalent(action)
+		if !ok {
+			// An action that is not spend may still be a visit
+			if !options.interactions.IsInteraction(action) {
+				return nil
+			}
+			return processInteraction(ctx, event, recompute, storage, options)
+		}
+
+		transaction = POSTransaction{
+			TransactionID: action.Reference,
+			Amount:        amount,
+			Timestamp:     event.Timestamp,
+		}
+	case "reward.triggered":
+		if options.rewards == nil {
+			return nil
+		}
+		return recordTriggeredReward(ctx, event, options.rewards)
+	default:
 		return nil
 	}
 
-	var transaction POSTransaction
-	transactionData, err := json.Marshal(event.Payload)
+	timestamp, err := options.timestamps.Check(event.EventID, transaction.Timestamp)
 	if err != nil {
 		return err
 	}
+	transaction.Timestamp = timestamp
 
-	if err := json.Unmarshal(transactionData, &transaction); err != nil {
-		return err
-	}
+	return processTransaction(ctx, event, transaction, recompute, storage, options)
+}
 
-	existingActivity, err := getExistingActivity(ctx, storage, event.OrgID, event.CustomerID)
+// recordTriggeredReward stores the reward with the location of the event
+// that triggered it
+func recordTriggeredReward(ctx context.Context, event BaseEvent, recorder rewards.RewardRecorderInterface) error {
+	payload, err := json.Marshal(event.Payload)
 	if err != nil {
-		log.Printf("Customer activity not found, creating new: %v", err)
-		existingActivity = &models.CustomerActivity{
-			OrgID:             event.OrgID,
-			CustomerID:        event.CustomerID,
-			FirstTransaction:  transaction.Timestamp,
-			LastTransaction:   transaction.Timestamp,
-			TotalTransactions: 0,
-			TotalSpent:        0,
-		}
+		return err
 	}
 
-	activity := models.CustomerActivity{
-		OrgID:             event.OrgID,
-		CustomerID:        event.CustomerID,
-		TransactionDate:   transaction.Timestamp,
-		Amount:            transaction.Amount,
-		FirstTransaction:  existingActivity.FirstTransaction,
-		LastTransaction:   transaction.Timestamp,
-		TotalTransactions: existingActivity.TotalTransactions + 1,
-		TotalSpent:        existingActivity.TotalSpent + transaction.Amount,
+	var reward RewardTriggered
+	if err := json.Unmarshal(payload, &reward); err != nil {
+		return err
 	}
 
-	if transaction.Timestamp.Before(existingActivity.FirstTransaction) {
-		activity.FirstTransaction = transaction.Timestamp
+	triggeredAt := reward.TriggeredAt
+	if triggeredAt.IsZero() {
+		triggeredAt = event.Timestamp
 	}
 
-	if err := storage.UpdateCustomerActivity(ctx, activity); err != nil {
-		return err
+	return recorder.SaveTriggeredReward(ctx, rewards.TriggeredReward{
+		EventID:       event.EventID,
+		OrgID:         event.OrgID,
+		LocationID:    event.LocationID,
+		CustomerID:    event.CustomerID,
+		RewardID:      reward.RewardID,
+		RewardType:    reward.RewardType,
+		RewardValue:   reward.RewardValue,
+		Description:   reward.Description,
+		SourceEventID: reward.SourceEventID,
+		TriggeredAt:   triggeredAt,
+	})
+}
+
+func processTransaction(ctx context.Context, event BaseEvent, transaction POSTransaction, recompute *throttle.Throttler[models.CustomerActivity], storage *rfm.RFMStorage, options eventOptions) error {
+	// The storage layer adds the transaction to the running totals itself,
+	// so concurrent transactions for the customer do not overwrite each
+	// other. A refund takes spend back but is not a visit.
+	activity := models.CustomerActivity{
+		OrgID:           event.OrgID,
+		LocationID:      event.LocationID,
+		CustomerID:      event.CustomerID,
+		TransactionDate: transaction.Timestamp,
+		Amount:          transaction.Amount,
 	}
 
-	if err := calculator.ProcessCustomerTransaction(ctx, activity); err != nil {
+	var updated *models.CustomerActivity
+	var err error
+	if transaction.Refund {
+		if transaction.Amount <= 0 {
+			return nil
+		}
+		activity.Amount = -transaction.Amount
+		updated, err = storage.RecordCustomerRefund(ctx, activity, options.refundsReverseTransactions)
+	} else {
+		updated, err = storage.UpdateCustomerActivity(ctx, activity)
+	}
+	if err != nil {
 		return err
 	}
 
-	log.Printf("Updated RFM for customer %s: %d transactions, $%.2f total",
-		event.CustomerID, activity.TotalTransactions, activity.TotalSpent)
+	recompute.Submit(recomputeKey(event), *updated)
 
 	return nil
 }
 
-func getExistingActivity(ctx context.Context, storage *rfm.RFMStorage, orgID, customerID string) (*models.CustomerActivity, error) {
-	activities, err := storage.GetCustomerActivities(ctx, orgID)
+// recomputeKey throttles recomputes per scored activity. Activity and RFM
+// scores are kept per location, so one location's pending recompute must not
+// replace another's.
+func recomputeKey(event BaseEvent) string {
+	return event.OrgID + ":" + event.LocationID + ":" + event.CustomerID
+}
+
+// processInteraction counts a loyalty action as a visit without spend, so it
+// moves the customer's recency but not their monetary total
+func processInteraction(ctx context.Context, event BaseEvent, recompute *throttle.Throttler[models.CustomerActivity], storage *rfm.RFMStorage, options eventOptions) error {
+	timestamp, err := options.timestamps.Check(event.EventID, event.Timestamp)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for _, activity := range activities {
-		if activity.CustomerID == customerID {
-			return &activity, nil
-		}
+	activity, err := storage.RecordCustomerInteraction(ctx, models.CustomerActivity{
+		OrgID:           event.OrgID,
+		LocationID:      event.LocationID,
+		CustomerID:      event.CustomerID,
+		TransactionDate: timestamp,
+	}, options.interactions.CountTransactions)
+	if err != nil {
+		return err
 	}
 
-	return nil, nil
-}
\ No newline at end of file
+	recompute.Submit(recomputeKey(event), *activity)
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/events"
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/loyalty/analytics/internal/rfm"
+	"github.com/loyalty/analytics/internal/throttle"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// activityRecorder stands in for Mongo, recording the activity the processor
+// stores. Methods it does not override panic if called.
+type activityRecorder struct {
+	rfm.MongoStorageInterface
+	mu      sync.Mutex
+	updates []models.CustomerActivity
+	refunds []models.CustomerActivity
+	scores  []models.RFMScore
+}
+
+func (r *activityRecorder) GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error) {
+	return &models.RFMQuintiles{
+		OrgID:              orgID,
+		RecencyQuintiles:   []int{7, 30, 90, 180},
+		FrequencyQuintiles: []int{1, 3, 5, 10},
+		MonetaryQuintiles:  []float64{20, 50, 100, 250},
+		CalculatedAt:       time.Now(),
+	}, nil
+}
+
+func (r *activityRecorder) SaveRFMScore(ctx context.Context, score models.RFMScore) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scores = append(r.scores, score)
+	return nil
+}
+
+func (r *activityRecorder) UpdateCustomerActivity(ctx context.Context, activity models.CustomerActivity) (*models.CustomerActivity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, activity)
+	return &activity, nil
+}
+
+func (r *activityRecorder) RecordCustomerRefund(ctx context.Context, activity models.CustomerActivity, reverseTransaction bool) (*models.CustomerActivity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refunds = append(r.refunds, activity)
+	return &activity, nil
+}
+
+// Test setup helper
+func setupTestProcessor() (*activityRecorder, *rfm.RFMStorage, *throttle.Throttler[models.CustomerActivity], *[]models.CustomerActivity) {
+	recorder := &activityRecorder{}
+	var recomputed []models.CustomerActivity
+	recompute := throttle.NewThrottler(0, func(activity models.CustomerActivity) {
+		recomputed = append(recomputed, activity)
+	})
+	return recorder, rfm.NewRFMStorage(recorder), recompute, &recomputed
+}
+
+func eventMessage(t *testing.T, topic string, event BaseEvent) kafka.Message {
+	value, err := json.Marshal(event)
+	assert.NoError(t, err)
+	return kafka.Message{Topic: topic, Value: value}
+}
+
+func customerMetricsMessage(t *testing.T, payload map[string]interface{}) kafka.Message {
+	return eventMessage(t, "test_org.customer.metrics.updated", BaseEvent{
+		EventID:    "evt_1_metrics",
+		EventType:  "customer.metrics.updated",
+		OrgID:      "test_org",
+		LocationID: "loc_1",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now().Add(-time.Hour),
+		Payload:    payload,
+	})
+}
+
+// Test processMessage
+func TestProcessMessage_CustomerMetricsCountSpend(t *testing.T) {
+	options := eventOptions{timestamps: events.DefaultTimestampPolicy(), customerMetrics: true}
+	recorder, storage, recompute, recomputed := setupTestProcessor()
+	timestamp := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	for _, status := range []string{"applied", "failed"} {
+		message := customerMetricsMessage(t, map[string]interface{}{
+			"transaction_id": "txn_1",
+			"amount":         40.0,
+			"timestamp":      timestamp,
+			"accrual_status": status,
+		})
+		assert.True(t, shouldProcessMessage(message.Topic, options))
+
+		err := processMessage(context.Background(), message, recompute, storage, options)
+
+		// Assertions - the spend counts whether or not the ledger applied it
+		assert.NoError(t, err, status)
+	}
+	if assert.Len(t, recorder.updates, 2) {
+		activity := recorder.updates[0]
+		assert.Equal(t, "test_org", activity.OrgID)
+		assert.Equal(t, "loc_1", activity.LocationID)
+		assert.Equal(t, "test_customer", activity.CustomerID)
+		assert.Equal(t, 40.0, activity.Amount)
+		assert.True(t, timestamp.Equal(activity.TransactionDate))
+	}
+	assert.Len(t, *recomputed, 2)
+}
+
+func TestProcessMessage_CustomerMetricsRefund(t *testing.T) {
+	options := eventOptions{timestamps: events.DefaultTimestampPolicy(), customerMetrics: true}
+	recorder, storage, recompute, _ := setupTestProcessor()
+
+	message := customerMetricsMessage(t, map[string]interface{}{
+		"transaction_id": "txn_1",
+		"amount":         15.0,
+		"refund":         true,
+		"timestamp":      time.Now().Add(-time.Hour),
+		"accrual_status": "applied",
+	})
+
+	err := processMessage(context.Background(), message, recompute, storage, options)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, recorder.updates)
+	if assert.Len(t, recorder.refunds, 1) {
+		assert.Equal(t, -15.0, recorder.refunds[0].Amount)
+	}
+}
+
+func TestProcessMessage_IgnoresTheTopicNotConsumed(t *testing.T) {
+	pos := eventMessage(t, "test_org.pos.transaction", BaseEvent{
+		EventID:    "evt_1",
+		EventType:  "pos.transaction",
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Payload:    map[string]interface{}{"transaction_id": "txn_1", "amount": 40.0},
+	})
+	metrics := customerMetricsMessage(t, map[string]interface{}{"transaction_id": "txn_1", "amount": 40.0})
+
+	// Reading both would count every transaction twice
+	for _, test := range []struct {
+		customerMetrics bool
+		ignored         kafka.Message
+	}{
+		{customerMetrics: true, ignored: pos},
+		{customerMetrics: false, ignored: metrics},
+	} {
+		options := eventOptions{timestamps: events.DefaultTimestampPolicy(), customerMetrics: test.customerMetrics}
+		recorder, storage, recompute, _ := setupTestProcessor()
+
+		err := processMessage(context.Background(), test.ignored, recompute, storage, options)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.False(t, shouldProcessMessage(test.ignored.Topic, options))
+		assert.Empty(t, recorder.updates)
+	}
+}
+
+func TestProcessMessage_POSLocationReachesActivityAndScore(t *testing.T) {
+	options := eventOptions{timestamps: events.DefaultTimestampPolicy()}
+	recorder := &activityRecorder{}
+	storage := rfm.NewRFMStorage(recorder)
+	calculator := rfm.NewRFMCalculator(storage)
+	recompute := throttle.NewThrottler(0, func(activity models.CustomerActivity) {
+		assert.NoError(t, calculator.ProcessCustomerTransaction(context.Background(), activity))
+	})
+
+	message := eventMessage(t, "test_org.pos.transaction", BaseEvent{
+		EventID:    "evt_1",
+		EventType:  "pos.transaction",
+		OrgID:      "test_org",
+		LocationID: "store_downtown",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now().Add(-time.Hour),
+		Payload: map[string]interface{}{
+			"transaction_id": "txn_1",
+			"amount":         40.0,
+			"timestamp":      time.Now().Add(-time.Hour),
+		},
+	})
+	assert.True(t, shouldProcessMessage(message.Topic, options))
+
+	err := processMessage(context.Background(), message, recompute, storage, options)
+
+	// Assertions
+	assert.NoError(t, err)
+	if assert.Len(t, recorder.updates, 1) {
+		assert.Equal(t, "store_downtown", recorder.updates[0].LocationID)
+	}
+	if assert.Len(t, recorder.scores, 1) {
+		assert.Equal(t, "store_downtown", recorder.scores[0].LocationID)
+		assert.Equal(t, "test_customer", recorder.scores[0].CustomerID)
+	}
+}
+
+func TestProcessMessage_ThrottledRecomputePerLocation(t *testing.T) {
+	options := eventOptions{timestamps: events.DefaultTimestampPolicy()}
+	recorder := &activityRecorder{}
+	storage := rfm.NewRFMStorage(recorder)
+	calculator := rfm.NewRFMCalculator(storage)
+	recompute := throttle.NewThrottler(time.Hour, func(activity models.CustomerActivity) {
+		assert.NoError(t, calculator.ProcessCustomerTransaction(context.Background(), activity))
+	})
+
+	// Process events - the same customer at two locations in one interval
+	for i, locationID := range []string{"store_downtown", "store_airport"} {
+		message := eventMessage(t, "test_org.pos.transaction", BaseEvent{
+			EventID:    fmt.Sprintf("evt_%d", i),
+			EventType:  "pos.transaction",
+			OrgID:      "test_org",
+			LocationID: locationID,
+			CustomerID: "test_customer",
+			Timestamp:  time.Now().Add(-time.Hour),
+			Payload: map[string]interface{}{
+				"transaction_id": fmt.Sprintf("txn_%d", i),
+				"amount":         40.0,
+				"timestamp":      time.Now().Add(-time.Hour),
+			},
+		})
+		assert.NoError(t, processMessage(context.Background(), message, recompute, storage, options))
+	}
+	recompute.Flush()
+
+	// Assertions - each location's score is saved
+	var locations []string
+	for _, score := range recorder.scores {
+		locations = append(locations, score.LocationID)
+	}
+	assert.ElementsMatch(t, []string{"store_downtown", "store_airport"}, locations)
+}
@@ -6,11 +6,15 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/loyalty/analytics/internal/clients"
+	"github.com/loyalty/analytics/internal/events"
 	"github.com/loyalty/analytics/internal/storage"
+	"github.com/loyalty/analytics/internal/throttle"
 	"github.com/loyalty/analytics/internal/tiers"
 	"github.com/segmentio/kafka-go"
 )
@@ -29,6 +33,22 @@ type POSTransaction struct {
 	TransactionID string    `json:"transaction_id"`
 	Amount        float64   `json:"amount"`
 	Timestamp     time.Time `json:"timestamp"`
+	// Refund marks Amount as returned, so it comes off the customer's spend
+	Refund        bool      `json:"refund"`
+}
+
+type CustomerUpdate struct {
+	Tier string `json:"tier"`
+}
+
+// eventOptions holds the configured rules for turning events into
+// transactions
+type eventOptions struct {
+	spend      events.SpendConfig
+	timestamps events.TimestampPolicy
+	// customerMetrics reads transactions from the stream processor's
+	// customer.metrics.updated events instead of raw POS events
+	customerMetrics bool
 }
 
 func main() {
@@ -47,14 +67,96 @@ func main() {
 		consumerGroupID = "tier-processor"
 	}
 
-	mongoStorage, err := storage.NewMongoStorage(mongoURL, "analytics")
+	ledgerURL := os.Getenv("LEDGER_URL")
+	if ledgerURL == "" {
+		ledgerURL = "http://localhost:8001"
+	}
+
+	mongoConfig := storage.DefaultMongoStorageConfig()
+	if spec := os.Getenv("TENANT_DATABASES"); spec != "" {
+		tenants, err := storage.ParseTenantConfig(spec)
+		if err != nil {
+			log.Fatalf("Invalid TENANT_DATABASES: %v", err)
+		}
+		mongoConfig.Tenants = tenants
+	}
+
+	mongoStorage, err := storage.NewMongoStorageWithConfig(mongoURL, "analytics", mongoConfig)
 	if err != nil {
 		log.Fatalf("Failed to create MongoDB storage: %v", err)
 	}
 	defer mongoStorage.Close()
 
-	tierStorage := tiers.NewTierStorage(mongoStorage.GetClient(), mongoStorage.GetDatabase())
-	calculator := tiers.NewTierCalculator(tierStorage)
+	calculatorConfig := tiers.DefaultCalculatorConfig()
+	if overrideDuration := os.Getenv("TIER_OVERRIDE_DURATION"); overrideDuration != "" {
+		duration, err := time.ParseDuration(overrideDuration)
+		if err != nil {
+			log.Fatalf("Invalid TIER_OVERRIDE_DURATION %q: %v", overrideDuration, err)
+		}
+		calculatorConfig.OverrideDuration = duration
+	}
+	if gracePeriod := os.Getenv("TIER_DOWNGRADE_GRACE_PERIOD"); gracePeriod != "" {
+		duration, err := time.ParseDuration(gracePeriod)
+		if err != nil {
+			log.Fatalf("Invalid TIER_DOWNGRADE_GRACE_PERIOD %q: %v", gracePeriod, err)
+		}
+		calculatorConfig.DowngradeGracePeriod = duration
+	}
+
+	tierStorage := tiers.NewTierStorageWithTenants(mongoStorage.GetClient(), mongoStorage.GetDatabase(), mongoStorage.GetTenants())
+	calculator := tiers.NewTierCalculatorWithConfig(tierStorage, clients.NewLedgerClient(ledgerURL), calculatorConfig)
+
+	spendConfig := events.DefaultSpendConfig()
+	if actionTypes := os.Getenv("LOYALTY_ACTION_SPEND_TYPES"); actionTypes != "" {
+		spendConfig.ActionTypes = strings.Split(actionTypes, ",")
+	}
+	if spendPerPoint := os.Getenv("LOYALTY_ACTION_SPEND_PER_POINT"); spendPerPoint != "" {
+		rate, err := strconv.ParseFloat(spendPerPoint, 64)
+		if err != nil || rate <= 0 {
+			log.Fatalf("Invalid LOYALTY_ACTION_SPEND_PER_POINT %q", spendPerPoint)
+		}
+		spendConfig.SpendPerPoint = rate
+	}
+
+	timestampPolicy := events.DefaultTimestampPolicy()
+	if maxSkew := os.Getenv("EVENT_MAX_FUTURE_SKEW"); maxSkew != "" {
+		skew, err := time.ParseDuration(maxSkew)
+		if err != nil {
+			log.Fatalf("Invalid EVENT_MAX_FUTURE_SKEW %q: %v", maxSkew, err)
+		}
+		timestampPolicy.MaxFutureSkew = skew
+	}
+	if mode := os.Getenv("EVENT_FUTURE_TIMESTAMP_MODE"); mode != "" {
+		if mode != events.FutureTimestampClamp && mode != events.FutureTimestampReject {
+			log.Fatalf("Invalid EVENT_FUTURE_TIMESTAMP_MODE %q: must be clamp or reject", mode)
+		}
+		timestampPolicy.Mode = mode
+	}
+
+	options := eventOptions{
+		spend:           spendConfig,
+		timestamps:      timestampPolicy,
+		customerMetrics: os.Getenv("CONSUME_CUSTOMER_METRICS") == "true",
+	}
+
+	var recomputeInterval time.Duration
+	if interval := os.Getenv("RECOMPUTE_INTERVAL"); interval != "" {
+		recomputeInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			log.Fatalf("Invalid RECOMPUTE_INTERVAL %q: %v", interval, err)
+		}
+	}
+
+	// The tier recompute is throttled per customer. A throttled recompute
+	// outlives its message, so it has its own context.
+	recompute := throttle.NewThrottler(recomputeInterval, func(metrics tiers.CustomerMetrics) {
+		if err := calculator.ProcessCustomerMetrics(context.Background(), metrics); err != nil {
+			log.Printf("Error recomputing tier for customer %s: %v", metrics.CustomerID, err)
+			return
+		}
+		log.Printf("Updated tier for customer %s: $%.2f total, %d visits",
+			metrics.CustomerID, metrics.TotalSpent, metrics.TotalVisits)
+	})
 
 	brokerList := strings.Split(kafkaBrokers, ",")
 	reader := kafka.NewReader(kafka.ReaderConfig{
@@ -89,6 +191,7 @@ func main() {
 		select {
 		case <-ctx.Done():
 			log.Println("Context cancelled, stopping tier processor")
+			recompute.Flush()
 			if err := reader.Close(); err != nil {
 				log.Printf("Error closing reader: %v", err)
 			}
@@ -104,8 +207,8 @@ func main() {
 				continue
 			}
 
-			if shouldProcessMessage(string(message.Topic)) {
-				if err := processMessage(ctx, message, calculator, tierStorage); err != nil {
+			if shouldProcessMessage(string(message.Topic), options) {
+				if err := processMessage(ctx, message, calculator, recompute, tierStorage, options); err != nil {
 					log.Printf("Error processing message: %v", err)
 				}
 			}
@@ -117,10 +220,11 @@ func main() {
 	}
 }
 
-func shouldProcessMessage(topic string) bool {
+func shouldProcessMessage(topic string, options eventOptions) bool {
 	patterns := []string{
-		".pos.transaction",
+		events.TransactionTopic(options.customerMetrics),
 		".loyalty.action",
+		".customer.updated",
 	}
 	
 	for _, pattern := range patterns {
@@ -131,71 +235,115 @@ func shouldProcessMessage(topic string) bool {
 	return false
 }
 
-func processMessage(ctx context.Context, message kafka.Message, calculator *tiers.TierCalculator, storage *tiers.TierStorage) error {
+func processMessage(ctx context.Context, message kafka.Message, calculator *tiers.TierCalculator, recompute *throttle.Throttler[tiers.CustomerMetrics], storage *tiers.TierStorage, options eventOptions) error {
 	var event BaseEvent
 	if err := json.Unmarshal(message.Value, &event); err != nil {
 		return err
 	}
 
-	if event.EventType != "pos.transaction" {
+	var transaction POSTransaction
+	switch event.EventType {
+	case "pos.transaction":
+		if options.customerMetrics {
+			return nil
+		}
+		transactionData, err := json.Marshal(event.Payload)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(transactionData, &transaction); err != nil {
+			return err
+		}
+	case "customer.metrics.updated":
+		if !options.customerMetrics {
+			return nil
+		}
+		metrics, err := events.ParseCustomerMetrics(event.Payload)
+		if err != nil {
+			return err
+		}
+
+		transaction = POSTransaction{
+			TransactionID: metrics.TransactionID,
+			Amount:        metrics.Amount,
+			Timestamp:     metrics.Timestamp,
+			Refund:        metrics.Refund,
+		}
+	case "loyalty.action":
+		var ok bool
+		var err error
+		transaction, ok, err = loyaltyActionTransaction(event, options.spend)
+		if err != nil || !ok {
+			return err
+		}
+	case "customer.updated":
+		return processCustomerUpdate(ctx, event, calculator)
+	default:
 		return nil
 	}
 
-	var transaction POSTransaction
-	transactionData, err := json.Marshal(event.Payload)
+	timestamp, err := options.timestamps.Check(event.EventID, transaction.Timestamp)
 	if err != nil {
 		return err
 	}
+	transaction.Timestamp = timestamp
+
+	return processTransaction(ctx, event, transaction, recompute, storage)
+}
 
-	if err := json.Unmarshal(transactionData, &transaction); err != nil {
+func processCustomerUpdate(ctx context.Context, event BaseEvent, calculator *tiers.TierCalculator) error {
+	var update CustomerUpdate
+	updateData, err := json.Marshal(event.Payload)
+	if err != nil {
 		return err
 	}
 
-	existingTier, err := storage.GetCustomerTier(ctx, event.OrgID, event.CustomerID)
-	if err != nil {
-		log.Printf("Customer tier not found, will create new: %v", err)
-		existingTier = &tiers.CustomerTier{
-			OrgID:       event.OrgID,
-			CustomerID:  event.CustomerID,
-			CurrentTier: "Bronze",
-			TierSince:   time.Now(),
-		}
+	if err := json.Unmarshal(updateData, &update); err != nil {
+		return err
+	}
+
+	if update.Tier == "" {
+		return nil
 	}
 
-	now := time.Now()
-	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
-	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return calculator.ApplyTierOverride(ctx, event.OrgID, event.CustomerID, update.Tier)
+}
 
-	metrics := tiers.CustomerMetrics{
-		OrgID:             event.OrgID,
-		CustomerID:        event.CustomerID,
-		TotalSpent:        existingTier.TotalSpent + transaction.Amount,
-		TotalVisits:       existingTier.TotalVisits + 1,
-		SpentThisYear:     existingTier.SpentThisYear + transaction.Amount,
-		VisitsThisYear:    existingTier.VisitsThisYear + 1,
-		SpentThisMonth:    existingTier.SpentThisMonth + transaction.Amount,
-		VisitsThisMonth:   existingTier.VisitsThisMonth + 1,
-		LastTransaction:   transaction.Timestamp,
-		TransactionAmount: transaction.Amount,
+// loyaltyActionTransaction turns a loyalty action that stands in for spend
+// into a transaction, reporting false for actions that should be ignored
+func loyaltyActionTransaction(event BaseEvent, spendConfig events.SpendConfig) (POSTransaction, bool, error) {
+	action, err := events.ParseLoyaltyAction(event.Payload)
+	if err != nil {
+		return POSTransaction{}, false, err
 	}
 
-	if existingTier.LastTransaction.Before(yearStart) {
-		metrics.SpentThisYear = transaction.Amount
-		metrics.VisitsThisYear = 1
+	amount, ok := spendConfig.SpendEquivalent(action)
+	if !ok {
+		return POSTransaction{}, false, nil
 	}
 
-	if existingTier.LastTransaction.Before(monthStart) {
-		metrics.SpentThisMonth = transaction.Amount
-		metrics.VisitsThisMonth = 1
+	return POSTransaction{
+		TransactionID: action.Reference,
+		Amount:        amount,
+		Timestamp:     event.Timestamp,
+	}, true, nil
+}
+
+func processTransaction(ctx context.Context, event BaseEvent, transaction POSTransaction, recompute *throttle.Throttler[tiers.CustomerMetrics], storage *tiers.TierStorage) error {
+	amount := transaction.Amount
+	if transaction.Refund {
+		amount = -amount
 	}
 
-	if err := calculator.ProcessCustomerMetrics(ctx, metrics); err != nil {
+	// The metrics are counted as the transaction arrives; only the tier they
+	// earn is throttled, so a pending recompute is replaced by the newer totals
+	metrics, err := storage.AccumulateCustomerMetrics(ctx, event.OrgID, event.LocationID, event.CustomerID, amount, transaction.Timestamp)
+	if err != nil {
 		return err
 	}
 
-	log.Printf("Updated tier for customer %s: $%.2f total, %d visits",
-		event.CustomerID, metrics.TotalSpent, metrics.TotalVisits)
-
+	recompute.Submit(event.OrgID+":"+event.CustomerID, *metrics)
 	return nil
 }
 
@@ -209,6 +357,9 @@ func scheduledRecalculation(ctx context.Context, calculator *tiers.TierCalculato
 			return
 		case <-ticker.C:
 			log.Println("Starting scheduled tier recalculation...")
+			if err := calculator.RecalculateAllOrgs(ctx); err != nil {
+				log.Printf("Scheduled tier recalculation failed: %v", err)
+			}
 		}
 	}
 }
\ No newline at end of file
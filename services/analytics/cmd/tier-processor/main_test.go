@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/events"
+	"github.com/loyalty/analytics/internal/throttle"
+	"github.com/loyalty/analytics/internal/tiers"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func metricsResponse(spent float64) bson.D {
+	return bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "n", Value: 1},
+		{Key: "value", Value: bson.D{
+			{Key: "org_id", Value: "test_org"},
+			{Key: "customer_id", Value: "test_customer"},
+			{Key: "total_spent", Value: spent},
+			{Key: "total_visits", Value: int32(1)},
+		}},
+	}
+}
+
+func customerMetricsMessage(t *testing.T, payload map[string]interface{}) kafka.Message {
+	value, err := json.Marshal(BaseEvent{
+		EventID:    "evt_1_metrics",
+		EventType:  "customer.metrics.updated",
+		OrgID:      "test_org",
+		LocationID: "loc_1",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now().Add(-time.Hour),
+		Payload:    payload,
+	})
+	assert.NoError(t, err)
+	return kafka.Message{Topic: "test_org.customer.metrics.updated", Value: value}
+}
+
+// accumulated returns the spend the processor's findAndModify added
+func accumulated(mt *mtest.T) float64 {
+	for _, started := range mt.GetAllStartedEvents() {
+		if started.CommandName == "findAndModify" {
+			return started.Command.Lookup("update").Document().Lookup("$inc", "total_spent").Double()
+		}
+	}
+	return 0
+}
+
+// Test processMessage
+func TestProcessMessage_CustomerMetricsCountSpend(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	options := eventOptions{timestamps: events.DefaultTimestampPolicy(), customerMetrics: true}
+
+	for _, test := range []struct {
+		name    string
+		payload map[string]interface{}
+		spent   float64
+	}{
+		{"applied", map[string]interface{}{"transaction_id": "txn_1", "amount": 40.0, "accrual_status": "applied"}, 40},
+		{"failed accrual", map[string]interface{}{"transaction_id": "txn_1", "amount": 40.0, "accrual_status": "failed"}, 40},
+		{"refund", map[string]interface{}{"transaction_id": "txn_1", "amount": 15.0, "refund": true, "accrual_status": "applied"}, -15},
+	} {
+		mt.Run(test.name, func(mt *mtest.T) {
+			storage := tiers.NewTierStorage(mt.Client, mt.DB)
+			var recomputed []tiers.CustomerMetrics
+			recompute := throttle.NewThrottler(0, func(metrics tiers.CustomerMetrics) {
+				recomputed = append(recomputed, metrics)
+			})
+			// Two stale-period resets, then the increment
+			mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(), metricsResponse(60))
+
+			message := customerMetricsMessage(t, test.payload)
+			assert.True(t, shouldProcessMessage(message.Topic, options))
+			err := processMessage(context.Background(), message, nil, recompute, storage, options)
+
+			// Assertions - the spend counts whether or not the ledger applied it
+			assert.NoError(t, err)
+			assert.Equal(t, test.spent, accumulated(mt))
+			assert.Len(t, recomputed, 1)
+		})
+	}
+}
+
+func TestProcessMessage_RawPOSIgnoredWhenConsumingCustomerMetrics(t *testing.T) {
+	options := eventOptions{timestamps: events.DefaultTimestampPolicy(), customerMetrics: true}
+	value, err := json.Marshal(BaseEvent{
+		EventID:    "evt_1",
+		EventType:  "pos.transaction",
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Payload:    map[string]interface{}{"transaction_id": "txn_1", "amount": 40.0},
+	})
+	assert.NoError(t, err)
+	message := kafka.Message{Topic: "test_org.pos.transaction", Value: value}
+
+	// Reading both would count every transaction twice. No storage is given,
+	// so counting it would panic.
+	err = processMessage(context.Background(), message, nil, nil, nil, options)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, shouldProcessMessage(message.Topic, options))
+}
@@ -0,0 +1,536 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/loyalty/analytics/internal/profile"
+	"github.com/loyalty/analytics/internal/rewards"
+	"github.com/loyalty/analytics/internal/rfm"
+	"github.com/loyalty/analytics/internal/snapshots"
+	"github.com/loyalty/analytics/internal/storage"
+	"github.com/loyalty/analytics/internal/tiers"
+)
+
+type AnalyticsHandler struct {
+	rfm       rfm.RFMReaderInterface
+	tiers     tiers.TierUpgradeReaderInterface
+	snapshots snapshots.SnapshotReaderInterface
+	benefits  tiers.BenefitRedeemerInterface
+	profiles  profile.ProfileReaderInterface
+	rewards   rewards.RewardReaderInterface
+}
+
+func NewAnalyticsHandler(rfmReader rfm.RFMReaderInterface, tierReader tiers.TierUpgradeReaderInterface, snapshotReader snapshots.SnapshotReaderInterface, benefitRedeemer tiers.BenefitRedeemerInterface, profileReader profile.ProfileReaderInterface, rewardReader rewards.RewardReaderInterface) *AnalyticsHandler {
+	return &AnalyticsHandler{rfm: rfmReader, tiers: tierReader, snapshots: snapshotReader, benefits: benefitRedeemer, profiles: profileReader, rewards: rewardReader}
+}
+
+// RedeemBenefitRequest is the body of a tier benefit redemption
+type RedeemBenefitRequest struct {
+	OrgID     string `json:"org_id" binding:"required"`
+	Benefit   string `json:"benefit" binding:"required"`
+	Reference string `json:"reference"`
+}
+
+// TierConfigRequest is the body of an org's tier rules update
+type TierConfigRequest struct {
+	TierRules        []tiers.TierRule `json:"tier_rules" binding:"required"`
+	HighestTierFloor bool             `json:"highest_tier_floor"`
+}
+
+func (h *AnalyticsHandler) GetRFMScores(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "50")
+	offsetStr := c.DefaultQuery("offset", "0")
+	sortBy := c.DefaultQuery("sort", models.RFMSortComposite)
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset parameter"})
+		return
+	}
+
+	if sortBy != models.RFMSortComposite && sortBy != models.RFMSortMonetary {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be composite or monetary"})
+		return
+	}
+
+	scores, err := h.rfm.GetRFMScores(c.Request.Context(), orgID, limit, offset, sortBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if scores == nil {
+		scores = []models.RFMScore{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scores": scores,
+		"count":  len(scores),
+		"limit":  limit,
+		"offset": offset,
+		"sort":   sortBy,
+	})
+}
+
+// GetTopRFMScores lists an org's customers above a composite score
+// percentile, the top 10% by default
+func (h *AnalyticsHandler) GetTopRFMScores(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	percentile, err := strconv.ParseFloat(c.DefaultQuery("percentile", "90"), 64)
+	if err != nil || percentile < 0 || percentile >= 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "percentile must be a number from 0 to below 100"})
+		return
+	}
+
+	scores, err := h.rfm.GetRFMScoresAbovePercentile(c.Request.Context(), orgID, percentile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if scores == nil {
+		scores = []models.RFMScore{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scores":     scores,
+		"count":      len(scores),
+		"percentile": percentile,
+	})
+}
+
+// GetRFMScore returns a customer's RFM score
+func (h *AnalyticsHandler) GetRFMScore(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	score, err := h.rfm.GetRFMScore(c.Request.Context(), orgID, c.Param("id"))
+	if errors.Is(err, storage.ErrRFMScoreNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, score)
+}
+
+// GetRFMScoresBySegment lists an org's customers in one RFM segment
+func (h *AnalyticsHandler) GetRFMScoresBySegment(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	segment := c.Param("segment")
+	scores, err := h.rfm.GetRFMScoresBySegment(c.Request.Context(), orgID, segment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if scores == nil {
+		scores = []models.RFMScore{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"segment": segment,
+		"scores":  scores,
+		"count":   len(scores),
+	})
+}
+
+// GetQuintiles returns the quintile boundaries the org's RFM scores were
+// last calculated against
+func (h *AnalyticsHandler) GetQuintiles(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	quintiles, err := h.rfm.GetQuintiles(c.Request.Context(), orgID)
+	if errors.Is(err, storage.ErrQuintilesNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, quintiles)
+}
+
+// Page sizes of the quintile history
+const (
+	defaultQuintileHistoryLimit = 30
+	maxQuintileHistoryLimit     = 365
+)
+
+// GetQuintileHistory lists an org's past quintile calculations, newest
+// first, so analysts can see how the thresholds drift
+func (h *AnalyticsHandler) GetQuintileHistory(c *gin.Context) {
+	orgID := c.Param("id")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultQuintileHistoryLimit)))
+	if err != nil || limit <= 0 || limit > maxQuintileHistoryLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be from 1 to 365"})
+		return
+	}
+
+	history, err := h.rfm.GetQuintileHistory(c.Request.Context(), orgID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if history == nil {
+		history = []models.RFMQuintiles{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"org_id":  orgID,
+		"history": history,
+		"count":   len(history),
+	})
+}
+
+// GetTierUpgrades lists an org's tier changes, optionally only unnotified ones
+// or those in one direction
+func (h *AnalyticsHandler) GetTierUpgrades(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	direction := c.Query("direction")
+	if direction != "" && direction != tiers.TierDirectionUpgrade && direction != tiers.TierDirectionDowngrade {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be upgrade or downgrade"})
+		return
+	}
+
+	unnotifiedOnly, err := strconv.ParseBool(c.DefaultQuery("unnotified", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid unnotified parameter"})
+		return
+	}
+
+	upgrades, err := h.tiers.GetTierUpgrades(c.Request.Context(), orgID, unnotifiedOnly, direction)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if upgrades == nil {
+		upgrades = []tiers.TierUpgrade{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upgrades":   upgrades,
+		"count":      len(upgrades),
+		"direction":  direction,
+		"unnotified": unnotifiedOnly,
+	})
+}
+
+// Tier upgrade feed page sizes
+const (
+	defaultFeedLimit = 100
+	maxFeedLimit     = 1000
+)
+
+// GetTierUpgradeFeed pages through an org's tier changes oldest first, for
+// integrations that poll rather than consume Kafka. Each page returns the
+// cursor to pass as since for the next one; a page with no changes returns
+// the cursor it was given.
+func (h *AnalyticsHandler) GetTierUpgradeFeed(c *gin.Context) {
+	orgID := c.Param("id")
+
+	since, err := tiers.ParseUpgradeCursor(c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a cursor or an RFC 3339 timestamp"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultFeedLimit)))
+	if err != nil || limit <= 0 || limit > maxFeedLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be from 1 to 1000"})
+		return
+	}
+
+	// One extra change tells whether another page follows
+	upgrades, err := h.tiers.GetTierUpgradeFeed(c.Request.Context(), orgID, since, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasMore := len(upgrades) > limit
+	if hasMore {
+		upgrades = upgrades[:limit]
+	}
+
+	next := c.Query("since")
+	if len(upgrades) > 0 {
+		next = tiers.CursorAfter(upgrades[len(upgrades)-1]).String()
+	} else {
+		upgrades = []tiers.TierUpgrade{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"org_id":      orgID,
+		"upgrades":    upgrades,
+		"count":       len(upgrades),
+		"next_cursor": next,
+		"has_more":    hasMore,
+	})
+}
+
+// GetCustomerTier returns a customer's current tier, including the points
+// multiplier the stream processor applies to their purchases
+func (h *AnalyticsHandler) GetCustomerTier(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	tier, err := h.tiers.GetCustomerTier(c.Request.Context(), orgID, c.Param("id"))
+	if errors.Is(err, tiers.ErrCustomerTierNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tier)
+}
+
+// GetTierConfig returns the tier rules applied to an org. Orgs that have not
+// saved their own rules get the defaults the calculator falls back to.
+func (h *AnalyticsHandler) GetTierConfig(c *gin.Context) {
+	orgID := c.Param("org_id")
+
+	config, err := h.tiers.GetTierConfig(c.Request.Context(), orgID)
+	if errors.Is(err, tiers.ErrTierConfigNotFound) {
+		c.JSON(http.StatusOK, tiers.OrgTierConfig{OrgID: orgID, TierRules: tiers.GetDefaultTierRules()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+// PutTierConfig replaces an org's tier rules. The calculator applies them from
+// the next tier calculation; existing tiers change when customers are next
+// recalculated.
+func (h *AnalyticsHandler) PutTierConfig(c *gin.Context) {
+	orgID := c.Param("org_id")
+
+	var req TierConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tiers.ValidateTierRules(req.TierRules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	config := tiers.OrgTierConfig{OrgID: orgID, TierRules: req.TierRules, HighestTierFloor: req.HighestTierFloor, CreatedAt: now, UpdatedAt: now}
+	existing, err := h.tiers.GetTierConfig(c.Request.Context(), orgID)
+	if err != nil && !errors.Is(err, tiers.ErrTierConfigNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing != nil {
+		config.CreatedAt = existing.CreatedAt
+	}
+
+	if err := h.tiers.SaveTierConfig(c.Request.Context(), config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+// MarkUpgradeNotified records that a tier change has been announced, so a
+// notification worker polling unnotified changes does not send it again
+func (h *AnalyticsHandler) MarkUpgradeNotified(c *gin.Context) {
+	err := h.tiers.MarkUpgradeNotified(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, tiers.ErrInvalidUpgradeID):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, tiers.ErrTierUpgradeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "upgrade marked as notified"})
+}
+
+// RedeemBenefit records a customer's use of one of their tier's benefits,
+// refusing benefits outside the tier and uses beyond the benefit's limit
+func (h *AnalyticsHandler) RedeemBenefit(c *gin.Context) {
+	var req RedeemBenefitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	usage, err := h.benefits.RedeemBenefit(c.Request.Context(), req.OrgID, c.Param("id"), req.Benefit, req.Reference)
+	switch {
+	case errors.Is(err, tiers.ErrCustomerTierNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, tiers.ErrBenefitNotInTier):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, tiers.ErrBenefitLimitReached):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, usage)
+}
+
+// GetTrends charts one metric from an org's daily snapshots between from and
+// to (YYYY-MM-DD, inclusive), defaulting to the last 30 days
+func (h *AnalyticsHandler) GetTrends(c *gin.Context) {
+	orgID := c.Param("org")
+
+	metric := c.Query("metric")
+	if metric != snapshots.MetricTierDistribution && metric != snapshots.MetricSegmentDistribution && metric != snapshots.MetricLiability {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be tier_distribution, segment_distribution or liability"})
+		return
+	}
+
+	today := time.Now().UTC()
+	toDate := c.DefaultQuery("to", today.Format(snapshots.DateLayout))
+	fromDate := c.DefaultQuery("from", today.AddDate(0, 0, -29).Format(snapshots.DateLayout))
+	for _, date := range []string{fromDate, toDate} {
+		if _, err := time.Parse(snapshots.DateLayout, date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be dates in YYYY-MM-DD format"})
+			return
+		}
+	}
+
+	daily, err := h.snapshots.GetSnapshots(c.Request.Context(), orgID, fromDate, toDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	points, _ := snapshots.Trend(daily, metric)
+
+	c.JSON(http.StatusOK, gin.H{
+		"org_id": orgID,
+		"metric": metric,
+		"from":   fromDate,
+		"to":     toDate,
+		"points": points,
+	})
+}
+
+// GetRewardCounts counts an org's triggered rewards per location, or per
+// reward with group_by=reward
+func (h *AnalyticsHandler) GetRewardCounts(c *gin.Context) {
+	orgID := c.Param("org")
+	groupBy := c.DefaultQuery("group_by", rewards.GroupByLocation)
+
+	counts, err := h.rewards.CountTriggeredRewards(c.Request.Context(), orgID, groupBy)
+	if errors.Is(err, rewards.ErrUnknownGroupBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"org_id":   orgID,
+		"group_by": groupBy,
+		"counts":   counts,
+		"total":    total,
+	})
+}
+
+// GetCustomerProfile returns a customer's membership record, balance, tier and
+// RFM score together. Sources that are down are listed under unavailable
+// rather than failing the request.
+func (h *AnalyticsHandler) GetCustomerProfile(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	customerProfile, err := h.profiles.GetProfile(c.Request.Context(), orgID, c.Param("id"))
+	if errors.Is(err, profile.ErrCustomerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, customerProfile)
+}
+
+func (h *AnalyticsHandler) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "healthy",
+		"service": "analytics",
+	})
+}
@@ -0,0 +1,1414 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/loyalty/analytics/internal/clients"
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/loyalty/analytics/internal/profile"
+	"github.com/loyalty/analytics/internal/rewards"
+	"github.com/loyalty/analytics/internal/snapshots"
+	"github.com/loyalty/analytics/internal/storage"
+	"github.com/loyalty/analytics/internal/tiers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockRFMReader is a mock implementation of the RFM reader
+type MockRFMReader struct {
+	mock.Mock
+}
+
+func (m *MockRFMReader) GetRFMScores(ctx context.Context, orgID string, limit, offset int, sortBy string) ([]models.RFMScore, error) {
+	args := m.Called(ctx, orgID, limit, offset, sortBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMScore), args.Error(1)
+}
+
+func (m *MockRFMReader) GetRFMScoresAbovePercentile(ctx context.Context, orgID string, percentile float64) ([]models.RFMScore, error) {
+	args := m.Called(ctx, orgID, percentile)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMScore), args.Error(1)
+}
+
+func (m *MockRFMReader) GetRFMScore(ctx context.Context, orgID, customerID string) (*models.RFMScore, error) {
+	args := m.Called(ctx, orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMScore), args.Error(1)
+}
+
+func (m *MockRFMReader) GetRFMScoresBySegment(ctx context.Context, orgID, segment string) ([]models.RFMScore, error) {
+	args := m.Called(ctx, orgID, segment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMScore), args.Error(1)
+}
+
+func (m *MockRFMReader) GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMQuintiles), args.Error(1)
+}
+
+func (m *MockRFMReader) GetQuintileHistory(ctx context.Context, orgID string, limit int) ([]models.RFMQuintiles, error) {
+	args := m.Called(ctx, orgID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMQuintiles), args.Error(1)
+}
+
+// MockTierUpgradeReader is a mock implementation of the tier upgrade reader
+type MockTierUpgradeReader struct {
+	mock.Mock
+}
+
+func (m *MockTierUpgradeReader) GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool, direction string) ([]tiers.TierUpgrade, error) {
+	args := m.Called(ctx, orgID, unnotifiedOnly, direction)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]tiers.TierUpgrade), args.Error(1)
+}
+
+func (m *MockTierUpgradeReader) GetTierUpgradeFeed(ctx context.Context, orgID string, after tiers.UpgradeCursor, limit int) ([]tiers.TierUpgrade, error) {
+	args := m.Called(ctx, orgID, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]tiers.TierUpgrade), args.Error(1)
+}
+
+func (m *MockTierUpgradeReader) GetCustomerTier(ctx context.Context, orgID, customerID string) (*tiers.CustomerTier, error) {
+	args := m.Called(ctx, orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*tiers.CustomerTier), args.Error(1)
+}
+
+func (m *MockTierUpgradeReader) GetTierConfig(ctx context.Context, orgID string) (*tiers.OrgTierConfig, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*tiers.OrgTierConfig), args.Error(1)
+}
+
+func (m *MockTierUpgradeReader) SaveTierConfig(ctx context.Context, config tiers.OrgTierConfig) error {
+	args := m.Called(ctx, config)
+	return args.Error(0)
+}
+
+func (m *MockTierUpgradeReader) MarkUpgradeNotified(ctx context.Context, upgradeID string) error {
+	args := m.Called(ctx, upgradeID)
+	return args.Error(0)
+}
+
+// MockBenefitRedeemer is a mock implementation of the benefit redeemer
+type MockBenefitRedeemer struct {
+	mock.Mock
+}
+
+func (m *MockBenefitRedeemer) RedeemBenefit(ctx context.Context, orgID, customerID, benefit, reference string) (*tiers.BenefitUsage, error) {
+	args := m.Called(ctx, orgID, customerID, benefit, reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*tiers.BenefitUsage), args.Error(1)
+}
+
+// MockSnapshotReader is a mock implementation of the snapshot reader
+type MockSnapshotReader struct {
+	mock.Mock
+}
+
+func (m *MockSnapshotReader) GetSnapshots(ctx context.Context, orgID, fromDate, toDate string) ([]snapshots.DailySnapshot, error) {
+	args := m.Called(ctx, orgID, fromDate, toDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]snapshots.DailySnapshot), args.Error(1)
+}
+
+// MockProfileReader is a mock implementation of the profile reader
+type MockProfileReader struct {
+	mock.Mock
+}
+
+func (m *MockProfileReader) GetProfile(ctx context.Context, orgID, customerID string) (*profile.Profile, error) {
+	args := m.Called(ctx, orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*profile.Profile), args.Error(1)
+}
+
+// MockRewardReader is a mock implementation of the triggered reward reader
+type MockRewardReader struct {
+	mock.Mock
+}
+
+func (m *MockRewardReader) CountTriggeredRewards(ctx context.Context, orgID, groupBy string) (map[string]int, error) {
+	args := m.Called(ctx, orgID, groupBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+// Test setup helper
+func setupTest() (*gin.Engine, *MockRFMReader, *AnalyticsHandler) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockRFM := &MockRFMReader{}
+	handler := &AnalyticsHandler{rfm: mockRFM}
+
+	return router, mockRFM, handler
+}
+
+func setupSnapshotTest() (*gin.Engine, *MockSnapshotReader, *AnalyticsHandler) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockSnapshots := &MockSnapshotReader{}
+	handler := &AnalyticsHandler{snapshots: mockSnapshots}
+
+	router.GET("/analytics/:org/trends", handler.GetTrends)
+
+	return router, mockSnapshots, handler
+}
+
+func setupRewardTest() (*gin.Engine, *MockRewardReader, *AnalyticsHandler) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockRewards := &MockRewardReader{}
+	handler := &AnalyticsHandler{rewards: mockRewards}
+
+	router.GET("/analytics/:org/rewards", handler.GetRewardCounts)
+
+	return router, mockRewards, handler
+}
+
+// Test GetRFMScores
+func TestGetRFMScores_Success(t *testing.T) {
+	router, mockRFM, handler := setupTest()
+
+	// Setup route
+	router.GET("/rfm", handler.GetRFMScores)
+
+	// Mock storage response - second page of a monetary-sorted set
+	scores := []models.RFMScore{
+		{OrgID: "test_org", CustomerID: "cust_3", MonetaryScore: 4, TotalSpent: 300.0},
+		{OrgID: "test_org", CustomerID: "cust_4", MonetaryScore: 3, TotalSpent: 150.0},
+	}
+
+	mockRFM.On("GetRFMScores", mock.Anything, "test_org", 2, 2, models.RFMSortMonetary).Return(scores, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm?org_id=test_org&limit=2&offset=2&sort=monetary", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Scores []models.RFMScore `json:"scores"`
+		Count  int               `json:"count"`
+		Limit  int               `json:"limit"`
+		Offset int               `json:"offset"`
+		Sort   string            `json:"sort"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, 2, response.Limit)
+	assert.Equal(t, 2, response.Offset)
+	assert.Equal(t, "monetary", response.Sort)
+	assert.Equal(t, "cust_3", response.Scores[0].CustomerID)
+	assert.Equal(t, "cust_4", response.Scores[1].CustomerID)
+
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetRFMScores_Defaults(t *testing.T) {
+	router, mockRFM, handler := setupTest()
+
+	// Setup route
+	router.GET("/rfm", handler.GetRFMScores)
+
+	mockRFM.On("GetRFMScores", mock.Anything, "test_org", 50, 0, models.RFMSortComposite).Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), response["count"])
+	assert.Equal(t, []interface{}{}, response["scores"])
+
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetRFMScores_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{"missing org", "/rfm", "org_id is required"},
+		{"invalid limit", "/rfm?org_id=test_org&limit=abc", "invalid limit parameter"},
+		{"negative offset", "/rfm?org_id=test_org&offset=-1", "invalid offset parameter"},
+		{"unknown sort", "/rfm?org_id=test_org&sort=alphabetical", "sort must be composite or monetary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockRFM, handler := setupTest()
+			router.GET("/rfm", handler.GetRFMScores)
+
+			req, _ := http.NewRequest("GET", tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.error, response["error"])
+
+			mockRFM.AssertNotCalled(t, "GetRFMScores")
+		})
+	}
+}
+
+func TestGetRFMScores_StorageError(t *testing.T) {
+	router, mockRFM, handler := setupTest()
+
+	// Setup route
+	router.GET("/rfm", handler.GetRFMScores)
+
+	mockRFM.On("GetRFMScores", mock.Anything, "test_org", 50, 0, models.RFMSortComposite).Return(nil, assert.AnError)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	mockRFM.AssertExpectations(t)
+}
+
+// Test GetTopRFMScores
+func TestGetTopRFMScores_DefaultsToTopDecile(t *testing.T) {
+	router, mockRFM, handler := setupTest()
+
+	// Setup route
+	router.GET("/rfm/top", handler.GetTopRFMScores)
+
+	scores := []models.RFMScore{
+		{OrgID: "test_org", CustomerID: "cust_1", RecencyScore: 5, FrequencyScore: 5, MonetaryScore: 5},
+	}
+
+	mockRFM.On("GetRFMScoresAbovePercentile", mock.Anything, "test_org", 90.0).Return(scores, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/top?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Scores     []models.RFMScore `json:"scores"`
+		Count      int               `json:"count"`
+		Percentile float64           `json:"percentile"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, response.Count)
+	assert.Equal(t, 90.0, response.Percentile)
+	assert.Equal(t, "cust_1", response.Scores[0].CustomerID)
+
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetTopRFMScores_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{"missing org", "/rfm/top", "org_id is required"},
+		{"non-numeric percentile", "/rfm/top?org_id=test_org&percentile=top", "percentile must be a number from 0 to below 100"},
+		{"percentile of 100", "/rfm/top?org_id=test_org&percentile=100", "percentile must be a number from 0 to below 100"},
+		{"negative percentile", "/rfm/top?org_id=test_org&percentile=-5", "percentile must be a number from 0 to below 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockRFM, handler := setupTest()
+			router.GET("/rfm/top", handler.GetTopRFMScores)
+
+			req, _ := http.NewRequest("GET", tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.error, response["error"])
+
+			mockRFM.AssertNotCalled(t, "GetRFMScoresAbovePercentile")
+		})
+	}
+}
+
+// Test RFM lookups
+func setupRFMLookupTest() (*gin.Engine, *MockRFMReader) {
+	router, mockRFM, handler := setupTest()
+
+	// Setup routes - registered together, as in the API, so the static
+	// paths and the history route are checked against the ID wildcard
+	router.GET("/rfm/top", handler.GetTopRFMScores)
+	router.GET("/rfm/quintiles", handler.GetQuintiles)
+	router.GET("/rfm/segments/:segment", handler.GetRFMScoresBySegment)
+	router.GET("/rfm/:id", handler.GetRFMScore)
+	router.GET("/rfm/:id/quintiles/history", handler.GetQuintileHistory)
+
+	return router, mockRFM
+}
+
+func TestGetRFMScore_Success(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	mockRFM.On("GetRFMScore", mock.Anything, "test_org", "cust_1").Return(&models.RFMScore{
+		OrgID: "test_org", CustomerID: "cust_1", RFMSegment: "Champions", RecencyScore: 5,
+	}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/cust_1?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.RFMScore
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "cust_1", response.CustomerID)
+	assert.Equal(t, "Champions", response.RFMSegment)
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetRFMScore_NotFound(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	mockRFM.On("GetRFMScore", mock.Anything, "test_org", "cust_new").Return(nil, storage.ErrRFMScoreNotFound)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/cust_new?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetRFMScore_StorageError(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	mockRFM.On("GetRFMScore", mock.Anything, "test_org", "cust_1").Return(nil, assert.AnError)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/cust_1?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetRFMScoresBySegment_Success(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	scores := []models.RFMScore{
+		{OrgID: "test_org", CustomerID: "cust_1", RFMSegment: "At Risk"},
+		{OrgID: "test_org", CustomerID: "cust_2", RFMSegment: "At Risk"},
+	}
+	mockRFM.On("GetRFMScoresBySegment", mock.Anything, "test_org", "At Risk").Return(scores, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/segments/At%20Risk?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Segment string            `json:"segment"`
+		Scores  []models.RFMScore `json:"scores"`
+		Count   int               `json:"count"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "At Risk", response.Segment)
+	assert.Equal(t, 2, response.Count)
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetRFMScoresBySegment_EmptySegment(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	mockRFM.On("GetRFMScoresBySegment", mock.Anything, "test_org", "Lost").Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/segments/Lost?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"scores":[]`)
+}
+
+func TestGetQuintiles_Success(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	mockRFM.On("GetQuintiles", mock.Anything, "test_org").Return(&models.RFMQuintiles{
+		OrgID:             "test_org",
+		RecencyQuintiles:  []int{7, 14, 30, 60},
+		MonetaryQuintiles: []float64{20, 50, 100, 250},
+	}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/quintiles?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.RFMQuintiles
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{7, 14, 30, 60}, response.RecencyQuintiles)
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetQuintiles_NotFound(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	mockRFM.On("GetQuintiles", mock.Anything, "test_org").Return(nil, storage.ErrQuintilesNotFound)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/quintiles?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetQuintileHistory_NewestFirst(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	latest := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	mockRFM.On("GetQuintileHistory", mock.Anything, "test_org", 2).Return([]models.RFMQuintiles{
+		{OrgID: "test_org", MonetaryQuintiles: []float64{25, 60, 120, 300}, CalculatedAt: latest},
+		{OrgID: "test_org", MonetaryQuintiles: []float64{20, 50, 100, 250}, CalculatedAt: latest.AddDate(0, 0, -1)},
+	}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/test_org/quintiles/history?limit=2", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		OrgID   string                `json:"org_id"`
+		History []models.RFMQuintiles `json:"history"`
+		Count   int                   `json:"count"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", response.OrgID)
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, latest, response.History[0].CalculatedAt)
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetQuintileHistory_Empty(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	mockRFM.On("GetQuintileHistory", mock.Anything, "test_org", 30).Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/rfm/test_org/quintiles/history", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"history":[]`)
+	mockRFM.AssertExpectations(t)
+}
+
+func TestGetQuintileHistory_InvalidLimit(t *testing.T) {
+	router, mockRFM := setupRFMLookupTest()
+
+	for _, limit := range []string{"0", "366", "abc"} {
+		// Create request
+		req, _ := http.NewRequest("GET", "/rfm/test_org/quintiles/history?limit="+limit, nil)
+
+		// Record response
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+	}
+	mockRFM.AssertNotCalled(t, "GetQuintileHistory", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRFMLookups_MissingOrgID(t *testing.T) {
+	router, _ := setupRFMLookupTest()
+
+	for _, path := range []string{"/rfm/cust_1", "/rfm/segments/Champions", "/rfm/quintiles"} {
+		// Create request
+		req, _ := http.NewRequest("GET", path, nil)
+
+		// Record response
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), "org_id is required", path)
+	}
+}
+
+// Test GetTierUpgrades
+func setupTierTest() (*gin.Engine, *MockTierUpgradeReader, *AnalyticsHandler) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockTiers := &MockTierUpgradeReader{}
+	handler := &AnalyticsHandler{tiers: mockTiers}
+	router.GET("/tier-upgrades", handler.GetTierUpgrades)
+	router.GET("/tiers/:id", handler.GetCustomerTier)
+	router.GET("/tiers/:id/upgrades/feed", handler.GetTierUpgradeFeed)
+	router.GET("/tiers/config/:org_id", handler.GetTierConfig)
+	router.PUT("/tiers/config/:org_id", handler.PutTierConfig)
+	router.GET("/tiers/upgrades", handler.GetTierUpgrades)
+	router.POST("/tiers/upgrades/:id/notified", handler.MarkUpgradeNotified)
+
+	return router, mockTiers, handler
+}
+
+func TestGetTierUpgrades_DowngradesOnly(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	// Mock storage response - the downgrades out of a mixed set of changes
+	changes := []tiers.TierUpgrade{
+		{CustomerID: "cust_1", FromTier: "Bronze", ToTier: "Silver", Direction: tiers.TierDirectionUpgrade},
+		{CustomerID: "cust_2", FromTier: "Gold", ToTier: "Silver", Direction: tiers.TierDirectionDowngrade},
+		{CustomerID: "cust_3", FromTier: "Silver", ToTier: "Gold", Direction: tiers.TierDirectionUpgrade},
+		{CustomerID: "cust_4", FromTier: "Silver", ToTier: "Bronze", Direction: tiers.TierDirectionDowngrade},
+	}
+	var downgrades []tiers.TierUpgrade
+	for _, change := range changes {
+		if change.Direction == tiers.TierDirectionDowngrade {
+			downgrades = append(downgrades, change)
+		}
+	}
+
+	mockTiers.On("GetTierUpgrades", mock.Anything, "test_org", false, tiers.TierDirectionDowngrade).Return(downgrades, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tier-upgrades?org_id=test_org&direction=downgrade", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Upgrades  []tiers.TierUpgrade `json:"upgrades"`
+		Count     int                 `json:"count"`
+		Direction string              `json:"direction"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, "downgrade", response.Direction)
+	for _, upgrade := range response.Upgrades {
+		assert.Equal(t, tiers.TierDirectionDowngrade, upgrade.Direction)
+	}
+	assert.Equal(t, "cust_2", response.Upgrades[0].CustomerID)
+	assert.Equal(t, "cust_4", response.Upgrades[1].CustomerID)
+
+	mockTiers.AssertExpectations(t)
+}
+
+func TestGetTierUpgrades_UnnotifiedWithoutDirection(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	mockTiers.On("GetTierUpgrades", mock.Anything, "test_org", true, "").Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tier-upgrades?org_id=test_org&unnotified=true", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{}, response["upgrades"])
+
+	mockTiers.AssertExpectations(t)
+}
+
+func TestGetTierUpgrades_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{"missing org_id", "/tier-upgrades?direction=upgrade", "org_id is required"},
+		{"unknown direction", "/tier-upgrades?org_id=test_org&direction=sideways", "direction must be upgrade or downgrade"},
+		{"invalid unnotified", "/tier-upgrades?org_id=test_org&unnotified=maybe", "invalid unnotified parameter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockTiers, _ := setupTierTest()
+
+			req, _ := http.NewRequest("GET", tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.error, response["error"])
+
+			mockTiers.AssertNotCalled(t, "GetTierUpgrades", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Test GetTierUpgradeFeed
+func TestGetTierUpgradeFeed_PollsFromCursor(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	since := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	first := tiers.TierUpgrade{ID: primitive.NewObjectID(), CustomerID: "cust_1", ToTier: "Silver", UpgradedAt: since.Add(time.Minute)}
+	second := tiers.TierUpgrade{ID: primitive.NewObjectID(), CustomerID: "cust_2", ToTier: "Gold", UpgradedAt: since.Add(2 * time.Minute)}
+	third := tiers.TierUpgrade{ID: primitive.NewObjectID(), CustomerID: "cust_3", ToTier: "Gold", UpgradedAt: since.Add(3 * time.Minute)}
+
+	// Mock storage responses - a page of two with one more to come, then the rest
+	mockTiers.On("GetTierUpgradeFeed", mock.Anything, "test_org", tiers.UpgradeCursor{UpgradedAt: since}, 3).
+		Return([]tiers.TierUpgrade{first, second, third}, nil)
+	mockTiers.On("GetTierUpgradeFeed", mock.Anything, "test_org", tiers.CursorAfter(second), 3).
+		Return([]tiers.TierUpgrade{third}, nil)
+
+	type feedResponse struct {
+		Upgrades   []tiers.TierUpgrade `json:"upgrades"`
+		NextCursor string              `json:"next_cursor"`
+		HasMore    bool                `json:"has_more"`
+	}
+	poll := func(query string) feedResponse {
+		req, _ := http.NewRequest("GET", "/tiers/test_org/upgrades/feed?limit=2&"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response feedResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	// Assertions
+	page := poll("since=" + since.Format(time.RFC3339))
+	assert.Len(t, page.Upgrades, 2)
+	assert.Equal(t, "cust_1", page.Upgrades[0].CustomerID)
+	assert.Equal(t, "cust_2", page.Upgrades[1].CustomerID)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, tiers.CursorAfter(second).String(), page.NextCursor)
+
+	page = poll("since=" + page.NextCursor)
+	assert.Len(t, page.Upgrades, 1)
+	assert.Equal(t, "cust_3", page.Upgrades[0].CustomerID)
+	assert.False(t, page.HasMore)
+
+	mockTiers.AssertExpectations(t)
+}
+
+func TestGetTierUpgradeFeed_EmptyPageKeepsCursor(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	cursor := tiers.UpgradeCursor{UpgradedAt: time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC), ID: primitive.NewObjectID()}
+	mockTiers.On("GetTierUpgradeFeed", mock.Anything, "test_org", cursor, 101).Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tiers/test_org/upgrades/feed?since="+cursor.String(), nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{}, response["upgrades"])
+	assert.Equal(t, cursor.String(), response["next_cursor"])
+	assert.Equal(t, false, response["has_more"])
+
+	mockTiers.AssertExpectations(t)
+}
+
+func TestGetTierUpgradeFeed_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{"invalid since", "?since=yesterday", "since must be a cursor or an RFC 3339 timestamp"},
+		{"zero limit", "?limit=0", "limit must be from 1 to 1000"},
+		{"limit too large", "?limit=1001", "limit must be from 1 to 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockTiers, _ := setupTierTest()
+
+			req, _ := http.NewRequest("GET", "/tiers/test_org/upgrades/feed"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.error, response["error"])
+
+			mockTiers.AssertNotCalled(t, "GetTierUpgradeFeed", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Test Health
+func TestHealth_Success(t *testing.T) {
+	router, _, handler := setupTest()
+
+	// Setup route
+	router.GET("/health", handler.Health)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/health", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "healthy", response["status"])
+	assert.Equal(t, "analytics", response["service"])
+}
+
+// Test GetTrends
+func TestGetTrends_Liability(t *testing.T) {
+	router, mockSnapshots, _ := setupSnapshotTest()
+
+	daily := []snapshots.DailySnapshot{
+		{OrgID: "test_org", Date: "2026-10-12", PointsLiability: 1000, StampsLiability: 20},
+		{OrgID: "test_org", Date: "2026-10-13", PointsLiability: 1250, StampsLiability: 18},
+	}
+	mockSnapshots.On("GetSnapshots", mock.Anything, "test_org", "2026-10-12", "2026-10-13").Return(daily, nil)
+
+	// Test
+	req, _ := http.NewRequest("GET", "/analytics/test_org/trends?metric=liability&from=2026-10-12&to=2026-10-13", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		OrgID  string `json:"org_id"`
+		Metric string `json:"metric"`
+		Points []struct {
+			Date  string              `json:"date"`
+			Value snapshots.Liability `json:"value"`
+		} `json:"points"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "test_org", response.OrgID)
+	assert.Equal(t, snapshots.MetricLiability, response.Metric)
+	assert.Len(t, response.Points, 2)
+	assert.Equal(t, "2026-10-13", response.Points[1].Date)
+	assert.Equal(t, uint64(1250), response.Points[1].Value.Points)
+	assert.Equal(t, uint64(18), response.Points[1].Value.Stamps)
+
+	mockSnapshots.AssertExpectations(t)
+}
+
+func TestGetTrends_InvalidParams(t *testing.T) {
+	router, mockSnapshots, _ := setupSnapshotTest()
+
+	for _, query := range []string{
+		"",
+		"metric=revenue",
+		"metric=tier_distribution&from=14-10-2026",
+		"metric=tier_distribution&to=yesterday",
+	} {
+		req, _ := http.NewRequest("GET", "/analytics/test_org/trends?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	mockSnapshots.AssertNotCalled(t, "GetSnapshots", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Test GetRewardCounts
+func TestGetRewardCounts_ByLocation(t *testing.T) {
+	router, mockRewards, _ := setupRewardTest()
+
+	mockRewards.On("CountTriggeredRewards", mock.Anything, "test_org", rewards.GroupByLocation).Return(map[string]int{"loc_1": 5, "loc_2": 3}, nil)
+
+	// Test
+	req, _ := http.NewRequest("GET", "/analytics/test_org/rewards?group_by=location", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		OrgID   string         `json:"org_id"`
+		GroupBy string         `json:"group_by"`
+		Counts  map[string]int `json:"counts"`
+		Total   int            `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "test_org", response.OrgID)
+	assert.Equal(t, rewards.GroupByLocation, response.GroupBy)
+	assert.Equal(t, map[string]int{"loc_1": 5, "loc_2": 3}, response.Counts)
+	assert.Equal(t, 8, response.Total)
+
+	mockRewards.AssertExpectations(t)
+}
+
+func TestGetRewardCounts_UnknownGroupBy(t *testing.T) {
+	router, mockRewards, _ := setupRewardTest()
+
+	mockRewards.On("CountTriggeredRewards", mock.Anything, "test_org", "customer").Return(nil, rewards.ErrUnknownGroupBy)
+
+	// Test
+	req, _ := http.NewRequest("GET", "/analytics/test_org/rewards?group_by=customer", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRewards.AssertExpectations(t)
+}
+
+func TestGetRewardCounts_StorageError(t *testing.T) {
+	router, mockRewards, _ := setupRewardTest()
+
+	mockRewards.On("CountTriggeredRewards", mock.Anything, "test_org", rewards.GroupByLocation).Return(nil, errors.New("database error"))
+
+	// Test
+	req, _ := http.NewRequest("GET", "/analytics/test_org/rewards", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRewards.AssertExpectations(t)
+}
+
+// Test GetCustomerTier
+func TestGetCustomerTier_Success(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	mockTiers.On("GetCustomerTier", mock.Anything, "test_org", "cust_1").Return(&tiers.CustomerTier{
+		OrgID: "test_org", CustomerID: "cust_1", CurrentTier: "Gold", PointsMultiplier: 1.5,
+	}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tiers/cust_1?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response tiers.CustomerTier
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "Gold", response.CurrentTier)
+	assert.Equal(t, 1.5, response.PointsMultiplier)
+	mockTiers.AssertExpectations(t)
+}
+
+func TestGetCustomerTier_NotFound(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	mockTiers.On("GetCustomerTier", mock.Anything, "test_org", "cust_new").Return(nil, tiers.ErrCustomerTierNotFound)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tiers/cust_new?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockTiers.AssertExpectations(t)
+}
+
+// Test GetTierConfig
+func TestGetTierConfig_Success(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	rules := []tiers.TierRule{{Name: "Member", Level: 1}, {Name: "VIP", Level: 2, MinSpentYear: 500}}
+	mockTiers.On("GetTierConfig", mock.Anything, "test_org").Return(&tiers.OrgTierConfig{OrgID: "test_org", TierRules: rules}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tiers/config/test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response tiers.OrgTierConfig
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.TierRules, 2)
+	assert.Equal(t, "VIP", response.TierRules[1].Name)
+	mockTiers.AssertExpectations(t)
+}
+
+func TestGetTierConfig_DefaultsWhenNotSaved(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	mockTiers.On("GetTierConfig", mock.Anything, "new_org").Return(nil, tiers.ErrTierConfigNotFound)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tiers/config/new_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response tiers.OrgTierConfig
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "new_org", response.OrgID)
+	assert.Len(t, response.TierRules, len(tiers.GetDefaultTierRules()))
+}
+
+func TestGetTierConfig_StorageError(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	mockTiers.On("GetTierConfig", mock.Anything, "test_org").Return(nil, assert.AnError)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/tiers/config/test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// Test PutTierConfig
+func TestPutTierConfig_Success(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockTiers.On("GetTierConfig", mock.Anything, "test_org").Return(&tiers.OrgTierConfig{OrgID: "test_org", CreatedAt: createdAt}, nil)
+	mockTiers.On("SaveTierConfig", mock.Anything, mock.MatchedBy(func(config tiers.OrgTierConfig) bool {
+		return config.OrgID == "test_org" && len(config.TierRules) == 2 && config.CreatedAt.Equal(createdAt)
+	})).Return(nil)
+
+	// Create request
+	body := `{"tier_rules":[{"name":"Member","level":1},{"name":"VIP","level":2,"min_spent_year":500}]}`
+	req, _ := http.NewRequest("PUT", "/tiers/config/test_org", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockTiers.AssertExpectations(t)
+}
+
+func TestPutTierConfig_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"duplicate level", `{"tier_rules":[{"name":"Member","level":1},{"name":"VIP","level":1}]}`},
+		{"decreasing threshold", `{"tier_rules":[{"name":"Member","level":1,"min_visits_year":10},{"name":"VIP","level":2,"min_visits_year":5}]}`},
+		{"no rules", `{"tier_rules":[]}`},
+		{"missing rules", `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockTiers, _ := setupTierTest()
+
+			req, _ := http.NewRequest("PUT", "/tiers/config/test_org", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			mockTiers.AssertNotCalled(t, "SaveTierConfig", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestPutTierConfig_FirstConfig(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	mockTiers.On("GetTierConfig", mock.Anything, "new_org").Return(nil, tiers.ErrTierConfigNotFound)
+	mockTiers.On("SaveTierConfig", mock.Anything, mock.MatchedBy(func(config tiers.OrgTierConfig) bool {
+		return config.OrgID == "new_org" && !config.CreatedAt.IsZero()
+	})).Return(nil)
+
+	// Create request
+	req, _ := http.NewRequest("PUT", "/tiers/config/new_org", strings.NewReader(`{"tier_rules":[{"name":"Member","level":1}]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockTiers.AssertExpectations(t)
+}
+
+// Test unnotified upgrade polling
+func TestTierUpgrades_NotificationWorkflow(t *testing.T) {
+	router, mockTiers, _ := setupTierTest()
+
+	upgradeID := primitive.NewObjectID()
+	mockTiers.On("GetTierUpgrades", mock.Anything, "test_org", true, "").Return([]tiers.TierUpgrade{
+		{ID: upgradeID, OrgID: "test_org", CustomerID: "cust_1", FromTier: "Silver", ToTier: "Gold"},
+	}, nil)
+	mockTiers.On("MarkUpgradeNotified", mock.Anything, upgradeID.Hex()).Return(nil)
+
+	// Fetch unnotified upgrades
+	req, _ := http.NewRequest("GET", "/tiers/upgrades?org_id=test_org&unnotified=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Upgrades []tiers.TierUpgrade `json:"upgrades"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Upgrades, 1)
+
+	// Mark the upgrade notified
+	req, _ = http.NewRequest("POST", "/tiers/upgrades/"+response.Upgrades[0].ID.Hex()+"/notified", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockTiers.AssertExpectations(t)
+}
+
+func TestMarkUpgradeNotified_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"unknown upgrade", tiers.ErrTierUpgradeNotFound, http.StatusNotFound},
+		{"invalid ID", tiers.ErrInvalidUpgradeID, http.StatusBadRequest},
+		{"storage error", assert.AnError, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockTiers, _ := setupTierTest()
+			mockTiers.On("MarkUpgradeNotified", mock.Anything, "upgrade_1").Return(tt.err)
+
+			req, _ := http.NewRequest("POST", "/tiers/upgrades/upgrade_1/notified", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+// Test RedeemBenefit
+func setupBenefitTest() (*gin.Engine, *MockBenefitRedeemer) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockBenefits := &MockBenefitRedeemer{}
+	handler := &AnalyticsHandler{benefits: mockBenefits}
+	router.POST("/tiers/:id/benefits", handler.RedeemBenefit)
+
+	return router, mockBenefits
+}
+
+func TestRedeemBenefit_Success(t *testing.T) {
+	router, mockBenefits := setupBenefitTest()
+
+	usage := &tiers.BenefitUsage{OrgID: "test_org", CustomerID: "cust_1", Benefit: "Monthly offers", Tier: "Silver", Period: "2026-10", Use: 1}
+	mockBenefits.On("RedeemBenefit", mock.Anything, "test_org", "cust_1", "Monthly offers", "pos_1").Return(usage, nil)
+
+	// Create request
+	body, _ := json.Marshal(RedeemBenefitRequest{OrgID: "test_org", Benefit: "Monthly offers", Reference: "pos_1"})
+	req, _ := http.NewRequest("POST", "/tiers/cust_1/benefits", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response tiers.BenefitUsage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-10", response.Period)
+	assert.Equal(t, 1, response.Use)
+	mockBenefits.AssertExpectations(t)
+}
+
+func TestRedeemBenefit_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"limit reached", tiers.ErrBenefitLimitReached, http.StatusConflict},
+		{"not in tier", tiers.ErrBenefitNotInTier, http.StatusForbidden},
+		{"no tier", tiers.ErrCustomerTierNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockBenefits := setupBenefitTest()
+			mockBenefits.On("RedeemBenefit", mock.Anything, "test_org", "cust_1", "Monthly offers", "").Return(nil, tt.err)
+
+			// Create request
+			body, _ := json.Marshal(RedeemBenefitRequest{OrgID: "test_org", Benefit: "Monthly offers"})
+			req, _ := http.NewRequest("POST", "/tiers/cust_1/benefits", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Record response
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, tt.expected, w.Code)
+			mockBenefits.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRedeemBenefit_MissingBenefit(t *testing.T) {
+	router, mockBenefits := setupBenefitTest()
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/tiers/cust_1/benefits", bytes.NewBufferString(`{"org_id":"test_org"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockBenefits.AssertNotCalled(t, "RedeemBenefit", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Test GetCustomerProfile
+func setupProfileTest() (*gin.Engine, *MockProfileReader) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	mockProfiles := &MockProfileReader{}
+	handler := &AnalyticsHandler{profiles: mockProfiles}
+
+	router.GET("/customers/:id/profile", handler.GetCustomerProfile)
+
+	return router, mockProfiles
+}
+
+func TestGetCustomerProfile_SourceDown(t *testing.T) {
+	router, mockProfiles := setupProfileTest()
+
+	mockProfiles.On("GetProfile", mock.Anything, "test_org", "cust_1").Return(&profile.Profile{
+		OrgID:       "test_org",
+		CustomerID:  "cust_1",
+		Customer:    &clients.Customer{CustomerID: "cust_1", OrgID: "test_org"},
+		Tier:        &tiers.CustomerTier{CurrentTier: "Gold"},
+		Unavailable: map[string]string{profile.SourceLedger: "ledger service returned status 503"},
+	}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/cust_1/profile?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions - a partial profile is still a success
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Nil(t, response["balance"])
+	assert.Equal(t, "Gold", response["tier"].(map[string]interface{})["current_tier"])
+	assert.Contains(t, response["unavailable"], "ledger")
+	mockProfiles.AssertExpectations(t)
+}
+
+func TestGetCustomerProfile_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		err            error
+		expectedStatus int
+	}{
+		{"missing org", "", nil, http.StatusBadRequest},
+		{"unknown customer", "?org_id=test_org", profile.ErrCustomerNotFound, http.StatusNotFound},
+		{"storage error", "?org_id=test_org", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockProfiles := setupProfileTest()
+			if tt.err != nil {
+				mockProfiles.On("GetProfile", mock.Anything, "test_org", "cust_1").Return(nil, tt.err)
+			}
+
+			// Create request
+			req, _ := http.NewRequest("GET", "/customers/cust_1/profile"+tt.query, nil)
+
+			// Record response
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockProfiles.AssertExpectations(t)
+		})
+	}
+}
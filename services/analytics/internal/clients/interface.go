@@ -0,0 +1,26 @@
+package clients
+
+import "errors"
+
+// ErrNotFound is returned when a downstream service has no record for the customer
+var ErrNotFound = errors.New("not found")
+
+// LedgerClientInterface defines the interface for ledger client operations
+type LedgerClientInterface interface {
+	CreatePointsTransfer(orgID, customerID string, points int, reference string) (*TransferResponse, error)
+}
+
+// LiabilityClientInterface reads an org's outstanding points and stamps
+type LiabilityClientInterface interface {
+	GetLiability(orgID string) (*Liability, error)
+}
+
+// BalanceClientInterface reads a customer's points and stamps balances
+type BalanceClientInterface interface {
+	GetBalance(orgID, customerID string) (*Balance, error)
+}
+
+// MembershipClientInterface reads customers from the membership service
+type MembershipClientInterface interface {
+	GetCustomer(customerID string) (*Customer, error)
+}
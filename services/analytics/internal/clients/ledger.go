@@ -0,0 +1,138 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/loyalty/ledger/pkg/codes"
+)
+
+// TransferCodePoints is the ledger's code for points transfers
+const TransferCodePoints = codes.Points
+
+type LedgerClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type CreateTransferRequest struct {
+	OrgID           string `json:"org_id"`
+	CustomerID      string `json:"customer_id"`
+	TransactionType string `json:"transaction_type"`
+	Amount          uint64 `json:"amount"`
+	Code            uint16 `json:"code"`
+	Reference       string `json:"reference"`
+}
+
+type TransferResponse struct {
+	TransferID string `json:"transfer_id"`
+	Status     string `json:"status"`
+}
+
+// Liability is what an org owes its customers in points and stamps
+type Liability struct {
+	OrgID             string `json:"org_id"`
+	PointsOutstanding uint64 `json:"points_outstanding"`
+	StampsOutstanding uint64 `json:"stamps_outstanding"`
+}
+
+// Balance is a customer's points and stamps balances
+type Balance struct {
+	OrgID         string `json:"org_id"`
+	CustomerID    string `json:"customer_id"`
+	PointsBalance uint64 `json:"points_balance"`
+	StampsBalance uint64 `json:"stamps_balance"`
+}
+
+func NewLedgerClient(baseURL string) *LedgerClient {
+	return &LedgerClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c *LedgerClient) CreatePointsTransfer(orgID, customerID string, points int, reference string) (*TransferResponse, error) {
+	if points <= 0 {
+		return nil, fmt.Errorf("points transfer amount must be positive, got %d", points)
+	}
+
+	req := CreateTransferRequest{
+		OrgID:           orgID,
+		CustomerID:      customerID,
+		TransactionType: "points_accrual",
+		Amount:          uint64(points),
+		Code:            TransferCodePoints,
+		Reference:       reference,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.httpClient.Post(
+		c.baseURL+"/api/v1/transfers",
+		"application/json",
+		bytes.NewBuffer(jsonData),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var response TransferResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *LedgerClient) GetLiability(orgID string) (*Liability, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/liability?org_id=" + url.QueryEscape(orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var liability Liability
+	if err := json.NewDecoder(resp.Body).Decode(&liability); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &liability, nil
+}
+
+func (c *LedgerClient) GetBalance(orgID, customerID string) (*Balance, error) {
+	query := url.Values{"org_id": {orgID}, "customer_id": {customerID}}
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/balance?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var balance Balance
+	if err := json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &balance, nil
+}
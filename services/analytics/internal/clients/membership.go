@@ -0,0 +1,59 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type MembershipClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Customer is the membership service's record of a customer
+type Customer struct {
+	CustomerID string    `json:"customer_id"`
+	OrgID      string    `json:"org_id"`
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Tier       string    `json:"tier"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewMembershipClient(baseURL string) *MembershipClient {
+	return &MembershipClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c *MembershipClient) GetCustomer(customerID string) (*Customer, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/customers/" + url.PathEscape(customerID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("membership service returned status %d", resp.StatusCode)
+	}
+
+	var customer Customer
+	if err := json.NewDecoder(resp.Body).Decode(&customer); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &customer, nil
+}
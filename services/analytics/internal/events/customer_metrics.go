@@ -0,0 +1,51 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CustomerMetrics is the payload of a customer.metrics.updated event, which
+// the stream processor publishes for every POS transaction or refund it
+// processes. Amounts are what the customer spent, or had refunded when Refund
+// is set. AccrualStatus is "applied" when the ledger applied the transaction
+// and "failed", with AccrualError, when it earned nothing; the spend counts
+// either way.
+type CustomerMetrics struct {
+	TransactionID string    `json:"transaction_id"`
+	Amount        float64   `json:"amount"`
+	Refund        bool      `json:"refund"`
+	Timestamp     time.Time `json:"timestamp"`
+	PointsEarned  int       `json:"points_earned"`
+	StampsEarned  int       `json:"stamps_earned"`
+	SourceEventID string    `json:"source_event_id"`
+	AccrualStatus string    `json:"accrual_status"`
+	AccrualError  string    `json:"accrual_error"`
+}
+
+// ParseCustomerMetrics decodes a customer.metrics.updated event payload
+func ParseCustomerMetrics(payload map[string]interface{}) (CustomerMetrics, error) {
+	var metrics CustomerMetrics
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return metrics, fmt.Errorf("failed to marshal customer metrics payload: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return metrics, fmt.Errorf("failed to unmarshal customer metrics: %w", err)
+	}
+
+	return metrics, nil
+}
+
+// TransactionTopic returns the topic suffix transactions are read from: the
+// stream processor's customer.metrics.updated events when consumeMetrics is
+// set, otherwise raw POS events. Reading both would count every transaction
+// twice.
+func TransactionTopic(consumeMetrics bool) string {
+	if consumeMetrics {
+		return ".customer.metrics.updated"
+	}
+	return ".pos.transaction"
+}
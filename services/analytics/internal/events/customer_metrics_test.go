@@ -0,0 +1,55 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ParseCustomerMetrics
+func TestParseCustomerMetrics(t *testing.T) {
+	timestamp := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	payload := map[string]interface{}{
+		"transaction_id":  "txn_123",
+		"amount":          42.5,
+		"refund":          true,
+		"timestamp":       timestamp.Format(time.RFC3339),
+		"points_earned":   -85,
+		"stamps_earned":   -1,
+		"source_event_id": "evt_123",
+		"accrual_status":  "failed",
+		"accrual_error":   "insufficient points balance",
+	}
+
+	metrics, err := ParseCustomerMetrics(payload)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "txn_123", metrics.TransactionID)
+	assert.Equal(t, 42.5, metrics.Amount)
+	assert.True(t, metrics.Refund)
+	assert.True(t, timestamp.Equal(metrics.Timestamp))
+	assert.Equal(t, -85, metrics.PointsEarned)
+	assert.Equal(t, -1, metrics.StampsEarned)
+	assert.Equal(t, "evt_123", metrics.SourceEventID)
+	assert.Equal(t, "failed", metrics.AccrualStatus)
+	assert.Equal(t, "insufficient points balance", metrics.AccrualError)
+}
+
+func TestParseCustomerMetrics_InvalidPayload(t *testing.T) {
+	payload := map[string]interface{}{
+		"amount": "plenty",
+	}
+
+	_, err := ParseCustomerMetrics(payload)
+
+	// Assertions
+	assert.Error(t, err)
+}
+
+// Test TransactionTopic
+func TestTransactionTopic(t *testing.T) {
+	assert.Equal(t, ".pos.transaction", TransactionTopic(false))
+	assert.Equal(t, ".customer.metrics.updated", TransactionTopic(true))
+}
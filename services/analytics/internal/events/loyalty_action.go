@@ -0,0 +1,83 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type LoyaltyAction struct {
+	ActionType string `json:"action_type"`
+	Points     int    `json:"points"`
+	Stamps     int    `json:"stamps"`
+	Reference  string `json:"reference"`
+}
+
+// SpendConfig decides which loyalty actions count towards RFM and tier
+// metrics. A matching action is treated as a transaction worth its points
+// times SpendPerPoint.
+type SpendConfig struct {
+	// ActionTypes lists the action types that stand in for spend, such as
+	// manual_points. Empty means loyalty actions never update metrics.
+	ActionTypes []string
+	// SpendPerPoint converts an action's points into a spend amount
+	SpendPerPoint float64
+}
+
+func DefaultSpendConfig() SpendConfig {
+	return SpendConfig{
+		SpendPerPoint: 1.0,
+	}
+}
+
+// InteractionConfig decides which loyalty actions count as a customer visit
+// for RFM. A matching action moves the customer's last transaction, so it
+// counts towards recency, but adds no spend.
+type InteractionConfig struct {
+	// ActionTypes lists the action types that are interactions, such as
+	// redemption or bonus_stamps. Empty means none are.
+	ActionTypes []string
+	// CountTransactions also adds a matching action to the customer's
+	// transaction count, so it counts towards frequency
+	CountTransactions bool
+}
+
+// ParseLoyaltyAction decodes a loyalty.action event payload
+func ParseLoyaltyAction(payload map[string]interface{}) (LoyaltyAction, error) {
+	var action LoyaltyAction
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return action, fmt.Errorf("failed to marshal loyalty action payload: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &action); err != nil {
+		return action, fmt.Errorf("failed to unmarshal loyalty action: %w", err)
+	}
+
+	return action, nil
+}
+
+// SpendEquivalent returns the spend an action stands for, and false if the
+// action should not update metrics
+func (c SpendConfig) SpendEquivalent(action LoyaltyAction) (float64, bool) {
+	if action.Points <= 0 || c.SpendPerPoint <= 0 {
+		return 0, false
+	}
+
+	for _, actionType := range c.ActionTypes {
+		if actionType == action.ActionType {
+			return float64(action.Points) * c.SpendPerPoint, true
+		}
+	}
+
+	return 0, false
+}
+
+// IsInteraction reports whether action counts as a customer interaction
+func (c InteractionConfig) IsInteraction(action LoyaltyAction) bool {
+	for _, actionType := range c.ActionTypes {
+		if actionType == action.ActionType {
+			return true
+		}
+	}
+	return false
+}
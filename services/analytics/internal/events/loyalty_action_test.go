@@ -0,0 +1,76 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ParseLoyaltyAction
+func TestParseLoyaltyAction(t *testing.T) {
+	payload := map[string]interface{}{
+		"action_type": "manual_points",
+		"points":      150,
+		"reference":   "ref_123",
+		"extra_data":  map[string]interface{}{"reason": "goodwill"},
+	}
+
+	action, err := ParseLoyaltyAction(payload)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "manual_points", action.ActionType)
+	assert.Equal(t, 150, action.Points)
+	assert.Equal(t, "ref_123", action.Reference)
+}
+
+func TestParseLoyaltyAction_InvalidPayload(t *testing.T) {
+	payload := map[string]interface{}{
+		"points": "lots",
+	}
+
+	_, err := ParseLoyaltyAction(payload)
+
+	// Assertions
+	assert.Error(t, err)
+}
+
+// Test SpendEquivalent
+func TestSpendEquivalent(t *testing.T) {
+	enabled := SpendConfig{
+		ActionTypes:   []string{"manual_points", "birthday_bonus"},
+		SpendPerPoint: 0.5,
+	}
+
+	tests := []struct {
+		name     string
+		config   SpendConfig
+		action   LoyaltyAction
+		expected float64
+		counts   bool
+	}{
+		{"configured action updates metrics", enabled, LoyaltyAction{ActionType: "manual_points", Points: 200}, 100.0, true},
+		{"unlisted action ignored", enabled, LoyaltyAction{ActionType: "referral_bonus", Points: 250}, 0, false},
+		{"stamps only action ignored", enabled, LoyaltyAction{ActionType: "manual_points", Stamps: 3}, 0, false},
+		{"ignored without config", DefaultSpendConfig(), LoyaltyAction{ActionType: "manual_points", Points: 200}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, counts := tt.config.SpendEquivalent(tt.action)
+			assert.Equal(t, tt.counts, counts)
+			assert.Equal(t, tt.expected, amount)
+		})
+	}
+}
+
+// Test IsInteraction
+func TestIsInteraction(t *testing.T) {
+	config := InteractionConfig{ActionTypes: []string{"redemption", "bonus_stamps"}}
+
+	// Assertions
+	assert.True(t, config.IsInteraction(LoyaltyAction{ActionType: "bonus_stamps", Stamps: 2}))
+	assert.True(t, config.IsInteraction(LoyaltyAction{ActionType: "redemption", Points: -500}))
+	assert.False(t, config.IsInteraction(LoyaltyAction{ActionType: "manual_points", Points: 100}))
+	assert.False(t, InteractionConfig{}.IsInteraction(LoyaltyAction{ActionType: "bonus_stamps"}))
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Future timestamp modes control what happens to an event stamped further
+// ahead than TimestampPolicy.MaxFutureSkew
+const (
+	FutureTimestampClamp  = "clamp"
+	FutureTimestampReject = "reject"
+)
+
+var ErrFutureTimestamp = errors.New("event timestamp is in the future")
+
+// TimestampPolicy guards recency and period maths against POS clocks running
+// ahead. A MaxFutureSkew of zero or less disables the check.
+type TimestampPolicy struct {
+	MaxFutureSkew time.Duration
+	// Mode is FutureTimestampClamp (default) to replace the timestamp with
+	// the current time, or FutureTimestampReject to drop the event
+	Mode string
+}
+
+func DefaultTimestampPolicy() TimestampPolicy {
+	return TimestampPolicy{
+		MaxFutureSkew: 5 * time.Minute,
+		Mode:          FutureTimestampClamp,
+	}
+}
+
+// Check returns the timestamp to use for an event, logging any event that is
+// too far in the future
+func (p TimestampPolicy) Check(eventID string, timestamp time.Time) (time.Time, error) {
+	return p.check(eventID, timestamp, time.Now())
+}
+
+func (p TimestampPolicy) check(eventID string, timestamp, now time.Time) (time.Time, error) {
+	if p.MaxFutureSkew <= 0 || !timestamp.After(now.Add(p.MaxFutureSkew)) {
+		return timestamp, nil
+	}
+
+	ahead := timestamp.Sub(now)
+	if p.Mode == FutureTimestampReject {
+		log.Printf("Rejecting event %s: timestamp %s is %s in the future", eventID, timestamp.Format(time.RFC3339), ahead)
+		return time.Time{}, fmt.Errorf("%w: %s ahead", ErrFutureTimestamp, ahead)
+	}
+
+	log.Printf("Clamping event %s: timestamp %s is %s in the future", eventID, timestamp.Format(time.RFC3339), ahead)
+	return now, nil
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test TimestampPolicy
+func TestTimestampPolicy_Check(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clamp := TimestampPolicy{MaxFutureSkew: 5 * time.Minute, Mode: FutureTimestampClamp}
+	reject := TimestampPolicy{MaxFutureSkew: 5 * time.Minute, Mode: FutureTimestampReject}
+
+	tests := []struct {
+		name      string
+		policy    TimestampPolicy
+		timestamp time.Time
+		expected  time.Time
+		rejected  bool
+	}{
+		{"past event kept", clamp, now.Add(-time.Hour), now.Add(-time.Hour), false},
+		{"within skew kept", clamp, now.Add(4 * time.Minute), now.Add(4 * time.Minute), false},
+		{"future event clamped", clamp, now.Add(48 * time.Hour), now, false},
+		{"future event rejected", reject, now.Add(48 * time.Hour), time.Time{}, true},
+		{"empty mode clamps", TimestampPolicy{MaxFutureSkew: time.Minute}, now.Add(time.Hour), now, false},
+		{"disabled policy keeps future event", TimestampPolicy{}, now.Add(48 * time.Hour), now.Add(48 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamp, err := tt.policy.check("evt_123", tt.timestamp, now)
+
+			if tt.rejected {
+				assert.True(t, errors.Is(err, ErrFutureTimestamp))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, timestamp)
+		})
+	}
+}
+
+func TestTimestampPolicy_ClampedRecencyNotNegative(t *testing.T) {
+	timestamp, err := DefaultTimestampPolicy().Check("evt_123", time.Now().Add(72*time.Hour))
+
+	// Assertions
+	assert.NoError(t, err)
+	daysSinceLast := int(time.Since(timestamp).Hours() / 24)
+	assert.GreaterOrEqual(t, daysSinceLast, 0)
+	assert.False(t, timestamp.After(time.Now()))
+}
@@ -5,6 +5,12 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Sort orders for paging through an org's RFM scores
+const (
+	RFMSortComposite = "composite"
+	RFMSortMonetary  = "monetary"
+)
+
 type RFMScore struct {
 	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	OrgID            string            `bson:"org_id" json:"org_id"`
@@ -13,6 +19,8 @@ type RFMScore struct {
 	RecencyScore     int               `bson:"recency_score" json:"recency_score"`
 	FrequencyScore   int               `bson:"frequency_score" json:"frequency_score"`
 	MonetaryScore    int               `bson:"monetary_score" json:"monetary_score"`
+	// WeightedScore averages the three scores with the org's vertical weights
+	WeightedScore    float64           `bson:"weighted_score" json:"weighted_score"`
 	RFMSegment       string            `bson:"rfm_segment" json:"rfm_segment"`
 	LastTransaction  time.Time         `bson:"last_transaction" json:"last_transaction"`
 	TotalTransactions int              `bson:"total_transactions" json:"total_transactions"`
@@ -24,20 +32,40 @@ type RFMScore struct {
 	UpdatedAt        time.Time         `bson:"updated_at" json:"updated_at"`
 }
 
+// CompositeScore combines the three scores into one number, e.g. 5/4/3
+// becomes 543, so ranking by it matches the composite sort order. That holds
+// for scores up to 10, which is why RFM_BUCKETS is capped there.
+func (s RFMScore) CompositeScore() int {
+	return s.RecencyScore*100 + s.FrequencyScore*10 + s.MonetaryScore
+}
+
 type CustomerActivity struct {
-	OrgID             string    `json:"org_id"`
-	LocationID        string    `json:"location_id"`
-	CustomerID        string    `json:"customer_id"`
-	TransactionDate   time.Time `json:"transaction_date"`
-	Amount            float64   `json:"amount"`
-	FirstTransaction  time.Time `json:"first_transaction"`
-	LastTransaction   time.Time `json:"last_transaction"`
-	TotalTransactions int       `json:"total_transactions"`
-	TotalSpent        float64   `json:"total_spent"`
+	OrgID             string    `bson:"org_id" json:"org_id"`
+	LocationID        string    `bson:"location_id" json:"location_id"`
+	CustomerID        string    `bson:"customer_id" json:"customer_id"`
+	TransactionDate   time.Time `bson:"transaction_date" json:"transaction_date"`
+	Amount            float64   `bson:"amount" json:"amount"`
+	FirstTransaction  time.Time `bson:"first_transaction" json:"first_transaction"`
+	LastTransaction   time.Time `bson:"last_transaction" json:"last_transaction"`
+	TotalTransactions int       `bson:"total_transactions" json:"total_transactions"`
+	TotalSpent        float64   `bson:"total_spent" json:"total_spent"`
+}
+
+// SegmentTransition records a customer moving from one RFM segment to
+// another at a location
+type SegmentTransition struct {
+	OrgID       string    `bson:"org_id" json:"org_id"`
+	LocationID  string    `bson:"location_id" json:"location_id"`
+	CustomerID  string    `bson:"customer_id" json:"customer_id"`
+	FromSegment string    `bson:"from_segment" json:"from_segment"`
+	ToSegment   string    `bson:"to_segment" json:"to_segment"`
+	ChangedAt   time.Time `bson:"changed_at" json:"changed_at"`
 }
 
 type RFMQuintiles struct {
 	OrgID              string    `bson:"org_id" json:"org_id"`
+	// LocationID is set on quintiles calculated from one location's customers
+	LocationID         string    `bson:"location_id,omitempty" json:"location_id,omitempty"`
 	RecencyQuintiles   []int     `bson:"recency_quintiles" json:"recency_quintiles"`
 	FrequencyQuintiles []int     `bson:"frequency_quintiles" json:"frequency_quintiles"`
 	MonetaryQuintiles  []float64 `bson:"monetary_quintiles" json:"monetary_quintiles"`
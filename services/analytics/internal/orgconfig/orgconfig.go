@@ -0,0 +1,40 @@
+// Package orgconfig parses the per-org settings the analytics services take
+// from environment variables.
+package orgconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseOrgValues parses a comma-separated list of org=value entries, such as
+// "org_a=grocery,org_b=hospitality", with parse turning each value into a T.
+// kind names the setting and format describes its value in errors, e.g.
+// "vertical" and "vertical". Empty entries are skipped and each org may
+// appear once.
+func ParseOrgValues[T any](spec, kind, format string, parse func(value string) (T, error)) (map[string]T, error) {
+	values := make(map[string]T)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		orgID, value, ok := strings.Cut(entry, "=")
+		orgID = strings.TrimSpace(orgID)
+		if !ok || orgID == "" {
+			return nil, fmt.Errorf("invalid org %s %q, expected org=%s", kind, entry, format)
+		}
+		if _, exists := values[orgID]; exists {
+			return nil, fmt.Errorf("duplicate %s for org %s", kind, orgID)
+		}
+
+		parsed, err := parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("org %s: %w", orgID, err)
+		}
+		values[orgID] = parsed
+	}
+
+	return values, nil
+}
@@ -0,0 +1,60 @@
+package orgconfig
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseLimit(value string) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(value))
+}
+
+// Test ParseOrgValues
+func TestParseOrgValues(t *testing.T) {
+	values, err := ParseOrgValues(" org_a=5, org_b = 10 ,,", "limit", "number", parseLimit)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"org_a": 5, "org_b": 10}, values)
+}
+
+func TestParseOrgValues_Empty(t *testing.T) {
+	values, err := ParseOrgValues("", "limit", "number", parseLimit)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestParseOrgValues_Invalid(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"org_a", `invalid org limit "org_a", expected org=number`},
+		{"=5", `invalid org limit "=5", expected org=number`},
+		{"org_a=5,org_a=6", "duplicate limit for org org_a"},
+		{"org_a=lots", `org org_a: strconv.Atoi: parsing "lots": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseOrgValues(tt.spec, "limit", "number", parseLimit)
+
+			// Assertions
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestParseOrgValues_WrapsParseErrors(t *testing.T) {
+	errTooLow := errors.New("too low")
+	_, err := ParseOrgValues("org_a=1", "limit", "number", func(string) (int, error) { return 0, errTooLow })
+
+	// Assertions
+	assert.ErrorIs(t, err, errTooLow)
+}
@@ -0,0 +1,157 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/loyalty/analytics/internal/clients"
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/loyalty/analytics/internal/storage"
+	"github.com/loyalty/analytics/internal/tiers"
+)
+
+// Profile sources, as named in Profile.Unavailable
+const (
+	SourceMembership = "membership"
+	SourceLedger     = "ledger"
+	SourceTier       = "tier"
+	SourceRFM        = "rfm"
+)
+
+// ErrCustomerNotFound is returned when membership has no such customer in the org
+var ErrCustomerNotFound = errors.New("customer not found")
+
+// Config holds the tunable behaviour of Aggregator
+type Config struct {
+	// SourceTimeout is how long a profile waits on its sources. Any still
+	// outstanding after it are reported unavailable. Zero waits as long as
+	// the request does.
+	SourceTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SourceTimeout: 2 * time.Second,
+	}
+}
+
+// Profile is everything an app shows about a customer, gathered from the
+// services that own each part
+type Profile struct {
+	OrgID      string              `json:"org_id"`
+	CustomerID string              `json:"customer_id"`
+	Customer   *clients.Customer   `json:"customer"`
+	Balance    *clients.Balance    `json:"balance"`
+	Tier       *tiers.CustomerTier `json:"tier"`
+	RFM        *models.RFMScore    `json:"rfm"`
+	// Unavailable maps each source that failed or timed out to its error.
+	// Its section of the profile is left empty.
+	Unavailable map[string]string `json:"unavailable,omitempty"`
+}
+
+// Aggregator builds customer profiles from membership, the ledger and the
+// analytics tier and RFM stores
+type Aggregator struct {
+	membership clients.MembershipClientInterface
+	ledger     clients.BalanceClientInterface
+	tiers      TierReaderInterface
+	rfm        RFMReaderInterface
+	config     Config
+}
+
+func NewAggregator(membership clients.MembershipClientInterface, ledger clients.BalanceClientInterface, tierReader TierReaderInterface, rfmReader RFMReaderInterface, config Config) *Aggregator {
+	return &Aggregator{
+		membership: membership,
+		ledger:     ledger,
+		tiers:      tierReader,
+		rfm:        rfmReader,
+		config:     config,
+	}
+}
+
+// sourceResult is one source's section of a profile
+type sourceResult struct {
+	source string
+	value  interface{}
+	err    error
+}
+
+// GetProfile reads every source at once. A source with no record of the
+// customer yet, such as a new customer without an RFM score, leaves its
+// section empty; one that fails or does not answer within SourceTimeout is
+// listed in Unavailable instead of failing the profile.
+func (a *Aggregator) GetProfile(ctx context.Context, orgID, customerID string) (*Profile, error) {
+	if a.config.SourceTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.config.SourceTimeout)
+		defer cancel()
+	}
+
+	// Buffered so sources answering after the timeout do not block
+	results := make(chan sourceResult, 4)
+	fetch := func(source string, read func() (interface{}, error)) {
+		go func() {
+			value, err := read()
+			results <- sourceResult{source: source, value: value, err: err}
+		}()
+	}
+	fetch(SourceMembership, func() (interface{}, error) { return a.membership.GetCustomer(customerID) })
+	fetch(SourceLedger, func() (interface{}, error) { return a.ledger.GetBalance(orgID, customerID) })
+	fetch(SourceTier, func() (interface{}, error) { return a.tiers.GetCustomerTier(ctx, orgID, customerID) })
+	fetch(SourceRFM, func() (interface{}, error) { return a.rfm.GetRFMScore(ctx, orgID, customerID) })
+
+	profile := &Profile{OrgID: orgID, CustomerID: customerID}
+	pending := map[string]bool{SourceMembership: true, SourceLedger: true, SourceTier: true, SourceRFM: true}
+	for len(pending) > 0 {
+		select {
+		case result := <-results:
+			delete(pending, result.source)
+			if err := result.err; err != nil {
+				if errors.Is(err, clients.ErrNotFound) && result.source == SourceMembership {
+					return nil, ErrCustomerNotFound
+				}
+				if !notFound(err) {
+					profile.unavailable(result.source, err)
+				}
+				continue
+			}
+
+			switch value := result.value.(type) {
+			case *clients.Customer:
+				// A customer of another org is not this org's to show
+				if value.OrgID != orgID {
+					return nil, ErrCustomerNotFound
+				}
+				profile.Customer = value
+			case *clients.Balance:
+				profile.Balance = value
+			case *tiers.CustomerTier:
+				profile.Tier = value
+			case *models.RFMScore:
+				profile.RFM = value
+			}
+		case <-ctx.Done():
+			for source := range pending {
+				profile.unavailable(source, ctx.Err())
+			}
+			return profile, nil
+		}
+	}
+
+	return profile, nil
+}
+
+func (p *Profile) unavailable(source string, err error) {
+	if p.Unavailable == nil {
+		p.Unavailable = make(map[string]string)
+	}
+	p.Unavailable[source] = err.Error()
+}
+
+// notFound reports whether err means a source has no record of the customer
+func notFound(err error) bool {
+	return errors.Is(err, clients.ErrNotFound) ||
+		errors.Is(err, tiers.ErrCustomerTierNotFound) ||
+		errors.Is(err, storage.ErrRFMScoreNotFound)
+}
@@ -0,0 +1,197 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/clients"
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/loyalty/analytics/internal/storage"
+	"github.com/loyalty/analytics/internal/tiers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMembershipClient is a mock implementation of the membership client
+type MockMembershipClient struct {
+	mock.Mock
+}
+
+func (m *MockMembershipClient) GetCustomer(customerID string) (*clients.Customer, error) {
+	args := m.Called(customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.Customer), args.Error(1)
+}
+
+// MockBalanceClient is a mock implementation of the ledger balance client
+type MockBalanceClient struct {
+	mock.Mock
+}
+
+func (m *MockBalanceClient) GetBalance(orgID, customerID string) (*clients.Balance, error) {
+	args := m.Called(orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.Balance), args.Error(1)
+}
+
+// MockTierReader is a mock implementation of the tier reader
+type MockTierReader struct {
+	mock.Mock
+}
+
+func (m *MockTierReader) GetCustomerTier(ctx context.Context, orgID, customerID string) (*tiers.CustomerTier, error) {
+	args := m.Called(ctx, orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*tiers.CustomerTier), args.Error(1)
+}
+
+// MockRFMReader is a mock implementation of the RFM reader
+type MockRFMReader struct {
+	mock.Mock
+}
+
+func (m *MockRFMReader) GetRFMScore(ctx context.Context, orgID, customerID string) (*models.RFMScore, error) {
+	args := m.Called(ctx, orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMScore), args.Error(1)
+}
+
+// Test setup helper
+func setupTestAggregator(config Config) (*Aggregator, *MockMembershipClient, *MockBalanceClient, *MockTierReader, *MockRFMReader) {
+	membership := &MockMembershipClient{}
+	ledger := &MockBalanceClient{}
+	tierReader := &MockTierReader{}
+	rfmReader := &MockRFMReader{}
+	return NewAggregator(membership, ledger, tierReader, rfmReader, config), membership, ledger, tierReader, rfmReader
+}
+
+var (
+	testCustomer = &clients.Customer{CustomerID: "cust_1", OrgID: "test_org", FirstName: "Ada"}
+	testBalance  = &clients.Balance{OrgID: "test_org", CustomerID: "cust_1", PointsBalance: 1200, StampsBalance: 3}
+	testTier     = &tiers.CustomerTier{OrgID: "test_org", CustomerID: "cust_1", CurrentTier: "Gold"}
+	testRFM      = &models.RFMScore{OrgID: "test_org", CustomerID: "cust_1", RFMSegment: "Champions"}
+)
+
+// Test GetProfile
+func TestGetProfile_AllSourcesUp(t *testing.T) {
+	aggregator, membership, ledger, tierReader, rfmReader := setupTestAggregator(DefaultConfig())
+
+	// Setup expectations
+	membership.On("GetCustomer", "cust_1").Return(testCustomer, nil)
+	ledger.On("GetBalance", "test_org", "cust_1").Return(testBalance, nil)
+	tierReader.On("GetCustomerTier", mock.Anything, "test_org", "cust_1").Return(testTier, nil)
+	rfmReader.On("GetRFMScore", mock.Anything, "test_org", "cust_1").Return(testRFM, nil)
+
+	// Test GetProfile
+	profile, err := aggregator.GetProfile(context.Background(), "test_org", "cust_1")
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Equal(t, testCustomer, profile.Customer)
+	assert.Equal(t, testBalance, profile.Balance)
+	assert.Equal(t, testTier, profile.Tier)
+	assert.Equal(t, testRFM, profile.RFM)
+	assert.Empty(t, profile.Unavailable)
+}
+
+func TestGetProfile_OneSourceDown(t *testing.T) {
+	aggregator, membership, ledger, tierReader, rfmReader := setupTestAggregator(DefaultConfig())
+
+	// Setup expectations
+	membership.On("GetCustomer", "cust_1").Return(testCustomer, nil)
+	ledger.On("GetBalance", "test_org", "cust_1").Return(nil, errors.New("ledger service returned status 503"))
+	tierReader.On("GetCustomerTier", mock.Anything, "test_org", "cust_1").Return(testTier, nil)
+	rfmReader.On("GetRFMScore", mock.Anything, "test_org", "cust_1").Return(testRFM, nil)
+
+	// Test GetProfile
+	profile, err := aggregator.GetProfile(context.Background(), "test_org", "cust_1")
+
+	// Assertions - the rest of the profile is still returned
+	require.NoError(t, err)
+	assert.Nil(t, profile.Balance)
+	assert.Equal(t, map[string]string{SourceLedger: "ledger service returned status 503"}, profile.Unavailable)
+	assert.Equal(t, testCustomer, profile.Customer)
+	assert.Equal(t, testTier, profile.Tier)
+	assert.Equal(t, testRFM, profile.RFM)
+}
+
+func TestGetProfile_SlowSourceTimesOut(t *testing.T) {
+	aggregator, membership, ledger, tierReader, rfmReader := setupTestAggregator(Config{SourceTimeout: 20 * time.Millisecond})
+
+	// Setup expectations
+	membership.On("GetCustomer", "cust_1").Return(testCustomer, nil)
+	ledger.On("GetBalance", "test_org", "cust_1").Return(testBalance, nil).After(time.Second)
+	tierReader.On("GetCustomerTier", mock.Anything, "test_org", "cust_1").Return(testTier, nil)
+	rfmReader.On("GetRFMScore", mock.Anything, "test_org", "cust_1").Return(testRFM, nil)
+
+	// Test GetProfile
+	start := time.Now()
+	profile, err := aggregator.GetProfile(context.Background(), "test_org", "cust_1")
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Nil(t, profile.Balance)
+	assert.Contains(t, profile.Unavailable, SourceLedger)
+	assert.Equal(t, testTier, profile.Tier)
+}
+
+func TestGetProfile_NewCustomerHasEmptySections(t *testing.T) {
+	aggregator, membership, ledger, tierReader, rfmReader := setupTestAggregator(DefaultConfig())
+
+	// Setup expectations
+	membership.On("GetCustomer", "cust_1").Return(testCustomer, nil)
+	ledger.On("GetBalance", "test_org", "cust_1").Return(testBalance, nil)
+	tierReader.On("GetCustomerTier", mock.Anything, "test_org", "cust_1").Return(nil, tiers.ErrCustomerTierNotFound)
+	rfmReader.On("GetRFMScore", mock.Anything, "test_org", "cust_1").Return(nil, storage.ErrRFMScoreNotFound)
+
+	// Test GetProfile
+	profile, err := aggregator.GetProfile(context.Background(), "test_org", "cust_1")
+
+	// Assertions - nothing is unavailable, there is just nothing to show yet
+	require.NoError(t, err)
+	assert.Nil(t, profile.Tier)
+	assert.Nil(t, profile.RFM)
+	assert.Empty(t, profile.Unavailable)
+}
+
+func TestGetProfile_CustomerNotFound(t *testing.T) {
+	tests := []struct {
+		name     string
+		customer *clients.Customer
+		err      error
+	}{
+		{"unknown customer", nil, clients.ErrNotFound},
+		{"customer of another org", &clients.Customer{CustomerID: "cust_1", OrgID: "other_org"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator, membership, ledger, tierReader, rfmReader := setupTestAggregator(DefaultConfig())
+
+			// Setup expectations
+			membership.On("GetCustomer", "cust_1").Return(tt.customer, tt.err)
+			ledger.On("GetBalance", "test_org", "cust_1").Return(testBalance, nil).Maybe()
+			tierReader.On("GetCustomerTier", mock.Anything, "test_org", "cust_1").Return(testTier, nil).Maybe()
+			rfmReader.On("GetRFMScore", mock.Anything, "test_org", "cust_1").Return(testRFM, nil).Maybe()
+
+			// Test GetProfile
+			profile, err := aggregator.GetProfile(context.Background(), "test_org", "cust_1")
+
+			// Assertions
+			assert.ErrorIs(t, err, ErrCustomerNotFound)
+			assert.Nil(t, profile)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package profile
+
+import (
+	"context"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/loyalty/analytics/internal/tiers"
+)
+
+// TierReaderInterface reads the tier section of a profile
+type TierReaderInterface interface {
+	GetCustomerTier(ctx context.Context, orgID, customerID string) (*tiers.CustomerTier, error)
+}
+
+// RFMReaderInterface reads the RFM section of a profile
+type RFMReaderInterface interface {
+	GetRFMScore(ctx context.Context, orgID, customerID string) (*models.RFMScore, error)
+}
+
+// ProfileReaderInterface defines the profile read exposed over the analytics API
+type ProfileReaderInterface interface {
+	GetProfile(ctx context.Context, orgID, customerID string) (*Profile, error)
+}
@@ -0,0 +1,22 @@
+package rewards
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnknownGroupBy is returned when counting rewards by an unsupported
+// grouping
+var ErrUnknownGroupBy = errors.New("group_by must be location or reward")
+
+// RewardRecorderInterface defines the storage operation the processors use to
+// record triggered rewards
+type RewardRecorderInterface interface {
+	SaveTriggeredReward(ctx context.Context, reward TriggeredReward) error
+}
+
+// RewardReaderInterface defines the triggered reward read operations exposed
+// over the analytics API
+type RewardReaderInterface interface {
+	CountTriggeredRewards(ctx context.Context, orgID, groupBy string) (map[string]int, error)
+}
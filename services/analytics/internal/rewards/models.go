@@ -0,0 +1,42 @@
+package rewards
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ways triggered rewards can be counted
+const (
+	GroupByLocation = "location"
+	GroupByReward   = "reward"
+)
+
+// TriggeredReward records a reward.triggered event. EventID is the reward
+// event's ID, so a redelivered event is stored once.
+type TriggeredReward struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventID       string             `bson:"event_id" json:"event_id"`
+	OrgID         string             `bson:"org_id" json:"org_id"`
+	LocationID    string             `bson:"location_id" json:"location_id"`
+	CustomerID    string             `bson:"customer_id" json:"customer_id"`
+	RewardID      string             `bson:"reward_id" json:"reward_id"`
+	RewardType    string             `bson:"reward_type" json:"reward_type"`
+	RewardValue   string             `bson:"reward_value" json:"reward_value"`
+	Description   string             `bson:"description" json:"description"`
+	SourceEventID string             `bson:"source_event_id" json:"source_event_id"`
+	TriggeredAt   time.Time          `bson:"triggered_at" json:"triggered_at"`
+}
+
+// groupField is the stored field rewards are counted by for groupBy,
+// reporting false for an unknown grouping
+func groupField(groupBy string) (string, bool) {
+	switch groupBy {
+	case GroupByLocation:
+		return "location_id", true
+	case GroupByReward:
+		return "reward_id", true
+	default:
+		return "", false
+	}
+}
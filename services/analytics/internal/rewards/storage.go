@@ -0,0 +1,79 @@
+package rewards
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/loyalty/analytics/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RewardStorage struct {
+	tenants *storage.TenantRouter
+}
+
+func NewRewardStorage(database *mongo.Database) *RewardStorage {
+	return NewRewardStorageWithTenants(storage.NewTenantRouter(database.Client(), database, nil))
+}
+
+// NewRewardStorageWithTenants reads and stores each org's triggered rewards
+// where tenants places it
+func NewRewardStorageWithTenants(tenants *storage.TenantRouter) *RewardStorage {
+	return &RewardStorage{tenants: tenants}
+}
+
+// SaveTriggeredReward stores the reward once per event ID, so a redelivered
+// reward.triggered event does not count twice
+func (s *RewardStorage) SaveTriggeredReward(ctx context.Context, reward TriggeredReward) error {
+	if reward.EventID == "" {
+		return fmt.Errorf("triggered reward requires an event ID")
+	}
+
+	collection := s.tenants.Collection(reward.OrgID, "triggered_rewards")
+
+	filter := bson.M{"event_id": reward.EventID}
+	update := bson.M{"$setOnInsert": reward}
+
+	opts := options.Update().SetUpsert(true)
+	if _, err := collection.UpdateOne(ctx, filter, update, opts); err != nil {
+		return fmt.Errorf("failed to save triggered reward: %w", err)
+	}
+
+	return nil
+}
+
+// CountTriggeredRewards counts the org's triggered rewards for each location
+// or each reward, as groupBy selects
+func (s *RewardStorage) CountTriggeredRewards(ctx context.Context, orgID, groupBy string) (map[string]int, error) {
+	field, ok := groupField(groupBy)
+	if !ok {
+		return nil, ErrUnknownGroupBy
+	}
+
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.M{"org_id": orgID}}},
+		{{"$group", bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := s.tenants.Collection(orgID, "triggered_rewards").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate triggered rewards by %s: %w", field, err)
+	}
+	defer cursor.Close(ctx)
+
+	counts := make(map[string]int)
+	for cursor.Next(ctx) {
+		var row struct {
+			Value string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode triggered reward count: %w", err)
+		}
+		counts[row.Value] = row.Count
+	}
+
+	return counts, nil
+}
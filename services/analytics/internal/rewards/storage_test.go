@@ -0,0 +1,103 @@
+package rewards
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Test SaveTriggeredReward
+func TestSaveTriggeredReward_UpsertsByEventID(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("insert once", func(mt *mtest.T) {
+		storage := NewRewardStorage(mt.DB)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		err := storage.SaveTriggeredReward(context.Background(), TriggeredReward{
+			EventID:     "evt_1_reward_free_coffee",
+			OrgID:       "test_org",
+			LocationID:  "loc_1",
+			CustomerID:  "cust_1",
+			RewardID:    "free_coffee",
+			TriggeredAt: time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC),
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "triggered_rewards", started.Command.Lookup("update").StringValue())
+		update := started.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "evt_1_reward_free_coffee", update.Lookup("q", "event_id").StringValue())
+		assert.True(t, update.Lookup("upsert").Boolean())
+		assert.Equal(t, "loc_1", update.Lookup("u", "$setOnInsert", "location_id").StringValue())
+	})
+
+	mt.Run("requires event ID", func(mt *mtest.T) {
+		storage := NewRewardStorage(mt.DB)
+
+		err := storage.SaveTriggeredReward(context.Background(), TriggeredReward{OrgID: "test_org", RewardID: "free_coffee"})
+
+		// Assertions
+		assert.Error(t, err)
+		assert.Nil(t, mt.GetStartedEvent())
+	})
+}
+
+// Test CountTriggeredRewards
+func TestCountTriggeredRewards(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("groups across two locations", func(mt *mtest.T) {
+		storage := NewRewardStorage(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.triggered_rewards", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "loc_1"}, {Key: "count", Value: 5}},
+			bson.D{{Key: "_id", Value: "loc_2"}, {Key: "count", Value: 3}},
+		))
+
+		counts, err := storage.CountTriggeredRewards(context.Background(), "test_org", GroupByLocation)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"loc_1": 5, "loc_2": 3}, counts)
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "aggregate", started.CommandName)
+		assert.Equal(t, "triggered_rewards", started.Command.Lookup("aggregate").StringValue())
+		pipeline := started.Command.Lookup("pipeline").Array()
+		assert.Equal(t, "test_org", pipeline.Index(0).Value().Document().Lookup("$match", "org_id").StringValue())
+		assert.Equal(t, "$location_id", pipeline.Index(1).Value().Document().Lookup("$group", "_id").StringValue())
+	})
+
+	mt.Run("groups by reward", func(mt *mtest.T) {
+		storage := NewRewardStorage(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.triggered_rewards", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "free_coffee"}, {Key: "count", Value: 8}},
+		))
+
+		counts, err := storage.CountTriggeredRewards(context.Background(), "test_org", GroupByReward)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"free_coffee": 8}, counts)
+		group := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(1).Value().Document()
+		assert.Equal(t, "$reward_id", group.Lookup("$group", "_id").StringValue())
+	})
+
+	mt.Run("unknown grouping", func(mt *mtest.T) {
+		storage := NewRewardStorage(mt.DB)
+
+		counts, err := storage.CountTriggeredRewards(context.Background(), "test_org", "customer")
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrUnknownGroupBy)
+		assert.Nil(t, counts)
+		assert.Nil(t, mt.GetStartedEvent())
+	})
+}
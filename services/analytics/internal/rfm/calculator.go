@@ -11,26 +11,116 @@ import (
 	"github.com/loyalty/analytics/internal/models"
 )
 
+// CalculatorConfig holds the tunable behaviour of RFMCalculator
+type CalculatorConfig struct {
+	// MinTransactionsForSegment is the transaction count a customer needs
+	// before their scores are mapped to a segment. Below it the scores are
+	// still recorded but the segment is InsufficientDataSegment. Zero
+	// disables the guard.
+	MinTransactionsForSegment int
+	// InsufficientDataSegment is the segment assigned to customers below
+	// MinTransactionsForSegment
+	InsufficientDataSegment string
+	// Verticals selects the preset weights and quintile methods of each org
+	Verticals VerticalConfig
+	// Sampling bounds the memory used by CalculateQuintilesForOrg
+	Sampling QuintileSampling
+	// Buckets is how many buckets each dimension is split into, so scores
+	// run from 1 to Buckets: 3 for terciles, 10 for deciles. Zero means 5,
+	// and it must not exceed MaxBuckets.
+	Buckets int
+	// LocationQuintiles scores customers against the quintiles of the
+	// location of their activity rather than the whole org's, so a busy
+	// flagship store does not push a kiosk's customers down
+	LocationQuintiles bool
+	// SegmentWriter, when set, receives a segment.changed event on the
+	// {org}.segment.changed topic whenever a customer's segment changes
+	SegmentWriter MessageWriter
+}
+
+func DefaultCalculatorConfig() CalculatorConfig {
+	return CalculatorConfig{
+		MinTransactionsForSegment: 2,
+		InsufficientDataSegment:   "New Customers",
+		Buckets:                   DefaultBuckets,
+	}
+}
+
+// DefaultBuckets scores each dimension by quintile
+const DefaultBuckets = 5
+
+// MaxBuckets is the most buckets a dimension may be split into. Composite
+// scores combine the three scores as decimal digits, which only sort like the
+// scores themselves while no score exceeds 10.
+const MaxBuckets = 10
+
+// buckets returns the configured bucket count, or DefaultBuckets if unset
+func (c CalculatorConfig) buckets() int {
+	if c.Buckets > 0 {
+		return c.Buckets
+	}
+	return DefaultBuckets
+}
+
+// rescaleScore maps score from a 1..from scale onto 1..to, keeping the lowest
+// and highest scores at the ends
+func rescaleScore(score, from, to int) int {
+	if from == to {
+		return score
+	}
+	if from <= 1 {
+		return 1
+	}
+	return int(math.Round(1 + float64(score-1)*float64(to-1)/float64(from-1)))
+}
+
 type RFMCalculator struct {
 	storage RFMStorageInterface
+	config  CalculatorConfig
 }
 
 func NewRFMCalculator(storage RFMStorageInterface) *RFMCalculator {
-	return &RFMCalculator{storage: storage}
+	return NewRFMCalculatorWithConfig(storage, DefaultCalculatorConfig())
+}
+
+func NewRFMCalculatorWithConfig(storage RFMStorageInterface, config CalculatorConfig) *RFMCalculator {
+	return &RFMCalculator{storage: storage, config: config}
 }
 
 func (c *RFMCalculator) ProcessCustomerTransaction(ctx context.Context, activity models.CustomerActivity) error {
 	log.Printf("Processing RFM for customer %s in org %s at location %s", 
 		activity.CustomerID, activity.OrgID, activity.LocationID)
 
-	quintiles, err := c.storage.GetOrCalculateQuintiles(ctx, activity.OrgID)
+	quintiles, err := c.quintilesFor(ctx, activity)
 	if err != nil {
 		return fmt.Errorf("failed to get quintiles: %w", err)
 	}
 
 	rfmScore := c.calculateRFMScore(activity, quintiles)
-	
-	return c.storage.SaveRFMScore(ctx, rfmScore)
+
+	// The previous segment is only needed to announce changes
+	var previousSegment string
+	if c.config.SegmentWriter != nil {
+		previousSegment = c.previousSegment(ctx, activity)
+	}
+
+	if err := c.storage.SaveRFMScore(ctx, rfmScore); err != nil {
+		return err
+	}
+
+	if previousSegment != "" && previousSegment != rfmScore.RFMSegment {
+		c.publishSegmentChange(ctx, previousSegment, rfmScore)
+	}
+	return nil
+}
+
+// quintilesFor returns the quintiles activity is scored against: its
+// location's with LocationQuintiles, otherwise its org's
+func (c *RFMCalculator) quintilesFor(ctx context.Context, activity models.CustomerActivity) (models.RFMQuintiles, error) {
+	if c.config.LocationQuintiles && activity.LocationID != "" {
+		return c.storage.GetOrCalculateLocationQuintiles(ctx, activity.OrgID, activity.LocationID)
+	}
+	return c.storage.GetOrCalculateQuintiles(ctx, activity.OrgID)
 }
 
 func (c *RFMCalculator) calculateRFMScore(activity models.CustomerActivity, quintiles models.RFMQuintiles) models.RFMScore {
@@ -38,13 +128,36 @@ func (c *RFMCalculator) calculateRFMScore(activity models.CustomerActivity, quin
 	
 	daysSinceLast := int(now.Sub(activity.LastTransaction).Hours() / 24)
 	daysSinceFirst := int(now.Sub(activity.FirstTransaction).Hours() / 24)
-	avgOrderValue := activity.TotalSpent / float64(activity.TotalTransactions)
+	// A POS clock running ahead leaves transactions in the future; count them
+	// as today rather than storing negative ages
+	if daysSinceLast < 0 {
+		log.Printf("Customer %s in org %s has a last transaction %d days in the future, treating it as today",
+			activity.CustomerID, activity.OrgID, -daysSinceLast)
+		daysSinceLast = 0
+	}
+	if daysSinceFirst < 0 {
+		daysSinceFirst = 0
+	}
+	// Customers known only from loyalty actions have no transactions to
+	// divide by, so like single-order customers their order value is what
+	// they have spent
+	avgOrderValue := activity.TotalSpent
+	if activity.TotalTransactions > 1 {
+		avgOrderValue = activity.TotalSpent / float64(activity.TotalTransactions)
+	}
 	
 	recencyScore := c.getRecencyScore(daysSinceLast, quintiles.RecencyQuintiles)
 	frequencyScore := c.getFrequencyScore(activity.TotalTransactions, quintiles.FrequencyQuintiles)
 	monetaryScore := c.getMonetaryScore(activity.TotalSpent, quintiles.MonetaryQuintiles)
 	
+	weightedScore := c.config.Verticals.Preset(activity.OrgID).Weights.Score(recencyScore, frequencyScore, monetaryScore)
+	
 	segment := c.getRFMSegment(recencyScore, frequencyScore, monetaryScore)
+	// A monetary score from one or two orders is just the order value, so
+	// segments like "Lost" or "Champions" would be noise at this point
+	if activity.TotalTransactions < c.config.MinTransactionsForSegment {
+		segment = c.config.InsufficientDataSegment
+	}
 	
 	return models.RFMScore{
 		OrgID:             activity.OrgID,
@@ -53,6 +166,7 @@ func (c *RFMCalculator) calculateRFMScore(activity models.CustomerActivity, quin
 		RecencyScore:      recencyScore,
 		FrequencyScore:    frequencyScore,
 		MonetaryScore:     monetaryScore,
+		WeightedScore:     weightedScore,
 		RFMSegment:        segment,
 		LastTransaction:   activity.LastTransaction,
 		TotalTransactions: activity.TotalTransactions,
@@ -65,10 +179,18 @@ func (c *RFMCalculator) calculateRFMScore(activity models.CustomerActivity, quin
 	}
 }
 
+// The score functions score against however many thresholds they are given.
+// Fixed vertical thresholds and quintiles saved before the bucket count
+// changed may not have one per bucket, so their scores are rescaled onto
+// 1..Buckets.
+
 func (c *RFMCalculator) getRecencyScore(daysSinceLast int, quintiles []int) int {
+	if daysSinceLast < 0 {
+		daysSinceLast = 0
+	}
 	for i, threshold := range quintiles {
 		if daysSinceLast <= threshold {
-			return 5 - i
+			return rescaleScore(len(quintiles)-i, len(quintiles), c.config.buckets())
 		}
 	}
 	return 1
@@ -77,7 +199,7 @@ func (c *RFMCalculator) getRecencyScore(daysSinceLast int, quintiles []int) int
 func (c *RFMCalculator) getFrequencyScore(totalTransactions int, quintiles []int) int {
 	for i := len(quintiles) - 1; i >= 0; i-- {
 		if totalTransactions >= quintiles[i] {
-			return i + 1
+			return rescaleScore(i+1, len(quintiles), c.config.buckets())
 		}
 	}
 	return 1
@@ -86,13 +208,21 @@ func (c *RFMCalculator) getFrequencyScore(totalTransactions int, quintiles []int
 func (c *RFMCalculator) getMonetaryScore(totalSpent float64, quintiles []float64) int {
 	for i := len(quintiles) - 1; i >= 0; i-- {
 		if totalSpent >= quintiles[i] {
-			return i + 1
+			return rescaleScore(i+1, len(quintiles), c.config.buckets())
 		}
 	}
 	return 1
 }
 
+// getRFMSegment names the segment for scores in 1..Buckets. The segments are
+// defined on quintile scores, so other bucket counts are rescaled onto 1..5
+// first.
 func (c *RFMCalculator) getRFMSegment(r, f, m int) string {
+	buckets := c.config.buckets()
+	r = rescaleScore(r, buckets, DefaultBuckets)
+	f = rescaleScore(f, buckets, DefaultBuckets)
+	m = rescaleScore(m, buckets, DefaultBuckets)
+
 	key := fmt.Sprintf("%d%d%d", r, f, m)
 	if segment, exists := RFMSegments[key]; exists {
 		return segment
@@ -120,6 +250,10 @@ func (c *RFMCalculator) getRFMSegment(r, f, m int) string {
 }
 
 func (c *RFMCalculator) CalculateQuintilesForOrg(ctx context.Context, orgID string) (models.RFMQuintiles, error) {
+	if c.config.Sampling.SampleSize > 0 {
+		return c.sampleQuintilesForOrg(ctx, orgID)
+	}
+
 	activities, err := c.storage.GetCustomerActivities(ctx, orgID)
 	if err != nil {
 		return models.RFMQuintiles{}, fmt.Errorf("failed to get customer activities: %w", err)
@@ -129,6 +263,32 @@ func (c *RFMCalculator) CalculateQuintilesForOrg(ctx context.Context, orgID stri
 		return c.getDefaultQuintiles(orgID), nil
 	}
 
+	recencyDays, frequencies, monetaryValues := activityValues(activities)
+	return c.quintilesFrom(orgID, recencyDays, frequencies, monetaryValues), nil
+}
+
+// CalculateQuintilesForLocation calculates quintiles from the customers of
+// one of orgID's locations. A location with fewer than 5 customers is too
+// small to split and gets the org's quintiles, which have no LocationID.
+func (c *RFMCalculator) CalculateQuintilesForLocation(ctx context.Context, orgID, locationID string) (models.RFMQuintiles, error) {
+	activities, err := c.storage.GetCustomerActivitiesByLocation(ctx, orgID, locationID)
+	if err != nil {
+		return models.RFMQuintiles{}, fmt.Errorf("failed to get customer activities: %w", err)
+	}
+
+	if len(activities) < 5 {
+		return c.storage.GetOrCalculateQuintiles(ctx, orgID)
+	}
+
+	recencyDays, frequencies, monetaryValues := activityValues(activities)
+	quintiles := c.quintilesFrom(orgID, recencyDays, frequencies, monetaryValues)
+	quintiles.LocationID = locationID
+	return quintiles, nil
+}
+
+// activityValues returns the days since each customer's last transaction,
+// their transaction counts and their spend
+func activityValues(activities []models.CustomerActivity) ([]int, []int, []float64) {
 	var recencyDays []int
 	var frequencies []int
 	var monetaryValues []float64
@@ -141,22 +301,63 @@ func (c *RFMCalculator) CalculateQuintilesForOrg(ctx context.Context, orgID stri
 		monetaryValues = append(monetaryValues, activity.TotalSpent)
 	}
 
-	return models.RFMQuintiles{
-		OrgID:              orgID,
-		RecencyQuintiles:   c.calculateIntQuintiles(recencyDays),
-		FrequencyQuintiles: c.calculateIntQuintiles(frequencies),
-		MonetaryQuintiles:  c.calculateFloatQuintiles(monetaryValues),
-		CalculatedAt:       time.Now(),
-	}, nil
+	return recencyDays, frequencies, monetaryValues
+}
+
+// sampleQuintilesForOrg streams the org's activities through a bounded
+// sample, so memory stays flat however many customers the org has
+func (c *RFMCalculator) sampleQuintilesForOrg(ctx context.Context, orgID string) (models.RFMQuintiles, error) {
+	sample := newQuintileSample(orgID, c.config.Sampling.SampleSize)
+	now := time.Now()
+
+	err := c.storage.EachCustomerActivity(ctx, orgID, c.config.Sampling.BatchSize, func(activity models.CustomerActivity) error {
+		sample.add(int(now.Sub(activity.LastTransaction).Hours()/24), activity.TotalTransactions, activity.TotalSpent)
+		return nil
+	})
+	if err != nil {
+		return models.RFMQuintiles{}, fmt.Errorf("failed to get customer activities: %w", err)
+	}
+
+	if sample.seen < 5 {
+		return c.getDefaultQuintiles(orgID), nil
+	}
+	if !sample.exact() {
+		log.Printf("Estimating quintiles for org %s from a sample of %d of its %d customers",
+			orgID, len(sample.recency), sample.seen)
+	}
+
+	return c.quintilesFrom(orgID, sample.recency, sample.frequency, sample.monetary), nil
+}
+
+// quintilesFrom splits the percentile dimensions of orgID's vertical by the
+// given values. The slices are sorted in place.
+func (c *RFMCalculator) quintilesFrom(orgID string, recencyDays, frequencies []int, monetaryValues []float64) models.RFMQuintiles {
+	// Fixed dimensions keep the vertical's thresholds
+	quintiles := c.getDefaultQuintiles(orgID)
+	preset := c.config.Verticals.Preset(orgID)
+	if preset.RecencyMethod != QuintileMethodFixed {
+		quintiles.RecencyQuintiles = c.calculateIntQuintiles(recencyDays, c.config.buckets())
+	}
+	if preset.FrequencyMethod != QuintileMethodFixed {
+		quintiles.FrequencyQuintiles = c.calculateIntQuintiles(frequencies, c.config.buckets())
+	}
+	if preset.MonetaryMethod != QuintileMethodFixed {
+		quintiles.MonetaryQuintiles = c.calculateFloatQuintiles(monetaryValues, c.config.buckets())
+	}
+
+	return quintiles
 }
 
-func (c *RFMCalculator) calculateIntQuintiles(values []int) []int {
+// calculateIntQuintiles returns the upper bound of each of buckets equal-sized
+// buckets of values. values is sorted in place.
+func (c *RFMCalculator) calculateIntQuintiles(values []int, buckets int) []int {
 	sort.Ints(values)
 	n := len(values)
-	quintiles := make([]int, 5)
+	quintiles := make([]int, buckets)
 	
-	for i := 0; i < 5; i++ {
-		percentile := float64(i+1) * 0.2
+	step := 1 / float64(buckets)
+	for i := 0; i < buckets; i++ {
+		percentile := float64(i+1) * step
 		index := int(math.Ceil(percentile*float64(n))) - 1
 		if index >= n {
 			index = n - 1
@@ -167,13 +368,16 @@ func (c *RFMCalculator) calculateIntQuintiles(values []int) []int {
 	return quintiles
 }
 
-func (c *RFMCalculator) calculateFloatQuintiles(values []float64) []float64 {
+// calculateFloatQuintiles returns the upper bound of each of buckets equal-sized
+// buckets of values. values is sorted in place.
+func (c *RFMCalculator) calculateFloatQuintiles(values []float64, buckets int) []float64 {
 	sort.Float64s(values)
 	n := len(values)
-	quintiles := make([]float64, 5)
+	quintiles := make([]float64, buckets)
 	
-	for i := 0; i < 5; i++ {
-		percentile := float64(i+1) * 0.2
+	step := 1 / float64(buckets)
+	for i := 0; i < buckets; i++ {
+		percentile := float64(i+1) * step
 		index := int(math.Ceil(percentile*float64(n))) - 1
 		if index >= n {
 			index = n - 1
@@ -184,12 +388,15 @@ func (c *RFMCalculator) calculateFloatQuintiles(values []float64) []float64 {
 	return quintiles
 }
 
+// getDefaultQuintiles returns the thresholds of orgID's vertical, copied so
+// callers cannot change the preset
 func (c *RFMCalculator) getDefaultQuintiles(orgID string) models.RFMQuintiles {
+	preset := c.config.Verticals.Preset(orgID)
 	return models.RFMQuintiles{
 		OrgID:              orgID,
-		RecencyQuintiles:   []int{7, 30, 90, 180, 365},
-		FrequencyQuintiles: []int{1, 2, 5, 10, 20},
-		MonetaryQuintiles:  []float64{10.0, 25.0, 50.0, 100.0, 250.0},
+		RecencyQuintiles:   append([]int(nil), preset.RecencyQuintiles...),
+		FrequencyQuintiles: append([]int(nil), preset.FrequencyQuintiles...),
+		MonetaryQuintiles:  append([]float64(nil), preset.MonetaryQuintiles...),
 		CalculatedAt:       time.Now(),
 	}
 }
\ No newline at end of file
@@ -2,6 +2,8 @@ package rfm
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"testing"
 	"time"
 
@@ -23,11 +25,27 @@ func (m *MockRFMStorage) GetOrCalculateQuintiles(ctx context.Context, orgID stri
 	return args.Get(0).(models.RFMQuintiles), args.Error(1)
 }
 
+func (m *MockRFMStorage) GetOrCalculateLocationQuintiles(ctx context.Context, orgID, locationID string) (models.RFMQuintiles, error) {
+	args := m.Called(ctx, orgID, locationID)
+	if args.Get(0) == nil {
+		return models.RFMQuintiles{}, args.Error(1)
+	}
+	return args.Get(0).(models.RFMQuintiles), args.Error(1)
+}
+
 func (m *MockRFMStorage) SaveRFMScore(ctx context.Context, score models.RFMScore) error {
 	args := m.Called(ctx, score)
 	return args.Error(0)
 }
 
+func (m *MockRFMStorage) GetRFMScoreByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.RFMScore, error) {
+	args := m.Called(ctx, orgID, locationID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMScore), args.Error(1)
+}
+
 func (m *MockRFMStorage) GetCustomerActivities(ctx context.Context, orgID string) ([]models.CustomerActivity, error) {
 	args := m.Called(ctx, orgID)
 	if args.Get(0) == nil {
@@ -36,6 +54,26 @@ func (m *MockRFMStorage) GetCustomerActivities(ctx context.Context, orgID string
 	return args.Get(0).([]models.CustomerActivity), args.Error(1)
 }
 
+func (m *MockRFMStorage) EachCustomerActivity(ctx context.Context, orgID string, batchSize int, fn func(models.CustomerActivity) error) error {
+	args := m.Called(ctx, orgID, batchSize)
+	if activities, ok := args.Get(0).([]models.CustomerActivity); ok {
+		for _, activity := range activities {
+			if err := fn(activity); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockRFMStorage) GetCustomerActivitiesByLocation(ctx context.Context, orgID, locationID string) ([]models.CustomerActivity, error) {
+	args := m.Called(ctx, orgID, locationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.CustomerActivity), args.Error(1)
+}
+
 // Test setup helper
 func setupTestCalculator() (*RFMCalculator, *MockRFMStorage) {
 	mockStorage := &MockRFMStorage{}
@@ -211,6 +249,220 @@ func TestCalculateRFMScore(t *testing.T) {
 	assert.NotEmpty(t, score.RFMSegment)
 }
 
+func TestCalculateRFMScore_ZeroTransactions(t *testing.T) {
+	calculator, _ := setupTestCalculator()
+	
+	now := time.Now()
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   now,
+		FirstTransaction:  now,
+		TotalTransactions: 0,
+		TotalSpent:        0.0,
+	}
+	
+	quintiles := calculator.getDefaultQuintiles("test_org")
+	
+	score := calculator.calculateRFMScore(activity, quintiles)
+	
+	// Assertions
+	assert.Equal(t, 0.0, score.AvgOrderValue)
+	assert.False(t, math.IsNaN(score.AvgOrderValue))
+	assert.False(t, math.IsInf(score.AvgOrderValue, 0))
+}
+
+func TestProcessCustomerTransaction_ZeroTransactionsStoresFiniteScore(t *testing.T) {
+	calculator, mockStorage := setupTestCalculator()
+	ctx := context.Background()
+
+	// Test data - a same-day customer with spend recorded but no counted
+	// transactions
+	now := time.Now()
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   now,
+		FirstTransaction:  now,
+		TotalTransactions: 0,
+		TotalSpent:        42.0,
+	}
+
+	// Setup expectations
+	mockStorage.On("GetOrCalculateQuintiles", ctx, "test_org").Return(calculator.getDefaultQuintiles("test_org"), nil)
+	mockStorage.On("SaveRFMScore", ctx, mock.AnythingOfType("models.RFMScore")).Return(nil)
+
+	// Process transaction
+	err := calculator.ProcessCustomerTransaction(ctx, activity)
+
+	// Assertions
+	assert.NoError(t, err)
+	score := mockStorage.Calls[1].Arguments.Get(1).(models.RFMScore)
+	for _, value := range []float64{score.AvgOrderValue, score.WeightedScore, score.TotalSpent} {
+		assert.False(t, math.IsNaN(value))
+		assert.False(t, math.IsInf(value, 0))
+	}
+	assert.Equal(t, 42.0, score.AvgOrderValue)
+	assert.Equal(t, 0, score.DaysSinceFirst)
+	assert.Equal(t, 0, score.DaysSinceLast)
+}
+
+func TestCalculateRFMScore_SingleTransactionAvgOrderValue(t *testing.T) {
+	calculator, _ := setupTestCalculator()
+
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   time.Now(),
+		FirstTransaction:  time.Now(),
+		TotalTransactions: 1,
+		TotalSpent:        18.5,
+	}
+
+	score := calculator.calculateRFMScore(activity, calculator.getDefaultQuintiles("test_org"))
+
+	// Assertions
+	assert.Equal(t, 18.5, score.AvgOrderValue)
+}
+
+func TestCalculateRFMScore_RefundLowersMonetaryScore(t *testing.T) {
+	calculator, _ := setupTestCalculator()
+	quintiles := models.RFMQuintiles{
+		OrgID:              "test_org",
+		RecencyQuintiles:   []int{7, 30, 90, 180, 365},
+		FrequencyQuintiles: []int{1, 2, 5, 10, 20},
+		MonetaryQuintiles:  []float64{10.0, 25.0, 50.0, 100.0, 250.0},
+	}
+
+	// Test data - the same customer before and after most of a purchase is
+	// refunded
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   time.Now().AddDate(0, 0, -2),
+		FirstTransaction:  time.Now().AddDate(0, 0, -60),
+		TotalTransactions: 4,
+		TotalSpent:        220.0,
+	}
+	refunded := activity
+	refunded.TotalSpent = 40.0
+
+	before := calculator.calculateRFMScore(activity, quintiles)
+	after := calculator.calculateRFMScore(refunded, quintiles)
+
+	// Assertions
+	assert.Equal(t, 4, before.MonetaryScore)
+	assert.Equal(t, 2, after.MonetaryScore)
+	assert.Less(t, after.WeightedScore, before.WeightedScore)
+}
+
+func TestCalculateRFMScore_SingleTransactionGetsGuardLabel(t *testing.T) {
+	calculator, _ := setupTestCalculator()
+	
+	// Test data - one large, recent order that would otherwise score 5/1/5
+	now := time.Now()
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   now.AddDate(0, 0, -1),
+		FirstTransaction:  now.AddDate(0, 0, -1),
+		TotalTransactions: 1,
+		TotalSpent:        400.0,
+	}
+	
+	quintiles := models.RFMQuintiles{
+		OrgID:              "test_org",
+		RecencyQuintiles:   []int{7, 30, 90, 180, 365},
+		FrequencyQuintiles: []int{1, 2, 5, 10, 20},
+		MonetaryQuintiles:  []float64{10.0, 25.0, 50.0, 100.0, 250.0},
+	}
+	
+	score := calculator.calculateRFMScore(activity, quintiles)
+	
+	// Assertions
+	assert.Equal(t, 5, score.MonetaryScore)
+	assert.Equal(t, "New Customers", score.RFMSegment)
+}
+
+func TestCalculateRFMScore_CustomGuardConfig(t *testing.T) {
+	calculator := NewRFMCalculatorWithConfig(&MockRFMStorage{}, CalculatorConfig{
+		MinTransactionsForSegment: 3,
+		InsufficientDataSegment:   "Insufficient Data",
+	})
+	
+	now := time.Now()
+	quintiles := calculator.getDefaultQuintiles("test_org")
+	
+	tests := []struct {
+		name         string
+		transactions int
+		expected     string
+	}{
+		{"below minimum", 2, "Insufficient Data"},
+		{"at minimum", 3, calculator.getRFMSegment(5, 2, 5)},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activity := models.CustomerActivity{
+				OrgID:             "test_org",
+				CustomerID:        "test_customer",
+				LastTransaction:   now,
+				FirstTransaction:  now.AddDate(0, 0, -10),
+				TotalTransactions: tt.transactions,
+				TotalSpent:        300.0,
+			}
+			
+			score := calculator.calculateRFMScore(activity, quintiles)
+			assert.Equal(t, tt.expected, score.RFMSegment)
+		})
+	}
+}
+
+func TestCalculateRFMScore_GuardDisabled(t *testing.T) {
+	calculator := NewRFMCalculatorWithConfig(&MockRFMStorage{}, CalculatorConfig{})
+	
+	now := time.Now()
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   now.AddDate(0, 0, -1),
+		FirstTransaction:  now.AddDate(0, 0, -1),
+		TotalTransactions: 1,
+		TotalSpent:        400.0,
+	}
+	quintiles := calculator.getDefaultQuintiles("test_org")
+	
+	score := calculator.calculateRFMScore(activity, quintiles)
+	
+	// Assertions
+	assert.Equal(t, calculator.getRFMSegment(score.RecencyScore, score.FrequencyScore, score.MonetaryScore), score.RFMSegment)
+}
+
+func TestCalculateRFMScore_FutureDatedTransaction(t *testing.T) {
+	calculator, _ := setupTestCalculator()
+	
+	// Test data - clock skew put both transactions days ahead of now
+	now := time.Now()
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   now.AddDate(0, 0, 3),
+		FirstTransaction:  now.AddDate(0, 0, 2),
+		TotalTransactions: 5,
+		TotalSpent:        100.0,
+	}
+	
+	quintiles := calculator.getDefaultQuintiles("test_org")
+	
+	score := calculator.calculateRFMScore(activity, quintiles)
+	
+	// Assertions
+	assert.Equal(t, 0, score.DaysSinceLast)
+	assert.Equal(t, 0, score.DaysSinceFirst)
+	assert.Equal(t, 5, score.RecencyScore)
+}
+
 // Test getRecencyScore
 func TestGetRecencyScore(t *testing.T) {
 	calculator, _ := setupTestCalculator()
@@ -228,6 +480,8 @@ func TestGetRecencyScore(t *testing.T) {
 		{"old", 200, 1},
 		{"very old", 400, 1},
 		{"extremely old", 500, 1},
+		{"future dated", -3, 5},
+		{"far future dated", -1000, 5},
 	}
 	
 	for _, tt := range tests {
@@ -454,12 +708,115 @@ func TestCalculateQuintilesForOrg_LessThan5Customers(t *testing.T) {
 	mockStorage.AssertExpectations(t)
 }
 
+// Test CalculateQuintilesForLocation
+func locationActivities(locationID string, spend ...float64) []models.CustomerActivity {
+	now := time.Now()
+	var activities []models.CustomerActivity
+	for i, spent := range spend {
+		activities = append(activities, models.CustomerActivity{
+			OrgID:             "test_org",
+			LocationID:        locationID,
+			CustomerID:        fmt.Sprintf("cust_%d", i+1),
+			LastTransaction:   now.AddDate(0, 0, -(i + 1)),
+			TotalTransactions: i + 1,
+			TotalSpent:        spent,
+		})
+	}
+	return activities
+}
+
+func TestCalculateQuintilesForLocation_LocationSpecific(t *testing.T) {
+	calculator, mockStorage := setupTestCalculator()
+	ctx := context.Background()
+
+	// Setup expectations - a kiosk whose customers spend little
+	mockStorage.On("GetCustomerActivitiesByLocation", ctx, "test_org", "kiosk").
+		Return(locationActivities("kiosk", 4.0, 6.0, 8.0, 10.0, 12.0), nil)
+
+	// Calculate quintiles
+	quintiles, err := calculator.CalculateQuintilesForLocation(ctx, "test_org", "kiosk")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", quintiles.OrgID)
+	assert.Equal(t, "kiosk", quintiles.LocationID)
+	if assert.Len(t, quintiles.MonetaryQuintiles, 5) {
+		assert.Equal(t, 4.0, quintiles.MonetaryQuintiles[0])
+		assert.Equal(t, 12.0, quintiles.MonetaryQuintiles[4])
+	}
+	mockStorage.AssertNotCalled(t, "GetOrCalculateQuintiles", mock.Anything, mock.Anything)
+}
+
+func TestCalculateQuintilesForLocation_FallsBackToOrg(t *testing.T) {
+	calculator, mockStorage := setupTestCalculator()
+	ctx := context.Background()
+
+	orgQuintiles := models.RFMQuintiles{
+		OrgID:              "test_org",
+		RecencyQuintiles:   []int{3, 10, 30, 60, 120},
+		FrequencyQuintiles: []int{1, 3, 6, 12, 24},
+		MonetaryQuintiles:  []float64{20.0, 60.0, 150.0, 400.0, 1000.0},
+	}
+
+	// Setup expectations - too few customers at the location to split
+	mockStorage.On("GetCustomerActivitiesByLocation", ctx, "test_org", "kiosk").
+		Return(locationActivities("kiosk", 4.0, 6.0), nil)
+	mockStorage.On("GetOrCalculateQuintiles", ctx, "test_org").Return(orgQuintiles, nil)
+
+	// Calculate quintiles
+	quintiles, err := calculator.CalculateQuintilesForLocation(ctx, "test_org", "kiosk")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, orgQuintiles, quintiles)
+	assert.Empty(t, quintiles.LocationID)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestProcessCustomerTransaction_PrefersLocationQuintiles(t *testing.T) {
+	mockStorage := &MockRFMStorage{}
+	config := DefaultCalculatorConfig()
+	config.LocationQuintiles = true
+	calculator := NewRFMCalculatorWithConfig(mockStorage, config)
+	ctx := context.Background()
+
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		LocationID:        "kiosk",
+		CustomerID:        "test_customer",
+		LastTransaction:   time.Now(),
+		FirstTransaction:  time.Now().AddDate(0, 0, -30),
+		TotalTransactions: 4,
+		TotalSpent:        30.0,
+	}
+
+	// Setup expectations - 30 spent tops the kiosk's quintiles
+	mockStorage.On("GetOrCalculateLocationQuintiles", ctx, "test_org", "kiosk").Return(models.RFMQuintiles{
+		OrgID:              "test_org",
+		LocationID:         "kiosk",
+		RecencyQuintiles:   []int{7, 30, 90, 180, 365},
+		FrequencyQuintiles: []int{1, 2, 5, 10, 20},
+		MonetaryQuintiles:  []float64{4.0, 6.0, 8.0, 10.0, 12.0},
+	}, nil)
+	mockStorage.On("SaveRFMScore", ctx, mock.AnythingOfType("models.RFMScore")).Return(nil)
+
+	// Process transaction
+	err := calculator.ProcessCustomerTransaction(ctx, activity)
+
+	// Assertions
+	assert.NoError(t, err)
+	mockStorage.AssertCalled(t, "SaveRFMScore", ctx, mock.MatchedBy(func(score models.RFMScore) bool {
+		return score.MonetaryScore == 5
+	}))
+	mockStorage.AssertNotCalled(t, "GetOrCalculateQuintiles", mock.Anything, mock.Anything)
+}
+
 // Test calculateIntQuintiles
 func TestCalculateIntQuintiles(t *testing.T) {
 	calculator, _ := setupTestCalculator()
 	
 	values := []int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}
-	quintiles := calculator.calculateIntQuintiles(values)
+	quintiles := calculator.calculateIntQuintiles(values, 5)
 	
 	// Assertions
 	assert.Len(t, quintiles, 5)
@@ -475,7 +832,7 @@ func TestCalculateFloatQuintiles(t *testing.T) {
 	calculator, _ := setupTestCalculator()
 	
 	values := []float64{1.0, 3.0, 5.0, 7.0, 9.0, 11.0, 13.0, 15.0, 17.0, 19.0}
-	quintiles := calculator.calculateFloatQuintiles(values)
+	quintiles := calculator.calculateFloatQuintiles(values, 5)
 	
 	// Assertions
 	assert.Len(t, quintiles, 5)
@@ -486,6 +843,159 @@ func TestCalculateFloatQuintiles(t *testing.T) {
 	assert.Equal(t, 19.0, quintiles[4]) // 100th percentile
 }
 
+// Test configurable bucket counts
+func setupBucketCalculator(buckets int) *RFMCalculator {
+	config := DefaultCalculatorConfig()
+	config.Buckets = buckets
+	return NewRFMCalculatorWithConfig(&MockRFMStorage{}, config)
+}
+
+func TestCalculateQuintiles_BucketCount(t *testing.T) {
+	calculator := setupBucketCalculator(3)
+
+	values := []int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}
+
+	// Assertions
+	assert.Equal(t, []int{7, 13, 19}, calculator.calculateIntQuintiles(values, 3))
+	deciles := calculator.calculateFloatQuintiles([]float64{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}, 10)
+	assert.Len(t, deciles, 10)
+	assert.Equal(t, 1.0, deciles[0])
+	assert.Equal(t, 19.0, deciles[9])
+}
+
+func TestScores_Terciles(t *testing.T) {
+	calculator := setupBucketCalculator(3)
+
+	recency := []int{30, 90, 365}
+	frequency := []int{2, 5, 10}
+	monetary := []float64{50, 100, 200}
+
+	// Assertions - each threshold is the top of its bucket
+	assert.Equal(t, 3, calculator.getRecencyScore(30, recency))
+	assert.Equal(t, 2, calculator.getRecencyScore(31, recency))
+	assert.Equal(t, 2, calculator.getRecencyScore(90, recency))
+	assert.Equal(t, 1, calculator.getRecencyScore(91, recency))
+	assert.Equal(t, 1, calculator.getRecencyScore(400, recency))
+
+	assert.Equal(t, 1, calculator.getFrequencyScore(4, frequency))
+	assert.Equal(t, 2, calculator.getFrequencyScore(5, frequency))
+	assert.Equal(t, 2, calculator.getFrequencyScore(9, frequency))
+	assert.Equal(t, 3, calculator.getFrequencyScore(10, frequency))
+
+	assert.Equal(t, 2, calculator.getMonetaryScore(199.99, monetary))
+	assert.Equal(t, 3, calculator.getMonetaryScore(200, monetary))
+}
+
+func TestScores_Deciles(t *testing.T) {
+	calculator := setupBucketCalculator(10)
+
+	recency := []int{3, 7, 14, 30, 60, 90, 180, 270, 365, 730}
+	frequency := []int{1, 2, 3, 4, 5, 6, 8, 10, 15, 20}
+
+	// Assertions
+	assert.Equal(t, 10, calculator.getRecencyScore(3, recency))
+	assert.Equal(t, 9, calculator.getRecencyScore(4, recency))
+	assert.Equal(t, 1, calculator.getRecencyScore(730, recency))
+	assert.Equal(t, 1, calculator.getRecencyScore(731, recency))
+
+	assert.Equal(t, 1, calculator.getFrequencyScore(1, frequency))
+	assert.Equal(t, 8, calculator.getFrequencyScore(14, frequency))
+	assert.Equal(t, 9, calculator.getFrequencyScore(15, frequency))
+	assert.Equal(t, 9, calculator.getFrequencyScore(19, frequency))
+	assert.Equal(t, 10, calculator.getFrequencyScore(20, frequency))
+
+	// Fixed quintile thresholds are rescaled onto deciles
+	quintiles := []int{1, 2, 5, 10, 20}
+	assert.Equal(t, 1, calculator.getFrequencyScore(1, quintiles))
+	assert.Equal(t, 6, calculator.getFrequencyScore(7, quintiles))
+	assert.Equal(t, 10, calculator.getFrequencyScore(25, quintiles))
+}
+
+func TestGetRFMSegment_NormalizesBucketCount(t *testing.T) {
+	tests := []struct {
+		name            string
+		buckets         int
+		r, f, m         int
+		expectedSegment string
+	}{
+		{"top tercile", 3, 3, 3, 3, "Champions"},
+		{"middle tercile", 3, 2, 2, 2, "New Customers"},
+		{"lapsed tercile", 3, 1, 3, 3, "Cannot Lose Them"},
+		{"bottom tercile", 3, 1, 1, 1, "Lost"},
+		{"top decile", 10, 10, 10, 10, "Champions"},
+		{"second decile", 10, 9, 9, 9, "Champions"},
+		{"eighth decile", 10, 8, 8, 8, "Loyal Customers"},
+		{"bottom deciles", 10, 2, 2, 2, "Lost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calculator := setupBucketCalculator(tt.buckets)
+			assert.Equal(t, tt.expectedSegment, calculator.getRFMSegment(tt.r, tt.f, tt.m))
+		})
+	}
+}
+
+func TestCalculateRFMScore_BucketCountSetsScoreRange(t *testing.T) {
+	for _, buckets := range []int{3, 10} {
+		t.Run(fmt.Sprintf("%d buckets", buckets), func(t *testing.T) {
+			mockStorage := &MockRFMStorage{}
+			config := DefaultCalculatorConfig()
+			config.Buckets = buckets
+			calculator := NewRFMCalculatorWithConfig(mockStorage, config)
+			ctx := context.Background()
+
+			// Customers spread evenly from recent, frequent and big spending
+			// to lapsed, occasional and small spending
+			now := time.Now()
+			activities := make([]models.CustomerActivity, 100)
+			for i := range activities {
+				activities[i] = models.CustomerActivity{
+					OrgID:             "test_org",
+					CustomerID:        fmt.Sprintf("cust_%d", i),
+					FirstTransaction:  now.AddDate(-1, 0, 0),
+					LastTransaction:   now.Add(-time.Duration(i)*24*time.Hour - 12*time.Hour),
+					TotalTransactions: 100 - i,
+					TotalSpent:        float64(100-i) * 10,
+				}
+			}
+
+			// Setup expectations
+			mockStorage.On("GetCustomerActivities", ctx, "test_org").Return(activities, nil)
+
+			quintiles, err := calculator.CalculateQuintilesForOrg(ctx, "test_org")
+			assert.NoError(t, err)
+			assert.Len(t, quintiles.RecencyQuintiles, buckets)
+
+			recency := map[int]bool{}
+			frequency := map[int]bool{}
+			monetary := map[int]bool{}
+			for _, activity := range activities {
+				score := calculator.calculateRFMScore(activity, quintiles)
+				recency[score.RecencyScore] = true
+				frequency[score.FrequencyScore] = true
+				monetary[score.MonetaryScore] = true
+				assert.NotEmpty(t, score.RFMSegment)
+			}
+
+			// Assertions - every score from 1 to buckets is used, and no other
+			for score := 1; score <= buckets; score++ {
+				assert.True(t, recency[score], "recency score %d", score)
+				assert.True(t, frequency[score], "frequency score %d", score)
+				assert.True(t, monetary[score], "monetary score %d", score)
+			}
+			assert.Len(t, recency, buckets)
+			assert.Len(t, frequency, buckets)
+			assert.Len(t, monetary, buckets)
+
+			// The best customer scores top in every dimension
+			best := calculator.calculateRFMScore(activities[0], quintiles)
+			assert.Equal(t, []int{buckets, buckets, buckets}, []int{best.RecencyScore, best.FrequencyScore, best.MonetaryScore})
+			assert.Equal(t, "Champions", best.RFMSegment)
+		})
+	}
+}
+
 // Test getDefaultQuintiles
 func TestGetDefaultQuintiles(t *testing.T) {
 	calculator, _ := setupTestCalculator()
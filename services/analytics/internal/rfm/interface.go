@@ -3,11 +3,55 @@ package rfm
 import (
 	"context"
 	"github.com/loyalty/analytics/internal/models"
+	"github.com/segmentio/kafka-go"
 )
 
 // RFMStorageInterface defines the interface for RFM storage operations
 type RFMStorageInterface interface {
 	GetOrCalculateQuintiles(ctx context.Context, orgID string) (models.RFMQuintiles, error)
+	GetOrCalculateLocationQuintiles(ctx context.Context, orgID, locationID string) (models.RFMQuintiles, error)
 	SaveRFMScore(ctx context.Context, score models.RFMScore) error
+	GetRFMScoreByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.RFMScore, error)
 	GetCustomerActivities(ctx context.Context, orgID string) ([]models.CustomerActivity, error)
-} 
\ No newline at end of file
+	EachCustomerActivity(ctx context.Context, orgID string, batchSize int, fn func(models.CustomerActivity) error) error
+	GetCustomerActivitiesByLocation(ctx context.Context, orgID, locationID string) ([]models.CustomerActivity, error)
+}
+
+// MongoStorageInterface defines the MongoDB operations RFMStorage depends on
+type MongoStorageInterface interface {
+	SaveRFMScore(ctx context.Context, score models.RFMScore) error
+	GetRFMScore(ctx context.Context, orgID, customerID string) (*models.RFMScore, error)
+	GetRFMScoreByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.RFMScore, error)
+	GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error)
+	SaveQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error
+	GetLocationQuintiles(ctx context.Context, orgID, locationID string) (*models.RFMQuintiles, error)
+	SaveLocationQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error
+	AppendQuintileHistory(ctx context.Context, quintiles models.RFMQuintiles) error
+	GetQuintileHistory(ctx context.Context, orgID string, limit int) ([]models.RFMQuintiles, error)
+	UpdateCustomerActivity(ctx context.Context, activity models.CustomerActivity) (*models.CustomerActivity, error)
+	RecordCustomerInteraction(ctx context.Context, activity models.CustomerActivity, countTransaction bool) (*models.CustomerActivity, error)
+	RecordCustomerRefund(ctx context.Context, activity models.CustomerActivity, reverseTransaction bool) (*models.CustomerActivity, error)
+	GetCustomerActivityByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.CustomerActivity, error)
+	GetCustomerActivities(ctx context.Context, orgID string) ([]models.CustomerActivity, error)
+	EachCustomerActivity(ctx context.Context, orgID string, batchSize int, fn func(models.CustomerActivity) error) error
+	GetRFMScores(ctx context.Context, orgID string, limit, offset int, sortBy string) ([]models.RFMScore, error)
+	GetRFMScoresBySegment(ctx context.Context, orgID, segment string) ([]models.RFMScore, error)
+	GetRFMScoresByLocation(ctx context.Context, orgID, locationID string) ([]models.RFMScore, error)
+	GetCustomerActivitiesByLocation(ctx context.Context, orgID, locationID string) ([]models.CustomerActivity, error)
+	GetSegmentHistory(ctx context.Context, orgID, customerID string) ([]models.SegmentTransition, error)
+}
+
+// RFMReaderInterface defines the RFM read operations exposed over the analytics API
+type RFMReaderInterface interface {
+	GetRFMScore(ctx context.Context, orgID, customerID string) (*models.RFMScore, error)
+	GetRFMScoresBySegment(ctx context.Context, orgID, segment string) ([]models.RFMScore, error)
+	GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error)
+	GetQuintileHistory(ctx context.Context, orgID string, limit int) ([]models.RFMQuintiles, error)
+	GetRFMScores(ctx context.Context, orgID string, limit, offset int, sortBy string) ([]models.RFMScore, error)
+	GetRFMScoresAbovePercentile(ctx context.Context, orgID string, percentile float64) ([]models.RFMScore, error)
+}
+
+// MessageWriter publishes messages to Kafka. *kafka.Writer satisfies it.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
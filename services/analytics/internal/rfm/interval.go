@@ -0,0 +1,41 @@
+package rfm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/loyalty/analytics/internal/orgconfig"
+)
+
+// MinRecalcInterval is the shortest quintile recalculation interval accepted.
+// Each recalculation reads every customer activity in the org, so anything
+// tighter would mostly add load.
+const MinRecalcInterval = time.Minute
+
+// ParseRecalcInterval parses how old an org's quintiles may get before they
+// are recalculated, as a Go duration such as "6h". It is an age rather than a
+// schedule: quintiles are recalculated when they are next read after it.
+func ParseRecalcInterval(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("recalculation interval is empty")
+	}
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid recalculation interval %q: %w", value, err)
+	}
+
+	if interval < MinRecalcInterval {
+		return 0, fmt.Errorf("recalculation interval %q is shorter than %s", value, MinRecalcInterval)
+	}
+
+	return interval, nil
+}
+
+// ParseOrgRecalcIntervals parses a comma-separated list of org=interval
+// pairs, such as "org_a=6h,org_b=24h"
+func ParseOrgRecalcIntervals(spec string) (map[string]time.Duration, error) {
+	return orgconfig.ParseOrgValues(spec, "interval", "interval", ParseRecalcInterval)
+}
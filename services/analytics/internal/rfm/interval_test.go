@@ -0,0 +1,69 @@
+package rfm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ParseRecalcInterval
+func TestParseRecalcInterval(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"6h", 6 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"168h", 7 * 24 * time.Hour},
+		{" 12h ", 12 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			interval, err := ParseRecalcInterval(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, interval)
+		})
+	}
+}
+
+func TestParseRecalcInterval_Invalid(t *testing.T) {
+	for _, value := range []string{"", "soon", "0 */6 * * *", "@daily", "@every 6h", "-6h", "30s"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := ParseRecalcInterval(value)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// Test ParseOrgRecalcIntervals
+func TestParseOrgRecalcIntervals(t *testing.T) {
+	intervals, err := ParseOrgRecalcIntervals("org_a=6h, org_b=24h,")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"org_a": 6 * time.Hour,
+		"org_b": 24 * time.Hour,
+	}, intervals)
+}
+
+func TestParseOrgRecalcIntervals_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"missing interval", "org_a"},
+		{"missing org", "=6h"},
+		{"invalid interval", "org_a=often"},
+		{"duplicate org", "org_a=6h,org_a=12h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOrgRecalcIntervals(tt.spec)
+			assert.Error(t, err)
+		})
+	}
+}
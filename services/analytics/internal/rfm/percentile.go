@@ -0,0 +1,42 @@
+package rfm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/loyalty/analytics/internal/models"
+)
+
+// AbovePercentile returns the scores whose composite score is above
+// percentile, highest first. A customer is included when fewer than
+// (100 - percentile)% of the set outscore them, so a percentile of 90 returns
+// the top 10%, plus any customers tied with the last one in.
+func AbovePercentile(scores []models.RFMScore, percentile float64) ([]models.RFMScore, error) {
+	if percentile < 0 || percentile >= 100 {
+		return nil, fmt.Errorf("percentile must be at least 0 and below 100, got %g", percentile)
+	}
+
+	ranked := make([]models.RFMScore, len(scores))
+	copy(ranked, scores)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].CompositeScore() != ranked[j].CompositeScore() {
+			return ranked[i].CompositeScore() > ranked[j].CompositeScore()
+		}
+		return ranked[i].CustomerID < ranked[j].CustomerID
+	})
+
+	cutoff := float64(len(ranked)) * (100 - percentile) / 100
+
+	// Customers tied on score share the rank of the first of them
+	outscoredBy := 0
+	for i := range ranked {
+		if i > 0 && ranked[i].CompositeScore() != ranked[i-1].CompositeScore() {
+			outscoredBy = i
+		}
+		if float64(outscoredBy) >= cutoff {
+			return ranked[:i], nil
+		}
+	}
+
+	return ranked, nil
+}
@@ -0,0 +1,92 @@
+package rfm
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func customerIDs(scores []models.RFMScore) []string {
+	ids := make([]string, len(scores))
+	for i, score := range scores {
+		ids[i] = score.CustomerID
+	}
+	return ids
+}
+
+// Test AbovePercentile
+func TestAbovePercentile_TopDecileOfSeededDistribution(t *testing.T) {
+	// One customer in each of 100 distinct RFM cells, in shuffled order
+	var scores []models.RFMScore
+	for r := 1; r <= 5; r++ {
+		for f := 1; f <= 5; f++ {
+			for m := 1; m <= 4; m++ {
+				scores = append(scores, models.RFMScore{
+					CustomerID:     fmt.Sprintf("cust_%d%d%d", r, f, m),
+					RecencyScore:   r,
+					FrequencyScore: f,
+					MonetaryScore:  m,
+				})
+			}
+		}
+	}
+	rng := rand.New(rand.NewSource(42))
+	rng.Shuffle(len(scores), func(i, j int) { scores[i], scores[j] = scores[j], scores[i] })
+
+	top, err := AbovePercentile(scores, 90)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"cust_554", "cust_553", "cust_552", "cust_551",
+		"cust_544", "cust_543", "cust_542", "cust_541",
+		"cust_534", "cust_533",
+	}, customerIDs(top))
+}
+
+func TestAbovePercentile_TiesAtCutoffAreIncluded(t *testing.T) {
+	scores := []models.RFMScore{
+		{CustomerID: "cust_a", RecencyScore: 5, FrequencyScore: 5, MonetaryScore: 5},
+		{CustomerID: "cust_b", RecencyScore: 4, FrequencyScore: 4, MonetaryScore: 4},
+		{CustomerID: "cust_c", RecencyScore: 4, FrequencyScore: 4, MonetaryScore: 4},
+		{CustomerID: "cust_d", RecencyScore: 3, FrequencyScore: 3, MonetaryScore: 3},
+		{CustomerID: "cust_e", RecencyScore: 1, FrequencyScore: 1, MonetaryScore: 1},
+	}
+
+	tests := []struct {
+		percentile float64
+		expected   []string
+	}{
+		{0, []string{"cust_a", "cust_b", "cust_c", "cust_d", "cust_e"}},
+		{50, []string{"cust_a", "cust_b", "cust_c"}},
+		{70, []string{"cust_a", "cust_b", "cust_c"}},
+		{90, []string{"cust_a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("p%g", tt.percentile), func(t *testing.T) {
+			top, err := AbovePercentile(scores, tt.percentile)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, customerIDs(top))
+		})
+	}
+}
+
+func TestAbovePercentile_InvalidPercentile(t *testing.T) {
+	for _, percentile := range []float64{-1, 100, 150} {
+		top, err := AbovePercentile(nil, percentile)
+		assert.Error(t, err)
+		assert.Nil(t, top)
+	}
+}
+
+func TestAbovePercentile_EmptySet(t *testing.T) {
+	top, err := AbovePercentile(nil, 90)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, top)
+}
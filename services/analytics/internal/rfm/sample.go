@@ -0,0 +1,65 @@
+package rfm
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// QuintileSampling bounds the memory used to calculate an org's quintiles
+type QuintileSampling struct {
+	// SampleSize is the most customers held while calculating quintiles.
+	// Orgs with more customers have their quintiles estimated from a uniform
+	// random sample of this many; smaller orgs are still calculated exactly.
+	// Zero loads every customer and calculates exactly.
+	SampleSize int
+	// BatchSize is how many customer activities are read from storage at a
+	// time when sampling. Zero uses the driver's default.
+	BatchSize int
+}
+
+// quintileSample is a reservoir of customers' recency, frequency and monetary
+// values. Until more than size customers are added it holds all of them.
+type quintileSample struct {
+	size      int
+	seen      int
+	rng       *rand.Rand
+	recency   []int
+	frequency []int
+	monetary  []float64
+}
+
+// newQuintileSample creates a reservoir of size customers for orgID. The
+// random source is seeded from the org ID so an org's estimate does not move
+// between recalculations over the same activities.
+func newQuintileSample(orgID string, size int) *quintileSample {
+	hash := fnv.New64a()
+	hash.Write([]byte(orgID))
+	return &quintileSample{
+		size: size,
+		rng:  rand.New(rand.NewSource(int64(hash.Sum64()))),
+	}
+}
+
+// add offers one customer to the reservoir. Once it is full, each new
+// customer replaces a random one with probability size/seen, which keeps
+// every customer seen equally likely to be held.
+func (s *quintileSample) add(recencyDays, frequency int, monetary float64) {
+	s.seen++
+	if len(s.recency) < s.size {
+		s.recency = append(s.recency, recencyDays)
+		s.frequency = append(s.frequency, frequency)
+		s.monetary = append(s.monetary, monetary)
+		return
+	}
+
+	if i := s.rng.Intn(s.seen); i < s.size {
+		s.recency[i] = recencyDays
+		s.frequency[i] = frequency
+		s.monetary[i] = monetary
+	}
+}
+
+// exact reports whether the reservoir holds every customer added
+func (s *quintileSample) exact() bool {
+	return s.seen <= s.size
+}
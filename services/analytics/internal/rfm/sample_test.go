@@ -0,0 +1,165 @@
+package rfm
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticActivities builds n customers with skewed spend and visit counts,
+// like a real org's long tail of one-off customers
+func syntheticActivities(n int) []models.CustomerActivity {
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+
+	activities := make([]models.CustomerActivity, n)
+	for i := range activities {
+		activities[i] = models.CustomerActivity{
+			OrgID:             "large_org",
+			LastTransaction:   now.Add(-time.Duration(rng.Intn(730*24)) * time.Hour),
+			TotalTransactions: 1 + int(rng.ExpFloat64()*8),
+			TotalSpent:        math.Round(math.Exp(3+rng.NormFloat64())*100) / 100,
+		}
+	}
+	return activities
+}
+
+// rankRange returns the fraction of sorted values below value and at or
+// below it, which bound the rank of a threshold that ties other values
+func rankRange[T int | float64](sorted []T, value T) (float64, float64) {
+	below := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= value })
+	atOrBelow := sort.Search(len(sorted), func(i int) bool { return sorted[i] > value })
+	n := float64(len(sorted))
+	return float64(below) / n, float64(atOrBelow) / n
+}
+
+func assertNearQuintiles[T int | float64](t *testing.T, dimension string, all []T, estimated []T) {
+	sorted := append([]T(nil), all...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	require.Len(t, estimated, 5)
+	// The top quintile is the sample's maximum, which says little about rank
+	for i := 0; i < 4; i++ {
+		target := float64(i+1) * 0.2
+		low, high := rankRange(sorted, estimated[i])
+		assert.True(t, low-0.02 <= target && target <= high+0.02,
+			"%s quintile %d at %v ranks %.3f-%.3f, want about %.1f", dimension, i+1, estimated[i], low, high, target)
+	}
+}
+
+// Test sampled quintiles
+func TestCalculateQuintilesForOrg_SampleCloseToExact(t *testing.T) {
+	ctx := context.Background()
+	activities := syntheticActivities(100000)
+
+	mockStorage := &MockRFMStorage{}
+	config := DefaultCalculatorConfig()
+	config.Sampling = QuintileSampling{SampleSize: 5000, BatchSize: 1000}
+	calculator := NewRFMCalculatorWithConfig(mockStorage, config)
+
+	// Setup expectations
+	mockStorage.On("EachCustomerActivity", ctx, "large_org", 1000).Return(activities, nil)
+
+	// Calculate quintiles
+	estimated, err := calculator.CalculateQuintilesForOrg(ctx, "large_org")
+	require.NoError(t, err)
+
+	// Assertions - each threshold should split the full population close to
+	// where the exact method does
+	now := time.Now()
+	var recencyDays, frequencies []int
+	var monetaryValues []float64
+	for _, activity := range activities {
+		recencyDays = append(recencyDays, int(now.Sub(activity.LastTransaction).Hours()/24))
+		frequencies = append(frequencies, activity.TotalTransactions)
+		monetaryValues = append(monetaryValues, activity.TotalSpent)
+	}
+	assertNearQuintiles(t, "recency", recencyDays, estimated.RecencyQuintiles)
+	assertNearQuintiles(t, "frequency", frequencies, estimated.FrequencyQuintiles)
+	assertNearQuintiles(t, "monetary", monetaryValues, estimated.MonetaryQuintiles)
+
+	mockStorage.AssertNotCalled(t, "GetCustomerActivities", ctx, "large_org")
+	mockStorage.AssertExpectations(t)
+}
+
+func TestCalculateQuintilesForOrg_SmallOrgSampledExactly(t *testing.T) {
+	ctx := context.Background()
+	activities := syntheticActivities(200)
+
+	exactStorage := &MockRFMStorage{}
+	exactStorage.On("GetCustomerActivities", ctx, "large_org").Return(activities, nil)
+	exact, err := NewRFMCalculator(exactStorage).CalculateQuintilesForOrg(ctx, "large_org")
+	require.NoError(t, err)
+
+	sampledStorage := &MockRFMStorage{}
+	config := DefaultCalculatorConfig()
+	config.Sampling = QuintileSampling{SampleSize: 1000}
+	sampledStorage.On("EachCustomerActivity", ctx, "large_org", 0).Return(activities, nil)
+	sampled, err := NewRFMCalculatorWithConfig(sampledStorage, config).CalculateQuintilesForOrg(ctx, "large_org")
+	require.NoError(t, err)
+
+	// Assertions - an org within the sample size is not estimated
+	assert.Equal(t, exact.RecencyQuintiles, sampled.RecencyQuintiles)
+	assert.Equal(t, exact.FrequencyQuintiles, sampled.FrequencyQuintiles)
+	assert.Equal(t, exact.MonetaryQuintiles, sampled.MonetaryQuintiles)
+}
+
+func TestCalculateQuintilesForOrg_SampleError(t *testing.T) {
+	ctx := context.Background()
+
+	mockStorage := &MockRFMStorage{}
+	config := DefaultCalculatorConfig()
+	config.Sampling = QuintileSampling{SampleSize: 1000}
+	calculator := NewRFMCalculatorWithConfig(mockStorage, config)
+
+	// Setup expectations
+	mockStorage.On("EachCustomerActivity", ctx, "test_org", 0).Return(nil, assert.AnError)
+
+	// Calculate quintiles
+	quintiles, err := calculator.CalculateQuintilesForOrg(ctx, "test_org")
+
+	// Assertions
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to get customer activities")
+	assert.Equal(t, models.RFMQuintiles{}, quintiles)
+}
+
+// Test quintileSample
+func TestQuintileSample_Bounded(t *testing.T) {
+	sample := newQuintileSample("test_org", 100)
+
+	for i := 0; i < 10000; i++ {
+		sample.add(i, i, float64(i))
+	}
+
+	// Assertions
+	assert.False(t, sample.exact())
+	assert.Equal(t, 10000, sample.seen)
+	assert.Len(t, sample.recency, 100)
+	assert.Len(t, sample.frequency, 100)
+	assert.Len(t, sample.monetary, 100)
+	// Rows are replaced together
+	for i := range sample.recency {
+		assert.Equal(t, sample.recency[i], sample.frequency[i])
+	}
+}
+
+func TestQuintileSample_SameOrgSameSample(t *testing.T) {
+	first := newQuintileSample("test_org", 10)
+	second := newQuintileSample("test_org", 10)
+
+	for i := 0; i < 1000; i++ {
+		first.add(i, i, float64(i))
+		second.add(i, i, float64(i))
+	}
+
+	// Assertions
+	assert.Equal(t, first.recency, second.recency)
+}
@@ -0,0 +1,62 @@
+package rfm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// EventTypeSegmentChanged is published when a customer moves between RFM
+// segments
+const EventTypeSegmentChanged = "segment.changed"
+
+// previousSegment returns the segment stored for the customer before this
+// recompute, or "" for a customer scored for the first time
+func (c *RFMCalculator) previousSegment(ctx context.Context, activity models.CustomerActivity) string {
+	previous, err := c.storage.GetRFMScoreByLocation(ctx, activity.OrgID, activity.LocationID, activity.CustomerID)
+	if err != nil || previous == nil {
+		return ""
+	}
+	return previous.RFMSegment
+}
+
+// publishSegmentChange writes a segment.changed event for score's customer.
+// The score is already saved, so a failed publish is logged rather than
+// failing the recompute.
+func (c *RFMCalculator) publishSegmentChange(ctx context.Context, oldSegment string, score models.RFMScore) {
+	event := models.BaseEvent{
+		EventID:    fmt.Sprintf("segment_changed_%s_%d", score.CustomerID, score.CalculatedAt.UnixNano()),
+		EventType:  EventTypeSegmentChanged,
+		OrgID:      score.OrgID,
+		LocationID: score.LocationID,
+		CustomerID: score.CustomerID,
+		Timestamp:  score.CalculatedAt,
+		Payload: map[string]interface{}{
+			"old_segment":     oldSegment,
+			"new_segment":     score.RFMSegment,
+			"recency_score":   score.RecencyScore,
+			"frequency_score": score.FrequencyScore,
+			"monetary_score":  score.MonetaryScore,
+		},
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal segment change for customer %s: %v", score.CustomerID, err)
+		return
+	}
+
+	message := kafka.Message{
+		Topic: fmt.Sprintf("%s.%s", score.OrgID, EventTypeSegmentChanged),
+		Key:   []byte(score.CustomerID),
+		Value: eventJSON,
+		Time:  score.CalculatedAt,
+	}
+	if err := c.config.SegmentWriter.WriteMessages(ctx, message); err != nil {
+		log.Printf("Failed to publish segment change for customer %s: %v", score.CustomerID, err)
+	}
+}
@@ -0,0 +1,135 @@
+package rfm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter captures the messages published by the calculator
+type recordingWriter struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (w *recordingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.messages = append(w.messages, msgs...)
+	return w.err
+}
+
+// Test setup helper - returns a calculator publishing segment changes, an
+// activity and the segment it scores into
+func setupSegmentCalculator() (*RFMCalculator, *MockRFMStorage, *recordingWriter, models.CustomerActivity, string) {
+	mockStorage := &MockRFMStorage{}
+	writer := &recordingWriter{}
+	config := DefaultCalculatorConfig()
+	config.SegmentWriter = writer
+	calculator := NewRFMCalculatorWithConfig(mockStorage, config)
+
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		LocationID:        "test_location",
+		CustomerID:        "test_customer",
+		LastTransaction:   time.Now().AddDate(0, 0, -5),
+		FirstTransaction:  time.Now().AddDate(0, 0, -30),
+		TotalTransactions: 10,
+		TotalSpent:        500.0,
+	}
+	quintiles := models.RFMQuintiles{
+		OrgID:              "test_org",
+		RecencyQuintiles:   []int{7, 30, 90, 180, 365},
+		FrequencyQuintiles: []int{1, 2, 5, 10, 20},
+		MonetaryQuintiles:  []float64{10.0, 25.0, 50.0, 100.0, 250.0},
+	}
+	mockStorage.On("GetOrCalculateQuintiles", mock.Anything, "test_org").Return(quintiles, nil)
+	mockStorage.On("SaveRFMScore", mock.Anything, mock.AnythingOfType("models.RFMScore")).Return(nil)
+
+	segment := calculator.calculateRFMScore(activity, quintiles).RFMSegment
+	return calculator, mockStorage, writer, activity, segment
+}
+
+// Test segment change events
+func TestProcessCustomerTransaction_SegmentChangePublishesOneEvent(t *testing.T) {
+	calculator, mockStorage, writer, activity, segment := setupSegmentCalculator()
+	ctx := context.Background()
+
+	// Setup expectations
+	previous := &models.RFMScore{OrgID: "test_org", CustomerID: "test_customer", RFMSegment: "At Risk"}
+	require.NotEqual(t, previous.RFMSegment, segment)
+	mockStorage.On("GetRFMScoreByLocation", ctx, "test_org", "test_location", "test_customer").Return(previous, nil)
+
+	// Process transaction
+	err := calculator.ProcessCustomerTransaction(ctx, activity)
+
+	// Assertions
+	assert.NoError(t, err)
+	require.Len(t, writer.messages, 1)
+	message := writer.messages[0]
+	assert.Equal(t, "test_org.segment.changed", message.Topic)
+	assert.Equal(t, "test_customer", string(message.Key))
+
+	var event models.BaseEvent
+	require.NoError(t, json.Unmarshal(message.Value, &event))
+	assert.Equal(t, EventTypeSegmentChanged, event.EventType)
+	assert.Equal(t, "test_customer", event.CustomerID)
+	assert.Equal(t, "At Risk", event.Payload["old_segment"])
+	assert.Equal(t, segment, event.Payload["new_segment"])
+	mockStorage.AssertExpectations(t)
+}
+
+func TestProcessCustomerTransaction_UnchangedSegmentPublishesNothing(t *testing.T) {
+	calculator, mockStorage, writer, activity, segment := setupSegmentCalculator()
+	ctx := context.Background()
+
+	// Setup expectations
+	previous := &models.RFMScore{OrgID: "test_org", CustomerID: "test_customer", RFMSegment: segment}
+	mockStorage.On("GetRFMScoreByLocation", ctx, "test_org", "test_location", "test_customer").Return(previous, nil)
+
+	// Process transaction
+	err := calculator.ProcessCustomerTransaction(ctx, activity)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, writer.messages)
+}
+
+func TestProcessCustomerTransaction_FirstScorePublishesNothing(t *testing.T) {
+	calculator, mockStorage, writer, activity, _ := setupSegmentCalculator()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockStorage.On("GetRFMScoreByLocation", ctx, "test_org", "test_location", "test_customer").Return(nil, errors.New("RFM score not found"))
+
+	// Process transaction
+	err := calculator.ProcessCustomerTransaction(ctx, activity)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, writer.messages)
+}
+
+func TestProcessCustomerTransaction_PublishFailureKeepsScore(t *testing.T) {
+	calculator, mockStorage, writer, activity, _ := setupSegmentCalculator()
+	writer.err = errors.New("broker unavailable")
+	ctx := context.Background()
+
+	// Setup expectations
+	previous := &models.RFMScore{OrgID: "test_org", CustomerID: "test_customer", RFMSegment: "At Risk"}
+	mockStorage.On("GetRFMScoreByLocation", ctx, "test_org", "test_location", "test_customer").Return(previous, nil)
+
+	// Process transaction
+	err := calculator.ProcessCustomerTransaction(ctx, activity)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, writer.messages, 1)
+	mockStorage.AssertCalled(t, "SaveRFMScore", ctx, mock.AnythingOfType("models.RFMScore"))
+}
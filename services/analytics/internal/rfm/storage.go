@@ -3,18 +3,70 @@ package rfm
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/loyalty/analytics/internal/models"
-	"github.com/loyalty/analytics/internal/storage"
 )
 
+// StorageConfig holds the tunable behaviour of RFMStorage
+type StorageConfig struct {
+	// QuintileSaveAttempts is how many times a freshly calculated set of
+	// quintiles is written before giving up
+	QuintileSaveAttempts int
+	// QuintileSaveBackoff is the delay between save attempts
+	QuintileSaveBackoff time.Duration
+	// RecalcInterval is how old an org's quintiles may get before the next
+	// read recalculates them. Zero means 24h.
+	RecalcInterval time.Duration
+	// OrgRecalcIntervals overrides RecalcInterval for individual orgs
+	OrgRecalcIntervals map[string]time.Duration
+	// Verticals selects the quintile methods used when recalculating each
+	// org's quintiles. Set it to the calculator's.
+	Verticals VerticalConfig
+	// Sampling bounds the memory used when recalculating quintiles. Set it
+	// to the calculator's.
+	Sampling QuintileSampling
+	// Buckets is how many thresholds are calculated per dimension. Set it to
+	// the calculator's.
+	Buckets int
+	// QuintileHistory keeps every calculation of an org's quintiles, not
+	// just the current one, so analysts can see how thresholds drift
+	QuintileHistory bool
+}
+
+func DefaultStorageConfig() StorageConfig {
+	return StorageConfig{
+		QuintileSaveAttempts: 3,
+		QuintileSaveBackoff:  100 * time.Millisecond,
+		RecalcInterval:       24 * time.Hour,
+	}
+}
+
+// recalcInterval returns the quintile recalculation interval for orgID
+func (c StorageConfig) recalcInterval(orgID string) time.Duration {
+	if interval, ok := c.OrgRecalcIntervals[orgID]; ok && interval > 0 {
+		return interval
+	}
+	if c.RecalcInterval > 0 {
+		return c.RecalcInterval
+	}
+	return 24 * time.Hour
+}
+
 type RFMStorage struct {
-	mongo *storage.MongoStorage
+	mongo  MongoStorageInterface
+	config StorageConfig
+	// now is swapped in tests to simulate quintiles ageing
+	now func() time.Time
+}
+
+func NewRFMStorage(mongo MongoStorageInterface) *RFMStorage {
+	return NewRFMStorageWithConfig(mongo, DefaultStorageConfig())
 }
 
-func NewRFMStorage(mongo *storage.MongoStorage) *RFMStorage {
-	return &RFMStorage{mongo: mongo}
+func NewRFMStorageWithConfig(mongo MongoStorageInterface, config StorageConfig) *RFMStorage {
+	return &RFMStorage{mongo: mongo, config: config, now: time.Now}
 }
 
 func (s *RFMStorage) SaveRFMScore(ctx context.Context, score models.RFMScore) error {
@@ -29,27 +81,29 @@ func (s *RFMStorage) GetRFMScoreByLocation(ctx context.Context, orgID, locationI
 	return s.mongo.GetRFMScoreByLocation(ctx, orgID, locationID, customerID)
 }
 
+// GetQuintiles returns the org's stored quintiles without recalculating them
+func (s *RFMStorage) GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error) {
+	return s.mongo.GetQuintiles(ctx, orgID)
+}
+
+// GetQuintileHistory returns up to limit of the org's past quintile
+// calculations, newest first. It is empty unless QuintileHistory is set.
+func (s *RFMStorage) GetQuintileHistory(ctx context.Context, orgID string, limit int) ([]models.RFMQuintiles, error) {
+	return s.mongo.GetQuintileHistory(ctx, orgID, limit)
+}
+
 func (s *RFMStorage) GetOrCalculateQuintiles(ctx context.Context, orgID string) (models.RFMQuintiles, error) {
 	quintiles, err := s.mongo.GetQuintiles(ctx, orgID)
 	if err != nil {
-		calculator := NewRFMCalculator(s)
-		newQuintiles, calcErr := calculator.CalculateQuintilesForOrg(ctx, orgID)
+		newQuintiles, calcErr := s.recalculateQuintiles(ctx, orgID)
 		if calcErr != nil {
 			return models.RFMQuintiles{}, fmt.Errorf("failed to calculate quintiles: %w", calcErr)
 		}
-		
-		if saveErr := s.mongo.SaveQuintiles(ctx, newQuintiles); saveErr != nil {
-			return newQuintiles, nil
-		}
-		
 		return newQuintiles, nil
 	}
 	
-	if time.Since(quintiles.CalculatedAt) > 24*time.Hour {
-		calculator := NewRFMCalculator(s)
-		newQuintiles, calcErr := calculator.CalculateQuintilesForOrg(ctx, orgID)
-		if calcErr == nil {
-			s.mongo.SaveQuintiles(ctx, newQuintiles)
+	if s.now().Sub(quintiles.CalculatedAt) >= s.config.recalcInterval(orgID) {
+		if newQuintiles, calcErr := s.recalculateQuintiles(ctx, orgID); calcErr == nil {
 			return newQuintiles, nil
 		}
 	}
@@ -57,14 +111,145 @@ func (s *RFMStorage) GetOrCalculateQuintiles(ctx context.Context, orgID string)
 	return *quintiles, nil
 }
 
-func (s *RFMStorage) UpdateCustomerActivity(ctx context.Context, activity models.CustomerActivity) error {
+// GetOrCalculateLocationQuintiles returns the quintiles of one of orgID's
+// locations, recalculated on the org's interval like its org quintiles. A
+// location too small to have its own gets the org's.
+func (s *RFMStorage) GetOrCalculateLocationQuintiles(ctx context.Context, orgID, locationID string) (models.RFMQuintiles, error) {
+	quintiles, err := s.mongo.GetLocationQuintiles(ctx, orgID, locationID)
+	if err == nil && s.now().Sub(quintiles.CalculatedAt) < s.config.recalcInterval(orgID) {
+		return *quintiles, nil
+	}
+
+	newQuintiles, calcErr := s.recalculateLocationQuintiles(ctx, orgID, locationID)
+	if calcErr != nil {
+		// Stale quintiles are still better than none
+		if err == nil {
+			return *quintiles, nil
+		}
+		return models.RFMQuintiles{}, fmt.Errorf("failed to calculate quintiles: %w", calcErr)
+	}
+	return newQuintiles, nil
+}
+
+// calculator returns a calculator with the quintile settings of s
+func (s *RFMStorage) calculator() *RFMCalculator {
+	calculatorConfig := DefaultCalculatorConfig()
+	calculatorConfig.Verticals = s.config.Verticals
+	calculatorConfig.Sampling = s.config.Sampling
+	calculatorConfig.Buckets = s.config.Buckets
+	return NewRFMCalculatorWithConfig(s, calculatorConfig)
+}
+
+// recalculateQuintiles calculates and saves fresh quintiles for orgID
+func (s *RFMStorage) recalculateQuintiles(ctx context.Context, orgID string) (models.RFMQuintiles, error) {
+	quintiles, err := s.calculator().CalculateQuintilesForOrg(ctx, orgID)
+	if err != nil {
+		return models.RFMQuintiles{}, err
+	}
+	quintiles.CalculatedAt = s.now()
+
+	if saveErr := s.saveQuintiles(ctx, quintiles); saveErr != nil {
+		log.Printf("Failed to save quintiles for org %s: %v", orgID, saveErr)
+	} else if s.config.QuintileHistory {
+		if historyErr := s.mongo.AppendQuintileHistory(ctx, quintiles); historyErr != nil {
+			log.Printf("Failed to record quintile history for org %s: %v", orgID, historyErr)
+		}
+	}
+	return quintiles, nil
+}
+
+// recalculateLocationQuintiles calculates and saves fresh quintiles for one
+// of orgID's locations. The org quintiles a small location falls back to are
+// returned as they are, since they are saved with the org.
+func (s *RFMStorage) recalculateLocationQuintiles(ctx context.Context, orgID, locationID string) (models.RFMQuintiles, error) {
+	quintiles, err := s.calculator().CalculateQuintilesForLocation(ctx, orgID, locationID)
+	if err != nil {
+		return models.RFMQuintiles{}, err
+	}
+	if quintiles.LocationID == "" {
+		return quintiles, nil
+	}
+	quintiles.CalculatedAt = s.now()
+
+	if saveErr := s.saveQuintiles(ctx, quintiles); saveErr != nil {
+		log.Printf("Failed to save quintiles for location %s of org %s: %v", locationID, orgID, saveErr)
+	}
+	return quintiles, nil
+}
+
+// saveQuintiles persists freshly calculated quintiles, retrying transient
+// failures. Saving is an upsert keyed on org, or org and location for
+// location quintiles, so retries are idempotent.
+// Callers log a failed save rather than failing since the quintiles are still
+// usable for the current calculation.
+func (s *RFMStorage) saveQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error {
+	attempts := s.config.QuintileSaveAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	save := s.mongo.SaveQuintiles
+	if quintiles.LocationID != "" {
+		save = s.mongo.SaveLocationQuintiles
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = save(ctx, quintiles); err == nil {
+			return nil
+		}
+
+		if attempt < attempts {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.config.QuintileSaveBackoff):
+			}
+		}
+	}
+
+	return fmt.Errorf("failed to save quintiles after %d attempts: %w", attempts, err)
+}
+
+func (s *RFMStorage) UpdateCustomerActivity(ctx context.Context, activity models.CustomerActivity) (*models.CustomerActivity, error) {
 	return s.mongo.UpdateCustomerActivity(ctx, activity)
 }
 
+func (s *RFMStorage) RecordCustomerInteraction(ctx context.Context, activity models.CustomerActivity, countTransaction bool) (*models.CustomerActivity, error) {
+	return s.mongo.RecordCustomerInteraction(ctx, activity, countTransaction)
+}
+
+func (s *RFMStorage) RecordCustomerRefund(ctx context.Context, activity models.CustomerActivity, reverseTransaction bool) (*models.CustomerActivity, error) {
+	return s.mongo.RecordCustomerRefund(ctx, activity, reverseTransaction)
+}
+
+func (s *RFMStorage) GetCustomerActivityByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.CustomerActivity, error) {
+	return s.mongo.GetCustomerActivityByLocation(ctx, orgID, locationID, customerID)
+}
+
 func (s *RFMStorage) GetCustomerActivities(ctx context.Context, orgID string) ([]models.CustomerActivity, error) {
 	return s.mongo.GetCustomerActivities(ctx, orgID)
 }
 
+func (s *RFMStorage) EachCustomerActivity(ctx context.Context, orgID string, batchSize int, fn func(models.CustomerActivity) error) error {
+	return s.mongo.EachCustomerActivity(ctx, orgID, batchSize, fn)
+}
+
+func (s *RFMStorage) GetRFMScores(ctx context.Context, orgID string, limit, offset int, sortBy string) ([]models.RFMScore, error) {
+	return s.mongo.GetRFMScores(ctx, orgID, limit, offset, sortBy)
+}
+
+// GetRFMScoresAbovePercentile returns the org's customers whose composite
+// score is above percentile, highest first
+func (s *RFMStorage) GetRFMScoresAbovePercentile(ctx context.Context, orgID string, percentile float64) ([]models.RFMScore, error) {
+	// A zero limit loads every score for the org
+	scores, err := s.mongo.GetRFMScores(ctx, orgID, 0, 0, models.RFMSortComposite)
+	if err != nil {
+		return nil, err
+	}
+	return AbovePercentile(scores, percentile)
+}
+
 func (s *RFMStorage) GetRFMScoresBySegment(ctx context.Context, orgID, segment string) ([]models.RFMScore, error) {
 	return s.mongo.GetRFMScoresBySegment(ctx, orgID, segment)
 }
@@ -0,0 +1,514 @@
+package rfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMongoStorage is a mock implementation of the MongoDB storage
+type MockMongoStorage struct {
+	mock.Mock
+}
+
+func (m *MockMongoStorage) SaveRFMScore(ctx context.Context, score models.RFMScore) error {
+	args := m.Called(ctx, score)
+	return args.Error(0)
+}
+
+func (m *MockMongoStorage) GetRFMScore(ctx context.Context, orgID, customerID string) (*models.RFMScore, error) {
+	args := m.Called(ctx, orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMScore), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetRFMScoreByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.RFMScore, error) {
+	args := m.Called(ctx, orgID, locationID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMScore), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMQuintiles), args.Error(1)
+}
+
+func (m *MockMongoStorage) SaveQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error {
+	args := m.Called(ctx, quintiles)
+	return args.Error(0)
+}
+
+func (m *MockMongoStorage) AppendQuintileHistory(ctx context.Context, quintiles models.RFMQuintiles) error {
+	args := m.Called(ctx, quintiles)
+	return args.Error(0)
+}
+
+func (m *MockMongoStorage) GetQuintileHistory(ctx context.Context, orgID string, limit int) ([]models.RFMQuintiles, error) {
+	args := m.Called(ctx, orgID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMQuintiles), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetLocationQuintiles(ctx context.Context, orgID, locationID string) (*models.RFMQuintiles, error) {
+	args := m.Called(ctx, orgID, locationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RFMQuintiles), args.Error(1)
+}
+
+func (m *MockMongoStorage) SaveLocationQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error {
+	args := m.Called(ctx, quintiles)
+	return args.Error(0)
+}
+
+func (m *MockMongoStorage) UpdateCustomerActivity(ctx context.Context, activity models.CustomerActivity) (*models.CustomerActivity, error) {
+	args := m.Called(ctx, activity)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CustomerActivity), args.Error(1)
+}
+
+func (m *MockMongoStorage) RecordCustomerInteraction(ctx context.Context, activity models.CustomerActivity, countTransaction bool) (*models.CustomerActivity, error) {
+	args := m.Called(ctx, activity, countTransaction)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CustomerActivity), args.Error(1)
+}
+
+func (m *MockMongoStorage) RecordCustomerRefund(ctx context.Context, activity models.CustomerActivity, reverseTransaction bool) (*models.CustomerActivity, error) {
+	args := m.Called(ctx, activity, reverseTransaction)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CustomerActivity), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetCustomerActivityByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.CustomerActivity, error) {
+	args := m.Called(ctx, orgID, locationID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CustomerActivity), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetCustomerActivities(ctx context.Context, orgID string) ([]models.CustomerActivity, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.CustomerActivity), args.Error(1)
+}
+
+func (m *MockMongoStorage) EachCustomerActivity(ctx context.Context, orgID string, batchSize int, fn func(models.CustomerActivity) error) error {
+	args := m.Called(ctx, orgID, batchSize)
+	if activities, ok := args.Get(0).([]models.CustomerActivity); ok {
+		for _, activity := range activities {
+			if err := fn(activity); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockMongoStorage) GetRFMScores(ctx context.Context, orgID string, limit, offset int, sortBy string) ([]models.RFMScore, error) {
+	args := m.Called(ctx, orgID, limit, offset, sortBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMScore), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetRFMScoresBySegment(ctx context.Context, orgID, segment string) ([]models.RFMScore, error) {
+	args := m.Called(ctx, orgID, segment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMScore), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetRFMScoresByLocation(ctx context.Context, orgID, locationID string) ([]models.RFMScore, error) {
+	args := m.Called(ctx, orgID, locationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.RFMScore), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetCustomerActivitiesByLocation(ctx context.Context, orgID, locationID string) ([]models.CustomerActivity, error) {
+	args := m.Called(ctx, orgID, locationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.CustomerActivity), args.Error(1)
+}
+
+func (m *MockMongoStorage) GetSegmentHistory(ctx context.Context, orgID, customerID string) ([]models.SegmentTransition, error) {
+	args := m.Called(ctx, orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.SegmentTransition), args.Error(1)
+}
+
+// Test setup helper
+func setupTestStorage(attempts int) (*RFMStorage, *MockMongoStorage) {
+	mockMongo := &MockMongoStorage{}
+	rfmStorage := NewRFMStorageWithConfig(mockMongo, StorageConfig{QuintileSaveAttempts: attempts})
+	return rfmStorage, mockMongo
+}
+
+// Test GetOrCalculateQuintiles
+func TestGetOrCalculateQuintiles_RetriesFailedSave(t *testing.T) {
+	rfmStorage, mockMongo := setupTestStorage(3)
+	ctx := context.Background()
+
+	// Setup expectations
+	mockMongo.On("GetQuintiles", ctx, "test_org").Return(nil, errors.New("not found"))
+	mockMongo.On("GetCustomerActivities", ctx, "test_org").Return([]models.CustomerActivity{}, nil)
+	mockMongo.On("SaveQuintiles", ctx, mock.AnythingOfType("models.RFMQuintiles")).Return(errors.New("connection reset")).Once()
+	mockMongo.On("SaveQuintiles", ctx, mock.AnythingOfType("models.RFMQuintiles")).Return(nil).Once()
+
+	// Test
+	quintiles, err := rfmStorage.GetOrCalculateQuintiles(ctx, "test_org")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", quintiles.OrgID)
+	mockMongo.AssertNumberOfCalls(t, "SaveQuintiles", 2)
+	mockMongo.AssertExpectations(t)
+}
+
+func TestGetOrCalculateQuintiles_SaveFailureStillReturnsQuintiles(t *testing.T) {
+	rfmStorage, mockMongo := setupTestStorage(2)
+	ctx := context.Background()
+
+	// Setup expectations
+	mockMongo.On("GetQuintiles", ctx, "test_org").Return(nil, errors.New("not found"))
+	mockMongo.On("GetCustomerActivities", ctx, "test_org").Return([]models.CustomerActivity{}, nil)
+	mockMongo.On("SaveQuintiles", ctx, mock.AnythingOfType("models.RFMQuintiles")).Return(errors.New("connection reset"))
+
+	// Test
+	quintiles, err := rfmStorage.GetOrCalculateQuintiles(ctx, "test_org")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", quintiles.OrgID)
+	mockMongo.AssertNumberOfCalls(t, "SaveQuintiles", 2)
+}
+
+// Test GetOrCalculateLocationQuintiles
+func TestGetOrCalculateLocationQuintiles_SavesLocationQuintiles(t *testing.T) {
+	rfmStorage, mockMongo := setupTestStorage(1)
+	ctx := context.Background()
+
+	now := time.Now()
+	var activities []models.CustomerActivity
+	for i := 1; i <= 5; i++ {
+		activities = append(activities, models.CustomerActivity{
+			OrgID: "test_org", LocationID: "flagship", LastTransaction: now, TotalTransactions: i, TotalSpent: float64(i * 100),
+		})
+	}
+
+	// Setup expectations
+	mockMongo.On("GetLocationQuintiles", ctx, "test_org", "flagship").Return(nil, errors.New("not found"))
+	mockMongo.On("GetCustomerActivitiesByLocation", ctx, "test_org", "flagship").Return(activities, nil)
+	mockMongo.On("SaveLocationQuintiles", ctx, mock.AnythingOfType("models.RFMQuintiles")).Return(nil)
+
+	// Test
+	quintiles, err := rfmStorage.GetOrCalculateLocationQuintiles(ctx, "test_org", "flagship")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "flagship", quintiles.LocationID)
+	if assert.Len(t, quintiles.MonetaryQuintiles, 5) {
+		assert.Equal(t, 500.0, quintiles.MonetaryQuintiles[4])
+	}
+	mockMongo.AssertCalled(t, "SaveLocationQuintiles", ctx, mock.MatchedBy(func(saved models.RFMQuintiles) bool {
+		return saved.OrgID == "test_org" && saved.LocationID == "flagship"
+	}))
+	mockMongo.AssertNotCalled(t, "SaveQuintiles", mock.Anything, mock.Anything)
+}
+
+func TestGetOrCalculateLocationQuintiles_SmallLocationUsesOrgQuintiles(t *testing.T) {
+	rfmStorage, mockMongo := setupTestStorage(1)
+	ctx := context.Background()
+
+	orgQuintiles := &models.RFMQuintiles{
+		OrgID:             "test_org",
+		MonetaryQuintiles: []float64{20, 60, 150, 400, 1000},
+		CalculatedAt:      time.Now(),
+	}
+
+	// Setup expectations
+	mockMongo.On("GetLocationQuintiles", ctx, "test_org", "kiosk").Return(nil, errors.New("not found"))
+	mockMongo.On("GetCustomerActivitiesByLocation", ctx, "test_org", "kiosk").Return([]models.CustomerActivity{
+		{OrgID: "test_org", LocationID: "kiosk", TotalTransactions: 1, TotalSpent: 5},
+	}, nil)
+	mockMongo.On("GetQuintiles", ctx, "test_org").Return(orgQuintiles, nil)
+
+	// Test
+	quintiles, err := rfmStorage.GetOrCalculateLocationQuintiles(ctx, "test_org", "kiosk")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, *orgQuintiles, quintiles)
+	mockMongo.AssertNotCalled(t, "SaveLocationQuintiles", mock.Anything, mock.Anything)
+	mockMongo.AssertNotCalled(t, "SaveQuintiles", mock.Anything, mock.Anything)
+}
+
+func TestGetOrCalculateLocationQuintiles_FreshStoredQuintiles(t *testing.T) {
+	rfmStorage, mockMongo := setupTestStorage(1)
+	ctx := context.Background()
+
+	stored := &models.RFMQuintiles{OrgID: "test_org", LocationID: "flagship", CalculatedAt: time.Now()}
+
+	// Setup expectations
+	mockMongo.On("GetLocationQuintiles", ctx, "test_org", "flagship").Return(stored, nil)
+
+	// Test
+	quintiles, err := rfmStorage.GetOrCalculateLocationQuintiles(ctx, "test_org", "flagship")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, *stored, quintiles)
+	mockMongo.AssertNotCalled(t, "GetCustomerActivitiesByLocation", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSaveQuintiles_ReturnsErrorAfterAllAttempts(t *testing.T) {
+	rfmStorage, mockMongo := setupTestStorage(3)
+	ctx := context.Background()
+
+	// Setup expectations
+	mockMongo.On("SaveQuintiles", ctx, mock.AnythingOfType("models.RFMQuintiles")).Return(errors.New("connection reset"))
+
+	// Test
+	err := rfmStorage.saveQuintiles(ctx, models.RFMQuintiles{OrgID: "test_org"})
+
+	// Assertions
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "after 3 attempts")
+	mockMongo.AssertNumberOfCalls(t, "SaveQuintiles", 3)
+}
+
+// quintileStore keeps the last saved quintiles per org so recalculations can
+// be counted over a simulated time window
+type quintileStore struct {
+	MockMongoStorage
+	saved   map[string]models.RFMQuintiles
+	saves   map[string]int
+	history map[string][]models.RFMQuintiles
+}
+
+func newQuintileStore() *quintileStore {
+	return &quintileStore{
+		saved:   make(map[string]models.RFMQuintiles),
+		saves:   make(map[string]int),
+		history: make(map[string][]models.RFMQuintiles),
+	}
+}
+
+func (q *quintileStore) GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error) {
+	quintiles, ok := q.saved[orgID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &quintiles, nil
+}
+
+func (q *quintileStore) SaveQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error {
+	q.saved[quintiles.OrgID] = quintiles
+	q.saves[quintiles.OrgID]++
+	return nil
+}
+
+func (q *quintileStore) AppendQuintileHistory(ctx context.Context, quintiles models.RFMQuintiles) error {
+	q.history[quintiles.OrgID] = append(q.history[quintiles.OrgID], quintiles)
+	return nil
+}
+
+func (q *quintileStore) GetCustomerActivities(ctx context.Context, orgID string) ([]models.CustomerActivity, error) {
+	return []models.CustomerActivity{}, nil
+}
+
+func TestGetOrCalculateQuintiles_PerOrgRecalcInterval(t *testing.T) {
+	store := newQuintileStore()
+	config := DefaultStorageConfig()
+	config.OrgRecalcIntervals = map[string]time.Duration{"org_6h": 6 * time.Hour}
+	rfmStorage := NewRFMStorageWithConfig(store, config)
+	ctx := context.Background()
+
+	// Simulate two days of hourly reads for both orgs
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for hour := 0; hour < 48; hour++ {
+		now := start.Add(time.Duration(hour) * time.Hour)
+		rfmStorage.now = func() time.Time { return now }
+
+		for _, orgID := range []string{"org_6h", "org_24h"} {
+			_, err := rfmStorage.GetOrCalculateQuintiles(ctx, orgID)
+			assert.NoError(t, err)
+		}
+	}
+
+	// Assertions - the first read calculates, then each interval recalculates
+	assert.Equal(t, 8, store.saves["org_6h"])
+	assert.Equal(t, 2, store.saves["org_24h"])
+	assert.Equal(t, start.Add(42*time.Hour), store.saved["org_6h"].CalculatedAt)
+	assert.Equal(t, start.Add(24*time.Hour), store.saved["org_24h"].CalculatedAt)
+}
+
+func TestGetOrCalculateQuintiles_AppendsHistory(t *testing.T) {
+	store := newQuintileStore()
+	config := DefaultStorageConfig()
+	config.QuintileHistory = true
+	rfmStorage := NewRFMStorageWithConfig(store, config)
+	ctx := context.Background()
+
+	// Recalculate on each of three days
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day := 0; day < 3; day++ {
+		now := start.AddDate(0, 0, day)
+		rfmStorage.now = func() time.Time { return now }
+
+		_, err := rfmStorage.GetOrCalculateQuintiles(ctx, "test_org")
+		assert.NoError(t, err)
+	}
+
+	// Assertions - the current quintiles are overwritten, the history kept
+	assert.Equal(t, 3, store.saves["test_org"])
+	assert.Equal(t, start.AddDate(0, 0, 2), store.saved["test_org"].CalculatedAt)
+	if assert.Len(t, store.history["test_org"], 3) {
+		for day, quintiles := range store.history["test_org"] {
+			assert.Equal(t, start.AddDate(0, 0, day), quintiles.CalculatedAt)
+		}
+	}
+}
+
+func TestGetOrCalculateQuintiles_HistoryDisabled(t *testing.T) {
+	rfmStorage, mockMongo := setupTestStorage(1)
+	ctx := context.Background()
+
+	// Setup expectations
+	mockMongo.On("GetQuintiles", ctx, "test_org").Return(nil, errors.New("not found"))
+	mockMongo.On("GetCustomerActivities", ctx, "test_org").Return([]models.CustomerActivity{}, nil)
+	mockMongo.On("SaveQuintiles", ctx, mock.AnythingOfType("models.RFMQuintiles")).Return(nil)
+
+	// Test
+	_, err := rfmStorage.GetOrCalculateQuintiles(ctx, "test_org")
+
+	// Assertions
+	assert.NoError(t, err)
+	mockMongo.AssertNotCalled(t, "AppendQuintileHistory", mock.Anything, mock.Anything)
+}
+
+func TestStorageConfig_RecalcInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   StorageConfig
+		expected time.Duration
+	}{
+		{"org override", StorageConfig{RecalcInterval: 12 * time.Hour, OrgRecalcIntervals: map[string]time.Duration{"test_org": time.Hour}}, time.Hour},
+		{"default interval", StorageConfig{RecalcInterval: 12 * time.Hour}, 12 * time.Hour},
+		{"zero config keeps 24h", StorageConfig{}, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.config.recalcInterval("test_org"))
+		})
+	}
+}
+
+// Test GetRFMScoresAbovePercentile
+func TestGetRFMScoresAbovePercentile_LoadsAllScores(t *testing.T) {
+	mockMongo := &MockMongoStorage{}
+	storage := NewRFMStorage(mockMongo)
+
+	scores := make([]models.RFMScore, 10)
+	for i := range scores {
+		scores[i] = models.RFMScore{CustomerID: fmt.Sprintf("cust_%d", i), RecencyScore: i%5 + 1, FrequencyScore: 3, MonetaryScore: 3}
+	}
+	scores[4].MonetaryScore = 5
+
+	// Setup expectations
+	mockMongo.On("GetRFMScores", mock.Anything, "test_org", 0, 0, models.RFMSortComposite).Return(scores, nil)
+
+	top, err := storage.GetRFMScoresAbovePercentile(context.Background(), "test_org", 90)
+
+	// Assertions
+	assert.NoError(t, err)
+	if assert.Len(t, top, 1) {
+		assert.Equal(t, "cust_4", top[0].CustomerID)
+	}
+	mockMongo.AssertExpectations(t)
+}
+
+// scoreStore keeps saved RFM scores keyed by org, location and customer, the
+// same key MongoDB upserts on
+type scoreStore struct {
+	MockMongoStorage
+	scores map[string]models.RFMScore
+}
+
+func (s *scoreStore) SaveRFMScore(ctx context.Context, score models.RFMScore) error {
+	s.scores[score.OrgID+"/"+score.LocationID+"/"+score.CustomerID] = score
+	return nil
+}
+
+func (s *scoreStore) GetRFMScoreByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.RFMScore, error) {
+	score, ok := s.scores[orgID+"/"+locationID+"/"+customerID]
+	if !ok {
+		return nil, errors.New("RFM score not found for location")
+	}
+	return &score, nil
+}
+
+func TestProcessCustomerTransaction_PreservesLocation(t *testing.T) {
+	store := &scoreStore{scores: make(map[string]models.RFMScore)}
+	store.On("GetQuintiles", mock.Anything, "test_org").Return(&models.RFMQuintiles{OrgID: "test_org", CalculatedAt: time.Now()}, nil)
+	rfmStorage := NewRFMStorage(store)
+	calculator := NewRFMCalculator(rfmStorage)
+	ctx := context.Background()
+
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		LocationID:        "store_downtown",
+		CustomerID:        "test_customer",
+		FirstTransaction:  time.Now().AddDate(0, 0, -30),
+		LastTransaction:   time.Now(),
+		TotalTransactions: 3,
+		TotalSpent:        120.0,
+	}
+
+	// Test
+	err := calculator.ProcessCustomerTransaction(ctx, activity)
+	assert.NoError(t, err)
+
+	score, err := rfmStorage.GetRFMScoreByLocation(ctx, "test_org", "store_downtown", "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "store_downtown", score.LocationID)
+	assert.Equal(t, 3, score.TotalTransactions)
+
+	_, err = rfmStorage.GetRFMScoreByLocation(ctx, "test_org", "", "test_customer")
+	assert.Error(t, err, "the score should not be stored without its location")
+}
@@ -0,0 +1,163 @@
+package rfm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/loyalty/analytics/internal/orgconfig"
+)
+
+// Vertical is the kind of business an org runs, which decides how its RFM
+// scores are weighted and how its quintiles are found
+type Vertical string
+
+const (
+	VerticalRetail      Vertical = "retail"
+	VerticalGrocery     Vertical = "grocery"
+	VerticalHospitality Vertical = "hospitality"
+)
+
+// QuintileMethod is how one dimension's quintile thresholds are set
+type QuintileMethod string
+
+const (
+	// QuintileMethodPercentile splits the org's customers into fifths
+	QuintileMethodPercentile QuintileMethod = "percentile"
+	// QuintileMethodFixed keeps the preset's thresholds however the org's
+	// customers are spread. Perishable-goods brands use it for recency,
+	// since a customer gone two weeks is lapsed even if most customers are.
+	QuintileMethodFixed QuintileMethod = "fixed"
+)
+
+// Weights sets how much each score counts towards a customer's weighted score
+type Weights struct {
+	Recency   float64
+	Frequency float64
+	Monetary  float64
+}
+
+// Score returns the weighted average of r, f and m, from 1 to 5. Weights that
+// sum to zero count the three equally.
+func (w Weights) Score(r, f, m int) float64 {
+	total := w.Recency + w.Frequency + w.Monetary
+	if total <= 0 {
+		return float64(r+f+m) / 3
+	}
+	return (w.Recency*float64(r) + w.Frequency*float64(f) + w.Monetary*float64(m)) / total
+}
+
+// Preset is the RFM configuration a vertical starts from
+type Preset struct {
+	Weights         Weights
+	RecencyMethod   QuintileMethod
+	FrequencyMethod QuintileMethod
+	MonetaryMethod  QuintileMethod
+	// Default thresholds, used for fixed dimensions and for orgs with too
+	// few customers to split
+	RecencyQuintiles   []int
+	FrequencyQuintiles []int
+	MonetaryQuintiles  []float64
+}
+
+// Presets holds the configuration of each vertical. Retail weighs the three
+// scores equally and splits every dimension by percentile, as RFM did before
+// verticals existed.
+var Presets = map[Vertical]Preset{
+	VerticalRetail: {
+		Weights:            Weights{Recency: 1, Frequency: 1, Monetary: 1},
+		RecencyMethod:      QuintileMethodPercentile,
+		FrequencyMethod:    QuintileMethodPercentile,
+		MonetaryMethod:     QuintileMethodPercentile,
+		RecencyQuintiles:   []int{7, 30, 90, 180, 365},
+		FrequencyQuintiles: []int{1, 2, 5, 10, 20},
+		MonetaryQuintiles:  []float64{10.0, 25.0, 50.0, 100.0, 250.0},
+	},
+	VerticalGrocery: {
+		Weights:            Weights{Recency: 0.6, Frequency: 0.3, Monetary: 0.1},
+		RecencyMethod:      QuintileMethodFixed,
+		FrequencyMethod:    QuintileMethodPercentile,
+		MonetaryMethod:     QuintileMethodPercentile,
+		RecencyQuintiles:   []int{3, 7, 14, 30, 60},
+		FrequencyQuintiles: []int{1, 3, 6, 12, 24},
+		MonetaryQuintiles:  []float64{10.0, 25.0, 50.0, 100.0, 250.0},
+	},
+	VerticalHospitality: {
+		Weights:            Weights{Recency: 0.5, Frequency: 0.3, Monetary: 0.2},
+		RecencyMethod:      QuintileMethodFixed,
+		FrequencyMethod:    QuintileMethodPercentile,
+		MonetaryMethod:     QuintileMethodPercentile,
+		RecencyQuintiles:   []int{7, 14, 30, 60, 90},
+		FrequencyQuintiles: []int{1, 2, 4, 8, 16},
+		MonetaryQuintiles:  []float64{10.0, 25.0, 50.0, 100.0, 250.0},
+	},
+}
+
+// VerticalConfig selects each org's vertical
+type VerticalConfig struct {
+	// Default applies to orgs without an entry in Orgs. Empty means retail.
+	Default Vertical
+	Orgs    map[string]Vertical
+	// Weights replaces the preset weights of individual orgs, such as a
+	// retailer that values spend over recency
+	Weights map[string]Weights
+}
+
+// Preset returns the preset of orgID's vertical, with the org's own weights
+// if it has any
+func (c VerticalConfig) Preset(orgID string) Preset {
+	vertical, ok := c.Orgs[orgID]
+	if !ok {
+		vertical = c.Default
+	}
+	preset, ok := Presets[vertical]
+	if !ok {
+		preset = Presets[VerticalRetail]
+	}
+	if weights, ok := c.Weights[orgID]; ok {
+		preset.Weights = weights
+	}
+	return preset
+}
+
+// ParseVertical parses a vertical name such as "grocery"
+func ParseVertical(name string) (Vertical, error) {
+	vertical := Vertical(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := Presets[vertical]; !ok {
+		return "", fmt.Errorf("unknown vertical %q, expected retail, grocery or hospitality", name)
+	}
+	return vertical, nil
+}
+
+// ParseOrgVerticals parses a comma-separated list of org=vertical pairs, such
+// as "org_a=grocery,org_b=hospitality"
+func ParseOrgVerticals(spec string) (map[string]Vertical, error) {
+	return orgconfig.ParseOrgValues(spec, "vertical", "vertical", ParseVertical)
+}
+
+// ParseOrgWeights parses a comma-separated list of org=recency/frequency/monetary
+// weights, such as "org_a=0.2/0.3/0.5". Each org's weights must be
+// non-negative and sum to 1.
+func ParseOrgWeights(spec string) (map[string]Weights, error) {
+	return orgconfig.ParseOrgValues(spec, "weights", "recency/frequency/monetary", parseWeights)
+}
+
+func parseWeights(value string) (Weights, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 3 {
+		return Weights{}, fmt.Errorf("invalid weights %q, expected recency/frequency/monetary", value)
+	}
+	var parsed [3]float64
+	for i, part := range parts {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || weight < 0 {
+			return Weights{}, fmt.Errorf("invalid weight %q", part)
+		}
+		parsed[i] = weight
+	}
+	if total := parsed[0] + parsed[1] + parsed[2]; math.Abs(total-1) > 1e-6 {
+		return Weights{}, fmt.Errorf("weights sum to %g, expected 1", total)
+	}
+	return Weights{Recency: parsed[0], Frequency: parsed[1], Monetary: parsed[2]}, nil
+}
@@ -0,0 +1,186 @@
+package rfm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test VerticalConfig
+func TestVerticalConfig_GroceryPreset(t *testing.T) {
+	config := VerticalConfig{Orgs: map[string]Vertical{"cafe_org": VerticalGrocery}}
+
+	preset := config.Preset("cafe_org")
+
+	// Assertions
+	assert.Equal(t, Weights{Recency: 0.6, Frequency: 0.3, Monetary: 0.1}, preset.Weights)
+	assert.Equal(t, QuintileMethodFixed, preset.RecencyMethod)
+	assert.Equal(t, QuintileMethodPercentile, preset.FrequencyMethod)
+	assert.Equal(t, QuintileMethodPercentile, preset.MonetaryMethod)
+	assert.Equal(t, []int{3, 7, 14, 30, 60}, preset.RecencyQuintiles)
+	assert.Greater(t, preset.Weights.Recency, preset.Weights.Monetary)
+}
+
+func TestVerticalConfig_FallsBackToDefaultThenRetail(t *testing.T) {
+	config := VerticalConfig{Default: VerticalHospitality, Orgs: map[string]Vertical{"cafe_org": VerticalGrocery}}
+
+	// Assertions
+	assert.Equal(t, Presets[VerticalHospitality], config.Preset("other_org"))
+	assert.Equal(t, Presets[VerticalRetail], VerticalConfig{}.Preset("other_org"))
+	assert.Equal(t, Presets[VerticalRetail], VerticalConfig{Default: "bakery"}.Preset("other_org"))
+}
+
+func TestVerticalConfig_OrgWeightsReplacePresetWeights(t *testing.T) {
+	weights := Weights{Recency: 0.2, Frequency: 0.3, Monetary: 0.5}
+	config := VerticalConfig{
+		Orgs:    map[string]Vertical{"cafe_org": VerticalGrocery},
+		Weights: map[string]Weights{"cafe_org": weights},
+	}
+
+	preset := config.Preset("cafe_org")
+
+	// Assertions - only the weights change
+	assert.Equal(t, weights, preset.Weights)
+	assert.Equal(t, QuintileMethodFixed, preset.RecencyMethod)
+	assert.Equal(t, Presets[VerticalGrocery].Weights, Weights{Recency: 0.6, Frequency: 0.3, Monetary: 0.1})
+	assert.Equal(t, Presets[VerticalRetail], config.Preset("other_org"))
+}
+
+// Test Weights
+func TestWeights_Score(t *testing.T) {
+	grocery := Presets[VerticalGrocery].Weights
+
+	// Assertions - a recent, low-spend shopper outranks a lapsed big spender
+	assert.InDelta(t, 5*0.6+3*0.3+1*0.1, grocery.Score(5, 3, 1), 1e-9)
+	assert.Greater(t, grocery.Score(5, 3, 1), grocery.Score(1, 3, 5))
+	assert.InDelta(t, 3.0, Weights{}.Score(5, 3, 1), 1e-9)
+	assert.InDelta(t, 3.0, Presets[VerticalRetail].Weights.Score(5, 3, 1), 1e-9)
+}
+
+// Test preset selection in the calculator
+func TestCalculateRFMScore_GroceryPresetWeightsRecency(t *testing.T) {
+	config := DefaultCalculatorConfig()
+	config.Verticals = VerticalConfig{Orgs: map[string]Vertical{"cafe_org": VerticalGrocery}}
+	calculator := NewRFMCalculatorWithConfig(&MockRFMStorage{}, config)
+
+	now := time.Now()
+	activity := models.CustomerActivity{
+		OrgID:             "cafe_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   now.AddDate(0, 0, -1),
+		FirstTransaction:  now.AddDate(0, 0, -60),
+		TotalTransactions: 3,
+		TotalSpent:        12.0,
+	}
+
+	score := calculator.calculateRFMScore(activity, calculator.getDefaultQuintiles("cafe_org"))
+
+	// Assertions - 5/2/1 weighted 0.6/0.3/0.1
+	assert.Equal(t, 5, score.RecencyScore)
+	assert.Equal(t, 2, score.FrequencyScore)
+	assert.Equal(t, 1, score.MonetaryScore)
+	assert.InDelta(t, 3.7, score.WeightedScore, 1e-9)
+}
+
+func TestCalculateRFMScore_MonetaryWeightRaisesLapsedBigSpender(t *testing.T) {
+	now := time.Now()
+	// Test data - a big spender who has not been back for over a year
+	activity := models.CustomerActivity{
+		OrgID:             "test_org",
+		CustomerID:        "test_customer",
+		LastTransaction:   now.AddDate(-1, 0, -30),
+		FirstTransaction:  now.AddDate(-3, 0, 0),
+		TotalTransactions: 12,
+		TotalSpent:        900.0,
+	}
+
+	scoreWith := func(weights Weights) models.RFMScore {
+		config := DefaultCalculatorConfig()
+		config.Verticals = VerticalConfig{Weights: map[string]Weights{"test_org": weights}}
+		calculator := NewRFMCalculatorWithConfig(&MockRFMStorage{}, config)
+		return calculator.calculateRFMScore(activity, calculator.getDefaultQuintiles("test_org"))
+	}
+
+	balanced := scoreWith(Weights{Recency: 0.4, Frequency: 0.3, Monetary: 0.3})
+	spendFirst := scoreWith(Weights{Recency: 0.2, Frequency: 0.3, Monetary: 0.5})
+
+	// Assertions - 1/4/5 weighted 0.4/0.3/0.3, then 0.2/0.3/0.5
+	assert.Equal(t, 1, balanced.RecencyScore)
+	assert.Equal(t, 4, balanced.FrequencyScore)
+	assert.Equal(t, 5, balanced.MonetaryScore)
+	assert.InDelta(t, 3.1, balanced.WeightedScore, 1e-9)
+	assert.InDelta(t, 3.9, spendFirst.WeightedScore, 1e-9)
+	assert.Equal(t, balanced.RFMSegment, spendFirst.RFMSegment, "weights do not move the segment")
+}
+
+func TestCalculateQuintilesForOrg_GroceryPresetKeepsFixedRecency(t *testing.T) {
+	mockStorage := &MockRFMStorage{}
+	config := DefaultCalculatorConfig()
+	config.Verticals = VerticalConfig{Orgs: map[string]Vertical{"cafe_org": VerticalGrocery}}
+	calculator := NewRFMCalculatorWithConfig(mockStorage, config)
+	ctx := context.Background()
+
+	// Test data - customers who mostly lapsed months ago
+	now := time.Now()
+	var activities []models.CustomerActivity
+	for i := 1; i <= 10; i++ {
+		activities = append(activities, models.CustomerActivity{
+			OrgID:             "cafe_org",
+			CustomerID:        fmt.Sprintf("cust_%d", i),
+			LastTransaction:   now.AddDate(0, 0, -30*i),
+			TotalTransactions: i,
+			TotalSpent:        float64(10 * i),
+		})
+	}
+	mockStorage.On("GetCustomerActivities", ctx, "cafe_org").Return(activities, nil)
+
+	quintiles, err := calculator.CalculateQuintilesForOrg(ctx, "cafe_org")
+
+	// Assertions - recency keeps the preset, the rest follow the customers
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 7, 14, 30, 60}, quintiles.RecencyQuintiles)
+	assert.Equal(t, 10, quintiles.FrequencyQuintiles[4])
+	assert.Equal(t, 100.0, quintiles.MonetaryQuintiles[4])
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGetDefaultQuintiles_DoesNotShareThePreset(t *testing.T) {
+	calculator, _ := setupTestCalculator()
+
+	quintiles := calculator.getDefaultQuintiles("test_org")
+	quintiles.RecencyQuintiles[0] = 999
+
+	// Assertions
+	assert.Equal(t, 7, Presets[VerticalRetail].RecencyQuintiles[0])
+}
+
+// Test ParseVertical
+func TestParseOrgVerticals(t *testing.T) {
+	verticals, err := ParseOrgVerticals(" org_a=Grocery, org_b=hospitality ,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Vertical{"org_a": VerticalGrocery, "org_b": VerticalHospitality}, verticals)
+
+	for _, spec := range []string{"org_a", "=grocery", "org_a=bakery", "org_a=retail,org_a=grocery"} {
+		_, err := ParseOrgVerticals(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// Test ParseOrgWeights
+func TestParseOrgWeights(t *testing.T) {
+	weights, err := ParseOrgWeights(" org_a=0.2/0.3/0.5, org_b=1/0/0 ,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Weights{
+		"org_a": {Recency: 0.2, Frequency: 0.3, Monetary: 0.5},
+		"org_b": {Recency: 1},
+	}, weights)
+
+	for _, spec := range []string{"org_a", "=0.2/0.3/0.5", "org_a=0.5/0.5", "org_a=0.2/0.3/x", "org_a=-0.5/0.5/1", "org_a=0.2/0.3/0.4", "org_a=0.2/0.3/0.5,org_a=1/0/0"} {
+		_, err := ParseOrgWeights(spec)
+		assert.Error(t, err, spec)
+	}
+}
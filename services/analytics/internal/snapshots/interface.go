@@ -0,0 +1,19 @@
+package snapshots
+
+import (
+	"context"
+)
+
+// SnapshotStorageInterface defines the storage operations Snapshotter depends on
+type SnapshotStorageInterface interface {
+	GetOrgIDs(ctx context.Context) ([]string, error)
+	CountCustomersByTier(ctx context.Context, orgID string) (map[string]int, error)
+	CountCustomersBySegment(ctx context.Context, orgID string) (map[string]int, error)
+	SaveSnapshot(ctx context.Context, snapshot DailySnapshot) error
+}
+
+// SnapshotReaderInterface defines the snapshot read operations exposed over
+// the analytics API
+type SnapshotReaderInterface interface {
+	GetSnapshots(ctx context.Context, orgID, fromDate, toDate string) ([]DailySnapshot, error)
+}
@@ -0,0 +1,67 @@
+package snapshots
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DateLayout is the format of DailySnapshot.Date, a UTC calendar day
+const DateLayout = "2006-01-02"
+
+// Trend metrics that can be charted from daily snapshots
+const (
+	MetricTierDistribution    = "tier_distribution"
+	MetricSegmentDistribution = "segment_distribution"
+	MetricLiability           = "liability"
+)
+
+// DailySnapshot records an org's aggregates for one day. Taking a second
+// snapshot on the same day replaces the first.
+type DailySnapshot struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID string             `bson:"org_id" json:"org_id"`
+	Date  string             `bson:"date" json:"date"`
+	// TierDistribution and SegmentDistribution count customers per tier and
+	// per RFM segment
+	TierDistribution    map[string]int `bson:"tier_distribution" json:"tier_distribution"`
+	SegmentDistribution map[string]int `bson:"segment_distribution" json:"segment_distribution"`
+	// PointsLiability and StampsLiability are the balances the org's
+	// customers hold in the ledger
+	PointsLiability uint64    `bson:"points_liability" json:"points_liability"`
+	StampsLiability uint64    `bson:"stamps_liability" json:"stamps_liability"`
+	CreatedAt       time.Time `bson:"created_at" json:"created_at"`
+}
+
+// TrendPoint is one day of a metric's trend
+type TrendPoint struct {
+	Date  string      `json:"date"`
+	Value interface{} `json:"value"`
+}
+
+// Liability is the value of a MetricLiability trend point
+type Liability struct {
+	Points uint64 `json:"points"`
+	Stamps uint64 `json:"stamps"`
+}
+
+// Trend extracts metric from each snapshot, reporting false for an unknown
+// metric
+func Trend(snapshots []DailySnapshot, metric string) ([]TrendPoint, bool) {
+	points := make([]TrendPoint, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var value interface{}
+		switch metric {
+		case MetricTierDistribution:
+			value = snapshot.TierDistribution
+		case MetricSegmentDistribution:
+			value = snapshot.SegmentDistribution
+		case MetricLiability:
+			value = Liability{Points: snapshot.PointsLiability, Stamps: snapshot.StampsLiability}
+		default:
+			return nil, false
+		}
+		points = append(points, TrendPoint{Date: snapshot.Date, Value: value})
+	}
+	return points, true
+}
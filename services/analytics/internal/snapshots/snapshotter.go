@@ -0,0 +1,111 @@
+package snapshots
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/loyalty/analytics/internal/clients"
+)
+
+// Config holds the tunable behaviour of Snapshotter
+type Config struct {
+	// Interval is how often every org is snapshotted. Zero disables the
+	// schedule.
+	Interval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval: 24 * time.Hour,
+	}
+}
+
+// Snapshotter writes each org's daily aggregates so trends can be charted
+type Snapshotter struct {
+	storage SnapshotStorageInterface
+	ledger  clients.LiabilityClientInterface
+	config  Config
+	now     func() time.Time
+}
+
+func NewSnapshotter(storage SnapshotStorageInterface, ledger clients.LiabilityClientInterface, config Config) *Snapshotter {
+	return &Snapshotter{storage: storage, ledger: ledger, config: config, now: time.Now}
+}
+
+// TakeSnapshot aggregates and saves today's snapshot for orgID
+func (s *Snapshotter) TakeSnapshot(ctx context.Context, orgID string) (*DailySnapshot, error) {
+	tiers, err := s.storage.CountCustomersByTier(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count customers by tier: %w", err)
+	}
+
+	segments, err := s.storage.CountCustomersBySegment(ctx, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count customers by segment: %w", err)
+	}
+
+	liability, err := s.ledger.GetLiability(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liability: %w", err)
+	}
+
+	now := s.now().UTC()
+	snapshot := DailySnapshot{
+		OrgID:               orgID,
+		Date:                now.Format(DateLayout),
+		TierDistribution:    tiers,
+		SegmentDistribution: segments,
+		PointsLiability:     liability.PointsOutstanding,
+		StampsLiability:     liability.StampsOutstanding,
+		CreatedAt:           now,
+	}
+
+	if err := s.storage.SaveSnapshot(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// SnapshotAllOrgs snapshots every org. One org failing is logged and does not
+// stop the others.
+func (s *Snapshotter) SnapshotAllOrgs(ctx context.Context) error {
+	orgIDs, err := s.storage.GetOrgIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list orgs: %w", err)
+	}
+
+	for _, orgID := range orgIDs {
+		if _, err := s.TakeSnapshot(ctx, orgID); err != nil {
+			log.Printf("Failed to snapshot org %s: %v", orgID, err)
+		}
+	}
+
+	log.Printf("Completed daily snapshots for %d orgs", len(orgIDs))
+	return nil
+}
+
+// Run snapshots every org immediately and then on each interval until ctx is
+// cancelled
+func (s *Snapshotter) Run(ctx context.Context) {
+	if s.config.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.SnapshotAllOrgs(ctx); err != nil {
+			log.Printf("Scheduled snapshot failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package snapshots
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSnapshotStorage is a mock implementation of SnapshotStorageInterface
+type MockSnapshotStorage struct {
+	mock.Mock
+}
+
+func (m *MockSnapshotStorage) GetOrgIDs(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockSnapshotStorage) CountCustomersByTier(ctx context.Context, orgID string) (map[string]int, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockSnapshotStorage) CountCustomersBySegment(ctx context.Context, orgID string) (map[string]int, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockSnapshotStorage) SaveSnapshot(ctx context.Context, snapshot DailySnapshot) error {
+	args := m.Called(ctx, snapshot)
+	return args.Error(0)
+}
+
+// MockLiabilityClient is a mock implementation of the ledger liability client
+type MockLiabilityClient struct {
+	mock.Mock
+}
+
+func (m *MockLiabilityClient) GetLiability(orgID string) (*clients.Liability, error) {
+	args := m.Called(orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.Liability), args.Error(1)
+}
+
+// Test setup helper
+func setupTestSnapshotter() (*Snapshotter, *MockSnapshotStorage, *MockLiabilityClient) {
+	mockStorage := &MockSnapshotStorage{}
+	mockLedger := &MockLiabilityClient{}
+	snapshotter := NewSnapshotter(mockStorage, mockLedger, DefaultConfig())
+	snapshotter.now = func() time.Time {
+		return time.Date(2026, 10, 14, 23, 30, 0, 0, time.FixedZone("EDT", -4*60*60))
+	}
+	return snapshotter, mockStorage, mockLedger
+}
+
+// Test TakeSnapshot
+func TestTakeSnapshot_WritesAggregates(t *testing.T) {
+	snapshotter, mockStorage, mockLedger := setupTestSnapshotter()
+	ctx := context.Background()
+
+	tiers := map[string]int{"Bronze": 120, "Silver": 30, "Gold": 5}
+	segments := map[string]int{"Champions": 12, "At Risk": 40, "New Customers": 103}
+
+	// Setup expectations
+	mockStorage.On("CountCustomersByTier", ctx, "test_org").Return(tiers, nil)
+	mockStorage.On("CountCustomersBySegment", ctx, "test_org").Return(segments, nil)
+	mockLedger.On("GetLiability", "test_org").Return(&clients.Liability{OrgID: "test_org", PointsOutstanding: 48250, StampsOutstanding: 310}, nil)
+	mockStorage.On("SaveSnapshot", ctx, mock.AnythingOfType("DailySnapshot")).Return(nil)
+
+	// Test
+	snapshot, err := snapshotter.TakeSnapshot(ctx, "test_org")
+
+	// Assertions - the date is the UTC day
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-10-15", snapshot.Date)
+	mockStorage.AssertCalled(t, "SaveSnapshot", ctx, mock.MatchedBy(func(saved DailySnapshot) bool {
+		return saved.OrgID == "test_org" &&
+			saved.Date == "2026-10-15" &&
+			assert.ObjectsAreEqual(tiers, saved.TierDistribution) &&
+			assert.ObjectsAreEqual(segments, saved.SegmentDistribution) &&
+			saved.PointsLiability == 48250 &&
+			saved.StampsLiability == 310
+	}))
+	mockStorage.AssertExpectations(t)
+	mockLedger.AssertExpectations(t)
+}
+
+func TestTakeSnapshot_LedgerErrorSavesNothing(t *testing.T) {
+	snapshotter, mockStorage, mockLedger := setupTestSnapshotter()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockStorage.On("CountCustomersByTier", ctx, "test_org").Return(map[string]int{"Bronze": 1}, nil)
+	mockStorage.On("CountCustomersBySegment", ctx, "test_org").Return(map[string]int{}, nil)
+	mockLedger.On("GetLiability", "test_org").Return(nil, assert.AnError)
+
+	// Test
+	snapshot, err := snapshotter.TakeSnapshot(ctx, "test_org")
+
+	// Assertions
+	assert.Error(t, err)
+	assert.Nil(t, snapshot)
+	assert.Contains(t, err.Error(), "failed to get liability")
+	mockStorage.AssertNotCalled(t, "SaveSnapshot", mock.Anything, mock.Anything)
+}
+
+// Test SnapshotAllOrgs
+func TestSnapshotAllOrgs_ContinuesPastFailingOrg(t *testing.T) {
+	snapshotter, mockStorage, mockLedger := setupTestSnapshotter()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockStorage.On("GetOrgIDs", ctx).Return([]string{"org_a", "org_b"}, nil)
+	mockStorage.On("CountCustomersByTier", ctx, "org_a").Return(nil, assert.AnError)
+	mockStorage.On("CountCustomersByTier", ctx, "org_b").Return(map[string]int{"Gold": 2}, nil)
+	mockStorage.On("CountCustomersBySegment", ctx, "org_b").Return(map[string]int{"Champions": 2}, nil)
+	mockLedger.On("GetLiability", "org_b").Return(&clients.Liability{OrgID: "org_b", PointsOutstanding: 10}, nil)
+	mockStorage.On("SaveSnapshot", ctx, mock.MatchedBy(func(saved DailySnapshot) bool {
+		return saved.OrgID == "org_b"
+	})).Return(nil).Once()
+
+	// Test
+	err := snapshotter.SnapshotAllOrgs(ctx)
+
+	// Assertions
+	assert.NoError(t, err)
+	mockStorage.AssertExpectations(t)
+	mockLedger.AssertExpectations(t)
+}
+
+// Test Trend
+func TestTrend(t *testing.T) {
+	daily := []DailySnapshot{
+		{Date: "2026-10-13", TierDistribution: map[string]int{"Gold": 4}, PointsLiability: 100, StampsLiability: 3},
+		{Date: "2026-10-14", TierDistribution: map[string]int{"Gold": 5}, PointsLiability: 150, StampsLiability: 1},
+	}
+
+	tiers, ok := Trend(daily, MetricTierDistribution)
+	assert.True(t, ok)
+	assert.Equal(t, []TrendPoint{
+		{Date: "2026-10-13", Value: map[string]int{"Gold": 4}},
+		{Date: "2026-10-14", Value: map[string]int{"Gold": 5}},
+	}, tiers)
+
+	liability, ok := Trend(daily, MetricLiability)
+	assert.True(t, ok)
+	assert.Equal(t, Liability{Points: 150, Stamps: 1}, liability[1].Value)
+
+	_, ok = Trend(daily, "revenue")
+	assert.False(t, ok)
+}
@@ -0,0 +1,138 @@
+package snapshots
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/loyalty/analytics/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SnapshotStorage struct {
+	tenants *storage.TenantRouter
+}
+
+func NewSnapshotStorage(database *mongo.Database) *SnapshotStorage {
+	return NewSnapshotStorageWithTenants(storage.NewTenantRouter(database.Client(), database, nil))
+}
+
+// NewSnapshotStorageWithTenants reads and stores each org's snapshots where
+// tenants places it
+func NewSnapshotStorageWithTenants(tenants *storage.TenantRouter) *SnapshotStorage {
+	return &SnapshotStorage{tenants: tenants}
+}
+
+// GetOrgIDs lists every org with tier or RFM data in any partition
+func (s *SnapshotStorage) GetOrgIDs(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var orgIDs []string
+
+	for _, partition := range s.tenants.Partitions() {
+		for _, name := range []string{"customer_tiers", "rfm_scores"} {
+			values, err := partition.Collection(name).Distinct(ctx, "org_id", bson.M{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list orgs in %s: %w", name, err)
+			}
+			for _, value := range values {
+				orgID, ok := value.(string)
+				if ok && orgID != "" && !seen[orgID] {
+					seen[orgID] = true
+					orgIDs = append(orgIDs, orgID)
+				}
+			}
+		}
+	}
+
+	return orgIDs, nil
+}
+
+func (s *SnapshotStorage) CountCustomersByTier(ctx context.Context, orgID string) (map[string]int, error) {
+	return s.countCustomersBy(ctx, "customer_tiers", "current_tier", orgID)
+}
+
+func (s *SnapshotStorage) CountCustomersBySegment(ctx context.Context, orgID string) (map[string]int, error) {
+	return s.countCustomersBy(ctx, "rfm_scores", "rfm_segment", orgID)
+}
+
+// countCustomersBy counts the distinct customers in the org for each value of
+// field. Records are kept per location, so a customer is only counted once
+// per value however many locations they visit.
+func (s *SnapshotStorage) countCustomersBy(ctx context.Context, collectionName, field, orgID string) (map[string]int, error) {
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.M{"org_id": orgID}}},
+		{{"$group", bson.M{"_id": bson.M{"value": "$" + field, "customer_id": "$customer_id"}}}},
+		{{"$group", bson.M{"_id": "$_id.value", "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := s.tenants.Collection(orgID, collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate %s by %s: %w", collectionName, field, err)
+	}
+	defer cursor.Close(ctx)
+
+	counts := make(map[string]int)
+	for cursor.Next(ctx) {
+		var row struct {
+			Value string `bson:"_id"`
+			Count int    `bson:"count"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode %s count: %w", field, err)
+		}
+		counts[row.Value] = row.Count
+	}
+
+	return counts, nil
+}
+
+func (s *SnapshotStorage) SaveSnapshot(ctx context.Context, snapshot DailySnapshot) error {
+	collection := s.tenants.Collection(snapshot.OrgID, "daily_snapshots")
+
+	filter := bson.M{
+		"org_id": snapshot.OrgID,
+		"date":   snapshot.Date,
+	}
+
+	update := bson.M{
+		"$set": snapshot,
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return fmt.Errorf("failed to save daily snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// GetSnapshots returns an org's snapshots from fromDate to toDate inclusive,
+// oldest first. Dates use DateLayout, which sorts as a string.
+func (s *SnapshotStorage) GetSnapshots(ctx context.Context, orgID, fromDate, toDate string) ([]DailySnapshot, error) {
+	collection := s.tenants.Collection(orgID, "daily_snapshots")
+
+	filter := bson.M{
+		"org_id": orgID,
+		"date":   bson.M{"$gte": fromDate, "$lte": toDate},
+	}
+
+	opts := options.Find().SetSort(bson.D{{"date", 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find daily snapshots: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var snapshots []DailySnapshot
+	for cursor.Next(ctx) {
+		var snapshot DailySnapshot
+		if err := cursor.Decode(&snapshot); err != nil {
+			return nil, fmt.Errorf("failed to decode daily snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
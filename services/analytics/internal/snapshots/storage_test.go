@@ -0,0 +1,90 @@
+package snapshots
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Test CountCustomersByTier
+func TestCountCustomersByTier(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("groups distinct customers by tier", func(mt *mtest.T) {
+		storage := NewSnapshotStorage(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customer_tiers", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: "Bronze"}, {Key: "count", Value: 7}},
+			bson.D{{Key: "_id", Value: "Gold"}, {Key: "count", Value: 2}},
+		))
+
+		counts, err := storage.CountCustomersByTier(context.Background(), "test_org")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"Bronze": 7, "Gold": 2}, counts)
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "aggregate", started.CommandName)
+		assert.Equal(t, "customer_tiers", started.Command.Lookup("aggregate").StringValue())
+		match := started.Command.Lookup("pipeline").Array().Index(0).Value().Document()
+		assert.Equal(t, "test_org", match.Lookup("$match", "org_id").StringValue())
+	})
+}
+
+// Test SaveSnapshot
+func TestSaveSnapshot_UpsertsByOrgAndDate(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("upsert", func(mt *mtest.T) {
+		storage := NewSnapshotStorage(mt.DB)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		err := storage.SaveSnapshot(context.Background(), DailySnapshot{
+			OrgID:            "test_org",
+			Date:             "2026-10-14",
+			TierDistribution: map[string]int{"Gold": 3},
+			PointsLiability:  900,
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "daily_snapshots", started.Command.Lookup("update").StringValue())
+		update := started.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "2026-10-14", update.Lookup("q", "date").StringValue())
+		assert.True(t, update.Lookup("upsert").Boolean())
+		assert.Equal(t, int32(3), update.Lookup("u", "$set", "tier_distribution", "Gold").Int32())
+	})
+}
+
+// Test GetSnapshots
+func TestGetSnapshots_DateRange(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("oldest first", func(mt *mtest.T) {
+		storage := NewSnapshotStorage(mt.DB)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.daily_snapshots", mtest.FirstBatch,
+			bson.D{{Key: "org_id", Value: "test_org"}, {Key: "date", Value: "2026-10-13"}},
+			bson.D{{Key: "org_id", Value: "test_org"}, {Key: "date", Value: "2026-10-14"}},
+		))
+
+		snapshots, err := storage.GetSnapshots(context.Background(), "test_org", "2026-10-01", "2026-10-14")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, snapshots, 2)
+		assert.Equal(t, "2026-10-13", snapshots[0].Date)
+
+		started := mt.GetStartedEvent()
+		filter := started.Command.Lookup("filter").Document()
+		assert.Equal(t, "2026-10-01", filter.Lookup("date", "$gte").StringValue())
+		assert.Equal(t, "2026-10-14", filter.Lookup("date", "$lte").StringValue())
+		assert.Equal(t, int32(1), started.Command.Lookup("sort", "date").Int32())
+	})
+}
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/loyalty/analytics/internal/models"
@@ -11,12 +13,34 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Sentinel errors for documents that have not been calculated yet
+var (
+	ErrRFMScoreNotFound  = errors.New("RFM score not found")
+	ErrQuintilesNotFound = errors.New("quintiles not found")
+)
+
 type MongoStorage struct {
 	client   *mongo.Client
 	database *mongo.Database
+	tenants  *TenantRouter
+}
+
+// MongoStorageConfig holds the optional behaviour of MongoStorage
+type MongoStorageConfig struct {
+	// Tenants keeps the listed orgs' documents in their own database or
+	// under a collection prefix, away from the shared database
+	Tenants TenantConfig
+}
+
+func DefaultMongoStorageConfig() MongoStorageConfig {
+	return MongoStorageConfig{}
 }
 
 func NewMongoStorage(uri, dbName string) (*MongoStorage, error) {
+	return NewMongoStorageWithConfig(uri, dbName, DefaultMongoStorageConfig())
+}
+
+func NewMongoStorageWithConfig(uri, dbName string, config MongoStorageConfig) (*MongoStorage, error) {
 	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
@@ -31,6 +55,7 @@ func NewMongoStorage(uri, dbName string) (*MongoStorage, error) {
 	storage := &MongoStorage{
 		client:   client,
 		database: database,
+		tenants:  NewTenantRouter(client, database, config.Tenants),
 	}
 
 	if err := storage.createIndexes(); err != nil {
@@ -40,20 +65,39 @@ func NewMongoStorage(uri, dbName string) (*MongoStorage, error) {
 	return storage, nil
 }
 
+// createIndexes creates every collection's indexes in each partition, so
+// tenant databases are indexed like the shared one
 func (s *MongoStorage) createIndexes() error {
+	for _, partition := range s.tenants.Partitions() {
+		if err := createPartitionIndexes(partition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createPartitionIndexes(partition Partition) error {
 	ctx := context.Background()
 
-	rfmCollection := s.database.Collection("rfm_scores")
-	quintilesCollection := s.database.Collection("rfm_quintiles")
-	activitiesCollection := s.database.Collection("customer_activities")
-	tiersCollection := s.database.Collection("customer_tiers")
-	tierConfigsCollection := s.database.Collection("tier_configs")
-	tierUpgradesCollection := s.database.Collection("tier_upgrades")
+	rfmCollection := partition.Collection("rfm_scores")
+	quintilesCollection := partition.Collection("rfm_quintiles")
+	locationQuintilesCollection := partition.Collection("rfm_location_quintiles")
+	quintileHistoryCollection := partition.Collection("rfm_quintile_history")
+	activitiesCollection := partition.Collection("customer_activities")
+	tiersCollection := partition.Collection("customer_tiers")
+	tierConfigsCollection := partition.Collection("tier_configs")
+	tierUpgradesCollection := partition.Collection("tier_upgrades")
+	benefitUsageCollection := partition.Collection("benefit_usage")
+	snapshotsCollection := partition.Collection("daily_snapshots")
+	segmentHistoryCollection := partition.Collection("rfm_segment_history")
+	triggeredRewardsCollection := partition.Collection("triggered_rewards")
 
 	rfmIndexes := []mongo.IndexModel{
 		{Keys: bson.D{{"org_id", 1}, {"location_id", 1}, {"customer_id", 1}}, Options: options.Index().SetUnique(true).SetName("rfm_org_location_customer_unique")},
 		{Keys: bson.D{{"org_id", 1}, {"location_id", 1}}, Options: options.Index().SetName("rfm_org_location")},
 		{Keys: bson.D{{"org_id", 1}, {"rfm_segment", 1}}, Options: options.Index().SetName("rfm_org_segment")},
+		{Keys: bson.D{{"org_id", 1}, {"recency_score", -1}, {"frequency_score", -1}, {"monetary_score", -1}}, Options: options.Index().SetName("rfm_org_composite")},
+		{Keys: bson.D{{"org_id", 1}, {"monetary_score", -1}, {"total_spent", -1}}, Options: options.Index().SetName("rfm_org_monetary")},
 		{Keys: bson.D{{"calculated_at", -1}}, Options: options.Index().SetName("rfm_calculated_at")},
 	}
 
@@ -61,6 +105,14 @@ func (s *MongoStorage) createIndexes() error {
 		{Keys: bson.D{{"org_id", 1}}, Options: options.Index().SetUnique(true)},
 	}
 
+	locationQuintilesIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{"org_id", 1}, {"location_id", 1}}, Options: options.Index().SetUnique(true).SetName("location_quintiles_org_location_unique")},
+	}
+
+	quintileHistoryIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{"org_id", 1}, {"calculated_at", -1}}, Options: options.Index().SetUnique(true).SetName("quintile_history_org_calculated_unique")},
+	}
+
 	activityIndexes := []mongo.IndexModel{
 		{Keys: bson.D{{"org_id", 1}, {"location_id", 1}, {"customer_id", 1}}, Options: options.Index().SetUnique(true).SetName("activity_org_location_customer_unique")},
 		{Keys: bson.D{{"org_id", 1}, {"location_id", 1}}, Options: options.Index().SetName("activity_org_location")},
@@ -84,6 +136,26 @@ func (s *MongoStorage) createIndexes() error {
 		{Keys: bson.D{{"org_id", 1}, {"customer_id", 1}}},
 		{Keys: bson.D{{"org_id", 1}, {"notified", 1}}},
 		{Keys: bson.D{{"upgraded_at", -1}}},
+		{Keys: bson.D{{"org_id", 1}, {"upgraded_at", 1}, {"_id", 1}}, Options: options.Index().SetName("tier_upgrade_org_feed")},
+	}
+
+	// Limited benefits number their uses within a period, so the unique
+	// index turns two redemptions racing for one use into a duplicate key
+	benefitUsageIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{"org_id", 1}, {"customer_id", 1}, {"benefit", 1}, {"period", 1}, {"use", 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"use": bson.M{"$gt": 0}}).SetName("benefit_usage_org_customer_period_use_unique")},
+	}
+
+	snapshotIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{"org_id", 1}, {"date", 1}}, Options: options.Index().SetUnique(true).SetName("snapshot_org_date_unique")},
+	}
+
+	segmentHistoryIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{"org_id", 1}, {"customer_id", 1}, {"changed_at", 1}}, Options: options.Index().SetName("segment_history_org_customer")},
+	}
+
+	triggeredRewardIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{"event_id", 1}}, Options: options.Index().SetUnique(true).SetName("triggered_reward_event_unique")},
+		{Keys: bson.D{{"org_id", 1}, {"location_id", 1}}, Options: options.Index().SetName("triggered_reward_org_location")},
 	}
 
 	if _, err := rfmCollection.Indexes().CreateMany(ctx, rfmIndexes); err != nil {
@@ -94,6 +166,14 @@ func (s *MongoStorage) createIndexes() error {
 		return err
 	}
 
+	if _, err := locationQuintilesCollection.Indexes().CreateMany(ctx, locationQuintilesIndexes); err != nil {
+		return err
+	}
+
+	if _, err := quintileHistoryCollection.Indexes().CreateMany(ctx, quintileHistoryIndexes); err != nil {
+		return err
+	}
+
 	if _, err := activitiesCollection.Indexes().CreateMany(ctx, activityIndexes); err != nil {
 		return err
 	}
@@ -110,11 +190,57 @@ func (s *MongoStorage) createIndexes() error {
 		return err
 	}
 
+	if _, err := benefitUsageCollection.Indexes().CreateMany(ctx, benefitUsageIndexes); err != nil {
+		return err
+	}
+
+	if _, err := snapshotsCollection.Indexes().CreateMany(ctx, snapshotIndexes); err != nil {
+		return err
+	}
+
+	if _, err := segmentHistoryCollection.Indexes().CreateMany(ctx, segmentHistoryIndexes); err != nil {
+		return err
+	}
+
+	if _, err := triggeredRewardsCollection.Indexes().CreateMany(ctx, triggeredRewardIndexes); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// saveRFMScoreAttempts bounds how often SaveRFMScore starts over when another
+// save changes the stored score underneath it
+const saveRFMScoreAttempts = 3
+
+// SaveRFMScore stores the customer's score at the location. When it moves
+// the customer to a different segment from the stored score, the transition
+// is appended to rfm_segment_history before the score is replaced, so a save
+// that fails in between and is retried still records it. The history write
+// is keyed on the transition, so a retry does not record it twice. The score
+// is only replaced while it still has the segment the transition was made
+// from; if another save changed it first, this one starts over against the
+// new segment.
 func (s *MongoStorage) SaveRFMScore(ctx context.Context, score models.RFMScore) error {
-	collection := s.database.Collection("rfm_scores")
+	if err := validateRFMScore(score); err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		saved, err := s.saveRFMScore(ctx, score)
+		if err != nil || saved {
+			return err
+		}
+		if attempt == saveRFMScoreAttempts {
+			return fmt.Errorf("failed to save RFM score: the stored score for customer %s kept changing", score.CustomerID)
+		}
+	}
+}
+
+// saveRFMScore makes one attempt at SaveRFMScore. saved is false when another
+// save changed the stored score first.
+func (s *MongoStorage) saveRFMScore(ctx context.Context, score models.RFMScore) (bool, error) {
+	collection := s.tenants.Collection(score.OrgID, "rfm_scores")
 	
 	filter := bson.M{
 		"org_id":      score.OrgID,
@@ -122,21 +248,112 @@ func (s *MongoStorage) SaveRFMScore(ctx context.Context, score models.RFMScore)
 		"customer_id": score.CustomerID,
 	}
 	
-	update := bson.M{
-		"$set": score,
+	var previous models.RFMScore
+	opts := options.FindOne().SetProjection(bson.M{"rfm_segment": 1})
+	err := collection.FindOne(ctx, filter, opts).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		// The customer's first score; there is no segment to move from. The
+		// unique index turns a racing first save into a duplicate key.
+		filter["rfm_segment"] = bson.M{"$exists": false}
+		_, err := collection.UpdateOne(ctx, filter, bson.M{"$set": score}, options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to save RFM score: %w", err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read RFM score: %w", err)
 	}
 	
-	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	if previous.RFMSegment != score.RFMSegment {
+		if err := s.recordSegmentTransition(ctx, score, previous.RFMSegment); err != nil {
+			return false, err
+		}
+	}
+	
+	filter["rfm_segment"] = previous.RFMSegment
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": score})
 	if err != nil {
-		return fmt.Errorf("failed to save RFM score: %w", err)
+		return false, fmt.Errorf("failed to save RFM score: %w", err)
 	}
 	
+	return result.MatchedCount > 0, nil
+}
+
+// recordSegmentTransition appends the customer's move from fromSegment to the
+// score's segment, unless the same move is already recorded
+func (s *MongoStorage) recordSegmentTransition(ctx context.Context, score models.RFMScore, fromSegment string) error {
+	changedAt := score.CalculatedAt
+	if changedAt.IsZero() {
+		changedAt = time.Now()
+	}
+	transition := models.SegmentTransition{
+		OrgID:       score.OrgID,
+		LocationID:  score.LocationID,
+		CustomerID:  score.CustomerID,
+		FromSegment: fromSegment,
+		ToSegment:   score.RFMSegment,
+		ChangedAt:   changedAt,
+	}
+
+	collection := s.tenants.Collection(score.OrgID, "rfm_segment_history")
+	opts := options.Update().SetUpsert(true)
+	if _, err := collection.UpdateOne(ctx, transition, bson.M{"$setOnInsert": transition}, opts); err != nil {
+		return fmt.Errorf("failed to record segment change: %w", err)
+	}
+
+	return nil
+}
+
+// GetSegmentHistory returns the customer's segment transitions across their
+// locations, oldest first
+func (s *MongoStorage) GetSegmentHistory(ctx context.Context, orgID, customerID string) ([]models.SegmentTransition, error) {
+	collection := s.tenants.Collection(orgID, "rfm_segment_history")
+
+	filter := bson.M{
+		"org_id":      orgID,
+		"customer_id": customerID,
+	}
+	opts := options.Find().SetSort(bson.D{{"changed_at", 1}, {"_id", 1}})
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find segment history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var transitions []models.SegmentTransition
+	for cursor.Next(ctx) {
+		var transition models.SegmentTransition
+		if err := cursor.Decode(&transition); err != nil {
+			return nil, fmt.Errorf("failed to decode segment transition: %w", err)
+		}
+		transitions = append(transitions, transition)
+	}
+
+	return transitions, cursor.Err()
+}
+
+// validateRFMScore rejects scores carrying NaN or Inf values, which Mongo would
+// otherwise store verbatim and break every downstream aggregation.
+func validateRFMScore(score models.RFMScore) error {
+	values := map[string]float64{
+		"total_spent":     score.TotalSpent,
+		"avg_order_value": score.AvgOrderValue,
+	}
+	for field, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("invalid RFM score for customer %s: %s is %v", score.CustomerID, field, value)
+		}
+	}
 	return nil
 }
 
 func (s *MongoStorage) GetRFMScore(ctx context.Context, orgID, customerID string) (*models.RFMScore, error) {
-	collection := s.database.Collection("rfm_scores")
+	collection := s.tenants.Collection(orgID, "rfm_scores")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -147,7 +364,7 @@ func (s *MongoStorage) GetRFMScore(ctx context.Context, orgID, customerID string
 	err := collection.FindOne(ctx, filter).Decode(&score)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("RFM score not found")
+			return nil, ErrRFMScoreNotFound
 		}
 		return nil, fmt.Errorf("failed to get RFM score: %w", err)
 	}
@@ -156,7 +373,7 @@ func (s *MongoStorage) GetRFMScore(ctx context.Context, orgID, customerID string
 }
 
 func (s *MongoStorage) SaveQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error {
-	collection := s.database.Collection("rfm_quintiles")
+	collection := s.tenants.Collection(quintiles.OrgID, "rfm_quintiles")
 	
 	filter := bson.M{"org_id": quintiles.OrgID}
 	update := bson.M{"$set": quintiles}
@@ -171,13 +388,13 @@ func (s *MongoStorage) SaveQuintiles(ctx context.Context, quintiles models.RFMQu
 }
 
 func (s *MongoStorage) GetQuintiles(ctx context.Context, orgID string) (*models.RFMQuintiles, error) {
-	collection := s.database.Collection("rfm_quintiles")
+	collection := s.tenants.Collection(orgID, "rfm_quintiles")
 	
 	var quintiles models.RFMQuintiles
 	err := collection.FindOne(ctx, bson.M{"org_id": orgID}).Decode(&quintiles)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("quintiles not found")
+			return nil, ErrQuintilesNotFound
 		}
 		return nil, fmt.Errorf("failed to get quintiles: %w", err)
 	}
@@ -185,8 +402,93 @@ func (s *MongoStorage) GetQuintiles(ctx context.Context, orgID string) (*models.
 	return &quintiles, nil
 }
 
-func (s *MongoStorage) UpdateCustomerActivity(ctx context.Context, activity models.CustomerActivity) error {
-	collection := s.database.Collection("customer_activities")
+// AppendQuintileHistory records a calculation of the org's quintiles. Each
+// calculation is kept, keyed on its CalculatedAt, so saving the same one
+// again does not add another entry.
+func (s *MongoStorage) AppendQuintileHistory(ctx context.Context, quintiles models.RFMQuintiles) error {
+	collection := s.tenants.Collection(quintiles.OrgID, "rfm_quintile_history")
+
+	filter := bson.M{"org_id": quintiles.OrgID, "calculated_at": quintiles.CalculatedAt}
+	update := bson.M{"$set": quintiles}
+	opts := options.Update().SetUpsert(true)
+
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return fmt.Errorf("failed to append quintile history: %w", err)
+	}
+
+	return nil
+}
+
+// GetQuintileHistory returns up to limit of the org's past quintile
+// calculations, newest first
+func (s *MongoStorage) GetQuintileHistory(ctx context.Context, orgID string, limit int) ([]models.RFMQuintiles, error) {
+	collection := s.tenants.Collection(orgID, "rfm_quintile_history")
+
+	opts := options.Find().SetSort(bson.D{{"calculated_at", -1}}).SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, bson.M{"org_id": orgID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find quintile history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var history []models.RFMQuintiles
+	for cursor.Next(ctx) {
+		var quintiles models.RFMQuintiles
+		if err := cursor.Decode(&quintiles); err != nil {
+			return nil, fmt.Errorf("failed to decode quintile history: %w", err)
+		}
+		history = append(history, quintiles)
+	}
+
+	return history, cursor.Err()
+}
+
+// SaveLocationQuintiles stores quintiles calculated from the customers of
+// quintiles.LocationID, alongside rather than in place of the org's
+func (s *MongoStorage) SaveLocationQuintiles(ctx context.Context, quintiles models.RFMQuintiles) error {
+	collection := s.tenants.Collection(quintiles.OrgID, "rfm_location_quintiles")
+	
+	filter := bson.M{"org_id": quintiles.OrgID, "location_id": quintiles.LocationID}
+	update := bson.M{"$set": quintiles}
+	opts := options.Update().SetUpsert(true)
+	
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return fmt.Errorf("failed to save location quintiles: %w", err)
+	}
+	
+	return nil
+}
+
+func (s *MongoStorage) GetLocationQuintiles(ctx context.Context, orgID, locationID string) (*models.RFMQuintiles, error) {
+	collection := s.tenants.Collection(orgID, "rfm_location_quintiles")
+	
+	var quintiles models.RFMQuintiles
+	err := collection.FindOne(ctx, bson.M{"org_id": orgID, "location_id": locationID}).Decode(&quintiles)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrQuintilesNotFound
+		}
+		return nil, fmt.Errorf("failed to get location quintiles: %w", err)
+	}
+	
+	return &quintiles, nil
+}
+
+// UpdateCustomerActivity adds one transaction, activity.Amount at
+// activity.TransactionDate, to the customer's running totals at the location
+// and returns the totals that result. The totals are changed with $inc, $min
+// and $max in a single update, so concurrent transactions for a customer all
+// count. A negative Amount is a refund, recorded as RecordCustomerRefund does
+// without reversing a transaction.
+func (s *MongoStorage) UpdateCustomerActivity(ctx context.Context, activity models.CustomerActivity) (*models.CustomerActivity, error) {
+	if activity.Amount < 0 {
+		return s.RecordCustomerRefund(ctx, activity, false)
+	}
+	
+	collection := s.tenants.Collection(activity.OrgID, "customer_activities")
 	
 	filter := bson.M{
 		"org_id":      activity.OrgID,
@@ -194,33 +496,163 @@ func (s *MongoStorage) UpdateCustomerActivity(ctx context.Context, activity mode
 		"customer_id": activity.CustomerID,
 	}
 	
+	now := time.Now()
 	update := bson.M{
-		"$set": bson.M{
-			"org_id":               activity.OrgID,
-			"location_id":          activity.LocationID,
-			"customer_id":          activity.CustomerID,
-			"last_transaction":     activity.LastTransaction,
-			"total_transactions":   activity.TotalTransactions,
-			"total_spent":          activity.TotalSpent,
-			"updated_at":           time.Now(),
-		},
-		"$setOnInsert": bson.M{
-			"first_transaction": activity.FirstTransaction,
-			"created_at":        time.Now(),
-		},
+		"$set":         bson.M{"updated_at": now},
+		"$inc":         bson.M{"total_transactions": 1, "total_spent": activity.Amount},
+		"$min":         bson.M{"first_transaction": activity.TransactionDate},
+		"$max":         bson.M{"last_transaction": activity.TransactionDate},
+		"$setOnInsert": bson.M{"created_at": now},
 	}
 	
-	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var updated models.CustomerActivity
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	// Two first transactions racing to insert the document collide on the
+	// unique index; the loser's update applies to the winner's document
+	if mongo.IsDuplicateKeyError(err) {
+		err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update customer activity: %w", err)
+		return nil, fmt.Errorf("failed to update customer activity: %w", err)
 	}
 	
-	return nil
+	updated.TransactionDate = activity.TransactionDate
+	updated.Amount = activity.Amount
+	return &updated, nil
+}
+
+// RecordCustomerRefund takes activity.Amount, which is negative, back off the
+// customer's spend at the location. It is not a visit, so the transaction
+// dates do not move, but with reverseTransaction the refunded transaction no
+// longer counts towards the customer's frequency. Neither the spend nor the
+// transaction count is left below zero.
+func (s *MongoStorage) RecordCustomerRefund(ctx context.Context, activity models.CustomerActivity, reverseTransaction bool) (*models.CustomerActivity, error) {
+	collection := s.tenants.Collection(activity.OrgID, "customer_activities")
+	
+	filter := bson.M{
+		"org_id":      activity.OrgID,
+		"location_id": activity.LocationID,
+		"customer_id": activity.CustomerID,
+	}
+	
+	now := time.Now()
+	inc := bson.M{"total_spent": activity.Amount}
+	onInsert := bson.M{
+		"first_transaction": activity.TransactionDate,
+		"last_transaction":  activity.TransactionDate,
+		"created_at":        now,
+	}
+	if reverseTransaction {
+		inc["total_transactions"] = -1
+	} else {
+		onInsert["total_transactions"] = 0
+	}
+	update := bson.M{
+		"$set":         bson.M{"updated_at": now},
+		"$inc":         inc,
+		"$setOnInsert": onInsert,
+	}
+	
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var updated models.CustomerActivity
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if mongo.IsDuplicateKeyError(err) {
+		err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update customer activity: %w", err)
+	}
+	
+	// A refund larger than what it comes off is clamped afterwards; the
+	// filter only matches while the field is still negative, so a concurrent
+	// purchase is not overwritten
+	if updated.TotalSpent < 0 {
+		clamp := bson.M{"org_id": activity.OrgID, "location_id": activity.LocationID, "customer_id": activity.CustomerID, "total_spent": bson.M{"$lt": 0}}
+		if _, err := collection.UpdateOne(ctx, clamp, bson.M{"$set": bson.M{"total_spent": 0}}); err != nil {
+			return nil, fmt.Errorf("failed to update customer activity: %w", err)
+		}
+		updated.TotalSpent = 0
+	}
+	if updated.TotalTransactions < 0 {
+		clamp := bson.M{"org_id": activity.OrgID, "location_id": activity.LocationID, "customer_id": activity.CustomerID, "total_transactions": bson.M{"$lt": 0}}
+		if _, err := collection.UpdateOne(ctx, clamp, bson.M{"$set": bson.M{"total_transactions": 0}}); err != nil {
+			return nil, fmt.Errorf("failed to update customer activity: %w", err)
+		}
+		updated.TotalTransactions = 0
+	}
+	
+	updated.TransactionDate = activity.TransactionDate
+	updated.Amount = activity.Amount
+	return &updated, nil
+}
+
+// RecordCustomerInteraction counts a visit that is not a purchase, such as a
+// redemption, at activity.TransactionDate. It moves the customer's first and
+// last transaction dates like a purchase but leaves spend alone, and only adds
+// to the transaction count when countTransaction is set.
+func (s *MongoStorage) RecordCustomerInteraction(ctx context.Context, activity models.CustomerActivity, countTransaction bool) (*models.CustomerActivity, error) {
+	collection := s.tenants.Collection(activity.OrgID, "customer_activities")
+	
+	filter := bson.M{
+		"org_id":      activity.OrgID,
+		"location_id": activity.LocationID,
+		"customer_id": activity.CustomerID,
+	}
+	
+	now := time.Now()
+	onInsert := bson.M{"total_spent": 0.0, "created_at": now}
+	update := bson.M{
+		"$set":         bson.M{"updated_at": now},
+		"$min":         bson.M{"first_transaction": activity.TransactionDate},
+		"$max":         bson.M{"last_transaction": activity.TransactionDate},
+		"$setOnInsert": onInsert,
+	}
+	if countTransaction {
+		update["$inc"] = bson.M{"total_transactions": 1}
+	} else {
+		onInsert["total_transactions"] = 0
+	}
+	
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var updated models.CustomerActivity
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if mongo.IsDuplicateKeyError(err) {
+		err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record customer interaction: %w", err)
+	}
+	
+	updated.TransactionDate = activity.TransactionDate
+	updated.Amount = 0
+	return &updated, nil
+}
+
+// GetCustomerActivityByLocation returns a customer's activity at one location
+func (s *MongoStorage) GetCustomerActivityByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.CustomerActivity, error) {
+	collection := s.tenants.Collection(orgID, "customer_activities")
+
+	filter := bson.M{
+		"org_id":      orgID,
+		"location_id": locationID,
+		"customer_id": customerID,
+	}
+
+	var activity models.CustomerActivity
+	err := collection.FindOne(ctx, filter).Decode(&activity)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("customer activity not found for location")
+		}
+		return nil, fmt.Errorf("failed to get customer activity by location: %w", err)
+	}
+
+	return &activity, nil
 }
 
 func (s *MongoStorage) GetCustomerActivities(ctx context.Context, orgID string) ([]models.CustomerActivity, error) {
-	collection := s.database.Collection("customer_activities")
+	collection := s.tenants.Collection(orgID, "customer_activities")
 	
 	cursor, err := collection.Find(ctx, bson.M{"org_id": orgID})
 	if err != nil {
@@ -240,8 +672,79 @@ func (s *MongoStorage) GetCustomerActivities(ctx context.Context, orgID string)
 	return activities, nil
 }
 
+// EachCustomerActivity calls fn with each of the org's customer activities,
+// reading batchSize at a time so the org is never held in memory at once. A
+// batchSize of zero uses the driver's default. An error from fn stops the
+// iteration and is returned.
+func (s *MongoStorage) EachCustomerActivity(ctx context.Context, orgID string, batchSize int, fn func(models.CustomerActivity) error) error {
+	collection := s.tenants.Collection(orgID, "customer_activities")
+
+	opts := options.Find()
+	if batchSize > 0 {
+		opts.SetBatchSize(int32(batchSize))
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"org_id": orgID}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to find customer activities: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var activity models.CustomerActivity
+		if err := cursor.Decode(&activity); err != nil {
+			return fmt.Errorf("failed to decode activity: %w", err)
+		}
+		if err := fn(activity); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// GetRFMScores pages through all RFM scores for an org. Composite ordering
+// ranks by recency, then frequency, then monetary score; monetary ordering
+// ranks by monetary score and total spent. Customer ID breaks ties so pages
+// are stable.
+func (s *MongoStorage) GetRFMScores(ctx context.Context, orgID string, limit, offset int, sortBy string) ([]models.RFMScore, error) {
+	collection := s.tenants.Collection(orgID, "rfm_scores")
+
+	var sort bson.D
+	switch sortBy {
+	case models.RFMSortComposite, "":
+		sort = bson.D{{"recency_score", -1}, {"frequency_score", -1}, {"monetary_score", -1}, {"customer_id", 1}}
+	case models.RFMSortMonetary:
+		sort = bson.D{{"monetary_score", -1}, {"total_spent", -1}, {"customer_id", 1}}
+	default:
+		return nil, fmt.Errorf("unsupported RFM sort %q", sortBy)
+	}
+
+	opts := options.Find().
+		SetSort(sort).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := collection.Find(ctx, bson.M{"org_id": orgID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find RFM scores: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var scores []models.RFMScore
+	for cursor.Next(ctx) {
+		var score models.RFMScore
+		if err := cursor.Decode(&score); err != nil {
+			return nil, fmt.Errorf("failed to decode RFM score: %w", err)
+		}
+		scores = append(scores, score)
+	}
+
+	return scores, cursor.Err()
+}
+
 func (s *MongoStorage) GetRFMScoresBySegment(ctx context.Context, orgID, segment string) ([]models.RFMScore, error) {
-	collection := s.database.Collection("rfm_scores")
+	collection := s.tenants.Collection(orgID, "rfm_scores")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -263,7 +766,7 @@ func (s *MongoStorage) GetRFMScoresBySegment(ctx context.Context, orgID, segment
 		scores = append(scores, score)
 	}
 	
-	return scores, nil
+	return scores, cursor.Err()
 }
 
 func (s *MongoStorage) GetClient() *mongo.Client {
@@ -274,8 +777,13 @@ func (s *MongoStorage) GetDatabase() *mongo.Database {
 	return s.database
 }
 
+// GetTenants returns the router that places each org's documents
+func (s *MongoStorage) GetTenants() *TenantRouter {
+	return s.tenants
+}
+
 func (s *MongoStorage) GetRFMScoreByLocation(ctx context.Context, orgID, locationID, customerID string) (*models.RFMScore, error) {
-	collection := s.database.Collection("rfm_scores")
+	collection := s.tenants.Collection(orgID, "rfm_scores")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -296,7 +804,7 @@ func (s *MongoStorage) GetRFMScoreByLocation(ctx context.Context, orgID, locatio
 }
 
 func (s *MongoStorage) GetRFMScoresByLocation(ctx context.Context, orgID, locationID string) ([]models.RFMScore, error) {
-	collection := s.database.Collection("rfm_scores")
+	collection := s.tenants.Collection(orgID, "rfm_scores")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -318,11 +826,11 @@ func (s *MongoStorage) GetRFMScoresByLocation(ctx context.Context, orgID, locati
 		scores = append(scores, score)
 	}
 	
-	return scores, nil
+	return scores, cursor.Err()
 }
 
 func (s *MongoStorage) GetCustomerActivitiesByLocation(ctx context.Context, orgID, locationID string) ([]models.CustomerActivity, error) {
-	collection := s.database.Collection("customer_activities")
+	collection := s.tenants.Collection(orgID, "customer_activities")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -0,0 +1,711 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Test setup helper
+func setupTestStorage(mt *mtest.T) *MongoStorage {
+	return &MongoStorage{
+		client:   mt.Client,
+		database: mt.DB,
+		tenants:  NewTenantRouter(mt.Client, mt.DB, nil),
+	}
+}
+
+// Test SaveRFMScore
+func TestSaveRFMScore_RejectsNonFiniteValues(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	tests := []struct {
+		name  string
+		score models.RFMScore
+	}{
+		{"NaN average order value", models.RFMScore{OrgID: "test_org", CustomerID: "test_customer", AvgOrderValue: math.NaN()}},
+		{"Inf average order value", models.RFMScore{OrgID: "test_org", CustomerID: "test_customer", AvgOrderValue: math.Inf(1)}},
+		{"NaN total spent", models.RFMScore{OrgID: "test_org", CustomerID: "test_customer", TotalSpent: math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			storage := setupTestStorage(mt)
+
+			err := storage.SaveRFMScore(context.Background(), tt.score)
+
+			// Assertions
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid RFM score")
+			assert.Nil(t, mt.GetStartedEvent(), "no write should reach MongoDB")
+		})
+	}
+}
+
+// storedScore replies to the read of the stored score, with no document when
+// segment is empty
+func storedScore(segment string) bson.D {
+	if segment == "" {
+		return mtest.CreateCursorResponse(0, "test.rfm_scores", mtest.FirstBatch)
+	}
+	return mtest.CreateCursorResponse(0, "test.rfm_scores", mtest.FirstBatch, bson.D{{Key: "rfm_segment", Value: segment}})
+}
+
+// matched replies to an update that matched n documents
+func matched(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+func TestSaveRFMScore_Success(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("finite score", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(storedScore(""), matched(1))
+
+		err := storage.SaveRFMScore(context.Background(), models.RFMScore{
+			OrgID:         "test_org",
+			CustomerID:    "test_customer",
+			TotalSpent:    100.0,
+			AvgOrderValue: 50.0,
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, "find", mt.GetStartedEvent().CommandName)
+		assert.Equal(t, "update", mt.GetStartedEvent().CommandName)
+	})
+}
+
+func TestSaveRFMScore_KeepsLocation(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("location in filter and document", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(storedScore(""), matched(1))
+
+		err := storage.SaveRFMScore(context.Background(), models.RFMScore{
+			OrgID:      "test_org",
+			LocationID: "store_downtown",
+			CustomerID: "test_customer",
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, "store_downtown", mt.GetStartedEvent().Command.Lookup("filter", "location_id").StringValue())
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "store_downtown", update.Lookup("q", "location_id").StringValue())
+		assert.Equal(t, "store_downtown", update.Lookup("u", "$set", "location_id").StringValue())
+	})
+}
+
+func TestSaveRFMScore_RecordsSegmentTransitions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	calculatedAt := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	score := models.RFMScore{
+		OrgID:        "test_org",
+		LocationID:   "store_downtown",
+		CustomerID:   "test_customer",
+		RFMSegment:   "Champions",
+		CalculatedAt: calculatedAt,
+	}
+
+	mt.Run("segment changed", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(storedScore("At Risk"), matched(0), matched(1))
+
+		err := storage.SaveRFMScore(context.Background(), score)
+
+		// Assertions - the transition is written before the score
+		assert.NoError(t, err)
+		events := mt.GetAllStartedEvents()
+		if assert.Len(t, events, 3) {
+			assert.Equal(t, "find", events[0].CommandName)
+			assert.Equal(t, "rfm_segment_history", events[1].Command.Lookup("update").StringValue())
+			history := events[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.True(t, history.Lookup("upsert").Boolean())
+			transition := history.Lookup("u", "$setOnInsert").Document()
+			assert.Equal(t, "At Risk", transition.Lookup("from_segment").StringValue())
+			assert.Equal(t, "Champions", transition.Lookup("to_segment").StringValue())
+			assert.Equal(t, "store_downtown", transition.Lookup("location_id").StringValue())
+			assert.Equal(t, calculatedAt, transition.Lookup("changed_at").Time().UTC())
+
+			assert.Equal(t, "rfm_scores", events[2].Command.Lookup("update").StringValue())
+			update := events[2].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, "At Risk", update.Lookup("q", "rfm_segment").StringValue())
+		}
+	})
+
+	mt.Run("history write fails", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(storedScore("At Risk"), mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 2, Message: "bad value"}))
+
+		err := storage.SaveRFMScore(context.Background(), score)
+
+		// Assertions - the score keeps its old segment for a retry to move
+		assert.Error(t, err)
+		assert.Len(t, mt.GetAllStartedEvents(), 2)
+	})
+
+	mt.Run("score changed by another save", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(
+			storedScore("At Risk"), matched(0), matched(0),
+			storedScore("Loyal"), matched(0), matched(1),
+		)
+
+		err := storage.SaveRFMScore(context.Background(), score)
+
+		// Assertions - the second attempt moves from the new segment
+		assert.NoError(t, err)
+		events := mt.GetAllStartedEvents()
+		if assert.Len(t, events, 6) {
+			transition := events[4].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, "Loyal", transition.Lookup("u", "$setOnInsert", "from_segment").StringValue())
+		}
+	})
+
+	mt.Run("segment unchanged", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(storedScore("Champions"), matched(1))
+
+		err := storage.SaveRFMScore(context.Background(), score)
+
+		// Assertions
+		assert.NoError(t, err)
+		events := mt.GetAllStartedEvents()
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, "rfm_scores", events[1].Command.Lookup("update").StringValue())
+		}
+	})
+
+	mt.Run("first score", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(storedScore(""), matched(1))
+
+		err := storage.SaveRFMScore(context.Background(), score)
+
+		// Assertions
+		assert.NoError(t, err)
+		events := mt.GetAllStartedEvents()
+		if assert.Len(t, events, 2) {
+			update := events[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.True(t, update.Lookup("upsert").Boolean())
+		}
+	})
+}
+
+// Test GetSegmentHistory
+func TestGetSegmentHistory(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("oldest first", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.rfm_segment_history", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "test_customer"}, {Key: "from_segment", Value: "New Customers"}, {Key: "to_segment", Value: "At Risk"}},
+			bson.D{{Key: "customer_id", Value: "test_customer"}, {Key: "from_segment", Value: "At Risk"}, {Key: "to_segment", Value: "Champions"}},
+		))
+
+		history, err := storage.GetSegmentHistory(context.Background(), "test_org", "test_customer")
+
+		// Assertions
+		assert.NoError(t, err)
+		if assert.Len(t, history, 2) {
+			assert.Equal(t, "At Risk", history[0].ToSegment)
+			assert.Equal(t, "Champions", history[1].ToSegment)
+		}
+		command := mt.GetStartedEvent().Command
+		assert.Equal(t, "test_customer", command.Lookup("filter", "customer_id").StringValue())
+		assert.Equal(t, int32(1), command.Lookup("sort", "changed_at").Int32())
+	})
+}
+
+// Test AppendQuintileHistory
+func TestAppendQuintileHistory_KeyedOnCalculation(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("upserts by org and calculated_at", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 0}))
+
+		calculatedAt := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+		err := storage.AppendQuintileHistory(context.Background(), models.RFMQuintiles{
+			OrgID:             "test_org",
+			MonetaryQuintiles: []float64{20, 50, 100, 250},
+			CalculatedAt:      calculatedAt,
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "rfm_quintile_history", started.Command.Lookup("update").StringValue())
+		update := started.Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "test_org", update.Lookup("q", "org_id").StringValue())
+		assert.Equal(t, calculatedAt, update.Lookup("q", "calculated_at").Time().UTC())
+		assert.True(t, update.Lookup("upsert").Boolean())
+	})
+}
+
+// Test GetQuintileHistory
+func TestGetQuintileHistory(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("newest first", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.rfm_quintile_history", mtest.FirstBatch,
+			bson.D{{Key: "org_id", Value: "test_org"}, {Key: "monetary_quintiles", Value: bson.A{25.0, 60.0, 120.0, 300.0}}},
+			bson.D{{Key: "org_id", Value: "test_org"}, {Key: "monetary_quintiles", Value: bson.A{20.0, 50.0, 100.0, 250.0}}},
+		))
+
+		history, err := storage.GetQuintileHistory(context.Background(), "test_org", 10)
+
+		// Assertions
+		assert.NoError(t, err)
+		if assert.Len(t, history, 2) {
+			assert.Equal(t, []float64{25, 60, 120, 300}, history[0].MonetaryQuintiles)
+		}
+		command := mt.GetStartedEvent().Command
+		assert.Equal(t, "test_org", command.Lookup("filter", "org_id").StringValue())
+		assert.Equal(t, int32(-1), command.Lookup("sort", "calculated_at").Int32())
+		assert.Equal(t, int64(10), command.Lookup("limit").Int64())
+	})
+}
+
+// Test GetCustomerActivityByLocation
+func TestGetCustomerActivityByLocation_Success(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("location lookup", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customer_activities", mtest.FirstBatch, bson.D{
+			{Key: "org_id", Value: "test_org"},
+			{Key: "location_id", Value: "store_downtown"},
+			{Key: "customer_id", Value: "cust_2"},
+			{Key: "total_transactions", Value: 4},
+		}))
+
+		activity, err := storage.GetCustomerActivityByLocation(context.Background(), "test_org", "store_downtown", "cust_2")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, "store_downtown", activity.LocationID)
+		assert.Equal(t, 4, activity.TotalTransactions)
+
+		started := mt.GetStartedEvent()
+		filter := started.Command.Lookup("filter").Document()
+		assert.Equal(t, "test_org", filter.Lookup("org_id").StringValue())
+		assert.Equal(t, "store_downtown", filter.Lookup("location_id").StringValue())
+		assert.Equal(t, "cust_2", filter.Lookup("customer_id").StringValue())
+		assert.Equal(t, int64(1), started.Command.Lookup("limit").AsInt64())
+	})
+}
+
+func TestGetCustomerActivityByLocation_NotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("no documents", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customer_activities", mtest.FirstBatch))
+
+		activity, err := storage.GetCustomerActivityByLocation(context.Background(), "test_org", "store_downtown", "missing")
+
+		// Assertions
+		assert.Error(t, err)
+		assert.Nil(t, activity)
+		assert.Contains(t, err.Error(), "customer activity not found")
+	})
+}
+
+// Test GetRFMScores
+// Test EachCustomerActivity
+func TestEachCustomerActivity_ReadsInBatches(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("two batches", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(42, "test.customer_activities", mtest.FirstBatch,
+				bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_1"}},
+				bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_2"}},
+			),
+			mtest.CreateCursorResponse(0, "test.customer_activities", mtest.NextBatch,
+				bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_3"}},
+			),
+		)
+
+		var customerIDs []string
+		err := storage.EachCustomerActivity(context.Background(), "test_org", 2, func(activity models.CustomerActivity) error {
+			customerIDs = append(customerIDs, activity.CustomerID)
+			return nil
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"cust_1", "cust_2", "cust_3"}, customerIDs)
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "find", started.CommandName)
+		assert.Equal(t, int32(2), started.Command.Lookup("batchSize").Int32())
+	})
+
+	mt.Run("callback error stops iteration", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.customer_activities", mtest.FirstBatch,
+				bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_1"}},
+				bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_2"}},
+			),
+		)
+
+		calls := 0
+		err := storage.EachCustomerActivity(context.Background(), "test_org", 0, func(activity models.CustomerActivity) error {
+			calls++
+			return assert.AnError
+		})
+
+		// Assertions
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestGetRFMScores_PagingAndOrder(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	tests := []struct {
+		name         string
+		sortBy       string
+		expectedSort bson.D
+	}{
+		{"composite", models.RFMSortComposite, bson.D{
+			{Key: "recency_score", Value: int32(-1)},
+			{Key: "frequency_score", Value: int32(-1)},
+			{Key: "monetary_score", Value: int32(-1)},
+			{Key: "customer_id", Value: int32(1)},
+		}},
+		{"monetary", models.RFMSortMonetary, bson.D{
+			{Key: "monetary_score", Value: int32(-1)},
+			{Key: "total_spent", Value: int32(-1)},
+			{Key: "customer_id", Value: int32(1)},
+		}},
+	}
+
+	for _, tt := range tests {
+		mt.Run(tt.name, func(mt *mtest.T) {
+			storage := setupTestStorage(mt)
+			mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.rfm_scores", mtest.FirstBatch,
+				bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_3"}, {Key: "monetary_score", Value: 5}},
+				bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_4"}, {Key: "monetary_score", Value: 4}},
+			))
+
+			scores, err := storage.GetRFMScores(context.Background(), "test_org", 2, 2, tt.sortBy)
+
+			// Assertions
+			assert.NoError(t, err)
+			assert.Len(t, scores, 2)
+			assert.Equal(t, "cust_3", scores[0].CustomerID)
+			assert.Equal(t, "cust_4", scores[1].CustomerID)
+
+			started := mt.GetStartedEvent()
+			assert.Equal(t, "find", started.CommandName)
+			assert.Equal(t, "test_org", started.Command.Lookup("filter").Document().Lookup("org_id").StringValue())
+			assert.Equal(t, int64(2), started.Command.Lookup("limit").AsInt64())
+			assert.Equal(t, int64(2), started.Command.Lookup("skip").AsInt64())
+
+			var sort bson.D
+			assert.NoError(t, started.Command.Lookup("sort").Unmarshal(&sort))
+			assert.Equal(t, tt.expectedSort, sort)
+		})
+	}
+}
+
+func TestGetRFMScores_CursorFailsMidway(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	// The first batch is returned, then fetching the next one fails
+	queries := map[string]func(storage *MongoStorage) ([]models.RFMScore, error){
+		"all scores": func(storage *MongoStorage) ([]models.RFMScore, error) {
+			return storage.GetRFMScores(context.Background(), "test_org", 10, 0, models.RFMSortComposite)
+		},
+		"by segment": func(storage *MongoStorage) ([]models.RFMScore, error) {
+			return storage.GetRFMScoresBySegment(context.Background(), "test_org", "champions")
+		},
+		"by location": func(storage *MongoStorage) ([]models.RFMScore, error) {
+			return storage.GetRFMScoresByLocation(context.Background(), "test_org", "loc_1")
+		},
+	}
+
+	for name, query := range queries {
+		mt.Run(name, func(mt *mtest.T) {
+			storage := setupTestStorage(mt)
+			mt.AddMockResponses(
+				mtest.CreateCursorResponse(1, "test.rfm_scores", mtest.FirstBatch,
+					bson.D{{Key: "org_id", Value: "test_org"}, {Key: "customer_id", Value: "cust_1"}},
+				),
+				mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "getMore failed"}),
+			)
+
+			_, err := query(storage)
+
+			// Assertions - a truncated page is an error, not a result
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGetRFMScores_UnsupportedSort(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("unknown sort", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+
+		scores, err := storage.GetRFMScores(context.Background(), "test_org", 10, 0, "alphabetical")
+
+		// Assertions
+		assert.Error(t, err)
+		assert.Nil(t, scores)
+		assert.Nil(t, mt.GetStartedEvent(), "no query should reach MongoDB")
+	})
+}
+
+// Test UpdateCustomerActivity
+func activityResponse(totalTransactions int, totalSpent float64) bson.D {
+	return bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "value", Value: bson.D{
+			{Key: "org_id", Value: "test_org"},
+			{Key: "location_id", Value: "store_downtown"},
+			{Key: "customer_id", Value: "cust_1"},
+			{Key: "total_transactions", Value: totalTransactions},
+			{Key: "total_spent", Value: totalSpent},
+		}},
+	}
+}
+
+func TestUpdateCustomerActivity_ConcurrentTransactionsBothCount(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("two transactions at once", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(1, 20), activityResponse(2, 50))
+
+		// Both transactions are processed at once, before either is stored
+		var wg sync.WaitGroup
+		for _, amount := range []float64{20, 30} {
+			wg.Add(1)
+			go func(amount float64) {
+				defer wg.Done()
+				_, err := storage.UpdateCustomerActivity(context.Background(), models.CustomerActivity{
+					OrgID:           "test_org",
+					LocationID:      "store_downtown",
+					CustomerID:      "cust_1",
+					TransactionDate: time.Now(),
+					Amount:          amount,
+				})
+				assert.NoError(t, err)
+			}(amount)
+		}
+		wg.Wait()
+
+		// Apply both updates to the stored totals, in whichever order they ran
+		var totalTransactions int32
+		var totalSpent float64
+		for _, started := range mt.GetAllStartedEvents() {
+			assert.Equal(t, "findAndModify", started.CommandName)
+			update := started.Command.Lookup("update").Document()
+			_, setsTotals := update.Lookup("$set").Document().LookupErr("total_spent")
+			assert.Error(t, setsTotals, "totals must not be overwritten")
+
+			inc := update.Lookup("$inc").Document()
+			totalTransactions += inc.Lookup("total_transactions").Int32()
+			totalSpent += inc.Lookup("total_spent").Double()
+		}
+
+		// Assertions
+		assert.Equal(t, int32(2), totalTransactions)
+		assert.Equal(t, 50.0, totalSpent)
+	})
+}
+
+func TestUpdateCustomerActivity_TracksFirstAndLastTransaction(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("purchase", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(3, 75))
+		transactionDate := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		activity, err := storage.UpdateCustomerActivity(context.Background(), models.CustomerActivity{
+			OrgID: "test_org", LocationID: "store_downtown", CustomerID: "cust_1", TransactionDate: transactionDate, Amount: 25,
+		})
+
+		// Assertions - the stored totals are returned with the transaction
+		assert.NoError(t, err)
+		assert.Equal(t, 3, activity.TotalTransactions)
+		assert.Equal(t, 75.0, activity.TotalSpent)
+		assert.Equal(t, 25.0, activity.Amount)
+
+		update := mt.GetStartedEvent().Command.Lookup("update").Document()
+		assert.Equal(t, transactionDate, update.Lookup("$min", "first_transaction").Time().UTC())
+		assert.Equal(t, transactionDate, update.Lookup("$max", "last_transaction").Time().UTC())
+	})
+}
+
+func TestUpdateCustomerActivity_RefundDoesNotCountVisit(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("refund larger than spend", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(1, -10), mtest.CreateSuccessResponse())
+
+		activity, err := storage.UpdateCustomerActivity(context.Background(), models.CustomerActivity{
+			OrgID: "test_org", LocationID: "store_downtown", CustomerID: "cust_1", TransactionDate: time.Now(), Amount: -30,
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, activity.TotalSpent, "spend never goes below zero")
+
+		events := mt.GetAllStartedEvents()
+		if assert.Len(t, events, 2) {
+			update := events[0].Command.Lookup("update").Document()
+			_, countsVisit := update.Lookup("$inc").Document().LookupErr("total_transactions")
+			assert.Error(t, countsVisit)
+			_, movesLast := update.LookupErr("$max")
+			assert.Error(t, movesLast)
+			assert.Equal(t, "update", events[1].CommandName)
+		}
+	})
+}
+
+// Test RecordCustomerRefund
+func TestRecordCustomerRefund_ReversesTransaction(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	refund := models.CustomerActivity{
+		OrgID: "test_org", LocationID: "store_downtown", CustomerID: "cust_1", TransactionDate: time.Now(), Amount: -40,
+	}
+
+	mt.Run("net spend and count lowered", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(3, 160))
+
+		activity, err := storage.RecordCustomerRefund(context.Background(), refund, true)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, 160.0, activity.TotalSpent)
+		assert.Equal(t, 3, activity.TotalTransactions)
+
+		update := mt.GetStartedEvent().Command.Lookup("update").Document()
+		assert.Equal(t, -40.0, update.Lookup("$inc", "total_spent").Double())
+		assert.Equal(t, int32(-1), update.Lookup("$inc", "total_transactions").Int32())
+		_, movesLast := update.LookupErr("$max")
+		assert.Error(t, movesLast)
+	})
+
+	mt.Run("never below zero", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(-1, -40), mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		activity, err := storage.RecordCustomerRefund(context.Background(), refund, true)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, activity.TotalSpent)
+		assert.Equal(t, 0, activity.TotalTransactions)
+
+		events := mt.GetAllStartedEvents()
+		if assert.Len(t, events, 3) {
+			spent := events[1].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, int32(0), spent.Lookup("u", "$set", "total_spent").Int32())
+			transactions := events[2].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, int32(0), transactions.Lookup("u", "$set", "total_transactions").Int32())
+		}
+	})
+
+	mt.Run("spend only", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(4, 160))
+
+		activity, err := storage.RecordCustomerRefund(context.Background(), refund, false)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, 4, activity.TotalTransactions)
+
+		update := mt.GetStartedEvent().Command.Lookup("update").Document()
+		_, countsTransaction := update.Lookup("$inc").Document().LookupErr("total_transactions")
+		assert.Error(t, countsTransaction)
+	})
+}
+
+// Test RecordCustomerInteraction
+func TestRecordCustomerInteraction_BonusStampsMovesRecencyNotSpend(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	visit := time.Date(2026, 10, 12, 15, 0, 0, 0, time.UTC)
+
+	mt.Run("bonus_stamps interaction", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(4, 120))
+
+		activity, err := storage.RecordCustomerInteraction(context.Background(), models.CustomerActivity{
+			OrgID: "test_org", LocationID: "store_downtown", CustomerID: "cust_1", TransactionDate: visit,
+		}, false)
+
+		// Assertions - the visit date moves but spend and count do not
+		assert.NoError(t, err)
+		assert.Equal(t, 120.0, activity.TotalSpent)
+		assert.Equal(t, 4, activity.TotalTransactions)
+
+		update := mt.GetStartedEvent().Command.Lookup("update").Document()
+		assert.Equal(t, visit, update.Lookup("$max", "last_transaction").Time().UTC())
+		_, incs := update.LookupErr("$inc")
+		assert.Error(t, incs, "neither spend nor transactions are incremented")
+		assert.Equal(t, 0.0, update.Lookup("$setOnInsert", "total_spent").Double())
+	})
+
+	mt.Run("counted as a transaction", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(activityResponse(5, 120))
+
+		activity, err := storage.RecordCustomerInteraction(context.Background(), models.CustomerActivity{
+			OrgID: "test_org", LocationID: "store_downtown", CustomerID: "cust_1", TransactionDate: visit,
+		}, true)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, 5, activity.TotalTransactions)
+
+		inc := mt.GetStartedEvent().Command.Lookup("update", "$inc").Document()
+		assert.Equal(t, int32(1), inc.Lookup("total_transactions").Int32())
+		_, addsSpend := inc.LookupErr("total_spent")
+		assert.Error(t, addsSpend)
+	})
+}
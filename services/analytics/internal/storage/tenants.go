@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/loyalty/analytics/internal/orgconfig"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Tenant places one org's documents apart from the shared database: in a
+// database of its own, under a collection name prefix, or both
+type Tenant struct {
+	Database         string
+	CollectionPrefix string
+}
+
+// TenantConfig maps org IDs to where their documents live. Orgs that are not
+// listed share the default database.
+type TenantConfig map[string]Tenant
+
+// ParseTenantConfig parses a comma-separated list of org=database[/prefix]
+// entries, such as "org_a=analytics_org_a,org_b=/org_b_". An empty database
+// keeps the org in the shared database under its prefix.
+func ParseTenantConfig(spec string) (TenantConfig, error) {
+	return orgconfig.ParseOrgValues(spec, "tenant", "database[/prefix]", parseTenant)
+}
+
+func parseTenant(placement string) (Tenant, error) {
+	database, prefix, _ := strings.Cut(placement, "/")
+	tenant := Tenant{Database: strings.TrimSpace(database), CollectionPrefix: strings.TrimSpace(prefix)}
+	if tenant.Database == "" && tenant.CollectionPrefix == "" {
+		return Tenant{}, errors.New("tenant needs a database or a collection prefix")
+	}
+	return tenant, nil
+}
+
+// Partition is one database and collection prefix that holds documents
+type Partition struct {
+	Database *mongo.Database
+	Prefix   string
+}
+
+// Collection returns the partition's collection for name
+func (p Partition) Collection(name string) *mongo.Collection {
+	return p.Database.Collection(p.Prefix + name)
+}
+
+// TenantRouter resolves the collection holding an org's documents
+type TenantRouter struct {
+	client  *mongo.Client
+	shared  *mongo.Database
+	tenants TenantConfig
+}
+
+// NewTenantRouter routes the orgs in tenants to their own partitions and
+// every other org to shared. client opens tenants' databases.
+func NewTenantRouter(client *mongo.Client, shared *mongo.Database, tenants TenantConfig) *TenantRouter {
+	return &TenantRouter{client: client, shared: shared, tenants: tenants}
+}
+
+// Collection returns the collection called name that holds orgID's documents
+func (r *TenantRouter) Collection(orgID, name string) *mongo.Collection {
+	return r.partition(orgID).Collection(name)
+}
+
+// Partitions lists the shared partition followed by each distinct tenant
+// partition in org ID order, for work that spans orgs such as creating
+// indexes
+func (r *TenantRouter) Partitions() []Partition {
+	partitions := []Partition{{Database: r.shared}}
+	seen := map[string]bool{r.shared.Name() + "/": true}
+
+	orgIDs := make([]string, 0, len(r.tenants))
+	for orgID := range r.tenants {
+		orgIDs = append(orgIDs, orgID)
+	}
+	sort.Strings(orgIDs)
+
+	for _, orgID := range orgIDs {
+		partition := r.partition(orgID)
+		key := partition.Database.Name() + "/" + partition.Prefix
+		if !seen[key] {
+			seen[key] = true
+			partitions = append(partitions, partition)
+		}
+	}
+
+	return partitions
+}
+
+func (r *TenantRouter) partition(orgID string) Partition {
+	tenant, ok := r.tenants[orgID]
+	if !ok {
+		return Partition{Database: r.shared}
+	}
+
+	database := r.shared
+	if tenant.Database != "" {
+		database = r.client.Database(tenant.Database)
+	}
+	return Partition{Database: database, Prefix: tenant.CollectionPrefix}
+}
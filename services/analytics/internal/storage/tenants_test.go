@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/loyalty/analytics/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Test ParseTenantConfig
+func TestParseTenantConfig(t *testing.T) {
+	config, err := ParseTenantConfig("org_a=analytics_org_a, org_b=/org_b_,org_c=analytics_big/c_")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, TenantConfig{
+		"org_a": {Database: "analytics_org_a"},
+		"org_b": {CollectionPrefix: "org_b_"},
+		"org_c": {Database: "analytics_big", CollectionPrefix: "c_"},
+	}, config)
+}
+
+func TestParseTenantConfig_Invalid(t *testing.T) {
+	for _, spec := range []string{"org_a", "=analytics_org_a", "org_a=/", "org_a=db_a,org_a=db_b"} {
+		_, err := ParseTenantConfig(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// Test tenant routing
+func TestMongoStorage_RoutesOrgsToTheirTenants(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("separate database and prefix", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		storage.tenants = NewTenantRouter(mt.Client, mt.DB, TenantConfig{
+			"org_a": {Database: "analytics_org_a"},
+			"org_b": {CollectionPrefix: "org_b_"},
+		})
+		for i := 0; i < 3; i++ {
+			mt.AddMockResponses(storedScore(""), matched(1))
+		}
+
+		ctx := context.Background()
+		assert.NoError(t, storage.SaveRFMScore(ctx, models.RFMScore{OrgID: "org_a", CustomerID: "cust_1"}))
+		assert.NoError(t, storage.SaveRFMScore(ctx, models.RFMScore{OrgID: "org_b", CustomerID: "cust_1"}))
+		assert.NoError(t, storage.SaveRFMScore(ctx, models.RFMScore{OrgID: "org_c", CustomerID: "cust_1"}))
+
+		// Assertions - org_a has its own database, org_b a prefix in the
+		// shared one, and unlisted org_c the shared collection
+		orgA := mt.GetStartedEvent()
+		mt.GetStartedEvent()
+		assert.Equal(t, "analytics_org_a", orgA.DatabaseName)
+		assert.Equal(t, "rfm_scores", orgA.Command.Lookup("find").StringValue())
+
+		orgB := mt.GetStartedEvent()
+		mt.GetStartedEvent()
+		assert.Equal(t, mt.DB.Name(), orgB.DatabaseName)
+		assert.Equal(t, "org_b_rfm_scores", orgB.Command.Lookup("find").StringValue())
+
+		orgC := mt.GetStartedEvent()
+		assert.Equal(t, mt.DB.Name(), orgC.DatabaseName)
+		assert.Equal(t, "rfm_scores", orgC.Command.Lookup("find").StringValue())
+	})
+}
+
+func TestTenantRouter_Partitions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("distinct partitions", func(mt *mtest.T) {
+		router := NewTenantRouter(mt.Client, mt.DB, TenantConfig{
+			"org_a": {Database: "analytics_shared_big"},
+			"org_b": {Database: "analytics_shared_big"},
+			"org_c": {CollectionPrefix: "org_c_"},
+		})
+
+		partitions := router.Partitions()
+
+		// Assertions - orgs sharing a placement share a partition
+		assert.Len(t, partitions, 3)
+		assert.Equal(t, mt.DB.Name(), partitions[0].Database.Name())
+		assert.Equal(t, "", partitions[0].Prefix)
+		assert.Equal(t, "analytics_shared_big", partitions[1].Database.Name())
+		assert.Equal(t, "org_c_", partitions[2].Prefix)
+	})
+}
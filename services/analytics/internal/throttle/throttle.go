@@ -0,0 +1,98 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttler coalesces per-key work so it runs at most once per interval.
+// The first value submitted for a key starts the interval; values submitted
+// while it is pending replace it, and whatever is pending when the interval
+// ends is passed to run. An interval of zero or less disables throttling and
+// runs every submission immediately.
+type Throttler[T any] struct {
+	interval time.Duration
+	run      func(value T)
+
+	// mu guards pending. A pending value is taken out of the map before run
+	// is called without it, so a slow run never holds up other keys'
+	// submissions and run may submit again itself.
+	mu      sync.Mutex
+	pending map[string]*pendingRun[T]
+}
+
+type pendingRun[T any] struct {
+	value T
+	timer *time.Timer
+}
+
+func NewThrottler[T any](interval time.Duration, run func(value T)) *Throttler[T] {
+	return &Throttler[T]{
+		interval: interval,
+		run:      run,
+		pending:  make(map[string]*pendingRun[T]),
+	}
+}
+
+// Submit schedules value for key. If a value is already pending for key it is
+// replaced, so the latest value wins.
+func (t *Throttler[T]) Submit(key string, value T) {
+	t.SubmitFunc(key, func(pending *T) T {
+		return value
+	})
+}
+
+// SubmitFunc schedules the value returned by next for key. next receives the
+// value still pending for key, or nil if there is none, which lets callers
+// whose values build on each other fold a burst into one run.
+func (t *Throttler[T]) SubmitFunc(key string, next func(pending *T) T) {
+	if t.interval <= 0 {
+		t.run(next(nil))
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p, exists := t.pending[key]; exists {
+		p.value = next(&p.value)
+		return
+	}
+
+	p := &pendingRun[T]{value: next(nil)}
+	p.timer = time.AfterFunc(t.interval, func() {
+		t.fire(key, p)
+	})
+	t.pending[key] = p
+}
+
+// Flush runs everything that is pending without waiting for its interval.
+// Processors call it on shutdown so coalesced work is not dropped.
+func (t *Throttler[T]) Flush() {
+	t.mu.Lock()
+	values := make([]T, 0, len(t.pending))
+	for key, p := range t.pending {
+		p.timer.Stop()
+		delete(t.pending, key)
+		values = append(values, p.value)
+	}
+	t.mu.Unlock()
+
+	for _, value := range values {
+		t.run(value)
+	}
+}
+
+func (t *Throttler[T]) fire(key string, p *pendingRun[T]) {
+	t.mu.Lock()
+	// Flush may have already taken this run
+	if t.pending[key] != p {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.pending, key)
+	value := p.value
+	t.mu.Unlock()
+
+	t.run(value)
+}
@@ -0,0 +1,157 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder collects the values passed to run
+type recorder struct {
+	mu     sync.Mutex
+	values []int
+}
+
+func (r *recorder) run(value int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = append(r.values, value)
+}
+
+func (r *recorder) snapshot() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.values...)
+}
+
+// Test setup helper
+func setupTestThrottler(interval time.Duration) (*Throttler[int], *recorder) {
+	rec := &recorder{}
+	return NewThrottler(interval, rec.run), rec
+}
+
+// Test Submit
+func TestSubmit_RapidSubmissionsRunOnce(t *testing.T) {
+	throttler, rec := setupTestThrottler(50 * time.Millisecond)
+
+	for i := 1; i <= 10; i++ {
+		throttler.Submit("customer_1", i)
+	}
+
+	// Assertions
+	assert.Empty(t, rec.snapshot())
+	assert.Eventually(t, func() bool { return len(rec.snapshot()) == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []int{10}, rec.snapshot())
+}
+
+func TestSubmit_SpacedSubmissionsEachRun(t *testing.T) {
+	throttler, rec := setupTestThrottler(20 * time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		throttler.Submit("customer_1", i)
+		assert.Eventually(t, func() bool { return len(rec.snapshot()) == i }, time.Second, 5*time.Millisecond)
+	}
+
+	// Assertions
+	assert.Equal(t, []int{1, 2, 3}, rec.snapshot())
+}
+
+func TestSubmit_KeysThrottledIndependently(t *testing.T) {
+	throttler, rec := setupTestThrottler(20 * time.Millisecond)
+
+	throttler.Submit("customer_1", 1)
+	throttler.Submit("customer_2", 2)
+	throttler.Submit("customer_1", 3)
+
+	// Assertions
+	assert.Eventually(t, func() bool { return len(rec.snapshot()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.ElementsMatch(t, []int{2, 3}, rec.snapshot())
+}
+
+func TestSubmit_ZeroIntervalRunsImmediately(t *testing.T) {
+	throttler, rec := setupTestThrottler(0)
+
+	throttler.Submit("customer_1", 1)
+	throttler.Submit("customer_1", 2)
+
+	// Assertions
+	assert.Equal(t, []int{1, 2}, rec.snapshot())
+}
+
+// Test SubmitFunc
+func TestSubmitFunc_FoldsPendingValue(t *testing.T) {
+	throttler, rec := setupTestThrottler(time.Hour)
+
+	for i := 0; i < 5; i++ {
+		throttler.SubmitFunc("customer_1", func(pending *int) int {
+			if pending == nil {
+				return 1
+			}
+			return *pending + 1
+		})
+	}
+	throttler.Flush()
+
+	// Assertions
+	assert.Equal(t, []int{5}, rec.snapshot())
+}
+
+// Test Flush
+func TestFlush_RunsPendingOnce(t *testing.T) {
+	throttler, rec := setupTestThrottler(20 * time.Millisecond)
+
+	throttler.Submit("customer_1", 1)
+	throttler.Flush()
+	time.Sleep(50 * time.Millisecond)
+
+	// Assertions
+	assert.Equal(t, []int{1}, rec.snapshot())
+}
+
+func TestFire_SlowRunDoesNotBlockOtherKeys(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	throttler := NewThrottler(10*time.Millisecond, func(value int) {
+		if value == 1 {
+			close(started)
+			<-release
+		}
+	})
+	defer close(release)
+
+	throttler.Submit("customer_1", 1)
+	<-started
+
+	// Assertions - another customer's submission returns while the first run
+	// is still going
+	done := make(chan struct{})
+	go func() {
+		throttler.Submit("customer_2", 2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Submit blocked on a running recompute")
+	}
+}
+
+func TestFire_RunCanSubmitAgain(t *testing.T) {
+	rec := &recorder{}
+	var throttler *Throttler[int]
+	throttler = NewThrottler(10*time.Millisecond, func(value int) {
+		rec.run(value)
+		if value == 1 {
+			throttler.Submit("customer_1", 2)
+		}
+	})
+
+	throttler.Submit("customer_1", 1)
+
+	// Assertions
+	assert.Eventually(t, func() bool { return len(rec.snapshot()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{1, 2}, rec.snapshot())
+}
@@ -0,0 +1,151 @@
+package tiers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Benefit limit periods
+const (
+	BenefitPeriodDay   = "day"
+	BenefitPeriodWeek  = "week"
+	BenefitPeriodMonth = "month"
+	BenefitPeriodYear  = "year"
+)
+
+var (
+	// ErrBenefitNotInTier is returned when the customer's current tier does
+	// not include the benefit being redeemed
+	ErrBenefitNotInTier = errors.New("benefit is not included in the customer's tier")
+	// ErrBenefitLimitReached is returned when the benefit has already been
+	// redeemed as often as its limit allows this period
+	ErrBenefitLimitReached = errors.New("benefit usage limit reached for this period")
+)
+
+// BenefitTracker redeems tier benefits against a per-customer usage ledger,
+// enforcing each benefit's frequency limit
+type BenefitTracker struct {
+	tiers TierStorageInterface
+	usage BenefitUsageStorageInterface
+	now   func() time.Time
+}
+
+func NewBenefitTracker(tierStorage TierStorageInterface, usageStorage BenefitUsageStorageInterface) *BenefitTracker {
+	return &BenefitTracker{
+		tiers: tierStorage,
+		usage: usageStorage,
+		now:   time.Now,
+	}
+}
+
+// RedeemBenefit records a redemption of benefit by the customer. The benefit
+// must be part of the customer's current tier, and a limited benefit must
+// have uses left in the current period.
+func (t *BenefitTracker) RedeemBenefit(ctx context.Context, orgID, customerID, benefit, reference string) (*BenefitUsage, error) {
+	tier, err := t.tiers.GetCustomerTier(ctx, orgID, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, ok := t.tierRule(ctx, orgID, tier.CurrentTier)
+	if !ok || !hasBenefit(rule, benefit) {
+		return nil, ErrBenefitNotInTier
+	}
+
+	now := t.now().UTC()
+	usage := BenefitUsage{
+		OrgID:      orgID,
+		CustomerID: customerID,
+		Benefit:    benefit,
+		Tier:       tier.CurrentTier,
+		Reference:  reference,
+		UsedAt:     now,
+	}
+
+	if limit, ok := benefitLimit(rule, benefit); ok {
+		period, err := benefitPeriod(limit.Period, now)
+		if err != nil {
+			return nil, err
+		}
+
+		used, err := t.usage.CountBenefitUsage(ctx, orgID, customerID, benefit, period)
+		if err != nil {
+			return nil, err
+		}
+		maxUses := limit.MaxUses
+		if maxUses <= 0 {
+			maxUses = 1
+		}
+		if used >= maxUses {
+			return nil, ErrBenefitLimitReached
+		}
+
+		usage.Period = period
+		usage.Use = used + 1
+	}
+
+	if err := t.usage.SaveBenefitUsage(ctx, usage); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// tierRule finds the rule for tierName in the org's tier config, falling back
+// to the default rules for orgs without one
+func (t *BenefitTracker) tierRule(ctx context.Context, orgID, tierName string) (TierRule, bool) {
+	rules := GetDefaultTierRules()
+	config, err := t.tiers.GetTierConfig(ctx, orgID)
+	if err != nil {
+		log.Printf("No tier config found for org %s, using default benefits", orgID)
+	} else {
+		rules = config.TierRules
+	}
+
+	for _, rule := range rules {
+		if rule.Name == tierName {
+			return rule, true
+		}
+	}
+	return TierRule{}, false
+}
+
+func hasBenefit(rule TierRule, benefit string) bool {
+	for _, b := range rule.Benefits {
+		if b == benefit {
+			return true
+		}
+	}
+	return false
+}
+
+func benefitLimit(rule TierRule, benefit string) (BenefitLimit, bool) {
+	for _, limit := range rule.BenefitLimits {
+		if limit.Benefit == benefit {
+			return limit, true
+		}
+	}
+	return BenefitLimit{}, false
+}
+
+// benefitPeriod names the UTC calendar period containing at, such as
+// 2026-10 for a month or 2026-W42 for an ISO week
+func benefitPeriod(period string, at time.Time) (string, error) {
+	at = at.UTC()
+	switch period {
+	case BenefitPeriodDay:
+		return at.Format("2006-01-02"), nil
+	case BenefitPeriodWeek:
+		year, week := at.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week), nil
+	case BenefitPeriodMonth:
+		return at.Format("2006-01"), nil
+	case BenefitPeriodYear:
+		return at.Format("2006"), nil
+	default:
+		return "", fmt.Errorf("unknown benefit period %q", period)
+	}
+}
@@ -0,0 +1,177 @@
+package tiers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBenefitUsageStorage is a mock implementation of the benefit usage ledger
+type MockBenefitUsageStorage struct {
+	mock.Mock
+}
+
+func (m *MockBenefitUsageStorage) CountBenefitUsage(ctx context.Context, orgID, customerID, benefit, period string) (int, error) {
+	args := m.Called(ctx, orgID, customerID, benefit, period)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockBenefitUsageStorage) SaveBenefitUsage(ctx context.Context, usage BenefitUsage) error {
+	args := m.Called(ctx, usage)
+	return args.Error(0)
+}
+
+// Test setup helper - a customer in tierName, in an org on config or the
+// default tier rules when config is nil
+func setupBenefitTracker(tierName string, config *OrgTierConfig) (*BenefitTracker, *MockBenefitUsageStorage, *time.Time) {
+	mockTiers := &MockTierStorage{}
+	mockUsage := &MockBenefitUsageStorage{}
+	tracker := NewBenefitTracker(mockTiers, mockUsage)
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	mockTiers.On("GetCustomerTier", mock.Anything, "test_org", "cust_1").
+		Return(&CustomerTier{OrgID: "test_org", CustomerID: "cust_1", CurrentTier: tierName}, nil)
+	if config != nil {
+		mockTiers.On("GetTierConfig", mock.Anything, "test_org").Return(config, nil)
+	} else {
+		mockTiers.On("GetTierConfig", mock.Anything, "test_org").Return(nil, assert.AnError)
+	}
+
+	return tracker, mockUsage, &now
+}
+
+// Test RedeemBenefit
+func TestRedeemBenefit_MonthlyBenefitOncePerMonth(t *testing.T) {
+	tracker, mockUsage, now := setupBenefitTracker("Silver", nil)
+	ctx := context.Background()
+
+	// Setup expectations - unused in October, then used once
+	mockUsage.On("CountBenefitUsage", mock.Anything, "test_org", "cust_1", "Monthly offers", "2026-10").Return(0, nil).Once()
+	mockUsage.On("CountBenefitUsage", mock.Anything, "test_org", "cust_1", "Monthly offers", "2026-10").Return(1, nil).Once()
+	mockUsage.On("CountBenefitUsage", mock.Anything, "test_org", "cust_1", "Monthly offers", "2026-11").Return(0, nil).Once()
+	mockUsage.On("SaveBenefitUsage", mock.Anything, mock.Anything).Return(nil)
+
+	first, err := tracker.RedeemBenefit(ctx, "test_org", "cust_1", "Monthly offers", "pos_1")
+	assert.NoError(t, err)
+
+	// A second use later the same month is refused
+	*now = now.Add(10 * 24 * time.Hour)
+	second, secondErr := tracker.RedeemBenefit(ctx, "test_org", "cust_1", "Monthly offers", "pos_2")
+
+	// Next month the benefit is available again
+	*now = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	third, thirdErr := tracker.RedeemBenefit(ctx, "test_org", "cust_1", "Monthly offers", "pos_3")
+
+	// Assertions
+	if assert.NotNil(t, first) {
+		assert.Equal(t, "2026-10", first.Period)
+		assert.Equal(t, 1, first.Use)
+		assert.Equal(t, "Silver", first.Tier)
+		assert.Equal(t, "pos_1", first.Reference)
+	}
+	assert.ErrorIs(t, secondErr, ErrBenefitLimitReached)
+	assert.Nil(t, second)
+	assert.NoError(t, thirdErr)
+	if assert.NotNil(t, third) {
+		assert.Equal(t, "2026-11", third.Period)
+		assert.Equal(t, 1, third.Use)
+	}
+	mockUsage.AssertNumberOfCalls(t, "SaveBenefitUsage", 2)
+	mockUsage.AssertExpectations(t)
+}
+
+func TestRedeemBenefit_UsesOrgLimits(t *testing.T) {
+	// An org allowing two free drinks a week
+	tracker, mockUsage, _ := setupBenefitTracker("Gold", &OrgTierConfig{
+		OrgID: "test_org",
+		TierRules: []TierRule{{
+			Name:          "Gold",
+			Benefits:      []string{"Free drink"},
+			BenefitLimits: []BenefitLimit{{Benefit: "Free drink", Period: BenefitPeriodWeek, MaxUses: 2}},
+		}},
+	})
+
+	// Setup expectations
+	mockUsage.On("CountBenefitUsage", mock.Anything, "test_org", "cust_1", "Free drink", "2026-W42").Return(1, nil).Once()
+	mockUsage.On("CountBenefitUsage", mock.Anything, "test_org", "cust_1", "Free drink", "2026-W42").Return(2, nil).Once()
+	mockUsage.On("SaveBenefitUsage", mock.Anything, mock.Anything).Return(nil).Once()
+
+	usage, err := tracker.RedeemBenefit(context.Background(), "test_org", "cust_1", "Free drink", "")
+	_, limitErr := tracker.RedeemBenefit(context.Background(), "test_org", "cust_1", "Free drink", "")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, 2, usage.Use)
+	assert.ErrorIs(t, limitErr, ErrBenefitLimitReached)
+	mockUsage.AssertExpectations(t)
+}
+
+func TestRedeemBenefit_UnlimitedBenefit(t *testing.T) {
+	tracker, mockUsage, _ := setupBenefitTracker("Silver", nil)
+
+	// Setup expectations
+	mockUsage.On("SaveBenefitUsage", mock.Anything, mock.Anything).Return(nil)
+
+	usage, err := tracker.RedeemBenefit(context.Background(), "test_org", "cust_1", "Priority support", "")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, usage.Period)
+	assert.Zero(t, usage.Use)
+	mockUsage.AssertNotCalled(t, "CountBenefitUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRedeemBenefit_NotInTier(t *testing.T) {
+	tracker, mockUsage, _ := setupBenefitTracker("Bronze", nil)
+
+	usage, err := tracker.RedeemBenefit(context.Background(), "test_org", "cust_1", "Monthly offers", "")
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrBenefitNotInTier)
+	assert.Nil(t, usage)
+	mockUsage.AssertNotCalled(t, "SaveBenefitUsage", mock.Anything, mock.Anything)
+}
+
+func TestRedeemBenefit_LostRaceIsLimitReached(t *testing.T) {
+	tracker, mockUsage, _ := setupBenefitTracker("Silver", nil)
+
+	// Setup expectations - another redemption saved the use first
+	mockUsage.On("CountBenefitUsage", mock.Anything, "test_org", "cust_1", "Monthly offers", "2026-10").Return(0, nil)
+	mockUsage.On("SaveBenefitUsage", mock.Anything, mock.Anything).Return(ErrBenefitLimitReached)
+
+	_, err := tracker.RedeemBenefit(context.Background(), "test_org", "cust_1", "Monthly offers", "")
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrBenefitLimitReached)
+}
+
+// Test benefitPeriod
+func TestBenefitPeriod(t *testing.T) {
+	at := time.Date(2026, 10, 14, 23, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+
+	tests := []struct {
+		period   string
+		expected string
+	}{
+		{BenefitPeriodDay, "2026-10-15"},
+		{BenefitPeriodWeek, "2026-W42"},
+		{BenefitPeriodMonth, "2026-10"},
+		{BenefitPeriodYear, "2026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			period, err := benefitPeriod(tt.period, at)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, period)
+		})
+	}
+
+	_, err := benefitPeriod("fortnight", at)
+	assert.Error(t, err)
+}
@@ -5,35 +5,49 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"time"
+
+	"github.com/loyalty/analytics/internal/clients"
 )
 
+// CalculatorConfig holds the tunable behaviour of the tier calculator
+type CalculatorConfig struct {
+	// OverrideDuration is how long a manually assigned tier is kept before
+	// automatic recalculation takes over again
+	OverrideDuration time.Duration
+	// DowngradeGracePeriod is how long a customer keeps a tier after their
+	// metrics stop meeting it. Zero downgrades on the next calculation.
+	DowngradeGracePeriod time.Duration
+}
+
+func DefaultCalculatorConfig() CalculatorConfig {
+	return CalculatorConfig{
+		OverrideDuration: 30 * 24 * time.Hour,
+	}
+}
+
 type TierCalculator struct {
 	storage TierStorageInterface
+	ledger  clients.LedgerClientInterface
+	config  CalculatorConfig
 }
 
 func NewTierCalculator(storage TierStorageInterface) *TierCalculator {
-	return &TierCalculator{storage: storage}
+	return NewTierCalculatorWithConfig(storage, nil, DefaultCalculatorConfig())
+}
+
+// NewTierCalculatorWithConfig creates a calculator with explicit settings. The
+// ledger client is optional; without it upgrade bonus points are not awarded.
+func NewTierCalculatorWithConfig(storage TierStorageInterface, ledger clients.LedgerClientInterface, config CalculatorConfig) *TierCalculator {
+	return &TierCalculator{storage: storage, ledger: ledger, config: config}
 }
 
 func (c *TierCalculator) ProcessCustomerMetrics(ctx context.Context, metrics CustomerMetrics) error {
 	log.Printf("Processing tier calculation for customer %s in org %s at location %s", 
 		metrics.CustomerID, metrics.OrgID, metrics.LocationID)
 
-	tierConfig, err := c.storage.GetTierConfig(ctx, metrics.OrgID)
-	if err != nil {
-		log.Printf("No tier config found for org %s, using defaults", metrics.OrgID)
-		tierConfig = &OrgTierConfig{
-			OrgID:     metrics.OrgID,
-			TierRules: GetDefaultTierRules(),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-		
-		if saveErr := c.storage.SaveTierConfig(ctx, *tierConfig); saveErr != nil {
-			log.Printf("Failed to save default tier config: %v", saveErr)
-		}
-	}
+	tierConfig := c.getTierConfig(ctx, metrics.OrgID)
 
 	currentTier, err := c.storage.GetCustomerTier(ctx, metrics.OrgID, metrics.CustomerID)
 	if err != nil {
@@ -46,21 +60,49 @@ func (c *TierCalculator) ProcessCustomerMetrics(ctx context.Context, metrics Cus
 		}
 	}
 
+	fromTier := currentTier.CurrentTier
 	newTier := c.calculateTier(metrics, tierConfig.TierRules)
+	triggeredBy := "transaction"
+	if c.overrideActive(currentTier) {
+		if rule, ok := findTierRule(tierConfig.TierRules, currentTier.CurrentTier); ok {
+			log.Printf("Customer %s has a manual tier override until %s, keeping %s",
+				metrics.CustomerID, currentTier.OverrideUntil.Format(time.RFC3339), rule.Name)
+			newTier = rule
+		}
+	} else if currentTier.Overridden {
+		// The override has lapsed, so the customer goes back to the tier their
+		// metrics earn
+		log.Printf("Manual tier override for customer %s expired at %s",
+			metrics.CustomerID, currentTier.OverrideUntil.Format(time.RFC3339))
+		currentTier.Overridden = false
+		currentTier.OverrideUntil = time.Time{}
+		triggeredBy = "override_expired"
+	} else if held, expired := c.applyDowngradeGrace(currentTier, newTier, tierConfig.TierRules); expired {
+		triggeredBy = "grace_period_expired"
+	} else {
+		newTier = held
+	}
+
+	if tierConfig.HighestTierFloor && !c.overrideActive(currentTier) {
+		newTier = applyTierFloor(currentTier, newTier, tierConfig.TierRules)
+	}
 	
-	updated := c.updateCustomerTier(currentTier, newTier, metrics)
+	updated := c.updateCustomerTier(currentTier, newTier, metrics, tierConfig.TierRules)
 
 	if err := c.storage.SaveCustomerTier(ctx, *updated); err != nil {
 		return fmt.Errorf("failed to save customer tier: %w", err)
 	}
 
-	if updated.CurrentTier != updated.PreviousTier && updated.PreviousTier != "" {
+	// Only record a tier change on the transaction that caused it; PreviousTier
+	// keeps its value afterwards so it cannot be used to detect changes
+	if updated.CurrentTier != fromTier {
 		upgrade := TierUpgrade{
 			OrgID:        metrics.OrgID,
 			CustomerID:   metrics.CustomerID,
-			FromTier:     updated.PreviousTier,
+			FromTier:     fromTier,
 			ToTier:       updated.CurrentTier,
-			TriggeredBy:  "transaction",
+			Direction:    tierDirection(tierConfig.TierRules, fromTier, updated.CurrentTier),
+			TriggeredBy:  triggeredBy,
 			TriggerValue: metrics.TransactionAmount,
 			UpgradedAt:   time.Now(),
 			Notified:     false,
@@ -69,26 +111,212 @@ func (c *TierCalculator) ProcessCustomerMetrics(ctx context.Context, metrics Cus
 		if err := c.storage.SaveTierUpgrade(ctx, upgrade); err != nil {
 			log.Printf("Failed to save tier upgrade: %v", err)
 		} else {
-			log.Printf("Customer %s upgraded from %s to %s", 
-				metrics.CustomerID, updated.PreviousTier, updated.CurrentTier)
+			log.Printf("Customer %s %sd from %s to %s", 
+				metrics.CustomerID, upgrade.Direction, fromTier, updated.CurrentTier)
+		}
+
+		if previous, ok := findTierRule(tierConfig.TierRules, fromTier); ok && newTier.Level > previous.Level {
+			c.awardUpgradeBonus(metrics.OrgID, metrics.CustomerID, newTier)
 		}
 	}
 
 	return nil
 }
 
-func (c *TierCalculator) calculateTier(metrics CustomerMetrics, rules []TierRule) TierRule {
-	sort.Slice(rules, func(i, j int) bool {
-		return rules[i].Level > rules[j].Level
-	})
+// awardUpgradeBonus credits the tier's one-time upgrade bonus. Failures are
+// logged rather than returned so a ledger outage doesn't block tier updates.
+func (c *TierCalculator) awardUpgradeBonus(orgID, customerID string, tier TierRule) {
+	if c.ledger == nil || tier.UpgradeBonusPoints <= 0 {
+		return
+	}
+
+	reference := fmt.Sprintf("tier_upgrade_%s_%s", customerID, strings.ToLower(tier.Name))
+	if _, err := c.ledger.CreatePointsTransfer(orgID, customerID, tier.UpgradeBonusPoints, reference); err != nil {
+		log.Printf("Failed to award %d bonus points for %s upgrade to customer %s: %v",
+			tier.UpgradeBonusPoints, tier.Name, customerID, err)
+		return
+	}
+
+	log.Printf("Awarded %d bonus points to customer %s for reaching %s",
+		tier.UpgradeBonusPoints, customerID, tier.Name)
+}
+
+// ApplyTierOverride pins a customer to the given tier for the configured
+// override duration, e.g. when support changes the tier in membership.
+func (c *TierCalculator) ApplyTierOverride(ctx context.Context, orgID, customerID, tierName string) error {
+	tierConfig := c.getTierConfig(ctx, orgID)
 
+	rule, ok := findTierRule(tierConfig.TierRules, tierName)
+	if !ok {
+		return fmt.Errorf("unknown tier %q for org %s", tierName, orgID)
+	}
+
+	now := time.Now()
+	current, err := c.storage.GetCustomerTier(ctx, orgID, customerID)
+	if err != nil {
+		current = &CustomerTier{
+			OrgID:       orgID,
+			CustomerID:  customerID,
+			CurrentTier: "Bronze",
+			TierSince:   now,
+		}
+	}
+
+	fromTier := current.CurrentTier
+	if fromTier != rule.Name {
+		current.PreviousTier = fromTier
+		current.CurrentTier = rule.Name
+		current.TierSince = now
+	}
+	current.PointsMultiplier = rule.PointsMultiplier
+	current.Benefits = rule.Benefits
+	current.Overridden = true
+	current.OverrideUntil = now.Add(c.config.OverrideDuration)
+	current.UpdatedAt = now
+
+	if err := c.storage.SaveCustomerTier(ctx, *current); err != nil {
+		return fmt.Errorf("failed to save customer tier: %w", err)
+	}
+
+	if fromTier != rule.Name {
+		upgrade := TierUpgrade{
+			OrgID:       orgID,
+			CustomerID:  customerID,
+			FromTier:    fromTier,
+			ToTier:      rule.Name,
+			Direction:   tierDirection(tierConfig.TierRules, fromTier, rule.Name),
+			TriggeredBy: "manual_override",
+			UpgradedAt:  now,
+			Notified:    false,
+		}
+		
+		if err := c.storage.SaveTierUpgrade(ctx, upgrade); err != nil {
+			log.Printf("Failed to save tier upgrade: %v", err)
+		}
+	}
+
+	log.Printf("Customer %s manually set to %s until %s",
+		customerID, rule.Name, current.OverrideUntil.Format(time.RFC3339))
+	return nil
+}
+
+func (c *TierCalculator) getTierConfig(ctx context.Context, orgID string) *OrgTierConfig {
+	tierConfig, err := c.storage.GetTierConfig(ctx, orgID)
+	if err != nil {
+		log.Printf("No tier config found for org %s, using defaults", orgID)
+		tierConfig = &OrgTierConfig{
+			OrgID:     orgID,
+			TierRules: GetDefaultTierRules(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		
+		if saveErr := c.storage.SaveTierConfig(ctx, *tierConfig); saveErr != nil {
+			log.Printf("Failed to save default tier config: %v", saveErr)
+		}
+	}
+	return tierConfig
+}
+
+func (c *TierCalculator) overrideActive(tier *CustomerTier) bool {
+	return tier.Overridden && time.Now().Before(tier.OverrideUntil)
+}
+
+// applyDowngradeGrace keeps the customer in their current tier while the
+// earned tier is lower and the grace period has not run out, returning the
+// tier to hold. expired reports that the grace period has just run out and
+// the customer should drop to the earned tier.
+func (c *TierCalculator) applyDowngradeGrace(current *CustomerTier, earned TierRule, rules []TierRule) (held TierRule, expired bool) {
+	rule, ok := findTierRule(rules, current.CurrentTier)
+	if c.config.DowngradeGracePeriod <= 0 || !ok || earned.Level >= rule.Level {
+		current.BelowTierSince = time.Time{}
+		return earned, false
+	}
+
+	now := time.Now()
+	if current.BelowTierSince.IsZero() {
+		current.BelowTierSince = now
+	}
+	graceEnds := current.BelowTierSince.Add(c.config.DowngradeGracePeriod)
+	if now.Before(graceEnds) {
+		log.Printf("Customer %s no longer meets %s, keeping it until %s",
+			current.CustomerID, rule.Name, graceEnds.Format(time.RFC3339))
+		return rule, false
+	}
+
+	current.BelowTierSince = time.Time{}
+	return earned, true
+}
+
+// highestTier returns the rule of the highest tier the customer has reached.
+// Tiers saved before HighestTier was tracked fall back to the current tier.
+func highestTier(current *CustomerTier, rules []TierRule) (TierRule, bool) {
+	highest, ok := findTierRule(rules, current.HighestTier)
+	if rule, found := findTierRule(rules, current.CurrentTier); found && (!ok || rule.Level > highest.Level) {
+		return rule, true
+	}
+	return highest, ok
+}
+
+// applyTierFloor returns the customer's highest tier if earned is below it
+func applyTierFloor(current *CustomerTier, earned TierRule, rules []TierRule) TierRule {
+	floor, ok := highestTier(current, rules)
+	if !ok || earned.Level >= floor.Level {
+		return earned
+	}
+
+	log.Printf("Customer %s earns %s but has reached %s, keeping %s",
+		current.CustomerID, earned.Name, floor.Name, floor.Name)
+	return floor
+}
+
+func findTierRule(rules []TierRule, name string) (TierRule, bool) {
 	for _, rule := range rules {
-		if c.meetsRequirements(metrics, rule) {
-			return rule
+		if strings.EqualFold(rule.Name, name) {
+			return rule, true
+		}
+	}
+	return TierRule{}, false
+}
+
+// tierDirection classifies a tier change by level. A tier missing from the
+// rules ranks below all of them.
+func tierDirection(rules []TierRule, fromTier, toTier string) string {
+	fromLevel, toLevel := -1, -1
+	if rule, ok := findTierRule(rules, fromTier); ok {
+		fromLevel = rule.Level
+	}
+	if rule, ok := findTierRule(rules, toTier); ok {
+		toLevel = rule.Level
+	}
+
+	if toLevel < fromLevel {
+		return TierDirectionDowngrade
+	}
+	return TierDirectionUpgrade
+}
+
+func (c *TierCalculator) calculateTier(metrics CustomerMetrics, rules []TierRule) TierRule {
+	ordered := sortedTierRules(rules)
+
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if c.meetsRequirements(metrics, ordered[i]) {
+			return ordered[i]
 		}
 	}
 
-	return rules[len(rules)-1]
+	return ordered[0]
+}
+
+// sortedTierRules returns a copy of rules ordered from lowest to highest level
+// so the org's stored configuration is never reordered in place
+func sortedTierRules(rules []TierRule) []TierRule {
+	ordered := make([]TierRule, len(rules))
+	copy(ordered, rules)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Level < ordered[j].Level
+	})
+	return ordered
 }
 
 func (c *TierCalculator) meetsRequirements(metrics CustomerMetrics, rule TierRule) bool {
@@ -100,9 +328,15 @@ func (c *TierCalculator) meetsRequirements(metrics CustomerMetrics, rule TierRul
 	return meetsSpentLifetime && meetsSpentYear && meetsVisitsLifetime && meetsVisitsYear
 }
 
-func (c *TierCalculator) updateCustomerTier(current *CustomerTier, newTier TierRule, metrics CustomerMetrics) *CustomerTier {
+func (c *TierCalculator) updateCustomerTier(current *CustomerTier, newTier TierRule, metrics CustomerMetrics, rules []TierRule) *CustomerTier {
 	now := time.Now()
 	
+	if highest, ok := highestTier(current, rules); !ok || newTier.Level > highest.Level {
+		current.HighestTier = newTier.Name
+	} else {
+		current.HighestTier = highest.Name
+	}
+
 	if current.CurrentTier != newTier.Name {
 		current.PreviousTier = current.CurrentTier
 		current.CurrentTier = newTier.Name
@@ -121,21 +355,15 @@ func (c *TierCalculator) updateCustomerTier(current *CustomerTier, newTier TierR
 	current.CalculatedAt = now
 	current.UpdatedAt = now
 
-	nextTier, progress := c.calculateNextTierProgress(newTier, metrics)
+	nextTier, progress := c.calculateNextTierProgress(newTier, metrics, rules)
 	current.NextTier = nextTier
 	current.ProgressToNext = progress
 
 	return current
 }
 
-func (c *TierCalculator) calculateNextTierProgress(currentTier TierRule, metrics CustomerMetrics) (string, float64) {
-	rules := GetDefaultTierRules()
-	
-	sort.Slice(rules, func(i, j int) bool {
-		return rules[i].Level < rules[j].Level
-	})
-
-	for _, rule := range rules {
+func (c *TierCalculator) calculateNextTierProgress(currentTier TierRule, metrics CustomerMetrics, rules []TierRule) (string, float64) {
+	for _, rule := range sortedTierRules(rules) {
 		if rule.Level > currentTier.Level {
 			nextTier := rule
 			
@@ -162,8 +390,12 @@ func (c *TierCalculator) calculateNextTierProgress(currentTier TierRule, metrics
 	return "", 1.0
 }
 
-func (c *TierCalculator) GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool) ([]TierUpgrade, error) {
-	return c.storage.GetTierUpgrades(ctx, orgID, unnotifiedOnly)
+func (c *TierCalculator) GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool, direction string) ([]TierUpgrade, error) {
+	return c.storage.GetTierUpgrades(ctx, orgID, unnotifiedOnly, direction)
+}
+
+func (c *TierCalculator) GetTierUpgradeFeed(ctx context.Context, orgID string, after UpgradeCursor, limit int) ([]TierUpgrade, error) {
+	return c.storage.GetTierUpgradeFeed(ctx, orgID, after, limit)
 }
 
 func (c *TierCalculator) MarkUpgradeNotified(ctx context.Context, upgradeID string) error {
@@ -182,8 +414,9 @@ func (c *TierCalculator) RecalculateAllTiers(ctx context.Context, orgID string)
 		return fmt.Errorf("failed to get customers: %w", err)
 	}
 
+	now := time.Now()
 	for _, customer := range customers {
-		metrics := CustomerMetrics{
+		metrics := currentPeriodMetrics(CustomerMetrics{
 			OrgID:            customer.OrgID,
 			LocationID:       customer.LocationID,
 			CustomerID:       customer.CustomerID,
@@ -194,7 +427,7 @@ func (c *TierCalculator) RecalculateAllTiers(ctx context.Context, orgID string)
 			SpentThisMonth:   customer.SpentThisMonth,
 			VisitsThisMonth:  customer.VisitsThisMonth,
 			LastTransaction:  customer.LastTransaction,
-		}
+		}, now)
 
 		if err := c.ProcessCustomerMetrics(ctx, metrics); err != nil {
 			log.Printf("Failed to recalculate tier for customer %s: %v", customer.CustomerID, err)
@@ -203,4 +436,41 @@ func (c *TierCalculator) RecalculateAllTiers(ctx context.Context, orgID string)
 
 	log.Printf("Completed tier recalculation for %d customers in org %s", len(customers), orgID)
 	return nil
-}
\ No newline at end of file
+}
+
+// currentPeriodMetrics zeroes the year and month totals of a customer whose
+// last transaction predates that period at now. The stored totals are only
+// reset by the customer's next transaction, so a customer who has lapsed
+// would otherwise keep last period's spend.
+func currentPeriodMetrics(metrics CustomerMetrics, now time.Time) CustomerMetrics {
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	if metrics.LastTransaction.Before(yearStart) {
+		metrics.SpentThisYear = 0
+		metrics.VisitsThisYear = 0
+	}
+	if metrics.LastTransaction.Before(monthStart) {
+		metrics.SpentThisMonth = 0
+		metrics.VisitsThisMonth = 0
+	}
+	return metrics
+}
+
+// RecalculateAllOrgs recalculates the tiers of every org with customer tiers.
+// One org failing is logged and does not stop the others.
+func (c *TierCalculator) RecalculateAllOrgs(ctx context.Context) error {
+	orgIDs, err := c.storage.GetAllOrgIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list orgs: %w", err)
+	}
+
+	for _, orgID := range orgIDs {
+		if err := c.RecalculateAllTiers(ctx, orgID); err != nil {
+			log.Printf("Failed to recalculate tiers for org %s: %v", orgID, err)
+		}
+	}
+
+	log.Printf("Completed scheduled tier recalculation for %d orgs", len(orgIDs))
+	return nil
+}
@@ -0,0 +1,51 @@
+package tiers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTierConfig is returned for tier rules the calculator cannot rank
+var ErrInvalidTierConfig = errors.New("invalid tier config")
+
+// ValidateTierRules checks that rules can be ranked: every tier is named once,
+// no two tiers share a level, and no threshold is lower than the one of the
+// tier below it. Rules may be listed in any order.
+func ValidateTierRules(rules []TierRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("%w: at least one tier is required", ErrInvalidTierConfig)
+	}
+
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		name := strings.ToLower(rule.Name)
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: every tier needs a name", ErrInvalidTierConfig)
+		}
+		if names[name] {
+			return fmt.Errorf("%w: tier %s is defined more than once", ErrInvalidTierConfig, rule.Name)
+		}
+		names[name] = true
+
+		if rule.MinSpentLifetime < 0 || rule.MinSpentYear < 0 || rule.MinVisitsLifetime < 0 || rule.MinVisitsYear < 0 {
+			return fmt.Errorf("%w: tier %s has a negative threshold", ErrInvalidTierConfig, rule.Name)
+		}
+	}
+
+	ordered := sortedTierRules(rules)
+	for i := 1; i < len(ordered); i++ {
+		lower, higher := ordered[i-1], ordered[i]
+		if higher.Level == lower.Level {
+			return fmt.Errorf("%w: tiers %s and %s share level %d", ErrInvalidTierConfig, lower.Name, higher.Name, higher.Level)
+		}
+		if higher.MinSpentLifetime < lower.MinSpentLifetime ||
+			higher.MinSpentYear < lower.MinSpentYear ||
+			higher.MinVisitsLifetime < lower.MinVisitsLifetime ||
+			higher.MinVisitsYear < lower.MinVisitsYear {
+			return fmt.Errorf("%w: tier %s has a lower threshold than %s below it", ErrInvalidTierConfig, higher.Name, lower.Name)
+		}
+	}
+
+	return nil
+}
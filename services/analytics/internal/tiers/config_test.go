@@ -0,0 +1,37 @@
+package tiers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ValidateTierRules
+func TestValidateTierRules_AcceptsDefaultAndUnsortedRules(t *testing.T) {
+	assert.NoError(t, ValidateTierRules(GetDefaultTierRules()))
+	assert.NoError(t, ValidateTierRules(customTierRules()))
+}
+
+func TestValidateTierRules_RejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []TierRule
+	}{
+		{"no tiers", nil},
+		{"unnamed tier", []TierRule{{Name: " ", Level: 1}}},
+		{"duplicate name", []TierRule{{Name: "Member", Level: 1}, {Name: "member", Level: 2}}},
+		{"levels not strictly increasing", []TierRule{{Name: "Member", Level: 1}, {Name: "Regular", Level: 2}, {Name: "VIP", Level: 2}}},
+		{"spend decreases as level rises", []TierRule{{Name: "Member", Level: 1, MinSpentYear: 500}, {Name: "VIP", Level: 2, MinSpentYear: 100}}},
+		{"visits decrease as level rises", []TierRule{{Name: "VIP", Level: 2, MinVisitsLifetime: 5}, {Name: "Member", Level: 1, MinVisitsLifetime: 10}}},
+		{"negative threshold", []TierRule{{Name: "Member", Level: 1, MinSpentLifetime: -1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTierRules(tt.rules)
+
+			// Assertions
+			assert.ErrorIs(t, err, ErrInvalidTierConfig)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package tiers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpgradeCursor marks a position in an org's tier upgrade feed. The feed
+// resumes after changes recorded before UpgradedAt, and after those recorded
+// at UpgradedAt with an ID up to ID, so changes sharing a timestamp are
+// neither skipped nor repeated across pages.
+type UpgradeCursor struct {
+	UpgradedAt time.Time
+	ID         primitive.ObjectID
+}
+
+// cursorSeparator joins a cursor's timestamp and ID. RFC 3339 timestamps
+// never contain it.
+const cursorSeparator = "_"
+
+// CursorAfter returns the cursor that resumes the feed after upgrade
+func CursorAfter(upgrade TierUpgrade) UpgradeCursor {
+	return UpgradeCursor{UpgradedAt: upgrade.UpgradedAt, ID: upgrade.ID}
+}
+
+// String encodes the cursor as its RFC 3339 timestamp, followed by
+// _<id> when it has an ID
+func (c UpgradeCursor) String() string {
+	if c.UpgradedAt.IsZero() {
+		return ""
+	}
+
+	cursor := c.UpgradedAt.UTC().Format(time.RFC3339Nano)
+	if !c.ID.IsZero() {
+		cursor += cursorSeparator + c.ID.Hex()
+	}
+	return cursor
+}
+
+// ParseUpgradeCursor parses a cursor returned by the feed, or a bare RFC 3339
+// timestamp to start from. An empty cursor starts at the oldest change.
+func ParseUpgradeCursor(cursor string) (UpgradeCursor, error) {
+	if cursor == "" {
+		return UpgradeCursor{}, nil
+	}
+
+	timestamp, id, hasID := strings.Cut(cursor, cursorSeparator)
+	upgradedAt, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return UpgradeCursor{}, fmt.Errorf("invalid cursor %q: %w", cursor, err)
+	}
+
+	parsed := UpgradeCursor{UpgradedAt: upgradedAt}
+	if hasID {
+		parsed.ID, err = primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return UpgradeCursor{}, fmt.Errorf("invalid cursor %q: %w", cursor, err)
+		}
+	}
+	return parsed, nil
+}
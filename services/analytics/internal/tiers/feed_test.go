@@ -0,0 +1,49 @@
+package tiers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Test UpgradeCursor
+func TestUpgradeCursor_RoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	cursor := UpgradeCursor{UpgradedAt: time.Date(2026, 10, 14, 9, 30, 0, 123000000, time.UTC), ID: id}
+
+	parsed, err := ParseUpgradeCursor(cursor.String())
+
+	// Assertions
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-14T09:30:00.123Z_"+id.Hex(), cursor.String())
+	assert.True(t, cursor.UpgradedAt.Equal(parsed.UpgradedAt))
+	assert.Equal(t, id, parsed.ID)
+}
+
+func TestParseUpgradeCursor_Timestamp(t *testing.T) {
+	parsed, err := ParseUpgradeCursor("2026-10-14T09:30:00+02:00")
+
+	// Assertions
+	require.NoError(t, err)
+	assert.True(t, parsed.UpgradedAt.Equal(time.Date(2026, 10, 14, 7, 30, 0, 0, time.UTC)))
+	assert.True(t, parsed.ID.IsZero())
+}
+
+func TestParseUpgradeCursor_Empty(t *testing.T) {
+	parsed, err := ParseUpgradeCursor("")
+
+	// Assertions
+	require.NoError(t, err)
+	assert.True(t, parsed.UpgradedAt.IsZero())
+	assert.Equal(t, "", parsed.String())
+}
+
+func TestParseUpgradeCursor_Invalid(t *testing.T) {
+	for _, cursor := range []string{"yesterday", "2026-10-14", "2026-10-14T09:30:00Z_nothex"} {
+		_, err := ParseUpgradeCursor(cursor)
+		assert.Error(t, err, cursor)
+	}
+}
@@ -11,8 +11,30 @@ type TierStorageInterface interface {
 	GetCustomerTier(ctx context.Context, orgID, customerID string) (*CustomerTier, error)
 	SaveCustomerTier(ctx context.Context, tier CustomerTier) error
 	SaveTierUpgrade(ctx context.Context, upgrade TierUpgrade) error
-	GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool) ([]TierUpgrade, error)
+	GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool, direction string) ([]TierUpgrade, error)
+	GetTierUpgradeFeed(ctx context.Context, orgID string, after UpgradeCursor, limit int) ([]TierUpgrade, error)
 	MarkUpgradeNotified(ctx context.Context, upgradeID string) error
 	GetCustomersByTier(ctx context.Context, orgID, tierName string) ([]CustomerTier, error)
 	GetAllCustomerTiers(ctx context.Context, orgID string) ([]CustomerTier, error)
-} 
\ No newline at end of file
+	GetAllOrgIDs(ctx context.Context) ([]string, error)
+} 
+// TierUpgradeReaderInterface defines the tier, tier config and tier change operations exposed over the analytics API
+type TierUpgradeReaderInterface interface {
+	GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool, direction string) ([]TierUpgrade, error)
+	GetTierUpgradeFeed(ctx context.Context, orgID string, after UpgradeCursor, limit int) ([]TierUpgrade, error)
+	GetCustomerTier(ctx context.Context, orgID, customerID string) (*CustomerTier, error)
+	GetTierConfig(ctx context.Context, orgID string) (*OrgTierConfig, error)
+	SaveTierConfig(ctx context.Context, config OrgTierConfig) error
+	MarkUpgradeNotified(ctx context.Context, upgradeID string) error
+}
+
+// BenefitUsageStorageInterface defines the benefit usage ledger operations
+type BenefitUsageStorageInterface interface {
+	CountBenefitUsage(ctx context.Context, orgID, customerID, benefit, period string) (int, error)
+	SaveBenefitUsage(ctx context.Context, usage BenefitUsage) error
+}
+
+// BenefitRedeemerInterface defines tier benefit redemption exposed over the analytics API
+type BenefitRedeemerInterface interface {
+	RedeemBenefit(ctx context.Context, orgID, customerID, benefit, reference string) (*BenefitUsage, error)
+}
@@ -29,6 +29,17 @@ type CustomerTier struct {
 	PointsMultiplier float64   `bson:"points_multiplier" json:"points_multiplier"`
 	Benefits         []string  `bson:"benefits" json:"benefits"`
 	
+	// Manual override applied from customer.updated events
+	Overridden       bool      `bson:"overridden" json:"overridden"`
+	OverrideUntil    time.Time `bson:"override_until" json:"override_until"`
+	
+	// BelowTierSince is when the customer's metrics stopped meeting their
+	// current tier. It is cleared once they meet it again or are downgraded.
+	BelowTierSince   time.Time `bson:"below_tier_since" json:"below_tier_since"`
+	// HighestTier is the highest tier the customer has been assigned. Orgs
+	// with HighestTierFloor never downgrade below it.
+	HighestTier      string    `bson:"highest_tier" json:"highest_tier"`
+	
 	CalculatedAt     time.Time `bson:"calculated_at" json:"calculated_at"`
 	UpdatedAt        time.Time `bson:"updated_at" json:"updated_at"`
 }
@@ -42,24 +53,68 @@ type TierRule struct {
 	MinVisitsYear     int       `bson:"min_visits_year" json:"min_visits_year"`
 	PointsMultiplier  float64   `bson:"points_multiplier" json:"points_multiplier"`
 	Benefits          []string  `bson:"benefits" json:"benefits"`
+	// BenefitLimits caps how often benefits can be redeemed. Benefits
+	// without a limit can be redeemed any number of times.
+	BenefitLimits     []BenefitLimit `bson:"benefit_limits,omitempty" json:"benefit_limits,omitempty"`
+	// UpgradeBonusPoints is awarded once when a customer is upgraded into this tier
+	UpgradeBonusPoints int      `bson:"upgrade_bonus_points" json:"upgrade_bonus_points"`
 	Color             string    `bson:"color" json:"color"`
 	Icon              string    `bson:"icon" json:"icon"`
 }
 
+// BenefitLimit caps redemptions of one of a tier's benefits per calendar
+// period, such as a birthday drink once a year
+type BenefitLimit struct {
+	Benefit string `bson:"benefit" json:"benefit"`
+	// Period is day, week, month or year, counted in UTC
+	Period  string `bson:"period" json:"period"`
+	// MaxUses is how many redemptions each period allows. Zero means once.
+	MaxUses int    `bson:"max_uses" json:"max_uses"`
+}
+
+// BenefitUsage records one redemption of a tier benefit
+type BenefitUsage struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID      string            `bson:"org_id" json:"org_id"`
+	CustomerID string            `bson:"customer_id" json:"customer_id"`
+	Benefit    string            `bson:"benefit" json:"benefit"`
+	Tier       string            `bson:"tier" json:"tier"`
+	// Period identifies the limit period the redemption counts towards,
+	// such as 2026-10 for a monthly benefit. Unlimited benefits leave it
+	// empty.
+	Period     string            `bson:"period" json:"period"`
+	// Use numbers the redemption within its period from 1. Unlimited
+	// benefits record zero.
+	Use        int               `bson:"use" json:"use"`
+	Reference  string            `bson:"reference" json:"reference"`
+	UsedAt     time.Time         `bson:"used_at" json:"used_at"`
+}
+
 type OrgTierConfig struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	OrgID      string            `bson:"org_id" json:"org_id"`
 	TierRules  []TierRule        `bson:"tier_rules" json:"tier_rules"`
+	// HighestTierFloor keeps customers at or above the highest tier they
+	// have reached, however their metrics fall
+	HighestTierFloor bool        `bson:"highest_tier_floor" json:"highest_tier_floor"`
 	CreatedAt  time.Time         `bson:"created_at" json:"created_at"`
 	UpdatedAt  time.Time         `bson:"updated_at" json:"updated_at"`
 }
 
+// Tier change directions recorded on TierUpgrade. Records saved before
+// Direction was added have it empty.
+const (
+	TierDirectionUpgrade   = "upgrade"
+	TierDirectionDowngrade = "downgrade"
+)
+
 type TierUpgrade struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	OrgID        string            `bson:"org_id" json:"org_id"`
 	CustomerID   string            `bson:"customer_id" json:"customer_id"`
 	FromTier     string            `bson:"from_tier" json:"from_tier"`
 	ToTier       string            `bson:"to_tier" json:"to_tier"`
+	Direction    string            `bson:"direction" json:"direction"`
 	TriggeredBy  string            `bson:"triggered_by" json:"triggered_by"`
 	TriggerValue float64           `bson:"trigger_value" json:"trigger_value"`
 	UpgradedAt   time.Time         `bson:"upgraded_at" json:"upgraded_at"`
@@ -91,6 +146,7 @@ func GetDefaultTierRules() []TierRule {
 			MinVisitsYear:     0,
 			PointsMultiplier:  1.0,
 			Benefits:          []string{"Basic rewards", "Birthday bonus"},
+			BenefitLimits:     []BenefitLimit{{Benefit: "Birthday bonus", Period: BenefitPeriodYear}},
 			Color:             "#CD7F32",
 			Icon:              "bronze-medal",
 		},
@@ -103,6 +159,10 @@ func GetDefaultTierRules() []TierRule {
 			MinVisitsYear:     3,
 			PointsMultiplier:  1.25,
 			Benefits:          []string{"25% bonus points", "Priority support", "Birthday bonus", "Monthly offers"},
+			BenefitLimits: []BenefitLimit{
+				{Benefit: "Birthday bonus", Period: BenefitPeriodYear},
+				{Benefit: "Monthly offers", Period: BenefitPeriodMonth},
+			},
 			Color:             "#C0C0C0",
 			Icon:              "silver-medal",
 		},
@@ -115,6 +175,7 @@ func GetDefaultTierRules() []TierRule {
 			MinVisitsYear:     8,
 			PointsMultiplier:  1.5,
 			Benefits:          []string{"50% bonus points", "Free shipping", "Early access", "VIP support", "Birthday bonus"},
+			BenefitLimits:     []BenefitLimit{{Benefit: "Birthday bonus", Period: BenefitPeriodYear}},
 			Color:             "#FFD700",
 			Icon:              "gold-medal",
 		},
@@ -2,29 +2,52 @@ package tiers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"time"
 
+	"github.com/loyalty/analytics/internal/storage"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrCustomerTierNotFound is returned when a customer has no tier yet
+var ErrCustomerTierNotFound = errors.New("customer tier not found")
+
+// ErrTierConfigNotFound is returned when an org has not saved tier rules
+var ErrTierConfigNotFound = errors.New("tier config not found")
+
+// ErrTierUpgradeNotFound is returned when no partition holds an upgrade
+var ErrTierUpgradeNotFound = errors.New("tier upgrade not found")
+
+// ErrInvalidUpgradeID is returned for upgrade IDs that are not object IDs
+var ErrInvalidUpgradeID = errors.New("invalid upgrade ID")
+
 type TierStorage struct {
 	client   *mongo.Client
 	database *mongo.Database
+	tenants  *storage.TenantRouter
 }
 
 func NewTierStorage(client *mongo.Client, database *mongo.Database) *TierStorage {
+	return NewTierStorageWithTenants(client, database, storage.NewTenantRouter(client, database, nil))
+}
+
+// NewTierStorageWithTenants stores each org's tiers where tenants places it
+func NewTierStorageWithTenants(client *mongo.Client, database *mongo.Database, tenants *storage.TenantRouter) *TierStorage {
 	return &TierStorage{
 		client:   client,
 		database: database,
+		tenants:  tenants,
 	}
 }
 
 func (s *TierStorage) SaveCustomerTier(ctx context.Context, tier CustomerTier) error {
-	collection := s.database.Collection("customer_tiers")
+	collection := s.tenants.Collection(tier.OrgID, "customer_tiers")
 	
 	filter := bson.M{
 		"org_id":      tier.OrgID,
@@ -32,12 +55,17 @@ func (s *TierStorage) SaveCustomerTier(ctx context.Context, tier CustomerTier) e
 		"customer_id": tier.CustomerID,
 	}
 	
+	assignment, err := tierAssignment(tier)
+	if err != nil {
+		return fmt.Errorf("failed to save customer tier: %w", err)
+	}
+	
 	update := bson.M{
-		"$set": tier,
+		"$set": assignment,
 	}
 	
 	opts := options.Update().SetUpsert(true)
-	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	_, err = collection.UpdateOne(ctx, filter, update, opts)
 	if err != nil {
 		return fmt.Errorf("failed to save customer tier: %w", err)
 	}
@@ -45,8 +73,126 @@ func (s *TierStorage) SaveCustomerTier(ctx context.Context, tier CustomerTier) e
 	return nil
 }
 
+// metricFields are the customer tier fields written only by
+// AccumulateCustomerMetrics
+var metricFields = []string{
+	"total_spent", "total_visits",
+	"spent_this_year", "visits_this_year",
+	"spent_this_month", "visits_this_month",
+	"last_transaction",
+}
+
+// tierAssignment returns tier's fields without its metrics, so saving a tier
+// cannot overwrite transactions counted since its metrics were read
+func tierAssignment(tier CustomerTier) (bson.M, error) {
+	data, err := bson.Marshal(tier)
+	if err != nil {
+		return nil, err
+	}
+	
+	var fields bson.M
+	if err := bson.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, field := range metricFields {
+		delete(fields, field)
+	}
+	
+	return fields, nil
+}
+
+// AccumulateCustomerMetrics counts a transaction into a customer's tier
+// metrics and returns the metrics after it. A negative amount is a refund: it
+// comes off spend but is not a visit. The counters are incremented in place,
+// so concurrent transactions for a customer all count.
+func (s *TierStorage) AccumulateCustomerMetrics(ctx context.Context, orgID, locationID, customerID string, amount float64, at time.Time) (*CustomerMetrics, error) {
+	collection := s.tenants.Collection(orgID, "customer_tiers")
+	
+	filter := bson.M{
+		"org_id":      orgID,
+		"customer_id": customerID,
+	}
+	
+	// A customer whose last transaction predates the current year or month
+	// starts that period again. Once a transaction in the period is counted
+	// these no longer match, so they cannot clear it.
+	now := time.Now()
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	resets := []struct {
+		start  time.Time
+		fields bson.M
+	}{
+		{yearStart, bson.M{"spent_this_year": 0.0, "visits_this_year": 0}},
+		{monthStart, bson.M{"spent_this_month": 0.0, "visits_this_month": 0}},
+	}
+	for _, reset := range resets {
+		stale := bson.M{"org_id": orgID, "customer_id": customerID, "last_transaction": bson.M{"$lt": reset.start}}
+		if _, err := collection.UpdateOne(ctx, stale, bson.M{"$set": reset.fields}); err != nil {
+			return nil, fmt.Errorf("failed to accumulate customer metrics: %w", err)
+		}
+	}
+	
+	update := bson.M{
+		"$set": bson.M{"updated_at": now},
+		"$setOnInsert": bson.M{
+			"location_id":  locationID,
+			"current_tier": "Bronze",
+			"tier_since":   now,
+		},
+	}
+	if amount < 0 {
+		update["$inc"] = bson.M{"total_spent": amount, "spent_this_year": amount, "spent_this_month": amount}
+	} else {
+		update["$inc"] = bson.M{
+			"total_spent": amount, "total_visits": 1,
+			"spent_this_year": amount, "visits_this_year": 1,
+			"spent_this_month": amount, "visits_this_month": 1,
+		}
+		update["$max"] = bson.M{"last_transaction": at}
+	}
+	
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var tier CustomerTier
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&tier)
+	// Two first transactions racing to insert the document collide on the
+	// unique index; the loser's update applies to the winner's document
+	if mongo.IsDuplicateKeyError(err) {
+		err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&tier)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to accumulate customer metrics: %w", err)
+	}
+	
+	// A refund larger than the spend it comes off leaves it at zero. $max only
+	// raises the spends that went negative.
+	if tier.TotalSpent < 0 || tier.SpentThisYear < 0 || tier.SpentThisMonth < 0 {
+		clamp := bson.M{"$max": bson.M{"total_spent": 0.0, "spent_this_year": 0.0, "spent_this_month": 0.0}}
+		if _, err := collection.UpdateOne(ctx, filter, clamp); err != nil {
+			return nil, fmt.Errorf("failed to accumulate customer metrics: %w", err)
+		}
+		tier.TotalSpent = math.Max(tier.TotalSpent, 0)
+		tier.SpentThisYear = math.Max(tier.SpentThisYear, 0)
+		tier.SpentThisMonth = math.Max(tier.SpentThisMonth, 0)
+	}
+	
+	return &CustomerMetrics{
+		OrgID:             orgID,
+		LocationID:        tier.LocationID,
+		CustomerID:        customerID,
+		TotalSpent:        tier.TotalSpent,
+		TotalVisits:       tier.TotalVisits,
+		SpentThisYear:     tier.SpentThisYear,
+		VisitsThisYear:    tier.VisitsThisYear,
+		SpentThisMonth:    tier.SpentThisMonth,
+		VisitsThisMonth:   tier.VisitsThisMonth,
+		LastTransaction:   tier.LastTransaction,
+		TransactionAmount: amount,
+	}, nil
+}
+
 func (s *TierStorage) GetCustomerTier(ctx context.Context, orgID, customerID string) (*CustomerTier, error) {
-	collection := s.database.Collection("customer_tiers")
+	collection := s.tenants.Collection(orgID, "customer_tiers")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -57,7 +203,7 @@ func (s *TierStorage) GetCustomerTier(ctx context.Context, orgID, customerID str
 	err := collection.FindOne(ctx, filter).Decode(&tier)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("customer tier not found")
+			return nil, ErrCustomerTierNotFound
 		}
 		return nil, fmt.Errorf("failed to get customer tier: %w", err)
 	}
@@ -66,7 +212,7 @@ func (s *TierStorage) GetCustomerTier(ctx context.Context, orgID, customerID str
 }
 
 func (s *TierStorage) SaveTierConfig(ctx context.Context, config OrgTierConfig) error {
-	collection := s.database.Collection("tier_configs")
+	collection := s.tenants.Collection(config.OrgID, "tier_configs")
 	
 	filter := bson.M{"org_id": config.OrgID}
 	update := bson.M{"$set": config}
@@ -81,13 +227,13 @@ func (s *TierStorage) SaveTierConfig(ctx context.Context, config OrgTierConfig)
 }
 
 func (s *TierStorage) GetTierConfig(ctx context.Context, orgID string) (*OrgTierConfig, error) {
-	collection := s.database.Collection("tier_configs")
+	collection := s.tenants.Collection(orgID, "tier_configs")
 	
 	var config OrgTierConfig
 	err := collection.FindOne(ctx, bson.M{"org_id": orgID}).Decode(&config)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("tier config not found")
+			return nil, ErrTierConfigNotFound
 		}
 		return nil, fmt.Errorf("failed to get tier config: %w", err)
 	}
@@ -96,7 +242,7 @@ func (s *TierStorage) GetTierConfig(ctx context.Context, orgID string) (*OrgTier
 }
 
 func (s *TierStorage) SaveTierUpgrade(ctx context.Context, upgrade TierUpgrade) error {
-	collection := s.database.Collection("tier_upgrades")
+	collection := s.tenants.Collection(upgrade.OrgID, "tier_upgrades")
 	
 	_, err := collection.InsertOne(ctx, upgrade)
 	if err != nil {
@@ -106,13 +252,18 @@ func (s *TierStorage) SaveTierUpgrade(ctx context.Context, upgrade TierUpgrade)
 	return nil
 }
 
-func (s *TierStorage) GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool) ([]TierUpgrade, error) {
-	collection := s.database.Collection("tier_upgrades")
+// GetTierUpgrades lists an org's tier changes. A non-empty direction keeps
+// only changes recorded with that direction.
+func (s *TierStorage) GetTierUpgrades(ctx context.Context, orgID string, unnotifiedOnly bool, direction string) ([]TierUpgrade, error) {
+	collection := s.tenants.Collection(orgID, "tier_upgrades")
 	
 	filter := bson.M{"org_id": orgID}
 	if unnotifiedOnly {
 		filter["notified"] = false
 	}
+	if direction != "" {
+		filter["direction"] = direction
+	}
 	
 	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
@@ -132,12 +283,48 @@ func (s *TierStorage) GetTierUpgrades(ctx context.Context, orgID string, unnotif
 	return upgrades, nil
 }
 
-func (s *TierStorage) MarkUpgradeNotified(ctx context.Context, upgradeID string) error {
-	collection := s.database.Collection("tier_upgrades")
+// GetTierUpgradeFeed lists up to limit of an org's tier changes after the
+// cursor, oldest first
+func (s *TierStorage) GetTierUpgradeFeed(ctx context.Context, orgID string, after UpgradeCursor, limit int) ([]TierUpgrade, error) {
+	collection := s.tenants.Collection(orgID, "tier_upgrades")
+	
+	filter := bson.M{"org_id": orgID}
+	if !after.UpgradedAt.IsZero() {
+		if after.ID.IsZero() {
+			filter["upgraded_at"] = bson.M{"$gt": after.UpgradedAt}
+		} else {
+			filter["$or"] = bson.A{
+				bson.M{"upgraded_at": bson.M{"$gt": after.UpgradedAt}},
+				bson.M{"upgraded_at": after.UpgradedAt, "_id": bson.M{"$gt": after.ID}},
+			}
+		}
+	}
+	
+	opts := options.Find().
+		SetSort(bson.D{{Key: "upgraded_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
 	
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tier upgrades: %w", err)
+	}
+	defer cursor.Close(ctx)
+	
+	var upgrades []TierUpgrade
+	if err := cursor.All(ctx, &upgrades); err != nil {
+		return nil, fmt.Errorf("failed to decode tier upgrades: %w", err)
+	}
+	
+	return upgrades, nil
+}
+
+// MarkUpgradeNotified flags an upgrade as notified. Upgrade IDs carry no org,
+// so each partition is tried until one holds the upgrade, and
+// ErrTierUpgradeNotFound is returned when none does.
+func (s *TierStorage) MarkUpgradeNotified(ctx context.Context, upgradeID string) error {
 	objID, err := primitive.ObjectIDFromHex(upgradeID)
 	if err != nil {
-		return fmt.Errorf("invalid upgrade ID: %w", err)
+		return fmt.Errorf("%w: %v", ErrInvalidUpgradeID, err)
 	}
 	
 	update := bson.M{
@@ -147,16 +334,58 @@ func (s *TierStorage) MarkUpgradeNotified(ctx context.Context, upgradeID string)
 		},
 	}
 	
-	_, err = collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	for _, partition := range s.tenants.Partitions() {
+		result, err := partition.Collection("tier_upgrades").UpdateOne(ctx, bson.M{"_id": objID}, update)
+		if err != nil {
+			return fmt.Errorf("failed to mark upgrade as notified: %w", err)
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+	}
+	
+	return ErrTierUpgradeNotFound
+}
+
+// CountBenefitUsage counts the customer's redemptions of benefit recorded
+// against period
+func (s *TierStorage) CountBenefitUsage(ctx context.Context, orgID, customerID, benefit, period string) (int, error) {
+	collection := s.tenants.Collection(orgID, "benefit_usage")
+	
+	filter := bson.M{
+		"org_id":      orgID,
+		"customer_id": customerID,
+		"benefit":     benefit,
+		"period":      period,
+	}
+	
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count benefit usage: %w", err)
+	}
+	
+	return int(count), nil
+}
+
+// SaveBenefitUsage records a redemption. Uses are unique per customer,
+// benefit and period, so of two redemptions racing for the same use the
+// loser gets ErrBenefitLimitReached instead of exceeding the limit.
+func (s *TierStorage) SaveBenefitUsage(ctx context.Context, usage BenefitUsage) error {
+	collection := s.tenants.Collection(usage.OrgID, "benefit_usage")
+	
+	_, err := collection.InsertOne(ctx, usage)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrBenefitLimitReached
+	}
 	if err != nil {
-		return fmt.Errorf("failed to mark upgrade as notified: %w", err)
+		return fmt.Errorf("failed to save benefit usage: %w", err)
 	}
 	
 	return nil
 }
 
 func (s *TierStorage) GetCustomersByTier(ctx context.Context, orgID, tierName string) ([]CustomerTier, error) {
-	collection := s.database.Collection("customer_tiers")
+	collection := s.tenants.Collection(orgID, "customer_tiers")
 	
 	filter := bson.M{
 		"org_id":       orgID,
@@ -182,7 +411,7 @@ func (s *TierStorage) GetCustomersByTier(ctx context.Context, orgID, tierName st
 }
 
 func (s *TierStorage) GetAllCustomerTiers(ctx context.Context, orgID string) ([]CustomerTier, error) {
-	collection := s.database.Collection("customer_tiers")
+	collection := s.tenants.Collection(orgID, "customer_tiers")
 	
 	cursor, err := collection.Find(ctx, bson.M{"org_id": orgID})
 	if err != nil {
@@ -202,8 +431,32 @@ func (s *TierStorage) GetAllCustomerTiers(ctx context.Context, orgID string) ([]
 	return customers, nil
 }
 
+// GetAllOrgIDs lists every org with customer tiers in any partition, in org
+// ID order
+func (s *TierStorage) GetAllOrgIDs(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var orgIDs []string
+
+	for _, partition := range s.tenants.Partitions() {
+		values, err := partition.Collection("customer_tiers").Distinct(ctx, "org_id", bson.M{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list orgs: %w", err)
+		}
+		for _, value := range values {
+			orgID, ok := value.(string)
+			if ok && orgID != "" && !seen[orgID] {
+				seen[orgID] = true
+				orgIDs = append(orgIDs, orgID)
+			}
+		}
+	}
+
+	sort.Strings(orgIDs)
+	return orgIDs, nil
+}
+
 func (s *TierStorage) GetCustomerTierByLocation(ctx context.Context, orgID, locationID, customerID string) (*CustomerTier, error) {
-	collection := s.database.Collection("customer_tiers")
+	collection := s.tenants.Collection(orgID, "customer_tiers")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -224,7 +477,7 @@ func (s *TierStorage) GetCustomerTierByLocation(ctx context.Context, orgID, loca
 }
 
 func (s *TierStorage) GetCustomersByLocation(ctx context.Context, orgID, locationID string) ([]CustomerTier, error) {
-	collection := s.database.Collection("customer_tiers")
+	collection := s.tenants.Collection(orgID, "customer_tiers")
 	
 	filter := bson.M{
 		"org_id":      orgID,
@@ -250,7 +503,7 @@ func (s *TierStorage) GetCustomersByLocation(ctx context.Context, orgID, locatio
 }
 
 func (s *TierStorage) GetCustomersByTierAndLocation(ctx context.Context, orgID, locationID, tierName string) ([]CustomerTier, error) {
-	collection := s.database.Collection("customer_tiers")
+	collection := s.tenants.Collection(orgID, "customer_tiers")
 	
 	filter := bson.M{
 		"org_id":       orgID,
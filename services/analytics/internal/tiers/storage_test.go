@@ -0,0 +1,419 @@
+package tiers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loyalty/analytics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Test setup helper
+func setupTestStorage(mt *mtest.T) *TierStorage {
+	return NewTierStorage(mt.Client, mt.DB)
+}
+
+func tierUpgradeDoc(customerID, fromTier, toTier, direction string) bson.D {
+	return bson.D{
+		{Key: "org_id", Value: "test_org"},
+		{Key: "customer_id", Value: customerID},
+		{Key: "from_tier", Value: fromTier},
+		{Key: "to_tier", Value: toTier},
+		{Key: "direction", Value: direction},
+	}
+}
+
+// Test GetTierUpgrades
+func TestGetTierUpgrades_FiltersByDirection(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("downgrades only", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.tier_upgrades", mtest.FirstBatch,
+			tierUpgradeDoc("cust_2", "Gold", "Silver", TierDirectionDowngrade),
+			tierUpgradeDoc("cust_4", "Silver", "Bronze", TierDirectionDowngrade),
+		))
+
+		upgrades, err := storage.GetTierUpgrades(context.Background(), "test_org", true, TierDirectionDowngrade)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, upgrades, 2)
+		for _, upgrade := range upgrades {
+			assert.Equal(t, TierDirectionDowngrade, upgrade.Direction)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, "test_org", filter.Lookup("org_id").StringValue())
+		assert.False(t, filter.Lookup("notified").Boolean())
+		assert.Equal(t, TierDirectionDowngrade, filter.Lookup("direction").StringValue())
+	})
+
+	mt.Run("no direction returns every change", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.tier_upgrades", mtest.FirstBatch,
+			tierUpgradeDoc("cust_1", "Bronze", "Silver", TierDirectionUpgrade),
+			tierUpgradeDoc("cust_2", "Gold", "Silver", TierDirectionDowngrade),
+		))
+
+		upgrades, err := storage.GetTierUpgrades(context.Background(), "test_org", false, "")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, upgrades, 2)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		_, hasDirection := filter.LookupErr("direction")
+		assert.Error(t, hasDirection)
+		_, hasNotified := filter.LookupErr("notified")
+		assert.Error(t, hasNotified)
+	})
+}
+
+// Test GetTierUpgradeFeed
+func TestGetTierUpgradeFeed_FiltersAfterCursor(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	since := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+	mt.Run("timestamp cursor", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.tier_upgrades", mtest.FirstBatch,
+			tierUpgradeDoc("cust_2", "Silver", "Gold", TierDirectionUpgrade),
+		))
+
+		upgrades, err := storage.GetTierUpgradeFeed(context.Background(), "test_org", UpgradeCursor{UpgradedAt: since}, 50)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, upgrades, 1)
+
+		command := mt.GetStartedEvent().Command
+		filter := command.Lookup("filter").Document()
+		assert.Equal(t, "test_org", filter.Lookup("org_id").StringValue())
+		assert.Equal(t, since.UnixMilli(), filter.Lookup("upgraded_at", "$gt").Time().UnixMilli())
+		assert.Equal(t, int64(50), command.Lookup("limit").AsInt64())
+
+		sort := command.Lookup("sort").Document()
+		assert.Equal(t, int32(1), sort.Lookup("upgraded_at").Int32())
+		assert.Equal(t, int32(1), sort.Lookup("_id").Int32())
+	})
+
+	mt.Run("cursor with ID breaks timestamp ties", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.tier_upgrades", mtest.FirstBatch))
+		id := primitive.NewObjectID()
+
+		_, err := storage.GetTierUpgradeFeed(context.Background(), "test_org", UpgradeCursor{UpgradedAt: since, ID: id}, 50)
+
+		// Assertions
+		assert.NoError(t, err)
+		or := mt.GetStartedEvent().Command.Lookup("filter", "$or").Array()
+		tie := or.Index(1).Value().Document()
+		assert.Equal(t, since.UnixMilli(), tie.Lookup("upgraded_at").Time().UnixMilli())
+		assert.Equal(t, id, tie.Lookup("_id", "$gt").ObjectID())
+	})
+
+	mt.Run("empty cursor starts at the oldest change", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.tier_upgrades", mtest.FirstBatch))
+
+		_, err := storage.GetTierUpgradeFeed(context.Background(), "test_org", UpgradeCursor{}, 50)
+
+		// Assertions
+		assert.NoError(t, err)
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		_, hasUpgradedAt := filter.LookupErr("upgraded_at")
+		assert.Error(t, hasUpgradedAt)
+	})
+}
+
+// Test tenant routing
+func TestSaveCustomerTier_UsesTenantDatabase(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("tenant org", func(mt *mtest.T) {
+		tenants := storage.NewTenantRouter(mt.Client, mt.DB, storage.TenantConfig{"org_a": {Database: "analytics_org_a"}})
+		tierStorage := NewTierStorageWithTenants(mt.Client, mt.DB, tenants)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		err := tierStorage.SaveCustomerTier(context.Background(), CustomerTier{OrgID: "org_a", CustomerID: "cust_1", CurrentTier: "Gold"})
+
+		// Assertions
+		assert.NoError(t, err)
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "analytics_org_a", started.DatabaseName)
+		assert.Equal(t, "customer_tiers", started.Command.Lookup("update").StringValue())
+	})
+}
+
+func TestMarkUpgradeNotified_SearchesPartitions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("upgrade in tenant database", func(mt *mtest.T) {
+		tenants := storage.NewTenantRouter(mt.Client, mt.DB, storage.TenantConfig{"org_a": {Database: "analytics_org_a"}})
+		tierStorage := NewTierStorageWithTenants(mt.Client, mt.DB, tenants)
+
+		// Not in the shared database, then found in org_a's
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+		)
+
+		err := tierStorage.MarkUpgradeNotified(context.Background(), "65f1c0a2b3d4e5f6a7b8c9d0")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, mt.DB.Name(), mt.GetStartedEvent().DatabaseName)
+		assert.Equal(t, "analytics_org_a", mt.GetStartedEvent().DatabaseName)
+	})
+}
+
+func TestMarkUpgradeNotified_NotFound(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("no partition holds the upgrade", func(mt *mtest.T) {
+		tierStorage := setupTestStorage(mt)
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		err := tierStorage.MarkUpgradeNotified(context.Background(), "65f1c0a2b3d4e5f6a7b8c9d0")
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrTierUpgradeNotFound)
+	})
+
+	mt.Run("invalid ID", func(mt *mtest.T) {
+		tierStorage := setupTestStorage(mt)
+
+		err := tierStorage.MarkUpgradeNotified(context.Background(), "not-an-id")
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrInvalidUpgradeID)
+	})
+}
+
+// Test GetAllOrgIDs
+func TestGetAllOrgIDs_MergesPartitions(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("shared and tenant databases", func(mt *mtest.T) {
+		tenants := storage.NewTenantRouter(mt.Client, mt.DB, storage.TenantConfig{"org_a": {Database: "analytics_org_a"}})
+		tierStorage := NewTierStorageWithTenants(mt.Client, mt.DB, tenants)
+
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "values", Value: bson.A{"org_c", "org_b"}}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "values", Value: bson.A{"org_a", "org_b"}}},
+		)
+
+		orgIDs, err := tierStorage.GetAllOrgIDs(context.Background())
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"org_a", "org_b", "org_c"}, orgIDs)
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "distinct", started.CommandName)
+		assert.Equal(t, "customer_tiers", started.Command.Lookup("distinct").StringValue())
+	})
+}
+
+// Test benefit usage
+func TestSaveBenefitUsage_DuplicateUseIsLimitReached(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("duplicate key", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}))
+
+		err := storage.SaveBenefitUsage(context.Background(), BenefitUsage{OrgID: "test_org", CustomerID: "cust_1", Benefit: "Monthly offers", Period: "2026-10", Use: 1})
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrBenefitLimitReached)
+	})
+}
+
+func TestCountBenefitUsage_FiltersByPeriod(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("count", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.benefit_usage", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(1)}}))
+
+		count, err := storage.CountBenefitUsage(context.Background(), "test_org", "cust_1", "Monthly offers", "2026-10")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		pipeline := mt.GetStartedEvent().Command.Lookup("pipeline").Array()
+		match := pipeline.Index(0).Value().Document().Lookup("$match").Document()
+		assert.Equal(t, "cust_1", match.Lookup("customer_id").StringValue())
+		assert.Equal(t, "Monthly offers", match.Lookup("benefit").StringValue())
+		assert.Equal(t, "2026-10", match.Lookup("period").StringValue())
+	})
+}
+
+// Test AccumulateCustomerMetrics
+func metricsResponse(visits int32, spent float64) bson.D {
+	return bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "n", Value: 1},
+		{Key: "value", Value: bson.D{
+			{Key: "org_id", Value: "test_org"},
+			{Key: "customer_id", Value: "cust_1"},
+			{Key: "total_spent", Value: spent},
+			{Key: "total_visits", Value: visits},
+			{Key: "spent_this_year", Value: spent},
+			{Key: "visits_this_year", Value: visits},
+			{Key: "spent_this_month", Value: spent},
+			{Key: "visits_this_month", Value: visits},
+		}},
+	}
+}
+
+func TestAccumulateCustomerMetrics_ConcurrentTransactionsAllCount(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("parallel transactions", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		amounts := []float64{20, 30, 45.5, 4.5}
+		// Each transaction resets stale periods then increments; every reply
+		// carries a document so the calls can interleave in any order
+		for i := 0; i < len(amounts)*3; i++ {
+			mt.AddMockResponses(metricsResponse(1, 20))
+		}
+
+		var wg sync.WaitGroup
+		for _, amount := range amounts {
+			wg.Add(1)
+			go func(amount float64) {
+				defer wg.Done()
+				_, err := storage.AccumulateCustomerMetrics(context.Background(), "test_org", "store_downtown", "cust_1", amount, time.Now())
+				assert.NoError(t, err)
+			}(amount)
+		}
+		wg.Wait()
+
+		// Apply every increment to the stored totals, in whichever order they ran
+		var totalVisits, visitsThisYear, visitsThisMonth int32
+		var totalSpent, spentThisYear, spentThisMonth float64
+		for _, started := range mt.GetAllStartedEvents() {
+			if started.CommandName != "findAndModify" {
+				continue
+			}
+			update := started.Command.Lookup("update").Document()
+			for _, field := range metricFields {
+				_, err := update.Lookup("$set").Document().LookupErr(field)
+				assert.Error(t, err, "%s must not be overwritten", field)
+			}
+
+			inc := update.Lookup("$inc").Document()
+			totalVisits += inc.Lookup("total_visits").Int32()
+			visitsThisYear += inc.Lookup("visits_this_year").Int32()
+			visitsThisMonth += inc.Lookup("visits_this_month").Int32()
+			totalSpent += inc.Lookup("total_spent").Double()
+			spentThisYear += inc.Lookup("spent_this_year").Double()
+			spentThisMonth += inc.Lookup("spent_this_month").Double()
+		}
+
+		// Assertions
+		assert.Equal(t, int32(4), totalVisits)
+		assert.Equal(t, int32(4), visitsThisYear)
+		assert.Equal(t, int32(4), visitsThisMonth)
+		assert.Equal(t, 100.0, totalSpent)
+		assert.Equal(t, 100.0, spentThisYear)
+		assert.Equal(t, 100.0, spentThisMonth)
+	})
+}
+
+func TestAccumulateCustomerMetrics_ResetsOnlyStalePeriods(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("purchase", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(), metricsResponse(3, 75))
+
+		metrics, err := storage.AccumulateCustomerMetrics(context.Background(), "test_org", "store_downtown", "cust_1", 25, time.Now())
+
+		// Assertions - the stored totals are returned with the transaction
+		assert.NoError(t, err)
+		assert.Equal(t, 3, metrics.TotalVisits)
+		assert.Equal(t, 75.0, metrics.SpentThisYear)
+		assert.Equal(t, 25.0, metrics.TransactionAmount)
+
+		events := mt.GetAllStartedEvents()
+		assert.Len(t, events, 3)
+		now := time.Now()
+		for i, start := range []time.Time{
+			time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location()),
+			time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()),
+		} {
+			statement := events[i].Command.Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(t, start.UTC(), statement.Lookup("q", "last_transaction", "$lt").Time().UTC())
+		}
+	})
+}
+
+func TestAccumulateCustomerMetrics_RefundClampsSpend(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("refund larger than spend", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(), metricsResponse(2, -10), mtest.CreateSuccessResponse())
+
+		metrics, err := storage.AccumulateCustomerMetrics(context.Background(), "test_org", "store_downtown", "cust_1", -40, time.Now())
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, metrics.TotalSpent)
+		assert.Equal(t, 0.0, metrics.SpentThisMonth)
+		assert.Equal(t, 2, metrics.TotalVisits)
+
+		events := mt.GetAllStartedEvents()
+		assert.Len(t, events, 4)
+		update := events[2].Command.Lookup("update").Document()
+		_, err = update.Lookup("$inc").Document().LookupErr("total_visits")
+		assert.Error(t, err, "a refund is not a visit")
+		_, err = update.LookupErr("$max")
+		assert.Error(t, err, "a refund does not move the last transaction")
+
+		clamp := events[3].Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, 0.0, clamp.Lookup("u", "$max", "total_spent").Double())
+	})
+}
+
+func TestSaveCustomerTier_LeavesMetricsAlone(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("save", func(mt *mtest.T) {
+		storage := setupTestStorage(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		err := storage.SaveCustomerTier(context.Background(), CustomerTier{OrgID: "test_org", CustomerID: "cust_1", CurrentTier: "Gold", TotalSpent: 500, TotalVisits: 10})
+
+		// Assertions
+		assert.NoError(t, err)
+		set := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		assert.Equal(t, "Gold", set.Lookup("current_tier").StringValue())
+		for _, field := range metricFields {
+			_, err := set.LookupErr(field)
+			assert.Error(t, err, "%s must not be overwritten", field)
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/loyalty/ledger/internal/handlers"
@@ -12,9 +14,37 @@ import (
 func main() {
 	log.Println("Starting Ledger Service with Mock TigerBeetle...")
 	
+	expiryInterval := time.Hour
+	if interval := os.Getenv("POINTS_EXPIRY_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid POINTS_EXPIRY_INTERVAL %q", interval)
+		}
+		expiryInterval = parsed
+	}
+
+	settlementInterval := time.Minute
+	if interval := os.Getenv("PENDING_SETTLEMENT_INTERVAL"); interval != "" {
+		parsed, err := time.ParseDuration(interval)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid PENDING_SETTLEMENT_INTERVAL %q", interval)
+		}
+		settlementInterval = parsed
+	}
+
 	repo := repository.NewMockTigerBeetleRepo()
 	defer repo.Close()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if expiryInterval > 0 {
+		go scheduledPointsExpiry(ctx, repo, expiryInterval)
+	}
+	if settlementInterval > 0 {
+		go scheduledSettlement(ctx, repo, settlementInterval)
+	}
+
 	handler := handlers.NewLedgerHandler(repo)
 
 	r := gin.Default()
@@ -23,8 +53,16 @@ func main() {
 	{
 		v1.POST("/accounts", handler.CreateAccount)
 		v1.GET("/accounts/:id", handler.GetAccount)
+		v1.GET("/accounts/:id/balance", handler.GetAccountBalance)
 		v1.POST("/transfers", handler.CreateTransfer)
+		v1.GET("/transfers", handler.ListTransfers)
+		v1.POST("/transfers/settle", handler.SettleTransfer)
+		v1.POST("/transfers/:id/reverse", handler.ReverseTransfer)
+		v1.POST("/redemptions", handler.CreateRedemption)
 		v1.GET("/balance", handler.GetBalance)
+		v1.GET("/balance/summary", handler.GetBalanceSummary)
+		v1.GET("/liability", handler.GetLiability)
+		v1.GET("/trial-balance", handler.GetTrialBalance)
 		v1.GET("/health", handler.Health)
 	}
 
@@ -37,4 +75,49 @@ func main() {
 	if err := r.Run(":" + port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// scheduledPointsExpiry takes back expired points every interval
+func scheduledPointsExpiry(ctx context.Context, repo repository.TigerBeetleRepoInterface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := repo.ExpirePoints(ctx)
+			if err != nil {
+				log.Printf("Error expiring points: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Expired %d points", expired)
+			}
+		}
+	}
+}
+
+// scheduledSettlement posts pending accruals whose settlement delay has passed
+// every interval
+func scheduledSettlement(ctx context.Context, repo repository.TigerBeetleRepoInterface, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			posted, err := repo.SettleDuePendingTransfers(ctx)
+			if err != nil {
+				log.Printf("Error settling pending transfers: %v", err)
+				continue
+			}
+			if posted > 0 {
+				log.Printf("Settled %d pending points", posted)
+			}
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/loyalty/ledger/internal/models"
@@ -39,7 +43,49 @@ func (h *LedgerHandler) CreateTransfer(c *gin.Context) {
 		return
 	}
 
+	// Amount is validated here rather than with binding:"required" so a zero
+	// transfer gets a clear error instead of a missing-field one
+	if req.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be greater than zero"})
+		return
+	}
+	if req.Pending && req.TransactionType != "points_accrual" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only points_accrual transfers can be pending"})
+		return
+	}
+
 	response, err := h.repo.CreateTransfer(c.Request.Context(), &req)
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// CreateRedemption redeems a reward, crediting any bonus points in the same
+// ledger operation as the cost
+func (h *LedgerHandler) CreateRedemption(c *gin.Context) {
+	var req models.CreateRedemptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Points == 0 && req.Stamps == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "redemption must cost points or stamps"})
+		return
+	}
+
+	response, err := h.repo.CreateRedemption(c.Request.Context(), &req)
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -64,10 +110,44 @@ func (h *LedgerHandler) GetAccount(c *gin.Context) {
 	c.JSON(http.StatusOK, account)
 }
 
+// GetAccountBalance returns the posted and pending debits and credits of any
+// account, customer or org, for reconciliation
+func (h *LedgerHandler) GetAccountBalance(c *gin.Context) {
+	accountID := c.Param("id")
+	if accountID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account ID is required"})
+		return
+	}
+
+	account, err := h.repo.GetAccount(c.Request.Context(), accountID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAccountBalance(account))
+}
+
+func newAccountBalance(account *models.Account) models.AccountBalance {
+	return models.AccountBalance{
+		AccountID:      account.ID,
+		OrgID:          account.OrgID,
+		CustomerID:     account.CustomerID,
+		AccountType:    account.AccountType,
+		Code:           account.Code,
+		DebitsPosted:   account.DebitsPosted,
+		DebitsPending:  account.DebitsPending,
+		CreditsPosted:  account.CreditsPosted,
+		CreditsPending: account.CreditsPending,
+		NetPosted:      int64(account.CreditsPosted) - int64(account.DebitsPosted),
+		NetPending:     int64(account.CreditsPending) - int64(account.DebitsPending),
+	}
+}
+
 func (h *LedgerHandler) GetBalance(c *gin.Context) {
 	orgID := c.Query("org_id")
 	customerID := c.Query("customer_id")
-	
+
 	if orgID == "" || customerID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id and customer_id are required"})
 		return
@@ -87,9 +167,270 @@ func (h *LedgerHandler) GetBalance(c *gin.Context) {
 	})
 }
 
+// GetBalanceSummary returns points and stamps balances together with stamp card
+// progress and the next points to expire. max_stamps_per_card is the org's
+// card size; when it is omitted or zero the card progress fields are left
+// empty.
+func (h *LedgerHandler) GetBalanceSummary(c *gin.Context) {
+	orgID := c.Query("org_id")
+	customerID := c.Query("customer_id")
+
+	if orgID == "" || customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id and customer_id are required"})
+		return
+	}
+
+	var maxStampsPerCard uint64
+	if value := c.Query("max_stamps_per_card"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_stamps_per_card must be a non-negative integer"})
+			return
+		}
+		maxStampsPerCard = parsed
+	}
+
+	balances, err := h.repo.GetBalance(c.Request.Context(), orgID, customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary := newBalanceSummary(orgID, customerID, balances, maxStampsPerCard)
+	summary.ExpiringPoints, summary.PointsExpireAt, err = h.repo.GetPointsExpiry(c.Request.Context(), orgID, customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func newBalanceSummary(orgID, customerID string, balances map[string]uint64, maxStampsPerCard uint64) models.BalanceSummary {
+	summary := models.BalanceSummary{
+		OrgID:            orgID,
+		CustomerID:       customerID,
+		PointsBalance:    balances["points"],
+		StampsBalance:    balances["stamps"],
+		MaxStampsPerCard: maxStampsPerCard,
+	}
+
+	if maxStampsPerCard > 0 {
+		summary.CompletedCards = summary.StampsBalance / maxStampsPerCard
+		summary.StampsOnCurrentCard = summary.StampsBalance % maxStampsPerCard
+		summary.StampsToNextCard = maxStampsPerCard - summary.StampsOnCurrentCard
+	}
+
+	return summary
+}
+
+// GetLiability returns the points and stamps outstanding across an org's
+// customers
+func (h *LedgerHandler) GetLiability(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	liability, err := h.repo.GetOrgLiability(c.Request.Context(), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"org_id":             orgID,
+		"points_outstanding": liability["points"],
+		"stamps_outstanding": liability["stamps"],
+	})
+}
+
+// GetTrialBalance sums the posted debits and credits of each transfer code
+// across all of an org's accounts, and pending ones too with
+// include_pending=true, flagging the org as unbalanced when any code's differ
+func (h *LedgerHandler) GetTrialBalance(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	includePending := false
+	if value := c.Query("include_pending"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "include_pending must be true or false"})
+			return
+		}
+		includePending = parsed
+	}
+
+	accounts, err := h.repo.ListAccounts(c.Request.Context(), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	trialBalance := newTrialBalance(orgID, accounts, includePending)
+	for _, code := range trialBalance.Codes {
+		if !code.Balanced {
+			log.Printf("Trial balance for org %s is out of balance for code %d: debits %d, credits %d",
+				orgID, code.Code, code.TotalDebits, code.TotalCredits)
+		}
+	}
+
+	c.JSON(http.StatusOK, trialBalance)
+}
+
+func newTrialBalance(orgID string, accounts []*models.Account, includePending bool) models.TrialBalance {
+	trialBalance := models.TrialBalance{
+		OrgID:          orgID,
+		Accounts:       make([]models.AccountBalance, 0, len(accounts)),
+		IncludePending: includePending,
+		Codes:          []models.CodeBalance{},
+		Balanced:       true,
+	}
+
+	codes := map[uint16]*models.CodeBalance{}
+	for _, account := range accounts {
+		trialBalance.Accounts = append(trialBalance.Accounts, newAccountBalance(account))
+
+		code, ok := codes[account.Code]
+		if !ok {
+			code = &models.CodeBalance{Code: account.Code}
+			codes[account.Code] = code
+		}
+		code.TotalDebits += account.DebitsPosted
+		code.TotalCredits += account.CreditsPosted
+		if includePending {
+			code.TotalDebits += account.DebitsPending
+			code.TotalCredits += account.CreditsPending
+		}
+	}
+
+	for _, code := range codes {
+		code.Difference = int64(code.TotalCredits) - int64(code.TotalDebits)
+		code.Balanced = code.Difference == 0
+		if !code.Balanced {
+			trialBalance.Balanced = false
+		}
+		trialBalance.Codes = append(trialBalance.Codes, *code)
+	}
+	sort.Slice(trialBalance.Codes, func(i, j int) bool {
+		return trialBalance.Codes[i].Code < trialBalance.Codes[j].Code
+	})
+
+	return trialBalance
+}
+
+// ListTransfers returns a page of a customer's transfers, newest first,
+// optionally only those with one of the given reference parameters
+func (h *LedgerHandler) ListTransfers(c *gin.Context) {
+	orgID := c.Query("org_id")
+	customerID := c.Query("customer_id")
+
+	if orgID == "" || customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id and customer_id are required"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset parameter"})
+		return
+	}
+
+	transfers, err := h.repo.ListTransfers(c.Request.Context(), orgID, customerID, limit, offset, c.QueryArray("reference")...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if transfers == nil {
+		transfers = []*models.Transfer{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"org_id":      orgID,
+		"customer_id": customerID,
+		"transfers":   transfers,
+		"count":       len(transfers),
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// ReverseTransfer posts a compensating transfer that undoes a single transfer.
+// The request body is optional.
+func (h *LedgerHandler) ReverseTransfer(c *gin.Context) {
+	transferID := c.Param("id")
+	if transferID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "transfer ID is required"})
+		return
+	}
+
+	var req models.ReverseTransferRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	response, err := h.repo.ReverseTransfer(c.Request.Context(), transferID, req.Reference)
+	if errors.Is(err, repository.ErrTransferNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repository.ErrTransferAlreadyReversed) || errors.Is(err, repository.ErrTransferPending) ||
+		errors.Is(err, repository.ErrInsufficientBalance) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// SettleTransfer posts a customer's pending points accrual, crediting the
+// points, or voids it so they are never spendable
+func (h *LedgerHandler) SettleTransfer(c *gin.Context) {
+	var req models.SettleTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Amount > 0 && !req.Void {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount can only be given when voiding"})
+		return
+	}
+
+	response, err := h.repo.SettlePendingTransfer(c.Request.Context(), &req)
+	if errors.Is(err, repository.ErrTransferNotPending) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 func (h *LedgerHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"status": "healthy",
+		"status":  "healthy",
 		"service": "ledger",
 	})
-}
\ No newline at end of file
+}
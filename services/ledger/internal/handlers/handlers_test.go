@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/loyalty/ledger/internal/models"
+	"github.com/loyalty/ledger/internal/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockTigerBeetleRepo is a mock implementation of the repository
@@ -35,6 +38,14 @@ func (m *MockTigerBeetleRepo) CreateTransfer(ctx context.Context, req *models.Cr
 	return args.Get(0).(*models.TransferResponse), args.Error(1)
 }
 
+func (m *MockTigerBeetleRepo) CreateRedemption(ctx context.Context, req *models.CreateRedemptionRequest) (*models.RedemptionResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.RedemptionResponse), args.Error(1)
+}
+
 func (m *MockTigerBeetleRepo) GetAccount(ctx context.Context, accountID string) (*models.Account, error) {
 	args := m.Called(ctx, accountID)
 	if args.Get(0) == nil {
@@ -43,6 +54,14 @@ func (m *MockTigerBeetleRepo) GetAccount(ctx context.Context, accountID string)
 	return args.Get(0).(*models.Account), args.Error(1)
 }
 
+func (m *MockTigerBeetleRepo) ListAccounts(ctx context.Context, orgID string) ([]*models.Account, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Account), args.Error(1)
+}
+
 func (m *MockTigerBeetleRepo) GetBalance(ctx context.Context, orgID, customerID string) (map[string]uint64, error) {
 	args := m.Called(ctx, orgID, customerID)
 	if args.Get(0) == nil {
@@ -51,6 +70,53 @@ func (m *MockTigerBeetleRepo) GetBalance(ctx context.Context, orgID, customerID
 	return args.Get(0).(map[string]uint64), args.Error(1)
 }
 
+func (m *MockTigerBeetleRepo) GetOrgLiability(ctx context.Context, orgID string) (map[string]uint64, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]uint64), args.Error(1)
+}
+
+func (m *MockTigerBeetleRepo) ListTransfers(ctx context.Context, orgID, customerID string, limit, offset int, references ...string) ([]*models.Transfer, error) {
+	args := m.Called(ctx, orgID, customerID, limit, offset, references)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Transfer), args.Error(1)
+}
+
+func (m *MockTigerBeetleRepo) ReverseTransfer(ctx context.Context, transferID, reference string) (*models.TransferResponse, error) {
+	args := m.Called(ctx, transferID, reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TransferResponse), args.Error(1)
+}
+
+func (m *MockTigerBeetleRepo) SettlePendingTransfer(ctx context.Context, req *models.SettleTransferRequest) (*models.TransferResponse, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TransferResponse), args.Error(1)
+}
+
+func (m *MockTigerBeetleRepo) SettleDuePendingTransfers(ctx context.Context) (uint64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (m *MockTigerBeetleRepo) ExpirePoints(ctx context.Context) (uint64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (m *MockTigerBeetleRepo) GetPointsExpiry(ctx context.Context, orgID, customerID string) (uint64, uint64, error) {
+	args := m.Called(ctx, orgID, customerID)
+	return args.Get(0).(uint64), args.Get(1).(uint64), args.Error(2)
+}
+
 func (m *MockTigerBeetleRepo) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -247,7 +313,7 @@ func TestCreateTransfer_InvalidRequest(t *testing.T) {
 	assert.Contains(t, response["error"], "invalid")
 }
 
-func TestCreateTransfer_RepositoryError(t *testing.T) {
+func TestCreateTransfer_ZeroAmount(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
@@ -258,16 +324,13 @@ func TestCreateTransfer_RepositoryError(t *testing.T) {
 		OrgID:           "test_org",
 		CustomerID:      "test_customer",
 		TransactionType: "points_accrual",
-		Amount:          100,
+		Amount:          0,
 		Code:            1,
 		Reference:       "test_transfer",
 	}
 	
 	jsonData, _ := json.Marshal(reqBody)
 	
-	// Mock repository error
-	mockRepo.On("CreateTransfer", mock.Anything, &reqBody).Return(nil, assert.AnError)
-	
 	// Create request
 	req, _ := http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
@@ -277,141 +340,307 @@ func TestCreateTransfer_RepositoryError(t *testing.T) {
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 	
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response["error"], "assert.AnError")
+	assert.Equal(t, "amount must be greater than zero", response["error"])
 	
-	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CreateTransfer")
 }
 
-// Test GetAccount
-func TestGetAccount_Success(t *testing.T) {
+func TestCreateTransfer_RepositoryError(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/accounts/:id", handler.GetAccount)
+	router.POST("/transfers", handler.CreateTransfer)
 	
-	// Mock repository response
-	expectedAccount := &models.Account{
-		ID:          "acc_123",
-		OrgID:       "test_org",
-		CustomerID:  "test_customer",
-		AccountType: models.AccountTypeAsset,
-		Code:        1001,
+	// Test data
+	reqBody := models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "points_accrual",
+		Amount:          100,
+		Code:            1,
+		Reference:       "test_transfer",
 	}
 	
-	mockRepo.On("GetAccount", mock.Anything, "acc_123").Return(expectedAccount, nil)
+	jsonData, _ := json.Marshal(reqBody)
+	
+	// Mock repository error
+	mockRepo.On("CreateTransfer", mock.Anything, &reqBody).Return(nil, assert.AnError)
 	
 	// Create request
-	req, _ := http.NewRequest("GET", "/accounts/acc_123", nil)
+	req, _ := http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	
-	var response models.Account
+	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "acc_123", response.ID)
-	assert.Equal(t, "test_org", response.OrgID)
+	assert.Contains(t, response["error"], "assert.AnError")
 	
 	mockRepo.AssertExpectations(t)
 }
 
-func TestGetAccount_MissingID(t *testing.T) {
-	router, _, handler := setupTest()
+func TestCreateTransfer_InsufficientBalance(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/transfers", handler.CreateTransfer)
+
+	jsonData, _ := json.Marshal(models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "points_redemption",
+		Amount:          500,
+	})
+
+	mockRepo.On("CreateTransfer", mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("%w: have 120, need 500", repository.ErrInsufficientBalance))
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "insufficient balance: have 120, need 500", response["error"])
+
+	mockRepo.AssertExpectations(t)
+}
+
+// Test ReverseTransfer
+func TestCreateTransfer_PendingStampsRejected(t *testing.T) {
+	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/accounts/:id", handler.GetAccount)
+	router.POST("/transfers", handler.CreateTransfer)
 	
-	// Create request without ID
-	req, _ := http.NewRequest("GET", "/accounts/", nil)
+	// Create request
+	req, _ := http.NewRequest("POST", "/transfers", bytes.NewBufferString(
+		`{"org_id":"test_org","customer_id":"test_customer","transaction_type":"stamps_accrual","amount":1,"pending":true}`))
+	req.Header.Set("Content-Type", "application/json")
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "only points_accrual transfers can be pending")
+	mockRepo.AssertNotCalled(t, "CreateTransfer", mock.Anything, mock.Anything)
 }
 
-func TestGetAccount_NotFound(t *testing.T) {
+// Test SettleTransfer
+func TestSettleTransfer_Success(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/accounts/:id", handler.GetAccount)
-	
-	// Mock repository error
-	mockRepo.On("GetAccount", mock.Anything, "nonexistent").Return(nil, assert.AnError)
+	router.POST("/transfers/settle", handler.SettleTransfer)
+	
+	expected := &models.SettleTransferRequest{
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Reference:  "pos_transaction_txn_1",
+		Void:       true,
+		Amount:     40,
+	}
+	mockRepo.On("SettlePendingTransfer", mock.Anything, expected).
+		Return(&models.TransferResponse{TransferID: "transfer_123", Status: "pending"}, nil)
 	
 	// Create request
-	req, _ := http.NewRequest("GET", "/accounts/nonexistent", nil)
+	req, _ := http.NewRequest("POST", "/transfers/settle", bytes.NewBufferString(
+		`{"org_id":"test_org","customer_id":"test_customer","reference":"pos_transaction_txn_1","void":true,"amount":40}`))
+	req.Header.Set("Content-Type", "application/json")
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
 	
-	var response map[string]interface{}
+	var response models.TransferResponse
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response["error"], "assert.AnError")
+	assert.Equal(t, "transfer_123", response.TransferID)
+	assert.Equal(t, "pending", response.Status)
 	
 	mockRepo.AssertExpectations(t)
 }
 
-// Test GetBalance
-func TestGetBalance_Success(t *testing.T) {
+func TestSettleTransfer_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		err      error
+		expected int
+	}{
+		{"nothing pending", `{"org_id":"test_org","customer_id":"test_customer","reference":"ref"}`, repository.ErrTransferNotPending, http.StatusConflict},
+		{"repository error", `{"org_id":"test_org","customer_id":"test_customer","reference":"ref"}`, assert.AnError, http.StatusInternalServerError},
+		{"missing reference", `{"org_id":"test_org","customer_id":"test_customer"}`, nil, http.StatusBadRequest},
+		{"amount without void", `{"org_id":"test_org","customer_id":"test_customer","reference":"ref","amount":5}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockRepo, handler := setupTest()
+			router.POST("/transfers/settle", handler.SettleTransfer)
+
+			if tt.err != nil {
+				mockRepo.On("SettlePendingTransfer", mock.Anything, mock.Anything).Return(nil, tt.err)
+			}
+
+			req, _ := http.NewRequest("POST", "/transfers/settle", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, tt.expected, w.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReverseTransfer_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/transfers/:id/reverse", handler.ReverseTransfer)
+
+	mockRepo.On("ReverseTransfer", mock.Anything, "transfer_123", "support_ticket_42").
+		Return(&models.TransferResponse{TransferID: "transfer_456", Status: "success"}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/transfers/transfer_123/reverse", bytes.NewBufferString(`{"reference":"support_ticket_42"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.TransferResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer_456", response.TransferID)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReverseTransfer_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"already reversed", fmt.Errorf("%w by transfer_456", repository.ErrTransferAlreadyReversed), http.StatusConflict},
+		{"pending transfer", fmt.Errorf("%w: transfer transfer_123 is pending", repository.ErrTransferPending), http.StatusConflict},
+		{"insufficient balance", repository.ErrInsufficientBalance, http.StatusConflict},
+		{"unknown transfer", repository.ErrTransferNotFound, http.StatusNotFound},
+		{"repository error", assert.AnError, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockRepo, handler := setupTest()
+			router.POST("/transfers/:id/reverse", handler.ReverseTransfer)
+
+			// Without a body the default reference is used
+			mockRepo.On("ReverseTransfer", mock.Anything, "transfer_123", "").Return(nil, tt.err)
+
+			req, _ := http.NewRequest("POST", "/transfers/transfer_123/reverse", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, tt.expected, w.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// Test GetAccount
+// Test CreateRedemption
+func TestCreateRedemption_Success(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/balance", handler.GetBalance)
+	router.POST("/redemptions", handler.CreateRedemption)
+	
+	// Test data
+	reqBody := models.CreateRedemptionRequest{
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		RewardID:    "free_coffee",
+		Points:      300,
+		BonusPoints: 25,
+	}
+	
+	jsonData, _ := json.Marshal(reqBody)
 	
 	// Mock repository response
-	expectedBalances := map[string]uint64{
-		"points": 500,
-		"stamps": 10,
+	expectedResponse := &models.RedemptionResponse{
+		TransferIDs: []string{"transfer_cost", "transfer_bonus"},
+		Status:      "success",
 	}
 	
-	mockRepo.On("GetBalance", mock.Anything, "test_org", "test_customer").Return(expectedBalances, nil)
+	mockRepo.On("CreateRedemption", mock.Anything, &reqBody).Return(expectedResponse, nil)
 	
 	// Create request
-	req, _ := http.NewRequest("GET", "/balance?org_id=test_org&customer_id=test_customer", nil)
+	req, _ := http.NewRequest("POST", "/redemptions", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusCreated, w.Code)
 	
-	var response map[string]interface{}
+	var response models.RedemptionResponse
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "test_org", response["org_id"])
-	assert.Equal(t, "test_customer", response["customer_id"])
-	assert.Equal(t, float64(500), response["points_balance"])
-	assert.Equal(t, float64(10), response["stamps_balance"])
+	assert.Equal(t, []string{"transfer_cost", "transfer_bonus"}, response.TransferIDs)
 	
 	mockRepo.AssertExpectations(t)
 }
 
-func TestGetBalance_MissingOrgID(t *testing.T) {
-	router, _, handler := setupTest()
+func TestCreateRedemption_NoCost(t *testing.T) {
+	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/balance", handler.GetBalance)
+	router.POST("/redemptions", handler.CreateRedemption)
 	
-	// Create request without org_id
-	req, _ := http.NewRequest("GET", "/balance?customer_id=test_customer", nil)
+	jsonData, _ := json.Marshal(models.CreateRedemptionRequest{
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		RewardID:    "free_coffee",
+		BonusPoints: 25,
+	})
+	
+	// Create request
+	req, _ := http.NewRequest("POST", "/redemptions", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
 	
 	// Record response
 	w := httptest.NewRecorder()
@@ -419,63 +648,702 @@ func TestGetBalance_MissingOrgID(t *testing.T) {
 	
 	// Assertions
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Contains(t, response["error"], "org_id and customer_id are required")
+	mockRepo.AssertNotCalled(t, "CreateRedemption", mock.Anything, mock.Anything)
 }
 
-func TestGetBalance_MissingCustomerID(t *testing.T) {
-	router, _, handler := setupTest()
+func TestCreateRedemption_InsufficientBalance(t *testing.T) {
+	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/balance", handler.GetBalance)
+	router.POST("/redemptions", handler.CreateRedemption)
 	
-	// Create request without customer_id
-	req, _ := http.NewRequest("GET", "/balance?org_id=test_org", nil)
+	jsonData, _ := json.Marshal(models.CreateRedemptionRequest{
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		RewardID:   "free_coffee",
+		Points:     300,
+	})
+	
+	mockRepo.On("CreateRedemption", mock.Anything, mock.Anything).Return(nil, repository.ErrInsufficientBalance)
+	
+	// Create request
+	req, _ := http.NewRequest("POST", "/redemptions", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
 	
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response["error"], "org_id and customer_id are required")
+	assert.Equal(t, "insufficient balance", response["error"])
+	
+	mockRepo.AssertExpectations(t)
 }
 
-func TestGetBalance_RepositoryError(t *testing.T) {
+func TestGetAccount_Success(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/balance", handler.GetBalance)
+	router.GET("/accounts/:id", handler.GetAccount)
 	
-	// Mock repository error
-	mockRepo.On("GetBalance", mock.Anything, "test_org", "test_customer").Return(nil, assert.AnError)
+	// Mock repository response
+	expectedAccount := &models.Account{
+		ID:          "acc_123",
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		AccountType: models.AccountTypeAsset,
+		Code:        1001,
+	}
+	
+	mockRepo.On("GetAccount", mock.Anything, "acc_123").Return(expectedAccount, nil)
 	
 	// Create request
-	req, _ := http.NewRequest("GET", "/balance?org_id=test_org&customer_id=test_customer", nil)
+	req, _ := http.NewRequest("GET", "/accounts/acc_123", nil)
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
 	
-	var response map[string]interface{}
+	var response models.Account
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response["error"], "assert.AnError")
+	assert.Equal(t, "acc_123", response.ID)
+	assert.Equal(t, "test_org", response.OrgID)
 	
 	mockRepo.AssertExpectations(t)
 }
 
-// Test Health
+func TestGetAccount_MissingID(t *testing.T) {
+	router, _, handler := setupTest()
+	
+	// Setup route
+	router.GET("/accounts/:id", handler.GetAccount)
+	
+	// Create request without ID
+	req, _ := http.NewRequest("GET", "/accounts/", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetAccount_NotFound(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/accounts/:id", handler.GetAccount)
+	
+	// Mock repository error
+	mockRepo.On("GetAccount", mock.Anything, "nonexistent").Return(nil, assert.AnError)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/accounts/nonexistent", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "assert.AnError")
+	
+	mockRepo.AssertExpectations(t)
+}
+
+// Test GetAccountBalance
+func TestGetAccountBalance_MixedDebitsAndCredits(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/accounts/:id/balance", handler.GetAccountBalance)
+
+	// The org's liability account is debited for accruals and credited back
+	// for redemptions
+	account := &models.Account{
+		ID:             "liability_test_org",
+		OrgID:          "test_org",
+		AccountType:    models.AccountTypeLiability,
+		DebitsPosted:   500,
+		DebitsPending:  20,
+		CreditsPosted:  120,
+		CreditsPending: 30,
+	}
+	mockRepo.On("GetAccount", mock.Anything, "liability_test_org").Return(account, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/accounts/liability_test_org/balance", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.AccountBalance
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "liability_test_org", response.AccountID)
+	assert.Equal(t, models.AccountTypeLiability, response.AccountType)
+	assert.Equal(t, uint64(500), response.DebitsPosted)
+	assert.Equal(t, uint64(20), response.DebitsPending)
+	assert.Equal(t, uint64(120), response.CreditsPosted)
+	assert.Equal(t, uint64(30), response.CreditsPending)
+	assert.Equal(t, int64(-380), response.NetPosted)
+	assert.Equal(t, int64(10), response.NetPending)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAccountBalance_NotFound(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/accounts/:id/balance", handler.GetAccountBalance)
+
+	mockRepo.On("GetAccount", mock.Anything, "nonexistent").Return(nil, assert.AnError)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/accounts/nonexistent/balance", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test GetBalance
+func TestGetBalance_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/balance", handler.GetBalance)
+	
+	// Mock repository response
+	expectedBalances := map[string]uint64{
+		"points": 500,
+		"stamps": 10,
+	}
+	
+	mockRepo.On("GetBalance", mock.Anything, "test_org", "test_customer").Return(expectedBalances, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/balance?org_id=test_org&customer_id=test_customer", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", response["org_id"])
+	assert.Equal(t, "test_customer", response["customer_id"])
+	assert.Equal(t, float64(500), response["points_balance"])
+	assert.Equal(t, float64(10), response["stamps_balance"])
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetBalance_MissingOrgID(t *testing.T) {
+	router, _, handler := setupTest()
+	
+	// Setup route
+	router.GET("/balance", handler.GetBalance)
+	
+	// Create request without org_id
+	req, _ := http.NewRequest("GET", "/balance?customer_id=test_customer", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "org_id and customer_id are required")
+}
+
+func TestGetBalance_MissingCustomerID(t *testing.T) {
+	router, _, handler := setupTest()
+	
+	// Setup route
+	router.GET("/balance", handler.GetBalance)
+	
+	// Create request without customer_id
+	req, _ := http.NewRequest("GET", "/balance?org_id=test_org", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "org_id and customer_id are required")
+}
+
+func TestGetBalance_RepositoryError(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/balance", handler.GetBalance)
+	
+	// Mock repository error
+	mockRepo.On("GetBalance", mock.Anything, "test_org", "test_customer").Return(nil, assert.AnError)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/balance?org_id=test_org&customer_id=test_customer", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "assert.AnError")
+	
+	mockRepo.AssertExpectations(t)
+}
+
+// Test GetBalanceSummary
+func TestGetBalanceSummary_PartialCard(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/balance/summary", handler.GetBalanceSummary)
+	
+	// Mock repository response
+	balances := map[string]uint64{
+		"points": 500,
+		"stamps": 23,
+	}
+	
+	mockRepo.On("GetBalance", mock.Anything, "test_org", "test_customer").Return(balances, nil)
+	mockRepo.On("GetPointsExpiry", mock.Anything, "test_org", "test_customer").Return(uint64(120), uint64(1767268800), nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/balance/summary?org_id=test_org&customer_id=test_customer&max_stamps_per_card=10", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response models.BalanceSummary
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(500), response.PointsBalance)
+	assert.Equal(t, uint64(23), response.StampsBalance)
+	assert.Equal(t, uint64(2), response.CompletedCards)
+	assert.Equal(t, uint64(3), response.StampsOnCurrentCard)
+	assert.Equal(t, uint64(7), response.StampsToNextCard)
+	assert.Equal(t, uint64(120), response.ExpiringPoints)
+	assert.Equal(t, uint64(1767268800), response.PointsExpireAt)
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetBalanceSummary_StampsToCompletion(t *testing.T) {
+	tests := []struct {
+		name             string
+		stamps           uint64
+		maxStampsPerCard uint64
+		expectedToNext   uint64
+		expectedOnCard   uint64
+	}{
+		{"empty card", 0, 10, 10, 0},
+		{"partially filled card", 4, 10, 6, 4},
+		{"one stamp short", 9, 10, 1, 9},
+		{"exactly completed card", 10, 10, 10, 0},
+		{"no card size configured", 4, 0, 0, 0},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := newBalanceSummary("test_org", "test_customer", map[string]uint64{"stamps": tt.stamps}, tt.maxStampsPerCard)
+			assert.Equal(t, tt.expectedToNext, summary.StampsToNextCard)
+			assert.Equal(t, tt.expectedOnCard, summary.StampsOnCurrentCard)
+		})
+	}
+}
+
+func TestGetBalanceSummary_InvalidMaxStamps(t *testing.T) {
+	router, _, handler := setupTest()
+	
+	// Setup route
+	router.GET("/balance/summary", handler.GetBalanceSummary)
+	
+	// Create request with an invalid card size
+	req, _ := http.NewRequest("GET", "/balance/summary?org_id=test_org&customer_id=test_customer&max_stamps_per_card=-1", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test Health
+// Test GetLiability
+func TestGetLiability_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/liability", handler.GetLiability)
+	
+	mockRepo.On("GetOrgLiability", mock.Anything, "test_org").Return(map[string]uint64{"points": 1500, "stamps": 42}, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/liability?org_id=test_org", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", response["org_id"])
+	assert.Equal(t, float64(1500), response["points_outstanding"])
+	assert.Equal(t, float64(42), response["stamps_outstanding"])
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetLiability_MissingOrgID(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/liability", handler.GetLiability)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/liability", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "GetOrgLiability", mock.Anything, mock.Anything)
+}
+
+// Test GetTrialBalance
+func TestGetTrialBalance_Balanced(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/trial-balance", handler.GetTrialBalance)
+
+	accounts := []*models.Account{
+		{ID: "liability_points_test_org", OrgID: "test_org", AccountType: models.AccountTypeLiability, Code: models.TransferCodePoints, DebitsPosted: 350, CreditsPosted: 100},
+		{ID: "liability_stamps_test_org", OrgID: "test_org", AccountType: models.AccountTypeLiability, Code: models.TransferCodeStamps, DebitsPosted: 4},
+		{ID: "points_test_org_cust_1", OrgID: "test_org", CustomerID: "cust_1", AccountType: models.AccountTypeAsset, Code: models.TransferCodePoints, DebitsPosted: 100, CreditsPosted: 300},
+		{ID: "points_test_org_cust_2", OrgID: "test_org", CustomerID: "cust_2", AccountType: models.AccountTypeAsset, Code: models.TransferCodePoints, CreditsPosted: 50},
+		{ID: "stamps_test_org_cust_2", OrgID: "test_org", CustomerID: "cust_2", AccountType: models.AccountTypeAsset, Code: models.TransferCodeStamps, CreditsPosted: 4},
+	}
+	mockRepo.On("ListAccounts", mock.Anything, "test_org").Return(accounts, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/trial-balance?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.TrialBalance
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", response.OrgID)
+	assert.Len(t, response.Accounts, 5)
+	assert.Equal(t, int64(-250), response.Accounts[0].NetPosted)
+	assert.Equal(t, []models.CodeBalance{
+		{Code: models.TransferCodePoints, TotalDebits: 450, TotalCredits: 450, Balanced: true},
+		{Code: models.TransferCodeStamps, TotalDebits: 4, TotalCredits: 4, Balanced: true},
+	}, response.Codes)
+	assert.True(t, response.Balanced)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTrialBalance_DetectsImbalance(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/trial-balance", handler.GetTrialBalance)
+
+	// A customer credit with no matching debit
+	accounts := []*models.Account{
+		{ID: "liability_points_test_org", OrgID: "test_org", AccountType: models.AccountTypeLiability, Code: models.TransferCodePoints, DebitsPosted: 300},
+		{ID: "points_test_org_cust_1", OrgID: "test_org", CustomerID: "cust_1", AccountType: models.AccountTypeAsset, Code: models.TransferCodePoints, CreditsPosted: 325},
+	}
+	mockRepo.On("ListAccounts", mock.Anything, "test_org").Return(accounts, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/trial-balance?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.TrialBalance
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	require.Len(t, response.Codes, 1)
+	assert.Equal(t, uint64(300), response.Codes[0].TotalDebits)
+	assert.Equal(t, uint64(325), response.Codes[0].TotalCredits)
+	assert.Equal(t, int64(25), response.Codes[0].Difference)
+	assert.False(t, response.Codes[0].Balanced)
+	assert.False(t, response.Balanced)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetTrialBalance_ImbalancesDoNotCancelAcrossCodes(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/trial-balance", handler.GetTrialBalance)
+
+	// Points are 5 over and stamps 5 under, which would net to zero together
+	accounts := []*models.Account{
+		{ID: "liability_points_test_org", OrgID: "test_org", AccountType: models.AccountTypeLiability, Code: models.TransferCodePoints, DebitsPosted: 100},
+		{ID: "liability_stamps_test_org", OrgID: "test_org", AccountType: models.AccountTypeLiability, Code: models.TransferCodeStamps, DebitsPosted: 10},
+		{ID: "points_test_org_cust_1", OrgID: "test_org", CustomerID: "cust_1", AccountType: models.AccountTypeAsset, Code: models.TransferCodePoints, CreditsPosted: 105},
+		{ID: "stamps_test_org_cust_1", OrgID: "test_org", CustomerID: "cust_1", AccountType: models.AccountTypeAsset, Code: models.TransferCodeStamps, CreditsPosted: 5},
+	}
+	mockRepo.On("ListAccounts", mock.Anything, "test_org").Return(accounts, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/trial-balance?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.TrialBalance
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	require.Len(t, response.Codes, 2)
+	assert.Equal(t, int64(5), response.Codes[0].Difference)
+	assert.Equal(t, int64(-5), response.Codes[1].Difference)
+	assert.False(t, response.Balanced)
+}
+
+func TestGetTrialBalance_IncludePending(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/trial-balance", handler.GetTrialBalance)
+
+	accounts := []*models.Account{
+		{ID: "liability_points_test_org", OrgID: "test_org", Code: models.TransferCodePoints, DebitsPosted: 100, DebitsPending: 20},
+		{ID: "points_test_org_cust_1", OrgID: "test_org", CustomerID: "cust_1", Code: models.TransferCodePoints, CreditsPosted: 100},
+	}
+	mockRepo.On("ListAccounts", mock.Anything, "test_org").Return(accounts, nil)
+
+	for query, balanced := range map[string]bool{"": true, "&include_pending=true": false} {
+		// Create request
+		req, _ := http.NewRequest("GET", "/trial-balance?org_id=test_org"+query, nil)
+
+		// Record response
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response models.TrialBalance
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, balanced, response.Balanced, query)
+		assert.Equal(t, !balanced, response.IncludePending, query)
+	}
+}
+
+func TestGetTrialBalance_InvalidParams(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/trial-balance", handler.GetTrialBalance)
+
+	for _, query := range []string{"", "?org_id=test_org&include_pending=maybe"} {
+		// Create request
+		req, _ := http.NewRequest("GET", "/trial-balance"+query, nil)
+
+		// Record response
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	mockRepo.AssertNotCalled(t, "ListAccounts", mock.Anything, mock.Anything)
+}
+
+// Test ListTransfers
+func TestListTransfers_Empty(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/transfers", handler.ListTransfers)
+	
+	mockRepo.On("ListTransfers", mock.Anything, "test_org", "test_customer", 50, 0, []string(nil)).Return(nil, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/transfers?org_id=test_org&customer_id=test_customer", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{}, response["transfers"])
+	assert.Equal(t, float64(0), response["count"])
+	assert.Equal(t, float64(50), response["limit"])
+	assert.Equal(t, float64(0), response["offset"])
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListTransfers_Populated(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/transfers", handler.ListTransfers)
+	
+	transfers := []*models.Transfer{
+		{ID: "txf_2", DebitAccountID: "points_test_org_test_customer", CreditAccountID: "liability_test_org", Amount: 40, Code: models.TransferCodePoints, Timestamp: 1760000200},
+		{ID: "txf_1", DebitAccountID: "liability_test_org", CreditAccountID: "points_test_org_test_customer", Amount: 100, Code: models.TransferCodePoints, Timestamp: 1760000100},
+	}
+	mockRepo.On("ListTransfers", mock.Anything, "test_org", "test_customer", 2, 4, []string(nil)).Return(transfers, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/transfers?org_id=test_org&customer_id=test_customer&limit=2&offset=4", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response struct {
+		Transfers []models.Transfer `json:"transfers"`
+		Count     int               `json:"count"`
+		Limit     int               `json:"limit"`
+		Offset    int               `json:"offset"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, 2, response.Limit)
+	assert.Equal(t, 4, response.Offset)
+	if assert.Len(t, response.Transfers, 2) {
+		assert.Equal(t, "txf_2", response.Transfers[0].ID)
+		assert.Equal(t, uint64(40), response.Transfers[0].Amount)
+		assert.Equal(t, "txf_1", response.Transfers[1].ID)
+	}
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListTransfers_InvalidParams(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/transfers", handler.ListTransfers)
+	
+	for _, query := range []string{
+		"customer_id=test_customer",
+		"org_id=test_org",
+		"org_id=test_org&customer_id=test_customer&limit=abc",
+		"org_id=test_org&customer_id=test_customer&limit=0",
+		"org_id=test_org&customer_id=test_customer&offset=-1",
+	} {
+		req, _ := http.NewRequest("GET", "/transfers?"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	mockRepo.AssertNotCalled(t, "ListTransfers", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListTransfers_ByReference(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/transfers", handler.ListTransfers)
+	
+	references := []string{"pos_transaction_txn_1", "pos_refund_txn_1"}
+	mockRepo.On("ListTransfers", mock.Anything, "test_org", "test_customer", 50, 0, references).Return([]*models.Transfer{}, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/transfers?org_id=test_org&customer_id=test_customer&reference=pos_transaction_txn_1&reference=pos_refund_txn_1", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestHealth_Success(t *testing.T) {
 	router, _, handler := setupTest()
 	
@@ -28,4 +28,59 @@ type CreateAccountRequest struct {
 	CustomerID  string      `json:"customer_id"`
 	AccountType AccountType `json:"account_type" binding:"required"`
 	Code        uint16      `json:"code"`
-}
\ No newline at end of file
+}
+// BalanceSummary combines a customer's points and stamps balances with their
+// progress towards completing the current stamp card, and the points that
+// expire next with when they do as a Unix timestamp
+type BalanceSummary struct {
+	OrgID               string `json:"org_id"`
+	CustomerID          string `json:"customer_id"`
+	PointsBalance       uint64 `json:"points_balance"`
+	StampsBalance       uint64 `json:"stamps_balance"`
+	MaxStampsPerCard    uint64 `json:"max_stamps_per_card"`
+	CompletedCards      uint64 `json:"completed_cards"`
+	StampsOnCurrentCard uint64 `json:"stamps_on_current_card"`
+	StampsToNextCard    uint64 `json:"stamps_to_next_card"`
+	ExpiringPoints      uint64 `json:"expiring_points,omitempty"`
+	PointsExpireAt      uint64 `json:"points_expire_at,omitempty"`
+}
+
+// AccountBalance reports one account's posted and pending totals. Net is
+// credits less debits, so customer accounts are positive while the org's
+// liability account is negative, and an org's nets sum to zero.
+type AccountBalance struct {
+	AccountID      string      `json:"account_id"`
+	OrgID          string      `json:"org_id"`
+	CustomerID     string      `json:"customer_id"`
+	AccountType    AccountType `json:"account_type"`
+	Code           uint16      `json:"code"`
+	DebitsPosted   uint64      `json:"debits_posted"`
+	DebitsPending  uint64      `json:"debits_pending"`
+	CreditsPosted  uint64      `json:"credits_posted"`
+	CreditsPending uint64      `json:"credits_pending"`
+	NetPosted      int64       `json:"net_posted"`
+	NetPending     int64       `json:"net_pending"`
+}
+
+// TrialBalance totals the debits and credits of every account in an org per
+// transfer code. Each transfer debits one account and credits another by the
+// same amount with one code, so every code balances unless the ledger is out
+// of balance. Balanced is set only when all of them do.
+type TrialBalance struct {
+	OrgID          string           `json:"org_id"`
+	Accounts       []AccountBalance `json:"accounts"`
+	IncludePending bool             `json:"include_pending"`
+	Codes          []CodeBalance    `json:"codes"`
+	Balanced       bool             `json:"balanced"`
+}
+
+// CodeBalance totals the accounts of one transfer code. Points and stamps are
+// checked apart, so an imbalance in one cannot be hidden by an opposite one in
+// the other. Difference is credits less debits.
+type CodeBalance struct {
+	Code         uint16 `json:"code"`
+	TotalDebits  uint64 `json:"total_debits"`
+	TotalCredits uint64 `json:"total_credits"`
+	Difference   int64  `json:"difference"`
+	Balanced     bool   `json:"balanced"`
+}
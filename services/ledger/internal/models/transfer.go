@@ -1,5 +1,25 @@
 package models
 
+import "github.com/loyalty/ledger/pkg/codes"
+
+// Transfer codes tag what a transfer moves
+const (
+	TransferCodePoints = codes.Points
+	TransferCodeStamps = codes.Stamps
+)
+
+// ReferencePointsExpiry marks the transfers that take back expired points
+const ReferencePointsExpiry = "points_expiry"
+
+// Pending statuses track a two-phase points accrual. A pending transfer holds
+// the points until it is posted, which credits them, or voided, which
+// releases the hold.
+const (
+	PendingStatusPending = "pending"
+	PendingStatusPosted  = "posted"
+	PendingStatusVoided  = "voided"
+)
+
 type Transfer struct {
 	ID              string `json:"id"`
 	DebitAccountID  string `json:"debit_account_id"`
@@ -8,18 +28,94 @@ type Transfer struct {
 	Code            uint16 `json:"code"`
 	Reference       string `json:"reference"`
 	Timestamp       uint64 `json:"timestamp"`
+	// UserData is stored with the transfer for the caller, like TigerBeetle's
+	// user_data_64; the ledger never reads it
+	UserData uint64 `json:"user_data,omitempty"`
+	// ExpiresAt is when the points an accrual credits expire, as a Unix
+	// timestamp. Zero means they never do.
+	ExpiresAt uint64 `json:"expires_at,omitempty"`
+	// ExpiryDays is kept on a pending accrual so the points are given their
+	// expiry when it posts
+	ExpiryDays int `json:"-"`
+	// Reverses is the ID of the transfer this one reverses, and ReversedBy
+	// the ID of the transfer that reversed this one
+	Reverses   string `json:"reverses,omitempty"`
+	ReversedBy string `json:"reversed_by,omitempty"`
+	// PendingStatus is set on two-phase accruals. Voided counts the held
+	// points released so far, and SettlesAt is when the settlement sweep
+	// posts the rest as a Unix timestamp; zero waits for an explicit
+	// settlement.
+	PendingStatus string `json:"pending_status,omitempty"`
+	Voided        uint64 `json:"voided,omitempty"`
+	SettlesAt     uint64 `json:"settles_at,omitempty"`
+	// PendingID is the pending transfer a posting transfer settles, and
+	// PostedBy the posting transfer of a settled pending one
+	PendingID string `json:"pending_id,omitempty"`
+	PostedBy  string `json:"posted_by,omitempty"`
 }
 
 type CreateTransferRequest struct {
 	OrgID           string `json:"org_id" binding:"required"`
 	CustomerID      string `json:"customer_id"`
 	TransactionType string `json:"transaction_type" binding:"required"`
-	Amount          uint64 `json:"amount" binding:"required"`
+	Amount          uint64 `json:"amount"`
 	Code            uint16 `json:"code"`
 	Reference       string `json:"reference"`
+	UserData        uint64 `json:"user_data"`
+	// ExpiryDays is how many days the points a points accrual credits last
+	// from when they are credited, which callers take from the org's
+	// points_expiry_days in membership. Zero keeps them forever.
+	ExpiryDays int `json:"expiry_days"`
+	// Pending holds a points accrual until it is settled instead of crediting
+	// it straight away. SettleAfterSeconds posts it automatically that long
+	// after it is created; zero waits for an explicit settlement.
+	Pending            bool   `json:"pending"`
+	SettleAfterSeconds uint64 `json:"settle_after_seconds"`
+}
+
+// SettleTransferRequest posts or voids the customer's pending points accrual
+// with Reference. Amount voids only that many of the held points, keeping the
+// rest pending; zero voids them all. Posting always credits what is left.
+type SettleTransferRequest struct {
+	OrgID      string `json:"org_id" binding:"required"`
+	CustomerID string `json:"customer_id" binding:"required"`
+	Reference  string `json:"reference" binding:"required"`
+	Void       bool   `json:"void"`
+	Amount     uint64 `json:"amount"`
 }
 
+// ReverseTransferRequest optionally references a reversal; it defaults to
+// reversal_<transfer ID>
+type ReverseTransferRequest struct {
+	Reference string `json:"reference"`
+}
+
+// TransferResponse reports a created or settled transfer. When a pending
+// transfer is settled, Amount is how many points the settlement posted or
+// voided and HeldAt is when they were first held, as a Unix timestamp.
 type TransferResponse struct {
 	TransferID string `json:"transfer_id"`
 	Status     string `json:"status"`
-}
\ No newline at end of file
+	Amount     uint64 `json:"amount,omitempty"`
+	HeldAt     uint64 `json:"held_at,omitempty"`
+}
+// CreateRedemptionRequest spends Points and/or Stamps on a reward. BonusPoints
+// are credited back in the same operation, so the customer never sees the cost
+// without the bonus.
+type CreateRedemptionRequest struct {
+	OrgID       string `json:"org_id" binding:"required"`
+	CustomerID  string `json:"customer_id" binding:"required"`
+	RewardID    string `json:"reward_id" binding:"required"`
+	Points      uint64 `json:"points"`
+	Stamps      uint64 `json:"stamps"`
+	BonusPoints uint64 `json:"bonus_points"`
+	Reference   string `json:"reference"`
+	// BonusExpiryDays is how many days bonus points last, like an accrual's
+	// ExpiryDays
+	BonusExpiryDays int `json:"bonus_expiry_days"`
+}
+
+type RedemptionResponse struct {
+	TransferIDs []string `json:"transfer_ids"`
+	Status      string   `json:"status"`
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/loyalty/ledger/internal/models"
+)
+
+// ExpirePoints takes back every customer's points whose accruals have passed
+// their expiry, posting one points_expiry transfer per customer. Points are
+// spent soonest-expiring first, so earlier redemptions and expiries use up
+// expiring credits before later ones and running it again expires nothing
+// new. It returns the total points expired.
+func (r *MockTigerBeetleRepo) ExpirePoints(ctx context.Context) (uint64, error) {
+	now := uint64(r.now().Unix())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credits, debits := r.pointsMovements()
+
+	var total uint64
+	for accountID, accountCredits := range credits {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		expired := expiredRemainder(accountCredits, debits[accountID], now)
+		if expired == 0 {
+			continue
+		}
+
+		account := r.accounts[accountID]
+		liabilityAccountID := r.generateOrgLiabilityAccount(account.OrgID, models.TransferCodePoints)
+		transfer := r.newTransfer(accountID, liabilityAccountID, expired, models.TransferCodePoints, models.ReferencePointsExpiry)
+		r.ensureAccount(liabilityAccountID, account.OrgID, "", models.AccountTypeLiability, models.TransferCodePoints)
+		r.transfers[transfer.ID] = transfer
+		r.updateAccountBalance(accountID, expired, true)
+		r.updateAccountBalance(liabilityAccountID, expired, false)
+		total += expired
+
+		log.Printf("Mock: Expired %d points for customer %s in org %s", expired, account.CustomerID, account.OrgID)
+	}
+
+	return total, nil
+}
+
+// GetPointsExpiry returns how many of the customer's points expire next and
+// when, as a Unix timestamp, taking what they have spent from the
+// soonest-expiring credits first as ExpirePoints does. Both are zero if none
+// of their points are due to expire.
+func (r *MockTigerBeetleRepo) GetPointsExpiry(ctx context.Context, orgID, customerID string) (uint64, uint64, error) {
+	accountID := r.generateCustomerPointsAccount(orgID, customerID)
+	now := uint64(r.now().Unix())
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	credits, debits := r.pointsMovements()
+	points, expiresAt := nextExpiry(credits[accountID], debits[accountID], now)
+	return points, expiresAt, nil
+}
+
+// pointsMovements groups the credits and totals the debits of every customer
+// points account. It must be called with r.mu held.
+func (r *MockTigerBeetleRepo) pointsMovements() (map[string][]*models.Transfer, map[string]uint64) {
+	credits := make(map[string][]*models.Transfer)
+	debits := make(map[string]uint64)
+	for _, transfer := range r.transfers {
+		// A reversed transfer and its reversal cancel out, and a pending
+		// accrual only counts once its posting transfer credits it
+		if transfer.ReversedBy != "" || transfer.Reverses != "" || transfer.PendingStatus != "" {
+			continue
+		}
+		if r.isCustomerPointsAccount(transfer.CreditAccountID) {
+			credits[transfer.CreditAccountID] = append(credits[transfer.CreditAccountID], transfer)
+		}
+		if r.isCustomerPointsAccount(transfer.DebitAccountID) {
+			debits[transfer.DebitAccountID] += transfer.Amount
+		}
+	}
+	return credits, debits
+}
+
+// sortByExpiry orders credits in the order they are spent: soonest-expiring
+// first, with credits that never expire last
+func sortByExpiry(credits []*models.Transfer) {
+	sort.Slice(credits, func(i, j int) bool {
+		a, b := credits[i], credits[j]
+		if (a.ExpiresAt == 0) != (b.ExpiresAt == 0) {
+			return b.ExpiresAt == 0
+		}
+		if a.ExpiresAt != b.ExpiresAt {
+			return a.ExpiresAt < b.ExpiresAt
+		}
+		return a.Timestamp < b.Timestamp
+	})
+}
+
+// nextExpiry returns how much of the credits left once spent has been taken
+// from them expires next after now, and when
+func nextExpiry(credits []*models.Transfer, spent, now uint64) (uint64, uint64) {
+	sortByExpiry(credits)
+
+	var points, expiresAt uint64
+	for _, credit := range credits {
+		if credit.ExpiresAt == 0 || (expiresAt != 0 && credit.ExpiresAt != expiresAt) {
+			break
+		}
+		if spent >= credit.Amount {
+			spent -= credit.Amount
+			continue
+		}
+		remaining := credit.Amount - spent
+		spent = 0
+		// Expired credits the next ExpirePoints run takes back are not
+		// announced again
+		if credit.ExpiresAt <= now {
+			continue
+		}
+		points += remaining
+		expiresAt = credit.ExpiresAt
+	}
+	return points, expiresAt
+}
+
+// expiredRemainder returns how much of the expired credits is left once spent
+// has been taken from them. Credits are spent in expiry order, with credits
+// that never expire spent last.
+func expiredRemainder(credits []*models.Transfer, spent, now uint64) uint64 {
+	sortByExpiry(credits)
+
+	var expired uint64
+	for _, credit := range credits {
+		if credit.ExpiresAt == 0 || credit.ExpiresAt > now {
+			break
+		}
+		if spent >= credit.Amount {
+			spent -= credit.Amount
+			continue
+		}
+		expired += credit.Amount - spent
+		spent = 0
+	}
+	return expired
+}
+
+// pointsExpiry returns when points credited now that last days expire, or
+// zero if they never do
+func (r *MockTigerBeetleRepo) pointsExpiry(days int) uint64 {
+	if days <= 0 {
+		return 0
+	}
+	return uint64(r.now().AddDate(0, 0, days).Unix())
+}
+
+// isCustomerPointsAccount must be called with r.mu held
+func (r *MockTigerBeetleRepo) isCustomerPointsAccount(accountID string) bool {
+	account, exists := r.accounts[accountID]
+	return exists && account.CustomerID != "" && account.Code == models.TransferCodePoints
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loyalty/ledger/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test setup helper - a repo whose clock the test moves
+func setupExpiryRepo() (*MockTigerBeetleRepo, func(time.Duration)) {
+	repo := NewMockTigerBeetleRepo()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+	advance := func(d time.Duration) { now = now.Add(d) }
+	return repo, advance
+}
+
+// testExpiryDays is how long the points accrue credits last
+const testExpiryDays = 30
+
+func accrue(t *testing.T, repo *MockTigerBeetleRepo, orgID, customerID, transactionType string, amount uint64) {
+	_, err := repo.CreateTransfer(context.Background(), &models.CreateTransferRequest{
+		OrgID:           orgID,
+		CustomerID:      customerID,
+		TransactionType: transactionType,
+		Amount:          amount,
+		ExpiryDays:      testExpiryDays,
+	})
+	assert.NoError(t, err)
+}
+
+// Test ExpirePoints
+func TestExpirePoints_DeductsExpiredAndKeepsFresh(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 100)
+	advance(20 * 24 * time.Hour)
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 40)
+	accrue(t, repo, "test_org", "test_customer", "stamps_accrual", 3)
+
+	// Nothing has expired yet
+	expired, err := repo.ExpirePoints(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), expired)
+
+	// Past the first accrual's expiry but not the second's
+	advance(11 * 24 * time.Hour)
+	expired, err = repo.ExpirePoints(ctx)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), expired)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(40), balances["points"])
+	assert.Equal(t, uint64(3), balances["stamps"], "stamps do not expire")
+
+	transfers, err := repo.ListTransfers(ctx, "test_org", "test_customer", 10, 0)
+	assert.NoError(t, err)
+	var expiries []*models.Transfer
+	for _, transfer := range transfers {
+		if transfer.Reference == models.ReferencePointsExpiry {
+			expiries = append(expiries, transfer)
+		}
+	}
+	if assert.Len(t, expiries, 1) {
+		assert.Equal(t, "points_test_org_test_customer", expiries[0].DebitAccountID)
+		assert.Equal(t, "liability_points_test_org", expiries[0].CreditAccountID)
+	}
+
+	liability, err := repo.GetOrgLiability(ctx, "test_org")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(40), liability["points"])
+}
+
+func TestExpirePoints_RunsAgainWithoutDoubleCounting(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 100)
+	advance(31 * 24 * time.Hour)
+
+	expired, err := repo.ExpirePoints(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), expired)
+
+	expired, err = repo.ExpirePoints(ctx)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), expired)
+}
+
+func TestExpirePoints_RedemptionsSpendSoonestExpiringFirst(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 100)
+	advance(10 * 24 * time.Hour)
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 50)
+	accrue(t, repo, "test_org", "test_customer", "points_redemption", 70)
+
+	advance(21 * 24 * time.Hour)
+	expired, err := repo.ExpirePoints(ctx)
+
+	// Assertions - the redemption came out of the first accrual
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(30), expired)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50), balances["points"])
+}
+
+func TestExpirePoints_EachAccrualsOwnExpiry(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+
+	// Each accrual lasts the days its org's settings gave when it was made
+	for _, days := range []int{30, 365, 0} {
+		_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+			OrgID: "test_org", CustomerID: "test_customer", TransactionType: "points_accrual", Amount: 100, ExpiryDays: days,
+		})
+		assert.NoError(t, err)
+	}
+
+	advance(31 * 24 * time.Hour)
+	expired, err := repo.ExpirePoints(ctx)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), expired)
+
+	advance(400 * 24 * time.Hour)
+	expired, err = repo.ExpirePoints(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), expired)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), balances["points"], "points without expiry days never expire")
+}
+
+func TestExpirePoints_BonusPointsExpire(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 100)
+	_, err := repo.CreateRedemption(ctx, &models.CreateRedemptionRequest{
+		OrgID: "test_org", CustomerID: "test_customer", RewardID: "reward_1",
+		Points: 100, BonusPoints: 25, BonusExpiryDays: testExpiryDays,
+	})
+	assert.NoError(t, err)
+
+	advance(31 * 24 * time.Hour)
+	expired, err := repo.ExpirePoints(ctx)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(25), expired)
+}
+
+// Test GetPointsExpiry
+func TestGetPointsExpiry_NextUnspentCredit(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+	start := repo.now()
+
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 100)
+	advance(10 * 24 * time.Hour)
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 50)
+	accrue(t, repo, "test_org", "test_customer", "points_redemption", 70)
+
+	points, expiresAt, err := repo.GetPointsExpiry(ctx, "test_org", "test_customer")
+
+	// Assertions - the redemption came out of the first accrual
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(30), points)
+	assert.Equal(t, uint64(start.AddDate(0, 0, testExpiryDays).Unix()), expiresAt)
+
+	// Once it has passed, the next accrual is due
+	advance(21 * 24 * time.Hour)
+	points, expiresAt, err = repo.GetPointsExpiry(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50), points)
+	assert.Equal(t, uint64(start.AddDate(0, 0, 10+testExpiryDays).Unix()), expiresAt)
+}
+
+func TestGetPointsExpiry_NoneDue(t *testing.T) {
+	repo, _ := setupExpiryRepo()
+	ctx := context.Background()
+
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID: "test_org", CustomerID: "test_customer", TransactionType: "points_accrual", Amount: 100,
+	})
+	assert.NoError(t, err)
+
+	points, expiresAt, err := repo.GetPointsExpiry(ctx, "test_org", "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Zero(t, points)
+	assert.Zero(t, expiresAt)
+}
+
+func TestExpirePoints_IgnoresReversedAccruals(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+
+	response, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID: "test_org", CustomerID: "test_customer", TransactionType: "points_accrual", Amount: 100,
+	})
+	assert.NoError(t, err)
+	advance(20 * 24 * time.Hour)
+	accrue(t, repo, "test_org", "test_customer", "points_accrual", 40)
+	_, err = repo.ReverseTransfer(ctx, response.TransferID, "")
+	assert.NoError(t, err)
+
+	// Past both accruals' expiry
+	advance(31 * 24 * time.Hour)
+	expired, err := repo.ExpirePoints(ctx)
+
+	// Assertions - only the accrual that was not reversed expires
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(40), expired)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), balances["points"])
+}
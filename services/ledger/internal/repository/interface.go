@@ -2,14 +2,44 @@ package repository
 
 import (
 	"context"
+	"errors"
+
 	"github.com/loyalty/ledger/internal/models"
 )
 
+// ErrInsufficientBalance is returned when a redemption costs more than the
+// customer holds
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// ErrTransferNotFound is returned when no transfer has the requested ID
+var ErrTransferNotFound = errors.New("transfer not found")
+
+// ErrTransferAlreadyReversed is returned when a transfer has been reversed
+// before, or is itself a reversal
+var ErrTransferAlreadyReversed = errors.New("transfer already reversed")
+
+// ErrTransferNotPending is returned when settling a pending accrual that does
+// not exist or was already posted or voided
+var ErrTransferNotPending = errors.New("no pending transfer to settle")
+
+// ErrTransferPending is returned when reversing a two-phase accrual, which is
+// settled by posting or voiding it instead
+var ErrTransferPending = errors.New("pending transfers are posted or voided, not reversed")
+
 // TigerBeetleRepoInterface defines the interface for TigerBeetle repository operations
 type TigerBeetleRepoInterface interface {
 	CreateAccount(ctx context.Context, req *models.CreateAccountRequest) (*models.Account, error)
 	CreateTransfer(ctx context.Context, req *models.CreateTransferRequest) (*models.TransferResponse, error)
+	CreateRedemption(ctx context.Context, req *models.CreateRedemptionRequest) (*models.RedemptionResponse, error)
 	GetAccount(ctx context.Context, accountID string) (*models.Account, error)
+	ListAccounts(ctx context.Context, orgID string) ([]*models.Account, error)
 	GetBalance(ctx context.Context, orgID, customerID string) (map[string]uint64, error)
+	GetOrgLiability(ctx context.Context, orgID string) (map[string]uint64, error)
+	ListTransfers(ctx context.Context, orgID, customerID string, limit, offset int, references ...string) ([]*models.Transfer, error)
+	ReverseTransfer(ctx context.Context, transferID, reference string) (*models.TransferResponse, error)
+	SettlePendingTransfer(ctx context.Context, req *models.SettleTransferRequest) (*models.TransferResponse, error)
+	SettleDuePendingTransfers(ctx context.Context) (uint64, error)
+	ExpirePoints(ctx context.Context) (uint64, error)
+	GetPointsExpiry(ctx context.Context, orgID, customerID string) (uint64, uint64, error)
 	Close() error
 } 
\ No newline at end of file
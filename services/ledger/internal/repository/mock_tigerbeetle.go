@@ -5,20 +5,27 @@ import (
 	"crypto/rand"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/loyalty/ledger/internal/models"
 )
 
 type MockTigerBeetleRepo struct {
+	// mu guards accounts and transfers; handlers serve requests concurrently
+	mu        sync.RWMutex
 	accounts  map[string]*models.Account
 	transfers map[string]*models.Transfer
+	now       func() time.Time
 }
 
 func NewMockTigerBeetleRepo() *MockTigerBeetleRepo {
 	return &MockTigerBeetleRepo{
 		accounts:  make(map[string]*models.Account),
 		transfers: make(map[string]*models.Transfer),
+		now:       time.Now,
 	}
 }
 
@@ -33,10 +40,12 @@ func (r *MockTigerBeetleRepo) CreateAccount(ctx context.Context, req *models.Cre
 		Code:         req.Code,
 		DebitsPosted: 0,
 		CreditsPosted: 0,
-		Timestamp:    uint64(time.Now().Unix()),
+		Timestamp:    uint64(r.now().Unix()),
 	}
 
+	r.mu.Lock()
 	r.accounts[accountID] = account
+	r.mu.Unlock()
 	
 	log.Printf("Mock: Created account %s for customer %s in org %s", 
 		accountID, req.CustomerID, req.OrgID)
@@ -48,10 +57,18 @@ func (r *MockTigerBeetleRepo) CreateTransfer(ctx context.Context, req *models.Cr
 	transferID := r.generateStringID()
 	
 	// Mock double-entry logic
-	debitAccountID := r.generateOrgLiabilityAccount(req.OrgID)
 	creditAccountID := r.generateCustomerPointsAccount(req.OrgID, req.CustomerID)
+	customerCode := models.TransferCodePoints
+	if strings.HasPrefix(req.TransactionType, "stamps_") {
+		creditAccountID = r.generateCustomerStampsAccount(req.OrgID, req.CustomerID)
+		customerCode = models.TransferCodeStamps
+	}
+	debitAccountID := r.generateOrgLiabilityAccount(req.OrgID, customerCode)
+	liabilityAccountID := debitAccountID
+	customerAccountID := creditAccountID
 	
-	if req.TransactionType == "points_redemption" || req.TransactionType == "stamps_redemption" {
+	isRedemption := req.TransactionType == "points_redemption" || req.TransactionType == "stamps_redemption"
+	if isRedemption {
 		debitAccountID, creditAccountID = creditAccountID, debitAccountID
 	}
 	
@@ -62,14 +79,48 @@ func (r *MockTigerBeetleRepo) CreateTransfer(ctx context.Context, req *models.Cr
 		Amount:          req.Amount,
 		Code:            req.Code,
 		Reference:       req.Reference,
-		Timestamp:       uint64(time.Now().Unix()),
+		Timestamp:       uint64(r.now().Unix()),
+		UserData:        req.UserData,
+	}
+	if req.Pending {
+		if isRedemption || customerCode != models.TransferCodePoints {
+			return nil, fmt.Errorf("only points accruals can be pending, not %s", req.TransactionType)
+		}
+		// Held points expire from when they are posted
+		transfer.PendingStatus = models.PendingStatusPending
+		transfer.ExpiryDays = req.ExpiryDays
+		if req.SettleAfterSeconds > 0 {
+			transfer.SettlesAt = uint64(r.now().Unix()) + req.SettleAfterSeconds
+		}
+	} else if !isRedemption && customerCode == models.TransferCodePoints {
+		transfer.ExpiresAt = r.pointsExpiry(req.ExpiryDays)
+	}
+
+	r.mu.Lock()
+	// A redemption is checked before anything is recorded, so a rejected
+	// one leaves the accounts as they were
+	if isRedemption {
+		if balance := r.accountBalance(customerAccountID); balance < req.Amount {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientBalance, balance, req.Amount)
+		}
 	}
 
+	// Nothing creates customer accounts up front, so the first transfer for
+	// a customer provisions them, as create-if-missing against TigerBeetle
+	r.ensureAccount(liabilityAccountID, req.OrgID, "", models.AccountTypeLiability, customerCode)
+	r.ensureAccount(customerAccountID, req.OrgID, req.CustomerID, models.AccountTypeAsset, customerCode)
 	r.transfers[transferID] = transfer
 	
 	// Update account balances in mock
-	r.updateAccountBalance(debitAccountID, req.Amount, true)
-	r.updateAccountBalance(creditAccountID, req.Amount, false)
+	if req.Pending {
+		r.updatePendingBalance(debitAccountID, int64(req.Amount), true)
+		r.updatePendingBalance(creditAccountID, int64(req.Amount), false)
+	} else {
+		r.updateAccountBalance(debitAccountID, req.Amount, true)
+		r.updateAccountBalance(creditAccountID, req.Amount, false)
+	}
+	r.mu.Unlock()
 	
 	log.Printf("Mock: Created transfer %s: %s -> %s (%d %s)", 
 		transferID, debitAccountID, creditAccountID, req.Amount, req.TransactionType)
@@ -80,12 +131,93 @@ func (r *MockTigerBeetleRepo) CreateTransfer(ctx context.Context, req *models.Cr
 	}, nil
 }
 
+// CreateRedemption debits the redemption cost and credits any bonus points as
+// one linked batch, the way TigerBeetle applies linked transfers: either every
+// transfer posts or none do.
+func (r *MockTigerBeetleRepo) CreateRedemption(ctx context.Context, req *models.CreateRedemptionRequest) (*models.RedemptionResponse, error) {
+	pointsLiabilityID := r.generateOrgLiabilityAccount(req.OrgID, models.TransferCodePoints)
+	stampsLiabilityID := r.generateOrgLiabilityAccount(req.OrgID, models.TransferCodeStamps)
+	pointsAccountID := r.generateCustomerPointsAccount(req.OrgID, req.CustomerID)
+	stampsAccountID := r.generateCustomerStampsAccount(req.OrgID, req.CustomerID)
+
+	reference := req.Reference
+	if reference == "" {
+		reference = fmt.Sprintf("redemption_%s", req.RewardID)
+	}
+
+	var transfers []*models.Transfer
+	if req.Points > 0 {
+		transfers = append(transfers, r.newTransfer(pointsAccountID, pointsLiabilityID, req.Points, models.TransferCodePoints, reference))
+	}
+	if req.Stamps > 0 {
+		transfers = append(transfers, r.newTransfer(stampsAccountID, stampsLiabilityID, req.Stamps, models.TransferCodeStamps, reference))
+	}
+	if req.BonusPoints > 0 {
+		bonus := r.newTransfer(pointsLiabilityID, pointsAccountID, req.BonusPoints, models.TransferCodePoints, reference+"_bonus")
+		bonus.ExpiresAt = r.pointsExpiry(req.BonusExpiryDays)
+		transfers = append(transfers, bonus)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The cost is checked against the balance before the bonus is credited,
+	// so a bonus never pays for the redemption that earns it
+	if r.accountBalance(pointsAccountID) < req.Points || r.accountBalance(stampsAccountID) < req.Stamps {
+		return nil, ErrInsufficientBalance
+	}
+
+	r.ensureAccount(pointsLiabilityID, req.OrgID, "", models.AccountTypeLiability, models.TransferCodePoints)
+	r.ensureAccount(stampsLiabilityID, req.OrgID, "", models.AccountTypeLiability, models.TransferCodeStamps)
+	r.ensureAccount(pointsAccountID, req.OrgID, req.CustomerID, models.AccountTypeAsset, models.TransferCodePoints)
+	r.ensureAccount(stampsAccountID, req.OrgID, req.CustomerID, models.AccountTypeAsset, models.TransferCodeStamps)
+
+	response := &models.RedemptionResponse{Status: "success"}
+	for _, transfer := range transfers {
+		r.transfers[transfer.ID] = transfer
+		r.updateAccountBalance(transfer.DebitAccountID, transfer.Amount, true)
+		r.updateAccountBalance(transfer.CreditAccountID, transfer.Amount, false)
+		response.TransferIDs = append(response.TransferIDs, transfer.ID)
+	}
+
+	log.Printf("Mock: Redeemed reward %s for customer %s in org %s (%d points, %d stamps, %d bonus points)",
+		req.RewardID, req.CustomerID, req.OrgID, req.Points, req.Stamps, req.BonusPoints)
+
+	return response, nil
+}
+
 func (r *MockTigerBeetleRepo) GetAccount(ctx context.Context, accountID string) (*models.Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	account, exists := r.accounts[accountID]
 	if !exists {
 		return nil, fmt.Errorf("account not found")
 	}
-	return account, nil
+
+	// Return a copy so callers never read balances while a transfer updates them
+	snapshot := *account
+	return &snapshot, nil
+}
+
+// ListAccounts returns copies of every account in the org, customer and org
+// accounts alike, ordered by ID
+func (r *MockTigerBeetleRepo) ListAccounts(ctx context.Context, orgID string) ([]*models.Account, error) {
+	r.mu.RLock()
+	accounts := []*models.Account{}
+	for _, account := range r.accounts {
+		if account.OrgID == orgID {
+			snapshot := *account
+			accounts = append(accounts, &snapshot)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ID < accounts[j].ID
+	})
+
+	return accounts, nil
 }
 
 func (r *MockTigerBeetleRepo) GetBalance(ctx context.Context, orgID, customerID string) (map[string]uint64, error) {
@@ -97,6 +229,9 @@ func (r *MockTigerBeetleRepo) GetBalance(ctx context.Context, orgID, customerID
 		"stamps": 0,
 	}
 	
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
 	if account, exists := r.accounts[pointsAccountID]; exists {
 		balances["points"] = account.CreditsPosted - account.DebitsPosted
 	}
@@ -108,6 +243,126 @@ func (r *MockTigerBeetleRepo) GetBalance(ctx context.Context, orgID, customerID
 	return balances, nil
 }
 
+// GetOrgLiability totals the points and stamps the org's customers hold, which
+// is what the org owes them
+func (r *MockTigerBeetleRepo) GetOrgLiability(ctx context.Context, orgID string) (map[string]uint64, error) {
+	liability := map[string]uint64{
+		"points": 0,
+		"stamps": 0,
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, account := range r.accounts {
+		if account.OrgID != orgID || account.CustomerID == "" || account.CreditsPosted < account.DebitsPosted {
+			continue
+		}
+		switch account.Code {
+		case models.TransferCodePoints:
+			liability["points"] += account.CreditsPosted - account.DebitsPosted
+		case models.TransferCodeStamps:
+			liability["stamps"] += account.CreditsPosted - account.DebitsPosted
+		}
+	}
+
+	return liability, nil
+}
+
+// ListTransfers returns a page of the transfers in or out of the customer's
+// points and stamps accounts, newest first. Given references, only transfers
+// with one of them are listed.
+func (r *MockTigerBeetleRepo) ListTransfers(ctx context.Context, orgID, customerID string, limit, offset int, references ...string) ([]*models.Transfer, error) {
+	customerAccounts := map[string]bool{
+		r.generateCustomerPointsAccount(orgID, customerID): true,
+		r.generateCustomerStampsAccount(orgID, customerID): true,
+	}
+	wanted := make(map[string]bool, len(references))
+	for _, reference := range references {
+		wanted[reference] = true
+	}
+
+	r.mu.RLock()
+	var transfers []*models.Transfer
+	for _, transfer := range r.transfers {
+		if len(wanted) > 0 && !wanted[transfer.Reference] {
+			continue
+		}
+		if customerAccounts[transfer.DebitAccountID] || customerAccounts[transfer.CreditAccountID] {
+			// Copy so the page does not share memory with the repository
+			snapshot := *transfer
+			transfers = append(transfers, &snapshot)
+		}
+	}
+	r.mu.RUnlock()
+
+	// Timestamps are in seconds, so ties are broken by ID to keep pages stable
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].Timestamp != transfers[j].Timestamp {
+			return transfers[i].Timestamp > transfers[j].Timestamp
+		}
+		return transfers[i].ID < transfers[j].ID
+	})
+
+	if offset < 0 || offset >= len(transfers) {
+		return []*models.Transfer{}, nil
+	}
+	transfers = transfers[offset:]
+	if limit > 0 && limit < len(transfers) {
+		transfers = transfers[:limit]
+	}
+
+	return transfers, nil
+}
+
+// ReverseTransfer posts a transfer of the same amount and code as transferID
+// with its debit and credit accounts swapped. Each transfer can be reversed
+// once, and a reversal that would take a customer's balance below zero is
+// rejected.
+func (r *MockTigerBeetleRepo) ReverseTransfer(ctx context.Context, transferID, reference string) (*models.TransferResponse, error) {
+	if reference == "" {
+		reference = fmt.Sprintf("reversal_%s", transferID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	original, exists := r.transfers[transferID]
+	if !exists {
+		return nil, ErrTransferNotFound
+	}
+	if original.ReversedBy != "" {
+		return nil, fmt.Errorf("%w by %s", ErrTransferAlreadyReversed, original.ReversedBy)
+	}
+	if original.Reverses != "" {
+		return nil, fmt.Errorf("%w: it reverses %s", ErrTransferAlreadyReversed, original.Reverses)
+	}
+	if original.PendingStatus != "" {
+		return nil, fmt.Errorf("%w: transfer %s is %s", ErrTransferPending, original.ID, original.PendingStatus)
+	}
+
+	if account := r.accounts[original.CreditAccountID]; account != nil && account.CustomerID != "" {
+		if balance := r.accountBalance(original.CreditAccountID); balance < original.Amount {
+			return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientBalance, balance, original.Amount)
+		}
+	}
+
+	reversal := r.newTransfer(original.CreditAccountID, original.DebitAccountID, original.Amount, original.Code, reference)
+	reversal.Reverses = original.ID
+	original.ReversedBy = reversal.ID
+	r.transfers[reversal.ID] = reversal
+	r.updateAccountBalance(reversal.DebitAccountID, reversal.Amount, true)
+	r.updateAccountBalance(reversal.CreditAccountID, reversal.Amount, false)
+
+	log.Printf("Mock: Reversed transfer %s with %s: %s -> %s (%d)",
+		original.ID, reversal.ID, reversal.DebitAccountID, reversal.CreditAccountID, reversal.Amount)
+
+	return &models.TransferResponse{
+		TransferID: reversal.ID,
+		Status:     "success",
+	}, nil
+}
+
 func (r *MockTigerBeetleRepo) Close() error {
 	log.Println("Mock: TigerBeetle repository closed")
 	return nil
@@ -120,8 +375,14 @@ func (r *MockTigerBeetleRepo) generateStringID() string {
 	return fmt.Sprintf("%x", bytes)
 }
 
-func (r *MockTigerBeetleRepo) generateOrgLiabilityAccount(orgID string) string {
-	return fmt.Sprintf("liability_%s", orgID)
+// generateOrgLiabilityAccount returns the org's liability account for one
+// transfer code. As in TigerBeetle, where both sides of a transfer share a
+// ledger, points and stamps are owed from separate accounts.
+func (r *MockTigerBeetleRepo) generateOrgLiabilityAccount(orgID string, code uint16) string {
+	if code == models.TransferCodeStamps {
+		return fmt.Sprintf("liability_stamps_%s", orgID)
+	}
+	return fmt.Sprintf("liability_points_%s", orgID)
 }
 
 func (r *MockTigerBeetleRepo) generateCustomerPointsAccount(orgID, customerID string) string {
@@ -132,19 +393,51 @@ func (r *MockTigerBeetleRepo) generateCustomerStampsAccount(orgID, customerID st
 	return fmt.Sprintf("stamps_%s_%s", orgID, customerID)
 }
 
-func (r *MockTigerBeetleRepo) updateAccountBalance(accountID string, amount uint64, isDebit bool) {
+func (r *MockTigerBeetleRepo) newTransfer(debitAccountID, creditAccountID string, amount uint64, code uint16, reference string) *models.Transfer {
+	return &models.Transfer{
+		ID:              r.generateStringID(),
+		DebitAccountID:  debitAccountID,
+		CreditAccountID: creditAccountID,
+		Amount:          amount,
+		Code:            code,
+		Reference:       reference,
+		Timestamp:       uint64(r.now().Unix()),
+	}
+}
+
+// accountBalance returns an account's credits less debits, or zero if it does
+// not exist. It must be called with r.mu held.
+func (r *MockTigerBeetleRepo) accountBalance(accountID string) uint64 {
 	account, exists := r.accounts[accountID]
 	if !exists {
-		// Create account if it doesn't exist
-		account = &models.Account{
-			ID:            accountID,
-			DebitsPosted:  0,
-			CreditsPosted: 0,
-			Timestamp:     uint64(time.Now().Unix()),
-		}
-		r.accounts[accountID] = account
+		return 0
 	}
-	
+	return account.CreditsPosted - account.DebitsPosted
+}
+
+// ensureAccount creates accountID if it does not exist yet. It must be called
+// with r.mu held for writing.
+func (r *MockTigerBeetleRepo) ensureAccount(accountID, orgID, customerID string, accountType models.AccountType, code uint16) {
+	if _, exists := r.accounts[accountID]; exists {
+		return
+	}
+
+	r.accounts[accountID] = &models.Account{
+		ID:          accountID,
+		OrgID:       orgID,
+		CustomerID:  customerID,
+		AccountType: accountType,
+		Code:        code,
+		Timestamp:   uint64(r.now().Unix()),
+	}
+
+	log.Printf("Mock: Provisioned account %s for customer %s in org %s", accountID, customerID, orgID)
+}
+
+// updateAccountBalance must be called with r.mu held for writing, after
+// ensureAccount has provisioned accountID
+func (r *MockTigerBeetleRepo) updateAccountBalance(accountID string, amount uint64, isDebit bool) {
+	account := r.accounts[accountID]
 	if isDebit {
 		account.DebitsPosted += amount
 	} else {
@@ -0,0 +1,518 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/loyalty/ledger/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test CreateTransfer
+func TestCreateTransfer_ConcurrentTransfers(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	const workers = 50
+	const transfersPerWorker = 20
+	const amount = 3
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < transfersPerWorker; i++ {
+				_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+					OrgID:           "test_org",
+					CustomerID:      "test_customer",
+					TransactionType: "points_accrual",
+					Amount:          amount,
+					Reference:       fmt.Sprintf("worker_%d_%d", worker, i),
+				})
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+
+	// Read balances while transfers are still being written
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_, err := repo.GetBalance(ctx, "test_org", "test_customer")
+			assert.NoError(t, err)
+		}
+	}()
+
+	wg.Wait()
+
+	// Assertions
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(workers*transfersPerWorker*amount), balances["points"])
+	assert.Len(t, repo.transfers, workers*transfersPerWorker)
+}
+
+func TestCreateTransfer_ProvisionsAccountsForNewCustomer(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	// Test data - a customer the ledger has never seen
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "new_customer",
+		TransactionType: "points_accrual",
+		Amount:          50,
+		Code:            models.TransferCodePoints,
+	})
+	assert.NoError(t, err)
+	_, err = repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "new_customer",
+		TransactionType: "stamps_accrual",
+		Amount:          2,
+		Code:            models.TransferCodeStamps,
+	})
+	assert.NoError(t, err)
+
+	// Assertions
+	points, err := repo.GetAccount(ctx, "points_test_org_new_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", points.OrgID)
+	assert.Equal(t, "new_customer", points.CustomerID)
+	assert.Equal(t, models.AccountTypeAsset, points.AccountType)
+	assert.Equal(t, models.TransferCodePoints, points.Code)
+	assert.Equal(t, uint64(50), points.CreditsPosted)
+
+	stamps, err := repo.GetAccount(ctx, "stamps_test_org_new_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, models.AccountTypeAsset, stamps.AccountType)
+	assert.Equal(t, models.TransferCodeStamps, stamps.Code)
+	assert.Equal(t, uint64(2), stamps.CreditsPosted)
+
+	liability, err := repo.GetAccount(ctx, "liability_points_test_org")
+	assert.NoError(t, err)
+	assert.Equal(t, models.AccountTypeLiability, liability.AccountType)
+	assert.Equal(t, models.TransferCodePoints, liability.Code)
+	assert.Equal(t, uint64(50), liability.DebitsPosted)
+
+	liability, err = repo.GetAccount(ctx, "liability_stamps_test_org")
+	assert.NoError(t, err)
+	assert.Equal(t, models.TransferCodeStamps, liability.Code)
+	assert.Equal(t, uint64(2), liability.DebitsPosted)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "new_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50), balances["points"])
+	assert.Equal(t, uint64(2), balances["stamps"])
+}
+
+func TestCreateTransfer_ReusesExistingAccounts(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+			OrgID:           "test_org",
+			CustomerID:      "test_customer",
+			TransactionType: "points_accrual",
+			Amount:          10,
+		})
+		assert.NoError(t, err)
+	}
+
+	// Assertions - one liability and one points account
+	assert.Len(t, repo.accounts, 2)
+	points, err := repo.GetAccount(ctx, "points_test_org_test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(30), points.CreditsPosted)
+}
+
+func TestCreateTransfer_RedemptionExceedingBalanceIsRejected(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "points_accrual",
+		Amount:          40,
+	})
+	assert.NoError(t, err)
+	transfersBefore := len(repo.transfers)
+	accountsBefore := len(repo.accounts)
+
+	response, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "points_redemption",
+		Amount:          50,
+	})
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.EqualError(t, err, "insufficient balance: have 40, need 50")
+	assert.Nil(t, response)
+	assert.Len(t, repo.transfers, transfersBefore)
+	assert.Len(t, repo.accounts, accountsBefore)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(40), balances["points"])
+}
+
+func TestCreateTransfer_StampsRedemptionWithoutAccountIsRejected(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "new_customer",
+		TransactionType: "stamps_redemption",
+		Amount:          1,
+	})
+
+	// Assertions - no account is provisioned for a rejected transfer
+	assert.EqualError(t, err, "insufficient balance: have 0, need 1")
+	assert.Empty(t, repo.transfers)
+	assert.Empty(t, repo.accounts)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "new_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), balances["stamps"])
+}
+
+func TestCreateTransfer_RedemptionWithinBalance(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "stamps_accrual",
+		Amount:          3,
+	})
+	assert.NoError(t, err)
+
+	_, err = repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "stamps_redemption",
+		Amount:          3,
+	})
+
+	// Assertions - spending the whole balance is allowed
+	assert.NoError(t, err)
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), balances["stamps"])
+}
+
+// Test CreateRedemption
+func TestCreateRedemption_DeductsCostAndCreditsBonus(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "points_accrual",
+		Amount:          500,
+	})
+	assert.NoError(t, err)
+	transfersBefore := len(repo.transfers)
+
+	response, err := repo.CreateRedemption(ctx, &models.CreateRedemptionRequest{
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		RewardID:    "free_coffee",
+		Points:      300,
+		BonusPoints: 25,
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "success", response.Status)
+	assert.Len(t, response.TransferIDs, 2)
+	assert.Len(t, repo.transfers, transfersBefore+2)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(500-300+25), balances["points"])
+
+	cost := repo.transfers[response.TransferIDs[0]]
+	assert.Equal(t, "points_test_org_test_customer", cost.DebitAccountID)
+	assert.Equal(t, uint64(300), cost.Amount)
+	assert.Equal(t, "redemption_free_coffee", cost.Reference)
+
+	bonus := repo.transfers[response.TransferIDs[1]]
+	assert.Equal(t, "points_test_org_test_customer", bonus.CreditAccountID)
+	assert.Equal(t, uint64(25), bonus.Amount)
+	assert.Equal(t, "redemption_free_coffee_bonus", bonus.Reference)
+}
+
+func TestCreateRedemption_StampsCostWithPointsBonus(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "stamps_accrual",
+		Amount:          10,
+	})
+	assert.NoError(t, err)
+
+	_, err = repo.CreateRedemption(ctx, &models.CreateRedemptionRequest{
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		RewardID:    "free_sandwich",
+		Stamps:      10,
+		BonusPoints: 50,
+	})
+
+	// Assertions
+	assert.NoError(t, err)
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), balances["stamps"])
+	assert.Equal(t, uint64(50), balances["points"])
+}
+
+func TestCreateRedemption_InsufficientBalancePostsNothing(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	_, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "points_accrual",
+		Amount:          100,
+	})
+	assert.NoError(t, err)
+	transfersBefore := len(repo.transfers)
+
+	// The bonus would cover the shortfall, but must not fund its own redemption
+	response, err := repo.CreateRedemption(ctx, &models.CreateRedemptionRequest{
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		RewardID:    "free_coffee",
+		Points:      110,
+		BonusPoints: 25,
+	})
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.Nil(t, response)
+	assert.Len(t, repo.transfers, transfersBefore)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), balances["points"])
+}
+
+// Test GetOrgLiability
+func TestGetOrgLiability_SumsCustomerBalances(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	transfers := []models.CreateTransferRequest{
+		{OrgID: "test_org", CustomerID: "cust_1", TransactionType: "points_accrual", Amount: 300, Code: models.TransferCodePoints},
+		{OrgID: "test_org", CustomerID: "cust_1", TransactionType: "points_redemption", Amount: 100, Code: models.TransferCodePoints},
+		{OrgID: "test_org", CustomerID: "cust_2", TransactionType: "points_accrual", Amount: 50, Code: models.TransferCodePoints},
+		{OrgID: "test_org", CustomerID: "cust_2", TransactionType: "stamps_accrual", Amount: 4, Code: models.TransferCodeStamps},
+		{OrgID: "other_org", CustomerID: "cust_1", TransactionType: "points_accrual", Amount: 999, Code: models.TransferCodePoints},
+	}
+	for i := range transfers {
+		_, err := repo.CreateTransfer(ctx, &transfers[i])
+		assert.NoError(t, err)
+	}
+
+	liability, err := repo.GetOrgLiability(ctx, "test_org")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(250), liability["points"])
+	assert.Equal(t, uint64(4), liability["stamps"])
+}
+
+// Test ListAccounts
+func TestListAccounts_OrgAccountsBalance(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	transfers := []models.CreateTransferRequest{
+		{OrgID: "test_org", CustomerID: "cust_1", TransactionType: "points_accrual", Amount: 300, Code: models.TransferCodePoints},
+		{OrgID: "test_org", CustomerID: "cust_1", TransactionType: "points_redemption", Amount: 100, Code: models.TransferCodePoints},
+		{OrgID: "test_org", CustomerID: "cust_2", TransactionType: "stamps_accrual", Amount: 4, Code: models.TransferCodeStamps},
+		{OrgID: "other_org", CustomerID: "cust_1", TransactionType: "points_accrual", Amount: 999, Code: models.TransferCodePoints},
+	}
+	for i := range transfers {
+		_, err := repo.CreateTransfer(ctx, &transfers[i])
+		assert.NoError(t, err)
+	}
+
+	accounts, err := repo.ListAccounts(ctx, "test_org")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 4)
+
+	// Points and stamps each balance against their own liability account
+	debits := map[uint16]uint64{}
+	credits := map[uint16]uint64{}
+	for i, account := range accounts {
+		assert.Equal(t, "test_org", account.OrgID)
+		if i > 0 {
+			assert.Less(t, accounts[i-1].ID, account.ID)
+		}
+		debits[account.Code] += account.DebitsPosted
+		credits[account.Code] += account.CreditsPosted
+	}
+	assert.Equal(t, uint64(400), debits[models.TransferCodePoints])
+	assert.Equal(t, uint64(4), debits[models.TransferCodeStamps])
+	assert.Equal(t, debits, credits)
+
+	// The accounts are copies
+	accounts[0].CreditsPosted += 1
+	again, err := repo.ListAccounts(ctx, "test_org")
+	assert.NoError(t, err)
+	assert.NotEqual(t, accounts[0].CreditsPosted, again[0].CreditsPosted)
+}
+
+func TestListAccounts_UnknownOrg(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+
+	accounts, err := repo.ListAccounts(context.Background(), "missing_org")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, accounts)
+}
+
+// Test ListTransfers
+func TestListTransfers_FiltersByCustomerNewestFirst(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	transfers := []models.CreateTransferRequest{
+		{OrgID: "test_org", CustomerID: "cust_1", TransactionType: "points_accrual", Amount: 300, Reference: "first"},
+		{OrgID: "test_org", CustomerID: "cust_2", TransactionType: "points_accrual", Amount: 50, Reference: "other_customer"},
+		{OrgID: "other_org", CustomerID: "cust_1", TransactionType: "points_accrual", Amount: 999, Reference: "other_org"},
+		{OrgID: "test_org", CustomerID: "cust_1", TransactionType: "stamps_accrual", Amount: 2, Reference: "second"},
+		{OrgID: "test_org", CustomerID: "cust_1", TransactionType: "points_redemption", Amount: 100, Reference: "third"},
+	}
+	for i := range transfers {
+		response, err := repo.CreateTransfer(ctx, &transfers[i])
+		assert.NoError(t, err)
+		// Spread the transfers a second apart so the order is deterministic
+		repo.transfers[response.TransferID].Timestamp = uint64(1760000000 + i)
+	}
+
+	page, err := repo.ListTransfers(ctx, "test_org", "cust_1", 10, 0)
+
+	// Assertions
+	assert.NoError(t, err)
+	var references []string
+	for _, transfer := range page {
+		references = append(references, transfer.Reference)
+	}
+	assert.Equal(t, []string{"third", "second", "first"}, references)
+
+	page, err = repo.ListTransfers(ctx, "test_org", "cust_1", 1, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, page, 1) {
+		assert.Equal(t, "second", page[0].Reference)
+	}
+
+	page, err = repo.ListTransfers(ctx, "test_org", "cust_1", 10, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+
+	page, err = repo.ListTransfers(ctx, "test_org", "cust_1", 10, 0, "first", "third", "other_customer")
+	assert.NoError(t, err)
+	references = nil
+	for _, transfer := range page {
+		references = append(references, transfer.Reference)
+	}
+	assert.Equal(t, []string{"third", "first"}, references)
+}
+
+// Test ReverseTransfer
+func TestReverseTransfer_SwapsAccounts(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	accrual, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID: "test_org", CustomerID: "test_customer", TransactionType: "points_accrual", Amount: 300, Code: models.TransferCodePoints,
+	})
+	assert.NoError(t, err)
+
+	reversal, err := repo.ReverseTransfer(ctx, accrual.TransferID, "")
+
+	// Assertions
+	assert.NoError(t, err)
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), balances["points"])
+
+	original := repo.transfers[accrual.TransferID]
+	compensating := repo.transfers[reversal.TransferID]
+	assert.Equal(t, original.CreditAccountID, compensating.DebitAccountID)
+	assert.Equal(t, original.DebitAccountID, compensating.CreditAccountID)
+	assert.Equal(t, uint64(300), compensating.Amount)
+	assert.Equal(t, models.TransferCodePoints, compensating.Code)
+	assert.Equal(t, "reversal_"+accrual.TransferID, compensating.Reference)
+	assert.Equal(t, accrual.TransferID, compensating.Reverses)
+	assert.Equal(t, reversal.TransferID, original.ReversedBy)
+}
+
+func TestReverseTransfer_OnlyOnce(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	accrual, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID: "test_org", CustomerID: "test_customer", TransactionType: "points_accrual", Amount: 300,
+	})
+	assert.NoError(t, err)
+	reversal, err := repo.ReverseTransfer(ctx, accrual.TransferID, "")
+	assert.NoError(t, err)
+
+	_, err = repo.ReverseTransfer(ctx, accrual.TransferID, "")
+	assert.ErrorIs(t, err, ErrTransferAlreadyReversed)
+
+	_, err = repo.ReverseTransfer(ctx, reversal.TransferID, "")
+	assert.ErrorIs(t, err, ErrTransferAlreadyReversed)
+
+	// Assertions - only the one reversal was posted
+	assert.Len(t, repo.transfers, 2)
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), balances["points"])
+}
+
+func TestReverseTransfer_RejectsSpentAccrual(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	accrual, err := repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID: "test_org", CustomerID: "test_customer", TransactionType: "points_accrual", Amount: 300,
+	})
+	assert.NoError(t, err)
+	_, err = repo.CreateTransfer(ctx, &models.CreateTransferRequest{
+		OrgID: "test_org", CustomerID: "test_customer", TransactionType: "points_redemption", Amount: 200,
+	})
+	assert.NoError(t, err)
+
+	_, err = repo.ReverseTransfer(ctx, accrual.TransferID, "")
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.Empty(t, repo.transfers[accrual.TransferID].ReversedBy)
+
+	_, err = repo.ReverseTransfer(ctx, "missing", "")
+	assert.ErrorIs(t, err, ErrTransferNotFound)
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/loyalty/ledger/internal/models"
+)
+
+// SettlePendingTransfer posts or voids the customer's pending points accrual
+// with req.Reference, the way TigerBeetle settles a two-phase transfer.
+// Posting credits what is still held in a new transfer; voiding releases the
+// hold, or only req.Amount of it, so the points can never be spent.
+func (r *MockTigerBeetleRepo) SettlePendingTransfer(ctx context.Context, req *models.SettleTransferRequest) (*models.TransferResponse, error) {
+	pointsAccountID := r.generateCustomerPointsAccount(req.OrgID, req.CustomerID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pending *models.Transfer
+	for _, transfer := range r.transfers {
+		if transfer.PendingStatus == models.PendingStatusPending &&
+			transfer.CreditAccountID == pointsAccountID && transfer.Reference == req.Reference {
+			pending = transfer
+			break
+		}
+	}
+	if pending == nil {
+		return nil, fmt.Errorf("%w: %s for customer %s", ErrTransferNotPending, req.Reference, req.CustomerID)
+	}
+
+	if req.Void {
+		return r.voidPending(pending, req.Amount), nil
+	}
+	return r.postPending(pending), nil
+}
+
+// SettleDuePendingTransfers posts every pending accrual whose settlement delay
+// has passed, returning the total points posted
+func (r *MockTigerBeetleRepo) SettleDuePendingTransfers(ctx context.Context) (uint64, error) {
+	now := uint64(r.now().Unix())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total uint64
+	for _, transfer := range r.transfers {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		if transfer.PendingStatus != models.PendingStatusPending || transfer.SettlesAt == 0 || transfer.SettlesAt > now {
+			continue
+		}
+		total += transfer.Amount - transfer.Voided
+		r.postPending(transfer)
+	}
+
+	return total, nil
+}
+
+// postPending credits what pending still holds. It must be called with r.mu
+// held for writing.
+func (r *MockTigerBeetleRepo) postPending(pending *models.Transfer) *models.TransferResponse {
+	held := pending.Amount - pending.Voided
+	r.updatePendingBalance(pending.DebitAccountID, -int64(held), true)
+	r.updatePendingBalance(pending.CreditAccountID, -int64(held), false)
+
+	posting := r.newTransfer(pending.DebitAccountID, pending.CreditAccountID, held, pending.Code, pending.Reference)
+	posting.PendingID = pending.ID
+	posting.UserData = pending.UserData
+	posting.ExpiresAt = r.pointsExpiry(pending.ExpiryDays)
+	r.transfers[posting.ID] = posting
+	r.updateAccountBalance(posting.DebitAccountID, held, true)
+	r.updateAccountBalance(posting.CreditAccountID, held, false)
+
+	pending.PendingStatus = models.PendingStatusPosted
+	pending.PostedBy = posting.ID
+
+	log.Printf("Mock: Posted pending transfer %s with %s (%d)", pending.ID, posting.ID, held)
+
+	return &models.TransferResponse{
+		TransferID: posting.ID,
+		Status:     models.PendingStatusPosted,
+		Amount:     held,
+		HeldAt:     pending.Timestamp,
+	}
+}
+
+// voidPending releases amount of what pending holds, or all of it when amount
+// is zero or more than is left. It must be called with r.mu held for writing.
+func (r *MockTigerBeetleRepo) voidPending(pending *models.Transfer, amount uint64) *models.TransferResponse {
+	held := pending.Amount - pending.Voided
+	if amount == 0 || amount > held {
+		amount = held
+	}
+	r.updatePendingBalance(pending.DebitAccountID, -int64(amount), true)
+	r.updatePendingBalance(pending.CreditAccountID, -int64(amount), false)
+
+	pending.Voided += amount
+	if pending.Voided == pending.Amount {
+		pending.PendingStatus = models.PendingStatusVoided
+	}
+
+	log.Printf("Mock: Voided %d of pending transfer %s, %d still held", amount, pending.ID, held-amount)
+
+	return &models.TransferResponse{
+		TransferID: pending.ID,
+		Status:     pending.PendingStatus,
+		Amount:     amount,
+		HeldAt:     pending.Timestamp,
+	}
+}
+
+// updatePendingBalance adds amount, which may be negative to release a hold,
+// to an account's pending debits or credits. It must be called with r.mu held
+// for writing, after ensureAccount has provisioned accountID.
+func (r *MockTigerBeetleRepo) updatePendingBalance(accountID string, amount int64, isDebit bool) {
+	account := r.accounts[accountID]
+	if isDebit {
+		account.DebitsPending = uint64(int64(account.DebitsPending) + amount)
+	} else {
+		account.CreditsPending = uint64(int64(account.CreditsPending) + amount)
+	}
+}
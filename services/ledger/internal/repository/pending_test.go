@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loyalty/ledger/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func accruePending(t *testing.T, repo *MockTigerBeetleRepo, reference string, amount, settleAfter uint64) string {
+	response, err := repo.CreateTransfer(context.Background(), &models.CreateTransferRequest{
+		OrgID:              "test_org",
+		CustomerID:         "test_customer",
+		TransactionType:    "points_accrual",
+		Amount:             amount,
+		Reference:          reference,
+		UserData:           5000,
+		ExpiryDays:         testExpiryDays,
+		Pending:            true,
+		SettleAfterSeconds: settleAfter,
+	})
+	assert.NoError(t, err)
+	return response.TransferID
+}
+
+func settle(repo *MockTigerBeetleRepo, reference string, void bool, amount uint64) (*models.TransferResponse, error) {
+	return repo.SettlePendingTransfer(context.Background(), &models.SettleTransferRequest{
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Reference:  reference,
+		Void:       void,
+		Amount:     amount,
+	})
+}
+
+// Test pending accruals
+func TestPendingAccrual_NotSpendableUntilPosted(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	accruePending(t, repo, "pos_transaction_txn_1", 100, 0)
+
+	// Held points are pending, not part of the balance
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), balances["points"])
+
+	account, err := repo.GetAccount(ctx, "points_test_org_test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), account.CreditsPending)
+	assert.Equal(t, uint64(0), account.CreditsPosted)
+
+	_, err = repo.CreateRedemption(ctx, &models.CreateRedemptionRequest{
+		OrgID: "test_org", CustomerID: "test_customer", RewardID: "free_coffee", Points: 50,
+	})
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+	response, err := settle(repo, "pos_transaction_txn_1", false, 0)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, models.PendingStatusPosted, response.Status)
+
+	balances, err = repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), balances["points"])
+
+	account, err = repo.GetAccount(ctx, "points_test_org_test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), account.CreditsPending)
+
+	_, err = repo.CreateRedemption(ctx, &models.CreateRedemptionRequest{
+		OrgID: "test_org", CustomerID: "test_customer", RewardID: "free_coffee", Points: 50,
+	})
+	assert.NoError(t, err)
+}
+
+func TestPendingAccrual_VoidedOnReversal(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	pendingID := accruePending(t, repo, "pos_transaction_txn_1", 100, 0)
+
+	response, err := settle(repo, "pos_transaction_txn_1", true, 0)
+
+	// Assertions - the hold is released and nothing is ever credited
+	assert.NoError(t, err)
+	assert.Equal(t, models.PendingStatusVoided, response.Status)
+
+	account, err := repo.GetAccount(ctx, "points_test_org_test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), account.CreditsPending)
+	assert.Equal(t, uint64(0), account.CreditsPosted)
+
+	_, err = settle(repo, "pos_transaction_txn_1", false, 0)
+	assert.ErrorIs(t, err, ErrTransferNotPending)
+
+	_, err = repo.ReverseTransfer(ctx, pendingID, "")
+	assert.ErrorIs(t, err, ErrTransferPending)
+}
+
+func TestPendingAccrual_PartialVoidPostsTheRest(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+	ctx := context.Background()
+
+	accruePending(t, repo, "pos_transaction_txn_1", 100, 0)
+
+	response, err := settle(repo, "pos_transaction_txn_1", true, 40)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PendingStatusPending, response.Status)
+	assert.Equal(t, uint64(40), response.Amount)
+
+	response, err = settle(repo, "pos_transaction_txn_1", false, 0)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, models.PendingStatusPosted, response.Status)
+	assert.Equal(t, uint64(60), response.Amount)
+	// The posting keeps the caller's data from the hold
+	assert.Equal(t, uint64(5000), repo.transfers[response.TransferID].UserData)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(60), balances["points"])
+
+	liability, err := repo.GetAccount(ctx, "liability_points_test_org")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), liability.DebitsPending)
+	assert.Equal(t, uint64(60), liability.DebitsPosted)
+}
+
+func TestPendingAccrual_UnknownReference(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+
+	_, err := settle(repo, "pos_transaction_txn_1", false, 0)
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrTransferNotPending)
+}
+
+func TestPendingAccrual_OnlyPointsAccruals(t *testing.T) {
+	repo := NewMockTigerBeetleRepo()
+
+	_, err := repo.CreateTransfer(context.Background(), &models.CreateTransferRequest{
+		OrgID:           "test_org",
+		CustomerID:      "test_customer",
+		TransactionType: "stamps_accrual",
+		Amount:          1,
+		Pending:         true,
+	})
+
+	// Assertions
+	assert.Error(t, err)
+}
+
+// Test SettleDuePendingTransfers
+func TestSettleDuePendingTransfers(t *testing.T) {
+	repo, advance := setupExpiryRepo()
+	ctx := context.Background()
+
+	accruePending(t, repo, "pos_transaction_txn_1", 100, 3600)
+	accruePending(t, repo, "pos_transaction_txn_2", 50, 0)
+
+	// Not due yet
+	posted, err := repo.SettleDuePendingTransfers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), posted)
+
+	advance(time.Hour)
+	posted, err = repo.SettleDuePendingTransfers(ctx)
+
+	// Assertions - only the delayed accrual settles; the other waits for an
+	// explicit settlement
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), posted)
+
+	balances, err := repo.GetBalance(ctx, "test_org", "test_customer")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), balances["points"])
+
+	// Posted points expire from when they were posted, and held ones never do
+	advance(29 * 24 * time.Hour)
+	expired, err := repo.ExpirePoints(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), expired)
+
+	advance(24 * time.Hour)
+	expired, err = repo.ExpirePoints(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), expired)
+}
@@ -0,0 +1,9 @@
+// Package codes holds the ledger's transfer codes, shared with the services
+// that create and read its transfers.
+package codes
+
+// Transfer codes tag what a transfer moves
+const (
+	Points uint16 = 1
+	Stamps uint16 = 2
+)
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/loyalty/membership/internal/alerts"
+	"github.com/loyalty/membership/internal/clients"
+	"github.com/loyalty/membership/internal/export"
 	"github.com/loyalty/membership/internal/handlers"
 	"github.com/loyalty/membership/internal/repository"
+	"github.com/segmentio/kafka-go"
 )
 
 func main() {
@@ -21,7 +28,56 @@ func main() {
 	}
 	defer repo.Close()
 
-	handler := handlers.NewMembershipHandler(repo)
+	ledgerURL := os.Getenv("LEDGER_URL")
+	if ledgerURL == "" {
+		ledgerURL = "http://localhost:8001"
+	}
+
+	analyticsURL := os.Getenv("ANALYTICS_URL")
+	if analyticsURL == "" {
+		analyticsURL = "http://localhost:8003"
+	}
+
+	ledgerClient := clients.NewLedgerClient(ledgerURL)
+	analyticsClient := clients.NewAnalyticsClient(analyticsURL)
+
+	exporter := export.NewExporter(repo, ledgerClient, analyticsClient)
+
+	// Balance alerts publish to Kafka, so they only run when an interval is
+	// set
+	if interval := os.Getenv("BALANCE_ALERT_INTERVAL"); interval != "" {
+		alertConfig := alerts.DefaultConfig()
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			log.Fatalf("Invalid BALANCE_ALERT_INTERVAL %q: %v", interval, err)
+		}
+		alertConfig.Interval = duration
+
+		kafkaBrokers := os.Getenv("KAFKA_BROKERS")
+		if kafkaBrokers == "" {
+			kafkaBrokers = "localhost:9092"
+		}
+		alertWriter := &kafka.Writer{
+			Addr:     kafka.TCP(strings.Split(kafkaBrokers, ",")...),
+			Balancer: &kafka.LeastBytes{},
+		}
+		defer alertWriter.Close()
+
+		alerter := alerts.NewAlerter(repo, ledgerClient, alertWriter, alertConfig)
+		go alerter.Run(context.Background())
+	}
+
+	handlerConfig := handlers.DefaultHandlerConfig()
+	if preferences, ok := os.LookupEnv("CONSENT_PREFERENCES"); ok {
+		handlerConfig.ConsentPreferences = nil
+		for _, preference := range strings.Split(preferences, ",") {
+			if preference = strings.TrimSpace(preference); preference != "" {
+				handlerConfig.ConsentPreferences = append(handlerConfig.ConsentPreferences, preference)
+			}
+		}
+	}
+
+	handler := handlers.NewMembershipHandlerWithConfig(repo, exporter, handlerConfig)
 
 	r := gin.Default()
 
@@ -29,16 +85,28 @@ func main() {
 	{
 		// Customer APIs
 		v1.POST("/customers", handler.CreateCustomer)
+		v1.GET("/customers/search", handler.SearchCustomers)
 		v1.GET("/customers/:id", handler.GetCustomer)
 		v1.GET("/customers", handler.GetCustomersByOrg)
 		v1.PATCH("/customers/:id", handler.UpdateCustomer)
+		v1.DELETE("/customers/:id", handler.DeactivateCustomer)
+		v1.POST("/customers/:id/reactivate", handler.ReactivateCustomer)
+		v1.PUT("/customers/:id/multiplier", handler.SetCustomerMultiplier)
+		v1.DELETE("/customers/:id/multiplier", handler.ClearCustomerMultiplier)
+		v1.POST("/customers/:id/tags", handler.AddCustomerTags)
+		v1.DELETE("/customers/:id/tags/:tag", handler.RemoveCustomerTag)
+		v1.GET("/customers/:id/export", handler.ExportCustomer)
+		v1.GET("/customers/:id/consent-history", handler.GetConsentHistory)
 		
 		// Organization APIs
 		v1.POST("/organizations", handler.CreateOrganization)
 		v1.GET("/organizations/:id", handler.GetOrganization)
+		v1.PATCH("/organizations/:id", handler.UpdateOrganization)
+		v1.GET("/organizations/:id/accrual-rate", handler.GetAccrualRate)
 		
 		// Location APIs
 		v1.POST("/locations", handler.CreateLocation)
+		v1.POST("/locations/bulk", handler.CreateLocations)
 		v1.GET("/locations/:id", handler.GetLocation)
 		v1.GET("/locations", handler.GetLocationsByOrg)
 		v1.PATCH("/locations/:id", handler.UpdateLocation)
@@ -0,0 +1,115 @@
+// Package accrual describes an org's points accrual rate the way customers
+// see it, in the org's currency and locale. Points and formatting come from
+// the stream processor's packages, so the description matches what is
+// actually accrued and how rewards are worded.
+package accrual
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/loyalty/membership/internal/models"
+	"github.com/loyalty/stream/pkg/locale"
+	"github.com/loyalty/stream/pkg/points"
+)
+
+// Rate displays control how an accrual rate is described. An empty display
+// behaves like DisplayPerUnit.
+const (
+	// DisplayPerUnit gives the points earned per one unit of currency, which
+	// may be fractional, e.g. "0.5 pts per $1"
+	DisplayPerUnit = "per_unit"
+	// DisplayWholePoints gives the smallest spend that earns a whole number
+	// of points, e.g. "1 pt per $2"
+	DisplayWholePoints = "whole_points"
+)
+
+// exampleAmounts are the purchases each rate is illustrated with
+var exampleAmounts = []float64{1, 10, 25, 100}
+
+// maxWholePointsSpend bounds the spend DisplayWholePoints looks for. Rates
+// that need more fall back to DisplayPerUnit.
+const maxWholePointsSpend = 1000
+
+// AccrualRate is an org's base points accrual rate described for customers.
+// Location promotions and tier multipliers are not included.
+type AccrualRate struct {
+	OrgID           string    `json:"org_id"`
+	PointsPerDollar float64   `json:"points_per_dollar"`
+	Currency        string    `json:"currency"`
+	Locale          string    `json:"locale"`
+	Display         string    `json:"display"`
+	Description     string    `json:"description"`
+	Examples        []Example `json:"examples"`
+}
+
+// Example is what one purchase earns at the org's rate
+type Example struct {
+	Amount      float64 `json:"amount"`
+	Points      int     `json:"points"`
+	Description string  `json:"description"`
+}
+
+// Describe renders org's accrual rate in its currency and locale
+func Describe(org *models.Organization) AccrualRate {
+	settings := org.Settings
+	rate := AccrualRate{
+		OrgID:           org.OrgID,
+		PointsPerDollar: settings.PointsPerDollar,
+		Currency:        settings.Currency,
+		Locale:          settings.Locale,
+		Display:         settings.AccrualRateDisplay,
+	}
+	if rate.Currency == "" {
+		rate.Currency = locale.DefaultCurrency
+	}
+	if rate.Locale == "" {
+		rate.Locale = locale.DefaultLocale
+	}
+	if rate.Display != DisplayWholePoints {
+		rate.Display = DisplayPerUnit
+	}
+
+	money := func(amount float64) string {
+		return locale.FormatMoneyShort(amount, rate.Currency, rate.Locale)
+	}
+
+	rate.Description = describeRate(settings.PointsPerDollar, rate.Display, rate.Locale, money)
+	for _, amount := range exampleAmounts {
+		earned := points.Calculate(amount, settings.PointsPerDollar)
+		rate.Examples = append(rate.Examples, Example{
+			Amount:      amount,
+			Points:      earned,
+			Description: fmt.Sprintf("Spend %s, earn %s", money(amount), pointsLabel(float64(earned), rate.Locale)),
+		})
+	}
+
+	return rate
+}
+
+func describeRate(pointsPerDollar float64, display, loc string, money func(float64) string) string {
+	if pointsPerDollar <= 0 {
+		return "No points earned on purchases"
+	}
+
+	// Rates are stored as floats, so 0.1 * 3 must still read as 0.3
+	perUnit := math.Round(pointsPerDollar*1e4) / 1e4
+	if display == DisplayWholePoints {
+		for spend := 1; spend <= maxWholePointsSpend; spend++ {
+			earned := pointsPerDollar * float64(spend)
+			if math.Abs(earned-math.Round(earned)) < 1e-9 {
+				return fmt.Sprintf("%s per %s", pointsLabel(math.Round(earned), loc), money(float64(spend)))
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s per %s", pointsLabel(perUnit, loc), money(1))
+}
+
+func pointsLabel(count float64, loc string) string {
+	unit := "pts"
+	if count == 1 {
+		unit = "pt"
+	}
+	return locale.FormatNumber(count, -1, loc) + " " + unit
+}
@@ -0,0 +1,64 @@
+package accrual
+
+import (
+	"testing"
+
+	"github.com/loyalty/membership/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOrg(settings models.OrgSettings) *models.Organization {
+	return &models.Organization{OrgID: "test_org", Settings: settings}
+}
+
+// Test Describe
+func TestDescribe_Rates(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.OrgSettings
+		expected string
+	}{
+		{"defaults", models.OrgSettings{PointsPerDollar: 1}, "1 pt per $1"},
+		{"whole rate", models.OrgSettings{PointsPerDollar: 2}, "2 pts per $1"},
+		{"fractional rate", models.OrgSettings{PointsPerDollar: 0.5}, "0.5 pts per $1"},
+		{"float noise", models.OrgSettings{PointsPerDollar: 0.1 * 3}, "0.3 pts per $1"},
+		{"whole points", models.OrgSettings{PointsPerDollar: 0.5, AccrualRateDisplay: DisplayWholePoints}, "1 pt per $2"},
+		{"whole points above one", models.OrgSettings{PointsPerDollar: 1.5, AccrualRateDisplay: DisplayWholePoints}, "3 pts per $2"},
+		{"German euros", models.OrgSettings{PointsPerDollar: 1.5, Currency: "EUR", Locale: "de-DE"}, "1,5 pts per 1 €"},
+		{"Swiss francs", models.OrgSettings{PointsPerDollar: 0.25, Currency: "CHF", Locale: "de-CH", AccrualRateDisplay: DisplayWholePoints}, "1 pt per CHF 4"},
+		{"yen", models.OrgSettings{PointsPerDollar: 0.01, Currency: "JPY", Locale: "ja-JP", AccrualRateDisplay: DisplayWholePoints}, "1 pt per ¥100"},
+		{"no accrual", models.OrgSettings{}, "No points earned on purchases"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := Describe(testOrg(tt.settings))
+
+			// Assertions
+			assert.Equal(t, tt.expected, rate.Description)
+		})
+	}
+}
+
+func TestDescribe_Examples(t *testing.T) {
+	rate := Describe(testOrg(models.OrgSettings{PointsPerDollar: 12.5, Currency: "EUR", Locale: "fr-FR"}))
+
+	// Assertions
+	assert.Equal(t, "EUR", rate.Currency)
+	assert.Equal(t, DisplayPerUnit, rate.Display)
+	if assert.Len(t, rate.Examples, 4) {
+		assert.Equal(t, 12, rate.Examples[0].Points, "fractional points are dropped")
+		assert.Equal(t, "Spend 1 €, earn 12 pts", rate.Examples[0].Description)
+		assert.Equal(t, 1250, rate.Examples[3].Points)
+		assert.Equal(t, "Spend 100 €, earn 1 250 pts", rate.Examples[3].Description)
+	}
+}
+
+func TestDescribe_Defaults(t *testing.T) {
+	rate := Describe(testOrg(models.OrgSettings{PointsPerDollar: 1}))
+
+	// Assertions
+	assert.Equal(t, "USD", rate.Currency)
+	assert.Equal(t, "en-US", rate.Locale)
+	assert.Equal(t, "Spend $25, earn 25 pts", rate.Examples[2].Description)
+}
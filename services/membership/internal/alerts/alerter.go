@@ -0,0 +1,215 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/loyalty/membership/internal/clients"
+	"github.com/loyalty/membership/internal/models"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Config holds the tunable behaviour of Alerter
+type Config struct {
+	// Interval is how often opted-in customers are scanned. Zero disables
+	// the schedule.
+	Interval time.Duration
+	// BatchSize is how many customers are read per page
+	BatchSize int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:  24 * time.Hour,
+		BatchSize: 100,
+	}
+}
+
+// Alerter scans customers who opted in to balance alerts and publishes a
+// notification when their points are about to expire or their stamp card is
+// one stamp from completion
+type Alerter struct {
+	customers CustomerSource
+	balances  BalanceSource
+	writer    MessageWriter
+	config    Config
+	now       func() time.Time
+}
+
+func NewAlerter(customers CustomerSource, balances BalanceSource, writer MessageWriter, config Config) *Alerter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultConfig().BatchSize
+	}
+	return &Alerter{
+		customers: customers,
+		balances:  balances,
+		writer:    writer,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// ScanAll checks every opted-in customer. A customer that cannot be checked
+// is logged and does not stop the scan.
+func (a *Alerter) ScanAll(ctx context.Context) error {
+	orgs := make(map[string]*models.Organization)
+	scanned, sent := 0, 0
+
+	for offset := 0; ; offset += a.config.BatchSize {
+		customers, err := a.customers.GetBalanceAlertCustomers(ctx, a.config.BatchSize, offset)
+		if err != nil {
+			return fmt.Errorf("failed to list customers: %w", err)
+		}
+
+		for _, customer := range customers {
+			org, ok := orgs[customer.OrgID]
+			if !ok {
+				org, err = a.customers.GetOrganization(ctx, customer.OrgID)
+				if err != nil {
+					log.Printf("Skipping balance alerts for customer %s: failed to get organization: %v", customer.CustomerID, err)
+					continue
+				}
+				orgs[customer.OrgID] = org
+			}
+
+			published, err := a.CheckCustomer(ctx, customer, org)
+			if err != nil {
+				log.Printf("Failed to check balance alerts for customer %s: %v", customer.CustomerID, err)
+			}
+			scanned++
+			sent += len(published)
+		}
+
+		if len(customers) < a.config.BatchSize {
+			break
+		}
+	}
+
+	log.Printf("Balance alert scan checked %d customers and sent %d alerts", scanned, sent)
+	return nil
+}
+
+// CheckCustomer publishes the alerts due to customer that have not already
+// been sent and returns them
+func (a *Alerter) CheckCustomer(ctx context.Context, customer *models.Customer, org *models.Organization) ([]Alert, error) {
+	// Points credited while the org had an expiry still expire after it is
+	// turned off, so every opted-in customer's balance is checked
+	settings := org.Settings
+	summary, err := a.balances.GetBalanceSummary(customer.OrgID, customer.CustomerID, settings.MaxStampsPerCard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	var published []Alert
+	for _, alert := range dueAlerts(settings, summary, a.now()) {
+		if customer.BalanceAlertsSent[alert.Kind] == alert.Key {
+			continue
+		}
+		if err := a.publish(ctx, customer, alert); err != nil {
+			return published, err
+		}
+		published = append(published, alert)
+	}
+
+	return published, nil
+}
+
+// dueAlerts works out which alerts a customer's balance calls for at now.
+// The ledger reports the next points due to expire, which are announced once
+// their expiry is within the org's lead time.
+func dueAlerts(settings models.OrgSettings, summary *clients.BalanceSummary, now time.Time) []Alert {
+	var due []Alert
+
+	if summary.ExpiringPoints > 0 && summary.PointsExpireAt > 0 {
+		leadDays := settings.BalanceAlertLeadDays
+		if leadDays <= 0 {
+			leadDays = DefaultLeadDays
+		}
+
+		expiresAt := time.Unix(int64(summary.PointsExpireAt), 0)
+		if now.Before(expiresAt) && !now.AddDate(0, 0, leadDays).Before(expiresAt) {
+			due = append(due, Alert{
+				Kind: KindPointsExpiring,
+				Key:  expiresAt.UTC().Format("2006-01-02"),
+				Payload: map[string]interface{}{
+					"points":     summary.ExpiringPoints,
+					"expires_at": expiresAt.UTC(),
+				},
+			})
+		}
+	}
+
+	// Cards of a single stamp are complete as soon as they are started
+	if settings.MaxStampsPerCard > 1 && summary.StampsToNextCard == 1 {
+		due = append(due, Alert{
+			Kind: KindStampCardOneAway,
+			Key:  fmt.Sprintf("%d", summary.StampsBalance),
+			Payload: map[string]interface{}{
+				"stamps_on_card":      summary.StampsOnCurrentCard,
+				"max_stamps_per_card": settings.MaxStampsPerCard,
+			},
+		})
+	}
+
+	return due
+}
+
+// publish writes the alert's notification and records it as sent. The record
+// is made after the write, so a failure in between repeats the alert rather
+// than losing it.
+func (a *Alerter) publish(ctx context.Context, customer *models.Customer, alert Alert) error {
+	event := NotificationEvent{
+		EventID:    fmt.Sprintf("%s_%s_%s", alert.Kind, customer.CustomerID, alert.Key),
+		EventType:  "notification." + alert.Kind,
+		OrgID:      customer.OrgID,
+		CustomerID: customer.CustomerID,
+		Timestamp:  a.now(),
+		Payload:    alert.Payload,
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	message := kafka.Message{
+		Topic: fmt.Sprintf("%s.%s", customer.OrgID, event.EventType),
+		Key:   []byte(customer.CustomerID),
+		Value: eventJSON,
+		Time:  event.Timestamp,
+	}
+	if err := a.writer.WriteMessages(ctx, message); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", alert.Kind, err)
+	}
+
+	if err := a.customers.UpdateCustomer(ctx, customer.CustomerID, bson.M{"balance_alerts_sent." + alert.Kind: alert.Key}); err != nil {
+		return fmt.Errorf("failed to record %s: %w", alert.Kind, err)
+	}
+	return nil
+}
+
+// Run scans immediately and then on each interval until ctx is cancelled
+func (a *Alerter) Run(ctx context.Context) {
+	if a.config.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.ScanAll(ctx); err != nil {
+			log.Printf("Scheduled balance alert scan failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
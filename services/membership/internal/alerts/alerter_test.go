@@ -0,0 +1,269 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loyalty/membership/internal/clients"
+	"github.com/loyalty/membership/internal/models"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MockCustomerSource is a mock implementation of the customer source
+type MockCustomerSource struct {
+	mock.Mock
+}
+
+func (m *MockCustomerSource) GetBalanceAlertCustomers(ctx context.Context, limit, offset int) ([]*models.Customer, error) {
+	args := m.Called(ctx, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Customer), args.Error(1)
+}
+
+func (m *MockCustomerSource) GetOrganization(ctx context.Context, orgID string) (*models.Organization, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Organization), args.Error(1)
+}
+
+func (m *MockCustomerSource) UpdateCustomer(ctx context.Context, customerID string, updates bson.M) error {
+	args := m.Called(ctx, customerID, updates)
+	return args.Error(0)
+}
+
+// MockBalanceSource is a mock implementation of the ledger balance lookup
+type MockBalanceSource struct {
+	mock.Mock
+}
+
+func (m *MockBalanceSource) GetBalanceSummary(orgID, customerID string, maxStampsPerCard int) (*clients.BalanceSummary, error) {
+	args := m.Called(orgID, customerID, maxStampsPerCard)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.BalanceSummary), args.Error(1)
+}
+
+// MockMessageWriter is a mock implementation of the Kafka writer
+type MockMessageWriter struct {
+	mock.Mock
+}
+
+func (m *MockMessageWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	args := m.Called(ctx, msgs)
+	return args.Error(0)
+}
+
+var testNow = time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+// Test setup helper
+func setupTestAlerter() (*Alerter, *MockCustomerSource, *MockBalanceSource, *MockMessageWriter) {
+	mockCustomers := &MockCustomerSource{}
+	mockBalances := &MockBalanceSource{}
+	mockWriter := &MockMessageWriter{}
+	alerter := NewAlerter(mockCustomers, mockBalances, mockWriter, DefaultConfig())
+	alerter.now = func() time.Time { return testNow }
+	return alerter, mockCustomers, mockBalances, mockWriter
+}
+
+func testCustomer() *models.Customer {
+	return &models.Customer{
+		CustomerID:  "test_customer",
+		OrgID:       "test_org",
+		Status:      "active",
+		Preferences: models.CustomerPrefs{BalanceAlerts: true},
+	}
+}
+
+func testOrg() *models.Organization {
+	return &models.Organization{
+		OrgID: "test_org",
+		Settings: models.OrgSettings{
+			MaxStampsPerCard: 10,
+			PointsExpiryDays: 365,
+		},
+	}
+}
+
+func decodeNotification(t *testing.T, msgs interface{}) NotificationEvent {
+	messages := msgs.([]kafka.Message)
+	assert.Len(t, messages, 1)
+
+	var event NotificationEvent
+	assert.NoError(t, json.Unmarshal(messages[0].Value, &event))
+	return event
+}
+
+// Test CheckCustomer
+func TestCheckCustomer_StampCardOneAway(t *testing.T) {
+	alerter, mockCustomers, mockBalances, mockWriter := setupTestAlerter()
+	ctx := context.Background()
+
+	// Nine of ten stamps, and no points to expire
+	summary := &clients.BalanceSummary{StampsBalance: 19, StampsOnCurrentCard: 9, StampsToNextCard: 1, CompletedCards: 1}
+
+	// Setup expectations
+	mockBalances.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(summary, nil)
+	mockWriter.On("WriteMessages", ctx, mock.MatchedBy(func(msgs []kafka.Message) bool {
+		return len(msgs) == 1 && msgs[0].Topic == "test_org.notification.stamp_card_one_away"
+	})).Return(nil)
+	mockCustomers.On("UpdateCustomer", ctx, "test_customer", bson.M{"balance_alerts_sent.stamp_card_one_away": "19"}).Return(nil)
+
+	// Test
+	alerts, err := alerter.CheckCustomer(ctx, testCustomer(), testOrg())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, KindStampCardOneAway, alerts[0].Kind)
+
+	event := decodeNotification(t, mockWriter.Calls[0].Arguments.Get(1))
+	assert.Equal(t, "notification.stamp_card_one_away", event.EventType)
+	assert.Equal(t, "test_customer", event.CustomerID)
+	assert.Equal(t, float64(9), event.Payload["stamps_on_card"])
+	assert.Equal(t, float64(10), event.Payload["max_stamps_per_card"])
+
+	mockCustomers.AssertExpectations(t)
+	mockWriter.AssertExpectations(t)
+}
+
+func TestCheckCustomer_PointsExpiringSoon(t *testing.T) {
+	alerter, mockCustomers, mockBalances, mockWriter := setupTestAlerter()
+	ctx := context.Background()
+
+	// 150 of the balance's points expire five days from now
+	summary := &clients.BalanceSummary{
+		PointsBalance:       420,
+		StampsBalance:       3,
+		StampsOnCurrentCard: 3,
+		StampsToNextCard:    7,
+		ExpiringPoints:      150,
+		PointsExpireAt:      uint64(testNow.AddDate(0, 0, 5).Unix()),
+	}
+
+	// Setup expectations
+	mockBalances.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(summary, nil)
+	mockWriter.On("WriteMessages", ctx, mock.Anything).Return(nil)
+	mockCustomers.On("UpdateCustomer", ctx, "test_customer", bson.M{"balance_alerts_sent.points_expiring": "2026-10-19"}).Return(nil)
+
+	// Test
+	alerts, err := alerter.CheckCustomer(ctx, testCustomer(), testOrg())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, KindPointsExpiring, alerts[0].Kind)
+
+	event := decodeNotification(t, mockWriter.Calls[0].Arguments.Get(1))
+	assert.Equal(t, "notification.points_expiring", event.EventType)
+	assert.Equal(t, "points_expiring_test_customer_2026-10-19", event.EventID)
+	assert.Equal(t, float64(150), event.Payload["points"])
+	assert.Equal(t, "2026-10-19T12:00:00Z", event.Payload["expires_at"])
+
+	mockCustomers.AssertExpectations(t)
+}
+
+func TestCheckCustomer_AlreadySentIsSkipped(t *testing.T) {
+	alerter, _, mockBalances, mockWriter := setupTestAlerter()
+	ctx := context.Background()
+
+	customer := testCustomer()
+	customer.BalanceAlertsSent = map[string]string{KindStampCardOneAway: "9"}
+	summary := &clients.BalanceSummary{StampsBalance: 9, StampsOnCurrentCard: 9, StampsToNextCard: 1}
+
+	// Setup expectations
+	mockBalances.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(summary, nil)
+
+	// Test
+	alerts, err := alerter.CheckCustomer(ctx, customer, testOrg())
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Empty(t, alerts)
+	mockWriter.AssertNotCalled(t, "WriteMessages", mock.Anything, mock.Anything)
+}
+
+// Test dueAlerts
+func TestDueAlerts_PointsExpiryWindow(t *testing.T) {
+	settings := models.OrgSettings{PointsExpiryDays: 90, BalanceAlertLeadDays: 14}
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		expected  bool
+	}{
+		{"expiry beyond lead time", testNow.AddDate(0, 0, 30), false},
+		{"expiry at lead time", testNow.AddDate(0, 0, 14), true},
+		{"expiry tomorrow", testNow.AddDate(0, 0, 1), true},
+		{"already expired", testNow, false},
+		{"nothing due", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := &clients.BalanceSummary{PointsBalance: 100}
+			if !tt.expiresAt.IsZero() {
+				summary.ExpiringPoints = 40
+				summary.PointsExpireAt = uint64(tt.expiresAt.Unix())
+			}
+			due := dueAlerts(settings, summary, testNow)
+			assert.Equal(t, tt.expected, len(due) == 1)
+		})
+	}
+}
+
+func TestDueAlerts_ExpiryTurnedOff(t *testing.T) {
+	summary := &clients.BalanceSummary{PointsBalance: 100, ExpiringPoints: 40, PointsExpireAt: uint64(testNow.AddDate(0, 0, 3).Unix())}
+
+	// Assertions - points credited while expiry was on still expire
+	due := dueAlerts(models.OrgSettings{}, summary, testNow)
+	assert.Len(t, due, 1)
+	assert.Equal(t, uint64(40), due[0].Payload["points"])
+}
+
+func TestDueAlerts_NothingConfigured(t *testing.T) {
+	summary := &clients.BalanceSummary{PointsBalance: 100, StampsToNextCard: 1}
+
+	// Assertions - without expiring points or a stamp card, nothing is due
+	assert.Empty(t, dueAlerts(models.OrgSettings{}, summary, testNow))
+}
+
+// Test ScanAll
+func TestScanAll_ContinuesPastFailingCustomer(t *testing.T) {
+	alerter, mockCustomers, mockBalances, mockWriter := setupTestAlerter()
+	alerter.config.BatchSize = 2
+	ctx := context.Background()
+
+	failing := testCustomer()
+	failing.CustomerID = "failing_customer"
+	nearlyDone := testCustomer()
+	org := testOrg()
+	org.Settings.PointsExpiryDays = 0
+
+	// Setup expectations - customers from one org share a single lookup
+	mockCustomers.On("GetBalanceAlertCustomers", ctx, 2, 0).Return([]*models.Customer{failing, nearlyDone}, nil)
+	mockCustomers.On("GetBalanceAlertCustomers", ctx, 2, 2).Return([]*models.Customer{}, nil)
+	mockCustomers.On("GetOrganization", ctx, "test_org").Return(org, nil).Once()
+	mockBalances.On("GetBalanceSummary", "test_org", "failing_customer", 10).Return(nil, assert.AnError)
+	mockBalances.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(&clients.BalanceSummary{StampsBalance: 9, StampsToNextCard: 1}, nil)
+	mockWriter.On("WriteMessages", ctx, mock.Anything).Return(nil).Once()
+	mockCustomers.On("UpdateCustomer", ctx, "test_customer", mock.Anything).Return(nil)
+
+	// Test
+	err := alerter.ScanAll(ctx)
+
+	// Assertions
+	assert.NoError(t, err)
+	mockCustomers.AssertExpectations(t)
+	mockBalances.AssertExpectations(t)
+	mockWriter.AssertExpectations(t)
+}
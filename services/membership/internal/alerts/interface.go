@@ -0,0 +1,27 @@
+package alerts
+
+import (
+	"context"
+
+	"github.com/loyalty/membership/internal/clients"
+	"github.com/loyalty/membership/internal/models"
+	"github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CustomerSource defines the membership lookups the alerter depends on
+type CustomerSource interface {
+	GetBalanceAlertCustomers(ctx context.Context, limit, offset int) ([]*models.Customer, error)
+	GetOrganization(ctx context.Context, orgID string) (*models.Organization, error)
+	UpdateCustomer(ctx context.Context, customerID string, updates bson.M) error
+}
+
+// BalanceSource defines the ledger lookup the alerter depends on
+type BalanceSource interface {
+	GetBalanceSummary(orgID, customerID string, maxStampsPerCard int) (*clients.BalanceSummary, error)
+}
+
+// MessageWriter publishes messages to Kafka. *kafka.Writer satisfies it.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
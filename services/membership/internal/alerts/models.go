@@ -0,0 +1,34 @@
+package alerts
+
+import "time"
+
+// Alert kinds. A kind's notification is published with event type
+// notification.<kind>.
+const (
+	KindPointsExpiring   = "points_expiring"
+	KindStampCardOneAway = "stamp_card_one_away"
+)
+
+// DefaultLeadDays is how far ahead points expiry is announced when an org has
+// not set BalanceAlertLeadDays
+const DefaultLeadDays = 7
+
+// Alert is a notice due to a customer. Key identifies the condition it
+// announces, such as the expiry date, so the same condition is not
+// announced twice.
+type Alert struct {
+	Kind    string
+	Key     string
+	Payload map[string]interface{}
+}
+
+// NotificationEvent is published on the {org}.notification.{kind} topic for the brand's
+// messaging to deliver. It has the same shape as the stream service's events.
+type NotificationEvent struct {
+	EventID    string                 `json:"event_id"`
+	EventType  string                 `json:"event_type"`
+	OrgID      string                 `json:"org_id"`
+	CustomerID string                 `json:"customer_id"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Payload    map[string]interface{} `json:"payload"`
+}
@@ -0,0 +1,108 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type AnalyticsClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type RFMScore struct {
+	OrgID             string    `json:"org_id"`
+	CustomerID        string    `json:"customer_id"`
+	RecencyScore      int       `json:"recency_score"`
+	FrequencyScore    int       `json:"frequency_score"`
+	MonetaryScore     int       `json:"monetary_score"`
+	RFMSegment        string    `json:"rfm_segment"`
+	LastTransaction   time.Time `json:"last_transaction"`
+	TotalTransactions int       `json:"total_transactions"`
+	TotalSpent        float64   `json:"total_spent"`
+	AvgOrderValue     float64   `json:"avg_order_value"`
+	CalculatedAt      time.Time `json:"calculated_at"`
+}
+
+type CustomerTier struct {
+	OrgID            string    `json:"org_id"`
+	CustomerID       string    `json:"customer_id"`
+	CurrentTier      string    `json:"current_tier"`
+	PreviousTier     string    `json:"previous_tier"`
+	TierSince        time.Time `json:"tier_since"`
+	NextTier         string    `json:"next_tier"`
+	ProgressToNext   float64   `json:"progress_to_next"`
+	PointsMultiplier float64   `json:"points_multiplier"`
+	Benefits         []string  `json:"benefits"`
+}
+
+type TierUpgrade struct {
+	FromTier     string    `json:"from_tier"`
+	ToTier       string    `json:"to_tier"`
+	TriggeredBy  string    `json:"triggered_by"`
+	TriggerValue float64   `json:"trigger_value"`
+	UpgradedAt   time.Time `json:"upgraded_at"`
+}
+
+type tierUpgradesResponse struct {
+	Upgrades []TierUpgrade `json:"upgrades"`
+}
+
+func NewAnalyticsClient(baseURL string) *AnalyticsClient {
+	return &AnalyticsClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c *AnalyticsClient) GetRFMScore(orgID, customerID string) (*RFMScore, error) {
+	var score RFMScore
+	if err := c.get("/api/v1/rfm/"+url.PathEscape(customerID), url.Values{"org_id": {orgID}}, &score); err != nil {
+		return nil, fmt.Errorf("failed to get RFM score: %w", err)
+	}
+	return &score, nil
+}
+
+func (c *AnalyticsClient) GetCustomerTier(orgID, customerID string) (*CustomerTier, error) {
+	var tier CustomerTier
+	if err := c.get("/api/v1/tiers/"+url.PathEscape(customerID), url.Values{"org_id": {orgID}}, &tier); err != nil {
+		return nil, fmt.Errorf("failed to get customer tier: %w", err)
+	}
+	return &tier, nil
+}
+
+func (c *AnalyticsClient) GetTierUpgrades(orgID, customerID string) ([]TierUpgrade, error) {
+	var response tierUpgradesResponse
+	query := url.Values{"org_id": {orgID}, "customer_id": {customerID}}
+	if err := c.get("/api/v1/tiers/upgrades", query, &response); err != nil {
+		return nil, fmt.Errorf("failed to get tier upgrades: %w", err)
+	}
+	return response.Upgrades, nil
+}
+
+func (c *AnalyticsClient) get(path string, query url.Values, out interface{}) error {
+	resp, err := c.httpClient.Get(c.baseURL + path + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("analytics service returned status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
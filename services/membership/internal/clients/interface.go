@@ -0,0 +1,19 @@
+package clients
+
+import "errors"
+
+// ErrNotFound is returned when a downstream service has no record for the customer
+var ErrNotFound = errors.New("not found")
+
+// LedgerClientInterface defines the interface for ledger client operations
+type LedgerClientInterface interface {
+	GetTransfers(orgID, customerID string) ([]Transfer, error)
+	GetBalanceSummary(orgID, customerID string, maxStampsPerCard int) (*BalanceSummary, error)
+}
+
+// AnalyticsClientInterface defines the interface for analytics client operations
+type AnalyticsClientInterface interface {
+	GetRFMScore(orgID, customerID string) (*RFMScore, error)
+	GetCustomerTier(orgID, customerID string) (*CustomerTier, error)
+	GetTierUpgrades(orgID, customerID string) ([]TierUpgrade, error)
+}
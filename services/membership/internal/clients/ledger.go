@@ -0,0 +1,127 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type LedgerClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type Transfer struct {
+	ID              string `json:"id"`
+	DebitAccountID  string `json:"debit_account_id"`
+	CreditAccountID string `json:"credit_account_id"`
+	Amount          uint64 `json:"amount"`
+	Code            uint16 `json:"code"`
+	Reference       string `json:"reference"`
+	Timestamp       uint64 `json:"timestamp"`
+}
+
+// BalanceSummary is a customer's balances with their stamp card progress
+type BalanceSummary struct {
+	OrgID               string `json:"org_id"`
+	CustomerID          string `json:"customer_id"`
+	PointsBalance       uint64 `json:"points_balance"`
+	StampsBalance       uint64 `json:"stamps_balance"`
+	MaxStampsPerCard    uint64 `json:"max_stamps_per_card"`
+	CompletedCards      uint64 `json:"completed_cards"`
+	StampsOnCurrentCard uint64 `json:"stamps_on_current_card"`
+	StampsToNextCard    uint64 `json:"stamps_to_next_card"`
+	// ExpiringPoints are the points due to expire next, at PointsExpireAt
+	// (unix seconds); both are zero when none are due
+	ExpiringPoints      uint64 `json:"expiring_points,omitempty"`
+	PointsExpireAt      uint64 `json:"points_expire_at,omitempty"`
+}
+
+type transfersResponse struct {
+	Transfers []Transfer `json:"transfers"`
+}
+
+func NewLedgerClient(baseURL string) *LedgerClient {
+	return &LedgerClient{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// transfersPageSize is how many transfers GetTransfers asks the ledger for at
+// a time
+const transfersPageSize = 200
+
+// GetTransfers returns all of the customer's transfers, paging through the
+// ledger until they run out
+func (c *LedgerClient) GetTransfers(orgID, customerID string) ([]Transfer, error) {
+	var transfers []Transfer
+	for offset := 0; ; offset += transfersPageSize {
+		page, err := c.getTransfersPage(orgID, customerID, offset)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, page...)
+		if len(page) < transfersPageSize {
+			return transfers, nil
+		}
+	}
+}
+
+func (c *LedgerClient) getTransfersPage(orgID, customerID string, offset int) ([]Transfer, error) {
+	query := url.Values{}
+	query.Set("org_id", orgID)
+	query.Set("customer_id", customerID)
+	query.Set("limit", strconv.Itoa(transfersPageSize))
+	query.Set("offset", strconv.Itoa(offset))
+
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/transfers?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var response transfersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode transfers: %w", err)
+	}
+
+	return response.Transfers, nil
+}
+
+// GetBalanceSummary returns the customer's balances, with stamp card progress
+// worked out for cards of maxStampsPerCard stamps
+func (c *LedgerClient) GetBalanceSummary(orgID, customerID string, maxStampsPerCard int) (*BalanceSummary, error) {
+	query := url.Values{}
+	query.Set("org_id", orgID)
+	query.Set("customer_id", customerID)
+	if maxStampsPerCard > 0 {
+		query.Set("max_stamps_per_card", strconv.Itoa(maxStampsPerCard))
+	}
+
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/balance/summary?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance summary: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var summary BalanceSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return nil, fmt.Errorf("failed to decode balance summary: %w", err)
+	}
+
+	return &summary, nil
+}
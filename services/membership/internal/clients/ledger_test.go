@@ -0,0 +1,58 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test GetTransfers
+func TestLedgerClient_GetTransfersPagesUntilExhausted(t *testing.T) {
+	// A ledger holding 450 transfers, which pages them like ListTransfers
+	const total = 450
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/api/v1/transfers", r.URL.Path)
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		assert.NoError(t, err)
+		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+		assert.NoError(t, err)
+
+		transfers := []Transfer{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			transfers = append(transfers, Transfer{ID: fmt.Sprintf("transfer_%d", i), Amount: 10})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"transfers": transfers})
+	}))
+	t.Cleanup(server.Close)
+	client := NewLedgerClient(server.URL)
+
+	transfers, err := client.GetTransfers("test_org", "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, transfers, total)
+	assert.Equal(t, "transfer_0", transfers[0].ID)
+	assert.Equal(t, "transfer_449", transfers[total-1].ID)
+	assert.Equal(t, 3, requests)
+}
+
+func TestLedgerClient_GetTransfersError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	client := NewLedgerClient(server.URL)
+
+	transfers, err := client.GetTransfers("test_org", "test_customer")
+
+	// Assertions
+	assert.EqualError(t, err, "ledger service returned status 503")
+	assert.Nil(t, transfers)
+}
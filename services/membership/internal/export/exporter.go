@@ -0,0 +1,123 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/loyalty/membership/internal/clients"
+	"github.com/loyalty/membership/internal/models"
+)
+
+// CustomerExport is the data-portability bundle for a single customer
+type CustomerExport struct {
+	ExportedAt  time.Time               `json:"exported_at"`
+	Profile     *models.Customer        `json:"profile"`
+	Balance     *clients.BalanceSummary `json:"balance"`
+	Transfers   []clients.Transfer      `json:"transfers"`
+	RFMScore    *clients.RFMScore       `json:"rfm_score"`
+	Tier        *clients.CustomerTier   `json:"tier"`
+	TierHistory []clients.TierUpgrade   `json:"tier_history"`
+	// Warnings name the parts of the bundle left out because the service
+	// holding them could not be reached
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// SourceError reports a downstream source that could not be read
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%s unavailable: %v", e.Source, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+type Exporter struct {
+	profiles  ProfileSource
+	ledger    clients.LedgerClientInterface
+	analytics clients.AnalyticsClientInterface
+}
+
+func NewExporter(profiles ProfileSource, ledger clients.LedgerClientInterface, analytics clients.AnalyticsClientInterface) *Exporter {
+	return &Exporter{
+		profiles:  profiles,
+		ledger:    ledger,
+		analytics: analytics,
+	}
+}
+
+// ExportCustomer gathers everything held about a customer across services.
+// Records a service has never created (e.g. no RFM score yet) are left empty.
+// A service that cannot be reached does not fail the export: the parts it
+// holds are left empty too and a warning says which.
+func (e *Exporter) ExportCustomer(ctx context.Context, customerID string) (*CustomerExport, error) {
+	profile, err := e.profiles.GetCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &CustomerExport{
+		ExportedAt:  time.Now(),
+		Profile:     profile,
+		Transfers:   []clients.Transfer{},
+		TierHistory: []clients.TierUpgrade{},
+	}
+
+	// Without the org's card size the summary has balances but no card
+	// progress
+	maxStampsPerCard := 0
+	org, err := e.profiles.GetOrganization(ctx, profile.OrgID)
+	if err != nil {
+		bundle.warn("stamp card progress", "membership", err)
+	} else {
+		maxStampsPerCard = org.Settings.MaxStampsPerCard
+	}
+
+	balance, err := e.ledger.GetBalanceSummary(profile.OrgID, customerID, maxStampsPerCard)
+	if err != nil {
+		bundle.warn("balance", "ledger", err)
+	}
+	bundle.Balance = balance
+
+	transfers, err := e.ledger.GetTransfers(profile.OrgID, customerID)
+	if err != nil {
+		bundle.warn("transfers", "ledger", err)
+	}
+	if transfers != nil {
+		bundle.Transfers = transfers
+	}
+
+	score, err := e.analytics.GetRFMScore(profile.OrgID, customerID)
+	if err != nil && !errors.Is(err, clients.ErrNotFound) {
+		bundle.warn("rfm_score", "analytics", err)
+	}
+	bundle.RFMScore = score
+
+	tier, err := e.analytics.GetCustomerTier(profile.OrgID, customerID)
+	if err != nil && !errors.Is(err, clients.ErrNotFound) {
+		bundle.warn("tier", "analytics", err)
+	}
+	bundle.Tier = tier
+
+	upgrades, err := e.analytics.GetTierUpgrades(profile.OrgID, customerID)
+	if err != nil && !errors.Is(err, clients.ErrNotFound) {
+		bundle.warn("tier_history", "analytics", err)
+	}
+	if upgrades != nil {
+		bundle.TierHistory = upgrades
+	}
+
+	return bundle, nil
+}
+
+// warn records that field was left out of the bundle because source failed
+func (b *CustomerExport) warn(field, source string, err error) {
+	sourceErr := &SourceError{Source: source, Err: err}
+	b.Warnings = append(b.Warnings, fmt.Sprintf("%s omitted: %v", field, sourceErr))
+}
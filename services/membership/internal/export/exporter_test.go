@@ -0,0 +1,283 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/loyalty/membership/internal/clients"
+	"github.com/loyalty/membership/internal/models"
+	"github.com/loyalty/membership/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockProfileSource is a mock implementation of the profile source
+type MockProfileSource struct {
+	mock.Mock
+}
+
+func (m *MockProfileSource) GetCustomer(ctx context.Context, customerID string) (*models.Customer, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Customer), args.Error(1)
+}
+
+func (m *MockProfileSource) GetOrganization(ctx context.Context, orgID string) (*models.Organization, error) {
+	args := m.Called(ctx, orgID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Organization), args.Error(1)
+}
+
+// MockLedgerClient is a mock implementation of the ledger client
+type MockLedgerClient struct {
+	mock.Mock
+}
+
+func (m *MockLedgerClient) GetTransfers(orgID, customerID string) ([]clients.Transfer, error) {
+	args := m.Called(orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]clients.Transfer), args.Error(1)
+}
+
+func (m *MockLedgerClient) GetBalanceSummary(orgID, customerID string, maxStampsPerCard int) (*clients.BalanceSummary, error) {
+	args := m.Called(orgID, customerID, maxStampsPerCard)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.BalanceSummary), args.Error(1)
+}
+
+// MockAnalyticsClient is a mock implementation of the analytics client
+type MockAnalyticsClient struct {
+	mock.Mock
+}
+
+func (m *MockAnalyticsClient) GetRFMScore(orgID, customerID string) (*clients.RFMScore, error) {
+	args := m.Called(orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.RFMScore), args.Error(1)
+}
+
+func (m *MockAnalyticsClient) GetCustomerTier(orgID, customerID string) (*clients.CustomerTier, error) {
+	args := m.Called(orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.CustomerTier), args.Error(1)
+}
+
+func (m *MockAnalyticsClient) GetTierUpgrades(orgID, customerID string) ([]clients.TierUpgrade, error) {
+	args := m.Called(orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]clients.TierUpgrade), args.Error(1)
+}
+
+// Test setup helper
+func setupTestExporter() (*Exporter, *MockProfileSource, *MockLedgerClient, *MockAnalyticsClient) {
+	mockProfiles := &MockProfileSource{}
+	mockLedger := &MockLedgerClient{}
+	mockAnalytics := &MockAnalyticsClient{}
+	exporter := NewExporter(mockProfiles, mockLedger, mockAnalytics)
+	return exporter, mockProfiles, mockLedger, mockAnalytics
+}
+
+func testCustomer() *models.Customer {
+	return &models.Customer{
+		CustomerID: "test_customer",
+		OrgID:      "test_org",
+		Email:      "test@example.com",
+		FirstName:  "Test",
+		LastName:   "Customer",
+		Status:     "active",
+	}
+}
+
+func testOrganization() *models.Organization {
+	return &models.Organization{
+		OrgID:    "test_org",
+		Settings: models.OrgSettings{MaxStampsPerCard: 10},
+	}
+}
+
+// Test ExportCustomer
+func TestExportCustomer_Success(t *testing.T) {
+	exporter, mockProfiles, mockLedger, mockAnalytics := setupTestExporter()
+	ctx := context.Background()
+
+	// Test data
+	transfers := []clients.Transfer{
+		{ID: "transfer_1", Amount: 50, Code: 1, Reference: "pos_transaction_txn_1"},
+		{ID: "transfer_2", Amount: 1, Code: 2, Reference: "pos_transaction_txn_1"},
+	}
+	score := &clients.RFMScore{OrgID: "test_org", CustomerID: "test_customer", RFMSegment: "Champions"}
+	tier := &clients.CustomerTier{OrgID: "test_org", CustomerID: "test_customer", CurrentTier: "Gold"}
+	upgrades := []clients.TierUpgrade{
+		{FromTier: "Bronze", ToTier: "Silver", UpgradedAt: time.Now().AddDate(0, -2, 0)},
+		{FromTier: "Silver", ToTier: "Gold", UpgradedAt: time.Now().AddDate(0, -1, 0)},
+	}
+
+	balance := &clients.BalanceSummary{OrgID: "test_org", CustomerID: "test_customer", PointsBalance: 49, StampsBalance: 1}
+
+	// Setup expectations
+	mockProfiles.On("GetCustomer", ctx, "test_customer").Return(testCustomer(), nil)
+	mockProfiles.On("GetOrganization", ctx, "test_org").Return(testOrganization(), nil)
+	mockLedger.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(balance, nil)
+	mockLedger.On("GetTransfers", "test_org", "test_customer").Return(transfers, nil)
+	mockAnalytics.On("GetRFMScore", "test_org", "test_customer").Return(score, nil)
+	mockAnalytics.On("GetCustomerTier", "test_org", "test_customer").Return(tier, nil)
+	mockAnalytics.On("GetTierUpgrades", "test_org", "test_customer").Return(upgrades, nil)
+
+	// Test
+	bundle, err := exporter.ExportCustomer(ctx, "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "test@example.com", bundle.Profile.Email)
+	assert.Equal(t, uint64(49), bundle.Balance.PointsBalance)
+	assert.Len(t, bundle.Transfers, 2)
+	assert.Equal(t, "Champions", bundle.RFMScore.RFMSegment)
+	assert.Equal(t, "Gold", bundle.Tier.CurrentTier)
+	assert.Len(t, bundle.TierHistory, 2)
+	assert.WithinDuration(t, time.Now(), bundle.ExportedAt, time.Second)
+	assert.Empty(t, bundle.Warnings)
+
+	mockProfiles.AssertExpectations(t)
+	mockLedger.AssertExpectations(t)
+	mockAnalytics.AssertExpectations(t)
+}
+
+func TestExportCustomer_NoAnalyticsRecords(t *testing.T) {
+	exporter, mockProfiles, mockLedger, mockAnalytics := setupTestExporter()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockProfiles.On("GetCustomer", ctx, "test_customer").Return(testCustomer(), nil)
+	mockProfiles.On("GetOrganization", ctx, "test_org").Return(testOrganization(), nil)
+	mockLedger.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(&clients.BalanceSummary{}, nil)
+	mockLedger.On("GetTransfers", "test_org", "test_customer").Return(nil, nil)
+	mockAnalytics.On("GetRFMScore", "test_org", "test_customer").Return(nil, clients.ErrNotFound)
+	mockAnalytics.On("GetCustomerTier", "test_org", "test_customer").Return(nil, clients.ErrNotFound)
+	mockAnalytics.On("GetTierUpgrades", "test_org", "test_customer").Return(nil, nil)
+
+	// Test
+	bundle, err := exporter.ExportCustomer(ctx, "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.NotNil(t, bundle.Profile)
+	assert.Empty(t, bundle.Transfers)
+	assert.Nil(t, bundle.RFMScore)
+	assert.Nil(t, bundle.Tier)
+	assert.Empty(t, bundle.TierHistory)
+	assert.Empty(t, bundle.Warnings, "missing records are not failures")
+}
+
+func TestExportCustomer_CustomerNotFound(t *testing.T) {
+	exporter, mockProfiles, mockLedger, _ := setupTestExporter()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockProfiles.On("GetCustomer", ctx, "missing_customer").Return(nil, repository.ErrCustomerNotFound)
+
+	// Test
+	bundle, err := exporter.ExportCustomer(ctx, "missing_customer")
+
+	// Assertions
+	assert.Error(t, err)
+	assert.Nil(t, bundle)
+	mockLedger.AssertNotCalled(t, "GetTransfers")
+}
+
+func TestExportCustomer_AnalyticsUnavailable(t *testing.T) {
+	exporter, mockProfiles, mockLedger, mockAnalytics := setupTestExporter()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockProfiles.On("GetCustomer", ctx, "test_customer").Return(testCustomer(), nil)
+	mockProfiles.On("GetOrganization", ctx, "test_org").Return(testOrganization(), nil)
+	mockLedger.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(&clients.BalanceSummary{PointsBalance: 120}, nil)
+	mockLedger.On("GetTransfers", "test_org", "test_customer").Return([]clients.Transfer{{ID: "transfer_1", Amount: 120}}, nil)
+	mockAnalytics.On("GetRFMScore", "test_org", "test_customer").Return(nil, errors.New("connection refused"))
+	mockAnalytics.On("GetCustomerTier", "test_org", "test_customer").Return(nil, errors.New("connection refused"))
+	mockAnalytics.On("GetTierUpgrades", "test_org", "test_customer").Return(nil, errors.New("connection refused"))
+
+	// Test
+	bundle, err := exporter.ExportCustomer(ctx, "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "test@example.com", bundle.Profile.Email)
+	assert.Equal(t, uint64(120), bundle.Balance.PointsBalance)
+	assert.Len(t, bundle.Transfers, 1)
+	assert.Nil(t, bundle.RFMScore)
+	assert.Nil(t, bundle.Tier)
+	assert.Empty(t, bundle.TierHistory)
+	assert.Equal(t, []string{
+		"rfm_score omitted: analytics unavailable: connection refused",
+		"tier omitted: analytics unavailable: connection refused",
+		"tier_history omitted: analytics unavailable: connection refused",
+	}, bundle.Warnings)
+}
+
+func TestExportCustomer_LedgerUnavailable(t *testing.T) {
+	exporter, mockProfiles, mockLedger, mockAnalytics := setupTestExporter()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockProfiles.On("GetCustomer", ctx, "test_customer").Return(testCustomer(), nil)
+	mockProfiles.On("GetOrganization", ctx, "test_org").Return(testOrganization(), nil)
+	mockLedger.On("GetBalanceSummary", "test_org", "test_customer", 10).Return(nil, errors.New("ledger service returned status 503"))
+	mockLedger.On("GetTransfers", "test_org", "test_customer").Return(nil, errors.New("ledger service returned status 503"))
+	mockAnalytics.On("GetRFMScore", "test_org", "test_customer").Return(&clients.RFMScore{RFMSegment: "Loyal"}, nil)
+	mockAnalytics.On("GetCustomerTier", "test_org", "test_customer").Return(&clients.CustomerTier{CurrentTier: "Silver"}, nil)
+	mockAnalytics.On("GetTierUpgrades", "test_org", "test_customer").Return([]clients.TierUpgrade{}, nil)
+
+	// Test
+	bundle, err := exporter.ExportCustomer(ctx, "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Nil(t, bundle.Balance)
+	assert.Empty(t, bundle.Transfers)
+	assert.Equal(t, "Loyal", bundle.RFMScore.RFMSegment)
+	assert.Equal(t, "Silver", bundle.Tier.CurrentTier)
+	assert.Len(t, bundle.Warnings, 2)
+	assert.Contains(t, bundle.Warnings[0], "balance omitted: ledger unavailable")
+	assert.Contains(t, bundle.Warnings[1], "transfers omitted: ledger unavailable")
+}
+
+func TestExportCustomer_OrganizationUnavailable(t *testing.T) {
+	exporter, mockProfiles, mockLedger, mockAnalytics := setupTestExporter()
+	ctx := context.Background()
+
+	// Setup expectations
+	mockProfiles.On("GetCustomer", ctx, "test_customer").Return(testCustomer(), nil)
+	mockProfiles.On("GetOrganization", ctx, "test_org").Return(nil, errors.New("organization not found"))
+	mockLedger.On("GetBalanceSummary", "test_org", "test_customer", 0).Return(&clients.BalanceSummary{PointsBalance: 120, StampsBalance: 3}, nil)
+	mockLedger.On("GetTransfers", "test_org", "test_customer").Return(nil, nil)
+	mockAnalytics.On("GetRFMScore", "test_org", "test_customer").Return(nil, clients.ErrNotFound)
+	mockAnalytics.On("GetCustomerTier", "test_org", "test_customer").Return(nil, clients.ErrNotFound)
+	mockAnalytics.On("GetTierUpgrades", "test_org", "test_customer").Return(nil, nil)
+
+	// Test
+	bundle, err := exporter.ExportCustomer(ctx, "test_customer")
+
+	// Assertions - balances are still exported, without card progress
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), bundle.Balance.StampsBalance)
+	assert.Len(t, bundle.Warnings, 1)
+	assert.Contains(t, bundle.Warnings[0], "stamp card progress omitted: membership unavailable")
+	mockLedger.AssertExpectations(t)
+}
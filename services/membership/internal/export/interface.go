@@ -0,0 +1,19 @@
+package export
+
+import (
+	"context"
+
+	"github.com/loyalty/membership/internal/models"
+)
+
+// ProfileSource defines the customer profile and organization lookups the
+// exporter depends on
+type ProfileSource interface {
+	GetCustomer(ctx context.Context, customerID string) (*models.Customer, error)
+	GetOrganization(ctx context.Context, orgID string) (*models.Organization, error)
+}
+
+// CustomerExporterInterface defines the interface for customer data exports
+type CustomerExporterInterface interface {
+	ExportCustomer(ctx context.Context, customerID string) (*CustomerExport, error)
+}
@@ -1,21 +1,51 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/loyalty/membership/internal/accrual"
+	"github.com/loyalty/membership/internal/export"
 	"github.com/loyalty/membership/internal/models"
 	"github.com/loyalty/membership/internal/repository"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// HandlerConfig holds the tunable behaviour of the membership handler
+type HandlerConfig struct {
+	// ConsentPreferences are the boolean preferences whose changes are kept
+	// in each customer's consent history
+	ConsentPreferences []string
+}
+
+func DefaultHandlerConfig() HandlerConfig {
+	return HandlerConfig{
+		ConsentPreferences: models.DefaultConsentPreferences,
+	}
+}
+
 type MembershipHandler struct {
-	repo repository.MongoRepoInterface
+	repo     repository.MongoRepoInterface
+	exporter export.CustomerExporterInterface
+	config   HandlerConfig
 }
 
-func NewMembershipHandler(repo repository.MongoRepoInterface) *MembershipHandler {
-	return &MembershipHandler{repo: repo}
+func NewMembershipHandler(repo repository.MongoRepoInterface, exporter export.CustomerExporterInterface) *MembershipHandler {
+	return NewMembershipHandlerWithConfig(repo, exporter, DefaultHandlerConfig())
+}
+
+// NewMembershipHandlerWithConfig creates a handler with explicit settings
+func NewMembershipHandlerWithConfig(repo repository.MongoRepoInterface, exporter export.CustomerExporterInterface, config HandlerConfig) *MembershipHandler {
+	return &MembershipHandler{repo: repo, exporter: exporter, config: config}
 }
 
 func (h *MembershipHandler) CreateCustomer(c *gin.Context) {
@@ -26,6 +56,10 @@ func (h *MembershipHandler) CreateCustomer(c *gin.Context) {
 	}
 
 	customer, err := h.repo.CreateCustomer(c.Request.Context(), &req)
+	if errors.Is(err, repository.ErrCustomerEmailExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": repository.ErrCustomerEmailExists.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -50,6 +84,26 @@ func (h *MembershipHandler) GetCustomer(c *gin.Context) {
 	c.JSON(http.StatusOK, customer)
 }
 
+// countResult is a listing's total, counted alongside its page
+type countResult struct {
+	total int64
+	err   error
+}
+
+// countConcurrently runs count in the background so a page and its total are
+// queried at the same time. The channel is buffered, so an abandoned count
+// does not leak its goroutine.
+func countConcurrently(count func() (int64, error)) <-chan countResult {
+	results := make(chan countResult, 1)
+	go func() {
+		total, err := count()
+		results <- countResult{total: total, err: err}
+	}()
+	return results
+}
+
+// GetCustomersByOrg returns a page of an org's customers. count is the size of
+// the page and total is every customer the listing matches.
 func (h *MembershipHandler) GetCustomersByOrg(c *gin.Context) {
 	orgID := c.Query("org_id")
 	if orgID == "" {
@@ -72,20 +126,194 @@ func (h *MembershipHandler) GetCustomersByOrg(c *gin.Context) {
 		return
 	}
 
-	customers, err := h.repo.GetCustomersByOrg(c.Request.Context(), orgID, limit, offset)
+	filter, targeted, err := parseTargetingFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	createdFrom, createdTo, ranged, err := parseCreatedRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tier := c.Query("tier")
+	if ranged && (targeted || tier != "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "created_from and created_to cannot be combined with tier or targeting filters"})
+		return
+	}
+
+	var totals <-chan countResult
+	switch {
+	case targeted:
+		totals = countConcurrently(func() (int64, error) {
+			return h.repo.CountCustomersByPreferences(c.Request.Context(), orgID, filter)
+		})
+	case tier != "":
+		totals = countConcurrently(func() (int64, error) {
+			return h.repo.CountCustomersByTier(c.Request.Context(), orgID, tier)
+		})
+	case ranged:
+		totals = countConcurrently(func() (int64, error) {
+			return h.repo.CountCustomersCreatedBetween(c.Request.Context(), orgID, createdFrom, createdTo)
+		})
+	default:
+		totals = countConcurrently(func() (int64, error) {
+			return h.repo.CountCustomersByOrg(c.Request.Context(), orgID)
+		})
+	}
+
+	var customers []*models.Customer
+	switch {
+	case targeted:
+		customers, err = h.repo.GetCustomersByPreferences(c.Request.Context(), orgID, filter, limit, offset)
+	case tier != "":
+		customers, err = h.repo.GetCustomersByTier(c.Request.Context(), orgID, tier, limit, offset)
+	case ranged:
+		customers, err = h.repo.GetCustomersCreatedBetween(c.Request.Context(), orgID, createdFrom, createdTo, limit, offset)
+	default:
+		customers, err = h.repo.GetCustomersByOrg(c.Request.Context(), orgID, limit, offset)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	if customers == nil {
+		customers = []*models.Customer{}
+	}
+
+	response := gin.H{
 		"customers": customers,
 		"count":     len(customers),
 		"limit":     limit,
 		"offset":    offset,
+	}
+	if totals != nil {
+		result := <-totals
+		if result.err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.err.Error()})
+			return
+		}
+		response["total"] = result.total
+	}
+	if tier != "" {
+		response["tier"] = tier
+	}
+	if targeted {
+		response["filter"] = filter
+	}
+	if !createdFrom.IsZero() {
+		response["created_from"] = createdFrom
+	}
+	if !createdTo.IsZero() {
+		response["created_to"] = createdTo
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SearchCustomers finds an org's customers by email and/or phone. Both must
+// match when both are given.
+func (h *MembershipHandler) SearchCustomers(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+
+	email := strings.TrimSpace(c.Query("email"))
+	phone := strings.TrimSpace(c.Query("phone"))
+	if email == "" && phone == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email or phone is required"})
+		return
+	}
+
+	customers, err := h.repo.SearchCustomers(c.Request.Context(), orgID, email, phone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if customers == nil {
+		customers = []*models.Customer{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customers": customers,
+		"count":     len(customers),
 	})
 }
 
+// parseTargetingFilter reads the preference filters of a customer listing.
+// targeted is false when none were given, in which case the listing covers
+// every customer as before. tier joins the filter when it is combined with one.
+func parseTargetingFilter(c *gin.Context) (filter models.TargetingFilter, targeted bool, err error) {
+	if filter.EmailOptIn, err = parseOptIn(c, "email_opt_in"); err != nil {
+		return filter, false, err
+	}
+	if filter.SMSOptIn, err = parseOptIn(c, "sms_opt_in"); err != nil {
+		return filter, false, err
+	}
+	filter.Category = c.Query("category")
+	filter.Language = c.Query("language")
+	filter.Tag = normalizeTag(c.Query("tag"))
+
+	targeted = filter.EmailOptIn != nil || filter.SMSOptIn != nil || filter.Category != "" || filter.Language != "" || filter.Tag != ""
+	if targeted {
+		filter.Tier = c.Query("tier")
+	}
+
+	return filter, targeted, nil
+}
+
+// parseCreatedRange reads the created_from and created_to query parameters.
+// Either may be left out to leave that side of the range open; created_to is
+// exclusive, so created_from=2024-06-01&created_to=2024-07-01 is June.
+func parseCreatedRange(c *gin.Context) (from, to time.Time, ranged bool, err error) {
+	if from, err = parseCreatedAt(c, "created_from"); err != nil {
+		return from, to, false, err
+	}
+	if to, err = parseCreatedAt(c, "created_to"); err != nil {
+		return from, to, false, err
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return from, to, false, errors.New("created_to must be after created_from")
+	}
+	return from, to, !from.IsZero() || !to.IsZero(), nil
+}
+
+// parseCreatedAt reads an optional query parameter given as an RFC 3339
+// timestamp or a date, which means midnight UTC
+func parseCreatedAt(c *gin.Context, param string) (time.Time, error) {
+	value := c.Query(param)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s parameter: use YYYY-MM-DD or RFC 3339", param)
+	}
+	return timestamp, nil
+}
+
+// parseOptIn reads an optional boolean query parameter
+func parseOptIn(c *gin.Context, param string) (*bool, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+	optIn, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", param)
+	}
+	return &optIn, nil
+}
+
 func (h *MembershipHandler) UpdateCustomer(c *gin.Context) {
 	customerID := c.Param("id")
 	if customerID == "" {
@@ -99,14 +327,161 @@ func (h *MembershipHandler) UpdateCustomer(c *gin.Context) {
 		return
 	}
 
+	var changes []models.ConsentChange
+	if h.updatesConsent(updates) {
+		customer, err := h.repo.GetCustomer(c.Request.Context(), customerID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		changes, err = consentChanges(customer, updates, h.config.ConsentPreferences, c.GetHeader("X-Changed-By"), time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	if err := h.repo.UpdateCustomer(c.Request.Context(), customerID, bson.M(updates)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if len(changes) > 0 {
+		if err := h.repo.AppendConsentHistory(c.Request.Context(), changes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "customer updated successfully"})
 }
 
+// updatesConsent reports whether updates may change a tracked consent
+// preference, either by replacing preferences as a whole or by setting one
+// of them directly
+func (h *MembershipHandler) updatesConsent(updates map[string]interface{}) bool {
+	if len(h.config.ConsentPreferences) == 0 {
+		return false
+	}
+	for key := range updates {
+		if key == "preferences" || strings.HasPrefix(key, "preferences.") {
+			return true
+		}
+	}
+	return false
+}
+
+// consentChanges lists the tracked preferences that updates changes on
+// customer. Replacing preferences as a whole turns off any tracked
+// preference it leaves out, as that is what gets stored.
+func consentChanges(customer *models.Customer, updates map[string]interface{}, tracked []string, changedBy string, now time.Time) ([]models.ConsentChange, error) {
+	current, err := bson.Marshal(customer.Preferences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current preferences: %w", err)
+	}
+	replaced, replacing := updates["preferences"].(map[string]interface{})
+
+	var changes []models.ConsentChange
+	for _, preference := range tracked {
+		value, ok := updates["preferences."+preference]
+		if !ok && replacing {
+			if value, ok = replaced[preference]; !ok {
+				value, ok = false, true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		newValue, isBool := value.(bool)
+		if !isBool {
+			return nil, fmt.Errorf("preferences.%s must be true or false", preference)
+		}
+		oldValue, _ := bson.Raw(current).Lookup(preference).BooleanOK()
+		if oldValue == newValue {
+			continue
+		}
+
+		changes = append(changes, models.ConsentChange{
+			CustomerID: customer.CustomerID,
+			OrgID:      customer.OrgID,
+			Preference: preference,
+			OldValue:   oldValue,
+			NewValue:   newValue,
+			ChangedBy:  changedBy,
+			ChangedAt:  now,
+		})
+	}
+
+	return changes, nil
+}
+
+// GetConsentHistory lists a customer's marketing consent changes, newest first
+func (h *MembershipHandler) GetConsentHistory(c *gin.Context) {
+	customerID := c.Param("id")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer ID is required"})
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "50")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset parameter"})
+		return
+	}
+
+	history, err := h.repo.GetConsentHistory(c.Request.Context(), customerID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if history == nil {
+		history = []*models.ConsentChange{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id": customerID,
+		"history":     history,
+		"count":       len(history),
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// ExportCustomer returns the customer's data-portability bundle
+func (h *MembershipHandler) ExportCustomer(c *gin.Context) {
+	customerID := c.Param("id")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer ID is required"})
+		return
+	}
+
+	// Unreachable services leave gaps listed in the bundle's warnings rather
+	// than failing the export
+	bundle, err := h.exporter.ExportCustomer(c.Request.Context(), customerID)
+	if errors.Is(err, repository.ErrCustomerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
 func (h *MembershipHandler) CreateOrganization(c *gin.Context) {
 	var org models.Organization
 	if err := c.ShouldBindJSON(&org); err != nil {
@@ -114,7 +489,30 @@ func (h *MembershipHandler) CreateOrganization(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.CreateOrganization(c.Request.Context(), &org); err != nil {
+	if err := validateStampCap(org.Settings.StampsPerVisit, org.Settings.MaxStampsPerTransaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.repo.CreateOrganization(c.Request.Context(), &org)
+	if errors.Is(err, repository.ErrOrganizationExists) && strings.EqualFold(c.GetHeader("X-Idempotent"), "true") {
+		// A retried create succeeds with the stored organization as long as
+		// it asked for the same one
+		existing, getErr := h.repo.GetOrganization(c.Request.Context(), org.OrgID)
+		if getErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": getErr.Error()})
+			return
+		}
+		if sameOrganization(existing, &org) {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
+	}
+	if errors.Is(err, repository.ErrOrganizationExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -122,6 +520,15 @@ func (h *MembershipHandler) CreateOrganization(c *gin.Context) {
 	c.JSON(http.StatusCreated, org)
 }
 
+// sameOrganization reports whether two organizations have the same
+// definition, ignoring their IDs and timestamps
+func sameOrganization(a, b *models.Organization) bool {
+	return a.OrgID == b.OrgID &&
+		a.Name == b.Name &&
+		a.Description == b.Description &&
+		reflect.DeepEqual(a.Settings, b.Settings)
+}
+
 func (h *MembershipHandler) GetOrganization(c *gin.Context) {
 	orgID := c.Param("id")
 	if orgID == "" {
@@ -138,6 +545,167 @@ func (h *MembershipHandler) GetOrganization(c *gin.Context) {
 	c.JSON(http.StatusOK, org)
 }
 
+// maxStampsPerVisit bounds stamps_per_visit, so a typo cannot fill many
+// cards with one visit
+const maxStampsPerVisit = 100
+
+// validateStampCap checks stamps_per_visit against max_stamps_per_transaction.
+// Stamps are earned per visit, so a cap below it would only ever clamp it.
+func validateStampCap(stampsPerVisit, maxPerTransaction int) error {
+	if maxPerTransaction < 0 {
+		return fmt.Errorf("max_stamps_per_transaction must not be negative")
+	}
+	if maxPerTransaction > 0 && stampsPerVisit > maxPerTransaction {
+		return fmt.Errorf("stamps_per_visit must not exceed max_stamps_per_transaction (%d)", maxPerTransaction)
+	}
+	return nil
+}
+
+// UpdateOrganization changes a live organization's name, description or
+// settings. Settings given as an object only replace the fields they name.
+// The stream processor reads settings on every event, so changes apply to
+// the next transaction.
+func (h *MembershipHandler) UpdateOrganization(c *gin.Context) {
+	orgID := c.Param("id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization ID is required"})
+		return
+	}
+
+	var patch organizationPatch
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates, err := organizationUpdates(patch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A patch to either stamp setting is checked against the other's stored
+	// value when it leaves that one out
+	stamps, stampsSet := updates["settings.stamps_per_visit"].(int)
+	maxStamps, maxSet := updates["settings.max_stamps_per_transaction"].(int)
+	if stampsSet || maxSet {
+		if !stampsSet || !maxSet {
+			org, err := h.repo.GetOrganization(c.Request.Context(), orgID)
+			if errors.Is(err, repository.ErrOrganizationNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if !stampsSet {
+				stamps = org.Settings.StampsPerVisit
+			}
+			if !maxSet {
+				maxStamps = org.Settings.MaxStampsPerTransaction
+			}
+		}
+		if err := validateStampCap(stamps, maxStamps); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err = h.repo.UpdateOrganization(c.Request.Context(), orgID, updates)
+	if errors.Is(err, repository.ErrOrganizationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "organization updated successfully"})
+}
+
+// organizationPatch is the body of an organization update. Fields left out
+// are not changed, and only the settings it names are replaced.
+type organizationPatch struct {
+	Name        *string                    `json:"name"`
+	Description *string                    `json:"description"`
+	Settings    map[string]json.RawMessage `json:"settings"`
+}
+
+// orgSettingFields maps each organization setting's JSON name to its field
+var orgSettingFields = func() map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	settings := reflect.TypeOf(models.OrgSettings{})
+	for i := 0; i < settings.NumField(); i++ {
+		field := settings.Field(i)
+		fields[strings.Split(field.Tag.Get("json"), ",")[0]] = field
+	}
+	return fields
+}()
+
+// organizationUpdates validates an organization update and flattens its
+// settings into dotted settings.<field> keys, each decoded as the type the
+// organization stores, so a wrongly typed value cannot be saved
+func organizationUpdates(patch organizationPatch) (bson.M, error) {
+	updates := bson.M{}
+	if patch.Name != nil {
+		updates["name"] = *patch.Name
+	}
+	if patch.Description != nil {
+		updates["description"] = *patch.Description
+	}
+	for name, raw := range patch.Settings {
+		field, ok := orgSettingFields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %s", name)
+		}
+		value := reflect.New(field.Type)
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(value.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		updates["settings."+strings.Split(field.Tag.Get("bson"), ",")[0]] = value.Elem().Interface()
+	}
+
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no updates given")
+	}
+
+	if value, ok := updates["settings.points_per_dollar"]; ok && value.(float64) < 0 {
+		return nil, fmt.Errorf("points_per_dollar must be a non-negative number")
+	}
+
+	if value, ok := updates["settings.stamps_per_visit"]; ok {
+		if stamps := value.(int); stamps < 0 || stamps > maxStampsPerVisit {
+			return nil, fmt.Errorf("stamps_per_visit must be a whole number from 0 to %d", maxStampsPerVisit)
+		}
+	}
+
+	return updates, nil
+}
+
+// GetAccrualRate describes the org's points accrual rate for customers, in
+// its currency and locale, with example purchases
+func (h *MembershipHandler) GetAccrualRate(c *gin.Context) {
+	orgID := c.Param("id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization ID is required"})
+		return
+	}
+
+	org, err := h.repo.GetOrganization(c.Request.Context(), orgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, accrual.Describe(org))
+}
+
 // Location Management APIs
 
 func (h *MembershipHandler) CreateLocation(c *gin.Context) {
@@ -156,6 +724,82 @@ func (h *MembershipHandler) CreateLocation(c *gin.Context) {
 	c.JSON(http.StatusCreated, location)
 }
 
+// maxBulkLocations caps the rows accepted by CreateLocations in one request
+const maxBulkLocations = 500
+
+// CreateLocations creates a batch of locations from a JSON array of
+// location requests. Each row is validated and inserted on its own, so an
+// invalid row is reported in its result without aborting the rest. The
+// response is 201 when every row is created and 207 otherwise.
+func (h *MembershipHandler) CreateLocations(c *gin.Context) {
+	var rows []json.RawMessage
+	if err := c.ShouldBindJSON(&rows); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request body must be an array of locations"})
+		return
+	}
+
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one location is required"})
+		return
+	}
+
+	if len(rows) > maxBulkLocations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d locations may be created at once", maxBulkLocations)})
+		return
+	}
+
+	results := make([]models.LocationResult, len(rows))
+	var valid []*models.CreateLocationRequest
+	var validIndexes []int
+	for i, row := range rows {
+		results[i].Index = i
+
+		var req models.CreateLocationRequest
+		if err := json.Unmarshal(row, &req); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		valid = append(valid, &req)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if len(valid) > 0 {
+		created, err := h.repo.CreateLocations(c.Request.Context(), valid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		for j, result := range created {
+			result.Index = validIndexes[j]
+			results[validIndexes[j]] = result
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	status := http.StatusCreated
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, gin.H{
+		"results": results,
+		"created": len(results) - failed,
+		"failed":  failed,
+	})
+}
+
 func (h *MembershipHandler) GetLocation(c *gin.Context) {
 	locationID := c.Param("id")
 	if locationID == "" {
@@ -172,6 +816,8 @@ func (h *MembershipHandler) GetLocation(c *gin.Context) {
 	c.JSON(http.StatusOK, location)
 }
 
+// GetLocationsByOrg returns a page of an org's locations with count, the size
+// of the page, and total, every location in the org
 func (h *MembershipHandler) GetLocationsByOrg(c *gin.Context) {
 	orgID := c.Query("org_id")
 	if orgID == "" {
@@ -194,15 +840,26 @@ func (h *MembershipHandler) GetLocationsByOrg(c *gin.Context) {
 		return
 	}
 
+	totals := countConcurrently(func() (int64, error) {
+		return h.repo.CountLocationsByOrg(c.Request.Context(), orgID)
+	})
+
 	locations, err := h.repo.GetLocationsByOrg(c.Request.Context(), orgID, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	result := <-totals
+	if result.err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"locations": locations,
 		"count":     len(locations),
+		"total":     result.total,
 		"limit":     limit,
 		"offset":    offset,
 	})
@@ -245,6 +902,172 @@ func (h *MembershipHandler) DeactivateLocation(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "location deactivated successfully"})
 }
 
+// DeactivateCustomer soft-deletes a customer by marking them inactive; the
+// stream processor then declines their POS transactions
+func (h *MembershipHandler) DeactivateCustomer(c *gin.Context) {
+	h.setCustomerStatus(c, models.CustomerStatusInactive, "customer deactivated successfully")
+}
+
+// ReactivateCustomer marks a deactivated customer active again
+func (h *MembershipHandler) ReactivateCustomer(c *gin.Context) {
+	h.setCustomerStatus(c, models.CustomerStatusActive, "customer reactivated successfully")
+}
+
+func (h *MembershipHandler) setCustomerStatus(c *gin.Context, status, message string) {
+	customerID := c.Param("id")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer ID is required"})
+		return
+	}
+
+	updates := bson.M{"status": status}
+	err := h.repo.UpdateCustomer(c.Request.Context(), customerID, updates)
+	if errors.Is(err, repository.ErrCustomerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// SetCustomerMultiplier gives a customer a temporary points multiplier on top
+// of their tier's
+func (h *MembershipHandler) SetCustomerMultiplier(c *gin.Context) {
+	customerID := c.Param("id")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer ID is required"})
+		return
+	}
+
+	var req models.SetMultiplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	updates := bson.M{
+		"custom_multiplier":     req.Multiplier,
+		"multiplier_expires_at": req.ExpiresAt,
+	}
+	if err := h.repo.UpdateCustomer(c.Request.Context(), customerID, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "customer multiplier set successfully"})
+}
+
+// ClearCustomerMultiplier removes a customer's custom multiplier before it
+// expires
+func (h *MembershipHandler) ClearCustomerMultiplier(c *gin.Context) {
+	customerID := c.Param("id")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer ID is required"})
+		return
+	}
+
+	updates := bson.M{
+		"custom_multiplier":     0,
+		"multiplier_expires_at": nil,
+	}
+	if err := h.repo.UpdateCustomer(c.Request.Context(), customerID, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "customer multiplier cleared successfully"})
+}
+
+// maxTagLength is the longest customer tag accepted, in characters
+const maxTagLength = 64
+
+// normalizeTag trims and lower cases a tag, so "VIP " and "vip" are the same
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// AddCustomerTags adds tags to a customer. Tags the customer already has are
+// ignored.
+func (h *MembershipHandler) AddCustomerTags(c *gin.Context) {
+	customerID := c.Param("id")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer ID is required"})
+		return
+	}
+
+	var req models.AddTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags := make([]string, 0, len(req.Tags))
+	for _, tag := range req.Tags {
+		tag = normalizeTag(tag)
+		if tag == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tags cannot be empty"})
+			return
+		}
+		if len([]rune(tag)) > maxTagLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tags cannot be longer than %d characters", maxTagLength)})
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	err := h.repo.AddCustomerTags(c.Request.Context(), customerID, tags)
+	if errors.Is(err, repository.ErrCustomerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "customer tags added successfully",
+		"tags":    tags,
+	})
+}
+
+// RemoveCustomerTag removes a tag from a customer. Removing a tag the
+// customer does not have succeeds.
+func (h *MembershipHandler) RemoveCustomerTag(c *gin.Context) {
+	customerID := c.Param("id")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customer ID is required"})
+		return
+	}
+
+	tag := normalizeTag(c.Param("tag"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag is required"})
+		return
+	}
+
+	err := h.repo.RemoveCustomerTag(c.Request.Context(), customerID, tag)
+	if errors.Is(err, repository.ErrCustomerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "customer tag removed successfully"})
+}
+
 func (h *MembershipHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "healthy",
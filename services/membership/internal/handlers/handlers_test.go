@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/loyalty/membership/internal/export"
 	"github.com/loyalty/membership/internal/models"
+	"github.com/loyalty/membership/internal/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson"
@@ -38,6 +42,34 @@ func (m *MockMongoRepo) GetCustomer(ctx context.Context, customerID string) (*mo
 	return args.Get(0).(*models.Customer), args.Error(1)
 }
 
+func (m *MockMongoRepo) CountCustomersByOrg(ctx context.Context, orgID string) (int64, error) {
+	args := m.Called(ctx, orgID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMongoRepo) GetCustomersCreatedBetween(ctx context.Context, orgID string, from, to time.Time, limit, offset int) ([]*models.Customer, error) {
+	args := m.Called(ctx, orgID, from, to, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Customer), args.Error(1)
+}
+
+func (m *MockMongoRepo) CountCustomersCreatedBetween(ctx context.Context, orgID string, from, to time.Time) (int64, error) {
+	args := m.Called(ctx, orgID, from, to)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMongoRepo) CountCustomersByTier(ctx context.Context, orgID, tier string) (int64, error) {
+	args := m.Called(ctx, orgID, tier)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockMongoRepo) CountCustomersByPreferences(ctx context.Context, orgID string, filter models.TargetingFilter) (int64, error) {
+	args := m.Called(ctx, orgID, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockMongoRepo) GetCustomersByOrg(ctx context.Context, orgID string, limit, offset int) ([]*models.Customer, error) {
 	args := m.Called(ctx, orgID, limit, offset)
 	if args.Get(0) == nil {
@@ -46,11 +78,66 @@ func (m *MockMongoRepo) GetCustomersByOrg(ctx context.Context, orgID string, lim
 	return args.Get(0).([]*models.Customer), args.Error(1)
 }
 
+func (m *MockMongoRepo) GetCustomersByTier(ctx context.Context, orgID, tier string, limit, offset int) ([]*models.Customer, error) {
+	args := m.Called(ctx, orgID, tier, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Customer), args.Error(1)
+}
+
+func (m *MockMongoRepo) SearchCustomers(ctx context.Context, orgID, email, phone string) ([]*models.Customer, error) {
+	args := m.Called(ctx, orgID, email, phone)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Customer), args.Error(1)
+}
+
+func (m *MockMongoRepo) GetCustomersByPreferences(ctx context.Context, orgID string, filter models.TargetingFilter, limit, offset int) ([]*models.Customer, error) {
+	args := m.Called(ctx, orgID, filter, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Customer), args.Error(1)
+}
+
+func (m *MockMongoRepo) GetBalanceAlertCustomers(ctx context.Context, limit, offset int) ([]*models.Customer, error) {
+	args := m.Called(ctx, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Customer), args.Error(1)
+}
+
 func (m *MockMongoRepo) UpdateCustomer(ctx context.Context, customerID string, updates bson.M) error {
 	args := m.Called(ctx, customerID, updates)
 	return args.Error(0)
 }
 
+func (m *MockMongoRepo) AddCustomerTags(ctx context.Context, customerID string, tags []string) error {
+	args := m.Called(ctx, customerID, tags)
+	return args.Error(0)
+}
+
+func (m *MockMongoRepo) RemoveCustomerTag(ctx context.Context, customerID, tag string) error {
+	args := m.Called(ctx, customerID, tag)
+	return args.Error(0)
+}
+
+func (m *MockMongoRepo) AppendConsentHistory(ctx context.Context, changes []models.ConsentChange) error {
+	args := m.Called(ctx, changes)
+	return args.Error(0)
+}
+
+func (m *MockMongoRepo) GetConsentHistory(ctx context.Context, customerID string, limit, offset int) ([]*models.ConsentChange, error) {
+	args := m.Called(ctx, customerID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ConsentChange), args.Error(1)
+}
+
 func (m *MockMongoRepo) CreateOrganization(ctx context.Context, org *models.Organization) error {
 	args := m.Called(ctx, org)
 	return args.Error(0)
@@ -64,6 +151,11 @@ func (m *MockMongoRepo) GetOrganization(ctx context.Context, orgID string) (*mod
 	return args.Get(0).(*models.Organization), args.Error(1)
 }
 
+func (m *MockMongoRepo) UpdateOrganization(ctx context.Context, orgID string, updates bson.M) error {
+	args := m.Called(ctx, orgID, updates)
+	return args.Error(0)
+}
+
 func (m *MockMongoRepo) CreateLocation(ctx context.Context, req *models.CreateLocationRequest) (*models.Location, error) {
 	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
@@ -72,6 +164,14 @@ func (m *MockMongoRepo) CreateLocation(ctx context.Context, req *models.CreateLo
 	return args.Get(0).(*models.Location), args.Error(1)
 }
 
+func (m *MockMongoRepo) CreateLocations(ctx context.Context, reqs []*models.CreateLocationRequest) ([]models.LocationResult, error) {
+	args := m.Called(ctx, reqs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.LocationResult), args.Error(1)
+}
+
 func (m *MockMongoRepo) GetLocation(ctx context.Context, locationID string) (*models.Location, error) {
 	args := m.Called(ctx, locationID)
 	if args.Get(0) == nil {
@@ -80,6 +180,11 @@ func (m *MockMongoRepo) GetLocation(ctx context.Context, locationID string) (*mo
 	return args.Get(0).(*models.Location), args.Error(1)
 }
 
+func (m *MockMongoRepo) CountLocationsByOrg(ctx context.Context, orgID string) (int64, error) {
+	args := m.Called(ctx, orgID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockMongoRepo) GetLocationsByOrg(ctx context.Context, orgID string, limit, offset int) ([]*models.Location, error) {
 	args := m.Called(ctx, orgID, limit, offset)
 	if args.Get(0) == nil {
@@ -98,6 +203,19 @@ func (m *MockMongoRepo) Close() error {
 	return args.Error(0)
 }
 
+// MockCustomerExporter is a mock implementation of the customer exporter
+type MockCustomerExporter struct {
+	mock.Mock
+}
+
+func (m *MockCustomerExporter) ExportCustomer(ctx context.Context, customerID string) (*export.CustomerExport, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*export.CustomerExport), args.Error(1)
+}
+
 // Test setup helper
 func setupTest() (*gin.Engine, *MockMongoRepo, *MembershipHandler) {
 	gin.SetMode(gin.TestMode)
@@ -226,6 +344,45 @@ func TestCreateCustomer_RepositoryError(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreateCustomer_DuplicateEmail(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.POST("/customers", handler.CreateCustomer)
+	
+	// Test data
+	reqBody := models.CreateCustomerRequest{
+		OrgID:     "test_org",
+		Email:     "test@example.com",
+		FirstName: "John",
+		LastName:  "Doe",
+	}
+	
+	jsonData, _ := json.Marshal(reqBody)
+	
+	// Mock repository error
+	mockRepo.On("CreateCustomer", mock.Anything, &reqBody).
+		Return(nil, fmt.Errorf("%w test_org", repository.ErrCustomerEmailExists))
+	
+	// Create request
+	req, _ := http.NewRequest("POST", "/customers", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusConflict, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "customer with this email already exists in organization", response["error"])
+	
+	mockRepo.AssertExpectations(t)
+}
+
 // Test GetCustomer
 func TestGetCustomer_Success(t *testing.T) {
 	router, mockRepo, handler := setupTest()
@@ -337,6 +494,7 @@ func TestGetCustomersByOrg_Success(t *testing.T) {
 	}
 	
 	mockRepo.On("GetCustomersByOrg", mock.Anything, "test_org", 10, 0).Return(expectedCustomers, nil)
+	mockRepo.On("CountCustomersByOrg", mock.Anything, "test_org").Return(int64(25), nil)
 	
 	// Create request
 	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&limit=10&offset=0", nil)
@@ -352,6 +510,7 @@ func TestGetCustomersByOrg_Success(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, float64(2), response["count"])
+	assert.Equal(t, float64(25), response["total"])
 	assert.Equal(t, float64(10), response["limit"])
 	assert.Equal(t, float64(0), response["offset"])
 	
@@ -427,28 +586,53 @@ func TestGetCustomersByOrg_InvalidOffset(t *testing.T) {
 	assert.Contains(t, response["error"], "invalid offset parameter")
 }
 
-// Test UpdateCustomer
-func TestUpdateCustomer_Success(t *testing.T) {
+func TestGetCustomersByOrg_FilterByTier(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	router.GET("/customers", handler.GetCustomersByOrg)
 	
-	// Test data
-	updates := map[string]interface{}{
-		"first_name": "Updated",
-		"last_name":  "Name",
-		"email":      "updated@example.com",
+	// Mock repository response
+	goldCustomers := []*models.Customer{
+		{CustomerID: "cust_1", OrgID: "test_org", Tier: "gold"},
+		{CustomerID: "cust_3", OrgID: "test_org", Tier: "Gold"},
 	}
 	
-	jsonData, _ := json.Marshal(updates)
+	mockRepo.On("GetCustomersByTier", mock.Anything, "test_org", "gold", 50, 0).Return(goldCustomers, nil)
+	mockRepo.On("CountCustomersByTier", mock.Anything, "test_org", "gold").Return(int64(3), nil)
 	
-	// Mock repository
-	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", bson.M(updates)).Return(nil)
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&tier=gold", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), response["count"])
+	assert.Equal(t, "gold", response["tier"])
+	assert.Equal(t, float64(3), response["total"])
+	
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetCustomersByOrg", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetCustomersByOrg_UnusedTier(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/customers", handler.GetCustomersByOrg)
+	
+	mockRepo.On("GetCustomersByTier", mock.Anything, "test_org", "platinum", 50, 0).Return(nil, nil)
+	mockRepo.On("CountCustomersByTier", mock.Anything, "test_org", "platinum").Return(int64(0), nil)
 	
 	// Create request
-	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&tier=platinum", nil)
 	
 	// Record response
 	w := httptest.NewRecorder()
@@ -460,114 +644,151 @@ func TestUpdateCustomer_Success(t *testing.T) {
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response["message"], "customer updated successfully")
+	assert.Equal(t, float64(0), response["count"])
+	assert.Equal(t, []interface{}{}, response["customers"])
 	
 	mockRepo.AssertExpectations(t)
 }
 
-func TestUpdateCustomer_MissingID(t *testing.T) {
-	router, _, handler := setupTest()
+func TestGetCustomersByOrg_TierRepositoryError(t *testing.T) {
+	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	router.GET("/customers", handler.GetCustomersByOrg)
 	
-	// Create request without ID
-	req, _ := http.NewRequest("PATCH", "/customers/", nil)
+	mockRepo.On("GetCustomersByTier", mock.Anything, "test_org", "gold", 50, 0).Return(nil, assert.AnError)
+	mockRepo.On("CountCustomersByTier", mock.Anything, "test_org", "gold").Return(int64(0), nil).Maybe()
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&tier=gold", nil)
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRepo.AssertExpectations(t)
 }
 
-func TestUpdateCustomer_InvalidRequest(t *testing.T) {
-	router, _, handler := setupTest()
+func TestGetCustomersByOrg_FilterByEmailOptIn(t *testing.T) {
+	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	router.GET("/customers", handler.GetCustomersByOrg)
 	
-	// Invalid JSON
-	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBufferString("invalid json"))
-	req.Header.Set("Content-Type", "application/json")
+	optedIn := true
+	filter := models.TargetingFilter{EmailOptIn: &optedIn}
+	customers := []*models.Customer{
+		{CustomerID: "cust_1", OrgID: "test_org", Preferences: models.CustomerPrefs{EmailMarketing: true}},
+	}
+	mockRepo.On("GetCustomersByPreferences", mock.Anything, "test_org", filter, 50, 0).Return(customers, nil)
+	mockRepo.On("CountCustomersByPreferences", mock.Anything, "test_org", filter).Return(int64(1), nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&email_opt_in=true", nil)
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
 	
 	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response["error"], "invalid")
+	assert.Equal(t, float64(1), response["count"])
+	assert.Equal(t, map[string]interface{}{"email_opt_in": true}, response["filter"])
+	
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetCustomersByOrg", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-// Test CreateOrganization
-func TestCreateOrganization_Success(t *testing.T) {
+func TestGetCustomersByOrg_FilterByCategoryAndTier(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.POST("/organizations", handler.CreateOrganization)
+	router.GET("/customers", handler.GetCustomersByOrg)
 	
-	// Test data
-	org := models.Organization{
-		OrgID:        "test_org",
-		Name:         "Test Organization",
-		Description:  "Test organization description",
-		Settings:     models.OrgSettings{},
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+	optedOut := false
+	filter := models.TargetingFilter{SMSOptIn: &optedOut, Category: "beverages", Tier: "gold"}
+	customers := []*models.Customer{
+		{CustomerID: "cust_2", OrgID: "test_org", Tier: "gold", Preferences: models.CustomerPrefs{Categories: []string{"beverages", "bakery"}}},
 	}
-	
-	jsonData, _ := json.Marshal(org)
-	
-	// Mock repository
-	mockRepo.On("CreateOrganization", mock.Anything, mock.AnythingOfType("*models.Organization")).Return(nil)
+	mockRepo.On("GetCustomersByPreferences", mock.Anything, "test_org", filter, 10, 20).Return(customers, nil)
+	mockRepo.On("CountCustomersByPreferences", mock.Anything, "test_org", filter).Return(int64(21), nil)
 	
 	// Create request
-	req, _ := http.NewRequest("POST", "/organizations", bytes.NewBuffer(jsonData))
-	req.Header.Set("Content-Type", "application/json")
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&sms_opt_in=false&category=beverages&tier=gold&limit=10&offset=20", nil)
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
 	
-	var response models.Organization
+	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "test_org", response.OrgID)
-	assert.Equal(t, "Test Organization", response.Name)
+	assert.Equal(t, float64(1), response["count"])
+	assert.Equal(t, float64(21), response["total"])
 	
 	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetCustomersByTier", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
-// Test GetOrganization
-func TestGetOrganization_Success(t *testing.T) {
+func TestGetCustomersByOrg_FilterByTag(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/customers", handler.GetCustomersByOrg)
+
+	filter := models.TargetingFilter{Tag: "vip"}
+	customers := []*models.Customer{
+		{CustomerID: "cust_1", OrgID: "test_org", Tags: []string{"vip", "beta-tester"}},
+	}
+	mockRepo.On("GetCustomersByPreferences", mock.Anything, "test_org", filter, 50, 0).Return(customers, nil)
+	mockRepo.On("CountCustomersByPreferences", mock.Anything, "test_org", filter).Return(int64(1), nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&tag=VIP", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), response["count"])
+	assert.Equal(t, float64(1), response["total"])
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetCustomersByOrg", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetCustomersByOrg_CreatedBetween(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/organizations/:id", handler.GetOrganization)
+	router.GET("/customers", handler.GetCustomersByOrg)
 	
-	// Mock repository response
-	expectedOrg := &models.Organization{
-		OrgID:        "test_org",
-		Name:         "Test Organization",
-		Description:  "Test organization description",
-		Settings:     models.OrgSettings{},
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	customers := []*models.Customer{
+		{CustomerID: "cust_2", OrgID: "test_org", CreatedAt: time.Date(2024, 6, 20, 9, 0, 0, 0, time.UTC)},
+		{CustomerID: "cust_1", OrgID: "test_org", CreatedAt: time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC)},
 	}
-	
-	mockRepo.On("GetOrganization", mock.Anything, "test_org").Return(expectedOrg, nil)
+	mockRepo.On("GetCustomersCreatedBetween", mock.Anything, "test_org", from, to, 50, 0).Return(customers, nil)
+	mockRepo.On("CountCustomersCreatedBetween", mock.Anything, "test_org", from, to).Return(int64(2), nil)
 	
 	// Create request
-	req, _ := http.NewRequest("GET", "/organizations/test_org", nil)
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&created_from=2024-06-01&created_to=2024-07-01", nil)
 	
 	// Record response
 	w := httptest.NewRecorder()
@@ -576,35 +797,962 @@ func TestGetOrganization_Success(t *testing.T) {
 	// Assertions
 	assert.Equal(t, http.StatusOK, w.Code)
 	
-	var response models.Organization
+	var response map[string]interface{}
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "test_org", response.OrgID)
-	assert.Equal(t, "Test Organization", response.Name)
+	assert.Equal(t, float64(2), response["count"])
+	assert.Equal(t, float64(2), response["total"])
+	assert.Equal(t, "2024-06-01T00:00:00Z", response["created_from"])
+	assert.Equal(t, "2024-07-01T00:00:00Z", response["created_to"])
 	
 	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "CountCustomersByOrg", mock.Anything, mock.Anything)
 }
 
-func TestGetOrganization_MissingID(t *testing.T) {
-	router, _, handler := setupTest()
+func TestGetCustomersByOrg_CreatedFromOnly(t *testing.T) {
+	router, mockRepo, handler := setupTest()
 	
 	// Setup route
-	router.GET("/organizations/:id", handler.GetOrganization)
+	router.GET("/customers", handler.GetCustomersByOrg)
 	
-	// Create request without ID
-	req, _ := http.NewRequest("GET", "/organizations/", nil)
+	from := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	mockRepo.On("GetCustomersCreatedBetween", mock.Anything, "test_org", from, time.Time{}, 50, 0).Return([]*models.Customer{}, nil)
+	mockRepo.On("CountCustomersCreatedBetween", mock.Anything, "test_org", from, time.Time{}).Return(int64(0), nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&created_from=2024-06-01T12:30:00Z", nil)
 	
 	// Record response
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	
 	// Assertions
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "created_to")
+	mockRepo.AssertExpectations(t)
 }
 
-// Test CreateLocation
-func TestCreateLocation_Success(t *testing.T) {
-	router, mockRepo, handler := setupTest()
+func TestGetCustomersByOrg_InvalidCreatedRange(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{"unparseable date", "created_from=June", "invalid created_from parameter"},
+		{"end before start", "created_from=2024-07-01&created_to=2024-06-01", "created_to must be after created_from"},
+		{"combined with tier", "created_from=2024-06-01&tier=gold", "cannot be combined"},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockRepo, handler := setupTest()
+			
+			// Setup route
+			router.GET("/customers", handler.GetCustomersByOrg)
+			
+			// Create request
+			req, _ := http.NewRequest("GET", "/customers?org_id=test_org&"+tt.query, nil)
+			
+			// Record response
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			
+			// Assertions
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.error)
+			mockRepo.AssertNotCalled(t, "GetCustomersCreatedBetween", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+// Test SearchCustomers
+func TestSearchCustomers_ByEmail(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route - registered alongside the customer ID route as in the server
+	router.GET("/customers/search", handler.SearchCustomers)
+	router.GET("/customers/:id", handler.GetCustomer)
+
+	// Setup expectations
+	mockRepo.On("SearchCustomers", mock.Anything, "test_org", "jane@example.com", "").Return([]*models.Customer{
+		{CustomerID: "cust_1", OrgID: "test_org", Email: "jane@example.com"},
+	}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/search?org_id=test_org&email=jane@example.com", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), response["count"])
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetCustomer", mock.Anything, mock.Anything)
+}
+
+func TestSearchCustomers_EmailAndPhone(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	router.GET("/customers/search", handler.SearchCustomers)
+
+	// Setup expectations
+	mockRepo.On("SearchCustomers", mock.Anything, "test_org", "jane@example.com", "+15550100").Return(nil, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/search?org_id=test_org&email=jane@example.com&phone=%2B15550100", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"customers":[],"count":0}`, w.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchCustomers_RequiresEmailOrPhone(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"neither", "org_id=test_org", "email or phone is required"},
+		{"blank", "org_id=test_org&email=%20&phone=", "email or phone is required"},
+		{"missing org", "email=jane@example.com", "org_id is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockRepo, handler := setupTest()
+			router.GET("/customers/search", handler.SearchCustomers)
+
+			// Create request
+			req, _ := http.NewRequest("GET", "/customers/search?"+tt.query, nil)
+
+			// Record response
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.want)
+			mockRepo.AssertNotCalled(t, "SearchCustomers", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSearchCustomers_RepositoryError(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	router.GET("/customers/search", handler.SearchCustomers)
+
+	// Setup expectations
+	mockRepo.On("SearchCustomers", mock.Anything, "test_org", "", "+15550100").Return(nil, assert.AnError)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/search?org_id=test_org&phone=%2B15550100", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetCustomersByOrg_InvalidOptIn(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/customers", handler.GetCustomersByOrg)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers?org_id=test_org&email_opt_in=maybe", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid email_opt_in parameter")
+	mockRepo.AssertNotCalled(t, "GetCustomersByPreferences", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Test UpdateCustomer
+func TestUpdateCustomer_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	
+	// Test data
+	updates := map[string]interface{}{
+		"first_name": "Updated",
+		"last_name":  "Name",
+		"email":      "updated@example.com",
+	}
+	
+	jsonData, _ := json.Marshal(updates)
+	
+	// Mock repository
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", bson.M(updates)).Return(nil)
+	
+	// Create request
+	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["message"], "customer updated successfully")
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateCustomer_MissingID(t *testing.T) {
+	router, _, handler := setupTest()
+	
+	// Setup route
+	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	
+	// Create request without ID
+	req, _ := http.NewRequest("PATCH", "/customers/", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateCustomer_InvalidRequest(t *testing.T) {
+	router, _, handler := setupTest()
+	
+	// Setup route
+	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	
+	// Invalid JSON
+	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBufferString("invalid json"))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "invalid")
+}
+
+func TestUpdateCustomer_ConsentChangeAppendsHistory(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	handler.config = DefaultHandlerConfig()
+	
+	// Setup route
+	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	
+	// Test data - the customer opts in to email marketing
+	customer := &models.Customer{
+		CustomerID:  "cust_123",
+		OrgID:       "test_org",
+		Preferences: models.CustomerPrefs{EmailMarketing: false, SMSMarketing: true},
+	}
+	updates := map[string]interface{}{"preferences.email_marketing": true}
+	jsonData, _ := json.Marshal(updates)
+	
+	// Mock repository
+	mockRepo.On("GetCustomer", mock.Anything, "cust_123").Return(customer, nil)
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", bson.M(updates)).Return(nil)
+	mockRepo.On("AppendConsentHistory", mock.Anything, mock.AnythingOfType("[]models.ConsentChange")).Return(nil)
+	
+	// Create request
+	before := time.Now()
+	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Changed-By", "support_agent_7")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertCalled(t, "AppendConsentHistory", mock.Anything, mock.MatchedBy(func(changes []models.ConsentChange) bool {
+		return len(changes) == 1 &&
+			changes[0].CustomerID == "cust_123" &&
+			changes[0].OrgID == "test_org" &&
+			changes[0].Preference == "email_marketing" &&
+			!changes[0].OldValue &&
+			changes[0].NewValue &&
+			changes[0].ChangedBy == "support_agent_7" &&
+			!changes[0].ChangedAt.Before(before)
+	}))
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateCustomer_ReplacedPreferencesRecordEachChange(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	handler.config = DefaultHandlerConfig()
+	
+	// Setup route
+	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	
+	// Test data - replacing preferences opts out of SMS by leaving it out
+	customer := &models.Customer{
+		CustomerID:  "cust_123",
+		OrgID:       "test_org",
+		Preferences: models.CustomerPrefs{EmailMarketing: false, SMSMarketing: true},
+	}
+	jsonData := []byte(`{"preferences": {"email_marketing": true, "language": "en"}}`)
+	
+	// Mock repository
+	mockRepo.On("GetCustomer", mock.Anything, "cust_123").Return(customer, nil)
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", mock.AnythingOfType("primitive.M")).Return(nil)
+	mockRepo.On("AppendConsentHistory", mock.Anything, mock.AnythingOfType("[]models.ConsentChange")).Return(nil)
+	
+	// Create request
+	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertCalled(t, "AppendConsentHistory", mock.Anything, mock.MatchedBy(func(changes []models.ConsentChange) bool {
+		return len(changes) == 2 &&
+			changes[0].Preference == "email_marketing" && changes[0].NewValue &&
+			changes[1].Preference == "sms_marketing" && !changes[1].NewValue &&
+			changes[1].ChangedBy == ""
+	}))
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateCustomer_UnchangedConsentNotRecorded(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	handler.config = DefaultHandlerConfig()
+	
+	// Setup route
+	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	
+	// Test data
+	customer := &models.Customer{
+		CustomerID:  "cust_123",
+		OrgID:       "test_org",
+		Preferences: models.CustomerPrefs{EmailMarketing: true},
+	}
+	updates := map[string]interface{}{"preferences.email_marketing": true}
+	jsonData, _ := json.Marshal(updates)
+	
+	// Mock repository
+	mockRepo.On("GetCustomer", mock.Anything, "cust_123").Return(customer, nil)
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", bson.M(updates)).Return(nil)
+	
+	// Create request
+	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertNotCalled(t, "AppendConsentHistory", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateCustomer_InvalidConsentValue(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	handler.config = DefaultHandlerConfig()
+	
+	// Setup route
+	router.PATCH("/customers/:id", handler.UpdateCustomer)
+	
+	// Mock repository
+	mockRepo.On("GetCustomer", mock.Anything, "cust_123").Return(&models.Customer{CustomerID: "cust_123"}, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("PATCH", "/customers/cust_123", bytes.NewBufferString(`{"preferences.sms_marketing": "yes"}`))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "preferences.sms_marketing must be true or false")
+	mockRepo.AssertNotCalled(t, "UpdateCustomer", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Test GetConsentHistory
+func TestGetConsentHistory_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/customers/:id/consent-history", handler.GetConsentHistory)
+	
+	// Mock repository
+	history := []*models.ConsentChange{
+		{CustomerID: "cust_123", Preference: "email_marketing", OldValue: true, NewValue: false, ChangedAt: time.Now()},
+		{CustomerID: "cust_123", Preference: "email_marketing", OldValue: false, NewValue: true, ChangedAt: time.Now().Add(-time.Hour)},
+	}
+	mockRepo.On("GetConsentHistory", mock.Anything, "cust_123", 10, 0).Return(history, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/cust_123/consent-history?limit=10", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "cust_123", response["customer_id"])
+	assert.Equal(t, float64(2), response["count"])
+	
+	mockRepo.AssertExpectations(t)
+}
+
+// Test ExportCustomer
+func TestExportCustomer_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	mockExporter := &MockCustomerExporter{}
+	handler.exporter = mockExporter
+	
+	// Setup route
+	router.GET("/customers/:id/export", handler.ExportCustomer)
+	
+	// Mock exporter response
+	bundle := &export.CustomerExport{
+		ExportedAt: time.Now(),
+		Profile: &models.Customer{
+			CustomerID: "cust_123",
+			OrgID:      "test_org",
+			Email:      "test@example.com",
+		},
+	}
+	
+	mockExporter.On("ExportCustomer", mock.Anything, "cust_123").Return(bundle, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/cust_123/export", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response export.CustomerExport
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "cust_123", response.Profile.CustomerID)
+	
+	mockExporter.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportCustomer_PartialData(t *testing.T) {
+	router, _, handler := setupTest()
+	mockExporter := &MockCustomerExporter{}
+	handler.exporter = mockExporter
+	
+	// Setup route
+	router.GET("/customers/:id/export", handler.ExportCustomer)
+	
+	// Mock exporter response missing what analytics holds
+	bundle := &export.CustomerExport{
+		ExportedAt: time.Now(),
+		Profile:    &models.Customer{CustomerID: "cust_123", OrgID: "test_org"},
+		Warnings:   []string{"rfm_score omitted: analytics unavailable: connection refused"},
+	}
+	mockExporter.On("ExportCustomer", mock.Anything, "cust_123").Return(bundle, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/cust_123/export", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response export.CustomerExport
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "cust_123", response.Profile.CustomerID)
+	assert.Equal(t, bundle.Warnings, response.Warnings)
+}
+
+func TestExportCustomer_NotFound(t *testing.T) {
+	router, _, handler := setupTest()
+	mockExporter := &MockCustomerExporter{}
+	handler.exporter = mockExporter
+	
+	// Setup route
+	router.GET("/customers/:id/export", handler.ExportCustomer)
+	
+	// Mock exporter error
+	mockExporter.On("ExportCustomer", mock.Anything, "missing").Return(nil, repository.ErrCustomerNotFound)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/missing/export", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestExportCustomer_ProfileLookupFails(t *testing.T) {
+	router, _, handler := setupTest()
+	mockExporter := &MockCustomerExporter{}
+	handler.exporter = mockExporter
+
+	// Setup route
+	router.GET("/customers/:id/export", handler.ExportCustomer)
+
+	// Mock exporter error
+	mockExporter.On("ExportCustomer", mock.Anything, "cust_123").Return(nil, fmt.Errorf("failed to get customer: server selection timeout"))
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/customers/cust_123/export", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// Test CreateOrganization
+func TestCreateOrganization_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.POST("/organizations", handler.CreateOrganization)
+	
+	// Test data
+	org := models.Organization{
+		OrgID:        "test_org",
+		Name:         "Test Organization",
+		Description:  "Test organization description",
+		Settings:     models.OrgSettings{},
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	
+	jsonData, _ := json.Marshal(org)
+	
+	// Mock repository
+	mockRepo.On("CreateOrganization", mock.Anything, mock.AnythingOfType("*models.Organization")).Return(nil)
+	
+	// Create request
+	req, _ := http.NewRequest("POST", "/organizations", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusCreated, w.Code)
+	
+	var response models.Organization
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", response.OrgID)
+	assert.Equal(t, "Test Organization", response.Name)
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateOrganization_StampsOverCap(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/organizations", handler.CreateOrganization)
+
+	org := models.Organization{
+		OrgID:    "test_org",
+		Name:     "Test Organization",
+		Settings: models.OrgSettings{StampsPerVisit: 5, MaxStampsPerTransaction: 3},
+	}
+
+	w := postOrganization(router, org, false)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "max_stamps_per_transaction")
+	mockRepo.AssertNotCalled(t, "CreateOrganization", mock.Anything, mock.Anything)
+}
+
+func postOrganization(router *gin.Engine, org models.Organization, idempotent bool) *httptest.ResponseRecorder {
+	jsonData, _ := json.Marshal(org)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/organizations", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	if idempotent {
+		req.Header.Set("X-Idempotent", "true")
+	}
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateOrganization_DuplicateConflict(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	router.POST("/organizations", handler.CreateOrganization)
+
+	// Mock repository
+	mockRepo.On("CreateOrganization", mock.Anything, mock.AnythingOfType("*models.Organization")).
+		Return(fmt.Errorf("%w: test_org", repository.ErrOrganizationExists))
+
+	w := postOrganization(router, models.Organization{OrgID: "test_org", Name: "Test Organization"}, false)
+
+	// Assertions
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "organization already exists")
+	mockRepo.AssertNotCalled(t, "GetOrganization", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrganization_IdempotentRetryReturnsExisting(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	router.POST("/organizations", handler.CreateOrganization)
+
+	// Test data
+	org := models.Organization{
+		OrgID:    "test_org",
+		Name:     "Test Organization",
+		Settings: models.OrgSettings{PointsPerDollar: 2.0},
+	}
+	existing := org
+	existing.ID = primitive.NewObjectID()
+	existing.CreatedAt = time.Now().Add(-time.Hour)
+
+	// Mock repository
+	mockRepo.On("CreateOrganization", mock.Anything, mock.AnythingOfType("*models.Organization")).
+		Return(fmt.Errorf("%w: test_org", repository.ErrOrganizationExists))
+	mockRepo.On("GetOrganization", mock.Anything, "test_org").Return(&existing, nil)
+
+	w := postOrganization(router, org, true)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Organization
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, existing.ID, response.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateOrganization_IdempotentDifferentOrgConflicts(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	router.POST("/organizations", handler.CreateOrganization)
+
+	// Mock repository
+	mockRepo.On("CreateOrganization", mock.Anything, mock.AnythingOfType("*models.Organization")).
+		Return(fmt.Errorf("%w: test_org", repository.ErrOrganizationExists))
+	mockRepo.On("GetOrganization", mock.Anything, "test_org").Return(&models.Organization{
+		OrgID:    "test_org",
+		Name:     "Test Organization",
+		Settings: models.OrgSettings{PointsPerDollar: 1.0},
+	}, nil)
+
+	w := postOrganization(router, models.Organization{
+		OrgID:    "test_org",
+		Name:     "Test Organization",
+		Settings: models.OrgSettings{PointsPerDollar: 2.0},
+	}, true)
+
+	// Assertions
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test GetOrganization
+func TestGetOrganization_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.GET("/organizations/:id", handler.GetOrganization)
+	
+	// Mock repository response
+	expectedOrg := &models.Organization{
+		OrgID:        "test_org",
+		Name:         "Test Organization",
+		Description:  "Test organization description",
+		Settings:     models.OrgSettings{},
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	
+	mockRepo.On("GetOrganization", mock.Anything, "test_org").Return(expectedOrg, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("GET", "/organizations/test_org", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response models.Organization
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_org", response.OrgID)
+	assert.Equal(t, "Test Organization", response.Name)
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetOrganization_MissingID(t *testing.T) {
+	router, _, handler := setupTest()
+	
+	// Setup route
+	router.GET("/organizations/:id", handler.GetOrganization)
+	
+	// Create request without ID
+	req, _ := http.NewRequest("GET", "/organizations/", nil)
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Test UpdateOrganization
+func patchOrganization(router *gin.Engine, orgID, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("PATCH", "/organizations/"+orgID, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateOrganization_Settings(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PATCH("/organizations/:id", handler.UpdateOrganization)
+
+	// Only the named settings are set
+	expectedUpdates := bson.M{
+		"name":                       "Renamed",
+		"settings.points_per_dollar": 2.5,
+		"settings.stamps_per_visit":  2,
+	}
+	mockRepo.On("GetOrganization", mock.Anything, "test_org").Return(&models.Organization{OrgID: "test_org"}, nil)
+	mockRepo.On("UpdateOrganization", mock.Anything, "test_org", expectedUpdates).Return(nil)
+
+	w := patchOrganization(router, "test_org", `{"name":"Renamed","settings":{"points_per_dollar":2.5,"stamps_per_visit":2}}`)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "organization updated successfully")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateOrganization_TypedSettings(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PATCH("/organizations/:id", handler.UpdateOrganization)
+
+	// Structured settings are stored as the organization's own types
+	expectedUpdates := bson.M{
+		"settings.tier_rules":      []models.TierRule{{Name: "gold", MinSpent: 1000}},
+		"settings.pending_accrual": true,
+	}
+	mockRepo.On("UpdateOrganization", mock.Anything, "test_org", expectedUpdates).Return(nil)
+
+	w := patchOrganization(router, "test_org", `{"settings":{"tier_rules":[{"name":"gold","min_spent":1000}],"pending_accrual":true}}`)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "organization updated successfully")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateOrganization_InvalidSettings(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PATCH("/organizations/:id", handler.UpdateOrganization)
+
+	for _, body := range []string{
+		`{"settings":{"points_per_dollar":-1}}`,
+		`{"settings":{"points_per_dollar":"lots"}}`,
+		`{"settings":{"stamps_per_visit":-2}}`,
+		`{"settings":{"stamps_per_visit":1.5}}`,
+		`{"settings":{"stamps_per_visit":500}}`,
+		`{"settings.stamps_per_visit":500}`,
+		`{"settings":{"stamps_per_visit":5,"max_stamps_per_transaction":3}}`,
+		`{"settings":{"stamps_per_visit":0,"max_stamps_per_transaction":-1}}`,
+		`{"settings":"none"}`,
+		`{"settings":{"tier_rules":"x"}}`,
+		`{"settings":{"tier_rules":[{"name":"gold","extra":1}]}}`,
+		`{"settings":{"pending_accrual":"yes"}}`,
+		`{"settings":{"no_such_setting":1}}`,
+		`{"org_id":"other_org"}`,
+		`{"status":"deleted"}`,
+		`{"name":7}`,
+		`{}`,
+	} {
+		w := patchOrganization(router, "test_org", body)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	mockRepo.AssertNotCalled(t, "UpdateOrganization", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateOrganization_StampCapAgainstStoredSettings(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PATCH("/organizations/:id", handler.UpdateOrganization)
+
+	mockRepo.On("GetOrganization", mock.Anything, "test_org").Return(&models.Organization{
+		OrgID:    "test_org",
+		Settings: models.OrgSettings{StampsPerVisit: 4, MaxStampsPerTransaction: 6},
+	}, nil)
+	mockRepo.On("UpdateOrganization", mock.Anything, "test_org", bson.M{"settings.stamps_per_visit": 6}).Return(nil)
+
+	// Assertions - each setting is checked against the other's stored value
+	assert.Equal(t, http.StatusBadRequest, patchOrganization(router, "test_org", `{"settings":{"stamps_per_visit":7}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, patchOrganization(router, "test_org", `{"settings":{"max_stamps_per_transaction":3}}`).Code)
+	assert.Equal(t, http.StatusOK, patchOrganization(router, "test_org", `{"settings":{"stamps_per_visit":6}}`).Code)
+	mockRepo.AssertNumberOfCalls(t, "UpdateOrganization", 1)
+}
+
+func TestUpdateOrganization_StampCapOrgNotFound(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PATCH("/organizations/:id", handler.UpdateOrganization)
+
+	mockRepo.On("GetOrganization", mock.Anything, "missing_org").
+		Return(nil, fmt.Errorf("%w: missing_org", repository.ErrOrganizationNotFound))
+
+	w := patchOrganization(router, "missing_org", `{"settings":{"max_stamps_per_transaction":3}}`)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdateOrganization", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateOrganization_NotFound(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PATCH("/organizations/:id", handler.UpdateOrganization)
+
+	mockRepo.On("UpdateOrganization", mock.Anything, "missing_org", bson.M{"description": "New"}).
+		Return(fmt.Errorf("%w: missing_org", repository.ErrOrganizationNotFound))
+
+	w := patchOrganization(router, "missing_org", `{"description":"New"}`)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test GetAccrualRate
+func TestGetAccrualRate_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/organizations/:id/accrual-rate", handler.GetAccrualRate)
+
+	mockRepo.On("GetOrganization", mock.Anything, "test_org").Return(&models.Organization{
+		OrgID:    "test_org",
+		Settings: models.OrgSettings{PointsPerDollar: 0.5, Currency: "GBP", Locale: "en-GB", AccrualRateDisplay: "whole_points"},
+	}, nil)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/organizations/test_org/accrual-rate", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "1 pt per £2", response["description"])
+	assert.Len(t, response["examples"], 4)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAccrualRate_OrganizationNotFound(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/organizations/:id/accrual-rate", handler.GetAccrualRate)
+
+	mockRepo.On("GetOrganization", mock.Anything, "missing_org").Return(nil, assert.AnError)
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/organizations/missing_org/accrual-rate", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Test CreateLocation
+func TestCreateLocation_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
 	
 	// Setup route
 	router.POST("/locations", handler.CreateLocation)
@@ -668,6 +1816,117 @@ func TestCreateLocation_Success(t *testing.T) {
 }
 
 // Test GetLocation
+// Test CreateLocations
+func TestCreateLocations_InvalidRowDoesNotAbortBatch(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.POST("/locations/bulk", handler.CreateLocations)
+	
+	// Test data - the middle row is missing its name
+	body := `[
+		{"org_id": "test_org", "name": "Downtown"},
+		{"org_id": "test_org", "manager": "Pat"},
+		{"org_id": "test_org", "name": "Airport"}
+	]`
+	
+	// Setup expectations - only the valid rows reach the repository
+	validRows := mock.MatchedBy(func(reqs []*models.CreateLocationRequest) bool {
+		return len(reqs) == 2 && reqs[0].Name == "Downtown" && reqs[1].Name == "Airport"
+	})
+	mockRepo.On("CreateLocations", mock.Anything, validRows).Return([]models.LocationResult{
+		{Index: 0, Location: &models.Location{LocationID: "loc_1", OrgID: "test_org", Name: "Downtown"}},
+		{Index: 1, Location: &models.Location{LocationID: "loc_2", OrgID: "test_org", Name: "Airport"}},
+	}, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("POST", "/locations/bulk", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	
+	var response struct {
+		Results []models.LocationResult `json:"results"`
+		Created int                     `json:"created"`
+		Failed  int                     `json:"failed"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, 1, response.Failed)
+	assert.Len(t, response.Results, 3)
+	
+	assert.Equal(t, 0, response.Results[0].Index)
+	assert.Equal(t, "loc_1", response.Results[0].Location.LocationID)
+	assert.Equal(t, 1, response.Results[1].Index)
+	assert.Nil(t, response.Results[1].Location)
+	assert.Contains(t, response.Results[1].Error, "Name")
+	assert.Equal(t, 2, response.Results[2].Index)
+	assert.Equal(t, "loc_2", response.Results[2].Location.LocationID)
+	
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLocations_AllCreated(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+	
+	// Setup route
+	router.POST("/locations/bulk", handler.CreateLocations)
+	
+	mockRepo.On("CreateLocations", mock.Anything, mock.Anything).Return([]models.LocationResult{
+		{Index: 0, Location: &models.Location{LocationID: "loc_1", Name: "Downtown"}},
+	}, nil)
+	
+	// Create request
+	req, _ := http.NewRequest("POST", "/locations/bulk", bytes.NewBufferString(`[{"org_id": "test_org", "name": "Downtown"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	// Assertions
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateLocations_InvalidBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		error string
+	}{
+		{"not an array", `{"org_id": "test_org", "name": "Downtown"}`, "request body must be an array of locations"},
+		{"empty array", `[]`, "at least one location is required"},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mockRepo, handler := setupTest()
+			router.POST("/locations/bulk", handler.CreateLocations)
+			
+			req, _ := http.NewRequest("POST", "/locations/bulk", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.error, response["error"])
+			
+			mockRepo.AssertNotCalled(t, "CreateLocations", mock.Anything, mock.Anything)
+		})
+	}
+}
+
 func TestGetLocation_Success(t *testing.T) {
 	router, mockRepo, handler := setupTest()
 	
@@ -742,6 +2001,7 @@ func TestGetLocationsByOrg_Success(t *testing.T) {
 	}
 	
 	mockRepo.On("GetLocationsByOrg", mock.Anything, "test_org", 10, 0).Return(expectedLocations, nil)
+	mockRepo.On("CountLocationsByOrg", mock.Anything, "test_org").Return(int64(12), nil)
 	
 	// Create request
 	req, _ := http.NewRequest("GET", "/locations?org_id=test_org&limit=10&offset=0", nil)
@@ -757,6 +2017,7 @@ func TestGetLocationsByOrg_Success(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, float64(2), response["count"])
+	assert.Equal(t, float64(12), response["total"])
 	assert.Equal(t, float64(10), response["limit"])
 	assert.Equal(t, float64(0), response["offset"])
 	
@@ -766,6 +2027,27 @@ func TestGetLocationsByOrg_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetLocationsByOrg_CountError(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.GET("/locations", handler.GetLocationsByOrg)
+
+	mockRepo.On("GetLocationsByOrg", mock.Anything, "test_org", 50, 0).Return([]*models.Location{}, nil)
+	mockRepo.On("CountLocationsByOrg", mock.Anything, "test_org").Return(int64(0), fmt.Errorf("failed to count locations"))
+
+	// Create request
+	req, _ := http.NewRequest("GET", "/locations?org_id=test_org", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
 // Test UpdateLocation
 func TestUpdateLocation_Success(t *testing.T) {
 	router, mockRepo, handler := setupTest()
@@ -832,6 +2114,291 @@ func TestDeactivateLocation_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+// Test DeactivateCustomer
+func TestDeactivateCustomer_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.DELETE("/customers/:id", handler.DeactivateCustomer)
+
+	// Mock repository
+	expectedUpdates := bson.M{"status": models.CustomerStatusInactive}
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", expectedUpdates).Return(nil)
+
+	// Create request
+	req, _ := http.NewRequest("DELETE", "/customers/cust_123", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["message"], "customer deactivated successfully")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeactivateCustomer_NotFound(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.DELETE("/customers/:id", handler.DeactivateCustomer)
+
+	// Mock repository
+	mockRepo.On("UpdateCustomer", mock.Anything, "missing", bson.M{"status": models.CustomerStatusInactive}).Return(fmt.Errorf("%w: %s", repository.ErrCustomerNotFound, "missing"))
+
+	// Create request
+	req, _ := http.NewRequest("DELETE", "/customers/missing", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test ReactivateCustomer
+func TestReactivateCustomer_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/customers/:id/reactivate", handler.ReactivateCustomer)
+
+	// Mock repository
+	expectedUpdates := bson.M{"status": models.CustomerStatusActive}
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", expectedUpdates).Return(nil)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/customers/cust_123/reactivate", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["message"], "customer reactivated successfully")
+
+	mockRepo.AssertExpectations(t)
+}
+
+// Test SetCustomerMultiplier
+func TestSetCustomerMultiplier_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PUT("/customers/:id/multiplier", handler.SetCustomerMultiplier)
+
+	expiresAt := time.Now().Add(7 * 24 * time.Hour).UTC().Truncate(time.Second)
+	expectedUpdates := bson.M{"custom_multiplier": 2.0, "multiplier_expires_at": expiresAt}
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", expectedUpdates).Return(nil)
+
+	// Create request
+	body, _ := json.Marshal(models.SetMultiplierRequest{Multiplier: 2.0, ExpiresAt: expiresAt})
+	req, _ := http.NewRequest("PUT", "/customers/cust_123/multiplier", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetCustomerMultiplier_InvalidRequest(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.PUT("/customers/:id/multiplier", handler.SetCustomerMultiplier)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	for _, body := range []string{
+		`{"multiplier":2}`,
+		`{"expires_at":"` + future + `"}`,
+		`{"multiplier":-1,"expires_at":"` + future + `"}`,
+		`{"multiplier":2,"expires_at":"` + past + `"}`,
+	} {
+		// Create request
+		req, _ := http.NewRequest("PUT", "/customers/cust_123/multiplier", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		// Record response
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	mockRepo.AssertNotCalled(t, "UpdateCustomer", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Test ClearCustomerMultiplier
+func TestClearCustomerMultiplier_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.DELETE("/customers/:id/multiplier", handler.ClearCustomerMultiplier)
+
+	expectedUpdates := bson.M{"custom_multiplier": 0, "multiplier_expires_at": nil}
+	mockRepo.On("UpdateCustomer", mock.Anything, "cust_123", expectedUpdates).Return(nil)
+
+	// Create request
+	req, _ := http.NewRequest("DELETE", "/customers/cust_123/multiplier", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "customer multiplier cleared successfully")
+	mockRepo.AssertExpectations(t)
+}
+
+// Test AddCustomerTags
+func TestAddCustomerTags_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/customers/:id/tags", handler.AddCustomerTags)
+
+	// Setup expectations
+	mockRepo.On("AddCustomerTags", mock.Anything, "cust_123", []string{"vip", "beta-tester"}).Return(nil)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/customers/cust_123/tags", bytes.NewBufferString(`{"tags":[" VIP","beta-tester"]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "customer tags added successfully", response["message"])
+	assert.Equal(t, []interface{}{"vip", "beta-tester"}, response["tags"])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddCustomerTags_DuplicateTag(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/customers/:id/tags", handler.AddCustomerTags)
+
+	// The customer already has the tag, which the repository leaves alone
+	mockRepo.On("AddCustomerTags", mock.Anything, "cust_123", []string{"vip"}).Return(nil).Twice()
+
+	for i := 0; i < 2; i++ {
+		// Create request
+		req, _ := http.NewRequest("POST", "/customers/cust_123/tags", bytes.NewBufferString(`{"tags":["vip"]}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		// Record response
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddCustomerTags_InvalidTags(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/customers/:id/tags", handler.AddCustomerTags)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no tags", `{"tags":[]}`},
+		{"blank tag", `{"tags":["vip","  "]}`},
+		{"too long", fmt.Sprintf(`{"tags":["%s"]}`, strings.Repeat("a", 65))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create request
+			req, _ := http.NewRequest("POST", "/customers/cust_123/tags", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Record response
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assertions
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	mockRepo.AssertNotCalled(t, "AddCustomerTags", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddCustomerTags_CustomerNotFound(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.POST("/customers/:id/tags", handler.AddCustomerTags)
+
+	// Setup expectations
+	notFound := fmt.Errorf("%w: %s", repository.ErrCustomerNotFound, "missing")
+	mockRepo.On("AddCustomerTags", mock.Anything, "missing", []string{"vip"}).Return(notFound)
+
+	// Create request
+	req, _ := http.NewRequest("POST", "/customers/missing/tags", bytes.NewBufferString(`{"tags":["vip"]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Test RemoveCustomerTag
+func TestRemoveCustomerTag_Success(t *testing.T) {
+	router, mockRepo, handler := setupTest()
+
+	// Setup route
+	router.DELETE("/customers/:id/tags/:tag", handler.RemoveCustomerTag)
+
+	// Setup expectations
+	mockRepo.On("RemoveCustomerTag", mock.Anything, "cust_123", "beta-tester").Return(nil)
+
+	// Create request
+	req, _ := http.NewRequest("DELETE", "/customers/cust_123/tags/Beta-Tester", nil)
+
+	// Record response
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "customer tag removed successfully")
+	mockRepo.AssertExpectations(t)
+}
+
 // Test Health
 func TestHealth_Success(t *testing.T) {
 	router, _, handler := setupTest()
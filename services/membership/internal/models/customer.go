@@ -5,6 +5,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Customer statuses. Deleting a customer only marks them inactive, so their
+// history is kept and they can be reactivated.
+const (
+	CustomerStatusActive   = "active"
+	CustomerStatusInactive = "inactive"
+)
+
 type Customer struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	CustomerID   string            `bson:"customer_id" json:"customer_id"`
@@ -19,6 +26,17 @@ type Customer struct {
 	Tier         string            `bson:"tier" json:"tier"`
 	Status       string            `bson:"status" json:"status"`
 	Metadata     map[string]any    `bson:"metadata" json:"metadata"`
+	// Tags label the customer for targeting, such as "vip". They are
+	// stored lower case and added or removed one at a time.
+	Tags         []string          `bson:"tags,omitempty" json:"tags,omitempty"`
+	// BalanceAlertsSent holds the last alert sent of each kind, so a
+	// condition that persists between scans is only announced once
+	BalanceAlertsSent map[string]string `bson:"balance_alerts_sent,omitempty" json:"balance_alerts_sent,omitempty"`
+	// CustomMultiplier is a staff-set points multiplier, for VIPs or to settle
+	// disputes. The stream processor stacks it with tier and promotion
+	// multipliers until MultiplierExpiresAt.
+	CustomMultiplier    float64    `bson:"custom_multiplier,omitempty" json:"custom_multiplier,omitempty"`
+	MultiplierExpiresAt *time.Time `bson:"multiplier_expires_at,omitempty" json:"multiplier_expires_at,omitempty"`
 	CreatedAt    time.Time         `bson:"created_at" json:"created_at"`
 	UpdatedAt    time.Time         `bson:"updated_at" json:"updated_at"`
 }
@@ -36,6 +54,39 @@ type CustomerPrefs struct {
 	SMSMarketing   bool     `bson:"sms_marketing" json:"sms_marketing"`
 	Categories     []string `bson:"categories" json:"categories"`
 	Language       string   `bson:"language" json:"language"`
+	// BalanceAlerts opts the customer in to notices about expiring points
+	// and nearly complete stamp cards. The brand may set it on their behalf.
+	BalanceAlerts  bool     `bson:"balance_alerts" json:"balance_alerts"`
+}
+
+// DefaultConsentPreferences are the preferences whose changes are kept in a
+// customer's consent history unless the service is configured otherwise
+var DefaultConsentPreferences = []string{"email_marketing", "sms_marketing", "balance_alerts"}
+
+// ConsentChange records one change to a customer's marketing consent. The
+// history is append-only, so entries are never updated or removed.
+type ConsentChange struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CustomerID string            `bson:"customer_id" json:"customer_id"`
+	OrgID      string            `bson:"org_id" json:"org_id"`
+	Preference string            `bson:"preference" json:"preference"`
+	OldValue   bool              `bson:"old_value" json:"old_value"`
+	NewValue   bool              `bson:"new_value" json:"new_value"`
+	// ChangedBy identifies who made the change, from the X-Changed-By
+	// request header. It is empty when the caller did not say.
+	ChangedBy  string            `bson:"changed_by,omitempty" json:"changed_by,omitempty"`
+	ChangedAt  time.Time         `bson:"changed_at" json:"changed_at"`
+}
+
+// TargetingFilter selects an org's customers by their preferences when
+// building a campaign. Unset fields match every customer.
+type TargetingFilter struct {
+	EmailOptIn *bool  `json:"email_opt_in,omitempty"`
+	SMSOptIn   *bool  `json:"sms_opt_in,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Tier       string `json:"tier,omitempty"`
+	Tag        string `json:"tag,omitempty"`
 }
 
 type Location struct {
@@ -46,6 +97,7 @@ type Location struct {
 	Address     Address           `bson:"address" json:"address"`
 	Manager     string            `bson:"manager" json:"manager"`
 	Settings    LocationSettings  `bson:"settings" json:"settings"`
+	OperatingHours OperatingHours `bson:"operating_hours" json:"operating_hours"`
 	Active      bool              `bson:"active" json:"active"`
 	CreatedAt   time.Time         `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time         `bson:"updated_at" json:"updated_at"`
@@ -55,6 +107,32 @@ type LocationSettings struct {
 	PointsMultiplier float64           `bson:"points_multiplier" json:"points_multiplier"`
 	CustomRewards    []RewardThreshold `bson:"custom_rewards" json:"custom_rewards"`
 	AllowStamps      bool              `bson:"allow_stamps" json:"allow_stamps"`
+	TimePromotions   []TimePromotion   `bson:"time_promotions" json:"time_promotions"`
+}
+
+// OperatingHours holds a location's timezone and weekly opening times. An
+// empty timezone means UTC, and no days means the location is always open.
+type OperatingHours struct {
+	Timezone string     `bson:"timezone" json:"timezone"`
+	Days     []DayHours `bson:"days" json:"days"`
+}
+
+// DayHours is one day's opening times as "HH:MM" in the location's local
+// time. A close before open runs past midnight.
+type DayHours struct {
+	Day   string `bson:"day" json:"day"`
+	Open  string `bson:"open" json:"open"`
+	Close string `bson:"close" json:"close"`
+}
+
+// TimePromotion multiplies points earned between Start and End local store
+// time, such as a 15:00-17:00 happy hour. No days means every day.
+type TimePromotion struct {
+	Name             string   `bson:"name" json:"name"`
+	Days             []string `bson:"days" json:"days"`
+	Start            string   `bson:"start" json:"start"`
+	End              string   `bson:"end" json:"end"`
+	PointsMultiplier float64  `bson:"points_multiplier" json:"points_multiplier"`
 }
 
 type CreateCustomerRequest struct {
@@ -75,4 +153,25 @@ type CreateLocationRequest struct {
 	Address  Address          `json:"address"`
 	Manager  string           `json:"manager"`
 	Settings LocationSettings `json:"settings"`
-}
\ No newline at end of file
+	OperatingHours OperatingHours `json:"operating_hours"`
+}
+
+// LocationResult is the outcome of one row of a bulk location create. Index
+// is the row's position in the request.
+type LocationResult struct {
+	Index    int       `json:"index"`
+	Location *Location `json:"location,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AddTagsRequest adds tags to a customer. Tags are trimmed and lower cased.
+type AddTagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1"`
+}
+
+// SetMultiplierRequest sets a customer's custom points multiplier until
+// ExpiresAt, which must be in the future
+type SetMultiplierRequest struct {
+	Multiplier float64   `json:"multiplier" binding:"required,gt=0"`
+	ExpiresAt  time.Time `json:"expires_at" binding:"required"`
+}
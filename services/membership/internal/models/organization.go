@@ -21,14 +21,77 @@ type OrgSettings struct {
 	RewardThresholds   []RewardThreshold `bson:"reward_thresholds" json:"reward_thresholds"`
 	TierRules          []TierRule        `bson:"tier_rules" json:"tier_rules"`
 	MaxStampsPerCard   int               `bson:"max_stamps_per_card" json:"max_stamps_per_card"`
+	// MaxStampsPerTransaction caps the stamps one POS transaction awards, so a
+	// single purchase cannot complete several cards; zero means no cap.
+	// StampsPerVisit above it is rejected when settings are saved.
+	MaxStampsPerTransaction int          `bson:"max_stamps_per_transaction" json:"max_stamps_per_transaction"`
+	// RewardMode is "all" (default) to emit every qualifying reward, or
+	// "highest" to emit only the highest qualifying threshold
+	RewardMode         string            `bson:"reward_mode" json:"reward_mode"`
+	// RewardThresholdBasis is "lifetime" (default) to trigger a threshold when
+	// the customer's ledger balance crosses it, or "per_event" to compare it
+	// against what a single event earned
+	RewardThresholdBasis string          `bson:"reward_threshold_basis" json:"reward_threshold_basis"`
+	// RedemptionBonuses credit extra points when specific rewards are
+	// redeemed, in the same ledger operation as the redemption
+	RedemptionBonuses  []RedemptionBonus `bson:"redemption_bonuses" json:"redemption_bonuses"`
+	// Currency is an ISO 4217 code and Locale a BCP 47 tag such as "de-DE".
+	// They default to USD and en-US when rendering reward descriptions.
+	Currency           string            `bson:"currency" json:"currency"`
+	Locale             string            `bson:"locale" json:"locale"`
+	// UnknownLocationPolicy is "lenient" (default) to process POS events
+	// whose location does not exist in the org with a warning, or "strict"
+	// to reject them
+	UnknownLocationPolicy string         `bson:"unknown_location_policy" json:"unknown_location_policy"`
+	// CategoryMultipliers scale the points earned on line items by category,
+	// e.g. {"beverages": 1.5}; other categories earn the base rate
+	CategoryMultipliers map[string]float64 `bson:"category_multipliers" json:"category_multipliers"`
+	// RoundingGranularity is "transaction" (default) to round down the
+	// basket's total points once, or "item" to round down each line item's
+	// points before summing them
+	RoundingGranularity string           `bson:"rounding_granularity" json:"rounding_granularity"`
+	// PointsExpiryDays is how long each accrual's points last from when they
+	// are credited; zero means they never expire. Customers who opted in to
+	// balance alerts are warned BalanceAlertLeadDays beforehand (default 7).
+	PointsExpiryDays     int             `bson:"points_expiry_days" json:"points_expiry_days"`
+	BalanceAlertLeadDays int             `bson:"balance_alert_lead_days" json:"balance_alert_lead_days"`
+	// DailyPointsCeiling is the most points the org's POS transactions may
+	// accrue in a UTC day before an alert is raised; zero disables it. With
+	// PauseAccrualAtCeiling, accrual also stops for the rest of the day.
+	DailyPointsCeiling    int            `bson:"daily_points_ceiling" json:"daily_points_ceiling"`
+	PauseAccrualAtCeiling bool           `bson:"pause_accrual_at_ceiling" json:"pause_accrual_at_ceiling"`
+	// InactiveCustomerPolicy is "skip" (default) to earn nothing on POS
+	// transactions of customers whose status is not active, or "flag" to
+	// award them as usual and mark the result
+	InactiveCustomerPolicy string        `bson:"inactive_customer_policy" json:"inactive_customer_policy"`
+	// AccrualRateDisplay is "per_unit" (default) to describe the accrual rate
+	// as points per unit of currency, which may be fractional, or
+	// "whole_points" as the smallest spend earning whole points
+	AccrualRateDisplay string            `bson:"accrual_rate_display" json:"accrual_rate_display"`
+	// PendingAccrual holds POS points as pending in the ledger, so they cannot
+	// be spent, until a pos.settlement event posts or voids them. With
+	// SettlementDelayHours the ledger also posts them after that many hours.
+	PendingAccrual       bool            `bson:"pending_accrual" json:"pending_accrual"`
+	SettlementDelayHours int             `bson:"settlement_delay_hours" json:"settlement_delay_hours"`
+}
+
+type RedemptionBonus struct {
+	RewardID    string `bson:"reward_id" json:"reward_id"`
+	BonusPoints int    `bson:"bonus_points" json:"bonus_points"`
 }
 
 type RewardThreshold struct {
+	// RewardID names the reward in the org's catalog so it can be redeemed by
+	// ID. It defaults to reward_<points>_<stamps>.
+	RewardID    string `bson:"reward_id,omitempty" json:"reward_id,omitempty"`
 	Points      int    `bson:"points" json:"points"`
 	Stamps      int    `bson:"stamps" json:"stamps"`
 	RewardType  string `bson:"reward_type" json:"reward_type"`
 	RewardValue string `bson:"reward_value" json:"reward_value"`
+	// Description may contain {amount}, which is replaced with Amount
+	// formatted in the org's currency and locale, e.g. "{amount} voucher"
 	Description string `bson:"description" json:"description"`
+	Amount      float64 `bson:"amount" json:"amount"`
 }
 
 type TierRule struct {
@@ -2,21 +2,56 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
+
 	"github.com/loyalty/membership/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// ErrCustomerEmailExists is returned when creating a customer whose email is
+// already registered in the organization
+var ErrCustomerEmailExists = errors.New("customer with this email already exists in organization")
+
+// ErrCustomerNotFound is returned when getting, updating or tagging a
+// customer that does not exist
+var ErrCustomerNotFound = errors.New("customer not found")
+
+// ErrOrganizationExists is returned when creating an organization whose
+// org_id is already taken
+var ErrOrganizationExists = errors.New("organization already exists")
+
+// ErrOrganizationNotFound is returned when getting or updating an
+// organization that does not exist
+var ErrOrganizationNotFound = errors.New("organization not found")
+
 // MongoRepoInterface defines the interface for MongoDB repository operations
 type MongoRepoInterface interface {
 	CreateCustomer(ctx context.Context, req *models.CreateCustomerRequest) (*models.Customer, error)
 	GetCustomer(ctx context.Context, customerID string) (*models.Customer, error)
 	GetCustomersByOrg(ctx context.Context, orgID string, limit, offset int) ([]*models.Customer, error)
+	CountCustomersByOrg(ctx context.Context, orgID string) (int64, error)
+	GetCustomersCreatedBetween(ctx context.Context, orgID string, from, to time.Time, limit, offset int) ([]*models.Customer, error)
+	CountCustomersCreatedBetween(ctx context.Context, orgID string, from, to time.Time) (int64, error)
+	GetCustomersByTier(ctx context.Context, orgID, tier string, limit, offset int) ([]*models.Customer, error)
+	CountCustomersByTier(ctx context.Context, orgID, tier string) (int64, error)
+	SearchCustomers(ctx context.Context, orgID, email, phone string) ([]*models.Customer, error)
+	GetCustomersByPreferences(ctx context.Context, orgID string, filter models.TargetingFilter, limit, offset int) ([]*models.Customer, error)
+	CountCustomersByPreferences(ctx context.Context, orgID string, filter models.TargetingFilter) (int64, error)
+	GetBalanceAlertCustomers(ctx context.Context, limit, offset int) ([]*models.Customer, error)
 	UpdateCustomer(ctx context.Context, customerID string, updates bson.M) error
+	AddCustomerTags(ctx context.Context, customerID string, tags []string) error
+	RemoveCustomerTag(ctx context.Context, customerID, tag string) error
+	AppendConsentHistory(ctx context.Context, changes []models.ConsentChange) error
+	GetConsentHistory(ctx context.Context, customerID string, limit, offset int) ([]*models.ConsentChange, error)
 	CreateOrganization(ctx context.Context, org *models.Organization) error
 	GetOrganization(ctx context.Context, orgID string) (*models.Organization, error)
+	UpdateOrganization(ctx context.Context, orgID string, updates bson.M) error
 	CreateLocation(ctx context.Context, req *models.CreateLocationRequest) (*models.Location, error)
+	CreateLocations(ctx context.Context, reqs []*models.CreateLocationRequest) ([]models.LocationResult, error)
 	GetLocation(ctx context.Context, locationID string) (*models.Location, error)
 	GetLocationsByOrg(ctx context.Context, orgID string, limit, offset int) ([]*models.Location, error)
+	CountLocationsByOrg(ctx context.Context, orgID string) (int64, error)
 	UpdateLocation(ctx context.Context, locationID string, updates bson.M) error
 	Close() error
 } 
\ No newline at end of file
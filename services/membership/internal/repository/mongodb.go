@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -47,11 +48,18 @@ func (r *MongoRepo) createIndexes() error {
 	customersCollection := r.database.Collection("customers")
 	orgsCollection := r.database.Collection("organizations")
 	locationsCollection := r.database.Collection("locations")
+	consentCollection := r.database.Collection("consent_history")
 
 	customerIndexes := []mongo.IndexModel{
 		{Keys: bson.D{{"customer_id", 1}}, Options: options.Index().SetUnique(true)},
 		{Keys: bson.D{{"org_id", 1}, {"email", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"org_id", 1}, {"phone", 1}}},
 		{Keys: bson.D{{"org_id", 1}}},
+		{Keys: bson.D{{"org_id", 1}, {"created_at", -1}}},
+		{Keys: bson.D{{"org_id", 1}, {"tier", 1}, {"created_at", -1}}, Options: options.Index().SetCollation(tierCollation)},
+		{Keys: bson.D{{"preferences.balance_alerts", 1}, {"customer_id", 1}}},
+		{Keys: bson.D{{"org_id", 1}, {"preferences.categories", 1}}, Options: options.Index().SetCollation(tierCollation)},
+		{Keys: bson.D{{"org_id", 1}, {"tags", 1}}, Options: options.Index().SetCollation(tierCollation)},
 	}
 
 	orgIndexes := []mongo.IndexModel{
@@ -63,6 +71,10 @@ func (r *MongoRepo) createIndexes() error {
 		{Keys: bson.D{{"org_id", 1}}},
 	}
 
+	consentIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{"customer_id", 1}, {"changed_at", -1}}},
+	}
+
 	if _, err := customersCollection.Indexes().CreateMany(ctx, customerIndexes); err != nil {
 		return err
 	}
@@ -75,6 +87,10 @@ func (r *MongoRepo) createIndexes() error {
 		return err
 	}
 
+	if _, err := consentCollection.Indexes().CreateMany(ctx, consentIndexes); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -100,6 +116,9 @@ func (r *MongoRepo) CreateCustomer(ctx context.Context, req *models.CreateCustom
 
 	collection := r.database.Collection("customers")
 	result, err := collection.InsertOne(ctx, customer)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("%w %s", ErrCustomerEmailExists, req.OrgID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create customer: %w", err)
 	}
@@ -115,7 +134,7 @@ func (r *MongoRepo) GetCustomer(ctx context.Context, customerID string) (*models
 	err := collection.FindOne(ctx, bson.M{"customer_id": customerID}).Decode(&customer)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("customer not found")
+			return nil, ErrCustomerNotFound
 		}
 		return nil, fmt.Errorf("failed to get customer: %w", err)
 	}
@@ -149,6 +168,254 @@ func (r *MongoRepo) GetCustomersByOrg(ctx context.Context, orgID string, limit,
 	return customers, nil
 }
 
+// CountCustomersByOrg counts all of an org's customers, the total that
+// GetCustomersByOrg pages through
+func (r *MongoRepo) CountCustomersByOrg(ctx context.Context, orgID string) (int64, error) {
+	total, err := r.database.Collection("customers").CountDocuments(ctx, bson.M{"org_id": orgID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count customers: %w", err)
+	}
+	return total, nil
+}
+
+// createdRangeQuery matches an org's customers created at or after from and
+// before to. A zero bound leaves that side of the range open.
+func createdRangeQuery(orgID string, from, to time.Time) bson.M {
+	query := bson.M{"org_id": orgID}
+	created := bson.M{}
+	if !from.IsZero() {
+		created["$gte"] = from
+	}
+	if !to.IsZero() {
+		created["$lt"] = to
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+	return query
+}
+
+// GetCustomersCreatedBetween pages through an org's customers created in
+// [from, to), newest first
+func (r *MongoRepo) GetCustomersCreatedBetween(ctx context.Context, orgID string, from, to time.Time, limit, offset int) ([]*models.Customer, error) {
+	collection := r.database.Collection("customers")
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetSort(bson.D{{"created_at", -1}})
+
+	cursor, err := collection.Find(ctx, createdRangeQuery(orgID, from, to), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find customers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var customers []*models.Customer
+	for cursor.Next(ctx) {
+		var customer models.Customer
+		if err := cursor.Decode(&customer); err != nil {
+			return nil, fmt.Errorf("failed to decode customer: %w", err)
+		}
+		customers = append(customers, &customer)
+	}
+
+	return customers, nil
+}
+
+// CountCustomersCreatedBetween counts an org's customers created in
+// [from, to), the total that GetCustomersCreatedBetween pages through
+func (r *MongoRepo) CountCustomersCreatedBetween(ctx context.Context, orgID string, from, to time.Time) (int64, error) {
+	total, err := r.database.Collection("customers").CountDocuments(ctx, createdRangeQuery(orgID, from, to))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count customers: %w", err)
+	}
+	return total, nil
+}
+
+// GetBalanceAlertCustomers pages through active customers in every org who
+// opted in to balance alerts, in customer_id order so a scan is not thrown
+// off by customers signing up while it runs
+func (r *MongoRepo) GetBalanceAlertCustomers(ctx context.Context, limit, offset int) ([]*models.Customer, error) {
+	collection := r.database.Collection("customers")
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetSort(bson.D{{"customer_id", 1}})
+
+	filter := bson.M{
+		"preferences.balance_alerts": true,
+		"status":                     "active",
+	}
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find customers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var customers []*models.Customer
+	for cursor.Next(ctx) {
+		var customer models.Customer
+		if err := cursor.Decode(&customer); err != nil {
+			return nil, fmt.Errorf("failed to decode customer: %w", err)
+		}
+		customers = append(customers, &customer)
+	}
+
+	return customers, nil
+}
+
+// tierCollation compares tiers case-insensitively, since new customers start
+// as "bronze" while updates may store "Gold". The org_id+tier index uses the
+// same collation so tier queries can use it.
+var tierCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// CountCustomersByTier counts an org's customers in tier, the total that
+// GetCustomersByTier pages through
+func (r *MongoRepo) CountCustomersByTier(ctx context.Context, orgID, tier string) (int64, error) {
+	total, err := r.database.Collection("customers").CountDocuments(ctx,
+		bson.M{"org_id": orgID, "tier": tier},
+		options.Count().SetCollation(tierCollation))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count customers: %w", err)
+	}
+	return total, nil
+}
+
+func (r *MongoRepo) GetCustomersByTier(ctx context.Context, orgID, tier string, limit, offset int) ([]*models.Customer, error) {
+	collection := r.database.Collection("customers")
+	
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetSort(bson.D{{"created_at", -1}}).
+		SetCollation(tierCollation)
+
+	cursor, err := collection.Find(ctx, bson.M{"org_id": orgID, "tier": tier}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find customers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var customers []*models.Customer
+	for cursor.Next(ctx) {
+		var customer models.Customer
+		if err := cursor.Decode(&customer); err != nil {
+			return nil, fmt.Errorf("failed to decode customer: %w", err)
+		}
+		customers = append(customers, &customer)
+	}
+
+	return customers, nil
+}
+
+// SearchCustomers finds an org's customers by exact email, phone, or both.
+// An empty email or phone is not filtered on; callers give at least one.
+func (r *MongoRepo) SearchCustomers(ctx context.Context, orgID, email, phone string) ([]*models.Customer, error) {
+	collection := r.database.Collection("customers")
+
+	query := bson.M{"org_id": orgID}
+	if email != "" {
+		query["email"] = email
+	}
+	if phone != "" {
+		query["phone"] = phone
+	}
+
+	cursor, err := collection.Find(ctx, query, options.Find().SetSort(bson.D{{"created_at", -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search customers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var customers []*models.Customer
+	for cursor.Next(ctx) {
+		var customer models.Customer
+		if err := cursor.Decode(&customer); err != nil {
+			return nil, fmt.Errorf("failed to decode customer: %w", err)
+		}
+		customers = append(customers, &customer)
+	}
+
+	return customers, nil
+}
+
+// GetCustomersByPreferences pages through an org's customers who match filter,
+// newest first. Categories, language and tier compare
+// case-insensitively.
+func (r *MongoRepo) GetCustomersByPreferences(ctx context.Context, orgID string, filter models.TargetingFilter, limit, offset int) ([]*models.Customer, error) {
+	collection := r.database.Collection("customers")
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetSort(bson.D{{"created_at", -1}}).
+		SetCollation(tierCollation)
+
+	cursor, err := collection.Find(ctx, targetingQuery(orgID, filter), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find customers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var customers []*models.Customer
+	for cursor.Next(ctx) {
+		var customer models.Customer
+		if err := cursor.Decode(&customer); err != nil {
+			return nil, fmt.Errorf("failed to decode customer: %w", err)
+		}
+		customers = append(customers, &customer)
+	}
+
+	return customers, nil
+}
+
+// CountCustomersByPreferences counts an org's customers who match filter, the
+// total that GetCustomersByPreferences pages through
+func (r *MongoRepo) CountCustomersByPreferences(ctx context.Context, orgID string, filter models.TargetingFilter) (int64, error) {
+	total, err := r.database.Collection("customers").CountDocuments(ctx,
+		targetingQuery(orgID, filter),
+		options.Count().SetCollation(tierCollation))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count customers: %w", err)
+	}
+	return total, nil
+}
+
+// targetingQuery builds the customers query for filter. Filtering on
+// marketing preferences targets only active customers, since campaigns
+// should not reach closed accounts; a tag or tier on its own lists every
+// customer that has it, like the unfiltered listing.
+func targetingQuery(orgID string, filter models.TargetingFilter) bson.M {
+	query := bson.M{"org_id": orgID}
+	if filter.EmailOptIn != nil || filter.SMSOptIn != nil || filter.Category != "" || filter.Language != "" {
+		query["status"] = "active"
+	}
+	if filter.EmailOptIn != nil {
+		query["preferences.email_marketing"] = *filter.EmailOptIn
+	}
+	if filter.SMSOptIn != nil {
+		query["preferences.sms_marketing"] = *filter.SMSOptIn
+	}
+	if filter.Category != "" {
+		// Matches when categories contains the value
+		query["preferences.categories"] = filter.Category
+	}
+	if filter.Language != "" {
+		query["preferences.language"] = filter.Language
+	}
+	if filter.Tier != "" {
+		query["tier"] = filter.Tier
+	}
+	if filter.Tag != "" {
+		// Matches when tags contains the value
+		query["tags"] = filter.Tag
+	}
+	return query
+}
+
 func (r *MongoRepo) UpdateCustomer(ctx context.Context, customerID string, updates bson.M) error {
 	collection := r.database.Collection("customers")
 	
@@ -164,18 +431,99 @@ func (r *MongoRepo) UpdateCustomer(ctx context.Context, customerID string, updat
 	}
 
 	if result.MatchedCount == 0 {
-		return fmt.Errorf("customer not found")
+		return fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
+	}
+
+	return nil
+}
+
+// AddCustomerTags adds tags to a customer. Tags the customer already has are
+// left as they are, so adding one twice is a no-op.
+func (r *MongoRepo) AddCustomerTags(ctx context.Context, customerID string, tags []string) error {
+	return r.updateCustomerTags(ctx, customerID, bson.M{
+		"$addToSet": bson.M{"tags": bson.M{"$each": tags}},
+		"$set":      bson.M{"updated_at": time.Now()},
+	})
+}
+
+// RemoveCustomerTag removes a tag from a customer, if they have it
+func (r *MongoRepo) RemoveCustomerTag(ctx context.Context, customerID, tag string) error {
+	return r.updateCustomerTags(ctx, customerID, bson.M{
+		"$pull": bson.M{"tags": tag},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+}
+
+func (r *MongoRepo) updateCustomerTags(ctx context.Context, customerID string, update bson.M) error {
+	collection := r.database.Collection("customers")
+
+	result, err := collection.UpdateOne(ctx, bson.M{"customer_id": customerID}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update customer tags: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s", ErrCustomerNotFound, customerID)
 	}
 
 	return nil
 }
 
+// AppendConsentHistory records changes to customers' marketing consent
+func (r *MongoRepo) AppendConsentHistory(ctx context.Context, changes []models.ConsentChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(changes))
+	for i, change := range changes {
+		documents[i] = change
+	}
+
+	collection := r.database.Collection("consent_history")
+	if _, err := collection.InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to record consent history: %w", err)
+	}
+
+	return nil
+}
+
+// GetConsentHistory pages through a customer's consent changes, newest first
+func (r *MongoRepo) GetConsentHistory(ctx context.Context, customerID string, limit, offset int) ([]*models.ConsentChange, error) {
+	collection := r.database.Collection("consent_history")
+
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset)).
+		SetSort(bson.D{{"changed_at", -1}})
+
+	cursor, err := collection.Find(ctx, bson.M{"customer_id": customerID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find consent history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var changes []*models.ConsentChange
+	for cursor.Next(ctx) {
+		var change models.ConsentChange
+		if err := cursor.Decode(&change); err != nil {
+			return nil, fmt.Errorf("failed to decode consent change: %w", err)
+		}
+		changes = append(changes, &change)
+	}
+
+	return changes, nil
+}
+
 func (r *MongoRepo) CreateOrganization(ctx context.Context, org *models.Organization) error {
 	org.CreatedAt = time.Now()
 	org.UpdatedAt = time.Now()
 	
 	collection := r.database.Collection("organizations")
 	result, err := collection.InsertOne(ctx, org)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: %s", ErrOrganizationExists, org.OrgID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create organization: %w", err)
 	}
@@ -191,7 +539,7 @@ func (r *MongoRepo) GetOrganization(ctx context.Context, orgID string) (*models.
 	err := collection.FindOne(ctx, bson.M{"org_id": orgID}).Decode(&org)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("organization not found")
+			return nil, fmt.Errorf("%w: %s", ErrOrganizationNotFound, orgID)
 		}
 		return nil, fmt.Errorf("failed to get organization: %w", err)
 	}
@@ -199,6 +547,29 @@ func (r *MongoRepo) GetOrganization(ctx context.Context, orgID string) (*models.
 	return &org, nil
 }
 
+// UpdateOrganization sets updates on the organization. Settings can be
+// changed field by field with dotted keys such as settings.points_per_dollar.
+func (r *MongoRepo) UpdateOrganization(ctx context.Context, orgID string, updates bson.M) error {
+	collection := r.database.Collection("organizations")
+
+	updates["updated_at"] = time.Now()
+
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{"org_id": orgID},
+		bson.M{"$set": updates},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s", ErrOrganizationNotFound, orgID)
+	}
+
+	return nil
+}
+
 // Location Management Methods
 
 func (r *MongoRepo) CreateLocation(ctx context.Context, req *models.CreateLocationRequest) (*models.Location, error) {
@@ -211,6 +582,7 @@ func (r *MongoRepo) CreateLocation(ctx context.Context, req *models.CreateLocati
 		Address:    req.Address,
 		Manager:    req.Manager,
 		Settings:   req.Settings,
+		OperatingHours: req.OperatingHours,
 		Active:     true,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
@@ -226,6 +598,55 @@ func (r *MongoRepo) CreateLocation(ctx context.Context, req *models.CreateLocati
 	return location, nil
 }
 
+// CreateLocations inserts every location in one unordered write, so a row
+// that fails does not stop the rest. The returned results line up with reqs;
+// an error is returned only when the write fails as a whole.
+func (r *MongoRepo) CreateLocations(ctx context.Context, reqs []*models.CreateLocationRequest) ([]models.LocationResult, error) {
+	results := make([]models.LocationResult, len(reqs))
+	if len(reqs) == 0 {
+		return results, nil
+	}
+
+	now := time.Now()
+	documents := make([]interface{}, len(reqs))
+	for i, req := range reqs {
+		location := &models.Location{
+			ID:         primitive.NewObjectID(),
+			LocationID: primitive.NewObjectID().Hex(),
+			OrgID:      req.OrgID,
+			Name:       req.Name,
+			Address:    req.Address,
+			Manager:    req.Manager,
+			Settings:   req.Settings,
+			OperatingHours: req.OperatingHours,
+			Active:     true,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		results[i] = models.LocationResult{Index: i, Location: location}
+		documents[i] = location
+	}
+
+	collection := r.database.Collection("locations")
+	_, err := collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			return nil, fmt.Errorf("failed to create locations: %w", err)
+		}
+
+		for _, writeErr := range bulkErr.WriteErrors {
+			if writeErr.Index < 0 || writeErr.Index >= len(results) {
+				continue
+			}
+			results[writeErr.Index].Location = nil
+			results[writeErr.Index].Error = fmt.Sprintf("failed to create location: %s", writeErr.Message)
+		}
+	}
+
+	return results, nil
+}
+
 func (r *MongoRepo) GetLocation(ctx context.Context, locationID string) (*models.Location, error) {
 	collection := r.database.Collection("locations")
 	
@@ -267,6 +688,16 @@ func (r *MongoRepo) GetLocationsByOrg(ctx context.Context, orgID string, limit,
 	return locations, nil
 }
 
+// CountLocationsByOrg counts all of an org's locations, the total that
+// GetLocationsByOrg pages through
+func (r *MongoRepo) CountLocationsByOrg(ctx context.Context, orgID string) (int64, error) {
+	total, err := r.database.Collection("locations").CountDocuments(ctx, bson.M{"org_id": orgID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count locations: %w", err)
+	}
+	return total, nil
+}
+
 func (r *MongoRepo) UpdateLocation(ctx context.Context, locationID string, updates bson.M) error {
 	collection := r.database.Collection("locations")
 	
@@ -0,0 +1,725 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loyalty/membership/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Test setup helper
+func setupTestRepo(mt *mtest.T) *MongoRepo {
+	return &MongoRepo{
+		client:   mt.Client,
+		database: mt.DB,
+	}
+}
+
+// Test GetCustomersByTier
+func TestGetCustomersByTier(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("matching customers", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "org_id", Value: "test_org"}, {Key: "tier", Value: "gold"}},
+			bson.D{{Key: "customer_id", Value: "cust_2"}, {Key: "org_id", Value: "test_org"}, {Key: "tier", Value: "Gold"}},
+		))
+
+		customers, err := repo.GetCustomersByTier(context.Background(), "test_org", "gold", 20, 40)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, customers, 2)
+		assert.Equal(t, "cust_1", customers[0].CustomerID)
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "find", started.CommandName)
+		filter := started.Command.Lookup("filter").Document()
+		assert.Equal(t, "test_org", filter.Lookup("org_id").StringValue())
+		assert.Equal(t, "gold", filter.Lookup("tier").StringValue())
+		assert.Equal(t, int64(20), started.Command.Lookup("limit").AsInt64())
+		assert.Equal(t, int64(40), started.Command.Lookup("skip").AsInt64())
+		collation := started.Command.Lookup("collation").Document()
+		assert.Equal(t, int32(2), collation.Lookup("strength").Int32())
+	})
+
+	mt.Run("unused tier", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch))
+
+		customers, err := repo.GetCustomersByTier(context.Background(), "test_org", "platinum", 50, 0)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Empty(t, customers)
+	})
+}
+
+// Test SearchCustomers
+func TestSearchCustomers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("email only", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "org_id", Value: "test_org"}, {Key: "email", Value: "jane@example.com"}},
+		))
+
+		customers, err := repo.SearchCustomers(context.Background(), "test_org", "jane@example.com", "")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, customers, 1)
+		assert.Equal(t, "cust_1", customers[0].CustomerID)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, "test_org", filter.Lookup("org_id").StringValue())
+		assert.Equal(t, "jane@example.com", filter.Lookup("email").StringValue())
+		_, byPhone := filter.LookupErr("phone")
+		assert.Error(t, byPhone)
+	})
+
+	mt.Run("email and phone", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch))
+
+		customers, err := repo.SearchCustomers(context.Background(), "test_org", "jane@example.com", "+15550100")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Empty(t, customers)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, "jane@example.com", filter.Lookup("email").StringValue())
+		assert.Equal(t, "+15550100", filter.Lookup("phone").StringValue())
+	})
+
+	mt.Run("phone only", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "phone", Value: "+15550100"}},
+			bson.D{{Key: "customer_id", Value: "cust_2"}, {Key: "phone", Value: "+15550100"}},
+		))
+
+		customers, err := repo.SearchCustomers(context.Background(), "test_org", "", "+15550100")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, customers, 2)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		_, byEmail := filter.LookupErr("email")
+		assert.Error(t, byEmail)
+	})
+}
+
+// Test CountCustomersByOrg
+func TestCountCustomersByOrg(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("counts the whole org", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "n", Value: int32(125)}},
+		))
+
+		total, err := repo.CountCustomersByOrg(context.Background(), "test_org")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, int64(125), total)
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "customers", started.Command.Lookup("aggregate").StringValue())
+		match := started.Command.Lookup("pipeline").Array().Index(0).Value().Document()
+		assert.Equal(t, "test_org", match.Lookup("$match", "org_id").StringValue())
+	})
+
+	mt.Run("command failure", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}))
+
+		_, err := repo.CountCustomersByOrg(context.Background(), "test_org")
+
+		// Assertions
+		assert.Error(t, err)
+	})
+}
+
+// Test GetCustomersCreatedBetween
+func TestGetCustomersCreatedBetween(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	mt.Run("customers in range", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_2"}, {Key: "org_id", Value: "test_org"}, {Key: "created_at", Value: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)}},
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "org_id", Value: "test_org"}, {Key: "created_at", Value: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)}},
+		))
+
+		customers, err := repo.GetCustomersCreatedBetween(context.Background(), "test_org", from, to, 10, 0)
+
+		// Assertions - the range starts at from and stops short of to, so
+		// customers created on 1 July are excluded
+		assert.NoError(t, err)
+		assert.Len(t, customers, 2)
+		assert.Equal(t, "cust_2", customers[0].CustomerID)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, "test_org", filter.Lookup("org_id").StringValue())
+		assert.Equal(t, from, filter.Lookup("created_at", "$gte").Time().UTC())
+		assert.Equal(t, to, filter.Lookup("created_at", "$lt").Time().UTC())
+	})
+
+	mt.Run("open ended range", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch))
+
+		customers, err := repo.GetCustomersCreatedBetween(context.Background(), "test_org", from, time.Time{}, 10, 0)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Empty(t, customers)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, from, filter.Lookup("created_at", "$gte").Time().UTC())
+		_, err = filter.LookupErr("created_at", "$lt")
+		assert.Error(t, err)
+	})
+}
+
+// Test CountCustomersCreatedBetween
+func TestCountCustomersCreatedBetween(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("counts the range", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "n", Value: int32(12)}},
+		))
+
+		from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		total, err := repo.CountCustomersCreatedBetween(context.Background(), "test_org", from, to)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), total)
+
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document()
+		assert.Equal(t, "test_org", match.Lookup("$match", "org_id").StringValue())
+		assert.Equal(t, from, match.Lookup("$match", "created_at", "$gte").Time().UTC())
+		assert.Equal(t, to, match.Lookup("$match", "created_at", "$lt").Time().UTC())
+	})
+}
+
+func TestGetBalanceAlertCustomers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("opted in customers", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "org_id", Value: "org_a"},
+				{Key: "preferences", Value: bson.D{{Key: "balance_alerts", Value: true}}}},
+		))
+
+		customers, err := repo.GetBalanceAlertCustomers(context.Background(), 100, 200)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, customers, 1)
+		assert.True(t, customers[0].Preferences.BalanceAlerts)
+
+		started := mt.GetStartedEvent()
+		filter := started.Command.Lookup("filter").Document()
+		assert.True(t, filter.Lookup("preferences.balance_alerts").Boolean())
+		assert.Equal(t, "active", filter.Lookup("status").StringValue())
+		assert.Equal(t, int32(1), started.Command.Lookup("sort", "customer_id").Int32())
+		assert.Equal(t, int64(200), started.Command.Lookup("skip").AsInt64())
+	})
+}
+
+// Test GetCustomersByPreferences
+func TestGetCustomersByPreferences(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("opted in to email", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "org_id", Value: "test_org"},
+				{Key: "preferences", Value: bson.D{{Key: "email_marketing", Value: true}}}},
+		))
+
+		optedIn := true
+		customers, err := repo.GetCustomersByPreferences(context.Background(), "test_org", models.TargetingFilter{EmailOptIn: &optedIn}, 50, 0)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, customers, 1)
+		assert.True(t, customers[0].Preferences.EmailMarketing)
+
+		started := mt.GetStartedEvent()
+		filter := started.Command.Lookup("filter").Document()
+		assert.Equal(t, "test_org", filter.Lookup("org_id").StringValue())
+		assert.Equal(t, "active", filter.Lookup("status").StringValue())
+		assert.True(t, filter.Lookup("preferences.email_marketing").Boolean())
+		_, err = filter.LookupErr("preferences.sms_marketing")
+		assert.Error(t, err, "unset opt-ins should not filter")
+	})
+
+	mt.Run("preferred category", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch))
+
+		customers, err := repo.GetCustomersByPreferences(context.Background(), "test_org", models.TargetingFilter{Category: "beverages", Language: "fr"}, 50, 0)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Empty(t, customers)
+
+		started := mt.GetStartedEvent()
+		filter := started.Command.Lookup("filter").Document()
+		assert.Equal(t, "beverages", filter.Lookup("preferences.categories").StringValue())
+		assert.Equal(t, "fr", filter.Lookup("preferences.language").StringValue())
+		assert.Equal(t, int32(2), started.Command.Lookup("collation", "strength").Int32())
+	})
+
+	mt.Run("tagged", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "org_id", Value: "test_org"},
+				{Key: "tags", Value: bson.A{"vip", "beta-tester"}}},
+		))
+
+		customers, err := repo.GetCustomersByPreferences(context.Background(), "test_org", models.TargetingFilter{Tag: "vip"}, 50, 0)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, customers, 1)
+		assert.Equal(t, []string{"vip", "beta-tester"}, customers[0].Tags)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, "vip", filter.Lookup("tags").StringValue())
+		_, err = filter.LookupErr("status")
+		assert.Error(t, err, "a tag listing should include inactive customers")
+	})
+}
+
+// Test CountCustomersByPreferences
+func TestCountCustomersByPreferences(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("counts the filter", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "n", Value: int32(4)}},
+		))
+
+		optedIn := true
+		total, err := repo.CountCustomersByPreferences(context.Background(), "test_org", models.TargetingFilter{EmailOptIn: &optedIn, Tag: "vip"})
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+
+		started := mt.GetStartedEvent()
+		match := started.Command.Lookup("pipeline").Array().Index(0).Value().Document()
+		assert.Equal(t, "active", match.Lookup("$match", "status").StringValue())
+		assert.Equal(t, "vip", match.Lookup("$match", "tags").StringValue())
+		assert.Equal(t, int32(2), started.Command.Lookup("collation", "strength").Int32())
+	})
+}
+
+// Test CountCustomersByTier
+func TestCountCustomersByTier(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("counts the tier", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.customers", mtest.FirstBatch,
+			bson.D{{Key: "n", Value: int32(7)}},
+		))
+
+		total, err := repo.CountCustomersByTier(context.Background(), "test_org", "gold")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), total)
+
+		started := mt.GetStartedEvent()
+		match := started.Command.Lookup("pipeline").Array().Index(0).Value().Document()
+		assert.Equal(t, "gold", match.Lookup("$match", "tier").StringValue())
+		assert.Equal(t, int32(2), started.Command.Lookup("collation", "strength").Int32())
+	})
+}
+
+// Test AddCustomerTags
+func TestAddCustomerTags(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("adds to set", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := repo.AddCustomerTags(context.Background(), "cust_1", []string{"vip", "beta-tester"})
+
+		// Assertions
+		assert.NoError(t, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "cust_1", update.Lookup("q", "customer_id").StringValue())
+		values, err := update.Lookup("u", "$addToSet", "tags", "$each").Array().Values()
+		assert.NoError(t, err)
+		assert.Len(t, values, 2)
+		assert.Equal(t, "vip", values[0].StringValue())
+		_, err = update.LookupErr("u", "$set", "tags")
+		assert.Error(t, err, "tags should not be overwritten")
+	})
+
+	mt.Run("duplicate tag is a no-op", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		// The customer matched but already had the tag
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 0}))
+
+		err := repo.AddCustomerTags(context.Background(), "cust_1", []string{"vip"})
+
+		// Assertions
+		assert.NoError(t, err)
+	})
+
+	mt.Run("unknown customer", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		err := repo.AddCustomerTags(context.Background(), "missing", []string{"vip"})
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrCustomerNotFound)
+	})
+}
+
+// Test RemoveCustomerTag
+func TestRemoveCustomerTag(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("pulls tag", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := repo.RemoveCustomerTag(context.Background(), "cust_1", "vip")
+
+		// Assertions
+		assert.NoError(t, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "vip", update.Lookup("u", "$pull", "tags").StringValue())
+	})
+
+	mt.Run("unknown customer", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		err := repo.RemoveCustomerTag(context.Background(), "missing", "vip")
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrCustomerNotFound)
+	})
+}
+
+// Test CreateLocations
+func TestCreateLocations(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	reqs := []*models.CreateLocationRequest{
+		{OrgID: "test_org", Name: "Downtown"},
+		{OrgID: "test_org", Name: "Airport"},
+		{OrgID: "test_org", Name: "Harbour"},
+	}
+
+	mt.Run("all inserted", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		results, err := repo.CreateLocations(context.Background(), reqs)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+		for i, result := range results {
+			assert.Equal(t, i, result.Index)
+			assert.Empty(t, result.Error)
+			assert.Equal(t, reqs[i].Name, result.Location.Name)
+			assert.NotEmpty(t, result.Location.LocationID)
+		}
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "insert", started.CommandName)
+		assert.False(t, started.Command.Lookup("ordered").Boolean())
+	})
+
+	mt.Run("failed row reported", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   1,
+			Code:    11000,
+			Message: "duplicate key",
+		}))
+
+		results, err := repo.CreateLocations(context.Background(), reqs)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+		assert.NotNil(t, results[0].Location)
+		assert.Nil(t, results[1].Location)
+		assert.Contains(t, results[1].Error, "duplicate key")
+		assert.NotNil(t, results[2].Location)
+	})
+
+	mt.Run("command failure", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    91,
+			Message: "shutdown in progress",
+		}))
+
+		results, err := repo.CreateLocations(context.Background(), reqs)
+
+		// Assertions
+		assert.Error(t, err)
+		assert.Nil(t, results)
+	})
+}
+
+// Test consent history
+// Test CountLocationsByOrg
+func TestCountLocationsByOrg(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("counts the whole org", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.locations", mtest.FirstBatch,
+			bson.D{{Key: "n", Value: int32(12)}},
+		))
+
+		total, err := repo.CountLocationsByOrg(context.Background(), "test_org")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, int64(12), total)
+		assert.Equal(t, "locations", mt.GetStartedEvent().Command.Lookup("aggregate").StringValue())
+	})
+}
+
+func TestAppendConsentHistory(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("inserts each change", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		err := repo.AppendConsentHistory(context.Background(), []models.ConsentChange{
+			{CustomerID: "cust_1", Preference: "email_marketing", NewValue: true, ChangedAt: time.Now()},
+			{CustomerID: "cust_1", Preference: "sms_marketing", OldValue: true, ChangedAt: time.Now()},
+		})
+
+		// Assertions
+		assert.NoError(t, err)
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "insert", started.CommandName)
+		assert.Equal(t, "consent_history", started.Command.Lookup("insert").StringValue())
+		documents, _ := started.Command.Lookup("documents").Array().Values()
+		assert.Len(t, documents, 2)
+	})
+
+	mt.Run("nothing to record", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+
+		// Assertions
+		assert.NoError(t, repo.AppendConsentHistory(context.Background(), nil))
+		assert.Nil(t, mt.GetStartedEvent())
+	})
+}
+
+func TestGetConsentHistory(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("newest first", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.consent_history", mtest.FirstBatch,
+			bson.D{{Key: "customer_id", Value: "cust_1"}, {Key: "preference", Value: "email_marketing"}, {Key: "new_value", Value: true}},
+		))
+
+		history, err := repo.GetConsentHistory(context.Background(), "cust_1", 50, 0)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Len(t, history, 1)
+		assert.True(t, history[0].NewValue)
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "cust_1", started.Command.Lookup("filter").Document().Lookup("customer_id").StringValue())
+		assert.Equal(t, int32(-1), started.Command.Lookup("sort").Document().Lookup("changed_at").Int32())
+	})
+}
+
+// Test CreateCustomer
+func TestCreateCustomer(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	req := &models.CreateCustomerRequest{OrgID: "test_org", Email: "test@example.com"}
+
+	mt.Run("inserted", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		customer, err := repo.CreateCustomer(context.Background(), req)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.Equal(t, "test@example.com", customer.Email)
+		assert.False(t, customer.ID.IsZero())
+	})
+
+	mt.Run("duplicate email", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   0,
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: test.customers index: org_id_1_email_1",
+		}))
+
+		_, err := repo.CreateCustomer(context.Background(), req)
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrCustomerEmailExists)
+		assert.Contains(t, err.Error(), "test_org")
+		assert.NotContains(t, err.Error(), "E11000")
+	})
+
+	mt.Run("command failure", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    91,
+			Message: "shutdown in progress",
+		}))
+
+		_, err := repo.CreateCustomer(context.Background(), req)
+
+		// Assertions
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCustomerEmailExists)
+	})
+}
+
+// Test CreateOrganization
+func TestCreateOrganization(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("inserted", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		org := &models.Organization{OrgID: "test_org", Name: "Test Organization"}
+		err := repo.CreateOrganization(context.Background(), org)
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.False(t, org.ID.IsZero())
+		assert.False(t, org.CreatedAt.IsZero())
+	})
+
+	mt.Run("duplicate org_id", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   0,
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: test.organizations index: org_id_1",
+		}))
+
+		err := repo.CreateOrganization(context.Background(), &models.Organization{OrgID: "test_org"})
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrOrganizationExists)
+		assert.Contains(t, err.Error(), "test_org")
+	})
+
+	mt.Run("command failure", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    91,
+			Message: "shutdown in progress",
+		}))
+
+		err := repo.CreateOrganization(context.Background(), &models.Organization{OrgID: "test_org"})
+
+		// Assertions
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrOrganizationExists)
+	})
+}
+
+// Test UpdateOrganization
+func TestUpdateOrganization(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("sets settings fields", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := repo.UpdateOrganization(context.Background(), "test_org", bson.M{"settings.points_per_dollar": 2.5})
+
+		// Assertions
+		assert.NoError(t, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "test_org", update.Lookup("q", "org_id").StringValue())
+		assert.Equal(t, 2.5, update.Lookup("u", "$set", "settings.points_per_dollar").Double())
+		_, err = update.LookupErr("u", "$set", "updated_at")
+		assert.NoError(t, err)
+	})
+
+	mt.Run("unknown organization", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		err := repo.UpdateOrganization(context.Background(), "missing_org", bson.M{"name": "Renamed"})
+
+		// Assertions
+		assert.ErrorIs(t, err, ErrOrganizationNotFound)
+	})
+
+	mt.Run("command failure", func(mt *mtest.T) {
+		repo := setupTestRepo(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutdown in progress"}))
+
+		err := repo.UpdateOrganization(context.Background(), "test_org", bson.M{"name": "Renamed"})
+
+		// Assertions
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrOrganizationNotFound)
+	})
+}
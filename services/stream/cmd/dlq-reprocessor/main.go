@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	// Location promotions need timezone data, which the alpine image lacks
+	_ "time/tzdata"
+
+	"github.com/loyalty/stream/internal/ceiling"
+	"github.com/loyalty/stream/internal/dlq"
+	"github.com/loyalty/stream/internal/processor"
+	"github.com/segmentio/kafka-go"
+)
+
+// The DLQ reprocessor drains one {topic}.dlq through the event processor and
+// exits, so it can be run by hand or as a job once the cause is fixed
+func main() {
+	dlqTopic := os.Getenv("DLQ_TOPIC")
+	if dlqTopic == "" {
+		log.Fatal("DLQ_TOPIC is required, e.g. brand123.pos.transaction.dlq")
+	}
+
+	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
+	if kafkaBrokers == "" {
+		kafkaBrokers = "localhost:9092"
+	}
+
+	ledgerURL := os.Getenv("LEDGER_URL")
+	if ledgerURL == "" {
+		ledgerURL = "http://localhost:8001"
+	}
+
+	membershipURL := os.Getenv("MEMBERSHIP_URL")
+	if membershipURL == "" {
+		membershipURL = "http://localhost:8002"
+	}
+
+	analyticsURL := os.Getenv("ANALYTICS_URL")
+	if analyticsURL == "" {
+		analyticsURL = "http://localhost:8003"
+	}
+
+	consumerGroupID := os.Getenv("CONSUMER_GROUP_ID")
+	if consumerGroupID == "" {
+		consumerGroupID = "loyalty-dlq-reprocessor"
+	}
+
+	config := dlq.DefaultConfig()
+	if maxAttempts := os.Getenv("DLQ_MAX_ATTEMPTS"); maxAttempts != "" {
+		attempts, err := strconv.Atoi(maxAttempts)
+		if err != nil || attempts < 1 {
+			log.Fatalf("Invalid DLQ_MAX_ATTEMPTS %q", maxAttempts)
+		}
+		config.MaxAttempts = attempts
+	}
+	if backoff := os.Getenv("DLQ_RETRY_BACKOFF"); backoff != "" {
+		d, err := time.ParseDuration(backoff)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid DLQ_RETRY_BACKOFF %q", backoff)
+		}
+		config.Backoff = d
+	}
+	if idle := os.Getenv("DLQ_IDLE_TIMEOUT"); idle != "" {
+		d, err := time.ParseDuration(idle)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid DLQ_IDLE_TIMEOUT %q", idle)
+		}
+		config.IdleTimeout = d
+	}
+
+	brokerList := strings.Split(kafkaBrokers, ",")
+
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(brokerList...),
+		Balancer: &kafka.LeastBytes{},
+	}
+	defer writer.Close()
+
+	processorConfig := processor.DefaultProcessorConfig()
+	processorConfig.AnalyticsURL = analyticsURL
+	if os.Getenv("PUBLISH_RESULTS") == "true" {
+		processorConfig.ResultWriter = writer
+	}
+	if os.Getenv("PUBLISH_REWARDS") != "false" {
+		processorConfig.RewardWriter = writer
+	}
+	if os.Getenv("PUBLISH_ACCRUAL_ALERTS") != "false" {
+		processorConfig.AlertWriter = writer
+	}
+	if os.Getenv("PUBLISH_CUSTOMER_METRICS") == "true" {
+		processorConfig.MetricsWriter = writer
+	}
+	// Accruals replayed here count towards the same ceiling as the
+	// processor's when the count is kept in MongoDB
+	var ceilingCounter *ceiling.MongoCounter
+	switch store := os.Getenv("ACCRUAL_CEILING_STORE"); store {
+	case "", "memory":
+	case "mongo":
+		mongoURL := os.Getenv("MONGO_URL")
+		if mongoURL == "" {
+			mongoURL = "mongodb://localhost:27017"
+		}
+		mongoDatabase := os.Getenv("MONGO_DATABASE")
+		if mongoDatabase == "" {
+			mongoDatabase = "stream"
+		}
+		counter, err := ceiling.NewMongoCounter(mongoURL, mongoDatabase)
+		if err != nil {
+			log.Fatalf("Failed to open accrual ceiling store: %v", err)
+		}
+		ceilingCounter = counter
+		processorConfig.AccrualCeiling = counter
+	default:
+		log.Fatalf("Invalid ACCRUAL_CEILING_STORE %q: must be memory or mongo", store)
+	}
+	if ceilingCounter != nil {
+		defer ceilingCounter.Close(context.Background())
+	}
+	eventProcessor := processor.NewEventProcessorWithConfig(ledgerURL, membershipURL, processorConfig)
+
+	reprocessor, err := dlq.NewReprocessor(eventProcessor, writer, dlqTopic, config)
+	if err != nil {
+		log.Fatalf("Failed to create reprocessor: %v", err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokerList,
+		GroupID:     consumerGroupID,
+		Topic:       dlqTopic,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+		MaxWait:     1 * time.Second,
+		StartOffset: kafka.FirstOffset,
+	})
+	defer reader.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	log.Printf("Draining %s with brokers: %s", dlqTopic, kafkaBrokers)
+	log.Printf("Max attempts: %d, retry backoff: %s", config.MaxAttempts, config.Backoff)
+
+	stats, err := reprocessor.Drain(ctx, reader)
+	log.Printf("Reprocessed %d dead letters, parked %d", stats.Reprocessed, stats.Parked)
+	if err != nil {
+		log.Printf("Stopped draining %s: %v", dlqTopic, err)
+		cancel()
+		reader.Close()
+		writer.Close()
+		if ceilingCounter != nil {
+			ceilingCounter.Close(context.Background())
+		}
+		os.Exit(1)
+	}
+}
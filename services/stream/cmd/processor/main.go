@@ -2,13 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	// Location promotions need timezone data, which the alpine image lacks
+	_ "time/tzdata"
 
+	"github.com/loyalty/stream/internal/ceiling"
+	"github.com/loyalty/stream/internal/idempotency"
 	"github.com/loyalty/stream/internal/processor"
 	"github.com/segmentio/kafka-go"
 )
@@ -29,6 +38,11 @@ func main() {
 		membershipURL = "http://localhost:8002"
 	}
 
+	analyticsURL := os.Getenv("ANALYTICS_URL")
+	if analyticsURL == "" {
+		analyticsURL = "http://localhost:8003"
+	}
+
 	consumerGroupID := os.Getenv("CONSUMER_GROUP_ID")
 	if consumerGroupID == "" {
 		consumerGroupID = "loyalty-stream-processor"
@@ -36,14 +50,153 @@ func main() {
 
 	topics := []string{
 		"*.pos.transaction",
+		"*.pos.settlement",
 		"*.loyalty.action",
 		"*.customer.updated",
 	}
 
-	eventProcessor := processor.NewEventProcessor(ledgerURL, membershipURL)
-
 	brokerList := strings.Split(kafkaBrokers, ",")
-	
+
+	processorConfig := processor.DefaultProcessorConfig()
+	processorConfig.AnalyticsURL = analyticsURL
+	ledgerConfig := &processorConfig.Ledger
+	if pointsCode := os.Getenv("LEDGER_POINTS_CODE"); pointsCode != "" {
+		code, err := strconv.ParseUint(pointsCode, 10, 16)
+		if err != nil {
+			log.Fatalf("Invalid LEDGER_POINTS_CODE %q: %v", pointsCode, err)
+		}
+		ledgerConfig.PointsCode = uint16(code)
+	}
+	if stampsCode := os.Getenv("LEDGER_STAMPS_CODE"); stampsCode != "" {
+		code, err := strconv.ParseUint(stampsCode, 10, 16)
+		if err != nil {
+			log.Fatalf("Invalid LEDGER_STAMPS_CODE %q: %v", stampsCode, err)
+		}
+		ledgerConfig.StampsCode = uint16(code)
+	}
+	if maxRequests := os.Getenv("LEDGER_MAX_CONCURRENT_REQUESTS"); maxRequests != "" {
+		max, err := strconv.Atoi(maxRequests)
+		if err != nil || max < 0 {
+			log.Fatalf("Invalid LEDGER_MAX_CONCURRENT_REQUESTS %q", maxRequests)
+		}
+		ledgerConfig.MaxConcurrentRequests = max
+	}
+	if attempts := os.Getenv("LEDGER_RETRY_ATTEMPTS"); attempts != "" {
+		max, err := strconv.Atoi(attempts)
+		if err != nil || max < 1 {
+			log.Fatalf("Invalid LEDGER_RETRY_ATTEMPTS %q", attempts)
+		}
+		processorConfig.LedgerRetry.MaxAttempts = max
+	}
+	if baseDelay := os.Getenv("LEDGER_RETRY_BASE_DELAY"); baseDelay != "" {
+		delay, err := time.ParseDuration(baseDelay)
+		if err != nil || delay < 0 {
+			log.Fatalf("Invalid LEDGER_RETRY_BASE_DELAY %q", baseDelay)
+		}
+		processorConfig.LedgerRetry.BaseDelay = delay
+	}
+	if maxRequests := os.Getenv("MEMBERSHIP_MAX_CONCURRENT_REQUESTS"); maxRequests != "" {
+		max, err := strconv.Atoi(maxRequests)
+		if err != nil || max < 0 {
+			log.Fatalf("Invalid MEMBERSHIP_MAX_CONCURRENT_REQUESTS %q", maxRequests)
+		}
+		processorConfig.Membership.MaxConcurrentRequests = max
+	}
+
+	publishResults := os.Getenv("PUBLISH_RESULTS") == "true"
+	publishRewards := os.Getenv("PUBLISH_REWARDS") != "false"
+	publishAlerts := os.Getenv("PUBLISH_ACCRUAL_ALERTS") != "false"
+	publishMetrics := os.Getenv("PUBLISH_CUSTOMER_METRICS") == "true"
+	publishDeadLetters := os.Getenv("PUBLISH_DEAD_LETTERS") != "false"
+
+	var resultWriter *kafka.Writer
+	if publishResults || publishRewards || publishAlerts || publishMetrics || publishDeadLetters {
+		resultWriter = &kafka.Writer{
+			Addr:     kafka.TCP(brokerList...),
+			Balancer: &kafka.LeastBytes{},
+		}
+	}
+	if publishResults {
+		processorConfig.ResultWriter = resultWriter
+	}
+	if publishRewards {
+		processorConfig.RewardWriter = resultWriter
+	}
+	if publishAlerts {
+		processorConfig.AlertWriter = resultWriter
+	}
+	if publishMetrics {
+		processorConfig.MetricsWriter = resultWriter
+	}
+	if publishDeadLetters {
+		processorConfig.DeadLetterWriter = resultWriter
+	}
+
+	accrualCeiling, ceilingStore := openAccrualCeiling()
+	processorConfig.AccrualCeiling = accrualCeiling
+
+	// Redelivered events are skipped. The memory store only sees this
+	// instance's events; the mongo store is shared by every instance.
+	idempotencyStore := os.Getenv("IDEMPOTENCY_STORE")
+	if idempotencyStore == "" {
+		idempotencyStore = "memory"
+	}
+	var idempotencyTTL time.Duration
+	if ttl := os.Getenv("IDEMPOTENCY_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid IDEMPOTENCY_TTL %q", ttl)
+		}
+		idempotencyTTL = parsed
+	}
+	var claimTimeout time.Duration
+	if timeout := os.Getenv("IDEMPOTENCY_CLAIM_TIMEOUT"); timeout != "" {
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid IDEMPOTENCY_CLAIM_TIMEOUT %q", timeout)
+		}
+		claimTimeout = parsed
+	}
+	var mongoStore *idempotency.MongoStore
+	switch idempotencyStore {
+	case "memory":
+		storeConfig := idempotency.DefaultMemoryStoreConfig()
+		if idempotencyTTL > 0 {
+			storeConfig.TTL = idempotencyTTL
+		}
+		if claimTimeout > 0 {
+			storeConfig.ClaimTimeout = claimTimeout
+		}
+		processorConfig.Idempotency = idempotency.NewMemoryStoreWithConfig(storeConfig)
+	case "mongo":
+		mongoURL := os.Getenv("MONGO_URL")
+		if mongoURL == "" {
+			mongoURL = "mongodb://localhost:27017"
+		}
+		mongoDatabase := os.Getenv("MONGO_DATABASE")
+		if mongoDatabase == "" {
+			mongoDatabase = "stream"
+		}
+		storeConfig := idempotency.DefaultMongoStoreConfig()
+		if idempotencyTTL > 0 {
+			storeConfig.TTL = idempotencyTTL
+		}
+		if claimTimeout > 0 {
+			storeConfig.ClaimTimeout = claimTimeout
+		}
+		store, err := idempotency.NewMongoStoreWithConfig(mongoURL, mongoDatabase, storeConfig)
+		if err != nil {
+			log.Fatalf("Failed to open idempotency store: %v", err)
+		}
+		mongoStore = store
+		processorConfig.Idempotency = store
+	case "none":
+	default:
+		log.Fatalf("Invalid IDEMPOTENCY_STORE %q: must be memory, mongo or none", idempotencyStore)
+	}
+
+	eventProcessor := processor.NewEventProcessorWithConfig(ledgerURL, membershipURL, processorConfig)
+
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:     brokerList,
 		GroupID:     consumerGroupID,
@@ -70,6 +223,11 @@ func main() {
 	log.Printf("Consumer group: %s", consumerGroupID)
 	log.Printf("Ledger URL: %s", ledgerURL)
 	log.Printf("Membership URL: %s", membershipURL)
+	log.Printf("Analytics URL: %s", analyticsURL)
+	log.Printf("Publishing results: %t", publishResults)
+	log.Printf("Publishing reward events: %t", publishRewards)
+	log.Printf("Idempotency store: %s", idempotencyStore)
+	log.Printf("Accrual ceiling store: %s", ceilingStore)
 
 	for {
 		select {
@@ -78,6 +236,21 @@ func main() {
 			if err := reader.Close(); err != nil {
 				log.Printf("Error closing reader: %v", err)
 			}
+			if resultWriter != nil {
+				if err := resultWriter.Close(); err != nil {
+					log.Printf("Error closing writer: %v", err)
+				}
+			}
+			if mongoStore != nil {
+				if err := mongoStore.Close(context.Background()); err != nil {
+					log.Printf("Error closing idempotency store: %v", err)
+				}
+			}
+			if counter, ok := accrualCeiling.(*ceiling.MongoCounter); ok {
+				if err := counter.Close(context.Background()); err != nil {
+					log.Printf("Error closing accrual ceiling store: %v", err)
+				}
+			}
 			return
 		default:
 			message, err := reader.FetchMessage(ctx)
@@ -92,9 +265,20 @@ func main() {
 
 			if shouldProcessTopic(string(message.Topic), topics) {
 				result, err := eventProcessor.ProcessEvent(ctx, message)
+				if errors.Is(err, context.Canceled) {
+					// Leave the message uncommitted so it is redelivered
+					log.Printf("Stopped mid-event: %v", err)
+					continue
+				}
+				if errors.Is(err, processor.ErrDeadLetterFailed) {
+					// Committing a later message would skip this one, so stop
+					// and leave it to be redelivered on restart
+					log.Printf("Stopping stream processor: %v", err)
+					cancel()
+					continue
+				}
 				if err != nil {
-					log.Printf("Error processing event %s: %v", 
-						getEventID(message.Value), err)
+					logProcessError(message.Value, err)
 				} else if result != nil {
 					if result.Success {
 						log.Printf("Successfully processed event %s: %d points, %d stamps, %d rewards",
@@ -113,6 +297,39 @@ func main() {
 	}
 }
 
+// openAccrualCeiling opens the count of each org's daily accrual named by
+// ACCRUAL_CEILING_STORE. The memory count only sees this instance's
+// accruals; the mongo count is shared by every instance and the DLQ
+// reprocessor.
+func openAccrualCeiling() (ceiling.Counter, string) {
+	store := os.Getenv("ACCRUAL_CEILING_STORE")
+	if store == "" {
+		store = "memory"
+	}
+
+	switch store {
+	case "memory":
+		return ceiling.NewMemoryCounter(), store
+	case "mongo":
+		mongoURL := os.Getenv("MONGO_URL")
+		if mongoURL == "" {
+			mongoURL = "mongodb://localhost:27017"
+		}
+		mongoDatabase := os.Getenv("MONGO_DATABASE")
+		if mongoDatabase == "" {
+			mongoDatabase = "stream"
+		}
+		counter, err := ceiling.NewMongoCounter(mongoURL, mongoDatabase)
+		if err != nil {
+			log.Fatalf("Failed to open accrual ceiling store: %v", err)
+		}
+		return counter, store
+	default:
+		log.Fatalf("Invalid ACCRUAL_CEILING_STORE %q: must be memory or mongo", store)
+		return nil, store
+	}
+}
+
 func shouldProcessTopic(topic string, patterns []string) bool {
 	for _, pattern := range patterns {
 		if strings.Contains(pattern, "*") {
@@ -138,13 +355,21 @@ func shouldProcessTopic(topic string, patterns []string) bool {
 	return false
 }
 
-func getEventID(messageValue []byte) string {
-	return string(messageValue[:min(50, len(messageValue))])
+func logProcessError(messageValue []byte, err error) {
+	log.Printf("Error processing event %s: %v", getEventID(messageValue), err)
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// getEventID returns the message's event_id for log correlation. Messages
+// that are not valid JSON or lack an event_id get a hash of their bytes, which
+// is stable across redeliveries and does not leak the payload into logs.
+func getEventID(messageValue []byte) string {
+	var event struct {
+		EventID string `json:"event_id"`
+	}
+	if err := json.Unmarshal(messageValue, &event); err == nil && event.EventID != "" {
+		return event.EventID
 	}
-	return b
-}
\ No newline at end of file
+
+	sum := sha256.Sum256(messageValue)
+	return "unparsed-" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,28 @@
+package ceiling
+
+import "context"
+
+// Check is the outcome of reserving an accrual against an org's daily
+// ceiling
+type Check struct {
+	// Total is the points counted against the day, including this accrual
+	// unless it was refused
+	Total int
+	// Paused is set when the accrual was refused and not counted
+	Paused bool
+	// Crossed is set only for the accrual that first takes the org over its
+	// ceiling in a day
+	Crossed bool
+}
+
+// Counter counts the points each org accrues per UTC day so runaway accrual,
+// such as from a mistyped points-per-dollar, can be caught. Days are keyed by
+// the caller, as YYYY-MM-DD.
+type Counter interface {
+	// Reserve counts points against the org's ceiling for day. When pause is
+	// set, the accrual that takes the org over its ceiling and every later
+	// one that day are refused and not counted.
+	Reserve(ctx context.Context, orgID, day string, points, ceiling int, pause bool) (Check, error)
+	// Release takes back points reserved on day that were not credited
+	Release(ctx context.Context, orgID, day string, points int) error
+}
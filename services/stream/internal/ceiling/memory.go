@@ -0,0 +1,70 @@
+package ceiling
+
+import (
+	"context"
+	"sync"
+)
+
+// orgAccrual is one org's points accrued on one day
+type orgAccrual struct {
+	day     string
+	points  int
+	alerted bool
+	paused  bool
+}
+
+// MemoryCounter keeps each org's count for its latest day in this process
+// only, so every instance sharing a topic gets its own full ceiling
+type MemoryCounter struct {
+	mu   sync.Mutex
+	orgs map[string]*orgAccrual
+}
+
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{orgs: make(map[string]*orgAccrual)}
+}
+
+func (c *MemoryCounter) Reserve(ctx context.Context, orgID, day string, points, ceiling int, pause bool) (Check, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	accrual, ok := c.orgs[orgID]
+	if !ok || accrual.day != day {
+		accrual = &orgAccrual{day: day}
+		c.orgs[orgID] = accrual
+	}
+
+	check := Check{Total: accrual.points + points}
+	if accrual.paused {
+		check.Total = accrual.points
+		check.Paused = true
+		return check, nil
+	}
+
+	if check.Total > ceiling && !accrual.alerted {
+		accrual.alerted = true
+		check.Crossed = true
+	}
+	if check.Total > ceiling && pause {
+		accrual.paused = true
+		check.Total = accrual.points
+		check.Paused = true
+		return check, nil
+	}
+
+	accrual.points += points
+	return check, nil
+}
+
+func (c *MemoryCounter) Release(ctx context.Context, orgID, day string, points int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if accrual, ok := c.orgs[orgID]; ok && accrual.day == day {
+		accrual.points -= points
+		if accrual.points < 0 {
+			accrual.points = 0
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package ceiling
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test MemoryCounter
+func TestMemoryCounter_CrossedOnce(t *testing.T) {
+	counter := NewMemoryCounter()
+	ctx := context.Background()
+
+	first, err := counter.Reserve(ctx, "org_a", "2026-10-14", 200, 250, false)
+	require.NoError(t, err)
+	crossing, err := counter.Reserve(ctx, "org_a", "2026-10-14", 100, 250, false)
+	require.NoError(t, err)
+	later, err := counter.Reserve(ctx, "org_a", "2026-10-14", 100, 250, false)
+	require.NoError(t, err)
+
+	// Assertions - without pause every accrual is counted
+	assert.False(t, first.Crossed)
+	assert.True(t, crossing.Crossed)
+	assert.Equal(t, 300, crossing.Total)
+	assert.False(t, later.Crossed)
+	assert.False(t, later.Paused)
+	assert.Equal(t, 400, later.Total)
+}
+
+func TestMemoryCounter_ResetsEachDay(t *testing.T) {
+	counter := NewMemoryCounter()
+	ctx := context.Background()
+
+	check, err := counter.Reserve(ctx, "org_a", "2026-10-14", 300, 250, true)
+	require.NoError(t, err)
+	assert.True(t, check.Crossed)
+	assert.True(t, check.Paused)
+	check, err = counter.Reserve(ctx, "org_a", "2026-10-14", 10, 250, true)
+	require.NoError(t, err)
+	assert.True(t, check.Paused)
+
+	check, err = counter.Reserve(ctx, "org_a", "2026-10-15", 10, 250, true)
+
+	// Assertions
+	require.NoError(t, err)
+	assert.False(t, check.Paused)
+	assert.False(t, check.Crossed)
+	assert.Equal(t, 10, check.Total)
+}
+
+func TestMemoryCounter_Release(t *testing.T) {
+	counter := NewMemoryCounter()
+	ctx := context.Background()
+
+	_, err := counter.Reserve(ctx, "org_a", "2026-10-14", 200, 250, false)
+	require.NoError(t, err)
+	require.NoError(t, counter.Release(ctx, "org_a", "2026-10-14", 200))
+
+	// Assertions - the released points no longer count towards the ceiling
+	check, err := counter.Reserve(ctx, "org_a", "2026-10-14", 200, 250, false)
+	require.NoError(t, err)
+	assert.False(t, check.Crossed)
+	check, err = counter.Reserve(ctx, "org_b", "2026-10-14", 200, 250, false)
+	require.NoError(t, err)
+	assert.False(t, check.Crossed)
+}
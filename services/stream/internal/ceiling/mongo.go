@@ -0,0 +1,207 @@
+package ceiling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// accrualCeilingsCollection holds one document per org and day
+const accrualCeilingsCollection = "accrual_ceilings"
+
+// MongoCounterConfig controls how long a MongoCounter keeps past days
+type MongoCounterConfig struct {
+	// TTL is how long a day's count is kept after it last changed before
+	// MongoDB's TTL monitor removes it. Held points voided after that no
+	// longer release anything.
+	TTL time.Duration
+}
+
+func DefaultMongoCounterConfig() MongoCounterConfig {
+	return MongoCounterConfig{
+		TTL: 7 * 24 * time.Hour,
+	}
+}
+
+type orgDay struct {
+	OrgID     string    `bson:"org_id"`
+	Day       string    `bson:"day"`
+	Points    int       `bson:"points"`
+	Alerted   bool      `bson:"alerted"`
+	Paused    bool      `bson:"paused"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// MongoCounter counts accruals in MongoDB, so every processor instance and
+// the DLQ reprocessor share one ceiling per org
+type MongoCounter struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+	now        func() time.Time
+}
+
+func NewMongoCounter(uri, dbName string) (*MongoCounter, error) {
+	return NewMongoCounterWithConfig(uri, dbName, DefaultMongoCounterConfig())
+}
+
+func NewMongoCounterWithConfig(uri, dbName string, config MongoCounterConfig) (*MongoCounter, error) {
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	if err := client.Ping(context.TODO(), nil); err != nil {
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	counter := newMongoCounter(client.Database(dbName).Collection(accrualCeilingsCollection))
+	counter.client = client
+
+	if err := counter.createIndexes(context.TODO(), config); err != nil {
+		return nil, fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	return counter, nil
+}
+
+func newMongoCounter(collection *mongo.Collection) *MongoCounter {
+	return &MongoCounter{
+		collection: collection,
+		now:        time.Now,
+	}
+}
+
+// createIndexes makes org and day unique and expires days after the
+// configured TTL
+func (c *MongoCounter) createIndexes(ctx context.Context, config MongoCounterConfig) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "day", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	if config.TTL > 0 {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys:    bson.D{{Key: "updated_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(config.TTL / time.Second)),
+		})
+	}
+
+	_, err := c.collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Reserve adds points to the org's day unless it is paused, creating the day
+// on its first accrual. An upsert that finds the day paused, or loses the
+// race to create it, fails on the unique index instead and the day is read
+// back. Going over the ceiling marks the day alerted once, and with pause set
+// takes the points back out and pauses the day.
+func (c *MongoCounter) Reserve(ctx context.Context, orgID, day string, points, ceiling int, pause bool) (Check, error) {
+	counted, paused, err := c.add(ctx, orgID, day, points)
+	if err == nil && counted == nil && paused == nil {
+		// Lost the race to create the day, which now exists
+		counted, paused, err = c.add(ctx, orgID, day, points)
+	}
+	if err != nil {
+		return Check{}, err
+	}
+	if paused != nil {
+		return Check{Total: paused.Points, Paused: true}, nil
+	}
+	if counted == nil {
+		return Check{}, fmt.Errorf("failed to count accrual for org %s on %s", orgID, day)
+	}
+
+	check := Check{Total: counted.Points}
+	if check.Total <= ceiling {
+		return check, nil
+	}
+
+	filter := bson.M{"org_id": orgID, "day": day, "alerted": bson.M{"$ne": true}}
+	result, err := c.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"alerted": true}})
+	if err != nil {
+		return Check{}, fmt.Errorf("failed to mark accrual ceiling alerted: %w", err)
+	}
+	check.Crossed = result.ModifiedCount > 0
+
+	if pause {
+		filter := bson.M{"org_id": orgID, "day": day}
+		update := bson.M{
+			"$inc": bson.M{"points": -points},
+			"$set": bson.M{"paused": true, "updated_at": c.now()},
+		}
+		if _, err := c.collection.UpdateOne(ctx, filter, update); err != nil {
+			return Check{}, fmt.Errorf("failed to pause accrual: %w", err)
+		}
+		check.Total -= points
+		check.Paused = true
+	}
+
+	return check, nil
+}
+
+// add increments the org's day by points. counted is the day after the
+// increment. When the day already exists and is paused, nothing is counted
+// and paused is the day as stored. Both are nil when another reservation
+// created the day first.
+func (c *MongoCounter) add(ctx context.Context, orgID, day string, points int) (counted, paused *orgDay, err error) {
+	filter := bson.M{"org_id": orgID, "day": day, "paused": bson.M{"$ne": true}}
+	update := bson.M{
+		"$inc": bson.M{"points": points},
+		"$set": bson.M{"updated_at": c.now()},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var doc orgDay
+	err = c.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&doc)
+	if err == nil {
+		return &doc, nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, nil, fmt.Errorf("failed to count accrual: %w", err)
+	}
+
+	err = c.collection.FindOne(ctx, bson.M{"org_id": orgID, "day": day}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read accrual count: %w", err)
+	}
+	if doc.Paused {
+		return nil, &doc, nil
+	}
+	return nil, nil, nil
+}
+
+// Release subtracts points from the org's day without taking it below zero
+func (c *MongoCounter) Release(ctx context.Context, orgID, day string, points int) error {
+	filter := bson.M{"org_id": orgID, "day": day}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "points", Value: bson.D{{Key: "$max", Value: bson.A{
+				0,
+				bson.D{{Key: "$subtract", Value: bson.A{"$points", points}}},
+			}}}},
+			{Key: "updated_at", Value: c.now()},
+		}}},
+	}
+
+	if _, err := c.collection.UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("failed to release accrual: %w", err)
+	}
+
+	return nil
+}
+
+func (c *MongoCounter) Close(ctx context.Context) error {
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Disconnect(ctx)
+}
@@ -0,0 +1,147 @@
+package ceiling
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// counted is a findAndModify reply returning the org's day
+func counted(points int, paused bool) bson.D {
+	return bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "value", Value: bson.D{
+			{Key: "org_id", Value: "org_a"},
+			{Key: "day", Value: "2026-10-14"},
+			{Key: "points", Value: points},
+			{Key: "paused", Value: paused},
+		}},
+	}
+}
+
+// Test MongoCounter
+func TestMongoCounter_Reserve(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("counts points under the ceiling", func(mt *mtest.T) {
+		counter := newMongoCounter(mt.Coll)
+		mt.AddMockResponses(counted(200, false))
+
+		check, err := counter.Reserve(context.Background(), "org_a", "2026-10-14", 200, 250, true)
+
+		// Assertions
+		require.NoError(t, err)
+		assert.Equal(t, Check{Total: 200}, check)
+		command := mt.GetStartedEvent().Command
+		assert.Equal(t, "org_a", command.Lookup("query", "org_id").StringValue())
+		assert.Equal(t, "2026-10-14", command.Lookup("query", "day").StringValue())
+		assert.Equal(t, int32(200), command.Lookup("update", "$inc", "points").Int32())
+		assert.True(t, command.Lookup("upsert").Boolean())
+	})
+
+	mt.Run("first crossing alerts and pauses", func(mt *mtest.T) {
+		counter := newMongoCounter(mt.Coll)
+		mt.AddMockResponses(
+			counted(300, false),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		check, err := counter.Reserve(context.Background(), "org_a", "2026-10-14", 100, 250, true)
+
+		// Assertions - the refused points are taken back out
+		require.NoError(t, err)
+		assert.Equal(t, Check{Total: 200, Paused: true, Crossed: true}, check)
+		mt.GetStartedEvent()
+		mt.GetStartedEvent()
+		pause := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, int32(-100), pause.Lookup("u", "$inc", "points").Int32())
+		assert.True(t, pause.Lookup("u", "$set", "paused").Boolean())
+	})
+
+	mt.Run("later crossing does not alert again", func(mt *mtest.T) {
+		counter := newMongoCounter(mt.Coll)
+		mt.AddMockResponses(
+			counted(400, false),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+		)
+
+		check, err := counter.Reserve(context.Background(), "org_a", "2026-10-14", 100, 250, false)
+
+		// Assertions
+		require.NoError(t, err)
+		assert.Equal(t, Check{Total: 400}, check)
+	})
+
+	mt.Run("paused day refuses the accrual", func(mt *mtest.T) {
+		counter := newMongoCounter(mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}),
+			mtest.CreateCursorResponse(0, "test.accrual_ceilings", mtest.FirstBatch, bson.D{
+				{Key: "org_id", Value: "org_a"},
+				{Key: "day", Value: "2026-10-14"},
+				{Key: "points", Value: 200},
+				{Key: "paused", Value: true},
+			}),
+		)
+
+		check, err := counter.Reserve(context.Background(), "org_a", "2026-10-14", 10, 250, true)
+
+		// Assertions
+		require.NoError(t, err)
+		assert.Equal(t, Check{Total: 200, Paused: true}, check)
+	})
+
+	mt.Run("retries after losing the race to create the day", func(mt *mtest.T) {
+		counter := newMongoCounter(mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}),
+			mtest.CreateCursorResponse(0, "test.accrual_ceilings", mtest.FirstBatch, bson.D{
+				{Key: "org_id", Value: "org_a"},
+				{Key: "day", Value: "2026-10-14"},
+				{Key: "points", Value: 50},
+			}),
+			counted(60, false),
+		)
+
+		check, err := counter.Reserve(context.Background(), "org_a", "2026-10-14", 10, 250, true)
+
+		// Assertions
+		require.NoError(t, err)
+		assert.Equal(t, Check{Total: 60}, check)
+	})
+
+	mt.Run("write failure", func(mt *mtest.T) {
+		counter := newMongoCounter(mt.Coll)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 2, Message: "bad value"}))
+
+		_, err := counter.Reserve(context.Background(), "org_a", "2026-10-14", 10, 250, true)
+
+		// Assertions
+		assert.Error(t, err)
+	})
+}
+
+func TestMongoCounter_Release(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("subtracts without going below zero", func(mt *mtest.T) {
+		counter := newMongoCounter(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := counter.Release(context.Background(), "org_a", "2026-10-14", 200)
+
+		// Assertions
+		require.NoError(t, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "2026-10-14", update.Lookup("q", "day").StringValue())
+		points := update.Lookup("u").Array().Index(0).Value().Document().Lookup("$set", "points", "$max").Array()
+		assert.Equal(t, int32(0), points.Index(0).Value().Int32())
+	})
+}
@@ -0,0 +1,142 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// AnalyticsClientConfig holds the tunable behaviour of AnalyticsClient
+type AnalyticsClientConfig struct {
+	// MaxConcurrentRequests caps requests in flight to analytics; the rest
+	// wait their turn. Zero means no limit.
+	MaxConcurrentRequests int
+}
+
+func DefaultAnalyticsClientConfig() AnalyticsClientConfig {
+	return AnalyticsClientConfig{}
+}
+
+type AnalyticsClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// CustomerTier is the tier analytics has assigned a customer
+type CustomerTier struct {
+	OrgID            string  `json:"org_id"`
+	CustomerID       string  `json:"customer_id"`
+	CurrentTier      string  `json:"current_tier"`
+	PointsMultiplier float64 `json:"points_multiplier"`
+}
+
+// BenefitUsage is analytics' record of one redemption of a tier benefit
+type BenefitUsage struct {
+	Benefit string `json:"benefit"`
+	Tier    string `json:"tier"`
+	Period  string `json:"period"`
+	Use     int    `json:"use"`
+}
+
+type RedeemBenefitRequest struct {
+	OrgID     string `json:"org_id"`
+	Benefit   string `json:"benefit"`
+	Reference string `json:"reference"`
+}
+
+var (
+	// ErrTierNotFound is returned when analytics has not yet assigned the
+	// customer a tier
+	ErrTierNotFound = errors.New("customer tier not found")
+	// ErrBenefitNotInTier is returned by RedeemBenefit when the customer's
+	// tier does not include the benefit
+	ErrBenefitNotInTier = errors.New("benefit is not included in the customer's tier")
+	// ErrBenefitLimitReached is returned by RedeemBenefit when the benefit
+	// has no uses left this period
+	ErrBenefitLimitReached = errors.New("benefit usage limit reached for this period")
+)
+
+func NewAnalyticsClient(baseURL string) *AnalyticsClient {
+	return NewAnalyticsClientWithConfig(baseURL, DefaultAnalyticsClientConfig())
+}
+
+func NewAnalyticsClientWithConfig(baseURL string, config AnalyticsClientConfig) *AnalyticsClient {
+	return &AnalyticsClient{
+		baseURL:    baseURL,
+		httpClient: newHTTPClient(config.MaxConcurrentRequests),
+	}
+}
+
+func (c *AnalyticsClient) GetCustomerTier(ctx context.Context, orgID, customerID string) (*CustomerTier, error) {
+	path := "/api/v1/tiers/" + url.PathEscape(customerID) + "?" + url.Values{"org_id": {orgID}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer tier: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer tier: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrTierNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("analytics service returned status %d", resp.StatusCode)
+	}
+
+	var tier CustomerTier
+	if err := json.NewDecoder(resp.Body).Decode(&tier); err != nil {
+		return nil, fmt.Errorf("failed to decode customer tier: %w", err)
+	}
+
+	return &tier, nil
+}
+
+// RedeemBenefit records a use of one of the customer's tier benefits,
+// which analytics refuses once the benefit's frequency limit is reached
+func (c *AnalyticsClient) RedeemBenefit(ctx context.Context, orgID, customerID, benefit, reference string) (*BenefitUsage, error) {
+	jsonData, err := json.Marshal(RedeemBenefitRequest{OrgID: orgID, Benefit: benefit, Reference: reference})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	path := "/api/v1/tiers/" + url.PathEscape(customerID) + "/benefits"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem benefit: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem benefit: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusNotFound:
+		return nil, ErrTierNotFound
+	case http.StatusForbidden:
+		return nil, ErrBenefitNotInTier
+	case http.StatusConflict:
+		return nil, ErrBenefitLimitReached
+	default:
+		return nil, fmt.Errorf("analytics service returned status %d", resp.StatusCode)
+	}
+
+	var usage BenefitUsage
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		return nil, fmt.Errorf("failed to decode benefit usage: %w", err)
+	}
+
+	return &usage, nil
+}
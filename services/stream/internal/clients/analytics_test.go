@@ -0,0 +1,87 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test GetCustomerTier
+func TestAnalyticsClient_GetCustomerTier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/tiers/cust_1", r.URL.Path)
+		assert.Equal(t, "test_org", r.URL.Query().Get("org_id"))
+		json.NewEncoder(w).Encode(CustomerTier{OrgID: "test_org", CustomerID: "cust_1", CurrentTier: "Gold", PointsMultiplier: 1.5})
+	}))
+	t.Cleanup(server.Close)
+
+	tier, err := NewAnalyticsClient(server.URL).GetCustomerTier(context.Background(), "test_org", "cust_1")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "Gold", tier.CurrentTier)
+	assert.Equal(t, 1.5, tier.PointsMultiplier)
+}
+
+func TestAnalyticsClient_GetCustomerTierNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := NewAnalyticsClient(server.URL).GetCustomerTier(context.Background(), "test_org", "cust_1")
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrTierNotFound)
+}
+
+// Test RedeemBenefit
+func TestAnalyticsClient_RedeemBenefit(t *testing.T) {
+	var received RedeemBenefitRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/tiers/cust_1/benefits", r.URL.Path)
+		err := json.NewDecoder(r.Body).Decode(&received)
+		assert.NoError(t, err)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(BenefitUsage{Benefit: received.Benefit, Tier: "Silver", Period: "2026-10", Use: 1})
+	}))
+	t.Cleanup(server.Close)
+
+	usage, err := NewAnalyticsClient(server.URL).RedeemBenefit(context.Background(), "test_org", "cust_1", "Monthly offers", "ref_1")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, RedeemBenefitRequest{OrgID: "test_org", Benefit: "Monthly offers", Reference: "ref_1"}, received)
+	assert.Equal(t, "2026-10", usage.Period)
+	assert.Equal(t, 1, usage.Use)
+}
+
+func TestAnalyticsClient_RedeemBenefitRefused(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected error
+	}{
+		{http.StatusConflict, ErrBenefitLimitReached},
+		{http.StatusForbidden, ErrBenefitNotInTier},
+		{http.StatusNotFound, ErrTierNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			t.Cleanup(server.Close)
+
+			_, err := NewAnalyticsClient(server.URL).RedeemBenefit(context.Background(), "test_org", "cust_1", "Monthly offers", "")
+
+			// Assertions
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// StatusError reports a response from a service with an unexpected status
+type StatusError struct {
+	Service    string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s service returned status %d", e.Service, e.StatusCode)
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying: a
+// network error or a 429 or 5xx response. Cancellation, 4xx responses and
+// errors such as an insufficient balance are permanent.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// IsUnsent reports whether err is a failure to reach the service at all, such
+// as a refused connection or an unresolvable host, so the request was never
+// sent. Only these are safe to retry for writes: the ledger does not
+// deduplicate transfers, so resending after a timeout or a 5xx could apply
+// the transfer twice.
+func IsUnsent(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,70 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test IsRetryable
+func TestIsRetryable(t *testing.T) {
+	refused := fmt.Errorf("failed to make request: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"network error", refused, true},
+		{"service unavailable", &StatusError{Service: "ledger", StatusCode: 503}, true},
+		{"too many requests", &StatusError{Service: "ledger", StatusCode: 429}, true},
+		{"unprocessable", &StatusError{Service: "ledger", StatusCode: 422}, false},
+		{"insufficient balance", ErrInsufficientBalance, false},
+		{"cancelled", fmt.Errorf("failed to make request: %w", context.Canceled), false},
+		{"validation", errors.New("points transfer amount must be positive, got 0"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsRetryable(tt.err))
+		})
+	}
+}
+
+// Test IsUnsent
+func TestIsUnsent(t *testing.T) {
+	refused := fmt.Errorf("failed to make request: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+	reset := fmt.Errorf("failed to make request: %w", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")})
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"connection refused", refused, true},
+		{"reset after sending", reset, false},
+		{"client timeout", fmt.Errorf("failed to make request: %w", timeoutError{}), false},
+		{"service unavailable", &StatusError{Service: "ledger", StatusCode: 503}, false},
+		{"insufficient balance", ErrInsufficientBalance, false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsUnsent(tt.err))
+		})
+	}
+}
+
+// timeoutError is a net.Error like the one http.Client returns when its
+// timeout expires while waiting for a response
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "Client.Timeout exceeded while awaiting headers" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -1,13 +1,33 @@
 package clients
 
-// LedgerClientInterface defines the interface for ledger client operations
+import (
+	"context"
+	"time"
+)
+
+// LedgerClientInterface defines the interface for ledger client operations.
+// saleCents is the POS sale points were earned on, in cents, or zero when
+// they were not; it is kept with the transfer so refunds can reverse a share
+// of what the sale earned.
 type LedgerClientInterface interface {
-	CreatePointsTransfer(orgID, customerID string, points int, reference string) (*TransferResponse, error)
-	CreateStampsTransfer(orgID, customerID string, stamps int, reference string) (*TransferResponse, error)
+	CreatePointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int) (*TransferResponse, error)
+	CreatePendingPointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int, settleAfter time.Duration) (*TransferResponse, error)
+	SettlePendingTransfer(ctx context.Context, orgID, customerID, reference string, void bool, points int) (*TransferResponse, error)
+	CreateStampsTransfer(ctx context.Context, orgID, customerID string, stamps int, reference string) (*TransferResponse, error)
+	CreateRedemption(ctx context.Context, orgID, customerID, rewardID string, points, stamps, bonusPoints, bonusExpiryDays int, reference string) (*RedemptionResponse, error)
+	GetBalance(ctx context.Context, orgID, customerID string) (*Balance, error)
+	GetTransfers(ctx context.Context, orgID, customerID string, references ...string) ([]Transfer, error)
 }
 
 // MembershipClientInterface defines the interface for membership client operations
 type MembershipClientInterface interface {
-	GetCustomer(customerID string) (*Customer, error)
-	GetOrganization(orgID string) (*Organization, error)
-} 
\ No newline at end of file
+	GetCustomer(ctx context.Context, customerID string) (*Customer, error)
+	GetOrganization(ctx context.Context, orgID string) (*Organization, error)
+	GetLocation(ctx context.Context, locationID string) (*Location, error)
+} 
+
+// AnalyticsClientInterface defines the interface for analytics client operations
+type AnalyticsClientInterface interface {
+	GetCustomerTier(ctx context.Context, orgID, customerID string) (*CustomerTier, error)
+	RedeemBenefit(ctx context.Context, orgID, customerID, benefit, reference string) (*BenefitUsage, error)
+}
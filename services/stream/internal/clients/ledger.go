@@ -2,15 +2,53 @@ package clients
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/loyalty/ledger/pkg/codes"
+)
+
+// Transfer codes are the ledger's own
+const (
+	TransferCodePoints = codes.Points
+	TransferCodeStamps = codes.Stamps
 )
 
+// ErrInsufficientBalance is returned when the ledger refuses a redemption the
+// customer's balance cannot cover
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// ErrNoPendingTransfer is returned when there are no pending points to settle
+// for a reference, because none were held or they were already settled
+var ErrNoPendingTransfer = errors.New("no pending transfer to settle")
+
+// LedgerClientConfig holds the transfer codes sent to the ledger. Deployments
+// backed by a ledger with its own code scheme can override them.
+type LedgerClientConfig struct {
+	PointsCode uint16
+	StampsCode uint16
+	// MaxConcurrentRequests caps requests in flight to the ledger; the rest
+	// wait their turn. Zero means no limit.
+	MaxConcurrentRequests int
+}
+
+func DefaultLedgerClientConfig() LedgerClientConfig {
+	return LedgerClientConfig{
+		PointsCode: TransferCodePoints,
+		StampsCode: TransferCodeStamps,
+	}
+}
+
 type LedgerClient struct {
 	baseURL    string
 	httpClient *http.Client
+	config     LedgerClientConfig
 }
 
 type CreateTransferRequest struct {
@@ -20,66 +58,326 @@ type CreateTransferRequest struct {
 	Amount          uint64 `json:"amount"`
 	Code            uint16 `json:"code"`
 	Reference       string `json:"reference"`
+	UserData        uint64 `json:"user_data,omitempty"`
+	ExpiryDays      int    `json:"expiry_days,omitempty"`
+	Pending            bool   `json:"pending,omitempty"`
+	SettleAfterSeconds uint64 `json:"settle_after_seconds,omitempty"`
+}
+
+type SettleTransferRequest struct {
+	OrgID      string `json:"org_id"`
+	CustomerID string `json:"customer_id"`
+	Reference  string `json:"reference"`
+	Void       bool   `json:"void"`
+	Amount     uint64 `json:"amount,omitempty"`
+}
+
+// TransferResponse reports a created or settled transfer. Settling a pending
+// transfer sets Amount to the points posted or voided, which is less than
+// asked for when fewer were still held, and HeldAt to when they were first
+// held as a Unix timestamp.
+// Transfer is a ledger transfer as GetTransfers lists it. Stamps is set when
+// it moves stamps rather than points. PendingStatus is set on a two-phase
+// accrual and Voided counts the points released from it; PendingID is set on
+// the transfer that posts one.
+type Transfer struct {
+	ID            string `json:"id"`
+	Amount        uint64 `json:"amount"`
+	Code          uint16 `json:"code"`
+	Reference     string `json:"reference"`
+	UserData      uint64 `json:"user_data"`
+	PendingStatus string `json:"pending_status"`
+	Voided        uint64 `json:"voided"`
+	PendingID     string `json:"pending_id"`
+	ReversedBy    string `json:"reversed_by"`
+	Stamps        bool   `json:"-"`
 }
 
 type TransferResponse struct {
 	TransferID string `json:"transfer_id"`
 	Status     string `json:"status"`
+	Amount     uint64 `json:"amount,omitempty"`
+	HeldAt     int64  `json:"held_at,omitempty"`
+}
+
+type CreateRedemptionRequest struct {
+	OrgID       string `json:"org_id"`
+	CustomerID  string `json:"customer_id"`
+	RewardID    string `json:"reward_id"`
+	Points      uint64 `json:"points"`
+	Stamps      uint64 `json:"stamps"`
+	BonusPoints uint64 `json:"bonus_points"`
+	Reference   string `json:"reference"`
+	BonusExpiryDays int `json:"bonus_expiry_days,omitempty"`
+}
+
+type RedemptionResponse struct {
+	TransferIDs []string `json:"transfer_ids"`
+	Status      string   `json:"status"`
+}
+
+type Balance struct {
+	OrgID         string `json:"org_id"`
+	CustomerID    string `json:"customer_id"`
+	PointsBalance uint64 `json:"points_balance"`
+	StampsBalance uint64 `json:"stamps_balance"`
 }
 
 func NewLedgerClient(baseURL string) *LedgerClient {
+	return NewLedgerClientWithConfig(baseURL, DefaultLedgerClientConfig())
+}
+
+func NewLedgerClientWithConfig(baseURL string, config LedgerClientConfig) *LedgerClient {
 	return &LedgerClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		baseURL:    baseURL,
+		httpClient: newHTTPClient(config.MaxConcurrentRequests),
+		config:     config,
 	}
 }
 
-func (c *LedgerClient) CreatePointsTransfer(orgID, customerID string, points int, reference string) (*TransferResponse, error) {
+func (c *LedgerClient) CreatePointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int) (*TransferResponse, error) {
+	if points <= 0 {
+		return nil, fmt.Errorf("points transfer amount must be positive, got %d", points)
+	}
+
 	req := CreateTransferRequest{
 		OrgID:           orgID,
 		CustomerID:      customerID,
 		TransactionType: "points_accrual",
 		Amount:          uint64(points),
-		Code:            1,
+		Code:            c.config.PointsCode,
 		Reference:       reference,
+		UserData:        saleCents,
+		ExpiryDays:      expiryDays,
+	}
+
+	return c.createTransfer(ctx, req)
+}
+
+// CreatePendingPointsTransfer holds points for the customer without crediting
+// them until SettlePendingTransfer posts them or, when settleAfter is
+// positive, the ledger does once that long has passed
+func (c *LedgerClient) CreatePendingPointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int, settleAfter time.Duration) (*TransferResponse, error) {
+	if points <= 0 {
+		return nil, fmt.Errorf("points transfer amount must be positive, got %d", points)
+	}
+
+	req := CreateTransferRequest{
+		OrgID:              orgID,
+		CustomerID:         customerID,
+		TransactionType:    "points_accrual",
+		Amount:             uint64(points),
+		Code:               c.config.PointsCode,
+		Reference:          reference,
+		UserData:           saleCents,
+		ExpiryDays:         expiryDays,
+		Pending:            true,
+		SettleAfterSeconds: uint64(settleAfter / time.Second),
 	}
 
-	return c.createTransfer(req)
+	return c.createTransfer(ctx, req)
 }
 
-func (c *LedgerClient) CreateStampsTransfer(orgID, customerID string, stamps int, reference string) (*TransferResponse, error) {
+// SettlePendingTransfer posts the points held with reference, or voids them
+// when void is set. A positive points voids only that many of them.
+func (c *LedgerClient) SettlePendingTransfer(ctx context.Context, orgID, customerID, reference string, void bool, points int) (*TransferResponse, error) {
+	if points < 0 {
+		return nil, fmt.Errorf("void amount must not be negative, got %d", points)
+	}
+
+	req := SettleTransferRequest{
+		OrgID:      orgID,
+		CustomerID: customerID,
+		Reference:  reference,
+		Void:       void,
+		Amount:     uint64(points),
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.post(ctx, "/api/v1/transfers/settle", jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return nil, ErrNoPendingTransfer
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Service: "ledger", StatusCode: resp.StatusCode}
+	}
+
+	var response TransferResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *LedgerClient) CreateStampsTransfer(ctx context.Context, orgID, customerID string, stamps int, reference string) (*TransferResponse, error) {
+	if stamps <= 0 {
+		return nil, fmt.Errorf("stamps transfer amount must be positive, got %d", stamps)
+	}
+
 	req := CreateTransferRequest{
 		OrgID:           orgID,
 		CustomerID:      customerID,
 		TransactionType: "stamps_accrual",
 		Amount:          uint64(stamps),
-		Code:            2,
+		Code:            c.config.StampsCode,
 		Reference:       reference,
 	}
 
-	return c.createTransfer(req)
+	return c.createTransfer(ctx, req)
+}
+
+// CreateRedemption spends points and/or stamps on a reward and credits
+// bonusPoints in the same ledger operation
+func (c *LedgerClient) CreateRedemption(ctx context.Context, orgID, customerID, rewardID string, points, stamps, bonusPoints, bonusExpiryDays int, reference string) (*RedemptionResponse, error) {
+	if points < 0 || stamps < 0 || bonusPoints < 0 {
+		return nil, fmt.Errorf("redemption amounts must not be negative")
+	}
+	if points == 0 && stamps == 0 {
+		return nil, fmt.Errorf("redemption must cost points or stamps")
+	}
+
+	req := CreateRedemptionRequest{
+		OrgID:       orgID,
+		CustomerID:  customerID,
+		RewardID:    rewardID,
+		Points:      uint64(points),
+		Stamps:      uint64(stamps),
+		BonusPoints: uint64(bonusPoints),
+		Reference:   reference,
+		BonusExpiryDays: bonusExpiryDays,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.post(ctx, "/api/v1/redemptions", jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return nil, ErrInsufficientBalance
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, &StatusError{Service: "ledger", StatusCode: resp.StatusCode}
+	}
+
+	var response RedemptionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *LedgerClient) GetBalance(ctx context.Context, orgID, customerID string) (*Balance, error) {
+	query := url.Values{}
+	query.Set("org_id", orgID)
+	query.Set("customer_id", customerID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/balance?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Service: "ledger", StatusCode: resp.StatusCode}
+	}
+
+	var balance Balance
+	if err := json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &balance, nil
 }
 
-func (c *LedgerClient) createTransfer(req CreateTransferRequest) (*TransferResponse, error) {
+// transfersPageSize is how many transfers GetTransfers asks the ledger for at
+// a time
+const transfersPageSize = 100
+
+// GetTransfers returns every one of the customer's transfers with one of
+// references, paging through the ledger until they run out
+func (c *LedgerClient) GetTransfers(ctx context.Context, orgID, customerID string, references ...string) ([]Transfer, error) {
+	var transfers []Transfer
+	for offset := 0; ; offset += transfersPageSize {
+		query := url.Values{}
+		query.Set("org_id", orgID)
+		query.Set("customer_id", customerID)
+		query.Set("limit", strconv.Itoa(transfersPageSize))
+		query.Set("offset", strconv.Itoa(offset))
+		for _, reference := range references {
+			query.Add("reference", reference)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v1/transfers?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+
+		var page struct {
+			Transfers []Transfer `json:"transfers"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, &StatusError{Service: "ledger", StatusCode: resp.StatusCode}
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		for _, transfer := range page.Transfers {
+			transfer.Stamps = transfer.Code == c.config.StampsCode
+			transfers = append(transfers, transfer)
+		}
+		if len(page.Transfers) < transfersPageSize {
+			return transfers, nil
+		}
+	}
+}
+
+func (c *LedgerClient) createTransfer(ctx context.Context, req CreateTransferRequest) (*TransferResponse, error) {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.httpClient.Post(
-		c.baseURL+"/api/v1/transfers",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	resp, err := c.post(ctx, "/api/v1/transfers", jsonData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+		return nil, &StatusError{Service: "ledger", StatusCode: resp.StatusCode}
 	}
 
 	var response TransferResponse
@@ -88,4 +386,15 @@ func (c *LedgerClient) createTransfer(req CreateTransferRequest) (*TransferRespo
 	}
 
 	return &response, nil
-}
\ No newline at end of file
+}
+
+// post sends a JSON body to path, cancelled along with ctx
+func (c *LedgerClient) post(ctx context.Context, path string, jsonData []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.httpClient.Do(req)
+}
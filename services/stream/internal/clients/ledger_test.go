@@ -0,0 +1,209 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test setup helper - a ledger stub that records each transfer request
+func setupTestLedger(t *testing.T) (*httptest.Server, *[]CreateTransferRequest) {
+	var received []CreateTransferRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req CreateTransferRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.NoError(t, err)
+		received = append(received, req)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(TransferResponse{TransferID: "transfer_1", Status: "created"})
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+// Test transfer codes
+func TestLedgerClient_DefaultTransferCodes(t *testing.T) {
+	server, received := setupTestLedger(t)
+	client := NewLedgerClient(server.URL)
+
+	_, err := client.CreatePointsTransfer(context.Background(), "test_org", "test_customer", 50, "ref_points", 0, 30)
+	assert.NoError(t, err)
+	_, err = client.CreateStampsTransfer(context.Background(), "test_org", "test_customer", 1, "ref_stamps")
+	assert.NoError(t, err)
+
+	// Assertions
+	assert.Len(t, *received, 2)
+	assert.Equal(t, "points_accrual", (*received)[0].TransactionType)
+	assert.Equal(t, TransferCodePoints, (*received)[0].Code)
+	assert.Equal(t, 30, (*received)[0].ExpiryDays)
+	assert.Equal(t, "stamps_accrual", (*received)[1].TransactionType)
+	assert.Equal(t, TransferCodeStamps, (*received)[1].Code)
+}
+
+func TestLedgerClient_CustomTransferCodes(t *testing.T) {
+	server, received := setupTestLedger(t)
+	client := NewLedgerClientWithConfig(server.URL, LedgerClientConfig{PointsCode: 101, StampsCode: 102})
+
+	_, err := client.CreatePointsTransfer(context.Background(), "test_org", "test_customer", 50, "ref_points", 0, 0)
+	assert.NoError(t, err)
+	_, err = client.CreateStampsTransfer(context.Background(), "test_org", "test_customer", 1, "ref_stamps")
+	assert.NoError(t, err)
+
+	// Assertions
+	assert.Len(t, *received, 2)
+	assert.Equal(t, uint16(101), (*received)[0].Code)
+	assert.Equal(t, uint16(102), (*received)[1].Code)
+}
+
+// Test CreateRedemption
+func TestLedgerClient_CreateRedemption(t *testing.T) {
+	var received CreateRedemptionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/redemptions", r.URL.Path)
+		err := json.NewDecoder(r.Body).Decode(&received)
+		assert.NoError(t, err)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(RedemptionResponse{TransferIDs: []string{"transfer_1", "transfer_2"}, Status: "success"})
+	}))
+	t.Cleanup(server.Close)
+	client := NewLedgerClient(server.URL)
+
+	response, err := client.CreateRedemption(context.Background(), "test_org", "test_customer", "free_coffee", 300, 0, 25, 30, "ref_redeem")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, response.TransferIDs, 2)
+	assert.Equal(t, "free_coffee", received.RewardID)
+	assert.Equal(t, uint64(300), received.Points)
+	assert.Equal(t, uint64(25), received.BonusPoints)
+	assert.Equal(t, 30, received.BonusExpiryDays)
+}
+
+func TestLedgerClient_CreateRedemption_InsufficientBalance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	t.Cleanup(server.Close)
+	client := NewLedgerClient(server.URL)
+
+	_, err := client.CreateRedemption(context.Background(), "test_org", "test_customer", "free_coffee", 300, 0, 25, 0, "ref_redeem")
+
+	// Assertions
+	assert.EqualError(t, err, "insufficient balance")
+}
+
+// Test pending transfers
+func TestLedgerClient_CreatePendingPointsTransfer(t *testing.T) {
+	server, received := setupTestLedger(t)
+	client := NewLedgerClient(server.URL)
+
+	_, err := client.CreatePendingPointsTransfer(context.Background(), "test_org", "test_customer", 50, "ref_points", 5000, 30, 48*time.Hour)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, *received, 1)
+	assert.True(t, (*received)[0].Pending)
+	assert.Equal(t, uint64(172800), (*received)[0].SettleAfterSeconds)
+	assert.Equal(t, "points_accrual", (*received)[0].TransactionType)
+	assert.Equal(t, uint64(5000), (*received)[0].UserData)
+	assert.Equal(t, 30, (*received)[0].ExpiryDays)
+}
+
+func TestLedgerClient_SettlePendingTransfer(t *testing.T) {
+	var received SettleTransferRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/transfers/settle", r.URL.Path)
+		err := json.NewDecoder(r.Body).Decode(&received)
+		assert.NoError(t, err)
+
+		json.NewEncoder(w).Encode(TransferResponse{TransferID: "transfer_1", Status: "voided"})
+	}))
+	t.Cleanup(server.Close)
+	client := NewLedgerClient(server.URL)
+
+	response, err := client.SettlePendingTransfer(context.Background(), "test_org", "test_customer", "ref_points", true, 20)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "voided", response.Status)
+	assert.Equal(t, "ref_points", received.Reference)
+	assert.True(t, received.Void)
+	assert.Equal(t, uint64(20), received.Amount)
+}
+
+func TestLedgerClient_SettlePendingTransfer_NothingPending(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}))
+	t.Cleanup(server.Close)
+	client := NewLedgerClient(server.URL)
+
+	_, err := client.SettlePendingTransfer(context.Background(), "test_org", "test_customer", "ref_points", false, 0)
+
+	// Assertions
+	assert.ErrorIs(t, err, ErrNoPendingTransfer)
+}
+
+// Test cancellation
+func TestLedgerClient_CancelledContextAbortsRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	client := NewLedgerClient(server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.CreatePointsTransfer(ctx, "test_org", "test_customer", 50, "ref_points", 0, 0)
+
+	// Assertions - the call returns on cancel rather than at the client timeout
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+// Test GetTransfers
+func TestLedgerClient_GetTransfersPagesByReference(t *testing.T) {
+	var offsets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/transfers", r.URL.Path)
+		assert.Equal(t, []string{"pos_transaction_txn_1", "pos_refund_txn_1"}, r.URL.Query()["reference"])
+		offsets = append(offsets, r.URL.Query().Get("offset"))
+
+		// One full page, then a short one
+		count := transfersPageSize
+		if len(offsets) > 1 {
+			count = 2
+		}
+		transfers := make([]Transfer, count)
+		for i := range transfers {
+			transfers[i] = Transfer{ID: "transfer", Amount: 10, Code: TransferCodePoints, Reference: "pos_transaction_txn_1"}
+		}
+		transfers[0].Code = TransferCodeStamps
+		json.NewEncoder(w).Encode(map[string]interface{}{"transfers": transfers})
+	}))
+	t.Cleanup(server.Close)
+	client := NewLedgerClient(server.URL)
+
+	transfers, err := client.GetTransfers(context.Background(), "test_org", "test_customer", "pos_transaction_txn_1", "pos_refund_txn_1")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "100"}, offsets)
+	assert.Len(t, transfers, transfersPageSize+2)
+	assert.True(t, transfers[0].Stamps)
+	assert.False(t, transfers[1].Stamps)
+}
@@ -0,0 +1,73 @@
+package clients
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// newHTTPClient builds the client used to call a service, capping in-flight
+// requests at maxConcurrent when it is positive
+func newHTTPClient(maxConcurrent int) *http.Client {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if maxConcurrent > 0 {
+		// Keep as many idle connections as requests may be in flight, so a
+		// busy pool reuses them instead of redialing
+		base := http.DefaultTransport.(*http.Transport).Clone()
+		base.MaxIdleConnsPerHost = maxConcurrent
+		client.Transport = newLimitedTransport(base, maxConcurrent)
+	}
+	return client
+}
+
+// limitedTransport caps how many requests are in flight through it. A request
+// holds its slot until its response body is closed, since the connection is
+// busy until then. Requests beyond the cap wait for a slot or for their
+// context to end.
+type limitedTransport struct {
+	base  http.RoundTripper
+	slots chan struct{}
+}
+
+// newLimitedTransport wraps base so at most maxConcurrent requests are in
+// flight. A maxConcurrent of zero or less returns base unchanged.
+func newLimitedTransport(base http.RoundTripper, maxConcurrent int) http.RoundTripper {
+	if maxConcurrent <= 0 {
+		return base
+	}
+	return &limitedTransport{
+		base:  base,
+		slots: make(chan struct{}, maxConcurrent),
+	}
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-t.slots
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-t.slots }}
+	return resp, nil
+}
+
+// releasingBody frees its request's slot the first time it is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
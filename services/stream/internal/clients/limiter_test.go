@@ -0,0 +1,123 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test setup helper - a slow server that records the most requests it saw in
+// flight at once
+func setupSlowServer(t *testing.T, delay time.Duration, body interface{}, status int) (*httptest.Server, *int32) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt32(&peak)
+			if current <= observed || atomic.CompareAndSwapInt32(&peak, observed, current) {
+				break
+			}
+		}
+
+		time.Sleep(delay)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &peak
+}
+
+// Test MaxConcurrentRequests
+func TestLedgerClient_MaxConcurrentRequests(t *testing.T) {
+	server, peak := setupSlowServer(t, 20*time.Millisecond, TransferResponse{TransferID: "transfer_1", Status: "success"}, http.StatusCreated)
+	config := DefaultLedgerClientConfig()
+	config.MaxConcurrentRequests = 3
+	client := NewLedgerClientWithConfig(server.URL, config)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.CreatePointsTransfer(context.Background(), "test_org", "test_customer", 10, "ref", 0, 0)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Assertions
+	assert.LessOrEqual(t, atomic.LoadInt32(peak), int32(3))
+	assert.Greater(t, atomic.LoadInt32(peak), int32(0))
+}
+
+func TestMembershipClient_MaxConcurrentRequests(t *testing.T) {
+	server, peak := setupSlowServer(t, 20*time.Millisecond, Customer{CustomerID: "test_customer"}, http.StatusOK)
+	client := NewMembershipClientWithConfig(server.URL, MembershipClientConfig{MaxConcurrentRequests: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			customer, err := client.GetCustomer(context.Background(), "test_customer")
+			assert.NoError(t, err)
+			assert.Equal(t, "test_customer", customer.CustomerID)
+		}()
+	}
+	wg.Wait()
+
+	// Assertions
+	assert.LessOrEqual(t, atomic.LoadInt32(peak), int32(2))
+}
+
+func TestMembershipClient_ErrorResponsesReleaseSlots(t *testing.T) {
+	server, _ := setupSlowServer(t, 0, map[string]string{"error": "not found"}, http.StatusNotFound)
+	client := NewMembershipClientWithConfig(server.URL, MembershipClientConfig{MaxConcurrentRequests: 1})
+
+	// With one slot, a leaked slot would block the second call forever
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			_, err := client.GetCustomer(context.Background(), "missing_customer")
+			assert.EqualError(t, err, "customer not found")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("requests blocked waiting for a slot")
+	}
+}
+
+func TestLimitedTransport_QueuedRequestHonorsContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	httpClient := newHTTPClient(1)
+
+	// The first request holds the only slot
+	go httpClient.Get(server.URL)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err := httpClient.Do(req)
+
+	// Assertions
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -1,12 +1,25 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 )
 
+// MembershipClientConfig holds the tunable behaviour of MembershipClient
+type MembershipClientConfig struct {
+	// MaxConcurrentRequests caps requests in flight to membership; the rest
+	// wait their turn. Zero means no limit.
+	MaxConcurrentRequests int
+}
+
+func DefaultMembershipClientConfig() MembershipClientConfig {
+	return MembershipClientConfig{}
+}
+
 type MembershipClient struct {
 	baseURL    string
 	httpClient *http.Client
@@ -22,6 +35,10 @@ type Customer struct {
 	Status      string                 `json:"status"`
 	Preferences CustomerPrefs          `json:"preferences"`
 	Metadata    map[string]interface{} `json:"metadata"`
+	// CustomMultiplier scales the customer's points on top of any tier and
+	// promotion multipliers until MultiplierExpiresAt
+	CustomMultiplier    float64    `json:"custom_multiplier,omitempty"`
+	MultiplierExpiresAt *time.Time `json:"multiplier_expires_at,omitempty"`
 }
 
 type CustomerPrefs struct {
@@ -43,14 +60,86 @@ type OrgSettings struct {
 	RewardThresholds   []RewardThreshold `json:"reward_thresholds"`
 	TierRules          []TierRule        `json:"tier_rules"`
 	MaxStampsPerCard   int               `json:"max_stamps_per_card"`
+	MaxStampsPerTransaction int          `json:"max_stamps_per_transaction"`
+	RewardMode         string            `json:"reward_mode"`
+	RewardThresholdBasis string          `json:"reward_threshold_basis"`
+	RedemptionBonuses  []RedemptionBonus `json:"redemption_bonuses"`
+	PointsExpiryDays   int               `json:"points_expiry_days"`
+	Currency           string            `json:"currency"`
+	Locale             string            `json:"locale"`
+	UnknownLocationPolicy string         `json:"unknown_location_policy"`
+	CategoryMultipliers map[string]float64 `json:"category_multipliers"`
+	RoundingGranularity string           `json:"rounding_granularity"`
+	DailyPointsCeiling int               `json:"daily_points_ceiling"`
+	PauseAccrualAtCeiling bool           `json:"pause_accrual_at_ceiling"`
+	InactiveCustomerPolicy string        `json:"inactive_customer_policy"`
+	PendingAccrual        bool           `json:"pending_accrual"`
+	SettlementDelayHours  int            `json:"settlement_delay_hours"`
 }
 
+// Reward modes control how many thresholds fire when a customer qualifies for
+// several at once. An empty mode behaves like RewardModeAll.
+const (
+	RewardModeAll     = "all"
+	RewardModeHighest = "highest"
+)
+
+// Unknown location policies control POS events whose location_id is not a
+// location of the org. An empty policy behaves like UnknownLocationLenient.
+const (
+	UnknownLocationLenient = "lenient"
+	UnknownLocationStrict  = "strict"
+)
+
+// CustomerStatusActive is the status of a customer who may accrue. An empty
+// status is treated as active.
+const CustomerStatusActive = "active"
+
+// Inactive customer policies control POS transactions of customers whose
+// status is not active, such as suspended or deleted ones. An empty policy
+// behaves like InactiveCustomerSkip.
+const (
+	InactiveCustomerSkip = "skip"
+	InactiveCustomerFlag = "flag"
+)
+
+// Rounding granularities control where fractional points are dropped when
+// category multipliers apply per line item. An empty granularity behaves like
+// RoundingTransaction.
+const (
+	RoundingTransaction = "transaction"
+	RoundingItem        = "item"
+)
+
+// ErrLocationNotFound is returned by GetLocation when membership has no such
+// location
+var ErrLocationNotFound = errors.New("location not found")
+
+// Reward threshold bases control what a threshold is compared against. An
+// empty basis behaves like RewardBasisLifetime.
+const (
+	RewardBasisLifetime = "lifetime"
+	RewardBasisPerEvent = "per_event"
+)
+
 type RewardThreshold struct {
+	// RewardID identifies the reward when it is redeemed; empty means
+	// reward_<points>_<stamps>
+	RewardID    string `json:"reward_id,omitempty"`
 	Points      int    `json:"points"`
 	Stamps      int    `json:"stamps"`
 	RewardType  string `json:"reward_type"`
 	RewardValue string `json:"reward_value"`
 	Description string `json:"description"`
+	// Amount is the reward's monetary value, rendered in place of {amount}
+	// in Description using the org's currency and locale
+	Amount      float64 `json:"amount"`
+}
+
+// RedemptionBonus credits BonusPoints whenever RewardID is redeemed
+type RedemptionBonus struct {
+	RewardID    string `json:"reward_id"`
+	BonusPoints int    `json:"bonus_points"`
 }
 
 type TierRule struct {
@@ -61,17 +150,59 @@ type TierRule struct {
 	Benefits         []string `json:"benefits"`
 }
 
+type Location struct {
+	LocationID     string           `json:"location_id"`
+	OrgID          string           `json:"org_id"`
+	Name           string           `json:"name"`
+	Settings       LocationSettings `json:"settings"`
+	OperatingHours OperatingHours   `json:"operating_hours"`
+	Active         bool             `json:"active"`
+}
+
+type LocationSettings struct {
+	PointsMultiplier float64         `json:"points_multiplier"`
+	AllowStamps      bool            `json:"allow_stamps"`
+	TimePromotions   []TimePromotion `json:"time_promotions"`
+}
+
+// OperatingHours holds a location's timezone and weekly opening times. An
+// empty timezone means UTC, and no days means the location is always open.
+type OperatingHours struct {
+	Timezone string     `json:"timezone"`
+	Days     []DayHours `json:"days"`
+}
+
+// DayHours is one day's opening times as "HH:MM" in the location's local
+// time. A close before open runs past midnight.
+type DayHours struct {
+	Day   string `json:"day"`
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
+// TimePromotion multiplies points earned between Start and End local store
+// time. No days means every day.
+type TimePromotion struct {
+	Name             string   `json:"name"`
+	Days             []string `json:"days"`
+	Start            string   `json:"start"`
+	End              string   `json:"end"`
+	PointsMultiplier float64  `json:"points_multiplier"`
+}
+
 func NewMembershipClient(baseURL string) *MembershipClient {
+	return NewMembershipClientWithConfig(baseURL, DefaultMembershipClientConfig())
+}
+
+func NewMembershipClientWithConfig(baseURL string, config MembershipClientConfig) *MembershipClient {
 	return &MembershipClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		baseURL:    baseURL,
+		httpClient: newHTTPClient(config.MaxConcurrentRequests),
 	}
 }
 
-func (c *MembershipClient) GetCustomer(customerID string) (*Customer, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/customers/" + customerID)
+func (c *MembershipClient) GetCustomer(ctx context.Context, customerID string) (*Customer, error) {
+	resp, err := c.get(ctx, "/api/v1/customers/"+customerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get customer: %w", err)
 	}
@@ -93,8 +224,8 @@ func (c *MembershipClient) GetCustomer(customerID string) (*Customer, error) {
 	return &customer, nil
 }
 
-func (c *MembershipClient) GetOrganization(orgID string) (*Organization, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/organizations/" + orgID)
+func (c *MembershipClient) GetOrganization(ctx context.Context, orgID string) (*Organization, error) {
+	resp, err := c.get(ctx, "/api/v1/organizations/"+orgID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get organization: %w", err)
 	}
@@ -114,4 +245,36 @@ func (c *MembershipClient) GetOrganization(orgID string) (*Organization, error)
 	}
 
 	return &org, nil
-}
\ No newline at end of file
+}
+func (c *MembershipClient) GetLocation(ctx context.Context, locationID string) (*Location, error) {
+	resp, err := c.get(ctx, "/api/v1/locations/"+locationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrLocationNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("membership service returned status %d", resp.StatusCode)
+	}
+
+	var location Location
+	if err := json.NewDecoder(resp.Body).Decode(&location); err != nil {
+		return nil, fmt.Errorf("failed to decode location: %w", err)
+	}
+
+	return &location, nil
+}
+
+// get fetches path, cancelled along with ctx
+func (c *MembershipClient) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.httpClient.Do(req)
+}
@@ -0,0 +1,24 @@
+package dlq
+
+import (
+	"context"
+
+	"github.com/loyalty/stream/internal/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// EventHandler processes one event. *processor.EventProcessor satisfies it.
+type EventHandler interface {
+	ProcessEvent(ctx context.Context, message kafka.Message) (*models.ProcessingResult, error)
+}
+
+// MessageReader reads a dead letter topic. *kafka.Reader satisfies it.
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// MessageWriter publishes parked messages. *kafka.Writer satisfies it.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
@@ -0,0 +1,218 @@
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loyalty/stream/internal/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// Topic suffixes. Events that failed on {topic} wait on {topic}.dlq, and the
+// ones that cannot be recovered are parked on {topic}.dlq.parked.
+const (
+	Suffix       = ".dlq"
+	ParkedSuffix = ".parked"
+)
+
+// Headers added to a parked message, next to the ones it arrived with
+const (
+	HeaderError    = "dlq-error"
+	HeaderAttempts = "dlq-attempts"
+)
+
+// Outcome is what happened to one dead-lettered message
+type Outcome string
+
+const (
+	OutcomeReprocessed Outcome = "reprocessed"
+	OutcomeParked      Outcome = "parked"
+)
+
+// Config holds the retry behaviour of Reprocessor
+type Config struct {
+	// MaxAttempts is how many times an event that fails is processed before
+	// it is parked
+	MaxAttempts int
+	// Backoff is the wait before the second attempt, doubling after each
+	Backoff time.Duration
+	// IdleTimeout is how long Drain waits for a message before it decides
+	// the topic is empty
+	IdleTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+		IdleTimeout: 10 * time.Second,
+	}
+}
+
+// Stats counts the outcomes of a Drain
+type Stats struct {
+	Reprocessed int
+	Parked      int
+}
+
+// Reprocessor sends dead-lettered events back through the event processor
+// once the cause of their failure has been fixed. Processed events continue
+// as normal, publishing their results if the processor does, and events that
+// still fail are parked for a person to look at.
+type Reprocessor struct {
+	handler     EventHandler
+	writer      MessageWriter
+	parkedTopic string
+	config      Config
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+// NewReprocessor reprocesses messages from dlqTopic, which must end in .dlq,
+// parking failures on dlqTopic.parked through writer
+func NewReprocessor(handler EventHandler, writer MessageWriter, dlqTopic string, config Config) (*Reprocessor, error) {
+	if !strings.HasSuffix(dlqTopic, Suffix) || dlqTopic == Suffix {
+		return nil, fmt.Errorf("dead letter topic %q must be named {topic}%s", dlqTopic, Suffix)
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+
+	return &Reprocessor{
+		handler:     handler,
+		writer:      writer,
+		parkedTopic: dlqTopic + ParkedSuffix,
+		config:      config,
+		sleep:       sleepContext,
+	}, nil
+}
+
+// Drain reprocesses messages until none arrive for IdleTimeout, committing
+// each one once it has been reprocessed or parked. A message interrupted by
+// ctx ending is left uncommitted so the next run picks it up.
+func (r *Reprocessor) Drain(ctx context.Context, reader MessageReader) (Stats, error) {
+	var stats Stats
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, r.config.IdleTimeout)
+		message, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return stats, nil
+			}
+			return stats, fmt.Errorf("failed to fetch dead letter: %w", err)
+		}
+
+		outcome, err := r.Reprocess(ctx, message)
+		if err != nil {
+			return stats, err
+		}
+		switch outcome {
+		case OutcomeReprocessed:
+			stats.Reprocessed++
+		case OutcomeParked:
+			stats.Parked++
+		}
+
+		if err := reader.CommitMessages(ctx, message); err != nil {
+			return stats, fmt.Errorf("failed to commit dead letter: %w", err)
+		}
+	}
+}
+
+// Reprocess handles one dead-lettered message. Events that no longer decode
+// are parked straight away, since retrying cannot fix them. Other events are
+// retried up to MaxAttempts and parked if they keep failing. An error is
+// returned only when the message could not be settled either way.
+func (r *Reprocessor) Reprocess(ctx context.Context, message kafka.Message) (Outcome, error) {
+	if reason := checkEvent(message.Value); reason != "" {
+		return r.park(ctx, message, reason, 0)
+	}
+
+	var reason string
+	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			if err := r.sleep(ctx, r.config.Backoff<<(attempt-2)); err != nil {
+				return "", err
+			}
+		}
+
+		result, err := r.handler.ProcessEvent(ctx, message)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return "", err
+		}
+		if err != nil {
+			// The processor only errors on events it cannot decode
+			return r.park(ctx, message, err.Error(), attempt)
+		}
+		if result != nil && result.Success {
+			log.Printf("Reprocessed dead letter %s on attempt %d", result.EventID, attempt)
+			return OutcomeReprocessed, nil
+		}
+
+		reason = "processing failed"
+		if result != nil && result.Error != "" {
+			reason = result.Error
+		}
+		log.Printf("Dead letter attempt %d/%d failed: %s", attempt, r.config.MaxAttempts, reason)
+	}
+
+	return r.park(ctx, message, reason, r.config.MaxAttempts)
+}
+
+func (r *Reprocessor) park(ctx context.Context, message kafka.Message, reason string, attempts int) (Outcome, error) {
+	headers := append([]kafka.Header{}, message.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderError, Value: []byte(reason)},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+	)
+
+	parked := kafka.Message{
+		Topic:   r.parkedTopic,
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	}
+	if err := r.writer.WriteMessages(ctx, parked); err != nil {
+		return "", fmt.Errorf("failed to park dead letter: %w", err)
+	}
+
+	log.Printf("Parked dead letter on %s: %s", r.parkedTopic, reason)
+	return OutcomeParked, nil
+}
+
+// checkEvent returns why value is not an event the processor can handle, or
+// an empty string if it is
+func checkEvent(value []byte) string {
+	var event models.BaseEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return fmt.Sprintf("failed to unmarshal event: %v", err)
+	}
+	if event.EventID == "" {
+		return "event has no event_id"
+	}
+
+	switch event.EventType {
+	case models.EventTypePOSTransaction, models.EventTypeLoyaltyAction:
+		return ""
+	default:
+		return fmt.Sprintf("unknown event type: %s", event.EventType)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,264 @@
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/loyalty/stream/internal/models"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockEventHandler is a mock implementation of the event processor
+type MockEventHandler struct {
+	mock.Mock
+}
+
+func (m *MockEventHandler) ProcessEvent(ctx context.Context, message kafka.Message) (*models.ProcessingResult, error) {
+	args := m.Called(message)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ProcessingResult), args.Error(1)
+}
+
+// MockMessageWriter is a mock implementation of the parked topic writer
+type MockMessageWriter struct {
+	mock.Mock
+}
+
+func (m *MockMessageWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	args := m.Called(msgs)
+	return args.Error(0)
+}
+
+// fakeReader serves messages in order, then blocks until the fetch context
+// ends, as a drained topic does
+type fakeReader struct {
+	messages  []kafka.Message
+	committed []kafka.Message
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.messages) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	message := r.messages[0]
+	r.messages = r.messages[1:]
+	return message, nil
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+// Test setup helper
+func setupTestReprocessor(t *testing.T) (*Reprocessor, *MockEventHandler, *MockMessageWriter) {
+	handler := &MockEventHandler{}
+	writer := &MockMessageWriter{}
+
+	config := Config{MaxAttempts: 3, Backoff: time.Second, IdleTimeout: 20 * time.Millisecond}
+	reprocessor, err := NewReprocessor(handler, writer, "test_org.pos.transaction.dlq", config)
+	require.NoError(t, err)
+	reprocessor.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	return reprocessor, handler, writer
+}
+
+func deadLetter(eventID string) kafka.Message {
+	event := models.BaseEvent{
+		EventID:    eventID,
+		EventType:  models.EventTypePOSTransaction,
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Payload:    map[string]interface{}{"transaction_id": "txn_" + eventID, "amount": 25.0},
+	}
+	value, _ := json.Marshal(event)
+	return kafka.Message{
+		Topic:   "test_org.pos.transaction.dlq",
+		Key:     []byte("test_customer"),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "source", Value: []byte("pos")}},
+	}
+}
+
+func header(message kafka.Message, key string) string {
+	for _, h := range message.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Test NewReprocessor
+func TestNewReprocessor_RequiresDLQTopic(t *testing.T) {
+	for _, topic := range []string{"test_org.pos.transaction", ".dlq", ""} {
+		_, err := NewReprocessor(&MockEventHandler{}, &MockMessageWriter{}, topic, DefaultConfig())
+		assert.Error(t, err, topic)
+	}
+}
+
+// Test Reprocess
+func TestReprocess_FixedEventSucceeds(t *testing.T) {
+	reprocessor, handler, writer := setupTestReprocessor(t)
+	message := deadLetter("evt_fixed")
+
+	// Setup expectations
+	handler.On("ProcessEvent", message).Return(&models.ProcessingResult{EventID: "evt_fixed", Success: true, PointsEarned: 25}, nil).Once()
+
+	outcome, err := reprocessor.Reprocess(context.Background(), message)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeReprocessed, outcome)
+	handler.AssertExpectations(t)
+	writer.AssertNotCalled(t, "WriteMessages", mock.Anything)
+}
+
+func TestReprocess_RetriesTransientFailure(t *testing.T) {
+	reprocessor, handler, writer := setupTestReprocessor(t)
+	message := deadLetter("evt_flaky")
+
+	var waits []time.Duration
+	reprocessor.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	// Setup expectations
+	handler.On("ProcessEvent", message).Return(&models.ProcessingResult{EventID: "evt_flaky", Error: "failed to get customer: timeout"}, nil).Twice()
+	handler.On("ProcessEvent", message).Return(&models.ProcessingResult{EventID: "evt_flaky", Success: true}, nil).Once()
+
+	outcome, err := reprocessor.Reprocess(context.Background(), message)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeReprocessed, outcome)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+	handler.AssertExpectations(t)
+	writer.AssertNotCalled(t, "WriteMessages", mock.Anything)
+}
+
+func TestReprocess_BrokenEventIsParked(t *testing.T) {
+	reprocessor, handler, writer := setupTestReprocessor(t)
+
+	unknownType := deadLetter("evt_unknown")
+	unknownType.Value = []byte(`{"event_id":"evt_unknown","event_type":"pos.refund","org_id":"test_org"}`)
+
+	tests := []struct {
+		name    string
+		message kafka.Message
+		reason  string
+	}{
+		{"invalid JSON", kafka.Message{Key: []byte("test_customer"), Value: []byte(`{"event_id": "evt_1", "payload": {`)}, "failed to unmarshal event"},
+		{"missing event ID", kafka.Message{Value: []byte(`{"event_type":"pos.transaction"}`)}, "event has no event_id"},
+		{"unknown event type", unknownType, "unknown event type: pos.refund"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer.Calls = nil
+			writer.ExpectedCalls = nil
+			writer.On("WriteMessages", mock.Anything).Return(nil).Once()
+
+			outcome, err := reprocessor.Reprocess(context.Background(), tt.message)
+
+			// Assertions
+			assert.NoError(t, err)
+			assert.Equal(t, OutcomeParked, outcome)
+			writer.AssertExpectations(t)
+
+			parked := writer.Calls[0].Arguments.Get(0).([]kafka.Message)
+			require.Len(t, parked, 1)
+			assert.Equal(t, "test_org.pos.transaction.dlq.parked", parked[0].Topic)
+			assert.Equal(t, tt.message.Value, parked[0].Value)
+			assert.Equal(t, tt.message.Key, parked[0].Key)
+			assert.Contains(t, header(parked[0], HeaderError), tt.reason)
+			assert.Equal(t, "0", header(parked[0], HeaderAttempts))
+		})
+	}
+
+	// A structurally broken event never reaches the processor
+	handler.AssertNotCalled(t, "ProcessEvent", mock.Anything)
+}
+
+func TestReprocess_ParksAfterMaxAttempts(t *testing.T) {
+	reprocessor, handler, writer := setupTestReprocessor(t)
+	message := deadLetter("evt_still_failing")
+
+	// Setup expectations
+	handler.On("ProcessEvent", message).Return(&models.ProcessingResult{EventID: "evt_still_failing", Error: "customer not found"}, nil).Times(3)
+	writer.On("WriteMessages", mock.Anything).Return(nil).Once()
+
+	outcome, err := reprocessor.Reprocess(context.Background(), message)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+	handler.AssertExpectations(t)
+
+	parked := writer.Calls[0].Arguments.Get(0).([]kafka.Message)
+	require.Len(t, parked, 1)
+	assert.Equal(t, "customer not found", header(parked[0], HeaderError))
+	assert.Equal(t, "3", header(parked[0], HeaderAttempts))
+	assert.Equal(t, "pos", header(parked[0], "source"), "original headers are kept")
+}
+
+func TestReprocess_CancelledIsNotParked(t *testing.T) {
+	reprocessor, handler, writer := setupTestReprocessor(t)
+	message := deadLetter("evt_cancelled")
+
+	// Setup expectations
+	handler.On("ProcessEvent", message).Return(nil, fmt.Errorf("processing of event evt_cancelled was cancelled: %w", context.Canceled))
+
+	_, err := reprocessor.Reprocess(context.Background(), message)
+
+	// Assertions
+	assert.ErrorIs(t, err, context.Canceled)
+	writer.AssertNotCalled(t, "WriteMessages", mock.Anything)
+}
+
+// Test Drain
+func TestDrain_SettlesEveryMessage(t *testing.T) {
+	reprocessor, handler, writer := setupTestReprocessor(t)
+
+	fixed := deadLetter("evt_fixed")
+	broken := kafka.Message{Value: []byte(`not json`)}
+	reader := &fakeReader{messages: []kafka.Message{fixed, broken}}
+
+	// Setup expectations
+	handler.On("ProcessEvent", fixed).Return(&models.ProcessingResult{EventID: "evt_fixed", Success: true}, nil).Once()
+	writer.On("WriteMessages", mock.Anything).Return(nil).Once()
+
+	stats, err := reprocessor.Drain(context.Background(), reader)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Reprocessed: 1, Parked: 1}, stats)
+	assert.Len(t, reader.committed, 2)
+	handler.AssertExpectations(t)
+	writer.AssertExpectations(t)
+}
+
+func TestDrain_ParkFailureLeavesMessageUncommitted(t *testing.T) {
+	reprocessor, _, writer := setupTestReprocessor(t)
+	reader := &fakeReader{messages: []kafka.Message{{Value: []byte(`not json`)}}}
+
+	// Setup expectations
+	writer.On("WriteMessages", mock.Anything).Return(errors.New("broker unavailable"))
+
+	_, err := reprocessor.Drain(context.Background(), reader)
+
+	// Assertions
+	assert.ErrorContains(t, err, "broker unavailable")
+	assert.Empty(t, reader.committed)
+}
@@ -0,0 +1,20 @@
+package idempotency
+
+import "context"
+
+// Store claims events before they are processed, so each is processed once
+// even when it is delivered twice at the same time. Events are keyed on org
+// and event ID, so orgs that reuse an event ID do not collide.
+type Store interface {
+	// Claim marks the org's event as being processed. It reports false when
+	// the event is already claimed, because it was processed or another
+	// delivery is processing it. A claim neither completed nor released
+	// within the store's claim timeout can be claimed again, so an instance
+	// that dies mid-event does not block it for good.
+	Claim(ctx context.Context, orgID, eventID string) (bool, error)
+	// Complete marks a claimed event as done, so it is never processed again
+	Complete(ctx context.Context, orgID, eventID string) error
+	// Release drops a claim on an event that failed before changing anything,
+	// so a redelivery can process it
+	Release(ctx context.Context, orgID, eventID string) error
+}
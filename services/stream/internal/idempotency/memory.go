@@ -0,0 +1,144 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStoreConfig bounds how much a MemoryStore remembers
+type MemoryStoreConfig struct {
+	// TTL is how long a claimed event is remembered
+	TTL time.Duration
+	// MaxEvents caps the events remembered; the oldest are forgotten first.
+	// Zero means no cap.
+	MaxEvents int
+	// ClaimTimeout is how long a claim may go uncompleted before the event
+	// can be claimed again
+	ClaimTimeout time.Duration
+}
+
+func DefaultMemoryStoreConfig() MemoryStoreConfig {
+	return MemoryStoreConfig{
+		TTL:          24 * time.Hour,
+		MaxEvents:    100000,
+		ClaimTimeout: 5 * time.Minute,
+	}
+}
+
+type eventKey struct {
+	orgID   string
+	eventID string
+}
+
+type claim struct {
+	at   time.Time
+	done bool
+}
+
+// claimed is an entry of the claim order, which is stale once the event is
+// released or claimed again
+type claimed struct {
+	key eventKey
+	at  time.Time
+}
+
+// MemoryStore remembers claimed events in this process only, which catches
+// redeliveries to the same consumer but not across instances
+type MemoryStore struct {
+	mu     sync.Mutex
+	config MemoryStoreConfig
+	claims map[eventKey]claim
+	order  []claimed
+	now    func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithConfig(DefaultMemoryStoreConfig())
+}
+
+func NewMemoryStoreWithConfig(config MemoryStoreConfig) *MemoryStore {
+	return &MemoryStore{
+		config: config,
+		claims: make(map[eventKey]claim),
+		now:    time.Now,
+	}
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, orgID, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire()
+	now := s.now()
+	key := eventKey{orgID: orgID, eventID: eventID}
+	if existing, ok := s.claims[key]; ok {
+		if existing.done || s.config.ClaimTimeout <= 0 || now.Sub(existing.at) < s.config.ClaimTimeout {
+			return false, nil
+		}
+	}
+
+	s.claims[key] = claim{at: now}
+	s.order = append(s.order, claimed{key: key, at: now})
+	if s.config.MaxEvents > 0 && len(s.claims) > s.config.MaxEvents {
+		s.evictOldest()
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, orgID, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := eventKey{orgID: orgID, eventID: eventID}
+	if existing, ok := s.claims[key]; ok {
+		existing.done = true
+		s.claims[key] = existing
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, orgID, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := eventKey{orgID: orgID, eventID: eventID}
+	if existing, ok := s.claims[key]; ok && !existing.done {
+		delete(s.claims, key)
+	}
+	return nil
+}
+
+// current reports whether entry is still the event's latest claim
+func (s *MemoryStore) current(entry claimed) bool {
+	existing, ok := s.claims[entry.key]
+	return ok && existing.at.Equal(entry.at)
+}
+
+// evictOldest forgets the oldest claimed event to make room
+func (s *MemoryStore) evictOldest() {
+	for len(s.order) > 0 {
+		entry := s.order[0]
+		s.order = s.order[1:]
+		if s.current(entry) {
+			delete(s.claims, entry.key)
+			return
+		}
+	}
+}
+
+// expire forgets events claimed longer ago than the TTL. Events are claimed
+// in time order, so expired ones are all at the front.
+func (s *MemoryStore) expire() {
+	if s.config.TTL <= 0 {
+		return
+	}
+
+	now := s.now()
+	for len(s.order) > 0 && now.Sub(s.order[0].at) >= s.config.TTL {
+		if s.current(s.order[0]) {
+			delete(s.claims, s.order[0].key)
+		}
+		s.order = s.order[1:]
+	}
+}
@@ -0,0 +1,120 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test MemoryStore
+func TestMemoryStore_ClaimOnce(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	claimed, err := store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Assertions - a second delivery cannot claim it while it is in progress
+	// or once it is done
+	claimed, err = store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	require.NoError(t, store.Complete(ctx, "org_a", "evt_1"))
+	claimed, err = store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+}
+
+func TestMemoryStore_KeyedOnOrg(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	_, err := store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+
+	// Assertions
+	claimed, err := store.Claim(ctx, "org_b", "evt_1")
+	require.NoError(t, err)
+	assert.True(t, claimed, "another org's event with the same ID is not a duplicate")
+}
+
+func TestMemoryStore_ReleaseAllowsRetry(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	_, err := store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+	require.NoError(t, store.Release(ctx, "org_a", "evt_1"))
+
+	// Assertions
+	claimed, err := store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// A completed event is not released
+	require.NoError(t, store.Complete(ctx, "org_a", "evt_1"))
+	require.NoError(t, store.Release(ctx, "org_a", "evt_1"))
+	claimed, _ = store.Claim(ctx, "org_a", "evt_1")
+	assert.False(t, claimed)
+}
+
+func TestMemoryStore_StaleClaimTakenOver(t *testing.T) {
+	store := NewMemoryStoreWithConfig(MemoryStoreConfig{TTL: time.Hour, ClaimTimeout: time.Minute})
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+
+	now = now.Add(59 * time.Second)
+	claimed, _ := store.Claim(ctx, "org_a", "evt_1")
+	assert.False(t, claimed)
+
+	// Assertions - the first claimant never finished
+	now = now.Add(time.Second)
+	claimed, _ = store.Claim(ctx, "org_a", "evt_1")
+	assert.True(t, claimed)
+}
+
+func TestMemoryStore_ForgetsAfterTTL(t *testing.T) {
+	store := NewMemoryStoreWithConfig(MemoryStoreConfig{TTL: time.Hour})
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := store.Claim(ctx, "org_a", "evt_1")
+	require.NoError(t, err)
+	require.NoError(t, store.Complete(ctx, "org_a", "evt_1"))
+
+	now = now.Add(59 * time.Minute)
+	claimed, _ := store.Claim(ctx, "org_a", "evt_1")
+	assert.False(t, claimed)
+
+	now = now.Add(time.Minute)
+	claimed, _ = store.Claim(ctx, "org_a", "evt_1")
+	assert.True(t, claimed, "the TTL should have expired")
+}
+
+func TestMemoryStore_BoundedSize(t *testing.T) {
+	store := NewMemoryStoreWithConfig(MemoryStoreConfig{TTL: time.Hour, MaxEvents: 2})
+	ctx := context.Background()
+
+	for _, eventID := range []string{"evt_1", "evt_2", "evt_3"} {
+		_, err := store.Claim(ctx, "org_a", eventID)
+		require.NoError(t, err)
+		require.NoError(t, store.Complete(ctx, "org_a", eventID))
+	}
+
+	// Assertions - the oldest event was evicted to make room
+	assert.Len(t, store.claims, 2)
+	claimed, _ := store.Claim(ctx, "org_a", "evt_3")
+	assert.False(t, claimed)
+	claimed, _ = store.Claim(ctx, "org_a", "evt_1")
+	assert.True(t, claimed)
+}
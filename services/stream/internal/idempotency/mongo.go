@@ -0,0 +1,177 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// processedEventsCollection holds one document per claimed event
+const processedEventsCollection = "processed_events"
+
+// Claim statuses of a processed event document
+const (
+	statusProcessing = "processing"
+	statusProcessed  = "processed"
+)
+
+// MongoStoreConfig controls how long a MongoStore remembers events
+type MongoStoreConfig struct {
+	// TTL is how long a claimed event is remembered before MongoDB's TTL
+	// monitor removes it
+	TTL time.Duration
+	// ClaimTimeout is how long a claim may go uncompleted before the event
+	// can be claimed again
+	ClaimTimeout time.Duration
+}
+
+func DefaultMongoStoreConfig() MongoStoreConfig {
+	return MongoStoreConfig{
+		TTL:          7 * 24 * time.Hour,
+		ClaimTimeout: 5 * time.Minute,
+	}
+}
+
+type processedEvent struct {
+	OrgID     string    `bson:"org_id"`
+	EventID   string    `bson:"event_id"`
+	Status    string    `bson:"status"`
+	ClaimedAt time.Time `bson:"claimed_at"`
+}
+
+// MongoStore records processed events in MongoDB, so every processor
+// instance sharing the database sees them
+type MongoStore struct {
+	client       *mongo.Client
+	collection   *mongo.Collection
+	claimTimeout time.Duration
+	now          func() time.Time
+}
+
+func NewMongoStore(uri, dbName string) (*MongoStore, error) {
+	return NewMongoStoreWithConfig(uri, dbName, DefaultMongoStoreConfig())
+}
+
+func NewMongoStoreWithConfig(uri, dbName string, config MongoStoreConfig) (*MongoStore, error) {
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	if err := client.Ping(context.TODO(), nil); err != nil {
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	store := newMongoStore(client.Database(dbName).Collection(processedEventsCollection))
+	store.client = client
+	store.claimTimeout = config.ClaimTimeout
+
+	if err := store.createIndexes(context.TODO(), config); err != nil {
+		return nil, fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	return store, nil
+}
+
+func newMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{
+		collection:   collection,
+		claimTimeout: DefaultMongoStoreConfig().ClaimTimeout,
+		now:          time.Now,
+	}
+}
+
+// createIndexes makes org and event ID unique and expires events after the
+// configured TTL
+func (s *MongoStore) createIndexes(ctx context.Context, config MongoStoreConfig) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	if config.TTL > 0 {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys:    bson.D{{Key: "claimed_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(config.TTL / time.Second)),
+		})
+	}
+
+	_, err := s.collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Claim inserts the event as processing. The unique index on org and event
+// ID rejects a second insert, so of two deliveries racing on the same event
+// only one claims it. A claim still processing after the claim timeout is
+// taken over.
+func (s *MongoStore) Claim(ctx context.Context, orgID, eventID string) (bool, error) {
+	now := s.now()
+	_, err := s.collection.InsertOne(ctx, processedEvent{
+		OrgID:     orgID,
+		EventID:   eventID,
+		Status:    statusProcessing,
+		ClaimedAt: now,
+	})
+	if err == nil {
+		return true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, fmt.Errorf("failed to claim event: %w", err)
+	}
+	if s.claimTimeout <= 0 {
+		return false, nil
+	}
+
+	filter := bson.M{
+		"org_id":     orgID,
+		"event_id":   eventID,
+		"status":     statusProcessing,
+		"claimed_at": bson.M{"$lt": now.Add(-s.claimTimeout)},
+	}
+	result, err := s.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"claimed_at": now}})
+	if err != nil {
+		return false, fmt.Errorf("failed to take over stale claim: %w", err)
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+func (s *MongoStore) Complete(ctx context.Context, orgID, eventID string) error {
+	filter := bson.M{
+		"org_id":   orgID,
+		"event_id": eventID,
+	}
+
+	_, err := s.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": statusProcessed}})
+	if err != nil {
+		return fmt.Errorf("failed to complete claim: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MongoStore) Release(ctx context.Context, orgID, eventID string) error {
+	filter := bson.M{
+		"org_id":   orgID,
+		"event_id": eventID,
+		"status":   statusProcessing,
+	}
+
+	if _, err := s.collection.DeleteOne(ctx, filter); err != nil {
+		return fmt.Errorf("failed to release claim: %w", err)
+	}
+
+	return nil
+}
+
+func (s *MongoStore) Close(ctx context.Context) error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Disconnect(ctx)
+}
@@ -0,0 +1,109 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// Test MongoStore
+func TestMongoStore_Claim(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("inserts the event as processing", func(mt *mtest.T) {
+		store := newMongoStore(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		claimed, err := store.Claim(context.Background(), "org_a", "evt_1")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		assert.Equal(t, "org_a", doc.Lookup("org_id").StringValue())
+		assert.Equal(t, "evt_1", doc.Lookup("event_id").StringValue())
+		assert.Equal(t, statusProcessing, doc.Lookup("status").StringValue())
+	})
+
+	mt.Run("already claimed", func(mt *mtest.T) {
+		store := newMongoStore(mt.Coll)
+		now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+		store.now = func() time.Time { return now }
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+		)
+
+		claimed, err := store.Claim(context.Background(), "org_a", "evt_1")
+
+		// Assertions - only a stale processing claim could be taken over
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		filter := update.Lookup("q").Document()
+		assert.Equal(t, statusProcessing, filter.Lookup("status").StringValue())
+		cutoff := filter.Lookup("claimed_at").Document().Lookup("$lt").Time()
+		assert.True(t, cutoff.Equal(now.Add(-5*time.Minute)))
+	})
+
+	mt.Run("takes over a stale claim", func(mt *mtest.T) {
+		store := newMongoStore(mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		claimed, err := store.Claim(context.Background(), "org_a", "evt_1")
+
+		// Assertions
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+	})
+
+	mt.Run("write failure", func(mt *mtest.T) {
+		store := newMongoStore(mt.Coll)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 2, Message: "bad value"}))
+
+		_, err := store.Claim(context.Background(), "org_a", "evt_1")
+
+		// Assertions
+		assert.Error(t, err)
+	})
+}
+
+func TestMongoStore_CompleteAndRelease(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("complete marks the event processed", func(mt *mtest.T) {
+		store := newMongoStore(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		err := store.Complete(context.Background(), "org_a", "evt_1")
+
+		// Assertions
+		assert.NoError(t, err)
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		assert.Equal(t, "evt_1", update.Lookup("q", "event_id").StringValue())
+		assert.Equal(t, statusProcessed, update.Lookup("u", "$set", "status").StringValue())
+	})
+
+	mt.Run("release deletes only a processing claim", func(mt *mtest.T) {
+		store := newMongoStore(mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		err := store.Release(context.Background(), "org_a", "evt_1")
+
+		// Assertions
+		assert.NoError(t, err)
+		deletion := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document()
+		assert.Equal(t, "org_a", deletion.Lookup("q", "org_id").StringValue())
+		assert.Equal(t, statusProcessing, deletion.Lookup("q", "status").StringValue())
+	})
+}
@@ -6,9 +6,19 @@ type EventType string
 
 const (
 	EventTypePOSTransaction   EventType = "pos.transaction"
+	EventTypePOSSettlement    EventType = "pos.settlement"
 	EventTypeLoyaltyAction    EventType = "loyalty.action"
 	EventTypeCustomerUpdated  EventType = "customer.updated"
 	EventTypeRewardTriggered  EventType = "reward.triggered"
+	EventTypeAccrualCeilingExceeded EventType = "alert.accrual_ceiling_exceeded"
+	EventTypeCustomerMetricsUpdated EventType = "customer.metrics.updated"
+)
+
+// AccrualApplied and AccrualFailed are the accrual_status of a
+// customer.metrics.updated event: whether the ledger applied the transaction
+const (
+	AccrualApplied = "applied"
+	AccrualFailed  = "failed"
 )
 
 type BaseEvent struct {
@@ -29,6 +39,9 @@ type POSTransaction struct {
 	PaymentMethod string    `json:"payment_method"`
 	ReceiptNumber string    `json:"receipt_number"`
 	Cashier       string    `json:"cashier"`
+	// Refund marks a return or void of TransactionID. Amount and Items are
+	// what is being refunded, and the points they earn are deducted.
+	Refund        bool      `json:"refund"`
 }
 
 type LineItem struct {
@@ -41,21 +54,40 @@ type LineItem struct {
 	Discounted  bool    `json:"discounted"`
 }
 
+// POSSettlement reports that a card transaction settled, or was voided
+// before it did, so points held pending its settlement are posted or voided
+type POSSettlement struct {
+	TransactionID string `json:"transaction_id"`
+	Voided        bool   `json:"voided"`
+}
+
 type LoyaltyAction struct {
 	ActionType    string                 `json:"action_type"`
 	Points        int                    `json:"points"`
 	Stamps        int                    `json:"stamps"`
 	RewardID      string                 `json:"reward_id"`
+	Benefit       string                 `json:"benefit"`
 	Reference     string                 `json:"reference"`
 	ExtraData     map[string]interface{} `json:"extra_data"`
 }
 
 type ProcessingResult struct {
 	EventID        string                 `json:"event_id"`
+	OrgID          string                 `json:"org_id"`
+	CustomerID     string                 `json:"customer_id"`
 	ProcessedAt    time.Time              `json:"processed_at"`
 	Success        bool                   `json:"success"`
+	// Duplicate is set when the event had already been processed and was
+	// skipped
+	Duplicate      bool                   `json:"duplicate,omitempty"`
+	// Deferred is set when the event's accrual is paused, so it goes to its
+	// dead letter topic to be reprocessed later
+	Deferred       bool                   `json:"deferred,omitempty"`
 	Error          string                 `json:"error,omitempty"`
 	PointsEarned   int                    `json:"points_earned"`
+	// PointsPending marks PointsEarned as held in the ledger until the
+	// transaction settles
+	PointsPending  bool                   `json:"points_pending,omitempty"`
 	StampsEarned   int                    `json:"stamps_earned"`
 	RewardsTriggered []RewardTriggered    `json:"rewards_triggered"`
 	Actions        []string               `json:"actions"`
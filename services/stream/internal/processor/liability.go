@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/loyalty/stream/internal/ceiling"
+	"github.com/loyalty/stream/internal/clients"
+	"github.com/loyalty/stream/internal/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// accrualDay is the UTC day POS accruals are counted against
+const accrualDay = "2006-01-02"
+
+// accrualCheck is the outcome of reserving an accrual against an org's
+// daily ceiling on day
+type accrualCheck struct {
+	ceiling.Check
+	day string
+}
+
+// releaseVoided takes points voided from a hold back from the ceiling of the
+// day they were held, since they will never be credited
+func (p *EventProcessor) releaseVoided(orgID string, voided *clients.TransferResponse) {
+	if voided == nil || voided.Amount == 0 || voided.HeldAt == 0 {
+		return
+	}
+	day := time.Unix(voided.HeldAt, 0).UTC().Format(accrualDay)
+	p.releaseAccrual(orgID, day, int(voided.Amount))
+}
+
+// releaseAccrual takes points that were not credited back from the org's
+// ceiling for day. It runs even if ctx has ended, since the points have
+// already been counted.
+func (p *EventProcessor) releaseAccrual(orgID, day string, points int) {
+	if day == "" {
+		return
+	}
+	if err := p.accrual.Release(context.Background(), orgID, day, points); err != nil {
+		log.Printf("Failed to release %d points from org %s's ceiling for %s: %v", points, orgID, day, err)
+	}
+}
+
+// reserveAccrual checks pointsEarned against the org's daily ceiling and
+// alerts when it is first exceeded. It reports whether the points may be
+// credited. If the count cannot be reached the points are credited
+// unchecked, since refusing them would defer every accrual of the org.
+func (p *EventProcessor) reserveAccrual(ctx context.Context, event *models.BaseEvent, settings clients.OrgSettings, pointsEarned int) (accrualCheck, bool) {
+	if settings.DailyPointsCeiling <= 0 || pointsEarned <= 0 {
+		return accrualCheck{}, true
+	}
+
+	check := accrualCheck{day: time.Now().UTC().Format(accrualDay)}
+	reserved, err := p.accrual.Reserve(ctx, event.OrgID, check.day, pointsEarned, settings.DailyPointsCeiling, settings.PauseAccrualAtCeiling)
+	if err != nil {
+		log.Printf("Failed to check org %s's daily points ceiling, crediting event %s unchecked: %v", event.OrgID, event.EventID, err)
+		return accrualCheck{}, true
+	}
+	check.Check = reserved
+
+	if check.Crossed {
+		p.publishAccrualAlert(ctx, event, settings, check)
+	}
+	return check, !check.Paused
+}
+
+// publishAccrualAlert logs and publishes an alert that the org has accrued
+// more than its daily ceiling. The event ID is derived from the org and day
+// so each crossing is announced once.
+func (p *EventProcessor) publishAccrualAlert(ctx context.Context, source *models.BaseEvent, settings clients.OrgSettings, check accrualCheck) {
+	log.Printf("Org %s exceeded its daily points ceiling of %d on %s: %d points (paused: %t)",
+		source.OrgID, settings.DailyPointsCeiling, check.day, check.Total, settings.PauseAccrualAtCeiling)
+
+	if p.alertWriter == nil {
+		return
+	}
+
+	now := time.Now()
+	event := models.BaseEvent{
+		EventID:   fmt.Sprintf("%s_accrual_ceiling_%s", source.OrgID, check.day),
+		EventType: models.EventTypeAccrualCeilingExceeded,
+		OrgID:     source.OrgID,
+		Timestamp: now,
+		Payload: map[string]interface{}{
+			"day":             check.day,
+			"ceiling":         settings.DailyPointsCeiling,
+			"points_accrued":  check.Total,
+			"accrual_paused":  settings.PauseAccrualAtCeiling,
+			"source_event_id": source.EventID,
+		},
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal accrual alert %s: %v", event.EventID, err)
+		return
+	}
+
+	message := kafka.Message{
+		Topic: fmt.Sprintf("%s.%s", source.OrgID, models.EventTypeAccrualCeilingExceeded),
+		Key:   []byte(source.OrgID),
+		Value: eventJSON,
+		Time:  now,
+	}
+	if err := p.alertWriter.WriteMessages(ctx, message); err != nil {
+		log.Printf("Failed to publish accrual alert %s: %v", event.EventID, err)
+	}
+}
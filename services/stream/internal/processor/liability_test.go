@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/loyalty/stream/internal/ceiling"
+	"github.com/loyalty/stream/internal/clients"
+	"github.com/loyalty/stream/internal/dlq"
+	"github.com/loyalty/stream/internal/idempotency"
+	"github.com/loyalty/stream/internal/models"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Test setup helper - a processor for an org earning 2 points per dollar with
+// the given daily ceiling
+func setupCeilingProcessor(ceiling int, pause bool) (*EventProcessor, *MockLedgerClient, *MockMessageWriter) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	mockWriter := &MockMessageWriter{}
+	processor.alertWriter = mockWriter
+
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:       2.0,
+			DailyPointsCeiling:    ceiling,
+			PauseAccrualAtCeiling: pause,
+		},
+	}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer", OrgID: "test_org"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", mock.Anything, mock.Anything).
+		Return(&clients.TransferResponse{TransferID: "transfer_123", Status: "success"}, nil)
+
+	return processor, mockLedgerClient, mockWriter
+}
+
+func ceilingTransaction(transactionID string, amount float64) kafka.Message {
+	event := models.BaseEvent{
+		EventID:    "evt_" + transactionID,
+		EventType:  models.EventTypePOSTransaction,
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now(),
+		Payload: map[string]interface{}{
+			"transaction_id": transactionID,
+			"amount":         amount,
+		},
+	}
+	eventData, _ := json.Marshal(event)
+	return kafka.Message{Value: eventData}
+}
+
+// Test daily points ceiling
+func TestProcessEvent_CeilingCrossedAlertsOnce(t *testing.T) {
+	processor, mockLedgerClient, mockWriter := setupCeilingProcessor(250, false)
+
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process events - 100 + 100 + 100 points crosses the ceiling on the third
+	for i := 1; i <= 4; i++ {
+		result, err := processor.ProcessEvent(context.Background(), ceilingTransaction(fmt.Sprintf("txn_%d", i), 50.0))
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, 100, result.PointsEarned)
+	}
+
+	// Assertions
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 4)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+
+	msgs := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "test_org.alert.accrual_ceiling_exceeded", msgs[0].Topic)
+
+	var alert models.BaseEvent
+	require.NoError(t, json.Unmarshal(msgs[0].Value, &alert))
+	assert.Equal(t, models.EventTypeAccrualCeilingExceeded, alert.EventType)
+	assert.Equal(t, float64(250), alert.Payload["ceiling"])
+	assert.Equal(t, float64(300), alert.Payload["points_accrued"])
+	assert.Equal(t, "evt_txn_3", alert.Payload["source_event_id"])
+}
+
+func TestProcessEvent_CeilingPausesAccrual(t *testing.T) {
+	processor, mockLedgerClient, mockWriter := setupCeilingProcessor(250, true)
+
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process events
+	for i := 1; i <= 2; i++ {
+		result, err := processor.ProcessEvent(context.Background(), ceilingTransaction(fmt.Sprintf("txn_%d", i), 50.0))
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+	}
+	crossing, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_3", 50.0))
+	require.NoError(t, err)
+	// A small transaction still fits under the ceiling but accrual is paused
+	later, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_4", 1.0))
+	require.NoError(t, err)
+
+	// Assertions
+	for _, result := range []*models.ProcessingResult{crossing, later} {
+		assert.False(t, result.Success)
+		assert.Equal(t, 0, result.PointsEarned)
+		assert.Contains(t, result.Error, "points accrual for org test_org is paused")
+	}
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 2)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+}
+
+func TestProcessEvent_NormalVolumeDoesNotAlert(t *testing.T) {
+	processor, mockLedgerClient, mockWriter := setupCeilingProcessor(1000, true)
+
+	// Process events - 500 points in total
+	for i := 1; i <= 5; i++ {
+		result, err := processor.ProcessEvent(context.Background(), ceilingTransaction(fmt.Sprintf("txn_%d", i), 50.0))
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+	}
+
+	// Assertions
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 5)
+	mockWriter.AssertNotCalled(t, "WriteMessages", mock.Anything, mock.Anything)
+}
+
+func TestProcessEvent_VoidedHoldReleasesCeiling(t *testing.T) {
+	processor, _, mockMembershipClient := setupTestProcessor()
+	ledger := &balanceLedger{}
+	processor.ledgerClient = ledger
+
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:       2.0,
+			PendingAccrual:        true,
+			DailyPointsCeiling:    250,
+			PauseAccrualAtCeiling: true,
+		},
+	}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer", OrgID: "test_org"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+
+	// Process events - 200 points are held, then the payment is voided
+	_, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_1", 100.0))
+	require.NoError(t, err)
+	_, err = processor.ProcessEvent(context.Background(), posSettlementMessage("txn_1", true))
+	require.NoError(t, err)
+
+	result, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_2", 100.0))
+
+	// Assertions - the voided points no longer count towards the ceiling
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 200, ledger.pending["pos_transaction_txn_2"])
+}
+
+func TestProcessEvent_PausedAccrualDeadLettered(t *testing.T) {
+	processor, mockLedgerClient, mockWriter := setupCeilingProcessor(250, true)
+	processor.processed = idempotency.NewMemoryStore()
+	mockDeadLetters := &MockMessageWriter{}
+	processor.deadLetterWriter = mockDeadLetters
+
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	mockDeadLetters.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process event - 300 points takes the org over its ceiling
+	message := ceilingTransaction("txn_1", 150.0)
+	message.Topic = "test_org.pos.transaction"
+	message.Key = []byte("test_customer")
+	result, err := processor.ProcessEvent(context.Background(), message)
+
+	// Assertions
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.True(t, result.Deferred)
+	mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	msgs := mockDeadLetters.Calls[0].Arguments.Get(1).([]kafka.Message)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "test_org.pos.transaction.dlq", msgs[0].Topic)
+	assert.Equal(t, message.Value, msgs[0].Value)
+	assert.Equal(t, dlq.HeaderError, msgs[0].Headers[0].Key)
+	assert.Contains(t, string(msgs[0].Headers[0].Value), "points accrual for org test_org is paused")
+
+	// The claim is released so the dead letter can be reprocessed
+	claimed, err := processor.processed.Claim(context.Background(), "test_org", "evt_txn_1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestProcessEvent_DeadLetterFailureIsReturned(t *testing.T) {
+	processor, _, mockWriter := setupCeilingProcessor(250, true)
+	mockDeadLetters := &MockMessageWriter{}
+	processor.deadLetterWriter = mockDeadLetters
+
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	mockDeadLetters.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("broker unavailable"))
+
+	// Process event
+	message := ceilingTransaction("txn_1", 150.0)
+	message.Topic = "test_org.pos.transaction"
+	result, err := processor.ProcessEvent(context.Background(), message)
+
+	// Assertions - the caller must not commit the message
+	assert.ErrorIs(t, err, ErrDeadLetterFailed)
+	require.NotNil(t, result)
+	assert.True(t, result.Deferred)
+}
+
+// failingCounter is a ceiling count that cannot be reached
+type failingCounter struct{}
+
+func (failingCounter) Reserve(ctx context.Context, orgID, day string, points, limit int, pause bool) (ceiling.Check, error) {
+	return ceiling.Check{}, errors.New("mongo unavailable")
+}
+
+func (failingCounter) Release(ctx context.Context, orgID, day string, points int) error {
+	return errors.New("mongo unavailable")
+}
+
+func TestProcessEvent_CeilingUnavailableCreditsPoints(t *testing.T) {
+	processor, mockLedgerClient, _ := setupCeilingProcessor(250, true)
+	processor.accrual = failingCounter{}
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_1", 150.0))
+
+	// Assertions
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 300, result.PointsEarned)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 1)
+}
@@ -0,0 +1,26 @@
+package processor
+
+import (
+	"strings"
+
+	"github.com/loyalty/stream/internal/clients"
+	"github.com/loyalty/stream/pkg/locale"
+)
+
+// amountPlaceholder is replaced in a reward threshold's description with its
+// amount, formatted in the org's currency and locale, e.g. "{amount} voucher"
+const amountPlaceholder = "{amount}"
+
+// localizeThresholds returns a copy of the org's reward thresholds with the
+// amount placeholder in each description rendered for the org
+func localizeThresholds(settings clients.OrgSettings) []clients.RewardThreshold {
+	localized := make([]clients.RewardThreshold, len(settings.RewardThresholds))
+	for i, threshold := range settings.RewardThresholds {
+		if strings.Contains(threshold.Description, amountPlaceholder) {
+			amount := locale.FormatMoney(threshold.Amount, settings.Currency, settings.Locale)
+			threshold.Description = strings.ReplaceAll(threshold.Description, amountPlaceholder, amount)
+		}
+		localized[i] = threshold
+	}
+	return localized
+}
@@ -0,0 +1,28 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/loyalty/stream/internal/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test localizeThresholds
+func TestLocalizeThresholds_SameThresholdInUSDAndEUR(t *testing.T) {
+	thresholds := []clients.RewardThreshold{
+		{Points: 500, RewardType: "voucher", Amount: 25, Description: "{amount} voucher"},
+		{Points: 100, RewardType: "free_item", Description: "Free coffee"},
+	}
+
+	usd := localizeThresholds(clients.OrgSettings{RewardThresholds: thresholds, Currency: "USD", Locale: "en-US"})
+	eur := localizeThresholds(clients.OrgSettings{RewardThresholds: thresholds, Currency: "EUR", Locale: "de-DE"})
+
+	// Assertions
+	assert.Equal(t, "$25.00 voucher", usd[0].Description)
+	assert.Equal(t, "25,00 € voucher", eur[0].Description)
+	assert.Equal(t, "Free coffee", usd[1].Description)
+	assert.Equal(t, "Free coffee", eur[1].Description)
+
+	// The org's own thresholds keep their template
+	assert.Equal(t, "{amount} voucher", thresholds[0].Description)
+}
@@ -3,29 +3,186 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"time"
 
+	"github.com/loyalty/stream/internal/ceiling"
 	"github.com/loyalty/stream/internal/clients"
+	"github.com/loyalty/stream/internal/dlq"
+	"github.com/loyalty/stream/internal/idempotency"
 	"github.com/loyalty/stream/internal/models"
+	"github.com/loyalty/stream/pkg/points"
 	"github.com/segmentio/kafka-go"
 )
 
+// ErrDeadLetterFailed is returned when an event that had to be deferred could
+// not be written to its dead letter topic. The message must not be committed,
+// or the event would be lost.
+var ErrDeadLetterFailed = errors.New("failed to dead-letter deferred event")
+
+// MessageWriter publishes messages to Kafka. *kafka.Writer satisfies it.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// ProcessorConfig holds the optional behaviour of EventProcessor
+type ProcessorConfig struct {
+	Ledger clients.LedgerClientConfig
+	// LedgerRetry controls retries of ledger calls that fail transiently
+	LedgerRetry RetryConfig
+	Membership  clients.MembershipClientConfig
+	// AnalyticsURL, when set, is where customer tiers are looked up so POS
+	// accruals earn the tier's points multiplier
+	AnalyticsURL string
+	Analytics    clients.AnalyticsClientConfig
+	// ResultWriter, when set, receives every ProcessingResult on the
+	// {org}.processing.result topic
+	ResultWriter MessageWriter
+	// RewardWriter, when set, receives a reward.triggered event on the
+	// {org}.reward.triggered topic for every reward an event fires
+	RewardWriter MessageWriter
+	// AlertWriter, when set, receives an alert on the
+	// {org}.alert.accrual_ceiling_exceeded topic when an org first accrues
+	// more than its daily points ceiling in a day
+	AlertWriter MessageWriter
+	// MetricsWriter, when set, receives a customer.metrics.updated event on
+	// the {org}.customer.metrics.updated topic for every POS transaction,
+	// applied or not, so analytics can consume it instead of raw POS events
+	MetricsWriter MessageWriter
+	// MetricsRetry controls retries of customer.metrics.updated publishes
+	MetricsRetry RetryConfig
+	// Idempotency, when set, claims each event before it is processed so a
+	// redelivered or concurrently delivered event is skipped instead of
+	// earning twice
+	Idempotency idempotency.Store
+	// AccrualCeiling counts the points each org accrues per day against its
+	// daily ceiling. It defaults to a count held by this processor only.
+	AccrualCeiling ceiling.Counter
+	// DeadLetterWriter, when set, receives POS transactions whose accrual is
+	// paused at the org's daily ceiling on {topic}.dlq, so they can be
+	// reprocessed once accrual resumes instead of being dropped
+	DeadLetterWriter MessageWriter
+	// DeadLetterRetry controls retries of dead letter writes
+	DeadLetterRetry RetryConfig
+}
+
+func DefaultProcessorConfig() ProcessorConfig {
+	return ProcessorConfig{
+		Ledger:          clients.DefaultLedgerClientConfig(),
+		LedgerRetry:     DefaultRetryConfig(),
+		MetricsRetry:    DefaultRetryConfig(),
+		DeadLetterRetry: DefaultRetryConfig(),
+		Membership:      clients.DefaultMembershipClientConfig(),
+		Analytics:       clients.DefaultAnalyticsClientConfig(),
+	}
+}
+
 type EventProcessor struct {
 	ledgerClient     clients.LedgerClientInterface
 	membershipClient clients.MembershipClientInterface
+	analyticsClient  clients.AnalyticsClientInterface
+	resultWriter     MessageWriter
+	rewardWriter     MessageWriter
+	alertWriter      MessageWriter
+	metricsWriter    MessageWriter
+	metricsRetry     RetryConfig
+	deadLetterWriter MessageWriter
+	deadLetterRetry  RetryConfig
+	accrual          ceiling.Counter
+	processed        idempotency.Store
 }
 
 func NewEventProcessor(ledgerURL, membershipURL string) *EventProcessor {
-	return &EventProcessor{
-		ledgerClient:     clients.NewLedgerClient(ledgerURL),
-		membershipClient: clients.NewMembershipClient(membershipURL),
+	return NewEventProcessorWithConfig(ledgerURL, membershipURL, DefaultProcessorConfig())
+}
+
+func NewEventProcessorWithConfig(ledgerURL, membershipURL string, config ProcessorConfig) *EventProcessor {
+	processor := &EventProcessor{
+		ledgerClient:     newRetryingLedgerClient(clients.NewLedgerClientWithConfig(ledgerURL, config.Ledger), config.LedgerRetry),
+		membershipClient: clients.NewMembershipClientWithConfig(membershipURL, config.Membership),
+		resultWriter:     config.ResultWriter,
+		rewardWriter:     config.RewardWriter,
+		alertWriter:      config.AlertWriter,
+		metricsWriter:    config.MetricsWriter,
+		metricsRetry:     config.MetricsRetry,
+		deadLetterWriter: config.DeadLetterWriter,
+		deadLetterRetry:  config.DeadLetterRetry,
+		accrual:          config.AccrualCeiling,
+		processed:        config.Idempotency,
+	}
+	if processor.accrual == nil {
+		processor.accrual = ceiling.NewMemoryCounter()
 	}
+	if config.AnalyticsURL != "" {
+		processor.analyticsClient = clients.NewAnalyticsClientWithConfig(config.AnalyticsURL, config.Analytics)
+	}
+	return processor
 }
 
 func (p *EventProcessor) ProcessEvent(ctx context.Context, message kafka.Message) (*models.ProcessingResult, error) {
+	result, err := p.processEvent(ctx, message)
+	if err == nil && result != nil && result.Deferred {
+		err = p.deadLetter(ctx, message, result.Error)
+	}
+	if result != nil && !result.Duplicate {
+		p.publishResult(ctx, result)
+	}
+	return result, err
+}
+
+// deadLetter writes a deferred event's message to {topic}.dlq with the reason
+// in a dlq-error header, retrying failed writes
+func (p *EventProcessor) deadLetter(ctx context.Context, message kafka.Message, reason string) error {
+	headers := append([]kafka.Header{}, message.Headers...)
+	headers = append(headers, kafka.Header{Key: dlq.HeaderError, Value: []byte(reason)})
+
+	deadLetter := kafka.Message{
+		Topic:   message.Topic + dlq.Suffix,
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	}
+
+	_, err := withRetry(ctx, p.deadLetterRetry, "Dead letter write", isWriteRetryable, func() (struct{}, error) {
+		return struct{}{}, p.deadLetterWriter.WriteMessages(ctx, deadLetter)
+	})
+	if err != nil {
+		return fmt.Errorf("%w to %s: %v", ErrDeadLetterFailed, deadLetter.Topic, err)
+	}
+	return nil
+}
+
+// publishResult writes result to its org's processing.result topic. A failed
+// publish is logged rather than failing the event, since the ledger has
+// already been updated.
+func (p *EventProcessor) publishResult(ctx context.Context, result *models.ProcessingResult) {
+	if p.resultWriter == nil {
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Failed to marshal processing result for event %s: %v", result.EventID, err)
+		return
+	}
+
+	message := kafka.Message{
+		Topic: fmt.Sprintf("%s.processing.result", result.OrgID),
+		Key:   []byte(result.CustomerID),
+		Value: resultJSON,
+		Time:  result.ProcessedAt,
+	}
+
+	if err := p.resultWriter.WriteMessages(ctx, message); err != nil {
+		log.Printf("Failed to publish processing result for event %s: %v", result.EventID, err)
+	}
+}
+
+func (p *EventProcessor) processEvent(ctx context.Context, message kafka.Message) (*models.ProcessingResult, error) {
 	var event models.BaseEvent
 	if err := json.Unmarshal(message.Value, &event); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
@@ -33,24 +190,213 @@ func (p *EventProcessor) ProcessEvent(ctx context.Context, message kafka.Message
 
 	result := &models.ProcessingResult{
 		EventID:     event.EventID,
+		OrgID:       event.OrgID,
+		CustomerID:  event.CustomerID,
 		ProcessedAt: time.Now(),
 		Success:     false,
 	}
 
+	claimed, ok := p.claim(ctx, &event)
+	if !ok {
+		result.Success = true
+		result.Duplicate = true
+		result.Actions = append(result.Actions, "skipped duplicate event")
+		log.Printf("Skipping duplicate event %s for org %s", event.EventID, event.OrgID)
+		return result, nil
+	}
+
+	var err error
 	switch event.EventType {
 	case models.EventTypePOSTransaction:
-		return p.processPOSTransaction(ctx, &event)
+		result, err = p.processPOSTransaction(ctx, &event)
 	case models.EventTypeLoyaltyAction:
-		return p.processLoyaltyAction(ctx, &event)
+		result, err = p.processLoyaltyAction(ctx, &event)
+	case models.EventTypePOSSettlement:
+		result, err = p.processPOSSettlement(ctx, &event)
 	default:
 		result.Error = fmt.Sprintf("unknown event type: %s", event.EventType)
-		return result, nil
 	}
+	if claimed {
+		p.settleClaim(ctx, &event, result, err)
+	}
+
+	// A step that failed because ctx ended says nothing about the event, so
+	// report the cancellation instead of a failed result
+	if err == nil && !result.Success && ctx.Err() != nil {
+		return nil, fmt.Errorf("processing of event %s was cancelled: %w", event.EventID, ctx.Err())
+	}
+	if result != nil && result.Success {
+		p.publishRewardEvents(ctx, &event, result.RewardsTriggered)
+	}
+	return result, err
+}
+
+// claim claims the event so no other delivery of it is processed at the same
+// time or afterwards. ok is false for an event already claimed. If the store
+// cannot be reached the event is processed unclaimed, since dropping it would
+// lose its points for certain.
+func (p *EventProcessor) claim(ctx context.Context, event *models.BaseEvent) (claimed, ok bool) {
+	if p.processed == nil || event.EventID == "" {
+		return false, true
+	}
+
+	claimed, err := p.processed.Claim(ctx, event.OrgID, event.EventID)
+	if err != nil {
+		log.Printf("Failed to claim event %s, processing it unclaimed: %v", event.EventID, err)
+		return false, true
+	}
+	return claimed, claimed
+}
+
+// settleClaim completes the event's claim once it is processed. A failed
+// event is released so it can be retried, unless it already changed the
+// ledger: retrying it would apply that part twice, so its claim is completed
+// too and the rest must be reconciled by hand. The claim is settled even if
+// ctx has ended.
+func (p *EventProcessor) settleClaim(ctx context.Context, event *models.BaseEvent, result *models.ProcessingResult, err error) {
+	ctx = context.WithoutCancel(ctx)
+
+	failed := err != nil || result == nil || !result.Success
+	if failed && !changedLedger(result) {
+		if err := p.processed.Release(ctx, event.OrgID, event.EventID); err != nil {
+			log.Printf("Failed to release claim on event %s: %v", event.EventID, err)
+		}
+		return
+	}
+
+	if failed {
+		reason := result.Error
+		if err != nil {
+			reason = err.Error()
+		}
+		log.Printf("Event %s failed after changing the ledger and will not be retried: %s", event.EventID, reason)
+	}
+	if err := p.processed.Complete(ctx, event.OrgID, event.EventID); err != nil {
+		log.Printf("Failed to complete claim on event %s: %v", event.EventID, err)
+	}
+}
+
+// changedLedger reports whether a failed event got as far as moving points
+// or stamps
+func changedLedger(result *models.ProcessingResult) bool {
+	return result != nil && (result.PointsEarned != 0 || result.StampsEarned != 0)
+}
+
+// publishRewardEvents writes a reward.triggered event for each reward to the
+// org's reward.triggered topic. The event ID is derived from the source event
+// so a reprocessed event republishes the same IDs for consumers to dedupe. As
+// with results, a failed publish is logged rather than failing the event.
+func (p *EventProcessor) publishRewardEvents(ctx context.Context, source *models.BaseEvent, rewards []models.RewardTriggered) {
+	if p.rewardWriter == nil || len(rewards) == 0 {
+		return
+	}
+
+	messages := make([]kafka.Message, 0, len(rewards))
+	for _, reward := range rewards {
+		event := models.BaseEvent{
+			EventID:    fmt.Sprintf("%s_reward_%s", source.EventID, reward.RewardID),
+			EventType:  models.EventTypeRewardTriggered,
+			OrgID:      source.OrgID,
+			LocationID: source.LocationID,
+			CustomerID: source.CustomerID,
+			Timestamp:  reward.TriggeredAt,
+			Payload: map[string]interface{}{
+				"reward_id":       reward.RewardID,
+				"reward_type":     reward.RewardType,
+				"reward_value":    reward.RewardValue,
+				"description":     reward.Description,
+				"triggered_at":    reward.TriggeredAt,
+				"source_event_id": source.EventID,
+			},
+		}
+
+		eventJSON, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Failed to marshal reward event %s: %v", event.EventID, err)
+			continue
+		}
+
+		messages = append(messages, kafka.Message{
+			Topic: fmt.Sprintf("%s.%s", source.OrgID, models.EventTypeRewardTriggered),
+			Key:   []byte(source.CustomerID),
+			Value: eventJSON,
+			Time:  reward.TriggeredAt,
+		})
+	}
+
+	if err := p.rewardWriter.WriteMessages(ctx, messages...); err != nil {
+		log.Printf("Failed to publish reward events for event %s: %v", source.EventID, err)
+	}
+}
+
+// publishCustomerMetrics writes a customer.metrics.updated event for a POS
+// transaction or refund to the org's customer.metrics.updated topic, whether
+// or not it earned anything, so analytics sees the same spend it would in raw
+// POS events. accrual_status says whether the ledger applied it. Like reward
+// events its ID is derived from the source event. A failed publish is retried
+// and then returned, since analytics would otherwise miss the spend.
+func (p *EventProcessor) publishCustomerMetrics(ctx context.Context, source *models.BaseEvent, transaction models.POSTransaction, result *models.ProcessingResult) error {
+	if p.metricsWriter == nil {
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"transaction_id":  transaction.TransactionID,
+		"amount":          transaction.Amount,
+		"refund":          transaction.Refund,
+		"timestamp":       source.Timestamp,
+		"points_earned":   result.PointsEarned,
+		"stamps_earned":   result.StampsEarned,
+		"source_event_id": source.EventID,
+		"accrual_status":  models.AccrualApplied,
+	}
+	if !result.Success {
+		payload["accrual_status"] = models.AccrualFailed
+		payload["accrual_error"] = result.Error
+	}
+
+	event := models.BaseEvent{
+		EventID:    fmt.Sprintf("%s_metrics", source.EventID),
+		EventType:  models.EventTypeCustomerMetricsUpdated,
+		OrgID:      source.OrgID,
+		LocationID: source.LocationID,
+		CustomerID: source.CustomerID,
+		Timestamp:  source.Timestamp,
+		Payload:    payload,
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal customer metrics event %s: %w", event.EventID, err)
+	}
+
+	message := kafka.Message{
+		Topic: fmt.Sprintf("%s.%s", source.OrgID, models.EventTypeCustomerMetricsUpdated),
+		Key:   []byte(source.CustomerID),
+		Value: eventJSON,
+		Time:  result.ProcessedAt,
+	}
+
+	_, err = withRetry(ctx, p.metricsRetry, "Customer metrics publish", isWriteRetryable, func() (struct{}, error) {
+		return struct{}{}, p.metricsWriter.WriteMessages(ctx, message)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish customer metrics for event %s: %w", source.EventID, err)
+	}
+	return nil
+}
+
+// isWriteRetryable reports whether a failed Kafka write is worth retrying:
+// any failure but the caller giving up
+func isWriteRetryable(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
 }
 
 func (p *EventProcessor) processPOSTransaction(ctx context.Context, event *models.BaseEvent) (*models.ProcessingResult, error) {
 	result := &models.ProcessingResult{
 		EventID:     event.EventID,
+		OrgID:       event.OrgID,
+		CustomerID:  event.CustomerID,
 		ProcessedAt: time.Now(),
 		Success:     false,
 	}
@@ -67,38 +413,112 @@ func (p *EventProcessor) processPOSTransaction(ctx context.Context, event *model
 		return result, nil
 	}
 
-	_, err = p.membershipClient.GetCustomer(event.CustomerID)
+	result = p.applyPOSTransaction(ctx, event, result, transaction)
+	if result.Deferred {
+		// Its metrics are published when it is reprocessed
+		return result, nil
+	}
+	if err := p.publishCustomerMetrics(ctx, event, transaction, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// applyPOSTransaction awards or refunds the points and stamps of a decoded
+// POS transaction
+func (p *EventProcessor) applyPOSTransaction(ctx context.Context, event *models.BaseEvent, result *models.ProcessingResult, transaction models.POSTransaction) *models.ProcessingResult {
+	customer, err := p.membershipClient.GetCustomer(ctx, event.CustomerID)
 	if err != nil {
 		result.Error = fmt.Sprintf("failed to get customer: %v", err)
-		return result, nil
+		return result
 	}
 
-	org, err := p.membershipClient.GetOrganization(event.OrgID)
+	org, err := p.membershipClient.GetOrganization(ctx, event.OrgID)
 	if err != nil {
 		result.Error = fmt.Sprintf("failed to get organization: %v", err)
-		return result, nil
+		return result
+	}
+
+	location, err := p.resolveLocation(ctx, event, org.Settings.UnknownLocationPolicy)
+	if err != nil {
+		result.Error = err.Error()
+		return result
 	}
 
-	pointsEarned := p.calculatePoints(transaction.Amount, org.Settings.PointsPerDollar)
-	stampsEarned := org.Settings.StampsPerVisit
+	var multipliers []float64
+	promotion := p.locationPromotion(event, location)
+	if promotion != nil {
+		multipliers = append(multipliers, promotion.PointsMultiplier)
+	}
+	tier := p.customerTier(ctx, event)
+	if tier != nil {
+		multipliers = append(multipliers, tier.PointsMultiplier)
+	}
+	custom, hasCustom := customMultiplier(customer, event)
+	if hasCustom {
+		multipliers = append(multipliers, custom)
+	}
+	pointsPerDollar := points.Rate(org.Settings.PointsPerDollar, multipliers...)
+
+	pointsEarned := p.calculateTransactionPoints(transaction, pointsPerDollar, org.Settings)
+	stampsEarned := transactionStamps(org.Settings)
+
+	if transaction.Refund {
+		return p.refundPOSTransaction(ctx, event, result, transaction, pointsEarned)
+	}
+
+	if status := customer.Status; status != "" && status != clients.CustomerStatusActive {
+		if org.Settings.InactiveCustomerPolicy != clients.InactiveCustomerFlag {
+			result.Error = fmt.Sprintf("customer %s is %s: points accrual skipped", event.CustomerID, status)
+			return result
+		}
+		log.Printf("Awarding POS transaction %s to customer %s whose status is %s", transaction.TransactionID, event.CustomerID, status)
+		result.Actions = append(result.Actions, fmt.Sprintf("flagged: customer status is %s", status))
+	}
 
 	if pointsEarned > 0 {
-		_, err := p.ledgerClient.CreatePointsTransfer(
-			event.OrgID,
-			event.CustomerID,
-			pointsEarned,
-			fmt.Sprintf("pos_transaction_%s", transaction.TransactionID),
-		)
+		accrual, ok := p.reserveAccrual(ctx, event, org.Settings, pointsEarned)
+		if !ok {
+			result.Error = fmt.Sprintf("points accrual for org %s is paused: daily ceiling of %d points exceeded",
+				event.OrgID, org.Settings.DailyPointsCeiling)
+			result.Deferred = p.deadLetterWriter != nil
+			return result
+		}
+
+		reference := fmt.Sprintf("pos_transaction_%s", transaction.TransactionID)
+		var err error
+		if org.Settings.PendingAccrual {
+			settleAfter := time.Duration(org.Settings.SettlementDelayHours) * time.Hour
+			_, err = p.ledgerClient.CreatePendingPointsTransfer(ctx, event.OrgID, event.CustomerID, pointsEarned, reference, saleCents(transaction.Amount), org.Settings.PointsExpiryDays, settleAfter)
+		} else {
+			_, err = p.ledgerClient.CreatePointsTransfer(ctx, event.OrgID, event.CustomerID, pointsEarned, reference, saleCents(transaction.Amount), org.Settings.PointsExpiryDays)
+		}
 		if err != nil {
+			p.releaseAccrual(event.OrgID, accrual.day, pointsEarned)
 			result.Error = fmt.Sprintf("failed to create points transfer: %v", err)
-			return result, nil
+			return result
 		}
 		result.PointsEarned = pointsEarned
-		result.Actions = append(result.Actions, fmt.Sprintf("awarded %d points", pointsEarned))
+		if org.Settings.PendingAccrual {
+			result.PointsPending = true
+			result.Actions = append(result.Actions, fmt.Sprintf("held %d points pending settlement", pointsEarned))
+		} else {
+			result.Actions = append(result.Actions, fmt.Sprintf("awarded %d points", pointsEarned))
+		}
+		if promotion != nil {
+			result.Actions = append(result.Actions, fmt.Sprintf("applied %s promotion: %gx points", promotion.Name, promotion.PointsMultiplier))
+		}
+		if tier != nil {
+			result.Actions = append(result.Actions, fmt.Sprintf("applied %s tier multiplier: %gx points", tier.CurrentTier, tier.PointsMultiplier))
+		}
+		if hasCustom {
+			result.Actions = append(result.Actions, fmt.Sprintf("applied custom multiplier: %gx points", custom))
+		}
 	}
 
 	if stampsEarned > 0 {
 		_, err := p.ledgerClient.CreateStampsTransfer(
+			ctx,
 			event.OrgID,
 			event.CustomerID,
 			stampsEarned,
@@ -106,25 +526,284 @@ func (p *EventProcessor) processPOSTransaction(ctx context.Context, event *model
 		)
 		if err != nil {
 			result.Error = fmt.Sprintf("failed to create stamps transfer: %v", err)
-			return result, nil
+			return result
 		}
 		result.StampsEarned = stampsEarned
 		result.Actions = append(result.Actions, fmt.Sprintf("awarded %d stamps", stampsEarned))
 	}
 
-	rewards := p.checkRewardThresholds(org.Settings.RewardThresholds, pointsEarned, stampsEarned)
+	// Held points are not the customer's until they settle, so they do not
+	// count towards rewards
+	creditedPoints := pointsEarned
+	if result.PointsPending {
+		creditedPoints = 0
+	}
+
+	var rewards []models.RewardTriggered
+	if len(org.Settings.RewardThresholds) > 0 {
+		thresholds := localizeThresholds(org.Settings)
+		if org.Settings.RewardThresholdBasis == clients.RewardBasisPerEvent {
+			rewards = p.checkRewardThresholds(thresholds, creditedPoints, stampsEarned)
+		} else {
+			balance, err := p.ledgerClient.GetBalance(ctx, event.OrgID, event.CustomerID)
+			if err != nil {
+				log.Printf("Skipping reward check for customer %s: failed to get balance: %v", event.CustomerID, err)
+			} else {
+				rewards = p.checkLifetimeRewardThresholds(thresholds, balance, creditedPoints, stampsEarned)
+			}
+		}
+	}
+	rewards = p.applyRewardMode(org.Settings.RewardMode, rewards)
 	result.RewardsTriggered = rewards
 
 	result.Success = true
 	log.Printf("Processed POS transaction %s: %d points, %d stamps, %d rewards",
 		transaction.TransactionID, pointsEarned, stampsEarned, len(rewards))
 
+	return result
+}
+
+// posRefundRewardID is recorded as the reward of refund deductions, which the
+// ledger posts as redemptions
+const posRefundRewardID = "pos_refund"
+
+// posAward is what a POS transaction earned in the ledger and how much of it
+// is left to refund
+type posAward struct {
+	// points and stamps are still refundable; held is the part of points
+	// still pending settlement
+	points int
+	stamps int
+	held   int
+	// earned is every point the sale earned and saleCents the sale amount
+	// stored with them, zero for accruals recorded without one. found is set
+	// when the ledger has any accrual for the transaction.
+	earned    int
+	saleCents uint64
+	found     bool
+}
+
+// lookupPOSAward totals the ledger transfers of a transaction's accrual and
+// of its earlier refunds. A hold counts once, not again when it is posted,
+// and points voided from it or reversed are no longer refundable.
+func (p *EventProcessor) lookupPOSAward(ctx context.Context, event *models.BaseEvent, transactionID string) (posAward, error) {
+	accrualReference := fmt.Sprintf("pos_transaction_%s", transactionID)
+	refundReference := fmt.Sprintf("pos_refund_%s", transactionID)
+
+	transfers, err := p.ledgerClient.GetTransfers(ctx, event.OrgID, event.CustomerID, accrualReference, refundReference)
+	if err != nil {
+		return posAward{}, err
+	}
+
+	var award posAward
+	for _, transfer := range transfers {
+		amount := int(transfer.Amount)
+		switch {
+		case transfer.Reference == refundReference && transfer.Stamps:
+			award.stamps -= amount
+		case transfer.Reference == refundReference:
+			award.points -= amount
+		case transfer.ReversedBy != "":
+			// Reversed in full outside of a refund
+			award.found = true
+		case transfer.Stamps:
+			award.found = true
+			award.stamps += amount
+		case transfer.PendingID != "":
+			// The posting of a hold already counted below
+		default:
+			award.found = true
+			award.earned += amount
+			award.points += amount - int(transfer.Voided)
+			if transfer.PendingStatus == pendingStatusPending {
+				award.held += amount - int(transfer.Voided)
+			}
+			if transfer.UserData > award.saleCents {
+				award.saleCents = transfer.UserData
+			}
+		}
+	}
+
+	award.points = max(award.points, 0)
+	award.stamps = max(award.stamps, 0)
+	award.held = min(award.held, award.points)
+	return award, nil
+}
+
+// pendingStatusPending is the ledger's status of a hold not yet settled
+const pendingStatusPending = "pending"
+
+// saleCents is a POS amount in cents, as stored with the points it earns
+func saleCents(amount float64) uint64 {
+	if amount <= 0 {
+		return 0
+	}
+	return uint64(math.Round(amount * 100))
+}
+
+// refundShare is how many of the points a sale earned its refund takes back:
+// the refunded share of the sale amount when the ledger has it, otherwise
+// what the refund earns at the current rates. Either way it never exceeds
+// what is left to refund.
+func refundShare(award posAward, transaction models.POSTransaction, currentPoints int) int {
+	share := currentPoints
+	if award.saleCents > 0 {
+		refunded := float64(saleCents(transaction.Amount)) / float64(award.saleCents)
+		share = int(math.Round(float64(award.earned) * refunded))
+	}
+	return min(share, award.points)
+}
+
+// refundPOSTransaction takes back what a refunded transaction earned, as
+// recorded in the ledger, so rate or promotion changes since the sale do not
+// change the reversal. Points earned in proportion to the refunded amount and
+// the visit's stamps are deducted in a single ledger redemption, capped at
+// what earlier refunds left, and a refund the customer can no longer cover
+// deducts nothing. Points still held for the transaction are voided first and
+// only the rest is redeemed.
+func (p *EventProcessor) refundPOSTransaction(ctx context.Context, event *models.BaseEvent, result *models.ProcessingResult, transaction models.POSTransaction, currentPoints int) *models.ProcessingResult {
+	award, err := p.lookupPOSAward(ctx, event, transaction.TransactionID)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to look up transaction %s: %v", transaction.TransactionID, err)
+		return result
+	}
+	if !award.found {
+		result.Error = fmt.Sprintf("nothing was awarded for transaction %s to refund", transaction.TransactionID)
+		return result
+	}
+	points := refundShare(award, transaction, currentPoints)
+	stamps := award.stamps
+
+	voided := 0
+	if award.held > 0 && points > 0 {
+		response, err := p.ledgerClient.SettlePendingTransfer(
+			ctx,
+			event.OrgID,
+			event.CustomerID,
+			fmt.Sprintf("pos_transaction_%s", transaction.TransactionID),
+			true,
+			min(points, award.held),
+		)
+		switch {
+		case err == nil:
+			// The ledger voids no more than is still held
+			voided = min(int(response.Amount), points)
+			points -= voided
+			p.releaseVoided(event.OrgID, response)
+		case errors.Is(err, clients.ErrNoPendingTransfer):
+			// Settled since the lookup, so the points are deducted as usual
+		default:
+			result.Error = fmt.Sprintf("failed to void pending points: %v", err)
+			return result
+		}
+	}
+
+	if points > 0 || stamps > 0 {
+		_, err := p.ledgerClient.CreateRedemption(
+			ctx,
+			event.OrgID,
+			event.CustomerID,
+			posRefundRewardID,
+			points,
+			stamps,
+			0,
+			0,
+			fmt.Sprintf("pos_refund_%s", transaction.TransactionID),
+		)
+		if err != nil {
+			// Points already voided stay voided
+			result.PointsEarned = -voided
+			if voided > 0 {
+				result.Actions = append(result.Actions, fmt.Sprintf("voided %d pending points", voided))
+			}
+		}
+		if errors.Is(err, clients.ErrInsufficientBalance) {
+			result.Error = fmt.Sprintf("insufficient balance to refund transaction %s: %d points and %d stamps",
+				transaction.TransactionID, points, stamps)
+			return result
+		}
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to refund transaction: %v", err)
+			return result
+		}
+	}
+
+	result.PointsEarned = -(points + voided)
+	result.StampsEarned = -stamps
+	if voided > 0 {
+		result.Actions = append(result.Actions, fmt.Sprintf("voided %d pending points", voided))
+	}
+	if points > 0 {
+		result.Actions = append(result.Actions, fmt.Sprintf("refunded %d points", points))
+	}
+	if stamps > 0 {
+		result.Actions = append(result.Actions, fmt.Sprintf("refunded %d stamps", stamps))
+	}
+
+	result.Success = true
+	log.Printf("Processed POS refund %s: -%d points, -%d stamps", transaction.TransactionID, points+voided, stamps)
+
+	return result
+}
+
+// processPOSSettlement posts the points held for a settled transaction, or
+// voids them if it was voided before settling
+func (p *EventProcessor) processPOSSettlement(ctx context.Context, event *models.BaseEvent) (*models.ProcessingResult, error) {
+	result := &models.ProcessingResult{
+		EventID:     event.EventID,
+		OrgID:       event.OrgID,
+		CustomerID:  event.CustomerID,
+		ProcessedAt: time.Now(),
+		Success:     false,
+	}
+
+	var settlement models.POSSettlement
+	settlementData, err := json.Marshal(event.Payload)
+	if err != nil {
+		result.Error = "failed to marshal settlement payload"
+		return result, nil
+	}
+
+	if err := json.Unmarshal(settlementData, &settlement); err != nil {
+		result.Error = "failed to unmarshal settlement data"
+		return result, nil
+	}
+
+	response, err := p.ledgerClient.SettlePendingTransfer(
+		ctx,
+		event.OrgID,
+		event.CustomerID,
+		fmt.Sprintf("pos_transaction_%s", settlement.TransactionID),
+		settlement.Voided,
+		0,
+	)
+	if errors.Is(err, clients.ErrNoPendingTransfer) {
+		result.Error = fmt.Sprintf("no pending points to settle for transaction %s", settlement.TransactionID)
+		return result, nil
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to settle pending points: %v", err)
+		return result, nil
+	}
+
+	if settlement.Voided {
+		p.releaseVoided(event.OrgID, response)
+		result.Actions = append(result.Actions, fmt.Sprintf("voided pending points for transaction %s", settlement.TransactionID))
+	} else {
+		result.Actions = append(result.Actions, fmt.Sprintf("posted pending points for transaction %s", settlement.TransactionID))
+	}
+
+	result.Success = true
+	log.Printf("Processed POS settlement %s: voided=%t", settlement.TransactionID, settlement.Voided)
+
 	return result, nil
 }
 
 func (p *EventProcessor) processLoyaltyAction(ctx context.Context, event *models.BaseEvent) (*models.ProcessingResult, error) {
 	result := &models.ProcessingResult{
 		EventID:     event.EventID,
+		OrgID:       event.OrgID,
+		CustomerID:  event.CustomerID,
 		ProcessedAt: time.Now(),
 		Success:     false,
 	}
@@ -144,11 +823,20 @@ func (p *EventProcessor) processLoyaltyAction(ctx context.Context, event *models
 	switch action.ActionType {
 	case "manual_points":
 		if action.Points > 0 {
-			_, err := p.ledgerClient.CreatePointsTransfer(
+			// Awarded points expire like earned ones
+			org, err := p.membershipClient.GetOrganization(ctx, event.OrgID)
+			if err != nil {
+				result.Error = fmt.Sprintf("failed to get organization: %v", err)
+				return result, nil
+			}
+			_, err = p.ledgerClient.CreatePointsTransfer(
+				ctx,
 				event.OrgID,
 				event.CustomerID,
 				action.Points,
 				action.Reference,
+				0,
+				org.Settings.PointsExpiryDays,
 			)
 			if err != nil {
 				result.Error = fmt.Sprintf("failed to create points transfer: %v", err)
@@ -160,6 +848,7 @@ func (p *EventProcessor) processLoyaltyAction(ctx context.Context, event *models
 	case "bonus_stamps":
 		if action.Stamps > 0 {
 			_, err := p.ledgerClient.CreateStampsTransfer(
+				ctx,
 				event.OrgID,
 				event.CustomerID,
 				action.Stamps,
@@ -172,6 +861,79 @@ func (p *EventProcessor) processLoyaltyAction(ctx context.Context, event *models
 			result.StampsEarned = action.Stamps
 			result.Actions = append(result.Actions, fmt.Sprintf("bonus stamps: %d", action.Stamps))
 		}
+	case "redeem_reward":
+		if action.RewardID == "" {
+			result.Error = "reward_id is required to redeem a reward"
+			return result, nil
+		}
+
+		org, err := p.membershipClient.GetOrganization(ctx, event.OrgID)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to get organization: %v", err)
+			return result, nil
+		}
+
+		// The cost comes from the org's catalog, not the event, so a terminal
+		// cannot redeem a reward for less than it is worth
+		reward, ok := findReward(org.Settings.RewardThresholds, action.RewardID)
+		if !ok {
+			result.Error = fmt.Sprintf("reward %s is not in the reward catalog", action.RewardID)
+			return result, nil
+		}
+
+		balance, err := p.ledgerClient.GetBalance(ctx, event.OrgID, event.CustomerID)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to get balance: %v", err)
+			return result, nil
+		}
+		if balance.PointsBalance < uint64(reward.Points) || balance.StampsBalance < uint64(reward.Stamps) {
+			result.Error = fmt.Sprintf("insufficient balance to redeem reward %s: costs %d points and %d stamps, balance is %d points and %d stamps",
+				action.RewardID, reward.Points, reward.Stamps, balance.PointsBalance, balance.StampsBalance)
+			return result, nil
+		}
+
+		// The bonus goes to the ledger with the redemption so both post or
+		// neither does. The ledger re-checks the balance in the same
+		// operation, so a concurrent spend still cannot overdraw it.
+		bonusPoints := redemptionBonus(org.Settings.RedemptionBonuses, action.RewardID)
+		_, err = p.ledgerClient.CreateRedemption(
+			ctx,
+			event.OrgID,
+			event.CustomerID,
+			action.RewardID,
+			reward.Points,
+			reward.Stamps,
+			bonusPoints,
+			org.Settings.PointsExpiryDays,
+			action.Reference,
+		)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to redeem reward: %v", err)
+			return result, nil
+		}
+		result.PointsEarned = bonusPoints
+		result.Actions = append(result.Actions, fmt.Sprintf("redeemed reward %s", action.RewardID))
+		if bonusPoints > 0 {
+			result.Actions = append(result.Actions, fmt.Sprintf("redemption bonus: %d points", bonusPoints))
+		}
+	case "redeem_benefit":
+		if action.Benefit == "" {
+			result.Error = "benefit is required to redeem a benefit"
+			return result, nil
+		}
+		if p.analyticsClient == nil {
+			result.Error = "benefit redemption needs the analytics service"
+			return result, nil
+		}
+
+		// Analytics keeps the usage ledger and refuses uses past the
+		// benefit's frequency limit
+		usage, err := p.analyticsClient.RedeemBenefit(ctx, event.OrgID, event.CustomerID, action.Benefit, action.Reference)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to redeem benefit %s: %v", action.Benefit, err)
+			return result, nil
+		}
+		result.Actions = append(result.Actions, fmt.Sprintf("redeemed %s benefit %s", usage.Tier, action.Benefit))
 	default:
 		result.Error = fmt.Sprintf("unknown loyalty action type: %s", action.ActionType)
 		return result, nil
@@ -183,17 +945,158 @@ func (p *EventProcessor) processLoyaltyAction(ctx context.Context, event *models
 	return result, nil
 }
 
-func (p *EventProcessor) calculatePoints(amount, pointsPerDollar float64) int {
+// resolveLocation looks up the event's location and checks it belongs to the
+// event's org. Under the strict policy a missing or foreign location rejects
+// the event; otherwise it is logged and the event is processed without
+// location promotions.
+func (p *EventProcessor) resolveLocation(ctx context.Context, event *models.BaseEvent, policy string) (*clients.Location, error) {
+	if event.LocationID == "" {
+		return nil, nil
+	}
+
+	location, err := p.membershipClient.GetLocation(ctx, event.LocationID)
+	if err == nil && location.OrgID != event.OrgID {
+		err = clients.ErrLocationNotFound
+	}
+	if err != nil {
+		if policy == clients.UnknownLocationStrict {
+			if errors.Is(err, clients.ErrLocationNotFound) {
+				return nil, fmt.Errorf("unknown location %s for org %s", event.LocationID, event.OrgID)
+			}
+			return nil, fmt.Errorf("failed to get location: %w", err)
+		}
+		log.Printf("Warning: event %s has location %s that could not be validated for org %s: %v",
+			event.EventID, event.LocationID, event.OrgID, err)
+		return nil, nil
+	}
+
+	return location, nil
+}
+
+// locationPromotion returns the time promotion for location at the event's
+// timestamp. Configuration failures are logged and the transaction earns the
+// base rate.
+func (p *EventProcessor) locationPromotion(event *models.BaseEvent, location *clients.Location) *clients.TimePromotion {
+	if location == nil {
+		return nil
+	}
+
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	promotion, err := activePromotion(location, timestamp)
+	if err != nil {
+		log.Printf("Skipping location promotions for event %s: location %s: %v", event.EventID, event.LocationID, err)
+		return nil
+	}
+	return promotion
+}
+
+// customMultiplier returns the multiplier staff set on the customer when it
+// is still in force at the event's timestamp
+func customMultiplier(customer *clients.Customer, event *models.BaseEvent) (float64, bool) {
+	if customer.CustomMultiplier <= 0 || customer.MultiplierExpiresAt == nil {
+		return 0, false
+	}
+
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	if !timestamp.Before(*customer.MultiplierExpiresAt) {
+		return 0, false
+	}
+	return customer.CustomMultiplier, true
+}
+
+// customerTier returns the customer's tier from analytics, or nil when there
+// is none to apply. A tier that cannot be fetched earns at 1x rather than
+// failing the transaction.
+func (p *EventProcessor) customerTier(ctx context.Context, event *models.BaseEvent) *clients.CustomerTier {
+	if p.analyticsClient == nil {
+		return nil
+	}
+
+	tier, err := p.analyticsClient.GetCustomerTier(ctx, event.OrgID, event.CustomerID)
+	if err != nil {
+		if !errors.Is(err, clients.ErrTierNotFound) {
+			log.Printf("Skipping tier multiplier for event %s: %v", event.EventID, err)
+		}
+		return nil
+	}
+	if tier.PointsMultiplier <= 0 {
+		log.Printf("Skipping tier multiplier for event %s: tier %s has multiplier %g", event.EventID, tier.CurrentTier, tier.PointsMultiplier)
+		return nil
+	}
+	return tier
+}
+
+// redemptionBonus returns the bonus points configured for rewardID, or zero
+func redemptionBonus(bonuses []clients.RedemptionBonus, rewardID string) int {
+	for _, bonus := range bonuses {
+		if bonus.RewardID == rewardID && bonus.BonusPoints > 0 {
+			return bonus.BonusPoints
+		}
+	}
+	return 0
+}
+
+// transactionStamps is how many stamps one POS transaction earns: the org's
+// stamps per visit, capped at MaxStampsPerTransaction when that is set.
+// Membership rejects settings above the cap, but orgs saved before it did or
+// written straight to MongoDB are still capped here.
+func transactionStamps(settings clients.OrgSettings) int {
+	stamps := settings.StampsPerVisit
+	if settings.MaxStampsPerTransaction > 0 && stamps > settings.MaxStampsPerTransaction {
+		return settings.MaxStampsPerTransaction
+	}
+	return stamps
+}
+
+// calculateTransactionPoints prices a transaction's points. When the org has
+// category multipliers and the transaction lists its items, points are earned
+// per line item and rounded down per item or once for the basket according
+// to the org's rounding granularity; otherwise they come from the total.
+func (p *EventProcessor) calculateTransactionPoints(transaction models.POSTransaction, pointsPerDollar float64, settings clients.OrgSettings) int {
+	if len(settings.CategoryMultipliers) == 0 || len(transaction.Items) == 0 {
+		return p.calculatePoints(transaction.Amount, pointsPerDollar)
+	}
 	if pointsPerDollar <= 0 {
 		return 0
 	}
-	return int(math.Floor(amount * pointsPerDollar))
+
+	var total float64
+	roundedTotal := 0
+	for _, item := range transaction.Items {
+		multiplier, ok := settings.CategoryMultipliers[item.Category]
+		if !ok || multiplier < 0 {
+			multiplier = 1
+		}
+		itemPoints := item.TotalPrice * pointsPerDollar * multiplier
+		total += itemPoints
+		roundedTotal += int(math.Floor(itemPoints))
+	}
+
+	if settings.RoundingGranularity == clients.RoundingItem {
+		return roundedTotal
+	}
+	return int(math.Floor(total))
 }
 
+func (p *EventProcessor) calculatePoints(amount, pointsPerDollar float64) int {
+	return points.Calculate(amount, pointsPerDollar)
+}
+
+// checkRewardThresholds returns every threshold the customer qualifies for
+// with what a single event earned, ordered from lowest to highest by points
+// and then stamps
 func (p *EventProcessor) checkRewardThresholds(thresholds []clients.RewardThreshold, points, stamps int) []models.RewardTriggered {
 	var rewards []models.RewardTriggered
 
-	for _, threshold := range thresholds {
+	for _, threshold := range sortRewardThresholds(thresholds) {
 		triggered := false
 		
 		if threshold.Points > 0 && points >= threshold.Points {
@@ -205,15 +1108,90 @@ func (p *EventProcessor) checkRewardThresholds(thresholds []clients.RewardThresh
 		}
 
 		if triggered {
-			rewards = append(rewards, models.RewardTriggered{
-				RewardID:    fmt.Sprintf("reward_%d_%d", threshold.Points, threshold.Stamps),
-				RewardType:  threshold.RewardType,
-				RewardValue: threshold.RewardValue,
-				Description: threshold.Description,
-				TriggeredAt: time.Now(),
-			})
+			rewards = append(rewards, newRewardTriggered(threshold))
 		}
 	}
 
 	return rewards
-}
\ No newline at end of file
+}
+
+// checkLifetimeRewardThresholds returns every threshold the customer's balance
+// crossed with this event, in the same order as checkRewardThresholds. balance
+// already includes the event, so subtracting what it earned gives the balance
+// before it. A threshold the customer was already past does not fire again.
+func (p *EventProcessor) checkLifetimeRewardThresholds(thresholds []clients.RewardThreshold, balance *clients.Balance, pointsEarned, stampsEarned int) []models.RewardTriggered {
+	var rewards []models.RewardTriggered
+
+	pointsAfter := int(balance.PointsBalance)
+	stampsAfter := int(balance.StampsBalance)
+	pointsBefore := pointsAfter - pointsEarned
+	stampsBefore := stampsAfter - stampsEarned
+
+	for _, threshold := range sortRewardThresholds(thresholds) {
+		triggered := false
+
+		if threshold.Points > 0 && pointsBefore < threshold.Points && pointsAfter >= threshold.Points {
+			triggered = true
+		}
+
+		if threshold.Stamps > 0 && stampsBefore < threshold.Stamps && stampsAfter >= threshold.Stamps {
+			triggered = true
+		}
+
+		if triggered {
+			rewards = append(rewards, newRewardTriggered(threshold))
+		}
+	}
+
+	return rewards
+}
+
+// sortRewardThresholds returns a copy of thresholds ordered from lowest to
+// highest by points and then stamps
+func sortRewardThresholds(thresholds []clients.RewardThreshold) []clients.RewardThreshold {
+	ordered := make([]clients.RewardThreshold, len(thresholds))
+	copy(ordered, thresholds)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Points != ordered[j].Points {
+			return ordered[i].Points < ordered[j].Points
+		}
+		return ordered[i].Stamps < ordered[j].Stamps
+	})
+	return ordered
+}
+
+// rewardID is the catalog ID of a reward threshold
+func rewardID(threshold clients.RewardThreshold) string {
+	if threshold.RewardID != "" {
+		return threshold.RewardID
+	}
+	return fmt.Sprintf("reward_%d_%d", threshold.Points, threshold.Stamps)
+}
+
+// findReward looks up a reward in the org's catalog of reward thresholds
+func findReward(thresholds []clients.RewardThreshold, id string) (clients.RewardThreshold, bool) {
+	for _, threshold := range thresholds {
+		if rewardID(threshold) == id {
+			return threshold, true
+		}
+	}
+	return clients.RewardThreshold{}, false
+}
+
+func newRewardTriggered(threshold clients.RewardThreshold) models.RewardTriggered {
+	return models.RewardTriggered{
+		RewardID:    rewardID(threshold),
+		RewardType:  threshold.RewardType,
+		RewardValue: threshold.RewardValue,
+		Description: threshold.Description,
+		TriggeredAt: time.Now(),
+	}
+}
+
+// applyRewardMode narrows ordered rewards according to the org's reward mode
+func (p *EventProcessor) applyRewardMode(mode string, rewards []models.RewardTriggered) []models.RewardTriggered {
+	if mode == clients.RewardModeHighest && len(rewards) > 1 {
+		return rewards[len(rewards)-1:]
+	}
+	return rewards
+}
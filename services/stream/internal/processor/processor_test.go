@@ -3,10 +3,16 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/loyalty/stream/internal/ceiling"
 	"github.com/loyalty/stream/internal/clients"
+	"github.com/loyalty/stream/internal/idempotency"
 	"github.com/loyalty/stream/internal/models"
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
@@ -18,7 +24,7 @@ type MockLedgerClient struct {
 	mock.Mock
 }
 
-func (m *MockLedgerClient) CreatePointsTransfer(orgID, customerID string, points int, reference string) (*clients.TransferResponse, error) {
+func (m *MockLedgerClient) CreatePointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int) (*clients.TransferResponse, error) {
 	args := m.Called(orgID, customerID, points, reference)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -26,7 +32,23 @@ func (m *MockLedgerClient) CreatePointsTransfer(orgID, customerID string, points
 	return args.Get(0).(*clients.TransferResponse), args.Error(1)
 }
 
-func (m *MockLedgerClient) CreateStampsTransfer(orgID, customerID string, stamps int, reference string) (*clients.TransferResponse, error) {
+func (m *MockLedgerClient) CreatePendingPointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int, settleAfter time.Duration) (*clients.TransferResponse, error) {
+	args := m.Called(orgID, customerID, points, reference, settleAfter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.TransferResponse), args.Error(1)
+}
+
+func (m *MockLedgerClient) SettlePendingTransfer(ctx context.Context, orgID, customerID, reference string, void bool, points int) (*clients.TransferResponse, error) {
+	args := m.Called(orgID, customerID, reference, void, points)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.TransferResponse), args.Error(1)
+}
+
+func (m *MockLedgerClient) CreateStampsTransfer(ctx context.Context, orgID, customerID string, stamps int, reference string) (*clients.TransferResponse, error) {
 	args := m.Called(orgID, customerID, stamps, reference)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -34,12 +56,36 @@ func (m *MockLedgerClient) CreateStampsTransfer(orgID, customerID string, stamps
 	return args.Get(0).(*clients.TransferResponse), args.Error(1)
 }
 
+func (m *MockLedgerClient) CreateRedemption(ctx context.Context, orgID, customerID, rewardID string, points, stamps, bonusPoints, bonusExpiryDays int, reference string) (*clients.RedemptionResponse, error) {
+	args := m.Called(orgID, customerID, rewardID, points, stamps, bonusPoints, reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.RedemptionResponse), args.Error(1)
+}
+
+func (m *MockLedgerClient) GetBalance(ctx context.Context, orgID, customerID string) (*clients.Balance, error) {
+	args := m.Called(orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.Balance), args.Error(1)
+}
+
+func (m *MockLedgerClient) GetTransfers(ctx context.Context, orgID, customerID string, references ...string) ([]clients.Transfer, error) {
+	args := m.Called(orgID, customerID, references)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]clients.Transfer), args.Error(1)
+}
+
 // MockMembershipClient is a mock implementation of the membership client
 type MockMembershipClient struct {
 	mock.Mock
 }
 
-func (m *MockMembershipClient) GetCustomer(customerID string) (*clients.Customer, error) {
+func (m *MockMembershipClient) GetCustomer(ctx context.Context, customerID string) (*clients.Customer, error) {
 	args := m.Called(customerID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -47,7 +93,7 @@ func (m *MockMembershipClient) GetCustomer(customerID string) (*clients.Customer
 	return args.Get(0).(*clients.Customer), args.Error(1)
 }
 
-func (m *MockMembershipClient) GetOrganization(orgID string) (*clients.Organization, error) {
+func (m *MockMembershipClient) GetOrganization(ctx context.Context, orgID string) (*clients.Organization, error) {
 	args := m.Called(orgID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -55,9 +101,48 @@ func (m *MockMembershipClient) GetOrganization(orgID string) (*clients.Organizat
 	return args.Get(0).(*clients.Organization), args.Error(1)
 }
 
+func (m *MockMembershipClient) GetLocation(ctx context.Context, locationID string) (*clients.Location, error) {
+	args := m.Called(locationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.Location), args.Error(1)
+}
+
+// MockAnalyticsClient is a mock implementation of the analytics client
+type MockAnalyticsClient struct {
+	mock.Mock
+}
+
+func (m *MockAnalyticsClient) GetCustomerTier(ctx context.Context, orgID, customerID string) (*clients.CustomerTier, error) {
+	args := m.Called(orgID, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.CustomerTier), args.Error(1)
+}
+
+func (m *MockAnalyticsClient) RedeemBenefit(ctx context.Context, orgID, customerID, benefit, reference string) (*clients.BenefitUsage, error) {
+	args := m.Called(orgID, customerID, benefit, reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*clients.BenefitUsage), args.Error(1)
+}
+
+// MockMessageWriter is a mock implementation of the Kafka result writer
+type MockMessageWriter struct {
+	mock.Mock
+}
+
+func (m *MockMessageWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	args := m.Called(ctx, msgs)
+	return args.Error(0)
+}
+
 // Test setup helper
 func setupTestProcessor() (*EventProcessor, *MockLedgerClient, *MockMembershipClient) {
-	processor := &EventProcessor{}
+	processor := &EventProcessor{accrual: ceiling.NewMemoryCounter()}
 	
 	// Create mock clients
 	mockLedgerClient := &MockLedgerClient{}
@@ -359,175 +444,2126 @@ func TestProcessEvent_POSTransaction_PointsTransferError(t *testing.T) {
 	mockMembershipClient.AssertExpectations(t)
 }
 
-// Test LoyaltyAction processing
-func TestProcessEvent_LoyaltyAction_ManualPoints_Success(t *testing.T) {
-	processor, mockLedgerClient, _ := setupTestProcessor()
-	
-	// Test data
-	action := models.LoyaltyAction{
-		ActionType: "manual_points",
-		Points:     50,
-		Reference:  "manual_award",
-	}
-	
-	event := models.BaseEvent{
-		EventID:     "evt_123",
-		EventType:   models.EventTypeLoyaltyAction,
-		OrgID:       "test_org",
-		CustomerID:  "test_customer",
-		Timestamp:   time.Now(),
-		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
-	}
-	
-	eventData, _ := json.Marshal(event)
-	message := kafka.Message{
-		Value: eventData,
+// Test customer status
+func setupCustomerStatusTest(status, policy string) (*EventProcessor, *MockLedgerClient) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: status}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:        2.0,
+			StampsPerVisit:         1,
+			InactiveCustomerPolicy: policy,
+		},
 	}
-	
-	// Mock responses
-	mockTransferResponse := &clients.TransferResponse{
-		TransferID: "transfer_123",
-		Status:     "success",
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_123").Return(mockTransferResponse, nil)
+	mockLedgerClient.On("CreateStampsTransfer", "test_org", "test_customer", 1, "pos_transaction_txn_123").Return(mockTransferResponse, nil)
+
+	return processor, mockLedgerClient
+}
+
+func TestProcessEvent_POSTransaction_SuspendedCustomerSkipped(t *testing.T) {
+	for _, status := range []string{"suspended", "deleted", "inactive"} {
+		t.Run(status, func(t *testing.T) {
+			processor, mockLedgerClient := setupCustomerStatusTest(status, "")
+
+			// Process event
+			result, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_123", 50.0))
+
+			// Assertions
+			assert.NoError(t, err)
+			assert.False(t, result.Success)
+			assert.Equal(t, 0, result.PointsEarned)
+			assert.Equal(t, 0, result.StampsEarned)
+			assert.Equal(t, "customer test_customer is "+status+": points accrual skipped", result.Error)
+			mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mockLedgerClient.AssertNotCalled(t, "CreateStampsTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
 	}
-	
-	// Setup expectations
-	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 50, "manual_award").Return(mockTransferResponse, nil)
-	
+}
+
+func TestProcessEvent_POSTransaction_SuspendedCustomerFlagged(t *testing.T) {
+	processor, mockLedgerClient := setupCustomerStatusTest("suspended", clients.InactiveCustomerFlag)
+
 	// Process event
-	result, err := processor.ProcessEvent(context.Background(), message)
-	
+	result, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_123", 50.0))
+
 	// Assertions
 	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, "evt_123", result.EventID)
 	assert.True(t, result.Success)
-	assert.Equal(t, 50, result.PointsEarned)
-	assert.Len(t, result.Actions, 1)
-	assert.Contains(t, result.Actions[0], "manual award: 50 points")
-	
+	assert.Equal(t, 100, result.PointsEarned)
+	assert.Equal(t, 1, result.StampsEarned)
+	assert.Contains(t, result.Actions, "flagged: customer status is suspended")
 	mockLedgerClient.AssertExpectations(t)
 }
 
-func TestProcessEvent_LoyaltyAction_BonusStamps_Success(t *testing.T) {
-	processor, mockLedgerClient, _ := setupTestProcessor()
-	
-	// Test data
-	action := models.LoyaltyAction{
-		ActionType: "bonus_stamps",
-		Stamps:     5,
-		Reference:  "bonus_award",
-	}
-	
-	event := models.BaseEvent{
-		EventID:     "evt_123",
-		EventType:   models.EventTypeLoyaltyAction,
-		OrgID:       "test_org",
-		CustomerID:  "test_customer",
-		Timestamp:   time.Now(),
-		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
+func TestProcessEvent_POSTransaction_ActiveCustomerAccrues(t *testing.T) {
+	for _, status := range []string{"active", ""} {
+		t.Run(status, func(t *testing.T) {
+			processor, mockLedgerClient := setupCustomerStatusTest(status, clients.InactiveCustomerSkip)
+
+			// Process event
+			result, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_123", 50.0))
+
+			// Assertions
+			assert.NoError(t, err)
+			assert.True(t, result.Success)
+			assert.Equal(t, 100, result.PointsEarned)
+			assert.Len(t, result.Actions, 2)
+			mockLedgerClient.AssertExpectations(t)
+		})
 	}
-	
-	eventData, _ := json.Marshal(event)
-	message := kafka.Message{
-		Value: eventData,
+}
+
+// Test custom multiplier setup helper - 2 points per dollar on a $50 purchase
+func setupCustomMultiplierTest(expiresAt time.Time, points int) (*EventProcessor, *MockLedgerClient) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+
+	mockCustomer := &clients.Customer{
+		CustomerID:          "test_customer",
+		OrgID:               "test_org",
+		Status:              clients.CustomerStatusActive,
+		CustomMultiplier:    1.5,
+		MultiplierExpiresAt: &expiresAt,
 	}
-	
-	// Mock responses
-	mockTransferResponse := &clients.TransferResponse{
-		TransferID: "transfer_123",
-		Status:     "success",
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 2.0},
 	}
-	
-	// Setup expectations
-	mockLedgerClient.On("CreateStampsTransfer", "test_org", "test_customer", 5, "bonus_award").Return(mockTransferResponse, nil)
-	
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", points, "pos_transaction_txn_123").Return(mockTransferResponse, nil)
+
+	return processor, mockLedgerClient
+}
+
+func TestProcessEvent_POSTransaction_CustomMultiplierWhileActive(t *testing.T) {
+	processor, mockLedgerClient := setupCustomMultiplierTest(time.Now().Add(24*time.Hour), 150)
+
 	// Process event
-	result, err := processor.ProcessEvent(context.Background(), message)
-	
+	result, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_123", 50.0))
+
 	// Assertions
 	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, "evt_123", result.EventID)
 	assert.True(t, result.Success)
-	assert.Equal(t, 5, result.StampsEarned)
-	assert.Len(t, result.Actions, 1)
-	assert.Contains(t, result.Actions[0], "bonus stamps: 5")
-	
+	assert.Equal(t, 150, result.PointsEarned)
+	assert.Contains(t, result.Actions, "applied custom multiplier: 1.5x points")
 	mockLedgerClient.AssertExpectations(t)
 }
 
-func TestProcessEvent_LoyaltyAction_UnknownActionType(t *testing.T) {
-	processor, _, _ := setupTestProcessor()
-	
-	// Test data
-	action := models.LoyaltyAction{
-		ActionType: "unknown_action",
-		Points:     50,
-		Reference:  "test",
-	}
-	
-	event := models.BaseEvent{
-		EventID:     "evt_123",
-		EventType:   models.EventTypeLoyaltyAction,
-		OrgID:       "test_org",
-		CustomerID:  "test_customer",
-		Timestamp:   time.Now(),
-		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
-	}
-	
-	eventData, _ := json.Marshal(event)
-	message := kafka.Message{
-		Value: eventData,
-	}
-	
+func TestProcessEvent_POSTransaction_CustomMultiplierIgnoredAfterExpiry(t *testing.T) {
+	processor, mockLedgerClient := setupCustomMultiplierTest(time.Now().Add(-time.Hour), 100)
+
 	// Process event
-	result, err := processor.ProcessEvent(context.Background(), message)
-	
+	result, err := processor.ProcessEvent(context.Background(), ceilingTransaction("txn_123", 50.0))
+
 	// Assertions
 	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, "evt_123", result.EventID)
-	assert.False(t, result.Success)
-	assert.Contains(t, result.Error, "unknown loyalty action type")
+	assert.True(t, result.Success)
+	assert.Equal(t, 100, result.PointsEarned)
+	assert.NotContains(t, result.Actions, "applied custom multiplier: 1.5x points")
+	mockLedgerClient.AssertExpectations(t)
 }
 
-func TestProcessEvent_LoyaltyAction_ZeroPoints(t *testing.T) {
-	processor, mockLedgerClient, _ := setupTestProcessor()
-	
-	// Test data
-	action := models.LoyaltyAction{
-		ActionType: "manual_points",
-		Points:     0, // Zero points
-		Reference:  "manual_award",
-	}
-	
+// Test duplicate events
+func duplicateTestEvent(orgID string) kafka.Message {
 	event := models.BaseEvent{
-		EventID:     "evt_123",
-		EventType:   models.EventTypeLoyaltyAction,
-		OrgID:       "test_org",
-		CustomerID:  "test_customer",
-		Timestamp:   time.Now(),
-		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
+		EventID:    "evt_dup",
+		EventType:  models.EventTypePOSTransaction,
+		OrgID:      orgID,
+		CustomerID: "test_customer",
+		Timestamp:  time.Now(),
+		Payload: map[string]interface{}{
+			"transaction_id": "txn_dup",
+			"amount":         50.0,
+		},
 	}
-	
 	eventData, _ := json.Marshal(event)
-	message := kafka.Message{
-		Value: eventData,
-	}
-	
-	// Process event
-	result, err := processor.ProcessEvent(context.Background(), message)
-	
+	return kafka.Message{Value: eventData}
+}
+
+func TestProcessEvent_DuplicateEventSkipped(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	processor.processed = idempotency.NewMemoryStore()
+	mockWriter := &MockMessageWriter{}
+	processor.resultWriter = mockWriter
+
+	// Setup expectations
+	mockOrg := &clients.Organization{OrgID: "test_org", Settings: clients.OrgSettings{PointsPerDollar: 2.0}}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_dup").
+		Return(&clients.TransferResponse{TransferID: "transfer_123"}, nil)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process event twice
+	first, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+	assert.NoError(t, err)
+	second, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+	assert.NoError(t, err)
+
 	// Assertions
+	assert.True(t, first.Success)
+	assert.False(t, first.Duplicate)
+	assert.Equal(t, 100, first.PointsEarned)
+	assert.True(t, second.Success)
+	assert.True(t, second.Duplicate)
+	assert.Equal(t, 0, second.PointsEarned)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 1)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+}
+
+func TestProcessEvent_DuplicateCheckKeyedOnOrg(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	processor.processed = idempotency.NewMemoryStore()
+
+	// Setup expectations - both orgs use the same event ID
+	for _, orgID := range []string{"org_a", "org_b"} {
+		mockOrg := &clients.Organization{OrgID: orgID, Settings: clients.OrgSettings{PointsPerDollar: 2.0}}
+		mockMembershipClient.On("GetOrganization", orgID).Return(mockOrg, nil)
+		mockLedgerClient.On("CreatePointsTransfer", orgID, "test_customer", 100, "pos_transaction_txn_dup").
+			Return(&clients.TransferResponse{TransferID: "transfer_" + orgID}, nil)
+	}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+
+	// Process events
+	resultA, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("org_a"))
 	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, "evt_123", result.EventID)
-	assert.True(t, result.Success)
-	assert.Equal(t, 0, result.PointsEarned)
-	assert.Len(t, result.Actions, 0)
-	
-	// Should not call ledger client for zero points
-	mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer")
+	resultB, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("org_b"))
+	assert.NoError(t, err)
+
+	// Assertions
+	assert.False(t, resultA.Duplicate)
+	assert.False(t, resultB.Duplicate)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 2)
+}
+
+func TestProcessEvent_FailedEventNotRecorded(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	processor.processed = idempotency.NewMemoryStore()
+
+	// Setup expectations - the first attempt fails at the ledger
+	mockOrg := &clients.Organization{OrgID: "test_org", Settings: clients.OrgSettings{PointsPerDollar: 2.0}}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_dup").
+		Return(nil, errors.New("ledger unavailable")).Once()
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_dup").
+		Return(&clients.TransferResponse{TransferID: "transfer_123"}, nil).Once()
+
+	// Process event twice
+	first, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+	assert.NoError(t, err)
+	second, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+	assert.NoError(t, err)
+
+	// Assertions - the retry is processed rather than skipped
+	assert.False(t, first.Success)
+	assert.True(t, second.Success)
+	assert.False(t, second.Duplicate)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 2)
+}
+
+func TestProcessEvent_ConcurrentDeliveriesProcessedOnce(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	processor.processed = idempotency.NewMemoryStore()
+
+	// Setup expectations - the first delivery is held up at the ledger
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockOrg := &clients.Organization{OrgID: "test_org", Settings: clients.OrgSettings{PointsPerDollar: 2.0}}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_dup").
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return(&clients.TransferResponse{TransferID: "transfer_123"}, nil)
+
+	// Process event twice at once
+	done := make(chan *models.ProcessingResult)
+	go func() {
+		result, _ := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+		done <- result
+	}()
+	<-started
+	second, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+	close(release)
+	first := <-done
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, first.Success)
+	assert.False(t, first.Duplicate)
+	assert.True(t, second.Duplicate)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 1)
+}
+
+func TestProcessEvent_PartialFailureNotRetried(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	processor.processed = idempotency.NewMemoryStore()
+
+	// Setup expectations - the points are credited but the stamps fail
+	mockOrg := &clients.Organization{OrgID: "test_org", Settings: clients.OrgSettings{PointsPerDollar: 2.0, StampsPerVisit: 1}}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_dup").
+		Return(&clients.TransferResponse{TransferID: "transfer_123"}, nil)
+	mockLedgerClient.On("CreateStampsTransfer", "test_org", "test_customer", 1, "pos_transaction_txn_dup").
+		Return(nil, errors.New("ledger unavailable"))
+
+	// Process event twice
+	first, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+	assert.NoError(t, err)
+	second, err := processor.ProcessEvent(context.Background(), duplicateTestEvent("test_org"))
+	assert.NoError(t, err)
+
+	// Assertions - the redelivery does not credit the points again
+	assert.False(t, first.Success)
+	assert.Equal(t, 100, first.PointsEarned)
+	assert.True(t, second.Duplicate)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 1)
+}
+
+// Test POS refunds
+// balanceLedger is a ledger client that keeps a single customer's balance
+// and transfers. Pending points are held by reference and not part of the
+// balance. The expiry requested for each credit is kept by reference.
+type balanceLedger struct {
+	points     int
+	stamps     int
+	pending    map[string]int
+	references []string
+	transfers  []clients.Transfer
+	expiryDays map[string]int
+}
+
+func (l *balanceLedger) record(transfer clients.Transfer) {
+	transfer.ID = fmt.Sprintf("transfer_%d", len(l.transfers)+1)
+	l.transfers = append(l.transfers, transfer)
+	l.references = append(l.references, transfer.Reference)
+}
+
+func (l *balanceLedger) expireAfter(reference string, days int) {
+	if l.expiryDays == nil {
+		l.expiryDays = make(map[string]int)
+	}
+	l.expiryDays[reference] = days
+}
+
+func (l *balanceLedger) CreatePointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int) (*clients.TransferResponse, error) {
+	l.points += points
+	l.expireAfter(reference, expiryDays)
+	l.record(clients.Transfer{Amount: uint64(points), Reference: reference, UserData: saleCents})
+	return &clients.TransferResponse{TransferID: reference}, nil
+}
+
+func (l *balanceLedger) CreatePendingPointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int, settleAfter time.Duration) (*clients.TransferResponse, error) {
+	if l.pending == nil {
+		l.pending = make(map[string]int)
+	}
+	l.pending[reference] += points
+	l.expireAfter(reference, expiryDays)
+	l.record(clients.Transfer{Amount: uint64(points), Reference: reference, UserData: saleCents, PendingStatus: "pending"})
+	return &clients.TransferResponse{TransferID: reference, Status: "pending"}, nil
+}
+
+func (l *balanceLedger) SettlePendingTransfer(ctx context.Context, orgID, customerID, reference string, void bool, points int) (*clients.TransferResponse, error) {
+	held, ok := l.pending[reference]
+	if !ok {
+		return nil, clients.ErrNoPendingTransfer
+	}
+	amount := held
+	switch {
+	case !void:
+		l.points += held
+		delete(l.pending, reference)
+	case points > 0 && points < held:
+		l.pending[reference] = held - points
+		amount = points
+	default:
+		delete(l.pending, reference)
+	}
+
+	for i := range l.transfers {
+		if l.transfers[i].Reference != reference || l.transfers[i].Stamps || l.transfers[i].PendingID != "" {
+			continue
+		}
+		if void {
+			l.transfers[i].Voided += uint64(amount)
+			if _, ok := l.pending[reference]; !ok {
+				l.transfers[i].PendingStatus = "voided"
+			}
+		} else {
+			l.transfers[i].PendingStatus = "posted"
+			l.record(clients.Transfer{Amount: uint64(held), Reference: reference, PendingID: l.transfers[i].ID})
+		}
+		break
+	}
+	return &clients.TransferResponse{TransferID: reference, Amount: uint64(amount), HeldAt: time.Now().Unix()}, nil
+}
+
+func (l *balanceLedger) CreateStampsTransfer(ctx context.Context, orgID, customerID string, stamps int, reference string) (*clients.TransferResponse, error) {
+	l.stamps += stamps
+	l.record(clients.Transfer{Amount: uint64(stamps), Reference: reference, Stamps: true})
+	return &clients.TransferResponse{TransferID: reference}, nil
+}
+
+func (l *balanceLedger) CreateRedemption(ctx context.Context, orgID, customerID, rewardID string, points, stamps, bonusPoints, bonusExpiryDays int, reference string) (*clients.RedemptionResponse, error) {
+	if points > l.points || stamps > l.stamps {
+		return nil, clients.ErrInsufficientBalance
+	}
+	if bonusPoints > 0 {
+		l.expireAfter(reference, bonusExpiryDays)
+	}
+	l.points += bonusPoints - points
+	l.stamps -= stamps
+	if points > 0 {
+		l.record(clients.Transfer{Amount: uint64(points), Reference: reference})
+	}
+	if stamps > 0 {
+		l.record(clients.Transfer{Amount: uint64(stamps), Reference: reference, Stamps: true})
+	}
+	return &clients.RedemptionResponse{TransferIDs: []string{reference}}, nil
+}
+
+func (l *balanceLedger) GetBalance(ctx context.Context, orgID, customerID string) (*clients.Balance, error) {
+	return &clients.Balance{PointsBalance: uint64(l.points), StampsBalance: uint64(l.stamps)}, nil
+}
+
+func (l *balanceLedger) GetTransfers(ctx context.Context, orgID, customerID string, references ...string) ([]clients.Transfer, error) {
+	var transfers []clients.Transfer
+	for _, transfer := range l.transfers {
+		for _, reference := range references {
+			if transfer.Reference == reference {
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+	return transfers, nil
+}
+
+func setupRefundTest() (*EventProcessor, *balanceLedger) {
+	processor, _, mockMembershipClient := setupTestProcessor()
+	ledger := &balanceLedger{}
+	processor.ledgerClient = ledger
+
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 2.0, StampsPerVisit: 1},
+	}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+
+	return processor, ledger
+}
+
+func posRefundMessage(transactionID string, amount float64) kafka.Message {
+	event := models.BaseEvent{
+		EventID:    "evt_refund_" + transactionID,
+		EventType:  models.EventTypePOSTransaction,
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now(),
+		Payload: map[string]interface{}{
+			"transaction_id": transactionID,
+			"amount":         amount,
+			"refund":         true,
+		},
+	}
+
+	eventData, _ := json.Marshal(event)
+	return kafka.Message{Value: eventData}
+}
+
+func TestProcessEvent_POSRefund_DeductsAwardedPoints(t *testing.T) {
+	processor, ledger := setupRefundTest()
+
+	// Process events - two purchases, then the first is returned
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	_, err = processor.ProcessEvent(context.Background(), posTransactionMessage("txn_2", 30.0))
+	assert.NoError(t, err)
+	assert.Equal(t, 160, ledger.points)
+
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, -100, result.PointsEarned)
+	assert.Equal(t, -1, result.StampsEarned)
+	assert.Equal(t, []string{"refunded 100 points", "refunded 1 stamps"}, result.Actions)
+	assert.Equal(t, 60, ledger.points)
+	assert.Equal(t, 1, ledger.stamps)
+	assert.Equal(t, "pos_refund_txn_1", ledger.references[len(ledger.references)-1])
+}
+
+func TestProcessEvent_POSRefund_PartialRefund(t *testing.T) {
+	processor, ledger := setupRefundTest()
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+
+	// Process event - 20 of the 50 are returned
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 20.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, -40, result.PointsEarned)
+	assert.Equal(t, 60, ledger.points)
+}
+
+func TestProcessEvent_POSRefund_ReversesWhatTheSaleEarned(t *testing.T) {
+	processor, ledger := setupRefundTest()
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+
+	// The org triples its rate after the sale
+	org, _ := processor.membershipClient.GetOrganization(context.Background(), "test_org")
+	org.Settings.PointsPerDollar = 6.0
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 20.0))
+
+	// Assertions - 20 of 50 takes back 40% of the 100 points earned
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, -40, result.PointsEarned)
+	assert.Equal(t, 60, ledger.points)
+}
+
+func TestProcessEvent_POSRefund_UnknownTransaction(t *testing.T) {
+	processor, ledger := setupRefundTest()
+	ledger.points = 100
+
+	// Process event - the sale was never accrued
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "nothing was awarded for transaction txn_1 to refund", result.Error)
+	assert.Equal(t, 100, ledger.points)
+}
+
+func TestProcessEvent_POSRefund_RepeatedRefundsStopAtTheSale(t *testing.T) {
+	processor, ledger := setupRefundTest()
+	ledger.points = 500
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+
+	// Process events - the whole sale is refunded twice
+	first, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	var refund models.BaseEvent
+	assert.NoError(t, json.Unmarshal(posRefundMessage("txn_1", 50.0).Value, &refund))
+	refund.EventID = "evt_refund_txn_1_again"
+	eventData, _ := json.Marshal(refund)
+	second, err := processor.ProcessEvent(context.Background(), kafka.Message{Value: eventData})
+
+	// Assertions - the second refund finds nothing left to take back
+	assert.NoError(t, err)
+	assert.Equal(t, -100, first.PointsEarned)
+	assert.True(t, second.Success)
+	assert.Equal(t, 0, second.PointsEarned)
+	assert.Equal(t, 0, second.StampsEarned)
+	assert.Equal(t, 500, ledger.points)
+}
+
+func TestProcessEvent_POSRefund_InsufficientBalance(t *testing.T) {
+	processor, ledger := setupRefundTest()
+
+	// Process events - a purchase, most of which is spent before the refund
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	ledger.points = 30
+
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+
+	// Assertions - nothing is deducted when the balance cannot cover it
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "insufficient balance to refund transaction txn_1")
+	assert.Equal(t, 30, ledger.points)
+	assert.Equal(t, 1, ledger.stamps)
+}
+
+// Test pending accrual
+func setupPendingAccrualTest(delayHours int) (*EventProcessor, *balanceLedger, *MockMessageWriter) {
+	processor, _, mockMembershipClient := setupTestProcessor()
+	ledger := &balanceLedger{}
+	processor.ledgerClient = ledger
+	mockWriter := &MockMessageWriter{}
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	processor.rewardWriter = mockWriter
+
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:      2.0,
+			StampsPerVisit:       1,
+			PendingAccrual:       true,
+			SettlementDelayHours: delayHours,
+			RewardThresholds:     []clients.RewardThreshold{{Points: 50, RewardType: "discount"}},
+		},
+	}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+
+	return processor, ledger, mockWriter
+}
+
+func posSettlementMessage(transactionID string, voided bool) kafka.Message {
+	event := models.BaseEvent{
+		EventID:    "evt_settle_" + transactionID,
+		EventType:  models.EventTypePOSSettlement,
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now(),
+		Payload: map[string]interface{}{
+			"transaction_id": transactionID,
+			"voided":         voided,
+		},
+	}
+
+	eventData, _ := json.Marshal(event)
+	return kafka.Message{Value: eventData}
+}
+
+func TestProcessEvent_PendingAccrualNotSpendableUntilSettled(t *testing.T) {
+	processor, ledger, mockWriter := setupPendingAccrualTest(0)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions - the points are held and earn no reward yet; stamps are
+	// awarded straight away
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 100, result.PointsEarned)
+	assert.True(t, result.PointsPending)
+	assert.Contains(t, result.Actions, "held 100 points pending settlement")
+	assert.Empty(t, result.RewardsTriggered)
+	assert.Equal(t, 0, ledger.points)
+	assert.Equal(t, 1, ledger.stamps)
+	assert.Equal(t, 100, ledger.pending["pos_transaction_txn_1"])
+	mockWriter.AssertNotCalled(t, "WriteMessages", mock.Anything, mock.Anything)
+
+	result, err = processor.ProcessEvent(context.Background(), posSettlementMessage("txn_1", false))
+
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, []string{"posted pending points for transaction txn_1"}, result.Actions)
+	assert.Equal(t, 100, ledger.points)
+	assert.Empty(t, ledger.pending)
+}
+
+func TestProcessEvent_PendingAccrualVoidedOnReversal(t *testing.T) {
+	processor, ledger, _ := setupPendingAccrualTest(0)
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+
+	// Process event - the card payment is voided before it settles
+	result, err := processor.ProcessEvent(context.Background(), posSettlementMessage("txn_1", true))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, []string{"voided pending points for transaction txn_1"}, result.Actions)
+	assert.Equal(t, 0, ledger.points)
+	assert.Empty(t, ledger.pending)
+
+	// Nothing is left to settle
+	result, err = processor.ProcessEvent(context.Background(), posSettlementMessage("txn_1", false))
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "no pending points to settle for transaction txn_1")
+}
+
+func TestProcessEvent_PendingAccrualRefundVoidsHeldPoints(t *testing.T) {
+	processor, ledger, _ := setupPendingAccrualTest(0)
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+
+	// Process event - 20 of the 50 are returned before settlement
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 20.0))
+
+	// Assertions - the refunded points come off the hold and the stamp is
+	// deducted from the balance
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, -40, result.PointsEarned)
+	assert.Equal(t, []string{"voided 40 pending points", "refunded 1 stamps"}, result.Actions)
+	assert.Equal(t, 60, ledger.pending["pos_transaction_txn_1"])
+	assert.Equal(t, 0, ledger.points)
+	assert.Equal(t, 0, ledger.stamps)
+}
+
+func TestProcessEvent_PendingAccrualRefundBeyondHeldPoints(t *testing.T) {
+	processor, ledger, _ := setupPendingAccrualTest(0)
+	ledger.points = 100
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	// Only 30 of the 100 are still held by the time the refund voids them
+	ledger.pending["pos_transaction_txn_1"] = 30
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+
+	// Assertions - what the void did not release is redeemed
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, -100, result.PointsEarned)
+	assert.Equal(t, []string{"voided 30 pending points", "refunded 70 points", "refunded 1 stamps"}, result.Actions)
+	assert.Empty(t, ledger.pending)
+	assert.Equal(t, 30, ledger.points)
+}
+
+func TestProcessEvent_PendingAccrualRefundCappedAtWhatIsLeft(t *testing.T) {
+	processor, ledger, _ := setupPendingAccrualTest(0)
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	_, err = processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 20.0))
+	assert.NoError(t, err)
+
+	// Process event - a second refund of the whole sale
+	var refund models.BaseEvent
+	assert.NoError(t, json.Unmarshal(posRefundMessage("txn_1", 50.0).Value, &refund))
+	refund.EventID = "evt_refund_txn_1_again"
+	eventData, _ := json.Marshal(refund)
+	result, err := processor.ProcessEvent(context.Background(), kafka.Message{Value: eventData})
+
+	// Assertions - only the 60 points and no stamps are left to take back
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, -60, result.PointsEarned)
+	assert.Equal(t, 0, result.StampsEarned)
+	assert.Equal(t, []string{"voided 60 pending points"}, result.Actions)
+	assert.Empty(t, ledger.pending)
+}
+
+func TestProcessEvent_PendingAccrualRefundAfterSettlement(t *testing.T) {
+	processor, ledger, _ := setupPendingAccrualTest(0)
+
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	_, err = processor.ProcessEvent(context.Background(), posSettlementMessage("txn_1", false))
+	assert.NoError(t, err)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+
+	// Assertions - settled points are deducted from the balance
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, []string{"refunded 100 points", "refunded 1 stamps"}, result.Actions)
+	assert.Equal(t, 0, ledger.points)
+}
+
+func TestProcessEvent_PendingAccrualSettlementDelay(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 1.0, PendingAccrual: true, SettlementDelayHours: 72},
+	}
+
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePendingPointsTransfer", "test_org", "test_customer", 50, "pos_transaction_txn_1", 72*time.Hour).
+		Return(&clients.TransferResponse{Status: "pending"}, nil)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	mockLedgerClient.AssertExpectations(t)
+	mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Test stamps per transaction
+func setupStampCapTest(cap int) (*EventProcessor, *balanceLedger) {
+	processor, _, mockMembershipClient := setupTestProcessor()
+	ledger := &balanceLedger{}
+	processor.ledgerClient = ledger
+
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 1.0, StampsPerVisit: 5, MaxStampsPerTransaction: cap},
+	}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+
+	return processor, ledger
+}
+
+func TestProcessEvent_POSTransaction_StampsUnderCap(t *testing.T) {
+	processor, ledger := setupStampCapTest(8)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 5, result.StampsEarned)
+	assert.Equal(t, 5, ledger.stamps)
+}
+
+func TestProcessEvent_POSTransaction_StampsOverCap(t *testing.T) {
+	processor, ledger := setupStampCapTest(3)
+
+	// Process events - a purchase, then its refund
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 3, result.StampsEarned)
+	assert.Contains(t, result.Actions, "awarded 3 stamps")
+	assert.Equal(t, 3, ledger.stamps)
+
+	refund, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+
+	// Assertions - the refund takes back the capped award
+	assert.NoError(t, err)
+	assert.True(t, refund.Success)
+	assert.Equal(t, -3, refund.StampsEarned)
+	assert.Equal(t, 0, ledger.stamps)
+}
+
+// Test LoyaltyAction processing
+func TestProcessEvent_LoyaltyAction_ManualPoints_Success(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Test data
+	action := models.LoyaltyAction{
+		ActionType: "manual_points",
+		Points:     50,
+		Reference:  "manual_award",
+	}
+	
+	event := models.BaseEvent{
+		EventID:     "evt_123",
+		EventType:   models.EventTypeLoyaltyAction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	message := kafka.Message{
+		Value: eventData,
+	}
+	
+	// Mock responses
+	mockTransferResponse := &clients.TransferResponse{
+		TransferID: "transfer_123",
+		Status:     "success",
+	}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetOrganization", "test_org").Return(&clients.Organization{OrgID: "test_org"}, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 50, "manual_award").Return(mockTransferResponse, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), message)
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, "evt_123", result.EventID)
+	assert.True(t, result.Success)
+	assert.Equal(t, 50, result.PointsEarned)
+	assert.Len(t, result.Actions, 1)
+	assert.Contains(t, result.Actions[0], "manual award: 50 points")
+	
+	mockLedgerClient.AssertExpectations(t)
+	mockMembershipClient.AssertExpectations(t)
+}
+
+// Test points expiry
+func TestProcessEvent_PointsExpireByOrgSettings(t *testing.T) {
+	processor, _, mockMembershipClient := setupTestProcessor()
+	ledger := &balanceLedger{}
+	processor.ledgerClient = ledger
+
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 1.0, PointsExpiryDays: 90},
+	}
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+
+	award := models.BaseEvent{
+		EventID:    "evt_award",
+		EventType:  models.EventTypeLoyaltyAction,
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now(),
+		Payload:    map[string]interface{}{"action_type": "manual_points", "points": 25, "reference": "manual_award"},
+	}
+	awardData, _ := json.Marshal(award)
+
+	// Process events - a purchase, then a manual award
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	result, err = processor.ProcessEvent(context.Background(), kafka.Message{Value: awardData})
+
+	// Assertions - both credits carry the org's expiry
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Len(t, ledger.expiryDays, 2)
+	for reference, days := range ledger.expiryDays {
+		assert.Equal(t, 90, days, reference)
+	}
+}
+
+func TestProcessEvent_LoyaltyAction_BonusStamps_Success(t *testing.T) {
+	processor, mockLedgerClient, _ := setupTestProcessor()
+	
+	// Test data
+	action := models.LoyaltyAction{
+		ActionType: "bonus_stamps",
+		Stamps:     5,
+		Reference:  "bonus_award",
+	}
+	
+	event := models.BaseEvent{
+		EventID:     "evt_123",
+		EventType:   models.EventTypeLoyaltyAction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	message := kafka.Message{
+		Value: eventData,
+	}
+	
+	// Mock responses
+	mockTransferResponse := &clients.TransferResponse{
+		TransferID: "transfer_123",
+		Status:     "success",
+	}
+	
+	// Setup expectations
+	mockLedgerClient.On("CreateStampsTransfer", "test_org", "test_customer", 5, "bonus_award").Return(mockTransferResponse, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), message)
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, "evt_123", result.EventID)
+	assert.True(t, result.Success)
+	assert.Equal(t, 5, result.StampsEarned)
+	assert.Len(t, result.Actions, 1)
+	assert.Contains(t, result.Actions[0], "bonus stamps: 5")
+	
+	mockLedgerClient.AssertExpectations(t)
+}
+
+func redeemRewardMessage(rewardID string) kafka.Message {
+	event := models.BaseEvent{
+		EventID:     "evt_redeem",
+		EventType:   models.EventTypeLoyaltyAction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{"action_type": "redeem_reward", "reward_id": rewardID, "reference": "redeem_ref"},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	return kafka.Message{Value: eventData}
+}
+
+// rewardCatalogOrg has a named reward and one identified by its default ID
+func rewardCatalogOrg() *clients.Organization {
+	return &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			RewardThresholds: []clients.RewardThreshold{
+				{RewardID: "free_coffee", Points: 300, RewardType: "free_item", RewardValue: "coffee"},
+				{Points: 0, Stamps: 10, RewardType: "free_item", RewardValue: "muffin"},
+			},
+			RedemptionBonuses: []clients.RedemptionBonus{
+				{RewardID: "free_coffee", BonusPoints: 25},
+			},
+		},
+	}
+}
+
+func TestProcessEvent_LoyaltyAction_RedeemReward(t *testing.T) {
+	tests := []struct {
+		name     string
+		rewardID string
+		points   int
+		stamps   int
+		bonus    int
+		actions  int
+	}{
+		{"configured reward credits bonus", "free_coffee", 300, 0, 25, 2},
+		{"default reward ID has no bonus", "reward_0_10", 0, 10, 0, 1},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+			
+			// Setup expectations - catalog cost and bonus go to the ledger in one call
+			mockMembershipClient.On("GetOrganization", "test_org").Return(rewardCatalogOrg(), nil)
+			mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 500, StampsBalance: 10}, nil)
+			mockLedgerClient.On("CreateRedemption", "test_org", "test_customer", tt.rewardID, tt.points, tt.stamps, tt.bonus, "redeem_ref").
+				Return(&clients.RedemptionResponse{Status: "success"}, nil).Once()
+			
+			// Process event
+			result, err := processor.ProcessEvent(context.Background(), redeemRewardMessage(tt.rewardID))
+			
+			// Assertions
+			assert.NoError(t, err)
+			assert.True(t, result.Success)
+			assert.Equal(t, tt.bonus, result.PointsEarned)
+			assert.Len(t, result.Actions, tt.actions)
+			assert.Equal(t, "redeemed reward "+tt.rewardID, result.Actions[0])
+			
+			mockLedgerClient.AssertExpectations(t)
+			mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mockMembershipClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessEvent_LoyaltyAction_RedeemRewardInsufficientBalance(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Setup expectations
+	mockMembershipClient.On("GetOrganization", "test_org").Return(rewardCatalogOrg(), nil)
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 299}, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), redeemRewardMessage("free_coffee"))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "insufficient balance to redeem reward free_coffee: costs 300 points and 0 stamps, balance is 299 points and 0 stamps", result.Error)
+	assert.Equal(t, 0, result.PointsEarned)
+	
+	mockLedgerClient.AssertExpectations(t)
+	mockLedgerClient.AssertNotCalled(t, "CreateRedemption", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessEvent_LoyaltyAction_RedeemRewardNotInCatalog(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Setup expectations
+	mockMembershipClient.On("GetOrganization", "test_org").Return(rewardCatalogOrg(), nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), redeemRewardMessage("free_muffin"))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "reward free_muffin is not in the reward catalog", result.Error)
+	
+	mockLedgerClient.AssertNotCalled(t, "GetBalance", mock.Anything, mock.Anything)
+	mockLedgerClient.AssertNotCalled(t, "CreateRedemption", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessEvent_LoyaltyAction_RedeemRewardLedgerError(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Setup expectations - the ledger's own balance check can still reject
+	mockMembershipClient.On("GetOrganization", "test_org").Return(rewardCatalogOrg(), nil)
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 300}, nil)
+	mockLedgerClient.On("CreateRedemption", "test_org", "test_customer", "free_coffee", 300, 0, 25, "redeem_ref").
+		Return(nil, errors.New("insufficient balance"))
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), redeemRewardMessage("free_coffee"))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "failed to redeem reward: insufficient balance", result.Error)
+	assert.Equal(t, 0, result.PointsEarned)
+	
+	mockLedgerClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_LoyaltyAction_RedeemRewardMissingID(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), redeemRewardMessage(""))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "reward_id is required to redeem a reward", result.Error)
+	
+	mockLedgerClient.AssertNotCalled(t, "CreateRedemption", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockMembershipClient.AssertNotCalled(t, "GetOrganization", mock.Anything)
+}
+
+func redeemBenefitMessage(benefit string) kafka.Message {
+	event := models.BaseEvent{
+		EventID:     "evt_benefit",
+		EventType:   models.EventTypeLoyaltyAction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{"action_type": "redeem_benefit", "benefit": benefit, "reference": "benefit_ref"},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	return kafka.Message{Value: eventData}
+}
+
+func TestProcessEvent_LoyaltyAction_RedeemBenefit(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	mockAnalyticsClient := &MockAnalyticsClient{}
+	processor.analyticsClient = mockAnalyticsClient
+	
+	// Setup expectations - the first use this month goes through, the second is refused
+	mockAnalyticsClient.On("RedeemBenefit", "test_org", "test_customer", "Monthly offers", "benefit_ref").
+		Return(&clients.BenefitUsage{Benefit: "Monthly offers", Tier: "Silver", Period: "2026-10", Use: 1}, nil).Once()
+	mockAnalyticsClient.On("RedeemBenefit", "test_org", "test_customer", "Monthly offers", "benefit_ref").
+		Return(nil, clients.ErrBenefitLimitReached).Once()
+	
+	// Process events
+	first, err := processor.ProcessEvent(context.Background(), redeemBenefitMessage("Monthly offers"))
+	assert.NoError(t, err)
+	second, err := processor.ProcessEvent(context.Background(), redeemBenefitMessage("Monthly offers"))
+	assert.NoError(t, err)
+	
+	// Assertions
+	assert.True(t, first.Success)
+	assert.Equal(t, []string{"redeemed Silver benefit Monthly offers"}, first.Actions)
+	assert.False(t, second.Success)
+	assert.Equal(t, "failed to redeem benefit Monthly offers: benefit usage limit reached for this period", second.Error)
+	
+	mockAnalyticsClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_LoyaltyAction_RedeemBenefitMissingBenefit(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	mockAnalyticsClient := &MockAnalyticsClient{}
+	processor.analyticsClient = mockAnalyticsClient
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), redeemBenefitMessage(""))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "benefit is required to redeem a benefit", result.Error)
+	mockAnalyticsClient.AssertNotCalled(t, "RedeemBenefit", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProcessEvent_LoyaltyAction_UnknownActionType(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	
+	// Test data
+	action := models.LoyaltyAction{
+		ActionType: "unknown_action",
+		Points:     50,
+		Reference:  "test",
+	}
+	
+	event := models.BaseEvent{
+		EventID:     "evt_123",
+		EventType:   models.EventTypeLoyaltyAction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	message := kafka.Message{
+		Value: eventData,
+	}
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), message)
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, "evt_123", result.EventID)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "unknown loyalty action type")
+}
+
+func TestProcessEvent_LoyaltyAction_ZeroPoints(t *testing.T) {
+	processor, mockLedgerClient, _ := setupTestProcessor()
+	
+	// Test data
+	action := models.LoyaltyAction{
+		ActionType: "manual_points",
+		Points:     0, // Zero points
+		Reference:  "manual_award",
+	}
+	
+	event := models.BaseEvent{
+		EventID:     "evt_123",
+		EventType:   models.EventTypeLoyaltyAction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{"action_type": action.ActionType, "points": action.Points, "stamps": action.Stamps, "reference": action.Reference},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	message := kafka.Message{
+		Value: eventData,
+	}
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), message)
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, "evt_123", result.EventID)
+	assert.True(t, result.Success)
+	assert.Equal(t, 0, result.PointsEarned)
+	assert.Len(t, result.Actions, 0)
+	
+	// Should not call ledger client for zero points
+	mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer")
+}
+
+func TestProcessEvent_LoyaltyAction_NegativeStamps(t *testing.T) {
+	processor, mockLedgerClient, _ := setupTestProcessor()
+	
+	// Test data
+	event := models.BaseEvent{
+		EventID:     "evt_123",
+		EventType:   models.EventTypeLoyaltyAction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{"action_type": "bonus_stamps", "stamps": -3, "reference": "bonus"},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	message := kafka.Message{
+		Value: eventData,
+	}
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), message)
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 0, result.StampsEarned)
+	assert.Len(t, result.Actions, 0)
+	
+	// Should not call ledger client for negative stamps
+	mockLedgerClient.AssertNotCalled(t, "CreateStampsTransfer")
+}
+
+func TestProcessEvent_POSTransaction_SkipsZeroAwards(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Test data
+	event := models.BaseEvent{
+		EventID:     "evt_123",
+		EventType:   models.EventTypePOSTransaction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{
+			"transaction_id": "txn_123",
+			"amount":         0.5,
+		},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	message := kafka.Message{
+		Value: eventData,
+	}
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{
+		CustomerID: "test_customer",
+		OrgID:      "test_org",
+		Status:     "active",
+	}
+	
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar: 1.0, // 0.5 rounds down to zero points
+			StampsPerVisit:  -1,  // Misconfigured negative stamps
+		},
+	}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), message)
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 0, result.PointsEarned)
+	assert.Equal(t, 0, result.StampsEarned)
+	
+	mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer")
+	mockLedgerClient.AssertNotCalled(t, "CreateStampsTransfer")
+	mockMembershipClient.AssertExpectations(t)
+}
+
+func posTransactionMessage(transactionID string, amount float64) kafka.Message {
+	event := models.BaseEvent{
+		EventID:     "evt_" + transactionID,
+		EventType:   models.EventTypePOSTransaction,
+		OrgID:       "test_org",
+		CustomerID:  "test_customer",
+		Timestamp:   time.Now(),
+		Payload:     map[string]interface{}{
+			"transaction_id": transactionID,
+			"amount":         amount,
+		},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	return kafka.Message{Value: eventData}
+}
+
+func TestProcessEvent_POSTransaction_LifetimeThresholdAcrossTransactions(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar: 1.0,
+			RewardThresholds: []clients.RewardThreshold{
+				{Points: 100, RewardType: "discount", Description: "100 lifetime points"},
+			},
+		},
+	}
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+	
+	// Setup expectations - 60 points then 50 points, neither reaching 100 alone
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 60, "pos_transaction_txn_1").Return(mockTransferResponse, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 50, "pos_transaction_txn_2").Return(mockTransferResponse, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 20, "pos_transaction_txn_3").Return(mockTransferResponse, nil)
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 60}, nil).Once()
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 110}, nil).Once()
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 130}, nil).Once()
+	
+	// Process events
+	first, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 60.0))
+	assert.NoError(t, err)
+	second, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_2", 50.0))
+	assert.NoError(t, err)
+	third, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_3", 20.0))
+	assert.NoError(t, err)
+	
+	// Assertions
+	assert.True(t, first.Success)
+	assert.Empty(t, first.RewardsTriggered)
+	assert.True(t, second.Success)
+	assert.Len(t, second.RewardsTriggered, 1)
+	assert.Equal(t, "100 lifetime points", second.RewardsTriggered[0].Description)
+	assert.Empty(t, third.RewardsTriggered, "threshold already crossed should not fire again")
+	
+	mockLedgerClient.AssertExpectations(t)
+	mockMembershipClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_POSTransaction_PerEventThresholdBasis(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:      1.0,
+			RewardThresholdBasis: clients.RewardBasisPerEvent,
+			RewardThresholds: []clients.RewardThreshold{
+				{Points: 100, RewardType: "discount", Description: "100 points in one visit"},
+			},
+		},
+	}
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 60, "pos_transaction_txn_1").Return(mockTransferResponse, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 120, "pos_transaction_txn_2").Return(mockTransferResponse, nil)
+	
+	// Process events
+	first, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 60.0))
+	assert.NoError(t, err)
+	second, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_2", 120.0))
+	assert.NoError(t, err)
+	
+	// Assertions
+	assert.Empty(t, first.RewardsTriggered)
+	assert.Len(t, second.RewardsTriggered, 1)
+	mockLedgerClient.AssertNotCalled(t, "GetBalance")
+	mockLedgerClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_POSTransaction_LocalizedRewardDescription(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Mock responses - a EUR brand with a voucher threshold
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:      1.0,
+			RewardThresholdBasis: clients.RewardBasisPerEvent,
+			Currency:             "EUR",
+			Locale:               "de-DE",
+			RewardThresholds: []clients.RewardThreshold{
+				{Points: 100, RewardType: "voucher", RewardValue: "25", Amount: 25, Description: "{amount} voucher"},
+			},
+		},
+	}
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 120, "pos_transaction_txn_1").Return(mockTransferResponse, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 120.0))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.Len(t, result.RewardsTriggered, 1)
+	assert.Equal(t, "25,00 € voucher", result.RewardsTriggered[0].Description)
+	assert.Equal(t, "25", result.RewardsTriggered[0].RewardValue)
+	mockLedgerClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_POSTransaction_BalanceErrorSkipsRewards(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar: 1.0,
+			RewardThresholds: []clients.RewardThreshold{
+				{Points: 100, RewardType: "discount"},
+			},
+		},
+	}
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 150, "pos_transaction_txn_1").Return(mockTransferResponse, nil)
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(nil, assert.AnError)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 150.0))
+	
+	// Assertions - the award stands even though rewards could not be checked
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 150, result.PointsEarned)
+	assert.Empty(t, result.RewardsTriggered)
+}
+
+// happyHourLocation is open 09:00-21:00 New York time on Fridays, with double
+// points from 15:00 to 17:00
+func happyHourLocation() *clients.Location {
+	return &clients.Location{
+		LocationID: "loc_downtown",
+		OrgID:      "test_org",
+		Settings: clients.LocationSettings{
+			TimePromotions: []clients.TimePromotion{
+				{Name: "happy hour", Days: []string{"friday"}, Start: "15:00", End: "17:00", PointsMultiplier: 2.0},
+			},
+		},
+		OperatingHours: clients.OperatingHours{
+			Timezone: "America/New_York",
+			Days:     []clients.DayHours{{Day: "friday", Open: "09:00", Close: "21:00"}},
+		},
+		Active: true,
+	}
+}
+
+func locationTransactionMessage(transactionID, locationID string, amount float64, timestamp time.Time) kafka.Message {
+	event := models.BaseEvent{
+		EventID:     "evt_" + transactionID,
+		EventType:   models.EventTypePOSTransaction,
+		OrgID:       "test_org",
+		LocationID:  locationID,
+		CustomerID:  "test_customer",
+		Timestamp:   timestamp,
+		Payload:     map[string]interface{}{
+			"transaction_id": transactionID,
+			"amount":         amount,
+		},
+	}
+	
+	eventData, _ := json.Marshal(event)
+	return kafka.Message{Value: eventData}
+}
+
+func TestProcessEvent_POSTransaction_LocationHappyHour(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		points    int
+		actions   int
+	}{
+		{"inside happy hour", time.Date(2026, 10, 16, 15, 30, 0, 0, newYork), 100, 2},
+		{"outside happy hour", time.Date(2026, 10, 16, 18, 0, 0, 0, newYork), 50, 1},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+			
+			// Mock responses
+			mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+			mockOrg := &clients.Organization{
+				OrgID:    "test_org",
+				Settings: clients.OrgSettings{PointsPerDollar: 1.0},
+			}
+			
+			// Setup expectations
+			mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+			mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+			mockMembershipClient.On("GetLocation", "loc_downtown").Return(happyHourLocation(), nil)
+			mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", tt.points, "pos_transaction_txn_1").
+				Return(&clients.TransferResponse{Status: "success"}, nil)
+			
+			// Process event - the timestamp is sent in UTC and compared in store time
+			message := locationTransactionMessage("txn_1", "loc_downtown", 50.0, tt.timestamp.UTC())
+			result, err := processor.ProcessEvent(context.Background(), message)
+			
+			// Assertions
+			assert.NoError(t, err)
+			assert.True(t, result.Success)
+			assert.Equal(t, tt.points, result.PointsEarned)
+			assert.Len(t, result.Actions, tt.actions)
+			if tt.actions > 1 {
+				assert.Equal(t, "applied happy hour promotion: 2x points", result.Actions[1])
+			}
+			
+			mockLedgerClient.AssertExpectations(t)
+			mockMembershipClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessEvent_POSTransaction_LocationErrorEarnsBaseRate(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 1.0},
+	}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockMembershipClient.On("GetLocation", "loc_missing").Return(nil, assert.AnError)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 50, "pos_transaction_txn_1").
+		Return(&clients.TransferResponse{Status: "success"}, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), locationTransactionMessage("txn_1", "loc_missing", 50.0, time.Now()))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 50, result.PointsEarned)
+	
+	mockLedgerClient.AssertExpectations(t)
+	mockMembershipClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_POSTransaction_TierMultiplier(t *testing.T) {
+	tests := []struct {
+		name       string
+		tier       string
+		multiplier float64
+		points     int
+		action     string
+	}{
+		{"gold at 1.5x", "Gold", 1.5, 150, "applied Gold tier multiplier: 1.5x points"},
+		{"bronze at 1x", "Bronze", 1.0, 100, "applied Bronze tier multiplier: 1x points"},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+			mockAnalyticsClient := &MockAnalyticsClient{}
+			processor.analyticsClient = mockAnalyticsClient
+			
+			// Mock responses
+			mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+			mockOrg := &clients.Organization{
+				OrgID:    "test_org",
+				Settings: clients.OrgSettings{PointsPerDollar: 2.0},
+			}
+			mockTier := &clients.CustomerTier{OrgID: "test_org", CustomerID: "test_customer", CurrentTier: tt.tier, PointsMultiplier: tt.multiplier}
+			
+			// Setup expectations
+			mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+			mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+			mockAnalyticsClient.On("GetCustomerTier", "test_org", "test_customer").Return(mockTier, nil)
+			mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", tt.points, "pos_transaction_txn_1").
+				Return(&clients.TransferResponse{Status: "success"}, nil)
+			
+			// Process event
+			result, err := processor.ProcessEvent(context.Background(), locationTransactionMessage("txn_1", "", 50.0, time.Now()))
+			
+			// Assertions
+			assert.NoError(t, err)
+			assert.True(t, result.Success)
+			assert.Equal(t, tt.points, result.PointsEarned)
+			assert.Len(t, result.Actions, 2)
+			assert.Equal(t, tt.action, result.Actions[1])
+			
+			mockLedgerClient.AssertExpectations(t)
+			mockMembershipClient.AssertExpectations(t)
+			mockAnalyticsClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessEvent_POSTransaction_TierErrorEarnsBaseRate(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	mockAnalyticsClient := &MockAnalyticsClient{}
+	processor.analyticsClient = mockAnalyticsClient
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 2.0},
+	}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockAnalyticsClient.On("GetCustomerTier", "test_org", "test_customer").Return(nil, assert.AnError)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_1").
+		Return(&clients.TransferResponse{Status: "success"}, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), locationTransactionMessage("txn_1", "", 50.0, time.Now()))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 100, result.PointsEarned)
+	assert.Equal(t, []string{"awarded 100 points"}, result.Actions)
+	
+	mockLedgerClient.AssertExpectations(t)
+	mockAnalyticsClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_POSTransaction_UnknownLocationPolicy(t *testing.T) {
+	foreignLocation := happyHourLocation()
+	foreignLocation.OrgID = "other_org"
+	
+	tests := []struct {
+		name     string
+		policy   string
+		location *clients.Location
+		err      error
+		success  bool
+		expected string
+	}{
+		{"strict rejects missing location", clients.UnknownLocationStrict, nil, clients.ErrLocationNotFound, false, "unknown location loc_downtown for org test_org"},
+		{"strict rejects another org's location", clients.UnknownLocationStrict, foreignLocation, nil, false, "unknown location loc_downtown for org test_org"},
+		{"strict rejects when lookup fails", clients.UnknownLocationStrict, nil, assert.AnError, false, "failed to get location: " + assert.AnError.Error()},
+		{"lenient accepts missing location", clients.UnknownLocationLenient, nil, clients.ErrLocationNotFound, true, ""},
+		{"default accepts missing location", "", nil, clients.ErrLocationNotFound, true, ""},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+			
+			// Mock responses
+			mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+			mockOrg := &clients.Organization{
+				OrgID:    "test_org",
+				Settings: clients.OrgSettings{PointsPerDollar: 1.0, UnknownLocationPolicy: tt.policy},
+			}
+			
+			// Setup expectations
+			mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+			mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+			if tt.location != nil {
+				mockMembershipClient.On("GetLocation", "loc_downtown").Return(tt.location, nil)
+			} else {
+				mockMembershipClient.On("GetLocation", "loc_downtown").Return(nil, tt.err)
+			}
+			if tt.success {
+				mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 50, "pos_transaction_txn_1").
+					Return(&clients.TransferResponse{Status: "success"}, nil)
+			}
+			
+			// Process event
+			result, err := processor.ProcessEvent(context.Background(), locationTransactionMessage("txn_1", "loc_downtown", 50.0, time.Now()))
+			
+			// Assertions
+			assert.NoError(t, err)
+			assert.Equal(t, tt.success, result.Success)
+			assert.Equal(t, tt.expected, result.Error)
+			if tt.success {
+				assert.Equal(t, 50, result.PointsEarned)
+			} else {
+				mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			
+			mockLedgerClient.AssertExpectations(t)
+			mockMembershipClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessEvent_PublishesResult(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	mockWriter := &MockMessageWriter{}
+	processor.resultWriter = mockWriter
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar: 2.0,
+			StampsPerVisit:  1,
+		},
+	}
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_1").Return(mockTransferResponse, nil)
+	mockLedgerClient.On("CreateStampsTransfer", "test_org", "test_customer", 1, "pos_transaction_txn_1").Return(mockTransferResponse, nil)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+	
+	messages := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "test_org.processing.result", messages[0].Topic)
+	assert.Equal(t, "test_customer", string(messages[0].Key))
+	
+	var published models.ProcessingResult
+	err = json.Unmarshal(messages[0].Value, &published)
+	assert.NoError(t, err)
+	assert.Equal(t, "evt_txn_1", published.EventID)
+	assert.Equal(t, "test_org", published.OrgID)
+	assert.Equal(t, "test_customer", published.CustomerID)
+	assert.True(t, published.Success)
+	assert.Equal(t, 100, published.PointsEarned)
+	assert.Equal(t, 1, published.StampsEarned)
+}
+
+func TestProcessEvent_PublishFailureDoesNotFailEvent(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	mockWriter := &MockMessageWriter{}
+	processor.resultWriter = mockWriter
+	
+	// Test data
+	event := models.BaseEvent{
+		EventID:   "evt_123",
+		EventType: "unknown_event_type",
+		OrgID:     "test_org",
+	}
+	eventData, _ := json.Marshal(event)
+	
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(assert.AnError)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), kafka.Message{Value: eventData})
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, "evt_123", result.EventID)
+	mockWriter.AssertExpectations(t)
+}
+
+func TestProcessEvent_PublishesRewardEvents(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	mockWriter := &MockMessageWriter{}
+	processor.rewardWriter = mockWriter
+	
+	// Mock responses - a single event crosses both thresholds
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:      1.0,
+			RewardThresholdBasis: clients.RewardBasisPerEvent,
+			RewardThresholds: []clients.RewardThreshold{
+				{RewardID: "free_coffee", Points: 50, RewardType: "free_item", RewardValue: "coffee", Description: "Free coffee"},
+				{Points: 100, RewardType: "discount", RewardValue: "10", Description: "10% off"},
+			},
+		},
+	}
+	mockTransferResponse := &clients.TransferResponse{TransferID: "transfer_123", Status: "success"}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 120, "pos_transaction_txn_1").Return(mockTransferResponse, nil)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 120.0))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Len(t, result.RewardsTriggered, 2)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+	
+	messages := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+	if !assert.Len(t, messages, 2) {
+		return
+	}
+	
+	var events []models.BaseEvent
+	for _, message := range messages {
+		assert.Equal(t, "test_org.reward.triggered", message.Topic)
+		assert.Equal(t, "test_customer", string(message.Key))
+		
+		var event models.BaseEvent
+		assert.NoError(t, json.Unmarshal(message.Value, &event))
+		events = append(events, event)
+	}
+	
+	assert.Equal(t, "evt_txn_1_reward_free_coffee", events[0].EventID)
+	assert.Equal(t, models.EventTypeRewardTriggered, events[0].EventType)
+	assert.Equal(t, "test_org", events[0].OrgID)
+	assert.Equal(t, "test_customer", events[0].CustomerID)
+	assert.Equal(t, "free_coffee", events[0].Payload["reward_id"])
+	assert.Equal(t, "free_item", events[0].Payload["reward_type"])
+	assert.Equal(t, "coffee", events[0].Payload["reward_value"])
+	assert.Equal(t, "Free coffee", events[0].Payload["description"])
+	assert.Equal(t, "evt_txn_1", events[0].Payload["source_event_id"])
+	assert.Equal(t, "reward_100_0", events[1].Payload["reward_id"])
+}
+
+func TestProcessEvent_NoRewardsPublishesNoRewardEvents(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	mockWriter := &MockMessageWriter{}
+	processor.rewardWriter = mockWriter
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 1.0},
+	}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 50, "pos_transaction_txn_1").
+		Return(&clients.TransferResponse{Status: "success"}, nil)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	mockWriter.AssertNotCalled(t, "WriteMessages", mock.Anything, mock.Anything)
+}
+
+func TestProcessEvent_RewardPublishFailureDoesNotFailEvent(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	mockWriter := &MockMessageWriter{}
+	processor.rewardWriter = mockWriter
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:      1.0,
+			RewardThresholdBasis: clients.RewardBasisPerEvent,
+			RewardThresholds:     []clients.RewardThreshold{{Points: 50, RewardType: "discount"}},
+		},
+	}
+	
+	// Setup expectations
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 50, "pos_transaction_txn_1").
+		Return(&clients.TransferResponse{Status: "success"}, nil)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(assert.AnError)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Len(t, result.RewardsTriggered, 1)
+	mockWriter.AssertExpectations(t)
+}
+
+// Test customer metrics events
+func customerMetricsEvent(t *testing.T, message kafka.Message) models.BaseEvent {
+	assert.Equal(t, "test_org.customer.metrics.updated", message.Topic)
+	assert.Equal(t, "test_customer", string(message.Key))
+
+	var event models.BaseEvent
+	assert.NoError(t, json.Unmarshal(message.Value, &event))
+	return event
+}
+
+func TestProcessEvent_PublishesCustomerMetrics(t *testing.T) {
+	processor, _ := setupRefundTest()
+	mockWriter := &MockMessageWriter{}
+	processor.metricsWriter = mockWriter
+
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+
+	messages := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+	event := customerMetricsEvent(t, messages[0])
+	assert.Equal(t, "evt_txn_1_metrics", event.EventID)
+	assert.Equal(t, models.EventTypeCustomerMetricsUpdated, event.EventType)
+	assert.Equal(t, "test_customer", event.CustomerID)
+	assert.False(t, event.Timestamp.IsZero())
+	assert.Equal(t, "txn_1", event.Payload["transaction_id"])
+	assert.Equal(t, 50.0, event.Payload["amount"])
+	assert.Equal(t, false, event.Payload["refund"])
+	assert.Equal(t, 100.0, event.Payload["points_earned"])
+	assert.Equal(t, 1.0, event.Payload["stamps_earned"])
+	assert.Equal(t, "evt_txn_1", event.Payload["source_event_id"])
+	assert.Equal(t, models.AccrualApplied, event.Payload["accrual_status"])
+	assert.NotContains(t, event.Payload, "accrual_error")
+}
+
+func TestProcessEvent_PublishesCustomerMetricsForRefund(t *testing.T) {
+	processor, _ := setupRefundTest()
+	mockWriter := &MockMessageWriter{}
+	processor.metricsWriter = mockWriter
+
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process events - a purchase, then part of it is returned
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 20.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 2)
+
+	messages := mockWriter.Calls[1].Arguments.Get(1).([]kafka.Message)
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+	event := customerMetricsEvent(t, messages[0])
+	assert.Equal(t, "evt_refund_txn_1_metrics", event.EventID)
+	assert.Equal(t, 20.0, event.Payload["amount"])
+	assert.Equal(t, true, event.Payload["refund"])
+	assert.Equal(t, -40.0, event.Payload["points_earned"])
+	assert.Equal(t, -1.0, event.Payload["stamps_earned"])
+}
+
+func TestProcessEvent_FailedTransactionPublishesCustomerMetrics(t *testing.T) {
+	processor, ledger := setupRefundTest()
+	_, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+	assert.NoError(t, err)
+	ledger.points = 30
+	mockWriter := &MockMessageWriter{}
+	processor.metricsWriter = mockWriter
+
+	// Setup expectations
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process event - the balance cannot cover the refund
+	result, err := processor.ProcessEvent(context.Background(), posRefundMessage("txn_1", 50.0))
+
+	// Assertions - analytics still sees the refunded spend
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+
+	messages := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+	event := customerMetricsEvent(t, messages[0])
+	assert.Equal(t, 50.0, event.Payload["amount"])
+	assert.Equal(t, true, event.Payload["refund"])
+	assert.Equal(t, 0.0, event.Payload["points_earned"])
+	assert.Equal(t, models.AccrualFailed, event.Payload["accrual_status"])
+	assert.Equal(t, result.Error, event.Payload["accrual_error"])
+}
+
+func TestProcessEvent_SkippedCustomerPublishesCustomerMetrics(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	mockWriter := &MockMessageWriter{}
+	processor.metricsWriter = mockWriter
+
+	// Setup expectations - an inactive customer earns nothing
+	mockMembershipClient.On("GetCustomer", "test_customer").
+		Return(&clients.Customer{CustomerID: "test_customer", Status: "suspended"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").
+		Return(&clients.Organization{OrgID: "test_org", Settings: clients.OrgSettings{PointsPerDollar: 2.0}}, nil)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	mockLedgerClient.AssertNotCalled(t, "CreatePointsTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 1)
+
+	messages := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+	if !assert.Len(t, messages, 1) {
+		return
+	}
+	event := customerMetricsEvent(t, messages[0])
+	assert.Equal(t, 50.0, event.Payload["amount"])
+	assert.Equal(t, models.AccrualFailed, event.Payload["accrual_status"])
+}
+
+func TestProcessEvent_CustomerMetricsPublishFailureIsReturned(t *testing.T) {
+	processor, _ := setupRefundTest()
+	mockWriter := &MockMessageWriter{}
+	processor.metricsWriter = mockWriter
+	processor.metricsRetry = RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond}
+
+	// Setup expectations - every publish fails
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(assert.AnError)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions - the publish was retried, the points stay awarded
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.True(t, result.Success)
+	assert.Equal(t, 100, result.PointsEarned)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 2)
+}
+
+func TestProcessEvent_RetriesCustomerMetricsPublish(t *testing.T) {
+	processor, _ := setupRefundTest()
+	mockWriter := &MockMessageWriter{}
+	processor.metricsWriter = mockWriter
+	processor.metricsRetry = RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	// Setup expectations - the first publish fails
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(assert.AnError).Once()
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 2)
+}
+
+func TestProcessEvent_CancelledContextAbortsSlowClientCall(t *testing.T) {
+	// A membership service that only answers once the caller gives up
+	release := make(chan struct{})
+	membership := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(membership.Close)
+	t.Cleanup(func() { close(release) })
+
+	processor := NewEventProcessor("http://ledger.invalid", membership.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	// Process event
+	start := time.Now()
+	result, err := processor.ProcessEvent(ctx, posTransactionMessage("txn_cancel", 10.0))
+
+	// Assertions - cancellation is returned as an error, not a failed event
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, result)
+	assert.Less(t, time.Since(start), 2*time.Second)
 }
 
 func TestProcessEvent_UnknownEventType(t *testing.T) {
@@ -601,6 +2637,89 @@ func TestCalculatePoints(t *testing.T) {
 	}
 }
 
+// Test calculateTransactionPoints
+func TestCalculateTransactionPoints_RoundingGranularity(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	
+	// 5.25 + 2.5 + 3.75 points: 11.5 for the basket, 5 + 2 + 3 per item
+	transaction := models.POSTransaction{
+		Amount: 8.50,
+		Items: []models.LineItem{
+			{SKU: "COFFEE001", TotalPrice: 3.50, Category: "beverages"},
+			{SKU: "MUFFIN001", TotalPrice: 2.50, Category: "pastries"},
+			{SKU: "TEA001", TotalPrice: 2.50, Category: "beverages"},
+		},
+	}
+	multipliers := map[string]float64{"beverages": 1.5}
+	
+	tests := []struct {
+		name     string
+		settings clients.OrgSettings
+		expected int
+	}{
+		{"transaction rounding", clients.OrgSettings{CategoryMultipliers: multipliers, RoundingGranularity: clients.RoundingTransaction}, 11},
+		{"default rounds per transaction", clients.OrgSettings{CategoryMultipliers: multipliers}, 11},
+		{"item rounding", clients.OrgSettings{CategoryMultipliers: multipliers, RoundingGranularity: clients.RoundingItem}, 10},
+		{"no multipliers uses the total", clients.OrgSettings{RoundingGranularity: clients.RoundingItem}, 8},
+		{"zero multiplier excludes a category", clients.OrgSettings{CategoryMultipliers: map[string]float64{"pastries": 0}}, 6},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := processor.calculateTransactionPoints(transaction, 1.0, tt.settings)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestProcessEvent_POSTransaction_ItemRounding(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	
+	// Mock responses
+	mockCustomer := &clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}
+	mockOrg := &clients.Organization{
+		OrgID: "test_org",
+		Settings: clients.OrgSettings{
+			PointsPerDollar:     1.0,
+			CategoryMultipliers: map[string]float64{"beverages": 1.5},
+			RoundingGranularity: clients.RoundingItem,
+		},
+	}
+	
+	// Setup expectations - 5.25 + 3.75 rounds to 5 + 3 rather than 9
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(mockCustomer, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(mockOrg, nil)
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 8, "pos_transaction_txn_items").
+		Return(&clients.TransferResponse{Status: "success"}, nil)
+	
+	event := models.BaseEvent{
+		EventID:    "evt_items",
+		EventType:  models.EventTypePOSTransaction,
+		OrgID:      "test_org",
+		CustomerID: "test_customer",
+		Timestamp:  time.Now(),
+		Payload: map[string]interface{}{
+			"transaction_id": "txn_items",
+			"amount":         6.0,
+			"items": []map[string]interface{}{
+				{"sku": "COFFEE001", "total_price": 3.50, "category": "beverages"},
+				{"sku": "TEA001", "total_price": 2.50, "category": "beverages"},
+			},
+		},
+	}
+	eventData, _ := json.Marshal(event)
+	
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), kafka.Message{Value: eventData})
+	
+	// Assertions
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 8, result.PointsEarned)
+	
+	mockLedgerClient.AssertExpectations(t)
+}
+
 // Test checkRewardThresholds
 func TestCheckRewardThresholds(t *testing.T) {
 	processor, _, _ := setupTestProcessor()
@@ -663,6 +2782,93 @@ func TestCheckRewardThresholds(t *testing.T) {
 	}
 }
 
+func TestCheckRewardThresholds_DeterministicOrder(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	
+	// Thresholds deliberately out of order
+	thresholds := []clients.RewardThreshold{
+		{Points: 200, Stamps: 10, Description: "voucher"},
+		{Points: 0, Stamps: 5, Description: "free item"},
+		{Points: 100, Stamps: 0, Description: "discount"},
+	}
+	
+	rewards := processor.checkRewardThresholds(thresholds, 250, 12)
+	
+	assert.Len(t, rewards, 3)
+	assert.Equal(t, "free item", rewards[0].Description)
+	assert.Equal(t, "discount", rewards[1].Description)
+	assert.Equal(t, "voucher", rewards[2].Description)
+}
+
+// Test checkLifetimeRewardThresholds
+func TestCheckLifetimeRewardThresholds(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	
+	thresholds := []clients.RewardThreshold{
+		{Points: 100, Stamps: 0, Description: "discount"},
+		{Points: 0, Stamps: 10, Description: "free item"},
+	}
+	
+	tests := []struct {
+		name         string
+		balance      clients.Balance
+		points       int
+		stamps       int
+		expectedDesc []string
+	}{
+		{"below thresholds", clients.Balance{PointsBalance: 90, StampsBalance: 9}, 40, 1, nil},
+		{"points crossed", clients.Balance{PointsBalance: 120, StampsBalance: 5}, 40, 1, []string{"discount"}},
+		{"landing exactly on threshold", clients.Balance{PointsBalance: 100, StampsBalance: 10}, 40, 1, []string{"free item", "discount"}},
+		{"already past thresholds", clients.Balance{PointsBalance: 300, StampsBalance: 15}, 40, 1, nil},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewards := processor.checkLifetimeRewardThresholds(thresholds, &tt.balance, tt.points, tt.stamps)
+			
+			var descriptions []string
+			for _, reward := range rewards {
+				descriptions = append(descriptions, reward.Description)
+			}
+			assert.Equal(t, tt.expectedDesc, descriptions)
+		})
+	}
+}
+
+// Test applyRewardMode
+func TestApplyRewardMode(t *testing.T) {
+	processor, _, _ := setupTestProcessor()
+	
+	thresholds := []clients.RewardThreshold{
+		{Points: 200, Stamps: 10, Description: "voucher"},
+		{Points: 100, Stamps: 0, Description: "discount"},
+		{Points: 0, Stamps: 5, Description: "free item"},
+	}
+	
+	tests := []struct {
+		name         string
+		mode         string
+		expectedDesc []string
+	}{
+		{"default emits all", "", []string{"free item", "discount", "voucher"}},
+		{"emit all", clients.RewardModeAll, []string{"free item", "discount", "voucher"}},
+		{"emit highest", clients.RewardModeHighest, []string{"voucher"}},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewards := processor.checkRewardThresholds(thresholds, 250, 12)
+			rewards = processor.applyRewardMode(tt.mode, rewards)
+			
+			var descriptions []string
+			for _, reward := range rewards {
+				descriptions = append(descriptions, reward.Description)
+			}
+			assert.Equal(t, tt.expectedDesc, descriptions)
+		})
+	}
+}
+
 // Test NewEventProcessor
 func TestNewEventProcessor(t *testing.T) {
 	ledgerURL := "http://localhost:8001"
@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/loyalty/stream/internal/clients"
+)
+
+// activePromotion returns the time promotion that applies to an event at
+// timestamp in location, or nil if none does. Promotions only apply while the
+// location is open, and when several overlap the highest multiplier wins
+// rather than stacking.
+func activePromotion(location *clients.Location, timestamp time.Time) (*clients.TimePromotion, error) {
+	if len(location.Settings.TimePromotions) == 0 {
+		return nil, nil
+	}
+
+	tz := time.UTC
+	if location.OperatingHours.Timezone != "" {
+		loaded, err := time.LoadLocation(location.OperatingHours.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", location.OperatingHours.Timezone, err)
+		}
+		tz = loaded
+	}
+
+	local := timestamp.In(tz)
+	day := strings.ToLower(local.Weekday().String())
+	minute := local.Hour()*60 + local.Minute()
+
+	open, err := isOpen(location.OperatingHours, local.Weekday(), minute)
+	if err != nil {
+		return nil, err
+	}
+	if !open {
+		return nil, nil
+	}
+
+	var best *clients.TimePromotion
+	for i := range location.Settings.TimePromotions {
+		promotion := &location.Settings.TimePromotions[i]
+		if promotion.PointsMultiplier <= 0 || !appliesOnDay(promotion.Days, day) {
+			continue
+		}
+
+		inWindow, err := withinWindow(promotion.Start, promotion.End, minute)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window for promotion %q: %w", promotion.Name, err)
+		}
+		if inWindow && (best == nil || promotion.PointsMultiplier > best.PointsMultiplier) {
+			best = promotion
+		}
+	}
+
+	return best, nil
+}
+
+// isOpen reports whether the location is open at minute on weekday. Hours
+// that close before they open run past midnight, so the early hours of a day
+// belong to the previous day's window rather than its own.
+func isOpen(hours clients.OperatingHours, weekday time.Weekday, minute int) (bool, error) {
+	if len(hours.Days) == 0 {
+		return true, nil
+	}
+
+	today := strings.ToLower(weekday.String())
+	yesterday := strings.ToLower(((weekday + 6) % 7).String())
+
+	for _, dayHours := range hours.Days {
+		isToday := strings.EqualFold(dayHours.Day, today)
+		if !isToday && !strings.EqualFold(dayHours.Day, yesterday) {
+			continue
+		}
+
+		openMinute, err := parseClock(dayHours.Open)
+		if err != nil {
+			return false, fmt.Errorf("invalid hours for %s: %w", dayHours.Day, err)
+		}
+		closeMinute, err := parseClock(dayHours.Close)
+		if err != nil {
+			return false, fmt.Errorf("invalid hours for %s: %w", dayHours.Day, err)
+		}
+
+		overnight := closeMinute < openMinute
+		switch {
+		case isToday && !overnight:
+			if minute >= openMinute && minute < closeMinute {
+				return true, nil
+			}
+		case isToday:
+			if minute >= openMinute {
+				return true, nil
+			}
+		case overnight:
+			if minute < closeMinute {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func appliesOnDay(days []string, day string) bool {
+	if len(days) == 0 {
+		return true
+	}
+
+	for _, d := range days {
+		if strings.EqualFold(d, day) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinWindow reports whether minute falls in [start, end). An end before
+// start wraps past midnight.
+func withinWindow(start, end string, minute int) (bool, error) {
+	startMinute, err := parseClock(start)
+	if err != nil {
+		return false, err
+	}
+	endMinute, err := parseClock(end)
+	if err != nil {
+		return false, err
+	}
+
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute, nil
+	}
+	return minute >= startMinute || minute < endMinute, nil
+}
+
+// parseClock converts "HH:MM" to minutes after midnight
+func parseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse time %q: %w", clock, err)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loyalty/stream/internal/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test activePromotion
+func TestActivePromotion(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	friday := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 16, hour, minute, 0, 0, newYork)
+	}
+
+	lateNight := happyHourLocation()
+	lateNight.OperatingHours.Days = []clients.DayHours{{Day: "friday", Open: "18:00", Close: "02:00"}}
+	lateNight.Settings.TimePromotions = []clients.TimePromotion{
+		{Name: "last orders", Start: "23:00", End: "01:00", PointsMultiplier: 1.5},
+	}
+
+	overlapping := happyHourLocation()
+	overlapping.Settings.TimePromotions = append(overlapping.Settings.TimePromotions,
+		clients.TimePromotion{Name: "afternoon", Start: "12:00", End: "16:00", PointsMultiplier: 1.5})
+
+	alwaysOpen := happyHourLocation()
+	alwaysOpen.OperatingHours = clients.OperatingHours{}
+
+	tests := []struct {
+		name      string
+		location  *clients.Location
+		timestamp time.Time
+		expected  string
+	}{
+		{"start of window", happyHourLocation(), friday(15, 0), "happy hour"},
+		{"end of window is exclusive", happyHourLocation(), friday(17, 0), ""},
+		{"before window", happyHourLocation(), friday(14, 59), ""},
+		{"wrong day", happyHourLocation(), friday(15, 30).AddDate(0, 0, 1), ""},
+		{"window past midnight", lateNight, friday(23, 30), "last orders"},
+		{"open past midnight into the next day", lateNight, friday(0, 30).AddDate(0, 0, 1), "last orders"},
+		{"early hours belong to the previous day", lateNight, friday(0, 30), ""},
+		{"overlap takes highest multiplier", overlapping, friday(15, 30), "happy hour"},
+		{"overlap outside higher window", overlapping, friday(13, 0), "afternoon"},
+		{"no hours means always open in UTC", alwaysOpen, time.Date(2026, 10, 16, 15, 30, 0, 0, time.UTC), "happy hour"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			promotion, err := activePromotion(tt.location, tt.timestamp)
+			assert.NoError(t, err)
+			if tt.expected == "" {
+				assert.Nil(t, promotion)
+				return
+			}
+			if assert.NotNil(t, promotion) {
+				assert.Equal(t, tt.expected, promotion.Name)
+			}
+		})
+	}
+}
+
+func TestActivePromotion_ClosedLocation(t *testing.T) {
+	location := happyHourLocation()
+	location.OperatingHours.Days = []clients.DayHours{{Day: "friday", Open: "16:00", Close: "21:00"}}
+
+	// Inside the promotion window but before the store opens
+	timestamp := time.Date(2026, 10, 16, 19, 30, 0, 0, time.UTC)
+
+	promotion, err := activePromotion(location, timestamp)
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Nil(t, promotion)
+}
+
+func TestIsOpen_OvernightHours(t *testing.T) {
+	hours := clients.OperatingHours{Days: []clients.DayHours{
+		{Day: "saturday", Open: "20:00", Close: "03:00"},
+		{Day: "sunday", Open: "12:00", Close: "18:00"},
+	}}
+
+	tests := []struct {
+		name     string
+		weekday  time.Weekday
+		minute   int
+		expected bool
+	}{
+		{"saturday evening", time.Saturday, 21 * 60, true},
+		{"sunday early hours from saturday", time.Sunday, 2 * 60, true},
+		{"closing time is exclusive", time.Sunday, 3 * 60, false},
+		{"sunday between windows", time.Sunday, 10 * 60, false},
+		{"sunday afternoon", time.Sunday, 13 * 60, true},
+		{"saturday early hours not from friday", time.Saturday, 2 * 60, false},
+		{"monday early hours after a daytime sunday", time.Monday, 60, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, err := isOpen(hours, tt.weekday, tt.minute)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, open)
+		})
+	}
+}
+
+func TestActivePromotion_InvalidConfig(t *testing.T) {
+	badTimezone := happyHourLocation()
+	badTimezone.OperatingHours.Timezone = "Mars/Olympus_Mons"
+
+	badWindow := happyHourLocation()
+	badWindow.Settings.TimePromotions[0].Start = "3pm"
+
+	for _, location := range []*clients.Location{badTimezone, badWindow} {
+		_, err := activePromotion(location, time.Date(2026, 10, 16, 19, 30, 0, 0, time.UTC))
+		assert.Error(t, err)
+	}
+}
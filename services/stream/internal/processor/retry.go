@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/loyalty/stream/internal/clients"
+)
+
+// RetryConfig controls how calls that fail transiently are retried
+type RetryConfig struct {
+	// MaxAttempts is the number of tries per call, including the first.
+	// Zero or one means calls are not retried.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, doubling after each
+	// further attempt
+	BaseDelay time.Duration
+}
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+	}
+}
+
+// retryingLedgerClient retries the calls of the ledger client it wraps while
+// they fail transiently. Reads are retried on any error clients.IsRetryable
+// accepts, but writes only when clients.IsUnsent says the request never
+// reached the ledger, since a resent transfer would be applied twice.
+type retryingLedgerClient struct {
+	ledger clients.LedgerClientInterface
+	config RetryConfig
+}
+
+func newRetryingLedgerClient(ledger clients.LedgerClientInterface, config RetryConfig) clients.LedgerClientInterface {
+	if config.MaxAttempts <= 1 {
+		return ledger
+	}
+	return &retryingLedgerClient{ledger: ledger, config: config}
+}
+
+func (c *retryingLedgerClient) CreatePointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int) (*clients.TransferResponse, error) {
+	return withRetry(ctx, c.config, "Ledger create points transfer", clients.IsUnsent, func() (*clients.TransferResponse, error) {
+		return c.ledger.CreatePointsTransfer(ctx, orgID, customerID, points, reference, saleCents, expiryDays)
+	})
+}
+
+func (c *retryingLedgerClient) CreatePendingPointsTransfer(ctx context.Context, orgID, customerID string, points int, reference string, saleCents uint64, expiryDays int, settleAfter time.Duration) (*clients.TransferResponse, error) {
+	return withRetry(ctx, c.config, "Ledger create pending points transfer", clients.IsUnsent, func() (*clients.TransferResponse, error) {
+		return c.ledger.CreatePendingPointsTransfer(ctx, orgID, customerID, points, reference, saleCents, expiryDays, settleAfter)
+	})
+}
+
+// SettlePendingTransfer never retries a void: a partial void that reached the
+// ledger before failing would release the hold twice
+func (c *retryingLedgerClient) SettlePendingTransfer(ctx context.Context, orgID, customerID, reference string, void bool, points int) (*clients.TransferResponse, error) {
+	if void {
+		return c.ledger.SettlePendingTransfer(ctx, orgID, customerID, reference, void, points)
+	}
+	return withRetry(ctx, c.config, "Ledger settle pending transfer", clients.IsUnsent, func() (*clients.TransferResponse, error) {
+		return c.ledger.SettlePendingTransfer(ctx, orgID, customerID, reference, void, points)
+	})
+}
+
+func (c *retryingLedgerClient) CreateStampsTransfer(ctx context.Context, orgID, customerID string, stamps int, reference string) (*clients.TransferResponse, error) {
+	return withRetry(ctx, c.config, "Ledger create stamps transfer", clients.IsUnsent, func() (*clients.TransferResponse, error) {
+		return c.ledger.CreateStampsTransfer(ctx, orgID, customerID, stamps, reference)
+	})
+}
+
+func (c *retryingLedgerClient) CreateRedemption(ctx context.Context, orgID, customerID, rewardID string, points, stamps, bonusPoints, bonusExpiryDays int, reference string) (*clients.RedemptionResponse, error) {
+	return withRetry(ctx, c.config, "Ledger create redemption", clients.IsUnsent, func() (*clients.RedemptionResponse, error) {
+		return c.ledger.CreateRedemption(ctx, orgID, customerID, rewardID, points, stamps, bonusPoints, bonusExpiryDays, reference)
+	})
+}
+
+func (c *retryingLedgerClient) GetBalance(ctx context.Context, orgID, customerID string) (*clients.Balance, error) {
+	return withRetry(ctx, c.config, "Ledger get balance", clients.IsRetryable, func() (*clients.Balance, error) {
+		return c.ledger.GetBalance(ctx, orgID, customerID)
+	})
+}
+
+func (c *retryingLedgerClient) GetTransfers(ctx context.Context, orgID, customerID string, references ...string) ([]clients.Transfer, error) {
+	return withRetry(ctx, c.config, "Ledger get transfers", clients.IsRetryable, func() ([]clients.Transfer, error) {
+		return c.ledger.GetTransfers(ctx, orgID, customerID, references...)
+	})
+}
+
+// withRetry calls fn until it succeeds, fails with an error retryable
+// rejects, runs out of attempts or ctx ends, and returns its last result
+func withRetry[T any](ctx context.Context, config RetryConfig, operation string, retryable func(error) bool, fn func() (T, error)) (T, error) {
+	delay := config.BaseDelay
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= config.MaxAttempts || !retryable(err) {
+			return result, err
+		}
+
+		log.Printf("%s failed (attempt %d of %d), retrying in %s: %v", operation, attempt, config.MaxAttempts, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
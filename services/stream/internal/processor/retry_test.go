@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/loyalty/stream/internal/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test setup helper - a processor whose ledger calls are retried quickly
+func setupRetryingProcessor(maxAttempts int) (*EventProcessor, *MockLedgerClient, *MockMembershipClient) {
+	processor, mockLedgerClient, mockMembershipClient := setupTestProcessor()
+	processor.ledgerClient = newRetryingLedgerClient(mockLedgerClient, RetryConfig{MaxAttempts: maxAttempts, BaseDelay: time.Millisecond})
+	return processor, mockLedgerClient, mockMembershipClient
+}
+
+func retryTestOrg(mockMembershipClient *MockMembershipClient) {
+	mockMembershipClient.On("GetCustomer", "test_customer").Return(&clients.Customer{CustomerID: "test_customer", OrgID: "test_org", Status: "active"}, nil)
+	mockMembershipClient.On("GetOrganization", "test_org").Return(&clients.Organization{
+		OrgID:    "test_org",
+		Settings: clients.OrgSettings{PointsPerDollar: 2.0},
+	}, nil)
+}
+
+// Test ledger retries
+func TestProcessEvent_RetriesTransientLedgerFailures(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupRetryingProcessor(3)
+	retryTestOrg(mockMembershipClient)
+
+	// Setup expectations - the ledger is down for two attempts, then recovers
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_1").Return(nil, refused).Twice()
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_1").
+		Return(&clients.TransferResponse{TransferID: "transfer_1", Status: "success"}, nil).Once()
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions - the transfer landed on the third attempt
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, 100, result.PointsEarned)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 3)
+	mockLedgerClient.AssertExpectations(t)
+}
+
+func TestProcessEvent_GivesUpAfterMaxAttempts(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupRetryingProcessor(3)
+	retryTestOrg(mockMembershipClient)
+
+	// Setup expectations
+	refused := fmt.Errorf("failed to make request: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+	mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_1").Return(nil, refused)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "connection refused")
+	mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 3)
+}
+
+func TestProcessEvent_DoesNotResendWritesTheLedgerMayHaveApplied(t *testing.T) {
+	for name, ledgerErr := range map[string]error{
+		"server error":   &clients.StatusError{Service: "ledger", StatusCode: 503},
+		"client timeout": &net.OpError{Op: "read", Net: "tcp", Err: errors.New("i/o timeout")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			processor, mockLedgerClient, mockMembershipClient := setupRetryingProcessor(3)
+			retryTestOrg(mockMembershipClient)
+
+			// Setup expectations - the transfer may have landed before the failure
+			mockLedgerClient.On("CreatePointsTransfer", "test_org", "test_customer", 100, "pos_transaction_txn_1").Return(nil, ledgerErr)
+
+			// Process event
+			result, err := processor.ProcessEvent(context.Background(), posTransactionMessage("txn_1", 50.0))
+
+			// Assertions - one attempt only, so the customer is never credited twice
+			assert.NoError(t, err)
+			assert.False(t, result.Success)
+			mockLedgerClient.AssertNumberOfCalls(t, "CreatePointsTransfer", 1)
+		})
+	}
+}
+
+func TestRetryingLedgerClient_RetriesReadsOnServerErrors(t *testing.T) {
+	mockLedgerClient := &MockLedgerClient{}
+	ledger := newRetryingLedgerClient(mockLedgerClient, RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	// Setup expectations
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(nil, &clients.StatusError{Service: "ledger", StatusCode: 503}).Once()
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 40}, nil).Once()
+
+	balance, err := ledger.GetBalance(context.Background(), "test_org", "test_customer")
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(40), balance.PointsBalance)
+	mockLedgerClient.AssertNumberOfCalls(t, "GetBalance", 2)
+}
+
+func TestProcessEvent_DoesNotRetryPermanentLedgerFailures(t *testing.T) {
+	processor, mockLedgerClient, mockMembershipClient := setupRetryingProcessor(3)
+
+	// Setup expectations - the ledger refuses the redemption outright
+	mockMembershipClient.On("GetOrganization", "test_org").Return(rewardCatalogOrg(), nil)
+	mockLedgerClient.On("GetBalance", "test_org", "test_customer").Return(&clients.Balance{PointsBalance: 300}, nil)
+	mockLedgerClient.On("CreateRedemption", "test_org", "test_customer", "free_coffee", 300, 0, 25, "redeem_ref").
+		Return(nil, clients.ErrInsufficientBalance)
+
+	// Process event
+	result, err := processor.ProcessEvent(context.Background(), redeemRewardMessage("free_coffee"))
+
+	// Assertions
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "failed to redeem reward: insufficient balance", result.Error)
+	mockLedgerClient.AssertNumberOfCalls(t, "CreateRedemption", 1)
+}
+
+func TestWithRetry_StopsWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+
+	_, err := withRetry(ctx, RetryConfig{MaxAttempts: 5, BaseDelay: time.Hour}, "test", clients.IsRetryable, func() (int, error) {
+		attempts++
+		cancel()
+		return 0, &clients.StatusError{Service: "ledger", StatusCode: 503}
+	})
+
+	// Assertions - the backoff is abandoned rather than waited out
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,175 @@
+// Package locale formats money and numbers in an org's currency and locale,
+// shared by the stream processor's reward descriptions and the accrual rates
+// membership describes, so both read alike.
+package locale
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Defaults used when an org has not set its currency or locale
+const (
+	DefaultCurrency = "USD"
+	DefaultLocale   = "en-US"
+)
+
+// numberFormat describes how a locale writes numbers and money amounts
+type numberFormat struct {
+	decimal     string
+	group       string
+	symbolAfter bool
+	symbolSpace bool
+}
+
+// numberFormats is keyed by language, with region-specific entries where the
+// region differs from its language
+var numberFormats = map[string]numberFormat{
+	"en":    {decimal: ".", group: ","},
+	"de":    {decimal: ",", group: ".", symbolAfter: true, symbolSpace: true},
+	"de-CH": {decimal: ".", group: "'", symbolSpace: true},
+	"es":    {decimal: ",", group: ".", symbolAfter: true, symbolSpace: true},
+	"fr":    {decimal: ",", group: " ", symbolAfter: true, symbolSpace: true},
+	"it":    {decimal: ",", group: ".", symbolAfter: true, symbolSpace: true},
+	"nl":    {decimal: ",", group: ".", symbolSpace: true},
+	"pt":    {decimal: ",", group: ".", symbolAfter: true, symbolSpace: true},
+	"ja":    {decimal: ".", group: ","},
+}
+
+type currencyFormat struct {
+	symbol   string
+	decimals int
+}
+
+var currencyFormats = map[string]currencyFormat{
+	"USD": {symbol: "$", decimals: 2},
+	"EUR": {symbol: "€", decimals: 2},
+	"GBP": {symbol: "£", decimals: 2},
+	"JPY": {symbol: "¥", decimals: 0},
+	"CAD": {symbol: "CA$", decimals: 2},
+	"AUD": {symbol: "A$", decimals: 2},
+	"CHF": {symbol: "CHF", decimals: 2},
+}
+
+// FormatMoney renders amount in currency using locale's separators and symbol
+// placement. Unknown currencies are written with their ISO code and unknown
+// locales fall back to en-US.
+func FormatMoney(amount float64, currency, locale string) string {
+	return formatMoney(amount, currency, locale, false)
+}
+
+// FormatMoneyShort is FormatMoney with whole amounts written without their
+// minor units, so a rate reads "per $1" rather than "per $1.00"
+func FormatMoneyShort(amount float64, currency, locale string) string {
+	return formatMoney(amount, currency, locale, true)
+}
+
+// FormatNumber writes value with decimals places, or as few as it needs when
+// decimals is negative, using locale's separators
+func FormatNumber(value float64, decimals int, locale string) string {
+	return formatNumber(value, decimals, lookupNumberFormat(locale))
+}
+
+func formatMoney(amount float64, currency, locale string, short bool) string {
+	cf := lookupCurrencyFormat(currency)
+	nf := lookupNumberFormat(locale)
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	decimals := cf.decimals
+	if short && amount == float64(int64(amount)) {
+		decimals = 0
+	}
+	number := formatNumber(amount, decimals, nf)
+
+	// Alphabetic symbols such as CHF always need a space to stay readable
+	space := ""
+	if nf.symbolSpace || isLetters(cf.symbol) {
+		space = " "
+	}
+
+	if nf.symbolAfter {
+		return sign + number + space + cf.symbol
+	}
+	return sign + cf.symbol + space + number
+}
+
+func formatNumber(value float64, decimals int, nf numberFormat) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	digits := strconv.FormatFloat(value, 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(digits, ".")
+	number := groupDigits(whole, nf.group)
+	if fraction != "" {
+		number += nf.decimal + fraction
+	}
+	return sign + number
+}
+
+func lookupCurrencyFormat(currency string) currencyFormat {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	currency = strings.ToUpper(currency)
+
+	if cf, ok := currencyFormats[currency]; ok {
+		return cf
+	}
+	return currencyFormat{symbol: currency, decimals: 2}
+}
+
+func lookupNumberFormat(locale string) numberFormat {
+	if locale == "" {
+		locale = DefaultLocale
+	}
+
+	// Accept both en-US and en_US
+	locale = strings.ReplaceAll(locale, "_", "-")
+	language, region, _ := strings.Cut(locale, "-")
+	language = strings.ToLower(language)
+
+	if nf, ok := numberFormats[language+"-"+strings.ToUpper(region)]; ok {
+		return nf
+	}
+	if nf, ok := numberFormats[language]; ok {
+		return nf
+	}
+	return numberFormats["en"]
+}
+
+// groupDigits inserts sep between every three digits of whole
+func groupDigits(whole, sep string) string {
+	if len(whole) <= 3 {
+		return whole
+	}
+
+	var b strings.Builder
+	lead := len(whole) % 3
+	if lead > 0 {
+		b.WriteString(whole[:lead])
+	}
+	for i := lead; i < len(whole); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(whole[i : i+3])
+	}
+	return b.String()
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,51 @@
+package locale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test FormatMoney
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		currency string
+		locale   string
+		expected string
+	}{
+		{"US dollars", 25, "USD", "en-US", "$25.00"},
+		{"defaults to US dollars", 25, "", "", "$25.00"},
+		{"euros in Germany", 25, "EUR", "de-DE", "25,00 €"},
+		{"euros in Ireland", 25, "EUR", "en-IE", "€25.00"},
+		{"euros in the Netherlands", 1234.5, "EUR", "nl-NL", "€ 1.234,50"},
+		{"grouping in France", 1234.5, "EUR", "fr_FR", "1 234,50 €"},
+		{"yen has no decimals", 1500, "JPY", "ja-JP", "¥1,500"},
+		{"Swiss francs", 1234.5, "CHF", "de-CH", "CHF 1'234.50"},
+		{"unknown currency uses its code", 10, "sek", "en-US", "SEK 10.00"},
+		{"unknown locale uses en-US", 25, "GBP", "xx-YY", "£25.00"},
+		{"rounds to the currency's decimals", 9.999, "USD", "en-US", "$10.00"},
+		{"large amounts", 1234567.891, "USD", "en-US", "$1,234,567.89"},
+		{"negative amounts", -5, "USD", "en-US", "-$5.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, FormatMoney(tt.amount, tt.currency, tt.locale))
+		})
+	}
+}
+
+func TestFormatMoneyShort(t *testing.T) {
+	assert.Equal(t, "$1,234.50", FormatMoneyShort(1234.5, "USD", "en-US"))
+	assert.Equal(t, "$10", FormatMoneyShort(10, "", ""))
+	assert.Equal(t, "10 €", FormatMoneyShort(10, "EUR", "de_DE"))
+	assert.Equal(t, "SEK 5", FormatMoneyShort(5, "sek", "xx"))
+}
+
+func TestFormatNumber(t *testing.T) {
+	assert.Equal(t, "1,5", FormatNumber(1.5, -1, "de-DE"))
+	assert.Equal(t, "1 250", FormatNumber(1250, -1, "fr-FR"))
+	assert.Equal(t, "0.30", FormatNumber(0.3, 2, ""))
+}
@@ -0,0 +1,25 @@
+// Package points holds the points accrual arithmetic shared by the stream
+// processor and tooling that previews what an org's settings will award.
+package points
+
+import "math"
+
+// Rate returns pointsPerDollar scaled by each multiplier in turn, as the
+// processor does for location promotions and customer tiers
+func Rate(pointsPerDollar float64, multipliers ...float64) float64 {
+	rate := pointsPerDollar
+	for _, multiplier := range multipliers {
+		rate *= multiplier
+	}
+	return rate
+}
+
+// Calculate returns the whole points earned by spending amount at
+// pointsPerDollar. Fractional points are dropped, and a rate of zero or less
+// earns nothing.
+func Calculate(amount, pointsPerDollar float64) int {
+	if pointsPerDollar <= 0 {
+		return 0
+	}
+	return int(math.Floor(amount * pointsPerDollar))
+}
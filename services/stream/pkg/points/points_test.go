@@ -0,0 +1,55 @@
+package points
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Calculate
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name            string
+		amount          float64
+		pointsPerDollar float64
+		expected        int
+	}{
+		{"whole dollars", 50.0, 2.0, 100},
+		{"fractional points are dropped", 10.99, 1.0, 10},
+		{"fractional rate", 25.0, 0.5, 12},
+		{"zero rate", 50.0, 0, 0},
+		{"negative rate", 50.0, -1.0, 0},
+		{"zero amount", 0, 2.0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Calculate(tt.amount, tt.pointsPerDollar))
+		})
+	}
+}
+
+// Test Rate
+func TestRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		base        float64
+		multipliers []float64
+		expected    float64
+	}{
+		{"no multipliers", 2.0, nil, 2.0},
+		{"tier multiplier", 2.0, []float64{1.5}, 3.0},
+		{"promotion and tier", 1.0, []float64{2.0, 1.5}, 3.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Rate(tt.base, tt.multipliers...))
+		})
+	}
+}
+
+func TestCalculate_GoldTierPurchase(t *testing.T) {
+	// $50 at 2 points per dollar for a 1.5x tier
+	assert.Equal(t, 150, Calculate(50.0, Rate(2.0, 1.5)))
+}
@@ -3,6 +3,7 @@ module github.com/loyalty/kafka-cli
 go 1.21
 
 require (
+	github.com/loyalty/stream v0.0.0
 	github.com/segmentio/kafka-go v0.4.44
 	github.com/spf13/cobra v1.8.0
 )
@@ -16,3 +17,5 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
 )
+
+replace github.com/loyalty/stream => ../../services/stream
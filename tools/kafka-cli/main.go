@@ -9,6 +9,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/loyalty/stream/pkg/points"
 	"github.com/segmentio/kafka-go"
 	"github.com/spf13/cobra"
 )
@@ -20,6 +21,10 @@ var (
 	customerID string
 	count      int
 	interval   time.Duration
+
+	calcAmount          float64
+	calcPointsPerDollar float64
+	calcMultipliers     []float64
 )
 
 type BaseEvent struct {
@@ -81,7 +86,19 @@ func main() {
 		Run:   runBenchmark,
 	}
 
-	rootCmd.AddCommand(posCmd, loyaltyCmd, customerCmd, streamCmd, benchmarkCmd)
+	var calcPointsCmd = &cobra.Command{
+		Use:   "calc-points",
+		Short: "Preview points awarded for a purchase",
+		Long:  "Print the points the stream processor would award for an amount, using the same calculation, to check org settings before publishing events",
+		Args:  cobra.NoArgs,
+		RunE:  calcPoints,
+	}
+	calcPointsCmd.Flags().Float64Var(&calcAmount, "amount", 0, "Purchase amount")
+	calcPointsCmd.Flags().Float64Var(&calcPointsPerDollar, "points-per-dollar", 1, "Org points per dollar")
+	calcPointsCmd.Flags().Float64SliceVar(&calcMultipliers, "multiplier", nil, "Points multiplier such as a promotion or tier (repeatable)")
+	calcPointsCmd.MarkFlagRequired("amount")
+
+	rootCmd.AddCommand(posCmd, loyaltyCmd, customerCmd, streamCmd, benchmarkCmd, calcPointsCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -218,6 +235,27 @@ func runBenchmark(cmd *cobra.Command, args []string) {
 	fmt.Printf("🚀 Events/sec: %.2f\n", eventsPerSec)
 }
 
+func calcPoints(cmd *cobra.Command, args []string) error {
+	if calcAmount < 0 {
+		return fmt.Errorf("amount must not be negative")
+	}
+	for _, multiplier := range calcMultipliers {
+		if multiplier <= 0 {
+			return fmt.Errorf("multiplier must be positive, got %g", multiplier)
+		}
+	}
+
+	rate := points.Rate(calcPointsPerDollar, calcMultipliers...)
+	fmt.Printf("Amount:            %.2f\n", calcAmount)
+	fmt.Printf("Points per dollar: %g\n", calcPointsPerDollar)
+	for _, multiplier := range calcMultipliers {
+		fmt.Printf("Multiplier:        %gx\n", multiplier)
+	}
+	fmt.Printf("Effective rate:    %g\n", rate)
+	fmt.Printf("Points awarded:    %d\n", points.Calculate(calcAmount, rate))
+	return nil
+}
+
 func createKafkaWriter() *kafka.Writer {
 	return &kafka.Writer{
 		Addr:     kafka.TCP(brokers),
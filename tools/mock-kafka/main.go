@@ -3,11 +3,13 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,18 +17,246 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// errConsumerClosed is returned when sending to a consumer that has
+// disconnected
+var errConsumerClosed = errors.New("consumer closed")
+
 var (
 	port       int
 	orgID      string
 	customerID string
 	interval   time.Duration
 	eventCount int
+
+	dedupWindow time.Duration
+	dedupMaxIDs int
+
+	deliveryLatency time.Duration
+	delayRate       float64
+	dropRate        float64
+
+	historySize int
 )
 
+// ServerConfig holds the optional behaviour of MockKafkaServer
+type ServerConfig struct {
+	// DedupWindow drops events whose event_id was already published within
+	// the window, like an exactly-once producer. Zero disables deduplication.
+	DedupWindow time.Duration
+	// DedupMaxIDs bounds how many event IDs are remembered; the oldest are
+	// forgotten first
+	DedupMaxIDs int
+	// DeliveryLatency holds back DelayRate of deliveries (0-1) by this long,
+	// so consumers see slow brokers. Zero delivers immediately.
+	DeliveryLatency time.Duration
+	DelayRate       float64
+	// DropRate is the fraction of deliveries (0-1) silently lost, so
+	// consumers' retry logic can be exercised
+	DropRate float64
+	// HistorySize is how many messages are kept per topic for consumers that
+	// connect with ?from=earliest. Zero keeps none.
+	HistorySize int
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		DedupMaxIDs: 10000,
+		DelayRate:   1,
+		HistorySize: 1000,
+	}
+}
+
 type MockKafkaServer struct {
-	consumers map[string][]*websocket.Conn
+	consumers map[string][]*consumer
 	mu        sync.RWMutex
 	upgrader  websocket.Upgrader
+	seen      *seenEvents
+	config    ServerConfig
+	// history holds each topic's recent messages. Publishes append to it
+	// under mu's read lock, so it has its own lock; consumers snapshot it
+	// under mu's write lock, so no publish is half done when they do.
+	history   map[string]*messageRing
+	historyMu sync.Mutex
+	// sequence orders messages across topics for replay
+	sequence uint64
+	// roll returns a number in [0, 1) for deciding whether to drop or delay
+	// a delivery
+	roll func() float64
+}
+
+// consumer wraps a subscriber's connection. Publishes hold the server's read
+// lock, so they only queue messages; a writer goroutine per connection sends
+// them, which also keeps to websocket's one concurrent writer. A slow
+// consumer then backs up its own queue rather than every publish.
+type consumer struct {
+	conn   *websocket.Conn
+	mu     sync.Mutex
+	queue  [][]byte
+	closed bool
+	// ready is signalled when messages are queued, done closed on disconnect
+	ready chan struct{}
+	done  chan struct{}
+}
+
+func newConsumer(conn *websocket.Conn) *consumer {
+	return &consumer{
+		conn:  conn,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// send queues data for the writer goroutine. It never blocks on the
+// connection.
+func (c *consumer) send(data []byte) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errConsumerClosed
+	}
+	c.queue = append(c.queue, data)
+	c.mu.Unlock()
+
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// next takes the oldest queued message, if any
+func (c *consumer) next() ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.queue) == 0 {
+		return nil, false
+	}
+	data := c.queue[0]
+	c.queue[0] = nil
+	c.queue = c.queue[1:]
+	return data, true
+}
+
+// run writes queued messages to the connection, in order, until close is
+// called or a write fails
+func (c *consumer) run() {
+	for {
+		select {
+		case <-c.ready:
+		case <-c.done:
+			return
+		}
+
+		for data, ok := c.next(); ok; data, ok = c.next() {
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				select {
+				case <-c.done:
+				default:
+					log.Printf("Failed to send message to consumer: %v", err)
+				}
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// close stops the writer and drops anything still queued
+func (c *consumer) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.queue = nil
+	close(c.done)
+}
+
+// seenEvents is a bounded set of recently published event IDs
+type seenEvents struct {
+	mu     sync.Mutex
+	window time.Duration
+	maxIDs int
+	at     map[string]time.Time
+	order  []string
+	now    func() time.Time
+}
+
+func newSeenEvents(window time.Duration, maxIDs int) *seenEvents {
+	return &seenEvents{
+		window: window,
+		maxIDs: maxIDs,
+		at:     make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// markSeen records eventID and reports whether it was already seen within the
+// window
+func (s *seenEvents) markSeen(eventID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	// IDs are recorded in time order, so expired ones are all at the front
+	for len(s.order) > 0 && now.Sub(s.at[s.order[0]]) >= s.window {
+		delete(s.at, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	if _, ok := s.at[eventID]; ok {
+		return true
+	}
+
+	s.at[eventID] = now
+	s.order = append(s.order, eventID)
+	if s.maxIDs > 0 && len(s.order) > s.maxIDs {
+		delete(s.at, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	return false
+}
+
+// bufferedMessage is a published message kept for replay
+type bufferedMessage struct {
+	sequence uint64
+	data     []byte
+}
+
+// messageRing keeps the last len(messages) messages published to a topic,
+// overwriting the oldest once full
+type messageRing struct {
+	messages []bufferedMessage
+	start    int
+	count    int
+}
+
+func newMessageRing(size int) *messageRing {
+	return &messageRing{messages: make([]bufferedMessage, size)}
+}
+
+func (r *messageRing) push(message bufferedMessage) {
+	if r.count < len(r.messages) {
+		r.messages[(r.start+r.count)%len(r.messages)] = message
+		r.count++
+		return
+	}
+	r.messages[r.start] = message
+	r.start = (r.start + 1) % len(r.messages)
+}
+
+// all returns the kept messages, oldest first
+func (r *messageRing) all() []bufferedMessage {
+	messages := make([]bufferedMessage, r.count)
+	for i := range messages {
+		messages[i] = r.messages[(r.start+i)%len(r.messages)]
+	}
+	return messages
 }
 
 type BaseEvent struct {
@@ -40,14 +270,25 @@ type BaseEvent struct {
 }
 
 func NewMockKafkaServer() *MockKafkaServer {
-	return &MockKafkaServer{
-		consumers: make(map[string][]*websocket.Conn),
+	return NewMockKafkaServerWithConfig(DefaultServerConfig())
+}
+
+func NewMockKafkaServerWithConfig(config ServerConfig) *MockKafkaServer {
+	server := &MockKafkaServer{
+		consumers: make(map[string][]*consumer),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
 			},
 		},
+		config:  config,
+		history: make(map[string]*messageRing),
+		roll:    rand.Float64,
 	}
+	if config.DedupWindow > 0 {
+		server.seen = newSeenEvents(config.DedupWindow, config.DedupMaxIDs)
+	}
+	return server
 }
 
 func (s *MockKafkaServer) handleConsumer(w http.ResponseWriter, r *http.Request) {
@@ -57,6 +298,12 @@ func (s *MockKafkaServer) handleConsumer(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	from := r.URL.Query().Get("from")
+	if from != "" && from != "earliest" && from != "latest" {
+		http.Error(w, "from must be earliest or latest", http.StatusBadRequest)
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade connection: %v", err)
@@ -64,11 +311,29 @@ func (s *MockKafkaServer) handleConsumer(w http.ResponseWriter, r *http.Request)
 	}
 	defer conn.Close()
 
+	sub := newConsumer(conn)
+	go sub.run()
+	defer sub.close()
+
+	// The replay is queued ahead of any live message before the consumer is
+	// registered, so none are missed, repeated or delivered out of order.
+	// Only queueing happens under the lock; the writer sends it afterwards.
 	s.mu.Lock()
-	s.consumers[topic] = append(s.consumers[topic], conn)
+	var replay []bufferedMessage
+	if from == "earliest" {
+		replay = s.bufferedFor(topic)
+	}
+	for _, message := range replay {
+		sub.send(message.data)
+	}
+	s.consumers[topic] = append(s.consumers[topic], sub)
 	s.mu.Unlock()
 
-	log.Printf("Consumer connected to topic: %s", topic)
+	if from == "earliest" {
+		log.Printf("Consumer connected to topic: %s (replayed %d messages)", topic, len(replay))
+	} else {
+		log.Printf("Consumer connected to topic: %s", topic)
+	}
 
 	// Keep connection alive
 	for {
@@ -81,7 +346,7 @@ func (s *MockKafkaServer) handleConsumer(w http.ResponseWriter, r *http.Request)
 	s.mu.Lock()
 	consumers := s.consumers[topic]
 	for i, c := range consumers {
-		if c == conn {
+		if c == sub {
 			s.consumers[topic] = append(consumers[:i], consumers[i+1:]...)
 			break
 		}
@@ -91,20 +356,26 @@ func (s *MockKafkaServer) handleConsumer(w http.ResponseWriter, r *http.Request)
 	log.Printf("Consumer disconnected from topic: %s", topic)
 }
 
-func (s *MockKafkaServer) publishEvent(topic string, event BaseEvent) {
+// publishEvent delivers event to every consumer subscribed to topic. It
+// reports false when the event is dropped as a duplicate.
+func (s *MockKafkaServer) publishEvent(topic string, event BaseEvent) bool {
+	if s.seen != nil && event.EventID != "" && s.seen.markSeen(event.EventID) {
+		log.Printf("🔁 Dropped duplicate event %s on topic %s", event.EventID, topic)
+		return false
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
 	eventJSON, _ := json.Marshal(event)
+	s.buffer(topic, eventJSON)
 	totalConsumers := 0
 	
 	// Find matching consumers for topic patterns
 	for subscribedTopic, consumers := range s.consumers {
 		if s.topicMatches(subscribedTopic, topic) {
-			for _, conn := range consumers {
-				if err := conn.WriteMessage(websocket.TextMessage, eventJSON); err != nil {
-					log.Printf("Failed to send message to consumer: %v", err)
-				} else {
+			for _, sub := range consumers {
+				if s.deliver(sub, topic, eventJSON) {
 					totalConsumers++
 				}
 			}
@@ -116,6 +387,64 @@ func (s *MockKafkaServer) publishEvent(topic string, event BaseEvent) {
 	} else {
 		log.Printf("📤 Event published to topic %s (%d consumers)", topic, totalConsumers)
 	}
+
+	return true
+}
+
+// buffer keeps data in topic's history for replay
+func (s *MockKafkaServer) buffer(topic string, data []byte) {
+	if s.config.HistorySize <= 0 {
+		return
+	}
+
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	ring, ok := s.history[topic]
+	if !ok {
+		ring = newMessageRing(s.config.HistorySize)
+		s.history[topic] = ring
+	}
+	s.sequence++
+	ring.push(bufferedMessage{sequence: s.sequence, data: data})
+}
+
+// bufferedFor returns the kept messages of every topic matching pattern, in
+// the order they were published
+func (s *MockKafkaServer) bufferedFor(pattern string) []bufferedMessage {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	var messages []bufferedMessage
+	for topic, ring := range s.history {
+		if s.topicMatches(pattern, topic) {
+			messages = append(messages, ring.all()...)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].sequence < messages[j].sequence
+	})
+	return messages
+}
+
+// deliver sends data to one consumer, applying any configured drop rate and
+// latency. Delayed deliveries are sent in the background so they do not hold
+// up other consumers. It reports whether the delivery was queued or scheduled.
+func (s *MockKafkaServer) deliver(sub *consumer, topic string, data []byte) bool {
+	if s.config.DropRate > 0 && s.roll() < s.config.DropRate {
+		log.Printf("💥 Dropped delivery on topic %s", topic)
+		return false
+	}
+
+	if s.config.DeliveryLatency > 0 && s.roll() < s.config.DelayRate {
+		go func() {
+			time.Sleep(s.config.DeliveryLatency)
+			sub.send(data)
+		}()
+		return true
+	}
+
+	return sub.send(data) == nil
 }
 
 func (s *MockKafkaServer) topicMatches(pattern, topic string) bool {
@@ -152,11 +481,14 @@ func (s *MockKafkaServer) handlePublish(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	s.publishEvent(topic, event)
+	status := "published"
+	if !s.publishEvent(topic, event) {
+		status = "duplicate"
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"status": "published",
+		"status": status,
 		"topic":  topic,
 		"event_id": event.EventID,
 	})
@@ -179,7 +511,20 @@ func (s *MockKafkaServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func startServer(cmd *cobra.Command, args []string) {
-	server := NewMockKafkaServer()
+	for name, rate := range map[string]float64{"delay-rate": delayRate, "drop-rate": dropRate} {
+		if rate < 0 || rate > 1 {
+			log.Fatalf("--%s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+
+	server := NewMockKafkaServerWithConfig(ServerConfig{
+		DedupWindow:     dedupWindow,
+		DedupMaxIDs:     dedupMaxIDs,
+		DeliveryLatency: deliveryLatency,
+		DelayRate:       delayRate,
+		DropRate:        dropRate,
+		HistorySize:     historySize,
+	})
 
 	http.HandleFunc("/consumer", server.handleConsumer)
 	http.HandleFunc("/publish", server.handlePublish)
@@ -187,7 +532,7 @@ func startServer(cmd *cobra.Command, args []string) {
 
 	log.Printf("🚀 Mock Kafka Server starting on port %d", port)
 	log.Printf("📊 Endpoints:")
-	log.Printf("   - WebSocket: ws://localhost:%d/consumer?topic=<topic>", port)
+	log.Printf("   - WebSocket: ws://localhost:%d/consumer?topic=<topic>[&from=earliest]", port)
 	log.Printf("   - Publish: POST http://localhost:%d/publish?topic=<topic>", port)
 	log.Printf("   - Status: GET http://localhost:%d/status", port)
 
@@ -347,6 +692,14 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&orgID, "org", "brand123", "Organization ID")
 	rootCmd.PersistentFlags().StringVar(&customerID, "customer", "", "Specific customer ID")
 
+	// Server flags
+	serverCmd.Flags().DurationVar(&dedupWindow, "dedup-window", 0, "Drop events whose event_id was already published within this window (0 disables)")
+	serverCmd.Flags().IntVar(&dedupMaxIDs, "dedup-max-ids", DefaultServerConfig().DedupMaxIDs, "Maximum event IDs remembered for deduplication")
+	serverCmd.Flags().DurationVar(&deliveryLatency, "latency", 0, "Delay deliveries to consumers by this long (0 disables)")
+	serverCmd.Flags().Float64Var(&delayRate, "delay-rate", DefaultServerConfig().DelayRate, "Fraction of deliveries (0-1) delayed by --latency")
+	serverCmd.Flags().Float64Var(&dropRate, "drop-rate", 0, "Fraction of deliveries (0-1) randomly dropped")
+	serverCmd.Flags().IntVar(&historySize, "history-size", DefaultServerConfig().HistorySize, "Messages kept per topic for consumers connecting with from=earliest (0 disables)")
+
 	// Publish flags
 	publishCmd.Flags().IntVar(&eventCount, "count", 10, "Number of events to publish")
 	publishCmd.Flags().DurationVar(&interval, "interval", 1*time.Second, "Interval between events")
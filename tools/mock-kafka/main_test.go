@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test setup helper - starts a server with one consumer subscribed to topic
+func setupTestConsumer(t *testing.T, topic string) (*MockKafkaServer, *websocket.Conn) {
+	return setupTestConsumerWithConfig(t, DefaultServerConfig(), topic)
+}
+
+func setupTestConsumerWithConfig(t *testing.T, config ServerConfig, topic string) (*MockKafkaServer, *websocket.Conn) {
+	server, httpServer := setupTestServer(t, config)
+	return server, connectTestConsumer(t, server, httpServer, "topic="+topic, topic)
+}
+
+func setupTestServer(t *testing.T, config ServerConfig) (*MockKafkaServer, *httptest.Server) {
+	server := NewMockKafkaServerWithConfig(config)
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleConsumer))
+	t.Cleanup(httpServer.Close)
+	return server, httpServer
+}
+
+// connectTestConsumer connects a consumer with query and waits for the
+// server to register it under topic
+func connectTestConsumer(t *testing.T, server *MockKafkaServer, httpServer *httptest.Server, query, topic string) *websocket.Conn {
+	server.mu.RLock()
+	registered := len(server.consumers[topic])
+	server.mu.RUnlock()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/consumer?" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	// Wait for the server to register the consumer
+	require.Eventually(t, func() bool {
+		server.mu.RLock()
+		defer server.mu.RUnlock()
+		return len(server.consumers[topic]) == registered+1
+	}, time.Second, 5*time.Millisecond)
+
+	return conn
+}
+
+// readEventIDs reads count events from conn and returns their IDs in order
+func readEventIDs(t *testing.T, conn *websocket.Conn, count int) []string {
+	var ids []string
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < count; i++ {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		var event BaseEvent
+		require.NoError(t, json.Unmarshal(data, &event))
+		ids = append(ids, event.EventID)
+	}
+	return ids
+}
+
+// Test publishEvent
+func TestPublishEvent_ConcurrentPublishesToOneConsumer(t *testing.T) {
+	server, conn := setupTestConsumer(t, "*.pos.transaction")
+
+	const publishes = 100
+	var wg sync.WaitGroup
+	for i := 0; i < publishes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			server.publishEvent("test_org.pos.transaction", BaseEvent{
+				EventID:   fmt.Sprintf("evt_%d", i),
+				EventType: "pos.transaction",
+				OrgID:     "test_org",
+				Payload:   map[string]interface{}{"amount": float64(i)},
+			})
+		}(i)
+	}
+
+	// Every frame must arrive intact and decode
+	received := make(map[string]bool)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < publishes; i++ {
+		messageType, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, websocket.TextMessage, messageType)
+
+		var event BaseEvent
+		require.NoError(t, json.Unmarshal(data, &event))
+		received[event.EventID] = true
+	}
+	wg.Wait()
+
+	// Assertions
+	assert.Len(t, received, publishes)
+}
+
+func TestPublishEvent_StalledConsumerDoesNotBlock(t *testing.T) {
+	server, conn := setupTestConsumer(t, "*.pos.transaction")
+
+	// A consumer whose writer never drains its queue, like one on a stalled
+	// connection
+	stalled := newConsumer(nil)
+	server.mu.Lock()
+	server.consumers["*.pos.transaction"] = append(server.consumers["*.pos.transaction"], stalled)
+	server.mu.Unlock()
+
+	const publishes = 50
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < publishes; i++ {
+			server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: fmt.Sprintf("evt_%d", i), OrgID: "test_org"})
+		}
+	}()
+
+	// Assertions - publishes return and the live consumer still gets every
+	// message while the stalled one only queues them
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishes blocked on the stalled consumer")
+	}
+	assert.Len(t, readEventIDs(t, conn, publishes), publishes)
+
+	stalled.mu.Lock()
+	assert.Len(t, stalled.queue, publishes)
+	stalled.mu.Unlock()
+}
+
+func TestPublishEvent_ClosedConsumerNotCounted(t *testing.T) {
+	server := NewMockKafkaServer()
+
+	closed := newConsumer(nil)
+	closed.close()
+
+	// Assertions
+	assert.False(t, server.deliver(closed, "test_org.pos.transaction", []byte("{}")))
+	assert.ErrorIs(t, closed.send([]byte("{}")), errConsumerClosed)
+}
+
+func TestPublishEvent_DedupDropsRepeatedEventID(t *testing.T) {
+	server, conn := setupTestConsumerWithConfig(t, ServerConfig{DedupWindow: time.Minute, DedupMaxIDs: 100}, "*.pos.transaction")
+
+	event := BaseEvent{EventID: "evt_1", EventType: "pos.transaction", OrgID: "test_org"}
+	assert.True(t, server.publishEvent("test_org.pos.transaction", event))
+	assert.False(t, server.publishEvent("test_org.pos.transaction", event))
+	assert.True(t, server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: "evt_2", OrgID: "test_org"}))
+
+	// Only evt_1 then evt_2 should arrive
+	var received []string
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 2; i++ {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		var event BaseEvent
+		require.NoError(t, json.Unmarshal(data, &event))
+		received = append(received, event.EventID)
+	}
+
+	// Assertions
+	assert.Equal(t, []string{"evt_1", "evt_2"}, received)
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err, "the duplicate should not be delivered")
+}
+
+func TestPublishEvent_DedupDisabledByDefault(t *testing.T) {
+	server := NewMockKafkaServer()
+	event := BaseEvent{EventID: "evt_1", OrgID: "test_org"}
+
+	// Assertions
+	assert.True(t, server.publishEvent("test_org.pos.transaction", event))
+	assert.True(t, server.publishEvent("test_org.pos.transaction", event))
+}
+
+// Test seenEvents
+func TestSeenEvents_ForgetsAfterWindow(t *testing.T) {
+	seen := newSeenEvents(time.Minute, 100)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	seen.now = func() time.Time { return now }
+
+	assert.False(t, seen.markSeen("evt_1"))
+
+	now = now.Add(59 * time.Second)
+	assert.True(t, seen.markSeen("evt_1"))
+
+	now = now.Add(time.Second)
+	assert.False(t, seen.markSeen("evt_1"), "the window should have expired")
+}
+
+func TestSeenEvents_BoundedSize(t *testing.T) {
+	seen := newSeenEvents(time.Hour, 2)
+
+	assert.False(t, seen.markSeen("evt_1"))
+	assert.False(t, seen.markSeen("evt_2"))
+	assert.False(t, seen.markSeen("evt_3"))
+
+	// Assertions - the oldest ID was evicted to make room
+	assert.Len(t, seen.at, 2)
+	assert.False(t, seen.markSeen("evt_1"))
+	assert.True(t, seen.markSeen("evt_3"))
+}
+
+func TestPublishEvent_DropRateDropsEveryDelivery(t *testing.T) {
+	config := DefaultServerConfig()
+	config.DropRate = 1
+	server, conn := setupTestConsumerWithConfig(t, config, "*.pos.transaction")
+
+	for i := 0; i < 10; i++ {
+		server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: fmt.Sprintf("evt_%d", i), OrgID: "test_org"})
+	}
+
+	// Assertions
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err, "no event should be delivered")
+}
+
+func TestPublishEvent_LatencyDelaysDelivery(t *testing.T) {
+	config := DefaultServerConfig()
+	config.DeliveryLatency = 200 * time.Millisecond
+	server, conn := setupTestConsumerWithConfig(t, config, "*.pos.transaction")
+
+	start := time.Now()
+	server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: "evt_1", OrgID: "test_org"})
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var event BaseEvent
+	require.NoError(t, json.Unmarshal(data, &event))
+
+	// Assertions
+	assert.Equal(t, "evt_1", event.EventID)
+	assert.GreaterOrEqual(t, time.Since(start), config.DeliveryLatency)
+}
+
+func TestPublishEvent_DelayRateDelaysSomeDeliveries(t *testing.T) {
+	config := DefaultServerConfig()
+	config.DeliveryLatency = time.Hour
+	config.DelayRate = 0.5
+	server, conn := setupTestConsumerWithConfig(t, config, "*.pos.transaction")
+
+	// The first delivery rolls under the delay rate, the second over it
+	rolls := []float64{0.2, 0.8}
+	server.roll = func() float64 {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	}
+
+	server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: "evt_delayed", OrgID: "test_org"})
+	server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: "evt_immediate", OrgID: "test_org"})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	var event BaseEvent
+	require.NoError(t, json.Unmarshal(data, &event))
+
+	// Assertions
+	assert.Equal(t, "evt_immediate", event.EventID)
+}
+
+// Test replay
+func TestHandleConsumer_ReplaysFromEarliest(t *testing.T) {
+	server, httpServer := setupTestServer(t, DefaultServerConfig())
+
+	// Publish before anyone is listening, on two matching topics and one other
+	server.publishEvent("org_a.pos.transaction", BaseEvent{EventID: "evt_1", OrgID: "org_a"})
+	server.publishEvent("org_b.pos.transaction", BaseEvent{EventID: "evt_2", OrgID: "org_b"})
+	server.publishEvent("org_a.loyalty.action", BaseEvent{EventID: "evt_other", OrgID: "org_a"})
+	server.publishEvent("org_a.pos.transaction", BaseEvent{EventID: "evt_3", OrgID: "org_a"})
+
+	conn := connectTestConsumer(t, server, httpServer, "topic=*.pos.transaction&from=earliest", "*.pos.transaction")
+
+	// Live messages follow the replay
+	server.publishEvent("org_a.pos.transaction", BaseEvent{EventID: "evt_4", OrgID: "org_a"})
+
+	// Assertions
+	assert.Equal(t, []string{"evt_1", "evt_2", "evt_3", "evt_4"}, readEventIDs(t, conn, 4))
+}
+
+func TestHandleConsumer_LatestSkipsHistory(t *testing.T) {
+	server, httpServer := setupTestServer(t, DefaultServerConfig())
+
+	server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: "evt_old", OrgID: "test_org"})
+
+	conn := connectTestConsumer(t, server, httpServer, "topic=test_org.pos.transaction", "test_org.pos.transaction")
+	server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: "evt_new", OrgID: "test_org"})
+
+	// Assertions
+	assert.Equal(t, []string{"evt_new"}, readEventIDs(t, conn, 1))
+}
+
+func TestHandleConsumer_ReplayKeepsHistorySize(t *testing.T) {
+	config := DefaultServerConfig()
+	config.HistorySize = 3
+	server, httpServer := setupTestServer(t, config)
+
+	for i := 1; i <= 5; i++ {
+		server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: fmt.Sprintf("evt_%d", i), OrgID: "test_org"})
+	}
+
+	conn := connectTestConsumer(t, server, httpServer, "topic=test_org.pos.transaction&from=earliest", "test_org.pos.transaction")
+
+	// Assertions - only the newest three were kept
+	assert.Equal(t, []string{"evt_3", "evt_4", "evt_5"}, readEventIDs(t, conn, 3))
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err := conn.ReadMessage()
+	assert.Error(t, err, "nothing older should be replayed")
+}
+
+func TestHandleConsumer_ReplayDuringConcurrentPublishes(t *testing.T) {
+	server, httpServer := setupTestServer(t, DefaultServerConfig())
+
+	const publishes = 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < publishes; i++ {
+			server.publishEvent("test_org.pos.transaction", BaseEvent{EventID: fmt.Sprintf("evt_%d", i), OrgID: "test_org"})
+		}
+	}()
+
+	conn := connectTestConsumer(t, server, httpServer, "topic=test_org.pos.transaction&from=earliest", "test_org.pos.transaction")
+	wg.Wait()
+
+	// Assertions - every message arrives once, in order, whether replayed or live
+	expected := make([]string, publishes)
+	for i := range expected {
+		expected[i] = fmt.Sprintf("evt_%d", i)
+	}
+	assert.Equal(t, expected, readEventIDs(t, conn, publishes))
+}
+
+func TestHandleConsumer_InvalidFrom(t *testing.T) {
+	server := NewMockKafkaServer()
+
+	req := httptest.NewRequest("GET", "/consumer?topic=test_org.pos.transaction&from=yesterday", nil)
+	w := httptest.NewRecorder()
+	server.handleConsumer(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test messageRing
+func TestMessageRing_OverwritesOldest(t *testing.T) {
+	ring := newMessageRing(2)
+
+	assert.Empty(t, ring.all())
+	for i := uint64(1); i <= 3; i++ {
+		ring.push(bufferedMessage{sequence: i})
+	}
+
+	// Assertions
+	messages := ring.all()
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, uint64(2), messages[0].sequence)
+		assert.Equal(t, uint64(3), messages[1].sequence)
+	}
+}